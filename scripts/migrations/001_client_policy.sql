@@ -0,0 +1,23 @@
+-- ============================================================
+-- 001: OAuth client policy
+-- ============================================================
+-- Stores the token endpoint authentication method, response types
+-- and PKCE requirement enforced by the client policy validator
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS token_endpoint_auth_method VARCHAR(50) NOT NULL DEFAULT 'client_secret_post',
+    ADD COLUMN IF NOT EXISTS response_types TEXT[] NOT NULL DEFAULT '{code}',
+    ADD COLUMN IF NOT EXISTS require_pkce BOOLEAN DEFAULT false,
+    ADD COLUMN IF NOT EXISTS jwks_uri TEXT;
+
+-- Existing public clients authenticate without a secret
+UPDATE clients SET token_endpoint_auth_method = 'none', require_pkce = true WHERE public = true;
+
+COMMENT ON COLUMN clients.token_endpoint_auth_method IS 'client_secret_basic, client_secret_post, private_key_jwt or none (public clients)';
+COMMENT ON COLUMN clients.require_pkce IS 'If true, authorization requests without a S256 code_challenge are rejected at login';
+COMMENT ON COLUMN tenants.settings IS 'Tenant-specific configuration (email verification, password policy, session timeout, OAuth policy overrides, etc.)';
+
+COMMIT;
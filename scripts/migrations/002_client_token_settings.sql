@@ -0,0 +1,20 @@
+-- ============================================================
+-- 002: Per-client token lifespans, audiences and browser settings
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS token_lifespans JSONB NOT NULL DEFAULT '{}'::jsonb,
+    ADD COLUMN IF NOT EXISTS audience TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS allowed_cors_origins TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS skip_consent BOOLEAN DEFAULT false,
+    ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS frontchannel_logout_uri TEXT,
+    ADD COLUMN IF NOT EXISTS backchannel_logout_uri TEXT;
+
+COMMENT ON COLUMN clients.token_lifespans IS 'Per-grant token lifespans (Go duration strings) synced to Hydra; empty uses Hydra defaults';
+COMMENT ON COLUMN clients.audience IS 'Audiences this client may request for access tokens';
+COMMENT ON COLUMN clients.skip_consent IS 'If true, Hydra skips the consent screen for this client';
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- ============================================================
+-- 003: Remembered consent and first-party clients
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE clients
+    ADD COLUMN IF NOT EXISTS first_party BOOLEAN DEFAULT false;
+
+COMMENT ON COLUMN clients.first_party IS 'Trusted first-party client: consent is granted automatically';
+
+CREATE TABLE IF NOT EXISTS consent_grants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_consent_grants_user_client ON consent_grants(user_id, client_id);
+CREATE INDEX IF NOT EXISTS idx_consent_grants_tenant ON consent_grants(tenant_id);
+
+COMMENT ON TABLE consent_grants IS 'Scopes each user has granted to each OAuth client; only new scopes are prompted for';
+
+DROP TRIGGER IF EXISTS update_consent_grants_updated_at ON consent_grants;
+CREATE TRIGGER update_consent_grants_updated_at BEFORE UPDATE ON consent_grants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- ============================================================
+-- 004: Email address change with dual confirmation
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS email_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    old_email VARCHAR(255) NOT NULL,
+    new_email VARCHAR(255) NOT NULL,
+    token VARCHAR(255) NOT NULL UNIQUE,
+    cancel_token VARCHAR(255) NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user ON email_changes(user_id);
+CREATE INDEX IF NOT EXISTS idx_email_changes_deleted ON email_changes(deleted_at);
+
+COMMENT ON TABLE email_changes IS 'Pending email changes: confirmed from the new address, cancellable from the old one';
+COMMENT ON COLUMN email_changes.token IS 'Confirmation token sent to new_email';
+COMMENT ON COLUMN email_changes.cancel_token IS 'Cancel token sent to old_email';
+
+COMMIT;
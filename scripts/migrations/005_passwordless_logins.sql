@@ -0,0 +1,30 @@
+-- ============================================================
+-- 005: Passwordless login (magic link / email one-time code)
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS passwordless_logins (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    challenge_hash VARCHAR(64) NOT NULL,
+    method VARCHAR(10) NOT NULL CHECK (method IN ('link', 'code')),
+    token VARCHAR(255) NOT NULL UNIQUE,
+    code_hash VARCHAR(64),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_passwordless_logins_user ON passwordless_logins(user_id);
+CREATE INDEX IF NOT EXISTS idx_passwordless_logins_challenge ON passwordless_logins(challenge_hash);
+CREATE INDEX IF NOT EXISTS idx_passwordless_logins_deleted ON passwordless_logins(deleted_at);
+
+COMMENT ON TABLE passwordless_logins IS 'Single-use magic links and one-time codes bound to a Hydra login challenge';
+COMMENT ON COLUMN passwordless_logins.challenge_hash IS 'SHA-256 of the login_challenge the link or code was issued for';
+COMMENT ON COLUMN passwordless_logins.code_hash IS 'SHA-256 of the 6-digit code (method = code only)';
+
+COMMIT;
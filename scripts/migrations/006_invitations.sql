@@ -0,0 +1,33 @@
+-- ============================================================
+-- 006: Tenant invitations and invite-only registration
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    token VARCHAR(255) NOT NULL UNIQUE,
+    invited_by VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_tenant ON invitations(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);
+
+COMMENT ON TABLE invitations IS 'Invitations to join a tenant; accepting one creates a user with a verified email';
+COMMENT ON COLUMN invitations.roles IS 'Roles assigned to the user when the invitation is accepted';
+COMMENT ON COLUMN invitations.invited_by IS 'Admin who sent the invitation';
+
+DROP TRIGGER IF EXISTS update_invitations_updated_at ON invitations;
+CREATE TRIGGER update_invitations_updated_at BEFORE UPDATE ON invitations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
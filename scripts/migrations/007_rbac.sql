@@ -0,0 +1,43 @@
+-- ============================================================
+-- 007: Tenant roles, permissions and role assignments
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    client_id VARCHAR(255) NOT NULL DEFAULT '',
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_tenant_client_name ON roles(tenant_id, client_id, name);
+
+COMMENT ON TABLE roles IS 'Named permission sets within a tenant';
+COMMENT ON COLUMN roles.client_id IS 'OAuth client the role is scoped to; empty for tenant-wide roles';
+
+CREATE TABLE IF NOT EXISTS role_assignments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    subject_type VARCHAR(20) NOT NULL,
+    subject_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_role_assignments_subject ON role_assignments(role_id, subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_role_assignments_lookup ON role_assignments(subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_role_assignments_tenant_id ON role_assignments(tenant_id);
+
+COMMENT ON TABLE role_assignments IS 'Roles granted to users or groups';
+COMMENT ON COLUMN role_assignments.subject_type IS 'user or group';
+
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+CREATE TRIGGER update_roles_updated_at BEFORE UPDATE ON roles
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,68 @@
+-- ============================================================
+-- 009: Organizations (B2B customer accounts) within tenants
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) NOT NULL,
+    logo TEXT,
+    primary_color VARCHAR(20),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_tenant_slug ON organizations(tenant_id, slug);
+
+COMMENT ON TABLE organizations IS 'Customer accounts within a tenant';
+COMMENT ON COLUMN organizations.logo IS 'Branding override; empty inherits the tenant logo';
+COMMENT ON COLUMN organizations.primary_color IS 'Branding override; empty inherits the tenant color';
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members(user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_members_tenant_id ON organization_members(tenant_id);
+
+COMMENT ON COLUMN organization_members.role IS 'owner, admin or member';
+
+CREATE TABLE IF NOT EXISTS organization_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL,
+    verification_token VARCHAR(255) NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_domains_tenant_domain ON organization_domains(tenant_id, domain);
+CREATE INDEX IF NOT EXISTS idx_organization_domains_organization_id ON organization_domains(organization_id);
+
+COMMENT ON TABLE organization_domains IS 'Email domains claimed by organizations; verified domains grant automatic membership';
+COMMENT ON COLUMN organization_domains.verification_token IS 'Published as TXT "authway-verification=<token>" at _authway-verification.<domain>';
+
+-- Organization-scoped invitations
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS organization_role VARCHAR(20);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations(organization_id);
+
+DROP TRIGGER IF EXISTS update_organizations_updated_at ON organizations;
+CREATE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_organization_members_updated_at ON organization_members;
+CREATE TRIGGER update_organization_members_updated_at BEFORE UPDATE ON organization_members
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
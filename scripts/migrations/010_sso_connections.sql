@@ -0,0 +1,56 @@
+-- ============================================================
+-- 010: Enterprise SSO connections with home-realm discovery
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('oidc', 'saml')),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    issuer TEXT,
+    client_id VARCHAR(255),
+    client_secret TEXT,
+    scopes TEXT[],
+    attribute_mapping JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_connections_tenant_name ON connections(tenant_id, name);
+
+COMMENT ON TABLE connections IS 'Enterprise identity providers (upstream OIDC or SAML) of a tenant';
+COMMENT ON COLUMN connections.attribute_mapping IS 'Upstream claim or attribute names that fill the user profile';
+
+CREATE TABLE IF NOT EXISTS connection_domains (
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL,
+    connection_id UUID NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, domain)
+);
+
+CREATE INDEX IF NOT EXISTS idx_connection_domains_connection_id ON connection_domains(connection_id);
+
+COMMENT ON TABLE connection_domains IS 'Email domains routed to a connection by home-realm discovery';
+
+CREATE TABLE IF NOT EXISTS sso_identities (
+    connection_id UUID NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (connection_id, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_identities_user_id ON sso_identities(user_id);
+
+COMMENT ON TABLE sso_identities IS 'Links upstream accounts to the users they sign in as (just-in-time provisioning)';
+
+DROP TRIGGER IF EXISTS update_connections_updated_at ON connections;
+CREATE TRIGGER update_connections_updated_at BEFORE UPDATE ON connections
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,19 @@
+-- ============================================================
+-- 011: SAML 2.0 service provider settings for enterprise connections
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS idp_entity_id TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS idp_sso_url TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS idp_sso_binding VARCHAR(20);
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS idp_certificate TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS idp_metadata_url TEXT;
+
+COMMENT ON COLUMN connections.idp_entity_id IS 'SAML IdP entity ID, expected as the Issuer of responses and assertions';
+COMMENT ON COLUMN connections.idp_sso_url IS 'SAML IdP SingleSignOnService location receiving AuthnRequests';
+COMMENT ON COLUMN connections.idp_sso_binding IS 'Binding used to send AuthnRequests: redirect or post';
+COMMENT ON COLUMN connections.idp_certificate IS 'SAML IdP signing certificate (PEM) used to verify assertions';
+COMMENT ON COLUMN connections.idp_metadata_url IS 'URL the IdP metadata was imported from, if any';
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- ============================================================
+-- 012: SAML service providers (Authway as SAML identity provider)
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS saml_service_providers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    entity_id TEXT NOT NULL,
+    acs_urls TEXT[] NOT NULL,
+    name_id_format VARCHAR(20) NOT NULL DEFAULT 'persistent' CHECK (name_id_format IN ('email', 'persistent', 'transient', 'unspecified')),
+    attributes JSONB NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    client_id UUID NOT NULL REFERENCES clients(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saml_sps_tenant_entity ON saml_service_providers(tenant_id, entity_id);
+
+COMMENT ON TABLE saml_service_providers IS 'Legacy applications signing users in with SAML against a tenant';
+COMMENT ON COLUMN saml_service_providers.acs_urls IS 'Registered assertion consumer service URLs; assertions are only posted to these';
+COMMENT ON COLUMN saml_service_providers.attributes IS 'SAML attribute name to Authway claim released in it';
+COMMENT ON COLUMN saml_service_providers.client_id IS 'Backing OAuth client whose login flow authenticates SAML users';
+
+DROP TRIGGER IF EXISTS update_saml_service_providers_updated_at ON saml_service_providers;
+CREATE TRIGGER update_saml_service_providers_updated_at BEFORE UPDATE ON saml_service_providers
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
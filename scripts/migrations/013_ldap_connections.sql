@@ -0,0 +1,29 @@
+-- ============================================================
+-- 013: LDAP / Active Directory connections verifying passwords at login
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE connections DROP CONSTRAINT IF EXISTS connections_type_check;
+ALTER TABLE connections ADD CONSTRAINT connections_type_check CHECK (type IN ('oidc', 'saml', 'ldap'));
+
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_url TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_start_tls BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_ca_certificate TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_bind_dn TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_bind_password TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_search_base TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_user_filter TEXT;
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS ldap_group_attribute VARCHAR(255);
+ALTER TABLE connections ADD COLUMN IF NOT EXISTS group_roles JSONB NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN connections.ldap_url IS 'Directory URL: ldap://host[:389] or ldaps://host[:636]';
+COMMENT ON COLUMN connections.ldap_start_tls IS 'Upgrade ldap:// connections with the StartTLS extended operation';
+COMMENT ON COLUMN connections.ldap_ca_certificate IS 'CA certificate (PEM) trusted for the directory instead of the system roots';
+COMMENT ON COLUMN connections.ldap_bind_dn IS 'Service account that searches for users; empty for anonymous search';
+COMMENT ON COLUMN connections.ldap_search_base IS 'Subtree searched for user entries';
+COMMENT ON COLUMN connections.ldap_user_filter IS 'RFC 4515 filter with a {username} placeholder, default (mail={username})';
+COMMENT ON COLUMN connections.ldap_group_attribute IS 'Attribute listing the groups of a user entry, default memberOf';
+COMMENT ON COLUMN connections.group_roles IS 'Upstream group (DN or CN) to tenant role names granted at login';
+
+COMMIT;
@@ -0,0 +1,33 @@
+-- ============================================================
+-- 014: Legacy auth connectors (lazy user migration at login)
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS legacy_auth_connectors (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL UNIQUE REFERENCES tenants(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    url TEXT NOT NULL CHECK (url LIKE 'https://%'),
+    secret TEXT NOT NULL,
+    timeout_seconds INTEGER NOT NULL DEFAULT 5 CHECK (timeout_seconds BETWEEN 1 AND 30),
+    migrated_count BIGINT NOT NULL DEFAULT 0,
+    rejected_count BIGINT NOT NULL DEFAULT 0,
+    failure_count BIGINT NOT NULL DEFAULT 0,
+    last_migrated_at TIMESTAMP WITH TIME ZONE,
+    last_failure_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE legacy_auth_connectors IS 'Legacy systems checking credentials of emails unknown to a tenant; accepted users are created locally';
+COMMENT ON COLUMN legacy_auth_connectors.url IS 'HTTPS endpoint receiving HMAC-signed credential checks';
+COMMENT ON COLUMN legacy_auth_connectors.secret IS 'HMAC-SHA256 key signing requests in X-Authway-Signature';
+COMMENT ON COLUMN legacy_auth_connectors.migrated_count IS 'Users created from accepted credential checks';
+COMMENT ON COLUMN legacy_auth_connectors.failure_count IS 'Calls that timed out or failed; repeated failures open the circuit breaker';
+
+DROP TRIGGER IF EXISTS update_legacy_auth_connectors_updated_at ON legacy_auth_connectors;
+CREATE TRIGGER update_legacy_auth_connectors_updated_at BEFORE UPDATE ON legacy_auth_connectors
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,42 @@
+-- ============================================================
+-- 015: Bulk user import jobs and user metadata
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN users.metadata IS 'Free-form data attached by administrators, such as IDs in other systems';
+
+CREATE TABLE IF NOT EXISTS user_import_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    format VARCHAR(10) NOT NULL CHECK (format IN ('csv', 'jsonl')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    upsert BOOLEAN NOT NULL DEFAULT FALSE,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    updated_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_tenant ON user_import_jobs(tenant_id, created_at DESC);
+
+COMMENT ON TABLE user_import_jobs IS 'Asynchronous imports of a tenant''s users from CSV or JSON Lines files';
+COMMENT ON COLUMN user_import_jobs.dry_run IS 'Rows are validated and counted as created or updated without writing';
+COMMENT ON COLUMN user_import_jobs.upsert IS 'Rows whose email exists update that user instead of failing';
+COMMENT ON COLUMN user_import_jobs.errors IS 'Per-row error report (line, email, error), first 1000 rows only';
+
+DROP TRIGGER IF EXISTS update_user_import_jobs_updated_at ON user_import_jobs;
+CREATE TRIGGER update_user_import_jobs_updated_at BEFORE UPDATE ON user_import_jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
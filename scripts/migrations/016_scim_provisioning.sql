@@ -0,0 +1,33 @@
+-- ============================================================
+-- 016: SCIM 2.0 provisioning
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_users_external_id ON users(external_id);
+CREATE INDEX IF NOT EXISTS idx_groups_external_id ON groups(external_id);
+
+COMMENT ON COLUMN users.external_id IS 'Identifier assigned by the provisioning client (SCIM externalId)';
+COMMENT ON COLUMN groups.external_id IS 'Identifier assigned by the provisioning client (SCIM externalId)';
+
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    hint VARCHAR(20) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scim_tokens_tenant ON scim_tokens(tenant_id);
+
+COMMENT ON TABLE scim_tokens IS 'Bearer tokens that let a tenant''s identity provider provision users and groups over SCIM';
+COMMENT ON COLUMN scim_tokens.token_hash IS 'SHA-256 of the token; the plaintext is only shown when created';
+COMMENT ON COLUMN scim_tokens.hint IS 'Last characters of the token, to tell tokens apart';
+
+COMMIT;
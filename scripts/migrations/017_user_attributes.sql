@@ -0,0 +1,41 @@
+-- ============================================================
+-- 017: Custom user attributes
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN users.attributes IS 'Values of the tenant''s custom attributes, keyed by user_attribute_definitions.key';
+
+CREATE TABLE IF NOT EXISTS user_attribute_definitions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    key VARCHAR(63) NOT NULL,
+    display_name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('string', 'number', 'boolean', 'date', 'enum')),
+    options JSONB NOT NULL DEFAULT '[]',
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    "unique" BOOLEAN NOT NULL DEFAULT FALSE,
+    user_editable BOOLEAN NOT NULL DEFAULT FALSE,
+    in_token BOOLEAN NOT NULL DEFAULT FALSE,
+    claim_name VARCHAR(63) NOT NULL DEFAULT '',
+    scope VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT idx_user_attribute_definitions_key UNIQUE (tenant_id, key)
+);
+
+COMMENT ON TABLE user_attribute_definitions IS 'Per-tenant custom user attributes, validated on create and update';
+COMMENT ON COLUMN user_attribute_definitions.options IS 'Allowed values of enum attributes';
+COMMENT ON COLUMN user_attribute_definitions."unique" IS 'No two users of the tenant share a value';
+COMMENT ON COLUMN user_attribute_definitions.user_editable IS 'Users can set the value through the self-service API';
+COMMENT ON COLUMN user_attribute_definitions.in_token IS 'Issued as a token claim when the scope is granted';
+COMMENT ON COLUMN user_attribute_definitions.claim_name IS 'Claim name; empty uses the key';
+COMMENT ON COLUMN user_attribute_definitions.scope IS 'Scope that releases the claim; empty means profile';
+
+DROP TRIGGER IF EXISTS update_user_attribute_definitions_updated_at ON user_attribute_definitions;
+CREATE TRIGGER update_user_attribute_definitions_updated_at BEFORE UPDATE ON user_attribute_definitions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,37 @@
+-- ============================================================
+-- 018: Data subject requests (GDPR export and erasure)
+-- ============================================================
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('export', 'erasure')),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('scheduled', 'completed', 'cancelled')),
+    mode VARCHAR(20) NOT NULL DEFAULT '' CHECK (mode IN ('', 'delete', 'pseudonymize')),
+    requested_by VARCHAR(20) NOT NULL CHECK (requested_by IN ('user', 'admin')),
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_user ON privacy_requests(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_privacy_requests_due ON privacy_requests(scheduled_for) WHERE type = 'erasure' AND status = 'scheduled';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_privacy_requests_pending_erasure ON privacy_requests(user_id) WHERE type = 'erasure' AND status = 'scheduled';
+
+COMMENT ON TABLE privacy_requests IS 'Data subject requests; rows hold no personal data and remain as the audit trail after erasure';
+COMMENT ON COLUMN privacy_requests.user_id IS 'Not a foreign key: the user row is deleted or pseudonymized by the erasure';
+COMMENT ON COLUMN privacy_requests.mode IS 'Erasure only: delete removes the user row, pseudonymize keeps it without personal data';
+COMMENT ON COLUMN privacy_requests.scheduled_for IS 'When a scheduled erasure runs; it can be cancelled until then';
+COMMENT ON COLUMN privacy_requests.error IS 'Last failed erasure attempt; retried on the next run';
+
+DROP TRIGGER IF EXISTS update_privacy_requests_updated_at ON privacy_requests;
+CREATE TRIGGER update_privacy_requests_updated_at BEFORE UPDATE ON privacy_requests
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
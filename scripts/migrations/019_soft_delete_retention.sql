@@ -0,0 +1,22 @@
+-- ============================================================
+-- 019: Uniqueness across soft-deleted records
+-- ============================================================
+-- Deleted users and tenants release their email and slug, which may then be reused
+-- (restoring one fails while a live record holds its key).
+-- Client IDs are never reused while the deleted client exists, so clients.client_id stays unique over all rows.
+
+BEGIN;
+
+ALTER TABLE tenants DROP CONSTRAINT IF EXISTS tenants_slug_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_slug_active ON tenants(slug) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_clients_deleted_at ON clients(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_tenants_deleted_at ON tenants(deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMENT ON COLUMN tenants.slug IS 'URL-friendly identifier, unique among non-deleted tenants';
+COMMENT ON COLUMN tenants.deleted_at IS 'Soft delete time; the tenant can be restored until it is purged after retention.soft_delete_period';
+COMMENT ON COLUMN users.deleted_at IS 'Soft delete time; the user can be restored until it is purged after retention.soft_delete_period';
+COMMENT ON COLUMN clients.deleted_at IS 'Soft delete time; the client can be restored until it is purged after retention.soft_delete_period';
+
+COMMIT;
@@ -0,0 +1,20 @@
+-- ============================================================
+-- 020: Expiring remembered consent
+-- ============================================================
+-- Grants are stored only when the user asks to remember consent and lapse after
+-- oauth.consent_remember_for. Existing grants had no expiry and were also written for
+-- consents that were not remembered, so they expire now; users are prompted once more.
+
+BEGIN;
+
+ALTER TABLE consent_grants
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+ALTER TABLE consent_grants
+    ALTER COLUMN expires_at DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_consent_grants_expires_at ON consent_grants(expires_at);
+
+COMMENT ON TABLE consent_grants IS 'Remembered consent: scopes each user has granted to each OAuth client; only new scopes are prompted for until expires_at';
+COMMENT ON COLUMN consent_grants.expires_at IS 'End of the remember period; the grant no longer skips the consent screen after this time';
+
+COMMIT;
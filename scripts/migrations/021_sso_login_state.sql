@@ -0,0 +1,42 @@
+-- ============================================================
+-- 021: Shared enterprise login state and SAML replay cache
+-- ============================================================
+-- Enterprise logins in progress were kept in process memory, so the upstream callback
+-- failed when it reached another instance or arrived after a restart. They are stored
+-- here for 15 minutes, keyed by a hash of the state parameter (OIDC) or RelayState (SAML).
+-- Accepted SAML assertion IDs are kept until the assertion expires, so a replay is caught
+-- by every instance.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS sso_login_states (
+    key_hash VARCHAR(64) PRIMARY KEY,
+    connection_id UUID NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    login_challenge TEXT NOT NULL,
+    organization VARCHAR(255) NOT NULL DEFAULT '',
+    nonce VARCHAR(255) NOT NULL DEFAULT '',
+    code_verifier VARCHAR(255) NOT NULL DEFAULT '',
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    authn_request BYTEA,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sso_login_states_expires_at ON sso_login_states(expires_at);
+
+COMMENT ON TABLE sso_login_states IS 'Enterprise logins waiting for the upstream identity provider; single use, removed after expires_at';
+COMMENT ON COLUMN sso_login_states.key_hash IS 'SHA-256 of the state parameter (OIDC) or RelayState (SAML); the value itself is not stored';
+COMMENT ON COLUMN sso_login_states.request_id IS 'ID of the SAML AuthnRequest the assertion must answer (InResponseTo)';
+
+CREATE TABLE IF NOT EXISTS saml_used_assertions (
+    connection_id UUID NOT NULL REFERENCES connections(id) ON DELETE CASCADE,
+    assertion_id VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (connection_id, assertion_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saml_used_assertions_expires_at ON saml_used_assertions(expires_at);
+
+COMMENT ON TABLE saml_used_assertions IS 'SAML assertions already used to sign in, rejected as replays until expires_at';
+
+COMMIT;
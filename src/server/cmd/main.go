@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto"
+	"crypto/x509"
 	"fmt"
 	"log"
 	"os"
@@ -14,18 +16,33 @@ import (
 	"authway/src/server/internal/middleware"
 	"authway/src/server/internal/service"
 	"authway/src/server/internal/service/social"
+	"authway/src/server/internal/service/sso"
 	"authway/src/server/internal/telemetry"
 	"authway/src/server/pkg/admin"
+	"authway/src/server/pkg/attribute"
+	"authway/src/server/pkg/bulk"
+	"authway/src/server/pkg/captcha"
 	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/consent"
 	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/invitation"
+	"authway/src/server/pkg/legacyauth"
 	adminMiddleware "authway/src/server/pkg/middleware"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/privacy"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/samlsp"
+	"authway/src/server/pkg/scim"
 	"authway/src/server/pkg/tenant"
 	"authway/src/server/pkg/user"
-	"github.com/go-playground/validator/v10"
+	"authway/src/server/pkg/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -94,12 +111,59 @@ func main() {
 	hydraClient := hydra.NewClient(cfg.Hydra.AdminURL)
 
 	// Initialize validator
-	validate := validator.New()
+	validate := handler.NewValidator()
 
 	// Initialize services
 	userService := user.NewService(db, zapLogger)
-	clientService := client.NewService(db, zapLogger, hydraClient)
+	clientPolicy := client.Policy{
+		AllowedGrantTypes: cfg.OAuth.AllowedGrantTypes,
+		AllowedScopes:     cfg.OAuth.AllowedScopes,
+		RequirePKCE:       cfg.OAuth.RequirePKCE,
+	}
+	clientService := client.NewService(db, zapLogger, hydraClient, clientPolicy)
+	consentService := consent.NewService(db, zapLogger)
+	rbacService := rbac.NewService(db, zapLogger)
+	groupService := group.NewService(db, zapLogger)
+	orgService := organization.NewService(db, zapLogger)
+	invitationService := invitation.NewService(db, zapLogger)
+	connectionService := connection.NewService(db, zapLogger)
+	ssoStateStore := connection.NewStateStore(db)
+	legacyAuthService := legacyauth.NewService(db, zapLogger)
+	attributeService := attribute.NewService(db, zapLogger)
+	erasureGracePeriod, err := time.ParseDuration(cfg.Privacy.ErasureGracePeriod)
+	if err != nil {
+		zapLogger.Fatal("Invalid privacy.erasure_grace_period", zap.Error(err))
+	}
+	privacyService := privacy.NewService(db, zapLogger, hydraClient, erasureGracePeriod, cfg.Privacy.ErasureMode)
+	softDeleteRetention, err := time.ParseDuration(cfg.Retention.SoftDeletePeriod)
+	if err != nil {
+		zapLogger.Fatal("Invalid retention.soft_delete_period", zap.Error(err))
+	}
+	bulkService := bulk.NewService(db, zapLogger, userService)
+	scimService := scim.NewService(db, zapLogger, userService, groupService, strings.TrimSuffix(cfg.App.BaseURL, "/")+handler.SCIMBasePath)
+	webhooks := webhook.New(cfg.Webhook.URL, cfg.Webhook.Secret, zapLogger)
 	googleService := social.NewGoogleService(&cfg.Google, userService, clientService, zapLogger)
+	oidcService := sso.NewOIDCService(zapLogger)
+	samlService := sso.NewSAMLService(sso.DefaultClockSkew, ssoStateStore, zapLogger)
+	ldapService := sso.NewLDAPService(sso.DefaultLDAPTimeout, zapLogger)
+	samlSPService := samlsp.NewService(db, zapLogger, clientService, strings.TrimSuffix(cfg.App.BaseURL, "/")+handler.SAMLIdPCallbackPath)
+
+	// SAML IdP signing key: service providers pin its certificate from the IdP metadata
+	var samlIdPKey crypto.Signer
+	var samlIdPCert *x509.Certificate
+	if cfg.SAML.IdPKeyPath != "" {
+		samlIdPKey, samlIdPCert, err = sso.LoadIdentityProviderKey(cfg.SAML.IdPKeyPath, cfg.SAML.IdPCertificatePath)
+	} else if cfg.App.Environment == "development" {
+		zapLogger.Warn("saml.idp_key_path is not set, signing SAML assertions with an ephemeral key")
+		samlIdPKey, samlIdPCert, err = sso.GenerateIdentityProviderKey(cfg.App.Name)
+	} else {
+		// An ephemeral key changes on every restart and breaks the certificate service providers pinned
+		zapLogger.Fatal("saml.idp_key_path is required outside development")
+	}
+	if err != nil {
+		zapLogger.Fatal("Failed to load SAML IdP signing key", zap.Error(err))
+	}
+	identityProvider := sso.NewIdentityProvider(samlIdPKey, samlIdPCert, zapLogger)
 
 	// Initialize email services
 	emailConfig := email.Config{
@@ -152,10 +216,36 @@ func main() {
 	})
 
 	// Initialize handlers
-	authHandler := handler.NewAuthHandler(userService, clientService, hydraClient, zapLogger)
+	consentRememberFor, err := time.ParseDuration(cfg.OAuth.ConsentRememberFor)
+	if err != nil {
+		zapLogger.Fatal("Invalid oauth.consent_remember_for", zap.Error(err))
+	}
+	authHandler := handler.NewAuthHandler(userService, clientService, connectionService, ldapService, legacyAuthService, consentService, rbacService, groupService, orgService, attributeService, consent.NewClaimMapper(cfg.OAuth.ScopeClaims), consentRememberFor, hydraClient, zapLogger)
 	socialHandler := handler.NewSocialHandler(googleService, userService, hydraClient, zapLogger)
 	clientHandler := handler.NewClientHandler(services, zapLogger)
+	userHandler := handler.NewUserHandler(services, attributeService, zapLogger)
+	bulkUserHandler := handler.NewBulkUserHandler(bulkService, tenantService, zapLogger)
+	privacyHandler := handler.NewPrivacyHandler(privacyService, userService, validate, zapLogger)
+	connectedAppHandler := handler.NewConnectedAppHandler(hydraClient, clientService, consentService, zapLogger)
+	meHandler := handler.NewMeHandler(userService, attributeService, privacyService, emailRepo, emailService, hydraClient, validate, zapLogger)
 	emailHandler := handler.NewEmailHandler(emailRepo, emailService, userService, hydraClient, validate, zapLogger)
+	invitationHandler := handler.NewInvitationHandler(invitationService, userService, tenantService, rbacService, orgService, emailService, validate, zapLogger)
+	captchaVerifier, err := captcha.New(cfg.Captcha.Provider, cfg.Captcha.VerifyURL, cfg.Captcha.Secret, cfg.Captcha.TestToken)
+	if err != nil {
+		zapLogger.Fatal("Invalid CAPTCHA configuration", zap.Error(err))
+	}
+	registrationHandler := handler.NewRegistrationHandler(userService, tenantService, attributeService, emailRepo, emailService, captchaVerifier, validate, zapLogger)
+	roleHandler := handler.NewRoleHandler(rbacService, userService, clientService, groupService, tenantService, validate, zapLogger)
+	organizationHandler := handler.NewOrganizationHandler(orgService, invitationService, userService, tenantService, emailService, validate, zapLogger)
+	groupHandler := handler.NewGroupHandler(groupService, rbacService, userService, tenantService, webhooks, validate, zapLogger)
+	connectionHandler := handler.NewConnectionHandler(connectionService, tenantService, samlService, validate, zapLogger, cfg.App.BaseURL)
+	legacyAuthHandler := handler.NewLegacyAuthHandler(legacyAuthService, tenantService, validate, zapLogger)
+	attributeHandler := handler.NewAttributeHandler(attributeService, tenantService, validate, zapLogger)
+	scimHandler := handler.NewSCIMHandler(scimService, tenantService, hydraClient, validate, zapLogger, cfg.App.BaseURL)
+	ssoHandler := handler.NewSSOHandler(connectionService, ssoStateStore, oidcService, samlService, userService, clientService, hydraClient, authHandler, validate, zapLogger, cfg.App.BaseURL)
+	samlIdPHandler := handler.NewSAMLIdPHandler(samlSPService, identityProvider, tenantService, clientService, userService, hydraClient, zapLogger, cfg.App.BaseURL, cfg.Hydra.PublicURL)
+	samlSPHandler := handler.NewSAMLServiceProviderHandler(samlSPService, tenantService, validate, zapLogger, cfg.App.BaseURL)
+	passwordlessHandler := handler.NewPasswordlessHandler(userService, clientService, tenantService, emailRepo, emailService, hydraClient, authHandler, validate, zapLogger)
 
 	// Auth routes for Hydra login/consent flow
 	app.Get("/login", authHandler.LoginPage)
@@ -166,8 +256,14 @@ func main() {
 	app.Post("/consent/accept", authHandler.Consent) // Actual consent submission
 	app.Post("/consent/reject", authHandler.RejectConsent)
 
+	// Passwordless (magic link / email code) login for tenants that enable it
+	passwordlessHandler.RegisterRoutes(app)
+
+	// SCIM 2.0 provisioning for tenants' IdPs, authenticated with tenant-scoped tokens
+	scimHandler.RegisterRoutes(app.Group(handler.SCIMBasePath))
+
 	// User registration
-	app.Post("/register", authHandler.Register)
+	app.Post("/register", registrationHandler.Register)
 
 	// Social login routes
 	app.Get("/auth/google/login", socialHandler.GoogleLogin)
@@ -175,34 +271,124 @@ func main() {
 	app.Get("/auth/google/callback", socialHandler.GoogleCallback)
 	app.Get("/auth/google/url", socialHandler.GetGoogleAuthURL)
 
+	// Enterprise SSO: home-realm discovery and upstream identity provider callbacks
+	ssoHandler.RegisterRoutes(app)
+
+	// SAML identity provider for legacy applications (logins run through the Hydra flow above)
+	samlIdPHandler.RegisterRoutes(app)
+
 	// API routes
 	api := app.Group("/api")
 
 	// Email verification and password reset routes
 	emailHandler.RegisterRoutes(api)
 
+	// Invitation preview and acceptance
+	invitationHandler.RegisterRoutes(api)
+
 	// API v1 routes
 	v1 := app.Group("/api/v1")
 
+	// Bearer token validation: Hydra introspection with a short cache,
+	// plus local verification of JWT access tokens when a JWKS URL is configured
+	introspectionCacheTTL, err := time.ParseDuration(cfg.Hydra.IntrospectionCacheTTL)
+	if err != nil {
+		zapLogger.Fatal("Invalid hydra.introspection_cache_ttl", zap.Error(err))
+	}
+	var jwksValidator *middleware.JWKSValidator
+	if cfg.Hydra.JWKSURL != "" {
+		jwksValidator = middleware.NewJWKSValidator(cfg.Hydra.JWKSURL, cfg.Hydra.Issuer, cfg.Hydra.Audience, time.Hour)
+	}
+	tokenValidator := middleware.NewHybridValidator(jwksValidator, middleware.NewIntrospectionValidator(hydraClient, cfg.Hydra.Audience, introspectionCacheTTL))
+	requireAuth := middleware.RequireAuth(tokenValidator, func(subject string) (string, error) {
+		// User tokens carry the user ID as subject, client_credentials tokens the client ID
+		if userID, err := uuid.Parse(subject); err == nil {
+			u, err := userService.GetByID(userID)
+			if err != nil {
+				return "", err
+			}
+			if !u.Active {
+				return "", fmt.Errorf("user is inactive")
+			}
+			return u.TenantID.String(), nil
+		}
+		c, err := clientService.GetByClientID(subject)
+		if err != nil {
+			return "", err
+		}
+		return c.TenantID.String(), nil
+	})
+
 	// User profile routes
-	v1.Get("/profile/:id", authHandler.Profile)
+	v1.Get("/profile/:id", requireAuth, authHandler.Profile)
 
-	// Client management routes
-	v1.Post("/clients", clientHandler.Create)
-	v1.Get("/clients/:id", clientHandler.Get)
-	v1.Put("/clients/:id", clientHandler.Update)
-	v1.Delete("/clients/:id", clientHandler.Delete)
-	v1.Get("/clients", clientHandler.List)
-	v1.Post("/clients/:id/regenerate-secret", clientHandler.RegenerateSecret)
+	// Self-service routes (user's bearer token with the account scope required)
+	me := v1.Group("/me", requireAuth, middleware.RequireScope(consent.ScopeAccount))
+	meHandler.RegisterRoutes(me)
+	me.Get("/connected-apps", connectedAppHandler.ListMine)
+	me.Delete("/connected-apps/:client_id", connectedAppHandler.RevokeMine)
+
+	// User management routes (Admin only)
+	adminAuth := adminMiddleware.AdminAuth(cfg.Admin.APIKey)
+	users := v1.Group("/users", adminAuth)
+	users.Get("/", userHandler.List)
+	users.Post("/import", userHandler.Import)
+	bulkUserHandler.RegisterRoutes(users)
+	users.Get("/deleted", userHandler.ListDeleted)
+	users.Get("/:id", userHandler.Get)
+	users.Put("/:id", userHandler.Update)
+	users.Delete("/:id", userHandler.Delete)
+	users.Post("/:id/restore", userHandler.Restore)
+	privacyHandler.RegisterRoutes(users)
+	users.Get("/:id/connected-apps", connectedAppHandler.ListForUser)
+	users.Delete("/:id/connected-apps/:client_id", connectedAppHandler.RevokeForUser)
+	users.Get("/:id/roles", roleHandler.UserRoles)
+	users.Get("/:id/groups", groupHandler.UserGroups)
+	users.Get("/:id/organizations", organizationHandler.UserOrganizations)
+
+	// Role and permission management routes (Admin only)
+	roleHandler.RegisterRoutes(v1.Group("/roles", adminAuth))
+
+	// Group and membership management routes (Admin only)
+	groupHandler.RegisterRoutes(v1.Group("/groups", adminAuth))
+
+	// Organization (B2B customer account) management routes (Admin only)
+	organizationHandler.RegisterRoutes(v1.Group("/organizations", adminAuth))
+
+	// Enterprise connection management routes (Admin only)
+	connectionHandler.RegisterRoutes(v1.Group("/connections", adminAuth))
+
+	// Legacy auth connector routes for lazy user migration (Admin only)
+	legacyAuthHandler.RegisterRoutes(v1.Group("/legacy-auth-connectors", adminAuth))
+	attributeHandler.RegisterRoutes(v1.Group("/user-attributes", adminAuth))
+
+	// SCIM token management routes (Admin only)
+	scimHandler.RegisterAdminRoutes(v1.Group("/scim-tokens", adminAuth))
+
+	// SAML service provider registration routes (Admin only)
+	samlSPHandler.RegisterRoutes(v1.Group("/saml-service-providers", adminAuth))
+
+	// Invitation management routes (Admin only)
+	invitationHandler.RegisterAdminRoutes(v1.Group("/invitations", adminAuth))
+
+	// Client management routes (Admin only): clients can be marked first-party, which skips consent
+	clients := v1.Group("/clients", adminAuth)
+	clients.Post("/", clientHandler.Create)
+	clients.Get("/deleted", clientHandler.ListDeleted)
+	clients.Get("/:id", clientHandler.Get)
+	clients.Put("/:id", clientHandler.Update)
+	clients.Delete("/:id", clientHandler.Delete)
+	clients.Post("/:id/restore", clientHandler.Restore)
+	clients.Get("/", clientHandler.List)
+	clients.Post("/:id/regenerate-secret", clientHandler.RegenerateSecret)
 
 	// Client Google OAuth configuration routes
-	v1.Put("/clients/:id/google-oauth", clientHandler.UpdateGoogleOAuth)
-	v1.Delete("/clients/:id/google-oauth", clientHandler.DisableGoogleOAuth)
-	v1.Get("/clients/:id/google-oauth/status", clientHandler.GetGoogleOAuthStatus)
+	clients.Put("/:id/google-oauth", clientHandler.UpdateGoogleOAuth)
+	clients.Delete("/:id/google-oauth", clientHandler.DisableGoogleOAuth)
+	clients.Get("/:id/google-oauth/status", clientHandler.GetGoogleOAuthStatus)
 
 	// Tenant Management API routes (Admin only)
 	tenantHandler := tenant.NewHandler(tenantService, validate)
-	adminAuth := adminMiddleware.AdminAuth(cfg.Admin.APIKey)
 	tenantHandler.RegisterRoutes(app, adminAuth)
 
 	// Admin Console routes
@@ -219,6 +405,63 @@ func main() {
 		}
 	}()
 
+	// Cleanup expired enterprise login states and used SAML assertions periodically
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := ssoStateStore.CleanupExpired(); err != nil {
+				zapLogger.Error("Failed to cleanup expired SSO states", zap.Error(err))
+			}
+		}
+	}()
+
+	// Erase users whose erasure grace period is over
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if erased, err := privacyService.ProcessDueErasures(); err != nil {
+				zapLogger.Error("Failed to process due erasures", zap.Error(err))
+			} else if erased > 0 {
+				zapLogger.Info("Processed due erasures", zap.Int("erased", erased))
+			}
+		}
+	}()
+
+	// Purge users, clients and tenants deleted longer than the retention period ago
+	if softDeleteRetention > 0 {
+		go func() {
+			ticker := time.NewTicker(1 * time.Hour)
+			defer ticker.Stop()
+			for range ticker.C {
+				before := time.Now().Add(-softDeleteRetention)
+				purgeAssignments := func(userID uuid.UUID) error {
+					return rbacService.DeleteSubjectAssignments(rbac.SubjectUser, userID)
+				}
+				if purged, err := userService.Purge(before, purgeAssignments); err != nil {
+					zapLogger.Error("Failed to purge deleted users", zap.Error(err))
+				} else if purged > 0 {
+					zapLogger.Info("Purged deleted users", zap.Int64("purged", purged))
+				}
+				if purged, err := clientService.Purge(before); err != nil {
+					zapLogger.Error("Failed to purge deleted clients", zap.Error(err))
+				} else if purged > 0 {
+					zapLogger.Info("Purged deleted clients", zap.Int64("purged", purged))
+				}
+				purgeClients := func(tenantID uuid.UUID) error {
+					_, err := clientService.PurgeTenant(tenantID)
+					return err
+				}
+				if purged, err := tenantService.PurgeTenants(before, purgeClients); err != nil {
+					zapLogger.Error("Failed to purge deleted tenants", zap.Error(err))
+				} else if purged > 0 {
+					zapLogger.Info("Purged deleted tenants", zap.Int64("purged", purged))
+				}
+			}
+		}()
+	}
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -22,6 +22,11 @@ type Config struct {
 	GitHub              GitHubOAuthConfig         `mapstructure:"github"`
 	Tenant              TenantConfig              `mapstructure:"tenant"`
 	Admin               AdminConfig               `mapstructure:"admin"`
+	Captcha             CaptchaConfig             `mapstructure:"captcha"`
+	Webhook             WebhookConfig             `mapstructure:"webhook"`
+	SAML                SAMLConfig                `mapstructure:"saml"`
+	Privacy             PrivacyConfig             `mapstructure:"privacy"`
+	Retention           RetentionConfig           `mapstructure:"retention"`
 	ApplicationInsights ApplicationInsightsConfig `mapstructure:"applicationinsights"`
 }
 
@@ -65,6 +70,10 @@ type OAuthConfig struct {
 	AllowedGrantTypes   []string `mapstructure:"allowed_grant_types"`
 	AllowedScopes       []string `mapstructure:"allowed_scopes"`
 	RequirePKCE         bool     `mapstructure:"require_pkce"`
+	ConsentRememberFor  string   `mapstructure:"consent_remember_for"` // e.g. "720h"; longest time Hydra remembers a consent
+
+	// ScopeClaims maps custom scopes to the user claims they release (openid, profile, email and tenant are built in)
+	ScopeClaims map[string][]string `mapstructure:"scope_claims"`
 }
 
 type CORSConfig struct {
@@ -74,6 +83,12 @@ type CORSConfig struct {
 type HydraConfig struct {
 	AdminURL  string `mapstructure:"admin_url"`
 	PublicURL string `mapstructure:"public_url"`
+
+	// Access token validation for bearer-protected APIs
+	IntrospectionCacheTTL string `mapstructure:"introspection_cache_ttl"` // e.g. "30s"; "0s" disables the cache
+	JWKSURL               string `mapstructure:"jwks_url"`                // Verify JWT access tokens locally (empty: always introspect)
+	Issuer                string `mapstructure:"issuer"`                  // Expected "iss" of JWT access tokens (optional)
+	Audience              string `mapstructure:"audience"`                // Expected "aud" of access tokens (optional)
 }
 
 type EmailConfig struct {
@@ -110,6 +125,37 @@ type AdminConfig struct {
 	Password string `mapstructure:"password"`
 }
 
+// CaptchaConfig enables CAPTCHA checks on self-service registration
+type CaptchaConfig struct {
+	Provider  string `mapstructure:"provider"`   // "" (disabled), "local" or "siteverify"
+	VerifyURL string `mapstructure:"verify_url"` // siteverify endpoint (reCAPTCHA, hCaptcha, Turnstile)
+	Secret    string `mapstructure:"secret"`
+	TestToken string `mapstructure:"test_token"` // Token accepted by the local provider (development only)
+}
+
+// WebhookConfig enables event delivery (e.g. group membership changes) to an HTTP endpoint
+type WebhookConfig struct {
+	URL    string `mapstructure:"url"`    // "" disables webhooks
+	Secret string `mapstructure:"secret"` // Signs deliveries in the X-Authway-Signature header
+}
+
+// SAMLConfig holds the key Authway signs assertions with as a SAML identity provider
+type SAMLConfig struct {
+	IdPKeyPath         string `mapstructure:"idp_key_path"`         // PEM private key; "" generates an ephemeral key (development only)
+	IdPCertificatePath string `mapstructure:"idp_certificate_path"` // PEM certificate published in the IdP metadata
+}
+
+// PrivacyConfig controls how data subject erasure requests are carried out
+type PrivacyConfig struct {
+	ErasureGracePeriod string `mapstructure:"erasure_grace_period"` // e.g. "720h"; "0s" erases immediately
+	ErasureMode        string `mapstructure:"erasure_mode"`         // "delete" or "pseudonymize" when a request names none
+}
+
+// RetentionConfig controls how long soft-deleted users, clients and tenants can be restored before they are purged
+type RetentionConfig struct {
+	SoftDeletePeriod string `mapstructure:"soft_delete_period"` // e.g. "720h"; "0s" (the default) keeps deleted records forever
+}
+
 type ApplicationInsightsConfig struct {
 	ConnectionString string `mapstructure:"connection_string"`
 	Enabled          bool   `mapstructure:"enabled"`
@@ -253,6 +299,10 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if mode := c.Privacy.ErasureMode; mode != "" && mode != "delete" && mode != "pseudonymize" {
+		errors = append(errors, "privacy.erasure_mode must be delete or pseudonymize")
+	}
+
 	// Warn about missing admin password in all environments
 	if c.Admin.Password == "" {
 		errors = append(errors, "WARNING: admin.password is not set - admin console will be inaccessible")
@@ -298,12 +348,14 @@ func setDefaults() {
 	// OAuth defaults
 	viper.SetDefault("oauth.authorize_code_expiry", "10m")
 	viper.SetDefault("oauth.allowed_grant_types", []string{"authorization_code", "refresh_token"})
-	viper.SetDefault("oauth.allowed_scopes", []string{"openid", "profile", "email"})
+	viper.SetDefault("oauth.allowed_scopes", []string{"openid", "profile", "email", "offline_access", "tenant", "roles", "groups", "account"})
 	viper.SetDefault("oauth.require_pkce", true)
+	viper.SetDefault("oauth.consent_remember_for", "720h")
 
 	// Hydra defaults
 	viper.SetDefault("hydra.admin_url", "http://localhost:4445")
 	viper.SetDefault("hydra.public_url", "http://localhost:4444")
+	viper.SetDefault("hydra.introspection_cache_ttl", "30s")
 
 	// CORS defaults
 	viper.SetDefault("cors.allowed_origins", []string{"http://localhost:3000", "http://localhost:3001"})
@@ -331,6 +383,19 @@ func setDefaults() {
 	viper.SetDefault("admin.api_key", "")
 	viper.SetDefault("admin.password", "admin123") // Default for development only
 
+	// CAPTCHA defaults (disabled)
+	viper.SetDefault("captcha.provider", "")
+
+	// Webhook defaults (disabled)
+	viper.SetDefault("webhook.url", "")
+
+	// Privacy defaults (30 day grace period before erasure)
+	viper.SetDefault("privacy.erasure_grace_period", "720h")
+	viper.SetDefault("privacy.erasure_mode", "delete")
+
+	// Retention defaults (deleted records can be restored for 30 days)
+	viper.SetDefault("retention.soft_delete_period", "0s")
+
 	// Application Insights defaults (completely optional)
 	viper.SetDefault("applicationinsights.enabled", false)
 	viper.SetDefault("applicationinsights.connection_string", "")
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+
+	"authway/src/server/pkg/attribute"
+	"authway/src/server/pkg/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// AttributeHandler manages tenants' custom user attribute definitions
+type AttributeHandler struct {
+	attributeService attribute.Service
+	tenantService    *tenant.Service
+	validator        *validator.Validate
+	logger           *zap.Logger
+}
+
+func NewAttributeHandler(
+	attributeService attribute.Service,
+	tenantService *tenant.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *AttributeHandler {
+	return &AttributeHandler{
+		attributeService: attributeService,
+		tenantService:    tenantService,
+		validator:        validator,
+		logger:           logger,
+	}
+}
+
+// RegisterRoutes registers attribute definition routes on an admin-protected group
+func (h *AttributeHandler) RegisterRoutes(attributes fiber.Router) {
+	attributes.Post("/", h.Create)
+	attributes.Get("/", h.List)
+	attributes.Get("/:id", h.Get)
+	attributes.Put("/:id", h.Update)
+	attributes.Delete("/:id", h.Delete)
+}
+
+func (h *AttributeHandler) Create(c *fiber.Ctx) error {
+	var req attribute.CreateDefinitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	created, err := h.attributeService.Create(tenantID, &req)
+	if err != nil {
+		return h.attributeError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// List returns a tenant's attribute definitions
+// GET /api/v1/user-attributes?tenant_id=...
+func (h *AttributeHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	defs, err := h.attributeService.List(tenantID)
+	if err != nil {
+		return h.attributeError(err)
+	}
+	return c.JSON(fiber.Map{
+		"attributes": defs,
+	})
+}
+
+func (h *AttributeHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid attribute ID")
+	}
+
+	found, err := h.attributeService.Get(id)
+	if err != nil {
+		return h.attributeError(err)
+	}
+	return c.JSON(found)
+}
+
+func (h *AttributeHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid attribute ID")
+	}
+
+	var req attribute.UpdateDefinitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	updated, err := h.attributeService.Update(id, &req)
+	if err != nil {
+		return h.attributeError(err)
+	}
+	return c.JSON(updated)
+}
+
+// Delete removes the definition and every user's value of the attribute
+func (h *AttributeHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid attribute ID")
+	}
+
+	if err := h.attributeService.Delete(id); err != nil {
+		return h.attributeError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AttributeHandler) attributeError(err error) error {
+	switch {
+	case errors.Is(err, attribute.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Attribute not found")
+	case errors.Is(err, attribute.ErrDuplicateKey):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, attribute.ErrInvalidDefinition), errors.Is(err, attribute.ErrReservedClaim):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Attribute definition operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process attribute definition")
+	}
+}
+
+// attributeFieldErrors converts rejected attribute values into attributes.<key> FieldErrors
+// ok is false when err is not a validation failure
+func attributeFieldErrors(err error) (fields []FieldError, ok bool) {
+	var valueErrors attribute.ValueErrors
+	if !errors.As(err, &valueErrors) {
+		return nil, false
+	}
+
+	fields = make([]FieldError, len(valueErrors))
+	for i, ve := range valueErrors {
+		fields[i] = FieldError{Field: "attributes." + ve.Key, Rule: ve.Rule, Message: ve.Message}
+	}
+	return fields, true
+}
@@ -1,8 +1,23 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
 	"authway/src/server/internal/hydra"
+	"authway/src/server/internal/service/sso"
+	"authway/src/server/pkg/attribute"
 	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/consent"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/legacyauth"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/rbac"
 	"authway/src/server/pkg/user"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -11,18 +26,38 @@ import (
 )
 
 type AuthHandler struct {
-	userService   user.Service
-	clientService client.Service
-	hydraClient   *hydra.Client
-	logger        *zap.Logger
+	userService       user.Service
+	clientService     client.Service
+	connectionService connection.Service
+	ldapService       *sso.LDAPService
+	legacyAuthService legacyauth.Service
+	consentService    consent.Service
+	rbacService       rbac.Service
+	groupService      group.Service
+	orgService        organization.Service
+	attributeService  attribute.Service
+	claimMapper       *consent.ClaimMapper
+	rememberConsent   int // Longest remember_for in seconds for accepted consent
+	hydraClient       *hydra.Client
+	logger            *zap.Logger
 }
 
-func NewAuthHandler(userService user.Service, clientService client.Service, hydraClient *hydra.Client, logger *zap.Logger) *AuthHandler {
+func NewAuthHandler(userService user.Service, clientService client.Service, connectionService connection.Service, ldapService *sso.LDAPService, legacyAuthService legacyauth.Service, consentService consent.Service, rbacService rbac.Service, groupService group.Service, orgService organization.Service, attributeService attribute.Service, claimMapper *consent.ClaimMapper, consentRememberFor time.Duration, hydraClient *hydra.Client, logger *zap.Logger) *AuthHandler {
 	return &AuthHandler{
-		userService:   userService,
-		clientService: clientService,
-		hydraClient:   hydraClient,
-		logger:        logger,
+		userService:       userService,
+		clientService:     clientService,
+		connectionService: connectionService,
+		ldapService:       ldapService,
+		legacyAuthService: legacyAuthService,
+		consentService:    consentService,
+		rbacService:       rbacService,
+		groupService:      groupService,
+		orgService:        orgService,
+		attributeService:  attributeService,
+		claimMapper:       claimMapper,
+		rememberConsent:   int(consentRememberFor.Seconds()),
+		hydraClient:       hydraClient,
+		logger:            logger,
 	}
 }
 
@@ -34,21 +69,79 @@ func min(a, b int) int {
 	return b
 }
 
+// hasPKCEChallenge reports whether the authorization request satisfies the client's PKCE requirement
+func hasPKCEChallenge(requestedClient *client.Client, loginReq *hydra.LoginRequest) bool {
+	if !requestedClient.RequirePKCE {
+		return true
+	}
+
+	requestURL, err := url.Parse(loginReq.RequestURL)
+	if err != nil {
+		return false
+	}
+	query := requestURL.Query()
+	return query.Get("code_challenge") != "" && query.Get("code_challenge_method") == "S256"
+}
+
+// loginOrganization resolves the organization selected for a login: the explicit selection,
+// or else the organization parameter of the authorization request (ID or slug); nil when none was selected
+func (h *AuthHandler) loginOrganization(tenantID uuid.UUID, loginReq *hydra.LoginRequest, selected string) (*organization.Organization, error) {
+	if selected == "" {
+		if requestURL, err := url.Parse(loginReq.RequestURL); err == nil {
+			selected = requestURL.Query().Get("organization")
+		}
+	}
+	if selected == "" {
+		return nil, nil
+	}
+	return h.orgService.Find(tenantID, selected)
+}
+
+// organizationMember returns the user's membership in org
+// Users with a verified email at one of the organization's verified domains join it here
+func (h *AuthHandler) organizationMember(org *organization.Organization, user *user.User) (*organization.Member, error) {
+	if user.EmailVerified {
+		if _, err := h.orgService.JoinByEmailDomain(user.TenantID, user.ID, user.Email); err != nil {
+			h.logger.Warn("Failed to join organizations by email domain", zap.Error(err), zap.String("user_id", user.ID.String()))
+		}
+	}
+	return h.orgService.GetMember(org.ID, user.ID)
+}
+
+// loginContext is the Hydra login context, read back at consent time to build the token claims
+func loginContext(user *user.User, member *organization.Member) map[string]interface{} {
+	context := map[string]interface{}{
+		"email":     user.Email,
+		"name":      user.Name,
+		"tenant_id": user.TenantID.String(),
+	}
+	if member != nil {
+		context["org_id"] = member.OrganizationID.String()
+		context["org_role"] = member.Role
+	}
+	return context
+}
+
 // LoginPageRequest for POST request body
 type LoginPageRequest struct {
 	LoginChallenge string `json:"login_challenge"`
+	Organization   string `json:"organization"`
 }
 
 // Login flow handler - supports both GET and POST
 func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 	// Try to get challenge from query parameter first (GET)
 	challenge := c.Query("login_challenge")
+	selectedOrg := c.Query("organization")
 
 	// If not in query, try POST body
 	if challenge == "" && c.Method() == "POST" {
 		var req LoginPageRequest
 		if err := c.BodyParser(&req); err == nil {
 			challenge = req.LoginChallenge
+			if selectedOrg == "" {
+				selectedOrg = req.Organization
+			}
 		}
 	}
 
@@ -98,6 +191,30 @@ func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 		})
 	}
 
+	// PKCE Check: reject authorization requests without a S256 code challenge
+	if !hasPKCEChallenge(requestedClient, loginReq) {
+		h.logger.Warn("Authorization request without PKCE rejected",
+			zap.String("client_id", requestedClient.ClientID))
+		resp, rejectErr := h.hydraClient.RejectLoginRequest(challenge, "invalid_request", "This client requires PKCE with code_challenge_method=S256")
+		if rejectErr != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to reject login request",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"redirect_to": resp.RedirectTo,
+		})
+	}
+
+	// Organization selection (B2B): the login is scoped to one organization of the client's tenant
+	org, err := h.loginOrganization(requestedClient.TenantID, loginReq, selectedOrg)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown organization",
+			"hint":  "The organization parameter must be the ID or slug of an organization in this application's tenant.",
+		})
+	}
+
 	// SSO Check: If user is already authenticated, verify tenant match
 	if loginReq.Skip && loginReq.Subject != "" {
 		userID, err := uuid.Parse(loginReq.Subject)
@@ -149,8 +266,16 @@ func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 			})
 		}
 
+		// A selected organization must also include the user; otherwise show the login form
+		var member *organization.Member
+		orgMember := true
+		if org != nil {
+			member, err = h.organizationMember(org, authenticatedUser)
+			orgMember = err == nil
+		}
+
 		// Compare tenant_id for SSO eligibility
-		if authenticatedUser.TenantID == requestedClient.TenantID {
+		if authenticatedUser.TenantID == requestedClient.TenantID && orgMember {
 			// Same tenant → SSO automatic approval
 			h.logger.Info("SSO approved - same tenant",
 				zap.String("user_id", authenticatedUser.ID.String()),
@@ -159,11 +284,7 @@ func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 				Subject:     loginReq.Subject,
 				Remember:    true,
 				RememberFor: 3600,
-				Context: map[string]interface{}{
-					"email":     authenticatedUser.Email,
-					"name":      authenticatedUser.Name,
-					"tenant_id": authenticatedUser.TenantID.String(),
-				},
+				Context:     loginContext(authenticatedUser, member),
 			}
 
 			resp, err := h.hydraClient.AcceptLoginRequest(challenge, acceptBody)
@@ -186,7 +307,7 @@ func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 	}
 
 	// Render login form with challenge
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"challenge":       challenge,
 		"client_name":     loginReq.Client.ClientName,
 		"requested_scope": loginReq.RequestedScope,
@@ -194,14 +315,20 @@ func (h *AuthHandler) LoginPage(c *fiber.Ctx) error {
 		"client": fiber.Map{
 			"client_id": loginReq.Client.ClientID,
 		},
-	})
+	}
+	if org != nil {
+		// Branding overrides only; the login page falls back to the tenant's branding
+		response["organization"] = org.ToPublic(nil)
+	}
+	return c.JSON(response)
 }
 
 type LoginRequest struct {
-	Challenge string `json:"challenge"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	Remember  bool   `json:"remember"`
+	Challenge    string `json:"challenge"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	Remember     bool   `json:"remember"`
+	Organization string `json:"organization"` // Organization ID or slug (optional)
 }
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -213,63 +340,224 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Get login request from Hydra
-	_, err := h.hydraClient.GetLoginRequest(req.Challenge)
+	loginReq, err := h.hydraClient.GetLoginRequest(req.Challenge)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to get login request",
 		})
 	}
 
-	// Authenticate user
-	user, err := h.userService.GetByEmail(req.Email)
-	if err != nil {
-		// Reject login request
-		resp, _ := h.hydraClient.RejectLoginRequest(req.Challenge, "invalid_credentials", "Invalid email or password")
+	// Enforce PKCE again in case the login page was bypassed
+	requestedClient, clientErr := h.clientService.GetByClientID(loginReq.Client.ClientID)
+	if clientErr == nil && !hasPKCEChallenge(requestedClient, loginReq) {
+		resp, err := h.hydraClient.RejectLoginRequest(req.Challenge, "invalid_request", "This client requires PKCE with code_challenge_method=S256")
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to reject login request",
+			})
+		}
 		return c.JSON(fiber.Map{
-			"error":       "Invalid email or password",
+			"error":       "PKCE required",
 			"redirect_to": resp.RedirectTo,
 		})
 	}
 
-	// Verify password
-	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+	// Authenticate user: the tenant's LDAP directory verifies the password when it has one for this email
+	var directory *connection.Connection
+	if clientErr == nil {
+		directory, err = h.connectionService.Directory(requestedClient.TenantID, req.Email)
+		if err != nil && !errors.Is(err, connection.ErrNotFound) {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to authenticate",
+			})
+		}
+	}
+
+	var user *user.User
+	if directory != nil {
+		user, err = h.directoryLogin(c.UserContext(), directory, req.Email, req.Password)
+		if errors.Is(err, sso.ErrLDAPUnavailable) {
+			// Leave the login request open so the user can retry once the directory is back
+			return c.Status(503).JSON(fiber.Map{
+				"error": "The directory service is unavailable, please try again later",
+			})
+		}
+	} else {
+		user, err = h.userService.GetByEmail(req.Email)
+		// Verify password; imported legacy hashes are upgraded on success
+		if err == nil && !h.userService.VerifyPassword(user, req.Password) {
+			err = bcrypt.ErrMismatchedHashAndPassword
+		} else if err == nil && !user.Active {
+			// Deactivated, e.g. deprovisioned by the tenant's IdP
+			err = errors.New("account is disabled")
+		} else if err != nil && clientErr == nil {
+			// Unknown emails may still exist in the tenant's legacy system
+			user, err = h.legacyAuthService.Migrate(c.UserContext(), requestedClient.TenantID, req.Email, req.Password)
+			if errors.Is(err, legacyauth.ErrUnavailable) {
+				return c.Status(503).JSON(fiber.Map{
+					"error": "Sign-in is temporarily unavailable, please try again later",
+				})
+			}
+		}
+	}
+	if err != nil {
 		// Reject login request
-		resp, _ := h.hydraClient.RejectLoginRequest(req.Challenge, "invalid_credentials", "Invalid email or password")
+		resp, err := h.hydraClient.RejectLoginRequest(req.Challenge, "invalid_credentials", "Invalid email or password")
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to reject login request",
+			})
+		}
 		return c.JSON(fiber.Map{
 			"error":       "Invalid email or password",
 			"redirect_to": resp.RedirectTo,
 		})
 	}
 
-	// Accept login request
-	rememberFor := 0
-	if req.Remember {
-		rememberFor = 3600 // 1 hour
+	acceptBody := &hydra.AcceptLoginRequest{Remember: req.Remember}
+	if directory != nil {
+		acceptBody.Context = map[string]interface{}{
+			"provider":      "ldap",
+			"connection_id": directory.ID.String(),
+		}
 	}
+	return h.acceptLogin(c, req.Challenge, loginReq, requestedClient, user, req.Organization, acceptBody)
+}
 
-	acceptBody := &hydra.AcceptLoginRequest{
-		Subject:     user.ID.String(),
-		Remember:    req.Remember,
-		RememberFor: rememberFor,
-		Context: map[string]interface{}{
-			"email":     user.Email,
-			"name":      user.Name,
-			"tenant_id": user.TenantID.String(),
-		},
-	}
+// Failures of acceptLoginRequest
+var (
+	errUnknownOrganization   = errors.New("unknown organization")
+	errNotOrganizationMember = errors.New("not a member of the organization")
+	errRejectLogin           = errors.New("failed to reject login request")
+	errAcceptLogin           = errors.New("failed to accept login request")
+)
 
-	resp, err := h.hydraClient.AcceptLoginRequest(req.Challenge, acceptBody)
-	if err != nil {
+// acceptLogin accepts the login challenge for an authenticated user and answers with JSON for the login form
+func (h *AuthHandler) acceptLogin(c *fiber.Ctx, challenge string, loginReq *hydra.LoginRequest, requestedClient *client.Client, user *user.User, selectedOrg string, acceptBody *hydra.AcceptLoginRequest) error {
+	redirectTo, err := h.acceptLoginRequest(challenge, loginReq, requestedClient, user, selectedOrg, acceptBody)
+	switch {
+	case errors.Is(err, errUnknownOrganization):
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unknown organization",
+		})
+	case errors.Is(err, errNotOrganizationMember):
+		return c.JSON(fiber.Map{
+			"error":       "You are not a member of this organization",
+			"redirect_to": redirectTo,
+		})
+	case errors.Is(err, errRejectLogin):
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to reject login request",
+		})
+	case err != nil:
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to accept login request",
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"redirect_to": resp.RedirectTo,
+		"redirect_to": redirectTo,
 	})
 }
 
+// acceptLoginRequest accepts the login challenge for an authenticated user and returns where to continue
+// The user must belong to the organization selected for the login, otherwise the login is rejected and
+// errNotOrganizationMember is returned with the rejection's redirect URL.
+// The standard login context is merged into any provider-specific context already set on acceptBody
+func (h *AuthHandler) acceptLoginRequest(challenge string, loginReq *hydra.LoginRequest, requestedClient *client.Client, user *user.User, selectedOrg string, acceptBody *hydra.AcceptLoginRequest) (string, error) {
+	var member *organization.Member
+	if requestedClient != nil {
+		org, err := h.loginOrganization(requestedClient.TenantID, loginReq, selectedOrg)
+		if err != nil {
+			return "", errUnknownOrganization
+		}
+		if org != nil {
+			if member, err = h.organizationMember(org, user); err != nil {
+				resp, err := h.hydraClient.RejectLoginRequest(challenge, "access_denied", "You are not a member of this organization")
+				if err != nil {
+					return "", fmt.Errorf("%w: %v", errRejectLogin, err)
+				}
+				return resp.RedirectTo, errNotOrganizationMember
+			}
+		}
+	}
+
+	context := loginContext(user, member)
+	for key, value := range acceptBody.Context {
+		context[key] = value
+	}
+	acceptBody.Subject = user.ID.String()
+	acceptBody.Context = context
+	if acceptBody.Remember {
+		acceptBody.RememberFor = 3600 // 1 hour
+	}
+
+	resp, err := h.hydraClient.AcceptLoginRequest(challenge, acceptBody)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errAcceptLogin, err)
+	}
+	return resp.RedirectTo, nil
+}
+
+// directoryLogin verifies the password with the tenant's LDAP directory and provisions the user just in time
+// Roles mapped from directory groups are granted or revoked to match the user's current groups
+func (h *AuthHandler) directoryLogin(ctx context.Context, directory *connection.Connection, username, password string) (*user.User, error) {
+	entry, err := h.ldapService.Authenticate(ctx, directory, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, err := h.connectionService.Provision(directory, sso.LDAPProfile(directory, entry))
+	if err != nil {
+		h.logger.Warn("Failed to provision directory user",
+			zap.Error(err),
+			zap.String("connection_id", directory.ID.String()))
+		return nil, err
+	}
+	if !usr.Active {
+		return nil, errors.New("account is disabled")
+	}
+
+	if len(directory.GroupRoles) > 0 {
+		h.syncDirectoryRoles(directory, usr, entry.Groups(directory.GroupAttribute()))
+	}
+	if err := h.userService.UpdateLastLogin(usr.ID); err != nil {
+		h.logger.Warn("Failed to update last login", zap.Error(err))
+	}
+	return usr, nil
+}
+
+// syncDirectoryRoles assigns the roles mapped to the user's directory groups and removes the other mapped roles
+// Roles assigned in Authway that no group maps to are left alone
+func (h *AuthHandler) syncDirectoryRoles(directory *connection.Connection, usr *user.User, groups []string) {
+	granted, managed := directory.GroupRoles.Roles(groups)
+	grant := make(map[string]bool, len(granted))
+	for _, name := range granted {
+		grant[name] = true
+	}
+
+	for _, name := range managed {
+		roles, err := h.rbacService.FindTenantRoles(directory.TenantID, []string{name})
+		if err != nil {
+			h.logger.Warn("Directory group maps to an unknown role",
+				zap.String("role", name),
+				zap.String("connection_id", directory.ID.String()))
+			continue
+		}
+		if grant[name] {
+			err = h.rbacService.Assign(roles[0].ID, rbac.SubjectUser, usr.ID)
+		} else if err = h.rbacService.Unassign(roles[0].ID, rbac.SubjectUser, usr.ID); errors.Is(err, rbac.ErrAssignmentNotFound) {
+			err = nil
+		}
+		if err != nil {
+			h.logger.Warn("Failed to sync directory role",
+				zap.Error(err),
+				zap.String("role", name),
+				zap.String("user_id", usr.ID.String()))
+		}
+	}
+}
+
 // ConsentPageRequest for POST request body
 type ConsentPageRequest struct {
 	ConsentChallenge string `json:"consent_challenge" form:"consent_challenge"`
@@ -337,10 +625,59 @@ func (h *AuthHandler) ConsentPage(c *fiber.Ctx) error {
 		})
 	}
 
+	// Scopes this user already granted to the client in an earlier consent
+	grant, err := h.consentService.GetGrant(user.ID, consentReq.Client.ClientID)
+	if err != nil {
+		h.logger.Error("Failed to load consent grant",
+			zap.String("user_id", user.ID.String()),
+			zap.String("client_id", consentReq.Client.ClientID),
+			zap.Error(err))
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load previous consent",
+		})
+	}
+	var grantedScope []string
+	if grant != nil {
+		grantedScope = grant.Scopes
+	}
+
+	// Skip the consent screen when Hydra remembers the consent, the client is trusted,
+	// or every requested scope is in an unexpired remembered grant
+	trusted := false
+	if requestedClient, err := h.clientService.GetByClientID(consentReq.Client.ClientID); err == nil {
+		trusted = requestedClient.FirstParty || requestedClient.SkipConsent
+	}
+	allowedScope := h.allowedScopes(consentReq)
+	if consentReq.Skip || trusted || (grant != nil && grant.Covers(consentReq.RequestedScope)) {
+		h.logger.Info("Auto-accepting consent request",
+			zap.String("user_id", user.ID.String()),
+			zap.String("client_id", consentReq.Client.ClientID),
+			zap.Bool("hydra_skip", consentReq.Skip),
+			zap.Bool("trusted_client", trusted))
+
+		grantScope := consentReq.RequestedScope
+		if len(allowedScope) > 0 {
+			grantScope = intersectScopes(grantScope, allowedScope)
+		}
+		// Only trusted clients renew the remembered consent; anything else lapses after the period the user agreed to
+		redirectTo, err := h.acceptConsent(challenge, consentReq, user, grantScope, trusted, h.rememberConsent)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to accept consent request",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"redirect_to":  redirectTo,
+			"auto_consent": true,
+		})
+	}
+
 	return c.JSON(fiber.Map{
-		"challenge":       challenge,
-		"client_name":     consentReq.Client.ClientName,
-		"requested_scope": consentReq.RequestedScope,
+		"challenge":                challenge,
+		"client_name":              consentReq.Client.ClientName,
+		"requested_scope":          consentReq.RequestedScope,
+		"previously_granted_scope": intersectScopes(consentReq.RequestedScope, grantedScope),
+		"new_scope":                consent.MissingScopes(consentReq.RequestedScope, grantedScope),
 		"user": fiber.Map{
 			"email": user.Email,
 			"name":  user.Name,
@@ -385,54 +722,184 @@ func (h *AuthHandler) Consent(c *fiber.Ctx) error {
 		})
 	}
 
-	// Accept consent request
+	// Only scopes that were requested and are allowed for the client may be granted
+	if invalid := consent.ValidateGrant(req.GrantScope, consentReq.RequestedScope, h.allowedScopes(consentReq)); len(invalid) > 0 {
+		h.logger.Warn("Rejected consent with invalid scopes",
+			zap.Strings("invalid_scope", invalid),
+			zap.String("client_id", consentReq.Client.ClientID),
+			zap.String("user_id", user.ID.String()))
+		return c.Status(400).JSON(fiber.Map{
+			"error":         "Invalid grant_scope",
+			"invalid_scope": invalid,
+			"hint":          "grant_scope may only contain scopes from requested_scope that the client is allowed to request",
+		})
+	}
+
+	// The user answered for every requested scope, so previously granted scopes left unchecked are withdrawn
+	grant, err := h.consentService.GetGrant(user.ID, consentReq.Client.ClientID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load previous consent",
+		})
+	}
+	var withdrawn []string
+	if grant != nil {
+		withdrawn = consent.MissingScopes(intersectScopes(consentReq.RequestedScope, grant.Scopes), req.GrantScope)
+	}
+
+	// Remembered consent expires after at most the configured period
+	rememberFor := req.RememberFor
+	if rememberFor <= 0 || rememberFor > h.rememberConsent {
+		rememberFor = h.rememberConsent
+	}
+
+	redirectTo, err := h.acceptConsent(req.Challenge, consentReq, user, req.GrantScope, req.Remember, rememberFor)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to accept consent request",
+		})
+	}
+	if len(withdrawn) > 0 {
+		if err := h.consentService.WithdrawScopes(user.ID, consentReq.Client.ClientID, withdrawn); err != nil {
+			h.logger.Warn("Failed to withdraw consent scopes",
+				zap.Error(err),
+				zap.String("user_id", user.ID.String()),
+				zap.String("client_id", consentReq.Client.ClientID))
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"redirect_to": redirectTo,
+	})
+}
+
+// acceptConsent accepts the consent request in Hydra and, when the user asked to remember it,
+// records the granted scopes for rememberFor seconds so later authorizations only prompt for new scopes
+func (h *AuthHandler) acceptConsent(challenge string, consentReq *hydra.ConsentRequest, user *user.User, grantScope []string, remember bool, rememberFor int) (string, error) {
 	acceptBody := &hydra.AcceptConsentRequest{
-		GrantScope:               req.GrantScope,
+		GrantScope:               grantScope,
 		GrantAccessTokenAudience: consentReq.RequestedAudience,
-		Remember:                 req.Remember,
-		RememberFor:              req.RememberFor,
-		Session: &hydra.ConsentSession{
-			AccessToken: map[string]interface{}{
-				"email":     user.Email,
-				"name":      user.Name,
-				"tenant_id": user.TenantID.String(),
-			},
-			IDToken: map[string]interface{}{
-				"email":          user.Email,
-				"name":           user.Name,
-				"email_verified": user.EmailVerified,
-				"tenant_id":      user.TenantID.String(),
-			},
-		},
+		Remember:                 remember,
+		RememberFor:              rememberFor,
+		Session:                  h.consentSession(user, consentReq, grantScope),
 	}
 
 	// Log detailed consent request data
 	h.logger.Info("Sending consent accept to Hydra",
-		zap.String("challenge", req.Challenge),
-		zap.Strings("grant_scope", req.GrantScope),
+		zap.String("challenge", challenge),
+		zap.Strings("grant_scope", grantScope),
 		zap.Strings("grant_access_token_audience", consentReq.RequestedAudience),
-		zap.Bool("remember", req.Remember),
-		zap.Int("remember_for", req.RememberFor),
+		zap.Bool("remember", remember),
+		zap.Int("remember_for", rememberFor),
 		zap.String("user_id", user.ID.String()),
 		zap.String("tenant_id", user.TenantID.String()))
 
-	resp, err := h.hydraClient.AcceptConsentRequest(req.Challenge, acceptBody)
+	resp, err := h.hydraClient.AcceptConsentRequest(challenge, acceptBody)
 	if err != nil {
 		h.logger.Error("Failed to accept consent request",
 			zap.Error(err),
-			zap.String("challenge", req.Challenge))
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to accept consent request",
-		})
+			zap.String("challenge", challenge))
+		return "", err
+	}
+
+	if remember && rememberFor > 0 {
+		expiresAt := time.Now().Add(time.Duration(rememberFor) * time.Second)
+		if _, err := h.consentService.SaveGrant(user.TenantID, user.ID, consentReq.Client.ClientID, grantScope, expiresAt); err != nil {
+			// Hydra already accepted the consent, so only the remembered scopes are lost
+			h.logger.Warn("Failed to save consent grant",
+				zap.Error(err),
+				zap.String("user_id", user.ID.String()),
+				zap.String("client_id", consentReq.Client.ClientID))
+		}
 	}
 
 	h.logger.Info("Consent accepted, redirecting",
 		zap.String("redirect_to", resp.RedirectTo),
 		zap.String("user_id", user.ID.String()))
 
-	return c.JSON(fiber.Map{
-		"redirect_to": resp.RedirectTo,
-	})
+	return resp.RedirectTo, nil
+}
+
+// consentSession builds the token claims authorized by the granted scopes
+func (h *AuthHandler) consentSession(user *user.User, consentReq *hydra.ConsentRequest, grantScope []string) *hydra.ConsentSession {
+	available := consent.UserClaims(user)
+
+	// Organization selected at login
+	for _, claim := range []string{"org_id", "org_role"} {
+		if value, ok := consentReq.Context[claim].(string); ok && value != "" {
+			available[claim] = value
+		}
+	}
+
+	if consent.ContainsScope(grantScope, consent.ScopeRoles) || consent.ContainsScope(grantScope, consent.ScopeGroups) {
+		clientID := ""
+		if consentReq.Client != nil {
+			clientID = consentReq.Client.ClientID
+		}
+		h.addAccessClaims(available, user, clientID)
+	}
+
+	session := &hydra.ConsentSession{
+		AccessToken: h.claimMapper.Claims(grantScope, available),
+		IDToken:     h.claimMapper.Claims(grantScope, available),
+	}
+
+	// Custom attributes the tenant issues in tokens; their claim names never collide with the standard ones
+	attributeClaims, err := h.attributeService.Claims(user, grantScope)
+	if err != nil {
+		h.logger.Error("Failed to resolve attribute claims for token", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return session
+	}
+	for claim, value := range attributeClaims {
+		session.AccessToken[claim] = value
+		session.IDToken[claim] = value
+	}
+	return session
+}
+
+// addAccessClaims adds the groups, roles and permissions claims; the claim mapper releases only the granted ones
+// Lookup failures issue the token without these claims rather than failing the login
+func (h *AuthHandler) addAccessClaims(available map[string]interface{}, user *user.User, clientID string) {
+	groups, err := h.groupService.EffectiveGroups(user.ID)
+	if err != nil {
+		h.logger.Error("Failed to resolve groups for token", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return
+	}
+	available["groups"] = group.Names(groups)
+
+	// Roles assigned to a group apply to members of its subgroups too
+	roles, err := h.rbacService.EffectiveRoles(user.ID, group.IDs(groups), clientID)
+	if err != nil {
+		h.logger.Error("Failed to resolve roles for token", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return
+	}
+	available["roles"], available["permissions"] = rbac.Claims(roles)
+}
+
+// allowedScopes returns the scopes registered for the requesting client
+// Falls back to the scope Hydra reports when the client is not in the Authway database
+func (h *AuthHandler) allowedScopes(consentReq *hydra.ConsentRequest) []string {
+	if consentReq.Client == nil {
+		return nil
+	}
+	if requestedClient, err := h.clientService.GetByClientID(consentReq.Client.ClientID); err == nil {
+		return requestedClient.Scopes
+	}
+	return strings.Fields(consentReq.Client.Scope)
+}
+
+// intersectScopes returns the scopes in requested that are also in granted
+func intersectScopes(requested, granted []string) []string {
+	result := []string{}
+	for _, scope := range requested {
+		for _, g := range granted {
+			if scope == g {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	return result
 }
 
 // RejectConsentRequest for POST request body
@@ -471,65 +938,6 @@ func (h *AuthHandler) RejectConsent(c *fiber.Ctx) error {
 	})
 }
 
-// Registration endpoint
-type RegisterRequest struct {
-	TenantID string `json:"tenant_id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
-}
-
-func (h *AuthHandler) Register(c *fiber.Ctx) error {
-	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
-	}
-
-	// Validate input
-	if req.Email == "" || req.Password == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Email and password are required",
-		})
-	}
-
-	if req.TenantID == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Tenant ID is required",
-		})
-	}
-
-	// Parse tenant ID
-	tenantID, err := uuid.Parse(req.TenantID)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid tenant ID format",
-		})
-	}
-
-	// Create user request
-	createReq := &user.CreateUserRequest{
-		Email:    req.Email,
-		Password: req.Password,
-		Name:     req.Name,
-	}
-
-	createdUser, err := h.userService.Create(tenantID, createReq)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to create user",
-		})
-	}
-
-	return c.Status(201).JSON(fiber.Map{
-		"id":        createdUser.ID,
-		"tenant_id": createdUser.TenantID,
-		"email":     createdUser.Email,
-		"name":      createdUser.Name,
-	})
-}
-
 // User profile endpoint
 func (h *AuthHandler) Profile(c *fiber.Ctx) error {
 	userID := c.Params("id")
@@ -545,6 +953,14 @@ func (h *AuthHandler) Profile(c *fiber.Ctx) error {
 			"error": "Invalid user ID format",
 		})
 	}
+
+	// Users can only read their own profile (subject set by middleware.RequireAuth)
+	if localUserID(c) != userUUID.String() {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Access denied",
+		})
+	}
+
 	user, err := h.userService.GetByID(userUUID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
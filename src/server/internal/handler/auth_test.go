@@ -5,1012 +5,537 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/attribute"
 	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/consent"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/legacyauth"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/tenant"
 	"authway/src/server/pkg/user"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
-// Mock user service
-type MockUserService struct {
-	mock.Mock
-}
-
-func (m *MockUserService) Create(req *user.CreateUserRequest) (*user.User, error) {
-	args := m.Called(req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*user.User), args.Error(1)
-}
+// fakeHydra serves the parts of the Hydra admin API used by the login and consent flows
+type fakeHydra struct {
+	mu              sync.Mutex
+	login           map[string]*hydra.LoginRequest
+	consent         map[string]*hydra.ConsentRequest
+	acceptedLogin   map[string]hydra.AcceptLoginRequest
+	rejectedLogin   map[string]string
+	acceptedConsent map[string]hydra.AcceptConsentRequest
+	rejectedConsent map[string]string
+	failReject      bool
+}
+
+func newFakeHydra() *fakeHydra {
+	return &fakeHydra{
+		login:           map[string]*hydra.LoginRequest{},
+		consent:         map[string]*hydra.ConsentRequest{},
+		acceptedLogin:   map[string]hydra.AcceptLoginRequest{},
+		rejectedLogin:   map[string]string{},
+		acceptedConsent: map[string]hydra.AcceptConsentRequest{},
+		rejectedConsent: map[string]string{},
+	}
+}
+
+func (f *fakeHydra) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	challenge := r.URL.Query().Get("challenge")
+	var rejection struct {
+		Error string `json:"error"`
+	}
+	switch r.URL.Path {
+	case "/admin/oauth2/auth/requests/login":
+		if req, ok := f.login[challenge]; ok {
+			json.NewEncoder(w).Encode(req)
+			return
+		}
+	case "/admin/oauth2/auth/requests/login/accept":
+		var body hydra.AcceptLoginRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		f.acceptedLogin[challenge] = body
+		json.NewEncoder(w).Encode(hydra.LoginResponse{RedirectTo: "https://hydra.test/login/accepted"})
+		return
+	case "/admin/oauth2/auth/requests/login/reject":
+		if f.failReject {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&rejection)
+		f.rejectedLogin[challenge] = rejection.Error
+		json.NewEncoder(w).Encode(hydra.LoginResponse{RedirectTo: "https://hydra.test/login/rejected"})
+		return
+	case "/admin/oauth2/auth/requests/consent":
+		if req, ok := f.consent[challenge]; ok {
+			json.NewEncoder(w).Encode(req)
+			return
+		}
+	case "/admin/oauth2/auth/requests/consent/accept":
+		var body hydra.AcceptConsentRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		f.acceptedConsent[challenge] = body
+		json.NewEncoder(w).Encode(hydra.LoginResponse{RedirectTo: "https://hydra.test/consent/accepted"})
+		return
+	case "/admin/oauth2/auth/requests/consent/reject":
+		if _, ok := f.consent[challenge]; ok {
+			json.NewDecoder(r.Body).Decode(&rejection)
+			f.rejectedConsent[challenge] = rejection.Error
+			json.NewEncoder(w).Encode(hydra.LoginResponse{RedirectTo: "https://hydra.test/consent/rejected"})
+			return
+		}
+	case "/admin/oauth2/auth/sessions/login", "/admin/oauth2/auth/sessions/consent":
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+type authTestEnv struct {
+	app      *fiber.App
+	handler  *AuthHandler
+	db       *gorm.DB
+	hydra    *fakeHydra
+	users    user.Service
+	tenantID uuid.UUID
+}
+
+func setupAuthTest(t *testing.T) *authTestEnv {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&tenant.Tenant{}, &user.User{}, &client.Client{}, &consent.Grant{},
+		&connection.Connection{}, &connection.Domain{}, &connection.Identity{}, &connection.LoginState{}, &legacyauth.Connector{},
+		&rbac.Role{}, &rbac.Assignment{}, &group.Group{}, &group.Membership{},
+		&organization.Organization{}, &organization.Member{}, &organization.Domain{}, &attribute.Definition{},
+	))
+
+	tn := &tenant.Tenant{ID: uuid.New(), Name: "Acme", Slug: "acme", Active: true}
+	require.NoError(t, db.Create(tn).Error)
+
+	fake := newFakeHydra()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	logger := zap.NewNop()
+	users := user.NewService(db, logger)
+	h := NewAuthHandler(
+		users,
+		client.NewService(db, logger, hydra.NewClient(server.URL), client.Policy{}),
+		connection.NewService(db, logger),
+		nil,
+		legacyauth.NewService(db, logger),
+		consent.NewService(db, logger),
+		rbac.NewService(db, logger),
+		group.NewService(db, logger),
+		organization.NewService(db, logger),
+		attribute.NewService(db, logger),
+		consent.NewClaimMapper(nil),
+		24*time.Hour,
+		hydra.NewClient(server.URL),
+		logger,
+	)
 
-func (m *MockUserService) GetByID(id uuid.UUID) (*user.User, error) {
-	args := m.Called(id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*user.User), args.Error(1)
-}
-
-func (m *MockUserService) GetByEmail(email string) (*user.User, error) {
-	args := m.Called(email)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*user.User), args.Error(1)
-}
-
-func (m *MockUserService) Update(id uuid.UUID, req *user.UpdateUserRequest) (*user.User, error) {
-	args := m.Called(id, req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*user.User), args.Error(1)
-}
-
-func (m *MockUserService) Delete(id uuid.UUID) error {
-	args := m.Called(id)
-	return args.Error(0)
-}
-
-func (m *MockUserService) List(limit, offset int) ([]*user.User, int64, error) {
-	args := m.Called(limit, offset)
-	if args.Get(0) == nil {
-		return nil, args.Get(1).(int64), args.Error(2)
-	}
-	return args.Get(0).([]*user.User), args.Get(1).(int64), args.Error(2)
-}
-
-func (m *MockUserService) VerifyPassword(user *user.User, password string) bool {
-	args := m.Called(user, password)
-	return args.Bool(0)
-}
-
-func (m *MockUserService) ChangePassword(id uuid.UUID, req *user.ChangePasswordRequest) error {
-	args := m.Called(id, req)
-	return args.Error(0)
-}
-
-func (m *MockUserService) UpdateLastLogin(id uuid.UUID) error {
-	args := m.Called(id)
-	return args.Error(0)
-}
-
-// Mock client service
-type MockClientService struct {
-	mock.Mock
-}
+	app := fiber.New()
+	app.Get("/login", h.LoginPage)
+	app.Post("/login/submit", h.Login)
+	app.Get("/consent", h.ConsentPage)
+	app.Post("/consent/submit", h.Consent)
+	app.Post("/consent/reject", h.RejectConsent)
+	app.Get("/profile/:id", func(c *fiber.Ctx) error {
+		c.Locals("userID", c.Get("X-User-ID"))
+		return c.Next()
+	}, h.Profile)
 
-func (m *MockClientService) Create(tenantID uuid.UUID, req *client.CreateClientRequest) (*client.Client, error) {
-	args := m.Called(tenantID, req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*client.Client), args.Error(1)
+	return &authTestEnv{app: app, handler: h, db: db, hydra: fake, users: users, tenantID: tn.ID}
 }
 
-func (m *MockClientService) GetByID(id uuid.UUID) (*client.Client, error) {
-	args := m.Called(id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
+// createClient registers an OAuth client of the test tenant directly in the database
+func (e *authTestEnv) createClient(t *testing.T, c *client.Client) *client.Client {
+	c.ID = uuid.New()
+	c.TenantID = e.tenantID
+	c.ClientSecret = "secret"
+	if c.Name == "" {
+		c.Name = c.ClientID
 	}
-	return args.Get(0).(*client.Client), args.Error(1)
+	require.NoError(t, e.db.Create(c).Error)
+	return c
 }
 
-func (m *MockClientService) GetByClientID(clientID string) (*client.Client, error) {
-	args := m.Called(clientID)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*client.Client), args.Error(1)
+func (e *authTestEnv) createUser(t *testing.T, email string) *user.User {
+	u, err := e.users.Create(e.tenantID, &user.CreateUserRequest{Email: email, Password: "password123", Name: "John Doe"})
+	require.NoError(t, err)
+	return u
 }
 
-func (m *MockClientService) Update(id uuid.UUID, req *client.UpdateClientRequest) (*client.Client, error) {
-	args := m.Called(id, req)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
+func (e *authTestEnv) do(t *testing.T, method, target string, body interface{}, headers ...string) (int, map[string]interface{}) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		require.NoError(t, err)
 	}
-	return args.Get(0).(*client.Client), args.Error(1)
-}
-
-func (m *MockClientService) Delete(id uuid.UUID) error {
-	args := m.Called(id)
-	return args.Error(0)
-}
 
-func (m *MockClientService) List(tenantID uuid.UUID, limit, offset int) ([]*client.Client, int64, error) {
-	args := m.Called(tenantID, limit, offset)
-	if args.Get(0) == nil {
-		return nil, args.Get(1).(int64), args.Error(2)
+	req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
 	}
-	return args.Get(0).([]*client.Client), args.Get(1).(int64), args.Error(2)
-}
+	resp, err := e.app.Test(req)
+	require.NoError(t, err)
 
-// Mock hydra client
-type MockHydraClient struct {
-	mock.Mock
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
 }
 
-func (m *MockHydraClient) GetLoginRequest(challenge string) (*hydra.LoginRequest, error) {
-	args := m.Called(challenge)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*hydra.LoginRequest), args.Error(1)
-}
-
-func (m *MockHydraClient) AcceptLoginRequest(challenge string, body *hydra.AcceptLoginRequest) (*hydra.LoginResponse, error) {
-	args := m.Called(challenge, body)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*hydra.LoginResponse), args.Error(1)
-}
+func TestNewAuthHandler(t *testing.T) {
+	env := setupAuthTest(t)
 
-func (m *MockHydraClient) RejectLoginRequest(challenge string, errorCode, errorDescription string) (*hydra.LoginResponse, error) {
-	args := m.Called(challenge, errorCode, errorDescription)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*hydra.LoginResponse), args.Error(1)
+	assert.NotNil(t, env.handler)
+	assert.NotNil(t, env.handler.hydraClient)
+	assert.NotNil(t, env.handler.userService)
 }
 
-func (m *MockHydraClient) GetConsentRequest(challenge string) (*hydra.ConsentRequest, error) {
-	args := m.Called(challenge)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*hydra.ConsentRequest), args.Error(1)
+func TestAuthHandler_LoginPage(t *testing.T) {
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app", Name: "Web App"})
+	env.createClient(t, &client.Client{ClientID: "spa", RequirePKCE: true})
+	u := env.createUser(t, "john@example.com")
+
+	webApp := &hydra.OAuth2Client{ClientID: "web-app", ClientName: "Web App"}
+	env.hydra.login["fresh"] = &hydra.LoginRequest{Challenge: "fresh", Client: webApp, RequestedScope: []string{"openid"}}
+	env.hydra.login["sso"] = &hydra.LoginRequest{Challenge: "sso", Client: webApp, Skip: true, Subject: u.ID.String()}
+	env.hydra.login["stale"] = &hydra.LoginRequest{Challenge: "stale", Client: webApp, Skip: true, Subject: uuid.New().String()}
+	env.hydra.login["unregistered"] = &hydra.LoginRequest{Challenge: "unregistered", Client: &hydra.OAuth2Client{ClientID: "unknown"}}
+	env.hydra.login["no-pkce"] = &hydra.LoginRequest{Challenge: "no-pkce", Client: &hydra.OAuth2Client{ClientID: "spa"}, RequestURL: "https://hydra.test/oauth2/auth?client_id=spa"}
+
+	t.Run("missing challenge", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login", nil)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "login_challenge parameter is required", body["error"])
+	})
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=missing", nil)
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to get login request from Hydra", body["error"])
+	})
+
+	t.Run("client not registered in Authway", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=unregistered", nil)
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "OAuth client not registered in Authway", body["error"])
+	})
+
+	t.Run("renders the login form", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=fresh", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "fresh", body["challenge"])
+		assert.Equal(t, "Web App", body["client_name"])
+		assert.Equal(t, env.tenantID.String(), body["tenant_id"])
+	})
+
+	t.Run("accepts a remembered session of the same tenant", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=sso", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, true, body["sso"])
+		assert.Equal(t, u.ID.String(), env.hydra.acceptedLogin["sso"].Subject)
+	})
+
+	t.Run("clears a session of a user that no longer exists", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=stale", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, true, body["session_cleared"])
+		assert.Equal(t, "login_required", env.hydra.rejectedLogin["stale"])
+	})
+
+	t.Run("rejects requests without PKCE for clients that require it", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/login?login_challenge=no-pkce", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "https://hydra.test/login/rejected", body["redirect_to"])
+		assert.Equal(t, "invalid_request", env.hydra.rejectedLogin["no-pkce"])
+	})
 }
 
-func (m *MockHydraClient) AcceptConsentRequest(challenge string, body *hydra.AcceptConsentRequest) (*hydra.LoginResponse, error) {
-	args := m.Called(challenge, body)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*hydra.LoginResponse), args.Error(1)
-}
+func TestAuthHandler_Login(t *testing.T) {
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app"})
+	u := env.createUser(t, "john@example.com")
+	disabled := env.createUser(t, "disabled@example.com")
+	require.NoError(t, env.db.Model(disabled).Update("active", false).Error)
 
-func (m *MockHydraClient) RejectConsentRequest(challenge string, errorCode, errorDescription string) (*hydra.LoginResponse, error) {
-	args := m.Called(challenge, errorCode, errorDescription)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
+	for _, challenge := range []string{"valid", "wrong-password", "unknown-email", "disabled"} {
+		env.hydra.login[challenge] = &hydra.LoginRequest{Challenge: challenge, Client: &hydra.OAuth2Client{ClientID: "web-app"}}
 	}
-	return args.Get(0).(*hydra.LoginResponse), args.Error(1)
-}
-
-func TestNewAuthHandler(t *testing.T) {
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
 
-	assert.NotNil(t, handler)
-	assert.Equal(t, mockUserService, handler.userService)
-	assert.Equal(t, mockHydraClient, handler.hydraClient)
-}
-
-func TestAuthHandler_LoginPage(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
+	t.Run("valid credentials", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: "valid", Email: "john@example.com", Password: "password123", Remember: true})
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "https://hydra.test/login/accepted", body["redirect_to"])
 
-	app.Get("/login", handler.LoginPage)
+		accepted := env.hydra.acceptedLogin["valid"]
+		assert.Equal(t, u.ID.String(), accepted.Subject)
+		assert.True(t, accepted.Remember)
+		assert.Equal(t, 3600, accepted.RememberFor)
+		assert.Equal(t, env.tenantID.String(), accepted.Context["tenant_id"])
+	})
 
 	tests := []struct {
-		name           string
-		challenge      string
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
+		name      string
+		challenge string
+		email     string
+		password  string
 	}{
-		{
-			name:           "missing challenge parameter",
-			challenge:      "",
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "login_challenge parameter is required",
-		},
-		{
-			name:      "hydra client error",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to get login request",
-		},
-		{
-			name:      "successful login page with skip=false",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{
-					Challenge:      "test-challenge",
-					Skip:           false,
-					RequestedScope: []string{"openid", "email"},
-					Client: &hydra.OAuth2Client{
-						ClientName: "Test App",
-					},
-				}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-		{
-			name:      "successful login page with skip=true",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{
-					Challenge:      "test-challenge",
-					Skip:           true,
-					Subject:        "user-123",
-					RequestedScope: []string{"openid", "email"},
-					Client: &hydra.OAuth2Client{
-						ClientName: "Test App",
-					},
-				}
-				loginResponse := &hydra.LoginResponse{
-					RedirectTo: "http://example.com/callback",
-				}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockHydraClient.On("AcceptLoginRequest", "test-challenge", mock.AnythingOfType("*hydra.AcceptLoginRequest")).
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 302, // Redirect
-		},
+		{"wrong password", "wrong-password", "john@example.com", "wrong"},
+		{"unknown email", "unknown-email", "nobody@example.com", "password123"},
+		{"disabled account", "disabled", "disabled@example.com", "password123"},
 	}
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			url := "/login"
-			if tt.challenge != "" {
-				url += "?login_challenge=" + tt.challenge
-			}
-
-			req := httptest.NewRequest("GET", url, nil)
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
+			status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: tt.challenge, Email: tt.email, Password: tt.password})
+			assert.Equal(t, 200, status)
+			assert.Equal(t, "Invalid email or password", body["error"])
+			assert.Equal(t, "https://hydra.test/login/rejected", body["redirect_to"])
+			assert.Equal(t, "invalid_credentials", env.hydra.rejectedLogin[tt.challenge])
 		})
 	}
-}
-
-func TestAuthHandler_Login(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Post("/login", handler.Login)
-
-	// Create test user with password
-	userID := uuid.New()
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
-	hashedPasswordBytes := hashedPassword
-	testUser := &user.User{
-		ID:           userID,
-		Email:        "test@example.com",
-		PasswordHash: &hashedPasswordBytes,
-		FirstName:    stringPtr("John"),
-		LastName:     stringPtr("Doe"),
-	}
-
-	tests := []struct {
-		name           string
-		requestBody    LoginRequest
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "invalid request body",
-			requestBody:    LoginRequest{},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-		},
-		{
-			name: "hydra client error on get login request",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "test@example.com",
-				Password:  "password123",
-			},
-			setupMocks: func() {
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to get login request",
-		},
-		{
-			name: "user not found",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "nonexistent@example.com",
-				Password:  "password123",
-			},
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{Challenge: "test-challenge"}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockUserService.On("GetByEmail", "nonexistent@example.com").
-					Return(nil, assert.AnError).Once()
-				loginResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/error"}
-				mockHydraClient.On("RejectLoginRequest", "test-challenge", "invalid_credentials", "Invalid email or password").
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 200,
-			expectedError:  "Invalid email or password",
-		},
-		{
-			name: "invalid password",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "test@example.com",
-				Password:  "wrongpassword",
-			},
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{Challenge: "test-challenge"}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockUserService.On("GetByEmail", "test@example.com").
-					Return(testUser, nil).Once()
-				loginResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/error"}
-				mockHydraClient.On("RejectLoginRequest", "test-challenge", "invalid_credentials", "Invalid email or password").
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 200,
-			expectedError:  "Invalid email or password",
-		},
-		{
-			name: "user with no password hash",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "social@example.com",
-				Password:  "password123",
-			},
-			setupMocks: func() {
-				socialUser := &user.User{
-					ID:           uuid.New(),
-					Email:        "social@example.com",
-					PasswordHash: nil,
-					FirstName:    stringPtr("Social"),
-					LastName:     stringPtr("User"),
-				}
-				loginReq := &hydra.LoginRequest{Challenge: "test-challenge"}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockUserService.On("GetByEmail", "social@example.com").
-					Return(socialUser, nil).Once()
-				loginResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/error"}
-				mockHydraClient.On("RejectLoginRequest", "test-challenge", "invalid_credentials", "Invalid email or password").
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 200,
-			expectedError:  "Invalid email or password",
-		},
-		{
-			name: "successful login without remember",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "test@example.com",
-				Password:  "password123",
-				Remember:  false,
-			},
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{Challenge: "test-challenge"}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockUserService.On("GetByEmail", "test@example.com").
-					Return(testUser, nil).Once()
-				loginResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/callback"}
-				mockHydraClient.On("AcceptLoginRequest", "test-challenge", mock.AnythingOfType("*hydra.AcceptLoginRequest")).
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-		{
-			name: "successful login with remember",
-			requestBody: LoginRequest{
-				Challenge: "test-challenge",
-				Email:     "test@example.com",
-				Password:  "password123",
-				Remember:  true,
-			},
-			setupMocks: func() {
-				loginReq := &hydra.LoginRequest{Challenge: "test-challenge"}
-				mockHydraClient.On("GetLoginRequest", "test-challenge").
-					Return(loginReq, nil).Once()
-				mockUserService.On("GetByEmail", "test@example.com").
-					Return(testUser, nil).Once()
-				loginResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/callback"}
-				mockHydraClient.On("AcceptLoginRequest", "test-challenge", mock.AnythingOfType("*hydra.AcceptLoginRequest")).
-					Return(loginResponse, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
 
-			tt.setupMocks()
+	t.Run("unknown challenge", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: "missing", Email: "john@example.com", Password: "password123"})
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to get login request", body["error"])
+	})
 
-			body, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
+	t.Run("hydra fails to reject", func(t *testing.T) {
+		env.hydra.failReject = true
+		defer func() { env.hydra.failReject = false }()
 
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
+		status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: "wrong-password", Email: "john@example.com", Password: "wrong"})
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to reject login request", body["error"])
+	})
 }
 
 func TestAuthHandler_ConsentPage(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Get("/consent", handler.ConsentPage)
-
-	userID := uuid.New()
-	testUser := &user.User{
-		ID:        userID,
-		Email:     "test@example.com",
-		FirstName: stringPtr("John"),
-		LastName:  stringPtr("Doe"),
-	}
-
-	tests := []struct {
-		name           string
-		challenge      string
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "missing challenge parameter",
-			challenge:      "",
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "consent_challenge parameter is required",
-		},
-		{
-			name:      "hydra client error",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to get consent request",
-		},
-		{
-			name:      "invalid user ID in consent request",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				consentReq := &hydra.ConsentRequest{
-					Challenge: "test-challenge",
-					Subject:   "invalid-uuid",
-				}
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(consentReq, nil).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Invalid user ID",
-		},
-		{
-			name:      "user not found",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				consentReq := &hydra.ConsentRequest{
-					Challenge: "test-challenge",
-					Subject:   userID.String(),
-				}
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(consentReq, nil).Once()
-				mockUserService.On("GetByID", userID).
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to get user information",
-		},
-		{
-			name:      "successful consent page",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				consentReq := &hydra.ConsentRequest{
-					Challenge:      "test-challenge",
-					Subject:        userID.String(),
-					RequestedScope: []string{"openid", "email"},
-					Client: &hydra.OAuth2Client{
-						ClientName: "Test App",
-					},
-				}
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(consentReq, nil).Once()
-				mockUserService.On("GetByID", userID).
-					Return(testUser, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			url := "/consent"
-			if tt.challenge != "" {
-				url += "?consent_challenge=" + tt.challenge
-			}
-
-			req := httptest.NewRequest("GET", url, nil)
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app", Scopes: pq.StringArray{"openid", "profile", "email"}})
+	env.createClient(t, &client.Client{ClientID: "first-party", FirstParty: true, Scopes: pq.StringArray{"openid", "email"}})
+	u := env.createUser(t, "john@example.com")
+
+	env.hydra.consent["prompt"] = &hydra.ConsentRequest{Challenge: "prompt", Subject: u.ID.String(), Client: &hydra.OAuth2Client{ClientID: "web-app", ClientName: "Web App"}, RequestedScope: []string{"openid", "profile", "email"}}
+	env.hydra.consent["trusted"] = &hydra.ConsentRequest{Challenge: "trusted", Subject: u.ID.String(), Client: &hydra.OAuth2Client{ClientID: "first-party"}, RequestedScope: []string{"openid", "email", "phone"}}
+	env.hydra.consent["remembered"] = &hydra.ConsentRequest{Challenge: "remembered", Subject: u.ID.String(), Client: &hydra.OAuth2Client{ClientID: "web-app"}, RequestedScope: []string{"openid"}}
+	env.hydra.consent["bad-subject"] = &hydra.ConsentRequest{Challenge: "bad-subject", Subject: "not-a-uuid", Client: &hydra.OAuth2Client{ClientID: "web-app"}}
+
+	t.Run("missing challenge", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/consent", nil)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "consent_challenge parameter is required", body["error"])
+	})
+
+	t.Run("invalid subject", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/consent?consent_challenge=bad-subject", nil)
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Invalid user ID format in consent request", body["error"])
+	})
+
+	t.Run("prompts for scopes not granted before", func(t *testing.T) {
+		_, err := consent.NewService(env.db, zap.NewNop()).SaveGrant(env.tenantID, u.ID, "web-app", []string{"openid"}, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		status, body := env.do(t, "GET", "/consent?consent_challenge=prompt", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "prompt", body["challenge"])
+		assert.Equal(t, []interface{}{"openid"}, body["previously_granted_scope"])
+		assert.Equal(t, []interface{}{"profile", "email"}, body["new_scope"])
+	})
+
+	t.Run("auto-accepts remembered scopes without extending the grant", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		_, err := consent.NewService(env.db, zap.NewNop()).SaveGrant(env.tenantID, u.ID, "web-app", []string{"openid"}, expiresAt)
+		require.NoError(t, err)
+
+		status, body := env.do(t, "GET", "/consent?consent_challenge=remembered", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, true, body["auto_consent"])
+		assert.False(t, env.hydra.acceptedConsent["remembered"].Remember)
+
+		grant, err := consent.NewService(env.db, zap.NewNop()).GetGrant(u.ID, "web-app")
+		require.NoError(t, err)
+		assert.True(t, grant.ExpiresAt.Equal(expiresAt))
+	})
+
+	t.Run("prompts again once the remembered grant expires", func(t *testing.T) {
+		_, err := consent.NewService(env.db, zap.NewNop()).SaveGrant(env.tenantID, u.ID, "web-app", []string{"openid"}, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+
+		status, body := env.do(t, "GET", "/consent?consent_challenge=prompt", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, []interface{}{}, body["previously_granted_scope"])
+		assert.Nil(t, body["auto_consent"])
+	})
+
+	t.Run("auto-accepts trusted clients with the allowed scopes", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/consent?consent_challenge=trusted", nil)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, true, body["auto_consent"])
+
+		accepted := env.hydra.acceptedConsent["trusted"]
+		assert.Equal(t, []string{"openid", "email"}, accepted.GrantScope)
+		assert.True(t, accepted.Remember)
+		assert.Equal(t, 86400, accepted.RememberFor)
+	})
 }
 
 func TestAuthHandler_Consent(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Post("/consent", handler.Consent)
-
-	userID := uuid.New()
-	testUser := &user.User{
-		ID:            userID,
-		Email:         "test@example.com",
-		FirstName:     stringPtr("John"),
-		LastName:      stringPtr("Doe"),
-		EmailVerified: true,
-	}
-
-	tests := []struct {
-		name           string
-		requestBody    ConsentRequest
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "invalid request body",
-			requestBody:    ConsentRequest{},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-		},
-		{
-			name: "hydra client error on get consent request",
-			requestBody: ConsentRequest{
-				Challenge:  "test-challenge",
-				GrantScope: []string{"openid", "email"},
-			},
-			setupMocks: func() {
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to get consent request",
-		},
-		{
-			name: "successful consent",
-			requestBody: ConsentRequest{
-				Challenge:   "test-challenge",
-				GrantScope:  []string{"openid", "email"},
-				Remember:    true,
-				RememberFor: 3600,
-			},
-			setupMocks: func() {
-				consentReq := &hydra.ConsentRequest{
-					Challenge:         "test-challenge",
-					Subject:           userID.String(),
-					RequestedAudience: []string{"test-audience"},
-				}
-				mockHydraClient.On("GetConsentRequest", "test-challenge").
-					Return(consentReq, nil).Once()
-				mockUserService.On("GetByID", userID).
-					Return(testUser, nil).Once()
-				consentResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/callback"}
-				mockHydraClient.On("AcceptConsentRequest", "test-challenge", mock.AnythingOfType("*hydra.AcceptConsentRequest")).
-					Return(consentResponse, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			body, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest("POST", "/consent", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app", Scopes: pq.StringArray{"openid", "profile", "email"}})
+	u := env.createUser(t, "john@example.com")
+
+	for _, challenge := range []string{"grant", "narrow", "invalid"} {
+		env.hydra.consent[challenge] = &hydra.ConsentRequest{Challenge: challenge, Subject: u.ID.String(), Client: &hydra.OAuth2Client{ClientID: "web-app"}, RequestedScope: []string{"openid", "email"}}
+	}
+
+	t.Run("grants the submitted scopes", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/submit", ConsentRequest{Challenge: "grant", GrantScope: []string{"openid", "email"}})
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "https://hydra.test/consent/accepted", body["redirect_to"])
+
+		accepted := env.hydra.acceptedConsent["grant"]
+		assert.Equal(t, []string{"openid", "email"}, accepted.GrantScope)
+		assert.Equal(t, "john@example.com", accepted.Session.IDToken["email"])
+
+		// Consent that is not remembered is asked for again next time
+		grant, err := consent.NewService(env.db, zap.NewNop()).GetGrant(u.ID, "web-app")
+		require.NoError(t, err)
+		assert.Nil(t, grant)
+	})
+
+	t.Run("grants exactly the submitted scopes", func(t *testing.T) {
+		status, _ := env.do(t, "POST", "/consent/submit", ConsentRequest{Challenge: "narrow", GrantScope: []string{"openid"}, Remember: true, RememberFor: 365 * 86400})
+		assert.Equal(t, 200, status)
+
+		accepted := env.hydra.acceptedConsent["narrow"]
+		assert.Equal(t, []string{"openid"}, accepted.GrantScope)
+		assert.Equal(t, 86400, accepted.RememberFor, "remember_for is capped to the configured period")
+
+		grant, err := consent.NewService(env.db, zap.NewNop()).GetGrant(u.ID, "web-app")
+		require.NoError(t, err)
+		require.NotNil(t, grant)
+		assert.Equal(t, []string{"openid"}, []string(grant.Scopes))
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), grant.ExpiresAt, time.Minute)
+	})
+
+	t.Run("rejects scopes that were not requested", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/submit", ConsentRequest{Challenge: "invalid", GrantScope: []string{"openid", "profile"}})
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid grant_scope", body["error"])
+		assert.Equal(t, []interface{}{"profile"}, body["invalid_scope"])
+	})
+
+	t.Run("unknown challenge", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/submit", ConsentRequest{Challenge: "missing"})
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to get consent request", body["error"])
+	})
 }
 
 func TestAuthHandler_RejectConsent(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Post("/consent/reject", handler.RejectConsent)
-
-	tests := []struct {
-		name           string
-		challenge      string
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "missing challenge parameter",
-			challenge:      "",
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "consent_challenge parameter is required",
-		},
-		{
-			name:      "hydra client error",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				mockHydraClient.On("RejectConsentRequest", "test-challenge", "access_denied", "User denied consent").
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to reject consent request",
-		},
-		{
-			name:      "successful consent rejection",
-			challenge: "test-challenge",
-			setupMocks: func() {
-				rejectResponse := &hydra.LoginResponse{RedirectTo: "http://example.com/error"}
-				mockHydraClient.On("RejectConsentRequest", "test-challenge", "access_denied", "User denied consent").
-					Return(rejectResponse, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			url := "/consent/reject"
-			if tt.challenge != "" {
-				url += "?consent_challenge=" + tt.challenge
-			}
+	env := setupAuthTest(t)
+	env.hydra.consent["deny"] = &hydra.ConsentRequest{Challenge: "deny"}
 
-			req := httptest.NewRequest("POST", url, nil)
-			resp, err := app.Test(req)
-			require.NoError(t, err)
+	t.Run("missing challenge", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/reject", nil)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "consent_challenge parameter is required", body["error"])
+	})
 
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+	t.Run("rejects the consent request", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/reject", RejectConsentRequest{ConsentChallenge: "deny"})
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "https://hydra.test/consent/rejected", body["redirect_to"])
+		assert.Equal(t, "access_denied", env.hydra.rejectedConsent["deny"])
+	})
 
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
-}
-
-func TestAuthHandler_Register(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Post("/register", handler.Register)
-
-	tests := []struct {
-		name           string
-		requestBody    RegisterRequest
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "invalid request body",
-			requestBody:    RegisterRequest{},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-		},
-		{
-			name: "missing email and password",
-			requestBody: RegisterRequest{
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "Email and password are required",
-		},
-		{
-			name: "missing email",
-			requestBody: RegisterRequest{
-				Password:  "password123",
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "Email and password are required",
-		},
-		{
-			name: "missing password",
-			requestBody: RegisterRequest{
-				Email:     "test@example.com",
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "Email and password are required",
-		},
-		{
-			name: "user service error",
-			requestBody: RegisterRequest{
-				Email:     "test@example.com",
-				Password:  "password123",
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-			setupMocks: func() {
-				createReq := &user.CreateUserRequest{
-					Email:     "test@example.com",
-					Password:  "password123",
-					FirstName: "John",
-					LastName:  "Doe",
-				}
-				mockUserService.On("Create", createReq).
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 500,
-			expectedError:  "Failed to create user",
-		},
-		{
-			name: "successful registration",
-			requestBody: RegisterRequest{
-				Email:     "test@example.com",
-				Password:  "password123",
-				FirstName: "John",
-				LastName:  "Doe",
-			},
-			setupMocks: func() {
-				createReq := &user.CreateUserRequest{
-					Email:     "test@example.com",
-					Password:  "password123",
-					FirstName: "John",
-					LastName:  "Doe",
-				}
-				createdUser := &user.User{
-					ID:        uuid.New(),
-					Email:     "test@example.com",
-					FirstName: stringPtr("John"),
-					LastName:  stringPtr("Doe"),
-				}
-				mockUserService.On("Create", createReq).
-					Return(createdUser, nil).Once()
-			},
-			expectedStatus: 201,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			body, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
-			req.Header.Set("Content-Type", "application/json")
-
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
+	t.Run("hydra error", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/consent/reject?consent_challenge=missing", nil)
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to reject consent request", body["error"])
+	})
 }
 
 func TestAuthHandler_Profile(t *testing.T) {
-	app := fiber.New()
-	mockUserService := &MockUserService{}
-	mockHydraClient := &MockHydraClient{}
-	handler := NewAuthHandler(mockUserService, &MockClientService{}, mockHydraClient, zap.NewNop())
-
-	app.Get("/profile/:id", handler.Profile)
-
-	userID := uuid.New()
-	testUser := &user.User{
-		ID:            userID,
-		Email:         "test@example.com",
-		FirstName:     stringPtr("John"),
-		LastName:      stringPtr("Doe"),
-		EmailVerified: true,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-	}
-
-	tests := []struct {
-		name           string
-		userIDParam    string
-		setupMocks     func()
-		expectedStatus int
-		expectedError  string
-	}{
-		{
-			name:           "missing user ID parameter",
-			userIDParam:    "",
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "User ID is required",
-		},
-		{
-			name:           "invalid user ID format",
-			userIDParam:    "invalid-uuid",
-			setupMocks:     func() {},
-			expectedStatus: 400,
-			expectedError:  "Invalid user ID format",
-		},
-		{
-			name:        "user not found",
-			userIDParam: userID.String(),
-			setupMocks: func() {
-				mockUserService.On("GetByID", userID).
-					Return(nil, assert.AnError).Once()
-			},
-			expectedStatus: 404,
-			expectedError:  "User not found",
-		},
-		{
-			name:        "successful profile retrieval",
-			userIDParam: userID.String(),
-			setupMocks: func() {
-				mockUserService.On("GetByID", userID).
-					Return(testUser, nil).Once()
-			},
-			expectedStatus: 200,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			// Reset mocks
-			mockHydraClient.ExpectedCalls = nil
-			mockUserService.ExpectedCalls = nil
-
-			tt.setupMocks()
-
-			url := "/profile/" + tt.userIDParam
-			req := httptest.NewRequest("GET", url, nil)
-			resp, err := app.Test(req)
-			require.NoError(t, err)
-
-			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
-
-			if tt.expectedError != "" {
-				var result map[string]interface{}
-				err = json.NewDecoder(resp.Body).Decode(&result)
-				require.NoError(t, err)
-				assert.Contains(t, result["error"], tt.expectedError)
-			}
-
-			mockHydraClient.AssertExpectations(t)
-			mockUserService.AssertExpectations(t)
-		})
-	}
-}
-
-// Helper function
-func stringPtr(s string) *string {
-	return &s
+	env := setupAuthTest(t)
+	u := env.createUser(t, "john@example.com")
+	other := uuid.New().String()
+
+	t.Run("own profile", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/profile/"+u.ID.String(), nil, "X-User-ID", u.ID.String())
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "john@example.com", body["email"])
+		assert.Equal(t, "John Doe", body["name"])
+	})
+
+	t.Run("invalid user ID", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/profile/not-a-uuid", nil, "X-User-ID", u.ID.String())
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid user ID format", body["error"])
+	})
+
+	t.Run("another user's profile", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/profile/"+other, nil, "X-User-ID", u.ID.String())
+		assert.Equal(t, 403, status)
+		assert.Equal(t, "Access denied", body["error"])
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		status, body := env.do(t, "GET", "/profile/"+other, nil, "X-User-ID", other)
+		assert.Equal(t, 404, status)
+		assert.Equal(t, "User not found", body["error"])
+	})
+}
+
+func TestAuthHandler_LoginOrganization(t *testing.T) {
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app"})
+	env.createUser(t, "john@example.com")
+	_, err := organization.NewService(env.db, zap.NewNop()).Create(env.tenantID, &organization.CreateOrganizationRequest{Name: "Partner", Slug: "partner"})
+	require.NoError(t, err)
+
+	for _, challenge := range []string{"non-member", "reject-fails"} {
+		env.hydra.login[challenge] = &hydra.LoginRequest{Challenge: challenge, Client: &hydra.OAuth2Client{ClientID: "web-app"}}
+	}
+
+	t.Run("user is not a member", func(t *testing.T) {
+		status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: "non-member", Email: "john@example.com", Password: "password123", Organization: "partner"})
+		assert.Equal(t, 200, status)
+		assert.Equal(t, "You are not a member of this organization", body["error"])
+		assert.Equal(t, "access_denied", env.hydra.rejectedLogin["non-member"])
+	})
+
+	t.Run("hydra fails to reject", func(t *testing.T) {
+		env.hydra.failReject = true
+		defer func() { env.hydra.failReject = false }()
+
+		status, body := env.do(t, "POST", "/login/submit", LoginRequest{Challenge: "reject-fails", Email: "john@example.com", Password: "password123", Organization: "partner"})
+		assert.Equal(t, 500, status)
+		assert.Equal(t, "Failed to reject login request", body["error"])
+	})
 }
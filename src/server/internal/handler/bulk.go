@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"authway/src/server/pkg/bulk"
+	"authway/src/server/pkg/tenant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// BulkUserHandler imports and exports a tenant's users in bulk
+type BulkUserHandler struct {
+	bulkService   bulk.Service
+	tenantService *tenant.Service
+	logger        *zap.Logger
+}
+
+func NewBulkUserHandler(bulkService bulk.Service, tenantService *tenant.Service, logger *zap.Logger) *BulkUserHandler {
+	return &BulkUserHandler{
+		bulkService:   bulkService,
+		tenantService: tenantService,
+		logger:        logger,
+	}
+}
+
+// RegisterRoutes registers bulk routes on the admin-protected users group, ahead of its /:id routes
+func (h *BulkUserHandler) RegisterRoutes(users fiber.Router) {
+	users.Post("/import-jobs", h.StartImport)
+	users.Get("/import-jobs", h.ListImports)
+	users.Get("/import-jobs/:id", h.GetImport)
+	users.Get("/export", h.Export)
+}
+
+// StartImport starts an asynchronous import of a CSV or JSON Lines file
+// The file is the request body, or the "file" part of a multipart form
+// POST /api/v1/users/import-jobs?tenant_id=...&format=csv|jsonl&dry_run=true&upsert=true
+func (h *BulkUserHandler) StartImport(c *fiber.Ctx) error {
+	tenantID, err := h.tenant(c)
+	if err != nil {
+		return err
+	}
+
+	var file io.Reader = bytes.NewReader(c.Body())
+	if header, err := c.FormFile("file"); err == nil {
+		f, err := header.Open()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid file upload")
+		}
+		defer f.Close()
+		file = f
+	}
+
+	job, err := h.bulkService.StartImport(tenantID, bulk.ImportOptions{
+		Format: strings.ToLower(c.Query("format", bulk.FormatCSV)),
+		DryRun: c.QueryBool("dry_run"),
+		Upsert: c.QueryBool("upsert"),
+	}, file)
+	if err != nil {
+		return h.bulkError(err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListImports returns a tenant's import jobs without their row error reports
+// GET /api/v1/users/import-jobs?tenant_id=...
+func (h *BulkUserHandler) ListImports(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	jobs, total, err := h.bulkService.ListJobs(tenantID, limit, offset)
+	if err != nil {
+		return h.bulkError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":   jobs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetImport returns an import job's progress and row error report
+// GET /api/v1/users/import-jobs/:id
+func (h *BulkUserHandler) GetImport(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid import job ID")
+	}
+
+	job, err := h.bulkService.GetJob(id)
+	if err != nil {
+		return h.bulkError(err)
+	}
+	return c.JSON(job)
+}
+
+// Export streams a tenant's users as CSV or JSON Lines
+// GET /api/v1/users/export?tenant_id=...&format=csv|jsonl&fields=email,name,...
+func (h *BulkUserHandler) Export(c *fiber.Ctx) error {
+	tenantID, err := h.tenant(c)
+	if err != nil {
+		return err
+	}
+
+	format := strings.ToLower(c.Query("format", bulk.FormatCSV))
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case bulk.FormatCSV:
+	case bulk.FormatJSONL:
+		contentType = "application/x-ndjson"
+	default:
+		return h.bulkError(bulk.ErrUnsupportedFormat)
+	}
+	fields, err := bulk.SelectFields(strings.Split(c.Query("fields"), ","))
+	if err != nil {
+		return h.bulkError(err)
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="users-%s.%s"`, tenantID, format))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The status is already sent; a failure can only cut the stream short
+		if err := h.bulkService.Export(tenantID, format, fields, w); err != nil {
+			h.logger.Error("User export failed", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		}
+		_ = w.Flush()
+	})
+	return nil
+}
+
+// tenant returns the tenant_id query parameter of an existing tenant
+func (h *BulkUserHandler) tenant(c *fiber.Ctx) (uuid.UUID, error) {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return uuid.Nil, fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return uuid.Nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+	return tenantID, nil
+}
+
+func (h *BulkUserHandler) bulkError(err error) error {
+	switch {
+	case errors.Is(err, bulk.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Import job not found")
+	case errors.Is(err, bulk.ErrUnsupportedFormat), errors.Is(err, bulk.ErrInvalidFile),
+		errors.Is(err, bulk.ErrUnknownField):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, bulk.ErrTooManyRows):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("%s (at most %d)", err.Error(), bulk.MaxRows))
+	default:
+		h.logger.Error("Bulk user operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process bulk user operation")
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"authway/src/server/internal/service"
@@ -75,6 +76,13 @@ func (h *ClientHandler) Create(c *fiber.Ctx) error {
 	newClient, credentials, err := h.services.ClientService.Create(&req)
 	if err != nil {
 		h.logger.Error("Failed to create client", zap.Error(err), zap.String("name", req.Name))
+		var policyErr *client.PolicyError
+		if errors.As(err, &policyErr) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, client.ErrClientIDTaken) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
 		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
 	}
 
@@ -126,6 +134,10 @@ func (h *ClientHandler) Update(c *fiber.Ctx) error {
 	updatedClient, err := h.services.ClientService.Update(id, &req)
 	if err != nil {
 		h.logger.Error("Failed to update client", zap.Error(err), zap.String("id", idStr))
+		var policyErr *client.PolicyError
+		if errors.As(err, &policyErr) {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		return fiber.NewError(fiber.StatusNotFound, err.Error())
 	}
 
@@ -157,6 +169,60 @@ func (h *ClientHandler) Delete(c *fiber.Ctx) error {
 	})
 }
 
+// ListDeleted handles listing soft-deleted OAuth clients that can still be restored
+func (h *ClientHandler) ListDeleted(c *fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	clients, total, err := h.services.ClientService.ListDeleted(limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list deleted clients", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve deleted clients")
+	}
+
+	publicClients := make([]client.PublicClient, len(clients))
+	for i, cl := range clients {
+		publicClients[i] = cl.ToPublic()
+	}
+
+	return c.JSON(fiber.Map{
+		"clients": publicClients,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// Restore handles undeleting a soft-deleted OAuth client
+func (h *ClientHandler) Restore(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid client ID")
+	}
+
+	restored, err := h.services.ClientService.Restore(id)
+	if err != nil {
+		h.logger.Error("Failed to restore client", zap.Error(err), zap.String("id", idStr))
+		var policyErr *client.PolicyError
+		switch {
+		case errors.Is(err, client.ErrDeletedNotFound):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case errors.Is(err, client.ErrTenantUnavailable):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		case errors.As(err, &policyErr):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to restore client")
+	}
+
+	h.logger.Info("Client restored successfully", zap.String("id", idStr))
+
+	return c.JSON(fiber.Map{
+		"message": "Client restored successfully",
+		"client":  restored.ToPublic(),
+	})
+}
+
 // RegenerateSecret handles regenerating client secret
 func (h *ClientHandler) RegenerateSecret(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"sort"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/consent"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ConnectedAppHandler lets users (and admins on their behalf) review and revoke
+// the applications they have granted access to
+type ConnectedAppHandler struct {
+	hydraClient    *hydra.Client
+	clientService  client.Service
+	consentService consent.Service
+	logger         *zap.Logger
+}
+
+func NewConnectedAppHandler(hydraClient *hydra.Client, clientService client.Service, consentService consent.Service, logger *zap.Logger) *ConnectedAppHandler {
+	return &ConnectedAppHandler{
+		hydraClient:    hydraClient,
+		clientService:  clientService,
+		consentService: consentService,
+		logger:         logger,
+	}
+}
+
+// ListMine lists the connected apps of the user owning the bearer token
+func (h *ConnectedAppHandler) ListMine(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(localUserID(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid token subject")
+	}
+	return h.list(c, userID)
+}
+
+// RevokeMine revokes one app's consent and tokens for the user owning the bearer token
+func (h *ConnectedAppHandler) RevokeMine(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(localUserID(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid token subject")
+	}
+	return h.revoke(c, userID, c.Params("client_id"))
+}
+
+// ListForUser lists a user's connected apps (admin)
+func (h *ConnectedAppHandler) ListForUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+	return h.list(c, userID)
+}
+
+// RevokeForUser revokes one app's consent and tokens for a user (admin)
+func (h *ConnectedAppHandler) RevokeForUser(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+	return h.revoke(c, userID, c.Params("client_id"))
+}
+
+func (h *ConnectedAppHandler) list(c *fiber.Ctx, userID uuid.UUID) error {
+	sessions, err := h.hydraClient.ListConsentSessions(userID.String())
+	if err != nil {
+		h.logger.Error("Failed to list consent sessions", zap.Error(err), zap.String("user_id", userID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve connected apps")
+	}
+
+	grants, err := h.consentService.ListGrants(userID)
+	if err != nil {
+		h.logger.Error("Failed to list consent grants", zap.Error(err), zap.String("user_id", userID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve connected apps")
+	}
+	grantedAt := make(map[string]*consent.Grant, len(grants))
+	for _, grant := range grants {
+		grantedAt[grant.ClientID] = grant
+	}
+
+	// Hydra keeps one session per remembered consent; merge them per client
+	apps := map[string]*consent.ConnectedApp{}
+	for _, session := range sessions {
+		if session.ConsentRequest == nil || session.ConsentRequest.Client == nil {
+			continue
+		}
+		hydraClient := session.ConsentRequest.Client
+
+		app, ok := apps[hydraClient.ClientID]
+		if !ok {
+			app = &consent.ConnectedApp{
+				ClientID:  hydraClient.ClientID,
+				Name:      hydraClient.ClientName,
+				Logo:      hydraClient.LogoURI,
+				Website:   hydraClient.ClientURI,
+				GrantedAt: session.HandledAt,
+			}
+			if registered, err := h.clientService.GetByClientID(hydraClient.ClientID); err == nil {
+				app.Name = registered.Name
+				app.Logo = registered.Logo
+				app.Website = registered.Website
+			}
+			if grant, ok := grantedAt[hydraClient.ClientID]; ok {
+				app.GrantedAt = grant.CreatedAt
+			}
+			apps[hydraClient.ClientID] = app
+		}
+
+		app.Scopes = consent.MergeScopes(app.Scopes, session.GrantScope)
+		if _, ok := grantedAt[hydraClient.ClientID]; !ok && session.HandledAt.Before(app.GrantedAt) {
+			app.GrantedAt = session.HandledAt
+		}
+	}
+
+	result := make([]consent.ConnectedApp, 0, len(apps))
+	for _, app := range apps {
+		result = append(result, *app)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].GrantedAt.Before(result[j].GrantedAt)
+	})
+
+	return c.JSON(fiber.Map{
+		"apps":  result,
+		"total": len(result),
+	})
+}
+
+func (h *ConnectedAppHandler) revoke(c *fiber.Ctx, userID uuid.UUID, clientID string) error {
+	if clientID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "client_id is required")
+	}
+
+	// Hydra revokes the consent together with the tokens issued to the client
+	if err := h.hydraClient.RevokeClientConsentSessions(userID.String(), clientID); err != nil {
+		h.logger.Error("Failed to revoke consent sessions",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke app access")
+	}
+
+	// Forget the remembered scopes so the next authorization prompts again
+	if err := h.consentService.DeleteGrant(userID, clientID); err != nil {
+		h.logger.Error("Failed to delete consent grant",
+			zap.Error(err),
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke app access")
+	}
+
+	h.logger.Info("Connected app revoked",
+		zap.String("user_id", userID.String()),
+		zap.String("client_id", clientID))
+
+	return c.JSON(fiber.Map{
+		"message": "App access revoked successfully",
+	})
+}
+
+// localUserID returns the token subject stored by middleware.RequireAuth
+func localUserID(c *fiber.Ctx) string {
+	userID, _ := c.Locals("userID").(string)
+	return userID
+}
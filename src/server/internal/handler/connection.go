@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"authway/src/server/internal/service/sso"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ConnectionHandler manages tenants' enterprise connections
+type ConnectionHandler struct {
+	connectionService connection.Service
+	tenantService     *tenant.Service
+	samlService       *sso.SAMLService
+	validator         *validator.Validate
+	logger            *zap.Logger
+	baseURL           string
+}
+
+func NewConnectionHandler(
+	connectionService connection.Service,
+	tenantService *tenant.Service,
+	samlService *sso.SAMLService,
+	validator *validator.Validate,
+	logger *zap.Logger,
+	baseURL string,
+) *ConnectionHandler {
+	return &ConnectionHandler{
+		connectionService: connectionService,
+		tenantService:     tenantService,
+		samlService:       samlService,
+		validator:         validator,
+		logger:            logger,
+		baseURL:           strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// RegisterRoutes registers connection routes on an admin-protected group
+func (h *ConnectionHandler) RegisterRoutes(connections fiber.Router) {
+	connections.Post("/", h.Create)
+	connections.Get("/", h.List)
+	connections.Get("/:id", h.Get)
+	connections.Put("/:id", h.Update)
+	connections.Delete("/:id", h.Delete)
+	connections.Get("/:id/saml/sp", h.ServiceProvider)
+	connections.Post("/:id/saml/metadata", h.ImportMetadata)
+}
+
+func (h *ConnectionHandler) Create(c *fiber.Ctx) error {
+	var req connection.CreateConnectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	// SAML connections can be created straight from the IdP's metadata
+	if req.Type == connection.TypeSAML && (req.IdPMetadataXML != "" || req.IdPMetadataURL != "") {
+		metadata, err := h.loadMetadata(c, req.IdPMetadataXML, req.IdPMetadataURL)
+		if err != nil {
+			return err
+		}
+		req.IdPEntityID = metadata.EntityID
+		req.IdPSSOURL = metadata.SSOURL
+		req.IdPSSOBinding = metadata.SSOBinding
+		req.IdPCertificate = metadata.Certificate
+		if req.IdPMetadataXML != "" {
+			req.IdPMetadataURL = ""
+		}
+	}
+
+	created, err := h.connectionService.Create(tenantID, &req)
+	if err != nil {
+		return h.connectionError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// List returns a tenant's enterprise connections
+// GET /api/v1/connections?tenant_id=...
+func (h *ConnectionHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	connections, err := h.connectionService.List(tenantID)
+	if err != nil {
+		h.logger.Error("Failed to list connections", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve connections")
+	}
+
+	return c.JSON(fiber.Map{
+		"connections": connections,
+		"total":       len(connections),
+	})
+}
+
+func (h *ConnectionHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connection ID")
+	}
+
+	found, err := h.connectionService.Get(id)
+	if err != nil {
+		return h.connectionError(err)
+	}
+	return c.JSON(found)
+}
+
+func (h *ConnectionHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connection ID")
+	}
+
+	var req connection.UpdateConnectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	updated, err := h.connectionService.Update(id, &req)
+	if err != nil {
+		return h.connectionError(err)
+	}
+	return c.JSON(updated)
+}
+
+func (h *ConnectionHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connection ID")
+	}
+
+	if err := h.connectionService.Delete(id); err != nil {
+		return h.connectionError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ServiceProvider returns the SP settings to register at the connection's SAML identity provider
+// GET /api/v1/connections/:id/saml/sp
+func (h *ConnectionHandler) ServiceProvider(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connection ID")
+	}
+
+	found, err := h.connectionService.Get(id)
+	if err != nil {
+		return h.connectionError(err)
+	}
+	if found.Type != connection.TypeSAML {
+		return fiber.NewError(fiber.StatusBadRequest, "Not a SAML connection")
+	}
+
+	sp := samlServiceProvider(h.baseURL, found.ID)
+	return c.JSON(fiber.Map{
+		"entity_id":    sp.EntityID,
+		"acs_url":      sp.ACSURL,
+		"metadata_url": sp.EntityID,
+	})
+}
+
+// ImportMetadata configures a SAML connection from an uploaded or fetched IdP metadata document
+// POST /api/v1/connections/:id/saml/metadata
+func (h *ConnectionHandler) ImportMetadata(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connection ID")
+	}
+
+	var req connection.ImportMetadataRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	metadata, err := h.loadMetadata(c, req.MetadataXML, req.MetadataURL)
+	if err != nil {
+		return err
+	}
+
+	metadataURL := req.MetadataURL
+	if req.MetadataXML != "" {
+		metadataURL = ""
+	}
+
+	updated, err := h.connectionService.ApplyMetadata(id, metadata, metadataURL)
+	if err != nil {
+		return h.connectionError(err)
+	}
+	return c.JSON(updated)
+}
+
+// loadMetadata parses the uploaded IdP metadata document, or fetches it when only a URL is given
+func (h *ConnectionHandler) loadMetadata(c *fiber.Ctx, metadataXML, metadataURL string) (*connection.IdPMetadata, error) {
+	if metadataXML != "" {
+		metadata, err := sso.ParseIdPMetadata([]byte(metadataXML))
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid IdP metadata: "+err.Error())
+		}
+		return metadata, nil
+	}
+
+	metadata, err := h.samlService.FetchIdPMetadata(c.Context(), metadataURL)
+	if err != nil {
+		h.logger.Warn("Failed to import IdP metadata", zap.Error(err), zap.String("metadata_url", metadataURL))
+		return nil, fiber.NewError(fiber.StatusBadGateway, "Failed to fetch IdP metadata: "+err.Error())
+	}
+	return metadata, nil
+}
+
+func (h *ConnectionHandler) connectionError(err error) error {
+	switch {
+	case errors.Is(err, connection.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Connection not found")
+	case errors.Is(err, connection.ErrDuplicateName):
+		return fiber.NewError(fiber.StatusConflict, "A connection with this name already exists")
+	case errors.Is(err, connection.ErrDomainTaken):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, connection.ErrInvalidSAMLSettings), errors.Is(err, connection.ErrInvalidLDAPSettings):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Connection operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process connection")
+	}
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+
 	"authway/src/server/internal/hydra"
 	"authway/src/server/pkg/email"
 	"authway/src/server/pkg/user"
@@ -9,6 +11,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // EmailHandler handles email verification and password reset requests
@@ -329,6 +332,121 @@ func (h *EmailHandler) ResetPassword(c *fiber.Ctx) error {
 	})
 }
 
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Apply a pending email change with the token sent to the new address
+// @Tags Email
+// @Accept json
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/email/confirm-email-change [get]
+func (h *EmailHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	change, err := h.emailRepo.GetEmailChangeByToken(token)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+	if !change.IsPending() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email change is no longer pending",
+		})
+	}
+
+	// Uniqueness is checked again: the address may have been taken since the request.
+	// The change is claimed in the same transaction as the update, so a token applies at most once
+	consume := func(tx *gorm.DB) error {
+		return h.emailRepo.ConfirmEmailChange(tx, change.ID)
+	}
+	if _, err := h.userSvc.ChangeEmail(change.UserID, change.OldEmail, change.NewEmail, consume); err != nil {
+		switch {
+		case errors.Is(err, email.ErrEmailChangeNotPending):
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Email change is no longer pending",
+			})
+		case errors.Is(err, user.ErrEmailTaken), errors.Is(err, user.ErrEmailChanged):
+			return c.Status(http.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("Failed to change email", zap.Error(err))
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to change email",
+		})
+	}
+
+	// Sign the user out everywhere so sessions and tokens pick up the new email
+	if err := h.hydraClient.RevokeUserSessions(change.UserID.String()); err != nil {
+		h.logger.Error("Failed to revoke user sessions after email change",
+			zap.String("user_id", change.UserID.String()),
+			zap.Error(err))
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email changed successfully",
+		"email":   change.NewEmail,
+	})
+}
+
+// CancelEmailChange godoc
+// @Summary Cancel email change
+// @Description Cancel a pending email change with the token sent to the old address
+// @Tags Email
+// @Accept json
+// @Produce json
+// @Param token query string true "Email change cancel token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Router /api/email/cancel-email-change [get]
+func (h *EmailHandler) CancelEmailChange(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Token is required",
+		})
+	}
+
+	change, err := h.emailRepo.GetEmailChangeByCancelToken(token)
+	if err != nil {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+	if !change.IsPending() {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Email change is no longer pending",
+		})
+	}
+
+	if err := h.emailRepo.CancelEmailChange(change.ID); err != nil {
+		if errors.Is(err, email.ErrEmailChangeNotPending) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error": "Email change is no longer pending",
+			})
+		}
+		h.logger.Error("Failed to cancel email change", zap.Error(err))
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel email change",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email change cancelled",
+	})
+}
+
 // RegisterRoutes registers email routes
 func (h *EmailHandler) RegisterRoutes(router fiber.Router) {
 	email := router.Group("/email")
@@ -338,5 +456,7 @@ func (h *EmailHandler) RegisterRoutes(router fiber.Router) {
 		email.Post("/forgot-password", h.ForgotPassword)
 		email.Get("/verify-reset-token", h.VerifyResetToken)
 		email.Post("/reset-password", h.ResetPassword)
+		email.Get("/confirm-email-change", h.ConfirmEmailChange)
+		email.Get("/cancel-email-change", h.CancelEmailChange)
 	}
 }
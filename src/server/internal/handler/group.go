@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"authway/src/server/pkg/webhook"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GroupHandler manages tenant groups and their members
+type GroupHandler struct {
+	groupService  group.Service
+	rbacService   rbac.Service
+	userService   user.Service
+	tenantService *tenant.Service
+	webhooks      webhook.Publisher
+	validator     *validator.Validate
+	logger        *zap.Logger
+}
+
+func NewGroupHandler(
+	groupService group.Service,
+	rbacService rbac.Service,
+	userService user.Service,
+	tenantService *tenant.Service,
+	webhooks webhook.Publisher,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *GroupHandler {
+	return &GroupHandler{
+		groupService:  groupService,
+		rbacService:   rbacService,
+		userService:   userService,
+		tenantService: tenantService,
+		webhooks:      webhooks,
+		validator:     validator,
+		logger:        logger,
+	}
+}
+
+// RegisterRoutes registers group routes on an admin-protected group
+func (h *GroupHandler) RegisterRoutes(groups fiber.Router) {
+	groups.Post("/", h.Create)
+	groups.Get("/", h.List)
+	groups.Get("/:id", h.Get)
+	groups.Put("/:id", h.Update)
+	groups.Delete("/:id", h.Delete)
+	groups.Get("/:id/members", h.ListMembers)
+	groups.Post("/:id/members", h.AddMembers)
+	groups.Delete("/:id/members", h.RemoveMembers)
+}
+
+// groupMembersEvent is the webhook payload for membership changes
+type groupMembersEvent struct {
+	GroupID   uuid.UUID   `json:"group_id"`
+	GroupName string      `json:"group_name"`
+	UserIDs   []uuid.UUID `json:"user_ids"`
+}
+
+func (h *GroupHandler) Create(c *fiber.Ctx) error {
+	var req group.CreateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	created, err := h.groupService.Create(tenantID, &req)
+	if err != nil {
+		return h.groupError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// List returns a tenant's groups
+// GET /api/v1/groups?tenant_id=...
+func (h *GroupHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	groups, err := h.groupService.List(tenantID)
+	if err != nil {
+		h.logger.Error("Failed to list groups", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve groups")
+	}
+
+	return c.JSON(fiber.Map{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+func (h *GroupHandler) Get(c *fiber.Ctx) error {
+	found, err := h.group(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(found)
+}
+
+func (h *GroupHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid group ID")
+	}
+
+	var req group.UpdateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	updated, err := h.groupService.Update(id, &req)
+	if err != nil {
+		return h.groupError(err)
+	}
+
+	return c.JSON(updated)
+}
+
+// Delete removes a group, its memberships and its role assignments
+func (h *GroupHandler) Delete(c *fiber.Ctx) error {
+	deleted, err := h.group(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.groupService.Delete(deleted.ID); err != nil {
+		return h.groupError(err)
+	}
+
+	if err := h.rbacService.DeleteSubjectAssignments(rbac.SubjectGroup, deleted.ID); err != nil {
+		h.logger.Error("Failed to delete group role assignments", zap.Error(err), zap.String("group_id", deleted.ID.String()))
+	}
+
+	h.webhooks.Publish(webhook.EventGroupDeleted, deleted.TenantID, fiber.Map{
+		"group_id":   deleted.ID,
+		"group_name": deleted.Name,
+	})
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListMembers returns a page of the group's direct members
+// GET /api/v1/groups/:id/members?limit=20&offset=0
+func (h *GroupHandler) ListMembers(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid group ID")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	members, total, err := h.groupService.ListMembers(id, limit, offset)
+	if err != nil {
+		return h.groupError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"members": members,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// AddMembers adds users to the group in bulk
+func (h *GroupHandler) AddMembers(c *fiber.Ctx) error {
+	target, err := h.group(c)
+	if err != nil {
+		return err
+	}
+
+	var req group.MembersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	added, err := h.groupService.AddMembers(target.ID, parseUUIDs(req.UserIDs))
+	if err != nil {
+		return h.groupError(err)
+	}
+
+	if len(added) > 0 {
+		h.webhooks.Publish(webhook.EventGroupMembersAdded, target.TenantID, groupMembersEvent{
+			GroupID:   target.ID,
+			GroupName: target.Name,
+			UserIDs:   added,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"added": added,
+	})
+}
+
+// RemoveMembers removes users from the group in bulk
+func (h *GroupHandler) RemoveMembers(c *fiber.Ctx) error {
+	target, err := h.group(c)
+	if err != nil {
+		return err
+	}
+
+	var req group.MembersRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	removed, err := h.groupService.RemoveMembers(target.ID, parseUUIDs(req.UserIDs))
+	if err != nil {
+		return h.groupError(err)
+	}
+
+	if len(removed) > 0 {
+		h.webhooks.Publish(webhook.EventGroupMembersRemoved, target.TenantID, groupMembersEvent{
+			GroupID:   target.ID,
+			GroupName: target.Name,
+			UserIDs:   removed,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"removed": removed,
+	})
+}
+
+// UserGroups returns the groups a user is a direct member of
+// GET /api/v1/users/:id/groups
+func (h *GroupHandler) UserGroups(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	if _, err := h.userService.GetByID(userID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	groups, err := h.groupService.UserGroups(userID)
+	if err != nil {
+		h.logger.Error("Failed to list user groups", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve groups")
+	}
+
+	return c.JSON(fiber.Map{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+func (h *GroupHandler) group(c *fiber.Ctx) (*group.Group, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid group ID")
+	}
+
+	found, err := h.groupService.Get(id)
+	if err != nil {
+		return nil, h.groupError(err)
+	}
+	return found, nil
+}
+
+// parseUUIDs converts IDs that already passed uuid validation
+func parseUUIDs(raw []string) []uuid.UUID {
+	ids := make([]uuid.UUID, len(raw))
+	for i, value := range raw {
+		ids[i] = uuid.MustParse(value)
+	}
+	return ids
+}
+
+func (h *GroupHandler) groupError(err error) error {
+	switch {
+	case errors.Is(err, group.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Group not found")
+	case errors.Is(err, group.ErrDuplicateName):
+		return fiber.NewError(fiber.StatusConflict, "A group with this name already exists")
+	case errors.Is(err, group.ErrInvalidParent):
+		return fiber.NewError(fiber.StatusBadRequest, "Parent group must be another group of the same tenant and must not create a cycle")
+	case errors.Is(err, group.ErrHasChildren):
+		return fiber.NewError(fiber.StatusConflict, "Delete or move the subgroups first")
+	case errors.Is(err, group.ErrUnknownUsers):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Group operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process group")
+	}
+}
@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/invitation"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// InvitationHandler handles tenant invitations: admin management and public acceptance
+type InvitationHandler struct {
+	invitationService invitation.Service
+	userService       user.Service
+	tenantService     *tenant.Service
+	rbacService       rbac.Service
+	orgService        organization.Service
+	emailSvc          *email.Service
+	validator         *validator.Validate
+	logger            *zap.Logger
+}
+
+func NewInvitationHandler(
+	invitationService invitation.Service,
+	userService user.Service,
+	tenantService *tenant.Service,
+	rbacService rbac.Service,
+	orgService organization.Service,
+	emailSvc *email.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		userService:       userService,
+		tenantService:     tenantService,
+		rbacService:       rbacService,
+		orgService:        orgService,
+		emailSvc:          emailSvc,
+		validator:         validator,
+		logger:            logger,
+	}
+}
+
+// RegisterAdminRoutes registers invitation management routes on an admin-protected group
+func (h *InvitationHandler) RegisterAdminRoutes(invitations fiber.Router) {
+	invitations.Post("/", h.Create)
+	invitations.Get("/", h.List)
+	invitations.Post("/:id/resend", h.Resend)
+	invitations.Delete("/:id", h.Revoke)
+}
+
+// RegisterRoutes registers the public invitation acceptance routes
+func (h *InvitationHandler) RegisterRoutes(router fiber.Router) {
+	invitations := router.Group("/invitations")
+	invitations.Post("/accept", h.Accept)
+	invitations.Get("/:token", h.Preview)
+}
+
+// Create invites a user to a tenant and sends the invitation email
+func (h *InvitationHandler) Create(c *fiber.Ctx) error {
+	var req invitation.CreateInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	invitedTenant, err := h.tenantService.GetTenantByID(tenantID)
+	if err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	if _, err := h.userService.GetByEmailAndTenant(tenantID, req.Email); err == nil {
+		return fiber.NewError(fiber.StatusConflict, "A user with this email already exists in the tenant")
+	}
+
+	// Invitation roles are granted on acceptance, so they must exist as tenant-wide roles now
+	if len(req.Roles) > 0 {
+		if _, err := h.rbacService.FindTenantRoles(tenantID, req.Roles); err != nil {
+			if errors.Is(err, rbac.ErrRoleNotFound) {
+				return fiber.NewError(fiber.StatusBadRequest, "Unknown role for this tenant")
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve roles")
+		}
+	}
+
+	inv, err := h.invitationService.Create(tenantID, req.Email, req.Roles, req.InvitedBy, time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		if errors.Is(err, invitation.ErrAlreadyInvited) {
+			return fiber.NewError(fiber.StatusConflict, "This email already has a pending invitation")
+		}
+		h.logger.Error("Failed to create invitation", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create invitation")
+	}
+
+	if err := h.emailSvc.SendInvitationEmail(inv.Email, invitedTenant.Name, inv.Token); err != nil {
+		h.logger.Error("Failed to send invitation email", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Invitation created but the email could not be sent; resend it later")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inv.ToPublic())
+}
+
+// List returns a tenant's invitations
+// GET /api/v1/invitations?tenant_id=...&status=pending&limit=20&offset=0
+func (h *InvitationHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	invitations, total, err := h.invitationService.List(tenantID, c.Query("status"), limit, offset)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	publicInvitations := make([]invitation.PublicInvitation, len(invitations))
+	for i, inv := range invitations {
+		publicInvitations[i] = inv.ToPublic()
+	}
+
+	return c.JSON(fiber.Map{
+		"invitations": publicInvitations,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// Resend renews an invitation's token and expiry and emails it again
+func (h *InvitationHandler) Resend(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	inv, err := h.invitationService.Resend(id, 0)
+	if err != nil {
+		return h.invitationError(err)
+	}
+
+	invitedTenant, err := h.tenantService.GetTenantByID(inv.TenantID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	inviterName := invitedTenant.Name
+	if inv.OrganizationID != nil {
+		if org, err := h.orgService.Get(*inv.OrganizationID); err == nil {
+			inviterName = org.Name
+		}
+	}
+
+	if err := h.emailSvc.SendInvitationEmail(inv.Email, inviterName, inv.Token); err != nil {
+		h.logger.Error("Failed to resend invitation email", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send invitation email")
+	}
+
+	return c.JSON(inv.ToPublic())
+}
+
+// Revoke cancels a pending invitation
+func (h *InvitationHandler) Revoke(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid invitation ID")
+	}
+
+	if err := h.invitationService.Revoke(id); err != nil {
+		return h.invitationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Preview shows the invitee which tenant invited them before they accept
+func (h *InvitationHandler) Preview(c *fiber.Ctx) error {
+	inv, err := h.pendingInvitation(c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	invitedTenant, err := h.tenantService.GetTenantByID(inv.TenantID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Invitation not found")
+	}
+
+	preview := fiber.Map{
+		"email":      inv.Email,
+		"tenant":     invitedTenant.ToPublic(),
+		"expires_at": inv.ExpiresAt,
+	}
+	if inv.OrganizationID != nil {
+		if org, err := h.orgService.Get(*inv.OrganizationID); err == nil {
+			preview["organization"] = org.ToPublic(invitedTenant)
+		}
+	}
+
+	return c.JSON(preview)
+}
+
+// Accept creates the invited user with a verified email
+func (h *InvitationHandler) Accept(c *fiber.Ctx) error {
+	var req invitation.AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	inv, err := h.pendingInvitation(req.Token)
+	if err != nil {
+		return err
+	}
+
+	invitedTenant, err := h.tenantService.GetTenantByID(inv.TenantID)
+	if err != nil || !invitedTenant.Active {
+		return fiber.NewError(fiber.StatusForbidden, "Tenant is not available")
+	}
+
+	if _, err := h.userService.GetByEmailAndTenant(inv.TenantID, inv.Email); err == nil {
+		return fiber.NewError(fiber.StatusConflict, "An account with this email already exists")
+	}
+
+	createdUser, err := h.userService.Create(inv.TenantID, &user.CreateUserRequest{
+		Email:    inv.Email,
+		Password: req.Password,
+		Name:     req.Name,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create invited user", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create user")
+	}
+
+	// The invitation was delivered to this address, so it is already verified
+	if err := h.userService.UpdateEmailVerified(createdUser.ID, true); err != nil {
+		h.logger.Error("Failed to mark invited user as verified", zap.Error(err))
+	}
+	createdUser.EmailVerified = true
+
+	h.assignInvitationRoles(inv, createdUser.ID)
+
+	if inv.OrganizationID != nil {
+		if _, err := h.orgService.AddMember(*inv.OrganizationID, createdUser.ID, inv.OrganizationRole); err != nil {
+			h.logger.Error("Failed to add invited user to organization", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+		}
+	}
+
+	if err := h.invitationService.MarkAccepted(inv.ID, createdUser.ID); err != nil {
+		h.logger.Error("Failed to mark invitation as accepted", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(createdUser.ToPublic())
+}
+
+// assignInvitationRoles grants the roles named in the invitation to the new user
+// Roles deleted since the invitation was sent are skipped
+func (h *InvitationHandler) assignInvitationRoles(inv *invitation.Invitation, userID uuid.UUID) {
+	for _, name := range inv.Roles {
+		roles, err := h.rbacService.FindTenantRoles(inv.TenantID, []string{name})
+		if err != nil {
+			h.logger.Warn("Invitation role not available", zap.String("role", name), zap.String("invitation_id", inv.ID.String()))
+			continue
+		}
+		if err := h.rbacService.Assign(roles[0].ID, rbac.SubjectUser, userID); err != nil {
+			h.logger.Error("Failed to assign invitation role", zap.Error(err), zap.String("role", name))
+		}
+	}
+}
+
+// pendingInvitation looks up an invitation by token and checks it can still be accepted
+func (h *InvitationHandler) pendingInvitation(token string) (*invitation.Invitation, error) {
+	inv, err := h.invitationService.GetByToken(token)
+	if err != nil {
+		return nil, h.invitationError(err)
+	}
+
+	switch inv.Status() {
+	case invitation.StatusPending:
+		return inv, nil
+	case invitation.StatusExpired:
+		return nil, h.invitationError(invitation.ErrExpired)
+	default:
+		return nil, h.invitationError(invitation.ErrNotPending)
+	}
+}
+
+func (h *InvitationHandler) invitationError(err error) error {
+	switch {
+	case errors.Is(err, invitation.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Invitation not found")
+	case errors.Is(err, invitation.ErrExpired):
+		return fiber.NewError(fiber.StatusGone, "Invitation has expired")
+	case errors.Is(err, invitation.ErrNotPending):
+		return fiber.NewError(fiber.StatusConflict, "Invitation was already accepted or revoked")
+	default:
+		h.logger.Error("Invitation operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process invitation")
+	}
+}
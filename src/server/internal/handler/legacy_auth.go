@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+
+	"authway/src/server/pkg/legacyauth"
+	"authway/src/server/pkg/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// LegacyAuthHandler manages tenants' legacy auth connectors for lazy user migration
+type LegacyAuthHandler struct {
+	legacyAuthService legacyauth.Service
+	tenantService     *tenant.Service
+	validator         *validator.Validate
+	logger            *zap.Logger
+}
+
+func NewLegacyAuthHandler(
+	legacyAuthService legacyauth.Service,
+	tenantService *tenant.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *LegacyAuthHandler {
+	return &LegacyAuthHandler{
+		legacyAuthService: legacyAuthService,
+		tenantService:     tenantService,
+		validator:         validator,
+		logger:            logger,
+	}
+}
+
+// RegisterRoutes registers legacy auth connector routes on an admin-protected group
+func (h *LegacyAuthHandler) RegisterRoutes(connectors fiber.Router) {
+	connectors.Post("/", h.Create)
+	connectors.Get("/", h.GetByTenant)
+	connectors.Get("/:id", h.Get)
+	connectors.Put("/:id", h.Update)
+	connectors.Delete("/:id", h.Delete)
+	connectors.Get("/:id/report", h.Report)
+}
+
+func (h *LegacyAuthHandler) Create(c *fiber.Ctx) error {
+	var req legacyauth.CreateConnectorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	created, err := h.legacyAuthService.Create(tenantID, &req)
+	if err != nil {
+		return h.legacyAuthError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// GetByTenant returns a tenant's legacy auth connector
+// GET /api/v1/legacy-auth-connectors?tenant_id=...
+func (h *LegacyAuthHandler) GetByTenant(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	found, err := h.legacyAuthService.GetByTenant(tenantID)
+	if err != nil {
+		return h.legacyAuthError(err)
+	}
+	return c.JSON(found)
+}
+
+func (h *LegacyAuthHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connector ID")
+	}
+
+	found, err := h.legacyAuthService.Get(id)
+	if err != nil {
+		return h.legacyAuthError(err)
+	}
+	return c.JSON(found)
+}
+
+func (h *LegacyAuthHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connector ID")
+	}
+
+	var req legacyauth.UpdateConnectorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	updated, err := h.legacyAuthService.Update(id, &req)
+	if err != nil {
+		return h.legacyAuthError(err)
+	}
+	return c.JSON(updated)
+}
+
+func (h *LegacyAuthHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connector ID")
+	}
+
+	if err := h.legacyAuthService.Delete(id); err != nil {
+		return h.legacyAuthError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Report returns the progress of a tenant's lazy migration and the state of its circuit breaker
+// GET /api/v1/legacy-auth-connectors/:id/report
+func (h *LegacyAuthHandler) Report(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid connector ID")
+	}
+
+	report, err := h.legacyAuthService.Report(id)
+	if err != nil {
+		return h.legacyAuthError(err)
+	}
+	return c.JSON(report)
+}
+
+func (h *LegacyAuthHandler) legacyAuthError(err error) error {
+	switch {
+	case errors.Is(err, legacyauth.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Legacy auth connector not found")
+	case errors.Is(err, legacyauth.ErrAlreadyConfigured):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, legacyauth.ErrInvalidURL):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("Legacy auth connector operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process legacy auth connector")
+	}
+}
@@ -0,0 +1,612 @@
+package handler
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/attribute"
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/privacy"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MeHandler serves the self-service account API for the user owning the bearer token
+// All routes must run after middleware.RequireAuth
+type MeHandler struct {
+	userService      user.Service
+	attributeService attribute.Service
+	privacyService   privacy.Service
+	emailRepo        *email.Repository
+	emailSvc         *email.Service
+	hydraClient      *hydra.Client
+	validator        *validator.Validate
+	logger           *zap.Logger
+}
+
+func NewMeHandler(
+	userService user.Service,
+	attributeService attribute.Service,
+	privacyService privacy.Service,
+	emailRepo *email.Repository,
+	emailSvc *email.Service,
+	hydraClient *hydra.Client,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *MeHandler {
+	return &MeHandler{
+		userService:      userService,
+		attributeService: attributeService,
+		privacyService:   privacyService,
+		emailRepo:        emailRepo,
+		emailSvc:         emailSvc,
+		hydraClient:      hydraClient,
+		validator:        validator,
+		logger:           logger,
+	}
+}
+
+// activeSession is a Hydra login session and the apps that used it
+type activeSession struct {
+	ID         string    `json:"id"`
+	Clients    []string  `json:"clients"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// RegisterRoutes registers self-service routes on a group protected by middleware.RequireAuth
+func (h *MeHandler) RegisterRoutes(me fiber.Router) {
+	me.Get("/", h.Get)
+	me.Patch("/", h.Update)
+	me.Delete("/", h.DeleteAccount)
+	me.Put("/avatar", h.UpdateAvatar)
+	me.Get("/attributes", h.ListAttributes)
+	me.Post("/password", h.ChangePassword)
+	me.Post("/email", h.ChangeEmail)
+	me.Post("/reauthentication-code", h.SendReauthCode)
+	me.Get("/identities", h.ListIdentities)
+	me.Delete("/identities/:provider", h.UnlinkIdentity)
+	me.Get("/sessions", h.ListSessions)
+	me.Delete("/sessions", h.RevokeAllSessions)
+	me.Delete("/sessions/:id", h.RevokeSession)
+	me.Get("/data-export", h.ExportData)
+	me.Get("/erasure", h.GetErasure)
+	me.Post("/erasure", h.RequestErasure)
+	me.Delete("/erasure", h.CancelErasure)
+}
+
+// Get returns the current user's profile
+func (h *MeHandler) Get(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"user":       u.ToPublic(),
+		"tenant_id":  u.TenantID,
+		"provider":   u.Provider,
+		"identities": u.Identities(),
+	})
+}
+
+// Update updates the current user's name, avatar and user-editable attributes
+func (h *MeHandler) Update(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.UpdateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if req.Attributes != nil {
+		attributes, err := h.attributeService.Validate(u.TenantID, u, req.Attributes, true)
+		if err != nil {
+			if fields, ok := attributeFieldErrors(err); ok {
+				return validationFailed(c, fields...)
+			}
+			h.logger.Error("Failed to validate attributes", zap.Error(err), zap.String("user_id", u.ID.String()))
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to update profile")
+		}
+		req.Attributes = attributes
+	}
+
+	updatedUser, err := h.userService.Update(u.ID, &req)
+	if err != nil {
+		h.logger.Error("Failed to update profile", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update profile")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Profile updated successfully",
+		"user":    updatedUser.ToPublic(),
+	})
+}
+
+// UpdateAvatar sets the current user's avatar URL
+func (h *MeHandler) UpdateAvatar(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.UpdateAvatarRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	updatedUser, err := h.userService.Update(u.ID, &user.UpdateUserRequest{AvatarURL: req.AvatarURL})
+	if err != nil {
+		h.logger.Error("Failed to update avatar", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update avatar")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Avatar updated successfully",
+		"user":    updatedUser.ToPublic(),
+	})
+}
+
+// ListAttributes returns the tenant's custom attributes with the current user's values
+// so account pages can render them; only user_editable ones can be changed through Update
+func (h *MeHandler) ListAttributes(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	defs, err := h.attributeService.List(u.TenantID)
+	if err != nil {
+		h.logger.Error("Failed to list attributes", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve attributes")
+	}
+
+	attributes := make([]fiber.Map, len(defs))
+	for i, def := range defs {
+		attributes[i] = fiber.Map{
+			"key":           def.Key,
+			"display_name":  def.DisplayName,
+			"type":          def.Type,
+			"options":       def.Options,
+			"required":      def.Required,
+			"user_editable": def.UserEditable,
+			"value":         u.Attributes[def.Key],
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"attributes": attributes,
+	})
+}
+
+// ChangePassword changes the current user's password and signs out every session
+func (h *MeHandler) ChangePassword(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if err := h.userService.ChangePassword(u.ID, &req); err != nil {
+		h.logger.Warn("Failed to change password", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	h.revokeSessions(u.ID, "password change")
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
+// ChangeEmail starts an email change: the new address receives a confirmation link and
+// the current address a notice with a cancel link. Nothing changes until confirmation
+func (h *MeHandler) ChangeEmail(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+	if err := h.reauthenticate(u, req.Password, req.Code); err != nil {
+		return err
+	}
+	if req.NewEmail == u.Email {
+		return fiber.NewError(fiber.StatusBadRequest, "New email is the same as the current email")
+	}
+
+	if err := h.userService.CheckEmailAvailable(u.ID, req.NewEmail); err != nil {
+		if errors.Is(err, user.ErrEmailTaken) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		h.logger.Error("Failed to check email availability", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to change email")
+	}
+
+	change, err := h.emailRepo.CreateEmailChange(u.ID, u.Email, req.NewEmail)
+	if err != nil {
+		h.logger.Error("Failed to create email change", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to change email")
+	}
+
+	if err := h.emailSvc.SendEmailChangeConfirmation(change.NewEmail, change.Token); err != nil {
+		h.logger.Error("Failed to send email change confirmation", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send confirmation email")
+	}
+	if err := h.emailSvc.SendEmailChangeNotice(change.OldEmail, change.NewEmail, change.CancelToken); err != nil {
+		// The change can still be confirmed; the old address just misses the notice
+		h.logger.Error("Failed to send email change notice", zap.Error(err))
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":    "A confirmation link has been sent to the new email address",
+		"new_email":  change.NewEmail,
+		"expires_at": change.ExpiresAt,
+	})
+}
+
+// SendReauthCode emails a one-time code that accounts without a password use
+// in place of the password to confirm sensitive changes
+func (h *MeHandler) SendReauthCode(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	if u.HasPassword() {
+		return fiber.NewError(fiber.StatusBadRequest, "Confirm changes with your password")
+	}
+
+	login, code, err := h.emailRepo.CreateReauthCode(u.ID)
+	if err != nil {
+		h.logger.Error("Failed to create reauthentication code", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send verification code")
+	}
+	if err := h.emailSvc.SendReauthCodeEmail(u.Email, code); err != nil {
+		h.logger.Error("Failed to send reauthentication code", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to send verification code")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":    "A verification code has been sent to your email address",
+		"expires_at": login.ExpiresAt,
+	})
+}
+
+// ListIdentities lists the social identities linked to the current user
+func (h *MeHandler) ListIdentities(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"identities":   u.Identities(),
+		"has_password": u.HasPassword(),
+	})
+}
+
+// UnlinkIdentity removes a linked social identity from the current user
+func (h *MeHandler) UnlinkIdentity(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.UnlinkIdentityRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if err := h.reauthenticate(u, req.Password, req.Code); err != nil {
+		return err
+	}
+
+	if err := h.userService.UnlinkIdentity(u.ID, c.Params("provider")); err != nil {
+		switch {
+		case errors.Is(err, user.ErrUnknownProvider), errors.Is(err, user.ErrIdentityNotLinked):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case errors.Is(err, user.ErrLastCredential):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		h.logger.Error("Failed to unlink identity", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to unlink identity")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Identity unlinked successfully",
+	})
+}
+
+// ListSessions lists the current user's active login sessions
+func (h *MeHandler) ListSessions(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.activeSessions(u.ID)
+	if err != nil {
+		h.logger.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve sessions")
+	}
+
+	return c.JSON(fiber.Map{
+		"sessions": sessions,
+		"total":    len(sessions),
+	})
+}
+
+// RevokeSession signs out a single login session of the current user
+func (h *MeHandler) RevokeSession(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	sessionID := c.Params("id")
+
+	// Only sessions belonging to the token subject can be revoked
+	sessions, err := h.activeSessions(u.ID)
+	if err != nil {
+		h.logger.Error("Failed to list sessions", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+	}
+	owned := false
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return fiber.NewError(fiber.StatusNotFound, "Session not found")
+	}
+
+	if err := h.hydraClient.RevokeLoginSession(sessionID); err != nil {
+		h.logger.Error("Failed to revoke session", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke session")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeAllSessions signs out every session of the current user
+func (h *MeHandler) RevokeAllSessions(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.hydraClient.RevokeUserSessions(u.ID.String()); err != nil {
+		h.logger.Error("Failed to revoke sessions", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to revoke sessions")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "All sessions revoked successfully",
+	})
+}
+
+// DeleteAccount deletes the current user's account and signs out every session
+func (h *MeHandler) DeleteAccount(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req user.DeleteAccountRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if err := h.reauthenticate(u, req.Password, req.Code); err != nil {
+		return err
+	}
+
+	if err := h.userService.Delete(u.ID); err != nil {
+		h.logger.Error("Failed to delete account", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete account")
+	}
+
+	h.revokeSessions(u.ID, "account deletion")
+
+	return c.JSON(fiber.Map{
+		"message": "Account deleted successfully",
+	})
+}
+
+// ExportData downloads a zip archive of everything Authway stores about the current user
+func (h *MeHandler) ExportData(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+	return sendDataExport(c, h.privacyService, u, privacy.RequestedByUser, h.logger)
+}
+
+// GetErasure returns the current user's scheduled erasure
+func (h *MeHandler) GetErasure(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	erasure, err := h.privacyService.GetErasure(u.ID)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.JSON(erasure)
+}
+
+// RequestErasure schedules the erasure of the current user's account and data
+// Unlike DeleteAccount, nothing can be restored once the grace period is over
+func (h *MeHandler) RequestErasure(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	var req privacy.SelfErasureRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if err := h.reauthenticate(u, req.Password, req.Code); err != nil {
+		return err
+	}
+
+	erasure, err := h.privacyService.RequestErasure(u, "", privacy.RequestedByUser)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(erasure)
+}
+
+// CancelErasure cancels the current user's scheduled erasure during the grace period
+func (h *MeHandler) CancelErasure(c *fiber.Ctx) error {
+	u, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	cancelled, err := h.privacyService.CancelErasure(u.ID)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.JSON(cancelled)
+}
+
+// currentUser loads the token subject and checks it belongs to the token tenant
+func (h *MeHandler) currentUser(c *fiber.Ctx) (*user.User, error) {
+	userID, err := uuid.Parse(localUserID(c))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Token is not issued to a user")
+	}
+
+	u, err := h.userService.GetByID(userID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	if tenantID, _ := c.Locals("tenantID").(string); tenantID != u.TenantID.String() {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Access denied")
+	}
+	if !u.Active {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Account is inactive")
+	}
+
+	return u, nil
+}
+
+// reauthenticate confirms a sensitive change with the user's password, or for accounts without one
+// (SSO and passwordless users) with a code from SendReauthCode, so a bearer token alone is never enough
+func (h *MeHandler) reauthenticate(u *user.User, password, code string) error {
+	if u.HasPassword() {
+		if password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Password is required")
+		}
+		if !h.userService.VerifyPassword(u, password) {
+			return fiber.NewError(fiber.StatusUnauthorized, "Password is incorrect")
+		}
+		return nil
+	}
+
+	if code == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Verification code is required; request one at /me/reauthentication-code")
+	}
+	invalid := fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired verification code")
+	login, err := h.emailRepo.GetPendingReauthCode(u.ID)
+	if err != nil || !login.IsValid() {
+		return invalid
+	}
+	if !login.MatchesCode(code) {
+		if err := h.emailRepo.IncrementPasswordlessAttempts(login.ID); err != nil {
+			h.logger.Error("Failed to record reauthentication attempt", zap.Error(err))
+		}
+		return invalid
+	}
+	if err := h.emailRepo.ConsumePasswordlessLogin(login.ID); err != nil {
+		return invalid
+	}
+	return nil
+}
+
+// revokeSessions signs the user out everywhere; failures are logged but not returned
+// because the account change has already been applied
+func (h *MeHandler) revokeSessions(userID uuid.UUID, reason string) {
+	if err := h.hydraClient.RevokeUserSessions(userID.String()); err != nil {
+		h.logger.Error("Failed to revoke user sessions",
+			zap.String("user_id", userID.String()),
+			zap.String("reason", reason),
+			zap.Error(err))
+		return
+	}
+	h.logger.Info("Revoked all user sessions",
+		zap.String("user_id", userID.String()),
+		zap.String("reason", reason))
+}
+
+// activeSessions groups the user's Hydra consent sessions by login session
+func (h *MeHandler) activeSessions(userID uuid.UUID) ([]activeSession, error) {
+	consentSessions, err := h.hydraClient.ListConsentSessions(userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*activeSession{}
+	for _, cs := range consentSessions {
+		if cs.ConsentRequest == nil || cs.ConsentRequest.LoginSessionID == "" {
+			continue
+		}
+		session, ok := byID[cs.ConsentRequest.LoginSessionID]
+		if !ok {
+			session = &activeSession{ID: cs.ConsentRequest.LoginSessionID, Clients: []string{}}
+			byID[session.ID] = session
+		}
+		if cs.ConsentRequest.Client != nil {
+			session.Clients = append(session.Clients, cs.ConsentRequest.Client.ClientID)
+		}
+		if cs.HandledAt.After(session.LastUsedAt) {
+			session.LastUsedAt = cs.HandledAt
+		}
+	}
+
+	sessions := make([]activeSession, 0, len(byID))
+	for _, session := range byID {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
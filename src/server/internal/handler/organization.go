@@ -0,0 +1,477 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/invitation"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OrganizationHandler manages the organizations (customer accounts) of a tenant
+type OrganizationHandler struct {
+	orgService        organization.Service
+	invitationService invitation.Service
+	userService       user.Service
+	tenantService     *tenant.Service
+	emailSvc          *email.Service
+	validator         *validator.Validate
+	logger            *zap.Logger
+}
+
+func NewOrganizationHandler(
+	orgService organization.Service,
+	invitationService invitation.Service,
+	userService user.Service,
+	tenantService *tenant.Service,
+	emailSvc *email.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService:        orgService,
+		invitationService: invitationService,
+		userService:       userService,
+		tenantService:     tenantService,
+		emailSvc:          emailSvc,
+		validator:         validator,
+		logger:            logger,
+	}
+}
+
+// RegisterRoutes registers organization routes on an admin-protected group
+func (h *OrganizationHandler) RegisterRoutes(orgs fiber.Router) {
+	orgs.Post("/", h.Create)
+	orgs.Get("/", h.List)
+	orgs.Get("/:id", h.Get)
+	orgs.Put("/:id", h.Update)
+	orgs.Delete("/:id", h.Delete)
+
+	orgs.Get("/:id/members", h.ListMembers)
+	orgs.Post("/:id/members", h.AddMember)
+	orgs.Put("/:id/members/:user_id", h.UpdateMember)
+	orgs.Delete("/:id/members/:user_id", h.RemoveMember)
+
+	orgs.Get("/:id/domains", h.ListDomains)
+	orgs.Post("/:id/domains", h.AddDomain)
+	orgs.Post("/:id/domains/:domain_id/verify", h.VerifyDomain)
+	orgs.Delete("/:id/domains/:domain_id", h.RemoveDomain)
+
+	orgs.Post("/:id/invitations", h.Invite)
+}
+
+func (h *OrganizationHandler) Create(c *fiber.Ctx) error {
+	var req organization.CreateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	org, err := h.orgService.Create(tenantID, &req)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(org)
+}
+
+// List returns a tenant's organizations
+// GET /api/v1/organizations?tenant_id=...&limit=20&offset=0
+func (h *OrganizationHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	limit, offset := pagination(c)
+	orgs, total, err := h.orgService.List(tenantID, limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list organizations", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve organizations")
+	}
+
+	return c.JSON(fiber.Map{
+		"organizations": orgs,
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+func (h *OrganizationHandler) Get(c *fiber.Ctx) error {
+	org, err := h.organization(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(org)
+}
+
+func (h *OrganizationHandler) Update(c *fiber.Ctx) error {
+	org, err := h.organization(c)
+	if err != nil {
+		return err
+	}
+
+	var req organization.UpdateOrganizationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	// An empty value resets the override to the tenant's branding
+	if req.Logo != nil && *req.Logo != "" && h.validator.Var(*req.Logo, "url") != nil {
+		return validationFailed(c, FieldError{Field: "logo", Rule: "url", Message: "must be a valid URL"})
+	}
+	if req.PrimaryColor != nil && *req.PrimaryColor != "" && h.validator.Var(*req.PrimaryColor, "hexcolor") != nil {
+		return validationFailed(c, FieldError{Field: "primary_color", Rule: "hexcolor", Message: "must be a hex color"})
+	}
+
+	updated, err := h.orgService.Update(org.ID, &req)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.JSON(updated)
+}
+
+func (h *OrganizationHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+
+	if err := h.orgService.Delete(id); err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListMembers returns a page of the organization's members
+// GET /api/v1/organizations/:id/members?limit=20&offset=0
+func (h *OrganizationHandler) ListMembers(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+
+	limit, offset := pagination(c)
+	members, total, err := h.orgService.ListMembers(id, limit, offset)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"members": members,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// AddMember adds an existing tenant user to the organization
+func (h *OrganizationHandler) AddMember(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+
+	var req organization.AddMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	member, err := h.orgService.AddMember(id, uuid.MustParse(req.UserID), req.Role)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(member)
+}
+
+// UpdateMember changes a member's organization role
+func (h *OrganizationHandler) UpdateMember(c *fiber.Ctx) error {
+	id, userID, err := h.memberParams(c)
+	if err != nil {
+		return err
+	}
+
+	var req organization.UpdateMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	member, err := h.orgService.UpdateMemberRole(id, userID, req.Role)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.JSON(member)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *fiber.Ctx) error {
+	id, userID, err := h.memberParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.orgService.RemoveMember(id, userID); err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *OrganizationHandler) ListDomains(c *fiber.Ctx) error {
+	org, err := h.organization(c)
+	if err != nil {
+		return err
+	}
+
+	domains, err := h.orgService.ListDomains(org.ID)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.JSON(fiber.Map{
+		"domains": domains,
+		"total":   len(domains),
+	})
+}
+
+// AddDomain claims an email domain and returns the DNS record that proves ownership
+func (h *OrganizationHandler) AddDomain(c *fiber.Ctx) error {
+	org, err := h.organization(c)
+	if err != nil {
+		return err
+	}
+
+	var req organization.AddDomainRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	domain, err := h.orgService.AddDomain(org.ID, req.Domain)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"domain": domain,
+		"verification": fiber.Map{
+			"type":  "TXT",
+			"name":  domain.RecordName(),
+			"value": domain.RecordValue(),
+		},
+	})
+}
+
+// VerifyDomain checks the DNS record; verified domains add matching users to the organization at login
+func (h *OrganizationHandler) VerifyDomain(c *fiber.Ctx) error {
+	id, domainID, err := h.domainParams(c)
+	if err != nil {
+		return err
+	}
+
+	domain, err := h.orgService.VerifyDomain(id, domainID)
+	if err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.JSON(domain)
+}
+
+func (h *OrganizationHandler) RemoveDomain(c *fiber.Ctx) error {
+	id, domainID, err := h.domainParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.orgService.RemoveDomain(id, domainID); err != nil {
+		return h.organizationError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Invite invites a new user into the organization; existing tenant users are added as members directly
+// and the response holds the member instead of an invitation
+func (h *OrganizationHandler) Invite(c *fiber.Ctx) error {
+	org, err := h.organization(c)
+	if err != nil {
+		return err
+	}
+
+	var req organization.InviteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	role := req.Role
+	if role == "" {
+		role = organization.RoleMember
+	}
+
+	if existing, err := h.userService.GetByEmailAndTenant(org.TenantID, req.Email); err == nil {
+		member, err := h.orgService.AddMember(org.ID, existing.ID, role)
+		if err != nil {
+			return h.organizationError(err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"member": member,
+		})
+	}
+
+	inv, err := h.invitationService.CreateForOrganization(org.TenantID, org.ID, role, req.Email, req.InvitedBy, time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		if errors.Is(err, invitation.ErrAlreadyInvited) {
+			return fiber.NewError(fiber.StatusConflict, "This email already has a pending invitation")
+		}
+		h.logger.Error("Failed to create organization invitation", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create invitation")
+	}
+
+	if err := h.emailSvc.SendInvitationEmail(inv.Email, org.Name, inv.Token); err != nil {
+		h.logger.Error("Failed to send invitation email", zap.Error(err), zap.String("invitation_id", inv.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Invitation created but the email could not be sent; resend it later")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(inv.ToPublic())
+}
+
+// UserOrganizations returns the organizations a user belongs to
+// GET /api/v1/users/:id/organizations
+func (h *OrganizationHandler) UserOrganizations(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	if _, err := h.userService.GetByID(userID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	orgs, err := h.orgService.UserOrganizations(userID)
+	if err != nil {
+		h.logger.Error("Failed to list user organizations", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve organizations")
+	}
+
+	return c.JSON(fiber.Map{
+		"organizations": orgs,
+		"total":         len(orgs),
+	})
+}
+
+func (h *OrganizationHandler) organization(c *fiber.Ctx) (*organization.Organization, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+
+	org, err := h.orgService.Get(id)
+	if err != nil {
+		return nil, h.organizationError(err)
+	}
+	return org, nil
+}
+
+func (h *OrganizationHandler) memberParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+	return id, userID, nil
+}
+
+func (h *OrganizationHandler) domainParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid organization ID")
+	}
+	domainID, err := uuid.Parse(c.Params("domain_id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "Invalid domain ID")
+	}
+	return id, domainID, nil
+}
+
+func (h *OrganizationHandler) organizationError(err error) error {
+	switch {
+	case errors.Is(err, organization.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Organization not found")
+	case errors.Is(err, organization.ErrDuplicateSlug):
+		return fiber.NewError(fiber.StatusConflict, "An organization with this slug already exists")
+	case errors.Is(err, organization.ErrMemberNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "User is not a member of this organization")
+	case errors.Is(err, organization.ErrUserNotInTenant):
+		return fiber.NewError(fiber.StatusBadRequest, "User not found in this tenant")
+	case errors.Is(err, organization.ErrLastOwner):
+		return fiber.NewError(fiber.StatusConflict, "The organization must keep at least one owner")
+	case errors.Is(err, organization.ErrDomainNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Domain not found")
+	case errors.Is(err, organization.ErrDomainTaken):
+		return fiber.NewError(fiber.StatusConflict, "This domain is already claimed by an organization")
+	case errors.Is(err, organization.ErrDomainNotVerified):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Verification TXT record not found; DNS changes can take a while to propagate")
+	default:
+		h.logger.Error("Organization operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process organization")
+	}
+}
+
+// pagination reads limit (1-100, default 20) and offset query parameters
+func pagination(c *fiber.Ctx) (int, int) {
+	limit, err := strconv.Atoi(c.Query("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// PasswordlessHandler handles magic link and email one-time code login for Hydra login challenges
+type PasswordlessHandler struct {
+	userService   user.Service
+	clientService client.Service
+	tenantService *tenant.Service
+	emailRepo     *email.Repository
+	emailSvc      *email.Service
+	hydraClient   *hydra.Client
+	authHandler   *AuthHandler
+	sendLimiter   *sendLimiter
+	validator     *validator.Validate
+	logger        *zap.Logger
+}
+
+func NewPasswordlessHandler(
+	userService user.Service,
+	clientService client.Service,
+	tenantService *tenant.Service,
+	emailRepo *email.Repository,
+	emailSvc *email.Service,
+	hydraClient *hydra.Client,
+	authHandler *AuthHandler,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *PasswordlessHandler {
+	return &PasswordlessHandler{
+		userService:   userService,
+		clientService: clientService,
+		tenantService: tenantService,
+		emailRepo:     emailRepo,
+		emailSvc:      emailSvc,
+		hydraClient:   hydraClient,
+		authHandler:   authHandler,
+		sendLimiter:   newSendLimiter(),
+		validator:     validator,
+		logger:        logger,
+	}
+}
+
+// RegisterRoutes registers passwordless login routes
+func (h *PasswordlessHandler) RegisterRoutes(router fiber.Router) {
+	passwordless := router.Group("/passwordless")
+	passwordless.Get("/", h.Options)
+	passwordless.Post("/start", h.Start)
+	passwordless.Post("/verify", h.Verify)
+}
+
+// passwordlessClient loads the login request and OAuth client for a login challenge
+// and checks that the client's tenant has passwordless login enabled
+func (h *PasswordlessHandler) passwordlessClient(challenge string) (*hydra.LoginRequest, *client.Client, error) {
+	loginReq, err := h.hydraClient.GetLoginRequest(challenge)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid or expired login challenge")
+	}
+
+	requestedClient, err := h.clientService.GetByClientID(loginReq.Client.ClientID)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "OAuth client not registered in Authway")
+	}
+
+	// Enforce PKCE again in case the login page was bypassed
+	if !hasPKCEChallenge(requestedClient, loginReq) {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "This client requires PKCE with code_challenge_method=S256")
+	}
+
+	clientTenant, err := h.tenantService.GetTenantByID(requestedClient.TenantID)
+	if err != nil || !clientTenant.Active || !clientTenant.Settings.PasswordlessLogin {
+		return nil, nil, fiber.NewError(fiber.StatusForbidden, "Passwordless login is not enabled for this application")
+	}
+
+	return loginReq, requestedClient, nil
+}
+
+// Options reports whether passwordless login is available for a login challenge
+func (h *PasswordlessHandler) Options(c *fiber.Ctx) error {
+	challenge := c.Query("login_challenge")
+	if challenge == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "login_challenge parameter is required",
+		})
+	}
+
+	_, _, err := h.passwordlessClient(challenge)
+	enabled := err == nil
+
+	methods := []string{}
+	if enabled {
+		methods = []string{email.PasswordlessMethodLink, email.PasswordlessMethodCode}
+	}
+
+	return c.JSON(fiber.Map{
+		"enabled": enabled,
+		"methods": methods,
+	})
+}
+
+// Start emails a magic link or one-time code for the login challenge
+// The response does not reveal whether the email address is registered:
+// the rate limit applies to any address, and a challenge locked after too many wrong codes gets the usual response
+func (h *PasswordlessHandler) Start(c *fiber.Ctx) error {
+	var req email.StartPasswordlessRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	_, requestedClient, err := h.passwordlessClient(req.LoginChallenge)
+	if err != nil {
+		return err
+	}
+
+	emailAddress := strings.TrimSpace(req.Email)
+	if !h.sendLimiter.allow(requestedClient.TenantID.String()+":"+strings.ToLower(emailAddress), time.Now()) {
+		return fiber.NewError(fiber.StatusTooManyRequests, "Too many sign-in emails requested, please try again later")
+	}
+
+	sent := fiber.Map{
+		"message":    "If the email is registered, a sign-in email has been sent",
+		"method":     req.Method,
+		"expires_in": 600,
+	}
+
+	usr, err := h.userService.GetByEmailAndTenant(requestedClient.TenantID, emailAddress)
+	if err != nil || !usr.Active {
+		return c.Status(http.StatusAccepted).JSON(sent)
+	}
+
+	login, code, err := h.emailRepo.CreatePasswordlessLogin(usr.ID, req.LoginChallenge, req.Method)
+	if errors.Is(err, email.ErrPasswordlessLocked) {
+		h.logger.Warn("Passwordless login locked after too many wrong codes", zap.String("user_id", usr.ID.String()))
+		return c.Status(http.StatusAccepted).JSON(sent)
+	}
+	if err != nil {
+		h.logger.Error("Failed to create passwordless login", zap.Error(err))
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start passwordless login",
+		})
+	}
+
+	if req.Method == email.PasswordlessMethodCode {
+		err = h.emailSvc.SendLoginCodeEmail(usr.Email, code)
+	} else {
+		err = h.emailSvc.SendMagicLinkEmail(usr.Email, req.LoginChallenge, login.Token)
+	}
+	if err != nil {
+		h.logger.Error("Failed to send passwordless login email", zap.Error(err))
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to send sign-in email",
+		})
+	}
+
+	return c.Status(http.StatusAccepted).JSON(sent)
+}
+
+// Verify checks a magic link token or one-time code and accepts the login challenge it was issued for
+func (h *PasswordlessHandler) Verify(c *fiber.Ctx) error {
+	var req email.VerifyPasswordlessRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	invalid := fiber.NewError(fiber.StatusBadRequest, "Invalid or expired sign-in code")
+
+	var login *email.PasswordlessLogin
+	var err error
+	if req.Token != "" {
+		login, err = h.emailRepo.GetPasswordlessLoginByToken(req.Token)
+		if err != nil || login.Method != email.PasswordlessMethodLink {
+			return invalid
+		}
+	} else {
+		login, err = h.emailRepo.GetPendingPasswordlessLoginByChallenge(req.LoginChallenge)
+		if err != nil || login.Method != email.PasswordlessMethodCode {
+			return invalid
+		}
+	}
+
+	// The token or code only works for the login flow it was issued for
+	if !login.MatchesChallenge(req.LoginChallenge) || !login.IsValid() {
+		return invalid
+	}
+
+	if req.Token == "" && !login.MatchesCode(req.Code) {
+		if err := h.emailRepo.IncrementPasswordlessAttempts(login.ID); err != nil {
+			h.logger.Error("Failed to record passwordless attempt", zap.Error(err))
+		}
+		return invalid
+	}
+
+	loginReq, requestedClient, err := h.passwordlessClient(req.LoginChallenge)
+	if err != nil {
+		return err
+	}
+
+	usr, err := h.userService.GetByID(login.UserID)
+	if err != nil || !usr.Active || usr.TenantID != requestedClient.TenantID {
+		return invalid
+	}
+
+	if err := h.emailRepo.ConsumePasswordlessLogin(login.ID); err != nil {
+		return invalid
+	}
+
+	// Receiving the link or code proves ownership of the address
+	if !usr.EmailVerified {
+		if err := h.userService.UpdateEmailVerified(usr.ID, true); err != nil {
+			h.logger.Error("Failed to mark email as verified", zap.Error(err))
+		}
+	}
+	if err := h.userService.UpdateLastLogin(usr.ID); err != nil {
+		h.logger.Warn("Failed to update last login", zap.Error(err))
+	}
+
+	// Organization membership and the login context are handled like a password login
+	acceptBody := &hydra.AcceptLoginRequest{
+		Remember: req.Remember,
+		AMR:      []string{"email"},
+	}
+	return h.authHandler.acceptLogin(c, req.LoginChallenge, loginReq, requestedClient, usr, req.Organization, acceptBody)
+}
+
+// Start accepts passwordlessSendLimit sign-in emails per address within passwordlessSendWindow
+const (
+	passwordlessSendLimit  = 5
+	passwordlessSendWindow = 15 * time.Minute
+)
+
+// sendLimiter counts sign-in emails per tenant and address in fixed windows
+// Counts are kept in memory, so each instance applies the limit separately
+type sendLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*sendWindow
+}
+
+type sendWindow struct {
+	start time.Time
+	count int
+}
+
+func newSendLimiter() *sendLimiter {
+	return &sendLimiter{windows: make(map[string]*sendWindow)}
+}
+
+// allow records a send for key and reports whether it is within the limit
+func (l *sendLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop finished windows so the map does not grow with every address ever seen
+	for k, w := range l.windows {
+		if now.Sub(w.start) >= passwordlessSendWindow {
+			delete(l.windows, k)
+		}
+	}
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &sendWindow{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= passwordlessSendLimit {
+		return false
+	}
+	w.count++
+	return true
+}
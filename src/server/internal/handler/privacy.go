@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"authway/src/server/pkg/privacy"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// PrivacyHandler answers data subject requests (GDPR export and erasure) on behalf of administrators
+// Users make their own requests through MeHandler
+type PrivacyHandler struct {
+	privacyService privacy.Service
+	userService    user.Service
+	validator      *validator.Validate
+	logger         *zap.Logger
+}
+
+func NewPrivacyHandler(
+	privacyService privacy.Service,
+	userService user.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+		userService:    userService,
+		validator:      validator,
+		logger:         logger,
+	}
+}
+
+// RegisterRoutes registers privacy routes on the admin-protected users group
+func (h *PrivacyHandler) RegisterRoutes(users fiber.Router) {
+	users.Get("/:id/data-export", h.Export)
+	users.Get("/:id/privacy-requests", h.ListRequests)
+	users.Post("/:id/erasure", h.RequestErasure)
+	users.Delete("/:id/erasure", h.CancelErasure)
+}
+
+// Export downloads a zip archive of the user's data
+// GET /api/v1/users/:id/data-export
+func (h *PrivacyHandler) Export(c *fiber.Ctx) error {
+	u, err := h.user(c)
+	if err != nil {
+		return err
+	}
+	return sendDataExport(c, h.privacyService, u, privacy.RequestedByAdmin, h.logger)
+}
+
+// ListRequests returns the user's data subject requests
+func (h *PrivacyHandler) ListRequests(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	// Requests outlive erased users, so the user does not have to exist
+	requests, err := h.privacyService.ListRequests(id)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.JSON(fiber.Map{
+		"requests": requests,
+	})
+}
+
+// RequestErasure schedules the user's erasure after the configured grace period
+// POST /api/v1/users/:id/erasure {"mode": "delete|pseudonymize"}
+func (h *PrivacyHandler) RequestErasure(c *fiber.Ctx) error {
+	u, err := h.user(c)
+	if err != nil {
+		return err
+	}
+
+	var req privacy.ErasureRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	erasure, err := h.privacyService.RequestErasure(u, req.Mode, privacy.RequestedByAdmin)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(erasure)
+}
+
+// CancelErasure cancels the user's scheduled erasure
+func (h *PrivacyHandler) CancelErasure(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	cancelled, err := h.privacyService.CancelErasure(id)
+	if err != nil {
+		return privacyError(err, h.logger)
+	}
+	return c.JSON(cancelled)
+}
+
+func (h *PrivacyHandler) user(c *fiber.Ctx) (*user.User, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	u, err := h.userService.GetByID(id)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+	return u, nil
+}
+
+// sendDataExport builds the user's export archive and sends it as a download
+func sendDataExport(c *fiber.Ctx, privacyService privacy.Service, u *user.User, requestedBy string, logger *zap.Logger) error {
+	var archive bytes.Buffer
+	if err := privacyService.Export(&archive, u, requestedBy); err != nil {
+		logger.Error("Failed to export user data", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to export user data")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="authway-data-export-%s.zip"`, u.ID))
+	return c.Send(archive.Bytes())
+}
+
+func privacyError(err error, logger *zap.Logger) error {
+	switch {
+	case errors.Is(err, privacy.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "No erasure is scheduled for this user")
+	case errors.Is(err, privacy.ErrErasurePending):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, privacy.ErrInvalidMode):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		logger.Error("Privacy request failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process privacy request")
+	}
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"authway/src/server/pkg/attribute"
+	"authway/src/server/pkg/captcha"
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RegistrationHandler handles self-service sign-up according to the tenant's registration mode
+type RegistrationHandler struct {
+	userService      user.Service
+	tenantService    *tenant.Service
+	attributeService attribute.Service
+	emailRepo        *email.Repository
+	emailSvc         *email.Service
+	captcha          captcha.Verifier // nil disables CAPTCHA
+	validator        *validator.Validate
+	logger           *zap.Logger
+}
+
+func NewRegistrationHandler(
+	userService user.Service,
+	tenantService *tenant.Service,
+	attributeService attribute.Service,
+	emailRepo *email.Repository,
+	emailSvc *email.Service,
+	captchaVerifier captcha.Verifier,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *RegistrationHandler {
+	return &RegistrationHandler{
+		userService:      userService,
+		tenantService:    tenantService,
+		attributeService: attributeService,
+		emailRepo:        emailRepo,
+		emailSvc:         emailSvc,
+		captcha:          captchaVerifier,
+		validator:        validator,
+		logger:           logger,
+	}
+}
+
+type RegisterRequest struct {
+	TenantID     string `json:"tenant_id" validate:"required,uuid"`
+	Email        string `json:"email" validate:"required,email,max=255"`
+	Password     string `json:"password" validate:"required,min=8,max=72"`
+	Name         string `json:"name" validate:"max=255"`
+	CaptchaToken string `json:"captcha_token"`
+
+	// Attributes are values of the tenant's user-editable custom attributes; required ones must be set
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+func (h *RegistrationHandler) Register(c *fiber.Ctx) error {
+	var req RegisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	if h.captcha != nil {
+		if err := h.captcha.Verify(req.CaptchaToken, c.IP()); err != nil {
+			if !errors.Is(err, captcha.ErrVerificationFailed) {
+				h.logger.Error("CAPTCHA verification error", zap.Error(err))
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "CAPTCHA verification failed",
+				"code":  "captcha_failed",
+			})
+		}
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	registrationTenant, err := h.tenantService.GetTenantByID(tenantID)
+	if err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return validationFailed(c, FieldError{Field: "tenant_id", Rule: "exists", Message: "tenant does not exist"})
+		}
+		h.logger.Error("Failed to get tenant for registration", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+	if !registrationTenant.Active {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "This tenant is not accepting sign-ups",
+			"code":  "tenant_inactive",
+		})
+	}
+
+	settings := registrationTenant.Settings
+	switch settings.Registration() {
+	case tenant.RegistrationModeDisabled:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Registration is disabled for this tenant",
+			"code":  "registration_disabled",
+		})
+	case tenant.RegistrationModeInviteOnly:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "This tenant only accepts invited users",
+			"code":  "invitation_required",
+		})
+	case tenant.RegistrationModeDomainRestricted:
+		if !settings.AllowsEmailDomain(req.Email) {
+			return validationFailed(c, FieldError{Field: "email", Rule: "domain", Message: "email domain is not allowed for this tenant"})
+		}
+	}
+
+	if len(req.Password) < settings.PasswordMinLength {
+		return validationFailed(c, FieldError{
+			Field:   "password",
+			Rule:    "min",
+			Message: fmt.Sprintf("must be at least %d characters", settings.PasswordMinLength),
+		})
+	}
+
+	attributes, err := h.attributeService.Validate(tenantID, nil, req.Attributes, true)
+	if err != nil {
+		if fields, ok := attributeFieldErrors(err); ok {
+			return validationFailed(c, fields...)
+		}
+		h.logger.Error("Failed to validate attributes for registration", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	if _, err := h.userService.GetByEmailAndTenant(tenantID, req.Email); err == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "An account with this email already exists",
+			"code":  "email_taken",
+		})
+	}
+
+	createdUser, err := h.userService.Create(tenantID, &user.CreateUserRequest{
+		Email:      req.Email,
+		Password:   req.Password,
+		Name:       req.Name,
+		Attributes: attributes,
+	})
+	if err != nil {
+		h.logger.Error("Failed to create user", zap.Error(err))
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create user",
+		})
+	}
+
+	verificationSent := false
+	if settings.RequireEmailVerification {
+		verificationSent = h.sendVerificationEmail(createdUser)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"id":                      createdUser.ID,
+		"tenant_id":               createdUser.TenantID,
+		"email":                   createdUser.Email,
+		"name":                    createdUser.Name,
+		"verification_email_sent": verificationSent,
+	})
+}
+
+// sendVerificationEmail sends the sign-up verification email; failures are logged and
+// the user can request a new email through /api/email/send-verification
+func (h *RegistrationHandler) sendVerificationEmail(createdUser *user.User) bool {
+	verification, err := h.emailRepo.CreateVerification(createdUser.ID)
+	if err != nil {
+		h.logger.Error("Failed to create verification", zap.Error(err))
+		return false
+	}
+
+	if err := h.emailSvc.SendVerificationEmail(createdUser.Email, verification.Token); err != nil {
+		h.logger.Error("Failed to send verification email", zap.Error(err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"authway/src/server/pkg/attribute"
+	"authway/src/server/pkg/captcha"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRegistrationApp(t *testing.T, verifier captcha.Verifier) (*fiber.App, *tenant.Service) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&tenant.Tenant{}, &user.User{}, &attribute.Definition{}))
+
+	tenantService := tenant.NewService(db)
+	registrationHandler := NewRegistrationHandler(user.NewService(db, zap.NewNop()), tenantService, attribute.NewService(db, zap.NewNop()), nil, nil, verifier, NewValidator(), zap.NewNop())
+
+	app := fiber.New()
+	app.Post("/register", registrationHandler.Register)
+	return app, tenantService
+}
+
+func createRegistrationTenant(t *testing.T, tenantService *tenant.Service, slug string, settings tenant.TenantSettings) string {
+	created, err := tenantService.CreateTenant(tenant.CreateTenantRequest{Name: slug, Slug: slug, Settings: settings})
+	require.NoError(t, err)
+	return created.ID.String()
+}
+
+func postRegister(t *testing.T, app *fiber.App, body map[string]string) (int, map[string]interface{}) {
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/register", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return resp.StatusCode, result
+}
+
+func TestRegistrationHandler_Modes(t *testing.T) {
+	app, tenantService := setupRegistrationApp(t, nil)
+
+	open := createRegistrationTenant(t, tenantService, "open", tenant.TenantSettings{})
+	restricted := createRegistrationTenant(t, tenantService, "restricted", tenant.TenantSettings{
+		RegistrationMode: tenant.RegistrationModeDomainRestricted,
+		AllowedDomains:   []string{"example.com"},
+	})
+	inviteOnly := createRegistrationTenant(t, tenantService, "invite-only", tenant.TenantSettings{RegistrationMode: tenant.RegistrationModeInviteOnly})
+	disabled := createRegistrationTenant(t, tenantService, "disabled", tenant.TenantSettings{RegistrationMode: tenant.RegistrationModeDisabled})
+	strict := createRegistrationTenant(t, tenantService, "strict", tenant.TenantSettings{PasswordMinLength: 12})
+
+	tests := []struct {
+		name           string
+		tenantID       string
+		email          string
+		password       string
+		expectedStatus int
+		expectedCode   string
+		expectedField  string
+	}{
+		{name: "open", tenantID: open, email: "a@anywhere.dev", password: "password123", expectedStatus: fiber.StatusCreated},
+		{name: "duplicate email", tenantID: open, email: "a@anywhere.dev", password: "password123", expectedStatus: fiber.StatusConflict, expectedCode: "email_taken"},
+		{name: "allowed domain", tenantID: restricted, email: "b@Example.com", password: "password123", expectedStatus: fiber.StatusCreated},
+		{name: "other domain", tenantID: restricted, email: "b@other.com", password: "password123", expectedStatus: fiber.StatusBadRequest, expectedField: "email"},
+		{name: "invite only", tenantID: inviteOnly, email: "c@example.com", password: "password123", expectedStatus: fiber.StatusForbidden, expectedCode: "invitation_required"},
+		{name: "disabled", tenantID: disabled, email: "d@example.com", password: "password123", expectedStatus: fiber.StatusForbidden, expectedCode: "registration_disabled"},
+		{name: "tenant password policy", tenantID: strict, email: "e@example.com", password: "password123", expectedStatus: fiber.StatusBadRequest, expectedField: "password"},
+		{name: "unknown tenant", tenantID: "7b0e3a52-8c1f-4a51-9a3c-2f0b5d7c9e11", email: "f@example.com", password: "password123", expectedStatus: fiber.StatusBadRequest, expectedField: "tenant_id"},
+		{name: "invalid fields", tenantID: "not-a-uuid", email: "not-an-email", password: "short", expectedStatus: fiber.StatusBadRequest, expectedField: "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := postRegister(t, app, map[string]string{
+				"tenant_id": tt.tenantID,
+				"email":     tt.email,
+				"password":  tt.password,
+				"name":      "User",
+			})
+			assert.Equal(t, tt.expectedStatus, status)
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, body["code"])
+			}
+			if tt.expectedField != "" {
+				fields, ok := body["fields"].([]interface{})
+				require.True(t, ok, "expected field errors in %v", body)
+				var names []string
+				for _, field := range fields {
+					names = append(names, field.(map[string]interface{})["field"].(string))
+				}
+				assert.Contains(t, names, tt.expectedField)
+			}
+		})
+	}
+}
+
+func TestRegistrationHandler_Captcha(t *testing.T) {
+	app, tenantService := setupRegistrationApp(t, captcha.NewLocalVerifier("test-pass"))
+	tenantID := createRegistrationTenant(t, tenantService, "captcha", tenant.TenantSettings{})
+
+	status, body := postRegister(t, app, map[string]string{
+		"tenant_id": tenantID,
+		"email":     "bot@example.com",
+		"password":  "password123",
+	})
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	assert.Equal(t, "captcha_failed", body["code"])
+
+	status, _ = postRegister(t, app, map[string]string{
+		"tenant_id":     tenantID,
+		"email":         "human@example.com",
+		"password":      "password123",
+		"captcha_token": "test-pass",
+	})
+	assert.Equal(t, fiber.StatusCreated, status)
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"errors"
+
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RoleHandler manages tenant roles, their permissions and their assignments to users and groups
+type RoleHandler struct {
+	rbacService   rbac.Service
+	userService   user.Service
+	clientService client.Service
+	groupService  group.Service
+	tenantService *tenant.Service
+	validator     *validator.Validate
+	logger        *zap.Logger
+}
+
+func NewRoleHandler(
+	rbacService rbac.Service,
+	userService user.Service,
+	clientService client.Service,
+	groupService group.Service,
+	tenantService *tenant.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+) *RoleHandler {
+	return &RoleHandler{
+		rbacService:   rbacService,
+		userService:   userService,
+		clientService: clientService,
+		groupService:  groupService,
+		tenantService: tenantService,
+		validator:     validator,
+		logger:        logger,
+	}
+}
+
+// RegisterRoutes registers role routes on an admin-protected group
+func (h *RoleHandler) RegisterRoutes(roles fiber.Router) {
+	roles.Post("/", h.Create)
+	roles.Get("/", h.List)
+	roles.Get("/:id", h.Get)
+	roles.Put("/:id", h.Update)
+	roles.Delete("/:id", h.Delete)
+	roles.Get("/:id/assignments", h.ListAssignments)
+	roles.Post("/:id/assignments", h.Assign)
+	roles.Delete("/:id/assignments/:subject_type/:subject_id", h.Unassign)
+}
+
+func (h *RoleHandler) Create(c *fiber.Ctx) error {
+	var req rbac.CreateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	// Client roles must belong to a client of the same tenant
+	if req.ClientID != "" {
+		roleClient, err := h.clientService.GetByClientID(req.ClientID)
+		if err != nil || roleClient.TenantID != tenantID {
+			return fiber.NewError(fiber.StatusBadRequest, "Client not found in this tenant")
+		}
+	}
+
+	role, err := h.rbacService.CreateRole(tenantID, &req)
+	if err != nil {
+		return h.roleError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(role)
+}
+
+// List returns a tenant's roles
+// GET /api/v1/roles?tenant_id=...&client_id=...
+func (h *RoleHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	roles, err := h.rbacService.ListRoles(tenantID, c.Query("client_id"))
+	if err != nil {
+		h.logger.Error("Failed to list roles", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve roles")
+	}
+
+	return c.JSON(fiber.Map{
+		"roles": roles,
+		"total": len(roles),
+	})
+}
+
+func (h *RoleHandler) Get(c *fiber.Ctx) error {
+	role, err := h.role(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(role)
+}
+
+func (h *RoleHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid role ID")
+	}
+
+	var req rbac.UpdateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	role, err := h.rbacService.UpdateRole(id, &req)
+	if err != nil {
+		return h.roleError(err)
+	}
+
+	return c.JSON(role)
+}
+
+func (h *RoleHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid role ID")
+	}
+
+	if err := h.rbacService.DeleteRole(id); err != nil {
+		return h.roleError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoleHandler) ListAssignments(c *fiber.Ctx) error {
+	role, err := h.role(c)
+	if err != nil {
+		return err
+	}
+
+	assignments, err := h.rbacService.ListAssignments(role.ID)
+	if err != nil {
+		h.logger.Error("Failed to list role assignments", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve role assignments")
+	}
+
+	return c.JSON(fiber.Map{
+		"assignments": assignments,
+		"total":       len(assignments),
+	})
+}
+
+// Assign grants the role to a user or group of the role's tenant
+func (h *RoleHandler) Assign(c *fiber.Ctx) error {
+	role, err := h.role(c)
+	if err != nil {
+		return err
+	}
+
+	var req rbac.AssignRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	subjectID := uuid.MustParse(req.SubjectID)
+	if err := h.checkSubject(role, req.SubjectType, subjectID); err != nil {
+		return err
+	}
+
+	if err := h.rbacService.Assign(role.ID, req.SubjectType, subjectID); err != nil {
+		return h.roleError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"role_id":      role.ID,
+		"subject_type": req.SubjectType,
+		"subject_id":   subjectID,
+	})
+}
+
+func (h *RoleHandler) Unassign(c *fiber.Ctx) error {
+	roleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid role ID")
+	}
+
+	subjectID, err := uuid.Parse(c.Params("subject_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid subject ID")
+	}
+
+	if err := h.rbacService.Unassign(roleID, c.Params("subject_type"), subjectID); err != nil {
+		return h.roleError(err)
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UserRoles returns the roles assigned directly to a user
+// GET /api/v1/users/:id/roles
+func (h *RoleHandler) UserRoles(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	if _, err := h.userService.GetByID(userID); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	roles, err := h.rbacService.SubjectRoles(rbac.SubjectUser, userID)
+	if err != nil {
+		h.logger.Error("Failed to list user roles", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve roles")
+	}
+
+	return c.JSON(fiber.Map{
+		"roles": roles,
+		"total": len(roles),
+	})
+}
+
+// checkSubject verifies that the user or group exists in the role's tenant
+func (h *RoleHandler) checkSubject(role *rbac.Role, subjectType string, subjectID uuid.UUID) error {
+	switch subjectType {
+	case rbac.SubjectUser:
+		subject, err := h.userService.GetByID(subjectID)
+		if err != nil || subject.TenantID != role.TenantID {
+			return fiber.NewError(fiber.StatusBadRequest, "User not found in this tenant")
+		}
+	case rbac.SubjectGroup:
+		subject, err := h.groupService.Get(subjectID)
+		if err != nil || subject.TenantID != role.TenantID {
+			return fiber.NewError(fiber.StatusBadRequest, "Group not found in this tenant")
+		}
+	}
+	return nil
+}
+
+func (h *RoleHandler) role(c *fiber.Ctx) (*rbac.Role, error) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid role ID")
+	}
+
+	role, err := h.rbacService.GetRole(id)
+	if err != nil {
+		return nil, h.roleError(err)
+	}
+	return role, nil
+}
+
+func (h *RoleHandler) roleError(err error) error {
+	switch {
+	case errors.Is(err, rbac.ErrRoleNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Role not found")
+	case errors.Is(err, rbac.ErrDuplicateRole):
+		return fiber.NewError(fiber.StatusConflict, "A role with this name already exists")
+	case errors.Is(err, rbac.ErrAssignmentNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Role is not assigned to this subject")
+	default:
+		h.logger.Error("Role operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process role")
+	}
+}
@@ -0,0 +1,441 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/internal/service/sso"
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/samlsp"
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// samlIdPStateData stores a SAML login in progress while the user signs in through Hydra
+type samlIdPStateData struct {
+	ServiceProviderID uuid.UUID
+	RequestID         string
+	ACSURL            string
+	RelayState        string
+	CodeVerifier      string
+	CreatedAt         time.Time
+}
+
+// samlIdPStateStore is a thread-safe in-memory store for SAML IdP login state
+// In production, use Redis or similar distributed cache
+var samlIdPStateStore sync.Map
+
+// cleanExpiredSAMLIdPStates removes SAML IdP login states older than ssoStateTTL
+func cleanExpiredSAMLIdPStates() {
+	now := time.Now()
+	samlIdPStateStore.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(*samlIdPStateData).CreatedAt) > ssoStateTTL {
+			samlIdPStateStore.Delete(key)
+		}
+		return true
+	})
+}
+
+// samlIdPEndpoints are a tenant's SAML identity provider URLs
+type samlIdPEndpoints struct {
+	EntityID    string
+	SSOURL      string
+	MetadataURL string
+}
+
+// samlIdentityProvider returns Authway's SAML IdP identity for a tenant
+func samlIdentityProvider(baseURL string, tenantID uuid.UUID) samlIdPEndpoints {
+	prefix := strings.TrimSuffix(baseURL, "/") + "/saml/idp/" + tenantID.String()
+	return samlIdPEndpoints{
+		EntityID:    prefix,
+		SSOURL:      prefix + "/sso",
+		MetadataURL: prefix + "/metadata",
+	}
+}
+
+// SAMLIdPCallbackPath is where Hydra returns SAML logins; it is the redirect URI of every backing client
+const SAMLIdPCallbackPath = "/saml/idp/callback"
+
+// SAMLIdPHandler lets registered SAML service providers sign users in (IdP mode)
+// AuthnRequests are turned into an authorization code flow of the provider's backing OAuth client,
+// so the user goes through the regular login page, MFA and Hydra's SSO session
+type SAMLIdPHandler struct {
+	spService        samlsp.Service
+	identityProvider *sso.IdentityProvider
+	tenantService    *tenant.Service
+	clientService    client.Service
+	userService      user.Service
+	hydraClient      *hydra.Client
+	httpClient       *http.Client
+	logger           *zap.Logger
+	baseURL          string
+	hydraPublicURL   string
+}
+
+func NewSAMLIdPHandler(
+	spService samlsp.Service,
+	identityProvider *sso.IdentityProvider,
+	tenantService *tenant.Service,
+	clientService client.Service,
+	userService user.Service,
+	hydraClient *hydra.Client,
+	logger *zap.Logger,
+	baseURL string,
+	hydraPublicURL string,
+) *SAMLIdPHandler {
+	return &SAMLIdPHandler{
+		spService:        spService,
+		identityProvider: identityProvider,
+		tenantService:    tenantService,
+		clientService:    clientService,
+		userService:      userService,
+		hydraClient:      hydraClient,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		logger:           logger,
+		baseURL:          strings.TrimSuffix(baseURL, "/"),
+		hydraPublicURL:   strings.TrimSuffix(hydraPublicURL, "/"),
+	}
+}
+
+// RegisterRoutes registers SAML IdP routes
+func (h *SAMLIdPHandler) RegisterRoutes(router fiber.Router) {
+	idp := router.Group("/saml/idp")
+	idp.Get("/callback", h.Callback)
+	idp.Get("/:tenant/metadata", h.Metadata)
+	idp.Get("/:tenant/sso", h.SSO)  // HTTP-Redirect binding
+	idp.Post("/:tenant/sso", h.SSO) // HTTP-POST binding
+}
+
+// activeTenant resolves the tenant of an IdP URL
+func (h *SAMLIdPHandler) activeTenant(c *fiber.Ctx) (uuid.UUID, error) {
+	tenantID, err := uuid.Parse(c.Params("tenant"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+	}
+	found, err := h.tenantService.GetTenantByID(tenantID)
+	if err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return uuid.Nil, fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return uuid.Nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+	if !found.Active {
+		return uuid.Nil, fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+	}
+	return tenantID, nil
+}
+
+// Metadata publishes the tenant's IdP metadata for service providers to import
+// GET /saml/idp/:tenant/metadata
+func (h *SAMLIdPHandler) Metadata(c *fiber.Ctx) error {
+	tenantID, err := h.activeTenant(c)
+	if err != nil {
+		return err
+	}
+
+	idp := samlIdentityProvider(h.baseURL, tenantID)
+	metadata, err := h.identityProvider.Metadata(idp.EntityID, idp.SSOURL)
+	if err != nil {
+		h.logger.Error("Failed to render SAML IdP metadata", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to render metadata")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/samlmetadata+xml")
+	return c.Send(metadata)
+}
+
+// SSO accepts an AuthnRequest and starts the login of the service provider's backing client
+// GET|POST /saml/idp/:tenant/sso
+func (h *SAMLIdPHandler) SSO(c *fiber.Ctx) error {
+	tenantID, err := h.activeTenant(c)
+	if err != nil {
+		return err
+	}
+
+	// IMPORTANT: Make copies because Fiber reuses internal buffers
+	samlRequest := string([]byte(c.Query("SAMLRequest")))
+	relayState := string([]byte(c.Query("RelayState")))
+	deflated := true
+	if c.Method() == fiber.MethodPost {
+		samlRequest = string([]byte(c.FormValue("SAMLRequest")))
+		relayState = string([]byte(c.FormValue("RelayState")))
+		deflated = false
+	}
+	if samlRequest == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing SAMLRequest parameter")
+	}
+
+	authnRequest, err := sso.ParseAuthnRequest(samlRequest, deflated)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	sp, err := h.spService.GetByEntityID(tenantID, authnRequest.Issuer)
+	if err != nil {
+		if errors.Is(err, samlsp.ErrNotFound) {
+			return fiber.NewError(fiber.StatusForbidden, "Service provider is not registered")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve service provider")
+	}
+	if !sp.Enabled {
+		return fiber.NewError(fiber.StatusForbidden, "Service provider is disabled")
+	}
+
+	// AuthnRequests are unsigned: assertions only ever go to a registered ACS URL
+	acsURL, err := sp.ACSURL(authnRequest.ACSURL)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Assertion consumer service URL is not registered for this service provider")
+	}
+
+	backing, err := h.clientService.GetByID(sp.ClientID)
+	if err != nil {
+		h.logger.Error("Backing client of SAML service provider is missing", zap.Error(err), zap.String("sp_id", sp.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Service provider is misconfigured")
+	}
+
+	stateData := &samlIdPStateData{
+		ServiceProviderID: sp.ID,
+		RequestID:         authnRequest.ID,
+		ACSURL:            acsURL,
+		RelayState:        relayState,
+		CreatedAt:         time.Now(),
+	}
+	state, err := randomToken()
+	if err == nil {
+		stateData.CodeVerifier, err = randomToken()
+	}
+	if err != nil {
+		h.logger.Error("Failed to generate SAML login state", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start login")
+	}
+
+	params := url.Values{}
+	params.Set("client_id", backing.ClientID)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(backing.Scopes, " "))
+	params.Set("redirect_uri", h.baseURL+SAMLIdPCallbackPath)
+	params.Set("state", state)
+	params.Set("code_challenge", sso.CodeChallenge(stateData.CodeVerifier))
+	params.Set("code_challenge_method", "S256")
+	if authnRequest.ForceAuthn {
+		params.Set("prompt", "login")
+	}
+
+	cleanExpiredSAMLIdPStates()
+	samlIdPStateStore.Store(state, stateData)
+
+	// Bind the state to this browser for CSRF protection
+	c.Cookie(&fiber.Cookie{
+		Name:     "saml_idp_state",
+		Value:    state,
+		Path:     "/saml/idp",
+		MaxAge:   int(ssoStateTTL.Seconds()),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+	})
+
+	h.logger.Info("SAML login started",
+		zap.String("sp_id", sp.ID.String()),
+		zap.String("entity_id", sp.EntityID),
+		zap.String("tenant_id", tenantID.String()))
+
+	return c.Redirect(h.hydraPublicURL+"/oauth2/auth?"+params.Encode(), fiber.StatusFound)
+}
+
+// Callback issues the signed assertion once Hydra has authenticated the user
+// GET /saml/idp/callback
+func (h *SAMLIdPHandler) Callback(c *fiber.Ctx) error {
+	// IMPORTANT: Make copies of query strings because Fiber reuses internal buffers
+	code := string([]byte(c.Query("code")))
+	state := string([]byte(c.Query("state")))
+
+	if errorParam := c.Query("error"); errorParam != "" {
+		h.logger.Warn("SAML login was not completed", zap.String("error", errorParam))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":             errorParam,
+			"error_description": c.Query("error_description"),
+		})
+	}
+
+	if code == "" || state == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing code or state parameter")
+	}
+
+	if c.Cookies("saml_idp_state") != state {
+		return fiber.NewError(fiber.StatusBadRequest, "State parameter does not match")
+	}
+
+	value, found := samlIdPStateStore.LoadAndDelete(state)
+	c.ClearCookie("saml_idp_state")
+	if !found || time.Since(value.(*samlIdPStateData).CreatedAt) > ssoStateTTL {
+		return fiber.NewError(fiber.StatusBadRequest, "Login state not found or expired, please restart the login")
+	}
+	stateData := value.(*samlIdPStateData)
+
+	sp, err := h.spService.Get(stateData.ServiceProviderID)
+	if err != nil || !sp.Enabled {
+		return fiber.NewError(fiber.StatusForbidden, "Service provider is not available")
+	}
+	backing, err := h.clientService.GetByID(sp.ClientID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Service provider is misconfigured")
+	}
+
+	accessToken, err := h.exchangeCode(c.Context(), backing, code, stateData.CodeVerifier)
+	if err != nil {
+		h.logger.Error("Failed to exchange authorization code for SAML login", zap.Error(err), zap.String("sp_id", sp.ID.String()))
+		return fiber.NewError(fiber.StatusBadGateway, "Failed to complete login, please restart the login")
+	}
+
+	// The access token carries the claims released by consent; introspection also confirms the subject
+	token, err := h.hydraClient.IntrospectToken(accessToken)
+	if err != nil || !token.Active || token.ClientID != backing.ClientID {
+		h.logger.Error("Failed to introspect SAML login token", zap.Error(err), zap.String("sp_id", sp.ID.String()))
+		return fiber.NewError(fiber.StatusBadGateway, "Failed to complete login, please restart the login")
+	}
+
+	userID, err := uuid.Parse(token.Subject)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Invalid subject")
+	}
+	usr, err := h.userService.GetByID(userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "User not found")
+	}
+	if !usr.Active || usr.TenantID != sp.TenantID {
+		return fiber.NewError(fiber.StatusForbidden, "Account is not allowed to sign in to this application")
+	}
+
+	nameID, nameIDFormat, err := samlNameID(sp, usr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to issue assertion")
+	}
+	sessionIndex, err := randomToken()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to issue assertion")
+	}
+
+	claims := map[string]interface{}{}
+	for name, value := range token.Extra {
+		claims[name] = value
+	}
+	claims["sub"] = token.Subject
+
+	idp := samlIdentityProvider(h.baseURL, sp.TenantID)
+	response, err := h.identityProvider.Response(&sso.ResponseParams{
+		Issuer:       idp.EntityID,
+		Audience:     sp.EntityID,
+		ACSURL:       stateData.ACSURL,
+		InResponseTo: stateData.RequestID,
+		NameID:       nameID,
+		NameIDFormat: nameIDFormat,
+		SessionIndex: "_" + sessionIndex,
+		Attributes:   samlAttributes(sp.ReleasedAttributes(), claims),
+	})
+	if err != nil {
+		h.logger.Error("Failed to issue SAML assertion", zap.Error(err), zap.String("sp_id", sp.ID.String()))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to issue assertion")
+	}
+
+	h.logger.Info("SAML assertion issued",
+		zap.String("user_id", usr.ID.String()),
+		zap.String("sp_id", sp.ID.String()),
+		zap.String("tenant_id", sp.TenantID.String()))
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return sso.PostForm(c, stateData.ACSURL, "SAMLResponse", response, stateData.RelayState)
+}
+
+// exchangeCode redeems the authorization code of a backing client at Hydra's token endpoint
+func (h *SAMLIdPHandler) exchangeCode(ctx context.Context, backing *client.Client, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", h.baseURL+SAMLIdPCallbackPath)
+	form.Set("client_id", backing.ClientID)
+	form.Set("client_secret", backing.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.hydraPublicURL+"/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.AccessToken == "" {
+		return "", errors.New("token response has no access token")
+	}
+	return tokens.AccessToken, nil
+}
+
+// samlNameID returns the subject identifier of a user for a service provider
+// Persistent IDs are derived per service provider so applications cannot correlate users
+func samlNameID(sp *samlsp.ServiceProvider, usr *user.User) (string, string, error) {
+	switch sp.NameIDFormat {
+	case samlsp.NameIDEmail:
+		return usr.Email, sso.NameIDFormatEmail, nil
+	case samlsp.NameIDTransient:
+		id, err := randomToken()
+		return "_" + id, sso.NameIDFormatTransient, err
+	case samlsp.NameIDUnspecified:
+		return usr.ID.String(), sso.NameIDFormatUnspecified, nil
+	default:
+		return uuid.NewSHA1(sp.ID, []byte(usr.ID.String())).String(), sso.NameIDFormatPersistent, nil
+	}
+}
+
+// samlAttributes renders the mapped claims as SAML attribute values
+// Claims missing from the token (e.g. no roles assigned) are left out
+func samlAttributes(mapping samlsp.AttributeMapping, claims map[string]interface{}) map[string][]string {
+	attributes := map[string][]string{}
+	for name, claim := range mapping {
+		var values []string
+		switch v := claims[claim].(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+		case []string:
+			values = v
+		case float64:
+			values = []string{fmt.Sprint(int64(v))}
+		default:
+			values = []string{fmt.Sprint(v)}
+		}
+		if len(values) > 0 {
+			attributes[name] = values
+		}
+	}
+	return attributes
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"errors"
+
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/samlsp"
+	"authway/src/server/pkg/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// SAMLServiceProviderHandler manages tenants' SAML service provider registrations
+type SAMLServiceProviderHandler struct {
+	spService     samlsp.Service
+	tenantService *tenant.Service
+	validator     *validator.Validate
+	logger        *zap.Logger
+	baseURL       string
+}
+
+func NewSAMLServiceProviderHandler(
+	spService samlsp.Service,
+	tenantService *tenant.Service,
+	validator *validator.Validate,
+	logger *zap.Logger,
+	baseURL string,
+) *SAMLServiceProviderHandler {
+	return &SAMLServiceProviderHandler{
+		spService:     spService,
+		tenantService: tenantService,
+		validator:     validator,
+		logger:        logger,
+		baseURL:       baseURL,
+	}
+}
+
+// RegisterRoutes registers SAML service provider routes on an admin-protected group
+func (h *SAMLServiceProviderHandler) RegisterRoutes(sps fiber.Router) {
+	sps.Post("/", h.Create)
+	sps.Get("/", h.List)
+	sps.Get("/:id", h.Get)
+	sps.Put("/:id", h.Update)
+	sps.Delete("/:id", h.Delete)
+}
+
+// Create registers a SAML service provider and its backing OAuth client
+// The response includes the tenant's IdP settings to configure in the application
+func (h *SAMLServiceProviderHandler) Create(c *fiber.Ctx) error {
+	var req samlsp.CreateServiceProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	created, err := h.spService.Create(tenantID, &req)
+	if err != nil {
+		return h.serviceProviderError(err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(h.withIdP(created))
+}
+
+// List returns a tenant's SAML service providers
+// GET /api/v1/saml-service-providers?tenant_id=...
+func (h *SAMLServiceProviderHandler) List(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	sps, err := h.spService.List(tenantID)
+	if err != nil {
+		h.logger.Error("Failed to list SAML service providers", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve SAML service providers")
+	}
+
+	return c.JSON(fiber.Map{
+		"service_providers": sps,
+		"total":             len(sps),
+	})
+}
+
+func (h *SAMLServiceProviderHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid service provider ID")
+	}
+
+	found, err := h.spService.Get(id)
+	if err != nil {
+		return h.serviceProviderError(err)
+	}
+	return c.JSON(h.withIdP(found))
+}
+
+func (h *SAMLServiceProviderHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid service provider ID")
+	}
+
+	var req samlsp.UpdateServiceProviderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	updated, err := h.spService.Update(id, &req)
+	if err != nil {
+		return h.serviceProviderError(err)
+	}
+	return c.JSON(h.withIdP(updated))
+}
+
+func (h *SAMLServiceProviderHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid service provider ID")
+	}
+
+	if err := h.spService.Delete(id); err != nil {
+		return h.serviceProviderError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// withIdP adds the tenant's IdP endpoints to a service provider
+func (h *SAMLServiceProviderHandler) withIdP(sp *samlsp.ServiceProvider) fiber.Map {
+	idp := samlIdentityProvider(h.baseURL, sp.TenantID)
+	return fiber.Map{
+		"service_provider": sp,
+		"idp": fiber.Map{
+			"entity_id":    idp.EntityID,
+			"sso_url":      idp.SSOURL,
+			"metadata_url": idp.MetadataURL,
+		},
+	}
+}
+
+func (h *SAMLServiceProviderHandler) serviceProviderError(err error) error {
+	var policyErr *client.PolicyError
+	switch {
+	case errors.Is(err, samlsp.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "SAML service provider not found")
+	case errors.Is(err, samlsp.ErrDuplicateEntityID):
+		return fiber.NewError(fiber.StatusConflict, "A SAML service provider with this entity ID already exists")
+	case errors.As(err, &policyErr):
+		// The tenant's OAuth policy must allow the scopes behind the mapped attributes
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("SAML service provider operation failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process SAML service provider")
+	}
+}
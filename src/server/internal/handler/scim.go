@@ -0,0 +1,437 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/scim"
+	"authway/src/server/pkg/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// SCIMBasePath is where the SCIM 2.0 service provider is served
+const SCIMBasePath = "/scim/v2"
+
+// scimTenantKey holds the tenant of the authenticated SCIM token in the request locals
+const scimTenantKey = "scimTenantID"
+
+// SCIMHandler serves SCIM 2.0 provisioning to tenants' IdPs and manages their tokens
+type SCIMHandler struct {
+	scimService   scim.Service
+	tenantService *tenant.Service
+	hydraClient   *hydra.Client
+	validator     *validator.Validate
+	logger        *zap.Logger
+	baseURL       string
+}
+
+func NewSCIMHandler(
+	scimService scim.Service,
+	tenantService *tenant.Service,
+	hydraClient *hydra.Client,
+	validator *validator.Validate,
+	logger *zap.Logger,
+	baseURL string,
+) *SCIMHandler {
+	return &SCIMHandler{
+		scimService:   scimService,
+		tenantService: tenantService,
+		hydraClient:   hydraClient,
+		validator:     validator,
+		logger:        logger,
+		baseURL:       strings.TrimSuffix(baseURL, "/") + SCIMBasePath,
+	}
+}
+
+// RegisterRoutes registers the SCIM endpoints; discovery is public, resources need a tenant's token
+func (h *SCIMHandler) RegisterRoutes(router fiber.Router) {
+	router.Get("/ServiceProviderConfig", h.ServiceProviderConfig)
+	router.Get("/ResourceTypes", h.ResourceTypes)
+	router.Get("/ResourceTypes/:id", h.ResourceTypes)
+	router.Get("/Schemas", h.Schemas)
+	router.Get("/Schemas/:id", h.Schemas)
+
+	router.Get("/Users", h.authenticate, h.ListUsers)
+	router.Post("/Users", h.authenticate, h.CreateUser)
+	router.Get("/Users/:id", h.authenticate, h.GetUser)
+	router.Put("/Users/:id", h.authenticate, h.ReplaceUser)
+	router.Patch("/Users/:id", h.authenticate, h.PatchUser)
+	router.Delete("/Users/:id", h.authenticate, h.DeleteUser)
+
+	router.Get("/Groups", h.authenticate, h.ListGroups)
+	router.Post("/Groups", h.authenticate, h.CreateGroup)
+	router.Get("/Groups/:id", h.authenticate, h.GetGroup)
+	router.Put("/Groups/:id", h.authenticate, h.ReplaceGroup)
+	router.Patch("/Groups/:id", h.authenticate, h.PatchGroup)
+	router.Delete("/Groups/:id", h.authenticate, h.DeleteGroup)
+}
+
+// RegisterAdminRoutes registers SCIM token management routes on an admin-protected group
+func (h *SCIMHandler) RegisterAdminRoutes(tokens fiber.Router) {
+	tokens.Post("/", h.CreateToken)
+	tokens.Get("/", h.ListTokens)
+	tokens.Delete("/:id", h.DeleteToken)
+}
+
+// CreateToken issues a SCIM token for a tenant; the token is only returned in this response
+func (h *SCIMHandler) CreateToken(c *fiber.Ctx) error {
+	var req scim.CreateTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	tenantID := uuid.MustParse(req.TenantID)
+	if _, err := h.tenantService.GetTenantByID(tenantID); err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Tenant not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve tenant")
+	}
+
+	token, plain, err := h.scimService.CreateToken(tenantID, &req)
+	if err != nil {
+		h.logger.Error("Failed to create SCIM token", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create SCIM token")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"token":    plain,
+		"scim_url": h.baseURL,
+		"details":  token,
+	})
+}
+
+// ListTokens returns a tenant's SCIM tokens without their secrets
+// GET /api/v1/scim-tokens?tenant_id=...
+func (h *SCIMHandler) ListTokens(c *fiber.Ctx) error {
+	tenantID, err := uuid.Parse(c.Query("tenant_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Valid tenant_id query parameter is required")
+	}
+
+	tokens, err := h.scimService.ListTokens(tenantID)
+	if err != nil {
+		h.logger.Error("Failed to list SCIM tokens", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve SCIM tokens")
+	}
+
+	return c.JSON(fiber.Map{
+		"tokens": tokens,
+		"total":  len(tokens),
+	})
+}
+
+func (h *SCIMHandler) DeleteToken(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid token ID")
+	}
+
+	if err := h.scimService.DeleteToken(id); err != nil {
+		if errors.Is(err, scim.ErrTokenNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "SCIM token not found")
+		}
+		h.logger.Error("Failed to delete SCIM token", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete SCIM token")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// authenticate resolves the tenant of the request's SCIM bearer token
+func (h *SCIMHandler) authenticate(c *fiber.Ctx) error {
+	plain, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return h.scimError(c, fiber.StatusUnauthorized, "", "Bearer token required")
+	}
+
+	token, err := h.scimService.Authenticate(strings.TrimSpace(plain))
+	if err != nil {
+		if errors.Is(err, scim.ErrInvalidToken) {
+			return h.scimError(c, fiber.StatusUnauthorized, "", "Invalid or expired token")
+		}
+		h.logger.Error("Failed to authenticate SCIM token", zap.Error(err))
+		return h.scimError(c, fiber.StatusInternalServerError, "", "Failed to authenticate")
+	}
+
+	c.Locals(scimTenantKey, token.TenantID)
+	return c.Next()
+}
+
+func (h *SCIMHandler) ServiceProviderConfig(c *fiber.Ctx) error {
+	return h.respond(c, fiber.StatusOK, scim.ServiceProviderConfig(h.baseURL))
+}
+
+// ResourceTypes serves the resource types, or a single one by name
+func (h *SCIMHandler) ResourceTypes(c *fiber.Ctx) error {
+	return h.discovery(c, scim.ResourceTypes(h.baseURL))
+}
+
+// Schemas serves the resource schemas, or a single one by URN
+func (h *SCIMHandler) Schemas(c *fiber.Ctx) error {
+	return h.discovery(c, scim.Schemas(h.baseURL))
+}
+
+func (h *SCIMHandler) discovery(c *fiber.Ctx, resources []map[string]interface{}) error {
+	if id := c.Params("id"); id != "" {
+		for _, resource := range resources {
+			if resource["id"] == id {
+				return h.respond(c, fiber.StatusOK, resource)
+			}
+		}
+		return h.scimError(c, fiber.StatusNotFound, "", "Resource not found")
+	}
+	return h.respond(c, fiber.StatusOK, &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: int64(len(resources)),
+		StartIndex:   1,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+func (h *SCIMHandler) ListUsers(c *fiber.Ctx) error {
+	list, err := h.scimService.ListUsers(h.tenantID(c), h.listQuery(c))
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, list)
+}
+
+func (h *SCIMHandler) GetUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+
+	found, err := h.scimService.GetUser(h.tenantID(c), id)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, found)
+}
+
+func (h *SCIMHandler) CreateUser(c *fiber.Ctx) error {
+	var req scim.User
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	created, err := h.scimService.CreateUser(h.tenantID(c), &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusCreated, created)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+	var req scim.User
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	replaced, deactivated, err := h.scimService.ReplaceUser(h.tenantID(c), id, &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	if deactivated {
+		h.revokeSessions(id)
+	}
+	return h.respond(c, fiber.StatusOK, replaced)
+}
+
+func (h *SCIMHandler) PatchUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+	var req scim.PatchRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	patched, deactivated, err := h.scimService.PatchUser(h.tenantID(c), id, &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	if deactivated {
+		h.revokeSessions(id)
+	}
+	return h.respond(c, fiber.StatusOK, patched)
+}
+
+func (h *SCIMHandler) DeleteUser(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+
+	if err := h.scimService.DeleteUser(h.tenantID(c), id); err != nil {
+		return h.resourceError(c, err)
+	}
+	h.revokeSessions(id)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *SCIMHandler) ListGroups(c *fiber.Ctx) error {
+	list, err := h.scimService.ListGroups(h.tenantID(c), h.listQuery(c))
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, list)
+}
+
+func (h *SCIMHandler) GetGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+
+	found, err := h.scimService.GetGroup(h.tenantID(c), id, h.listQuery(c).ExcludeMembers)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, found)
+}
+
+func (h *SCIMHandler) CreateGroup(c *fiber.Ctx) error {
+	var req scim.Group
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	created, err := h.scimService.CreateGroup(h.tenantID(c), &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusCreated, created)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+	var req scim.Group
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	replaced, err := h.scimService.ReplaceGroup(h.tenantID(c), id, &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, replaced)
+}
+
+func (h *SCIMHandler) PatchGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+	var req scim.PatchRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return h.scimError(c, fiber.StatusBadRequest, "invalidSyntax", "Invalid request body")
+	}
+
+	patched, err := h.scimService.PatchGroup(h.tenantID(c), id, &req)
+	if err != nil {
+		return h.resourceError(c, err)
+	}
+	return h.respond(c, fiber.StatusOK, patched)
+}
+
+func (h *SCIMHandler) DeleteGroup(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.resourceError(c, scim.ErrNotFound)
+	}
+
+	if err := h.scimService.DeleteGroup(h.tenantID(c), id); err != nil {
+		return h.resourceError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// revokeSessions signs a deprovisioned user out everywhere
+// The user is already deactivated or deleted, so a failure here is logged rather than failing the IdP's request
+func (h *SCIMHandler) revokeSessions(userID uuid.UUID) {
+	if err := h.hydraClient.RevokeUserSessions(userID.String()); err != nil {
+		h.logger.Error("Failed to revoke sessions of deprovisioned user", zap.Error(err), zap.String("user_id", userID.String()))
+	}
+}
+
+func (h *SCIMHandler) tenantID(c *fiber.Ctx) uuid.UUID {
+	return c.Locals(scimTenantKey).(uuid.UUID)
+}
+
+// listQuery reads the filter and pagination parameters of a list request
+func (h *SCIMHandler) listQuery(c *fiber.Ctx) *scim.ListQuery {
+	q := &scim.ListQuery{
+		Filter:     c.Query("filter"),
+		StartIndex: 1,
+		Count:      scim.DefaultCount,
+	}
+	if startIndex, err := strconv.Atoi(c.Query("startIndex")); err == nil {
+		q.StartIndex = startIndex
+	}
+	if count, err := strconv.Atoi(c.Query("count")); err == nil {
+		q.Count = count
+	}
+	for _, attr := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			q.ExcludeMembers = true
+		}
+	}
+	return q
+}
+
+func (h *SCIMHandler) respond(c *fiber.Ctx, status int, body interface{}) error {
+	c.Set(fiber.HeaderContentType, scim.ContentType)
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return c.Status(status).Send(data)
+}
+
+// resourceError answers with the SCIM error matching a service error
+func (h *SCIMHandler) resourceError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, scim.ErrNotFound):
+		return h.scimError(c, fiber.StatusNotFound, "", "Resource not found")
+	case errors.Is(err, scim.ErrUniqueness):
+		return h.scimError(c, fiber.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, scim.ErrInvalidFilter):
+		return h.scimError(c, fiber.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, scim.ErrInvalidPath):
+		return h.scimError(c, fiber.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, scim.ErrInvalidValue):
+		return h.scimError(c, fiber.StatusBadRequest, "invalidValue", err.Error())
+	default:
+		h.logger.Error("SCIM operation failed", zap.Error(err))
+		return h.scimError(c, fiber.StatusInternalServerError, "", "Failed to process request")
+	}
+}
+
+func (h *SCIMHandler) scimError(c *fiber.Ctx, status int, scimType, detail string) error {
+	return h.respond(c, status, &scim.ErrorResponse{
+		Schemas:  []string{scim.SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
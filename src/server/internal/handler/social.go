@@ -3,6 +3,7 @@ package handler
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"html/template"
 	"net/http"
 	"sync"
 	"time"
@@ -353,32 +354,7 @@ func (s *SocialHandler) GoogleCallback(c *fiber.Ctx) error {
 
 	// Return HTML page with JavaScript redirect to ensure proper browser navigation
 	// This is more reliable than HTTP 302 redirect for cross-origin OAuth flows
-	html := `<!DOCTYPE html>
-<html>
-<head>
-    <title>Redirecting...</title>
-    <meta charset="utf-8">
-</head>
-<body>
-    <div style="text-align: center; padding: 50px; font-family: sans-serif;">
-        <div style="font-size: 18px; color: #666; margin-bottom: 20px;">로그인 처리 중...</div>
-        <div style="width: 40px; height: 40px; margin: 0 auto; border: 4px solid #f3f3f3; border-top: 4px solid #4F46E5; border-radius: 50%; animation: spin 1s linear infinite;"></div>
-    </div>
-    <style>
-        @keyframes spin {
-            0% { transform: rotate(0deg); }
-            100% { transform: rotate(360deg); }
-        }
-    </style>
-    <script>
-        // Redirect to Hydra's OAuth endpoint with login_verifier
-        window.location.href = "` + acceptResp.RedirectTo + `";
-    </script>
-</body>
-</html>`
-
-	c.Set("Content-Type", "text/html; charset=utf-8")
-	return c.SendString(html)
+	return redirectPage(c, acceptResp.RedirectTo)
 }
 
 // GetGoogleAuthURL returns the Google OAuth URL for frontend use
@@ -414,3 +390,34 @@ func (s *SocialHandler) GetGoogleAuthURL(c *fiber.Ctx) error {
 
 	return c.JSON(response)
 }
+
+// redirectPage sends the browser on to redirectTo, typically Hydra's login verifier URL,
+// after an upstream identity provider returned to Authway
+func redirectPage(c *fiber.Ctx, redirectTo string) error {
+	html := `<!DOCTYPE html>
+<html>
+<head>
+    <title>Redirecting...</title>
+    <meta charset="utf-8">
+</head>
+<body>
+    <div style="text-align: center; padding: 50px; font-family: sans-serif;">
+        <div style="font-size: 18px; color: #666; margin-bottom: 20px;">로그인 처리 중...</div>
+        <div style="width: 40px; height: 40px; margin: 0 auto; border: 4px solid #f3f3f3; border-top: 4px solid #4F46E5; border-radius: 50%; animation: spin 1s linear infinite;"></div>
+    </div>
+    <style>
+        @keyframes spin {
+            0% { transform: rotate(0deg); }
+            100% { transform: rotate(360deg); }
+        }
+    </style>
+    <script>
+        // Redirect to Hydra's OAuth endpoint with login_verifier
+        window.location.href = "` + template.JSEscapeString(redirectTo) + `";
+    </script>
+</body>
+</html>`
+
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(html)
+}
@@ -0,0 +1,471 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/internal/service/sso"
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ssoStateTTL is how long a user has to sign in at the upstream identity provider
+const ssoStateTTL = connection.LoginStateTTL
+
+// randomToken returns a URL-safe random value for state, nonce and PKCE parameters
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SSOHandler signs users in through their tenant's enterprise connections
+type SSOHandler struct {
+	connectionService connection.Service
+	stateStore        *connection.StateStore
+	oidcService       *sso.OIDCService
+	samlService       *sso.SAMLService
+	userService       user.Service
+	clientService     client.Service
+	hydraClient       *hydra.Client
+	authHandler       *AuthHandler
+	validator         *validator.Validate
+	logger            *zap.Logger
+	baseURL           string
+	callbackURL       string
+}
+
+func NewSSOHandler(
+	connectionService connection.Service,
+	stateStore *connection.StateStore,
+	oidcService *sso.OIDCService,
+	samlService *sso.SAMLService,
+	userService user.Service,
+	clientService client.Service,
+	hydraClient *hydra.Client,
+	authHandler *AuthHandler,
+	validator *validator.Validate,
+	logger *zap.Logger,
+	baseURL string,
+) *SSOHandler {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &SSOHandler{
+		connectionService: connectionService,
+		stateStore:        stateStore,
+		oidcService:       oidcService,
+		samlService:       samlService,
+		userService:       userService,
+		clientService:     clientService,
+		hydraClient:       hydraClient,
+		authHandler:       authHandler,
+		validator:         validator,
+		logger:            logger,
+		baseURL:           baseURL,
+		callbackURL:       baseURL + "/auth/sso/callback",
+	}
+}
+
+// RegisterRoutes registers enterprise login routes
+func (h *SSOHandler) RegisterRoutes(router fiber.Router) {
+	ssoRoutes := router.Group("/auth/sso")
+	ssoRoutes.Post("/discover", h.Discover)
+	ssoRoutes.Get("/callback", h.Callback)
+	ssoRoutes.Get("/saml/post", h.SAMLPost)
+	ssoRoutes.Get("/saml/:id/metadata", h.SAMLMetadata)
+	ssoRoutes.Post("/saml/:id/acs", h.SAMLACS)
+}
+
+// samlServiceProvider returns Authway's SAML service provider identity for a connection
+// Each connection gets its own entity ID so one tenant can federate with several IdPs
+func samlServiceProvider(baseURL string, connectionID uuid.UUID) sso.ServiceProvider {
+	prefix := baseURL + "/auth/sso/saml/" + connectionID.String()
+	return sso.ServiceProvider{
+		EntityID: prefix + "/metadata",
+		ACSURL:   prefix + "/acs",
+	}
+}
+
+// DiscoverRequest is the email entered on the login form for home-realm discovery
+type DiscoverRequest struct {
+	LoginChallenge string `json:"login_challenge" validate:"required"`
+	Email          string `json:"email" validate:"required,email"`
+	Organization   string `json:"organization"` // Organization ID or slug (optional)
+}
+
+// loginClient loads the login request and OAuth client for a login challenge
+func (h *SSOHandler) loginClient(challenge string) (*hydra.LoginRequest, *client.Client, error) {
+	loginReq, err := h.hydraClient.GetLoginRequest(challenge)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid or expired login challenge")
+	}
+
+	requestedClient, err := h.clientService.GetByClientID(loginReq.Client.ClientID)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "OAuth client not registered in Authway")
+	}
+
+	// Enforce PKCE again in case the login page was bypassed
+	if !hasPKCEChallenge(requestedClient, loginReq) {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "This client requires PKCE with code_challenge_method=S256")
+	}
+	return loginReq, requestedClient, nil
+}
+
+// Discover routes the email entered on the login form to the tenant's enterprise connection for its domain
+// When no connection matches, the login form continues with the password step
+// POST /auth/sso/discover
+func (h *SSOHandler) Discover(c *fiber.Ctx) error {
+	var req DiscoverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		return validationFailed(c, fieldErrors(err)...)
+	}
+
+	_, requestedClient, err := h.loginClient(req.LoginChallenge)
+	if err != nil {
+		return err
+	}
+
+	conn, err := h.connectionService.Discover(requestedClient.TenantID, req.Email)
+	if errors.Is(err, connection.ErrNotFound) {
+		return c.JSON(fiber.Map{"sso": false})
+	}
+	if err != nil {
+		h.logger.Error("Home-realm discovery failed", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to look up enterprise connection")
+	}
+
+	redirectURL, err := h.start(c, conn, req.LoginChallenge, req.Organization)
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info("Routing login to enterprise connection",
+		zap.String("connection_id", conn.ID.String()),
+		zap.String("tenant_id", conn.TenantID.String()))
+
+	// Returned as JSON: the login form navigates itself (cross-origin redirects fail with fetch)
+	return c.JSON(fiber.Map{
+		"sso": true,
+		"connection": fiber.Map{
+			"id":   conn.ID,
+			"name": conn.Name,
+			"type": conn.Type,
+		},
+		"redirect_url": redirectURL,
+	})
+}
+
+// start stores the login state and returns the upstream sign-in URL
+func (h *SSOHandler) start(c *fiber.Ctx, conn *connection.Connection, loginChallenge, organization string) (string, error) {
+	if conn.Type == connection.TypeSAML {
+		return h.startSAML(conn, loginChallenge, organization)
+	}
+
+	stateData := &connection.LoginState{
+		LoginChallenge: loginChallenge,
+		ConnectionID:   conn.ID,
+		Organization:   organization,
+	}
+	state, err := randomToken()
+	if err == nil {
+		stateData.Nonce, err = randomToken()
+	}
+	if err == nil {
+		stateData.CodeVerifier, err = randomToken()
+	}
+	if err != nil {
+		h.logger.Error("Failed to generate SSO state", zap.Error(err))
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+	}
+
+	authURL, err := h.oidcService.AuthURL(c.Context(), conn, h.callbackURL, state, stateData.Nonce, stateData.CodeVerifier)
+	if err != nil {
+		h.logger.Error("Failed to build upstream authorization URL", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return "", fiber.NewError(fiber.StatusBadGateway, "Enterprise identity provider is unavailable")
+	}
+
+	if err := h.stateStore.SaveLoginState(state, stateData); err != nil {
+		h.logger.Error("Failed to save SSO state", zap.Error(err))
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+	}
+
+	// Bind the state to this browser for CSRF protection
+	c.Cookie(&fiber.Cookie{
+		Name:     "sso_state",
+		Value:    state,
+		Path:     "/auth/sso",
+		MaxAge:   int(ssoStateTTL.Seconds()),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https",
+		SameSite: "Lax",
+	})
+
+	return authURL, nil
+}
+
+// startSAML creates an AuthnRequest and returns the URL that delivers it to the IdP
+// The RelayState keys the login state: the IdP posts back cross-site, so no SameSite cookie is sent,
+// and the assertion must instead answer the single-use AuthnRequest ID stored with it
+func (h *SSOHandler) startSAML(conn *connection.Connection, loginChallenge, organization string) (string, error) {
+	requestID, request, err := h.samlService.AuthnRequest(conn, samlServiceProvider(h.baseURL, conn.ID))
+	if err != nil {
+		h.logger.Error("Failed to create SAML AuthnRequest", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+	}
+
+	relayState, err := randomToken()
+	if err != nil {
+		h.logger.Error("Failed to generate SSO state", zap.Error(err))
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+	}
+
+	stateData := &connection.LoginState{
+		LoginChallenge: loginChallenge,
+		ConnectionID:   conn.ID,
+		Organization:   organization,
+		RequestID:      requestID,
+	}
+
+	redirectURL := h.baseURL + "/auth/sso/saml/post?state=" + url.QueryEscape(relayState)
+	if conn.IdPSSOBinding == connection.BindingPOST {
+		stateData.AuthnRequest = request
+	} else {
+		redirectURL, err = sso.RedirectURL(conn.IdPSSOURL, request, relayState)
+		if err != nil {
+			h.logger.Error("Failed to encode SAML AuthnRequest", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+			return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+		}
+	}
+
+	if err := h.stateStore.SaveLoginState(relayState, stateData); err != nil {
+		h.logger.Error("Failed to save SSO state", zap.Error(err))
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to start enterprise login")
+	}
+
+	return redirectURL, nil
+}
+
+// SAMLPost renders the auto-submitting form that posts an AuthnRequest to the IdP (HTTP-POST binding)
+// GET /auth/sso/saml/post
+func (h *SSOHandler) SAMLPost(c *fiber.Ctx) error {
+	relayState := c.Query("state")
+
+	stateData, err := h.loginState(h.stateStore.GetLoginState, relayState)
+	if err != nil {
+		return err
+	}
+	if len(stateData.AuthnRequest) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Login state not found or expired, please restart the login")
+	}
+
+	conn, err := h.connectionService.Get(stateData.ConnectionID)
+	if err != nil || !conn.Enabled {
+		return fiber.NewError(fiber.StatusForbidden, "Enterprise connection is not available")
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return sso.PostForm(c, conn.IdPSSOURL, "SAMLRequest", stateData.AuthnRequest, relayState)
+}
+
+// SAMLMetadata publishes the service provider metadata to import at the IdP
+// GET /auth/sso/saml/:id/metadata
+func (h *SSOHandler) SAMLMetadata(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "Connection not found")
+	}
+
+	conn, err := h.connectionService.Get(id)
+	if err != nil || conn.Type != connection.TypeSAML {
+		return fiber.NewError(fiber.StatusNotFound, "Connection not found")
+	}
+
+	metadata, err := h.samlService.Metadata(samlServiceProvider(h.baseURL, conn.ID))
+	if err != nil {
+		h.logger.Error("Failed to render SAML metadata", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to render metadata")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/samlmetadata+xml")
+	return c.Send(metadata)
+}
+
+// SAMLACS completes an enterprise login when the IdP posts its SAML response (assertion consumer service)
+// POST /auth/sso/saml/:id/acs
+func (h *SSOHandler) SAMLACS(c *fiber.Ctx) error {
+	// IMPORTANT: Make copies of form values because Fiber reuses internal buffers
+	samlResponse := string([]byte(c.FormValue("SAMLResponse")))
+	relayState := string([]byte(c.FormValue("RelayState")))
+
+	if samlResponse == "" || relayState == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing SAMLResponse or RelayState parameter")
+	}
+
+	stateData, err := h.loginState(h.stateStore.ConsumeLoginState, relayState)
+	if err != nil {
+		return err
+	}
+	if stateData.RequestID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Login state not found or expired, please restart the login")
+	}
+
+	if stateData.ConnectionID.String() != c.Params("id") {
+		return fiber.NewError(fiber.StatusBadRequest, "Login state does not belong to this connection")
+	}
+
+	conn, err := h.connectionService.Get(stateData.ConnectionID)
+	if err != nil || !conn.Enabled || conn.Type != connection.TypeSAML {
+		return fiber.NewError(fiber.StatusForbidden, "Enterprise connection is not available")
+	}
+
+	assertion, err := h.samlService.ParseResponse(conn, samlServiceProvider(h.baseURL, conn.ID), samlResponse, stateData.RequestID)
+	if err != nil {
+		h.logger.Warn("SAML login failed", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return fiber.NewError(fiber.StatusUnauthorized, "Sign-in at the enterprise identity provider failed")
+	}
+
+	attributes := make(map[string]interface{}, len(assertion.Attributes)+1)
+	for name, values := range assertion.Attributes {
+		attributes[name] = values
+	}
+	if _, ok := attributes["email"]; !ok && assertion.NameIDFormat == sso.NameIDFormatEmail {
+		attributes["email"] = assertion.NameID
+	}
+
+	return h.completeLogin(c, conn, stateData, conn.AttributeMapping.Profile(assertion.NameID, attributes))
+}
+
+// Callback completes an enterprise login after the upstream OIDC provider redirects back
+// GET /auth/sso/callback
+func (h *SSOHandler) Callback(c *fiber.Ctx) error {
+	// IMPORTANT: Make copies of query strings because Fiber reuses internal buffers
+	code := string([]byte(c.Query("code")))
+	state := string([]byte(c.Query("state")))
+
+	if errorParam := c.Query("error"); errorParam != "" {
+		h.logger.Warn("Upstream identity provider returned an error", zap.String("error", errorParam))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":             errorParam,
+			"error_description": c.Query("error_description"),
+		})
+	}
+
+	if code == "" || state == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing code or state parameter")
+	}
+
+	if c.Cookies("sso_state") != state {
+		return fiber.NewError(fiber.StatusBadRequest, "State parameter does not match")
+	}
+
+	stateData, err := h.loginState(h.stateStore.ConsumeLoginState, state)
+	c.ClearCookie("sso_state")
+	if err != nil {
+		return err
+	}
+
+	conn, err := h.connectionService.Get(stateData.ConnectionID)
+	if err != nil || !conn.Enabled {
+		return fiber.NewError(fiber.StatusForbidden, "Enterprise connection is not available")
+	}
+
+	claims, err := h.oidcService.Exchange(c.Context(), conn, h.callbackURL, code, stateData.Nonce, stateData.CodeVerifier)
+	if err != nil {
+		h.logger.Warn("Upstream OIDC login failed", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return fiber.NewError(fiber.StatusUnauthorized, "Sign-in at the enterprise identity provider failed")
+	}
+
+	subject, _ := claims["sub"].(string)
+	return h.completeLogin(c, conn, stateData, conn.AttributeMapping.Profile(subject, claims))
+}
+
+// loginState looks up the login state of an upstream callback, mapping a missing or expired state to a 400
+func (h *SSOHandler) loginState(lookup func(key string) (*connection.LoginState, error), key string) (*connection.LoginState, error) {
+	stateData, err := lookup(key)
+	if errors.Is(err, connection.ErrLoginStateNotFound) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Login state not found or expired, please restart the login")
+	}
+	if err != nil {
+		h.logger.Error("Failed to load SSO state", zap.Error(err))
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to complete login, please restart the login")
+	}
+	return stateData, nil
+}
+
+// completeLogin provisions the upstream user in the connection's tenant and accepts the Hydra login request
+// Organization membership and the login context are handled like a password login
+func (h *SSOHandler) completeLogin(c *fiber.Ctx, conn *connection.Connection, stateData *connection.LoginState, profile *connection.Profile) error {
+	loginReq, requestedClient, err := h.loginClient(stateData.LoginChallenge)
+	if err != nil {
+		return err
+	}
+	if conn.TenantID != requestedClient.TenantID {
+		return fiber.NewError(fiber.StatusForbidden, "The enterprise connection does not belong to this application's tenant")
+	}
+
+	usr, err := h.connectionService.Provision(conn, profile)
+	if err != nil {
+		switch {
+		case errors.Is(err, connection.ErrMissingEmail):
+			return fiber.NewError(fiber.StatusForbidden, "The identity provider did not share an email address")
+		case errors.Is(err, connection.ErrEmailDomainMismatch):
+			return fiber.NewError(fiber.StatusForbidden, "The email address is not managed by this identity provider")
+		case errors.Is(err, connection.ErrUserMismatch):
+			return fiber.NewError(fiber.StatusForbidden, "The linked account belongs to another tenant")
+		default:
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to provision user")
+		}
+	}
+
+	if !usr.Active {
+		return fiber.NewError(fiber.StatusForbidden, "Account is disabled")
+	}
+
+	if err := h.userService.UpdateLastLogin(usr.ID); err != nil {
+		h.logger.Warn("Failed to update last login", zap.Error(err))
+	}
+
+	acceptBody := &hydra.AcceptLoginRequest{
+		Remember: true,
+		Context: map[string]interface{}{
+			"provider":      "sso",
+			"connection_id": conn.ID.String(),
+		},
+	}
+	redirectTo, err := h.authHandler.acceptLoginRequest(stateData.LoginChallenge, loginReq, requestedClient, usr, stateData.Organization, acceptBody)
+	switch {
+	case errors.Is(err, errUnknownOrganization):
+		return fiber.NewError(fiber.StatusBadRequest, "Unknown organization")
+	case errors.Is(err, errNotOrganizationMember):
+		// The login was rejected; Hydra reports access_denied to the application
+		h.logger.Info("Enterprise login rejected - not an organization member",
+			zap.String("user_id", usr.ID.String()),
+			zap.String("connection_id", conn.ID.String()))
+		return redirectPage(c, redirectTo)
+	case err != nil:
+		h.logger.Error("Failed to complete Hydra login request", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to complete login, please restart the login")
+	}
+
+	h.logger.Info("Enterprise login successful",
+		zap.String("user_id", usr.ID.String()),
+		zap.String("connection_id", conn.ID.String()),
+		zap.String("tenant_id", usr.TenantID.String()))
+
+	return redirectPage(c, redirectTo)
+}
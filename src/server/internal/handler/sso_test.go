@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/client"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/organization"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSSOHandler_CompleteLogin(t *testing.T) {
+	env := setupAuthTest(t)
+	env.createClient(t, &client.Client{ClientID: "web-app"})
+	orgs := organization.NewService(env.db, zap.NewNop())
+	org, err := orgs.Create(env.tenantID, &organization.CreateOrganizationRequest{Name: "Partner", Slug: "partner"})
+	require.NoError(t, err)
+
+	logger := zap.NewNop()
+	h := NewSSOHandler(connection.NewService(env.db, logger), connection.NewStateStore(env.db), nil, nil, env.users, env.handler.clientService, env.handler.hydraClient, env.handler, nil, logger, "https://auth.test")
+	conn := &connection.Connection{ID: uuid.New(), TenantID: env.tenantID, Type: connection.TypeLDAP, Enabled: true}
+
+	complete := func(challenge, organization string, conn *connection.Connection, subject string) int {
+		env.hydra.login[challenge] = &hydra.LoginRequest{Challenge: challenge, Client: &hydra.OAuth2Client{ClientID: "web-app"}}
+		app := fiber.New()
+		app.Get("/complete", func(c *fiber.Ctx) error {
+			state := &connection.LoginState{LoginChallenge: challenge, ConnectionID: conn.ID, Organization: organization}
+			return h.completeLogin(c, conn, state, &connection.Profile{Subject: subject, Email: subject + "@example.com"})
+		})
+		resp, err := app.Test(httptest.NewRequest("GET", "/complete", nil))
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	t.Run("non-member is rejected", func(t *testing.T) {
+		assert.Equal(t, 200, complete("sso-non-member", "partner", conn, "outsider"))
+		assert.Equal(t, "access_denied", env.hydra.rejectedLogin["sso-non-member"])
+		assert.NotContains(t, env.hydra.acceptedLogin, "sso-non-member")
+	})
+
+	t.Run("member gets the organization in the login context", func(t *testing.T) {
+		usr := env.createUser(t, "member@example.com")
+		_, err := orgs.AddMember(org.ID, usr.ID, organization.RoleMember)
+		require.NoError(t, err)
+
+		assert.Equal(t, 200, complete("sso-member", "partner", conn, "member"))
+		accepted, ok := env.hydra.acceptedLogin["sso-member"]
+		require.True(t, ok)
+		assert.Equal(t, usr.ID.String(), accepted.Subject)
+		assert.Equal(t, org.ID.String(), accepted.Context["org_id"])
+		assert.Equal(t, "sso", accepted.Context["provider"])
+	})
+
+	t.Run("connection of another tenant", func(t *testing.T) {
+		other := &connection.Connection{ID: uuid.New(), TenantID: uuid.New(), Type: connection.TypeLDAP, Enabled: true}
+		assert.Equal(t, 403, complete("sso-other-tenant", "", other, "stranger"))
+		assert.NotContains(t, env.hydra.acceptedLogin, "sso-other-tenant")
+	})
+}
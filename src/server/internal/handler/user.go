@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"authway/src/server/internal/service"
+	"authway/src/server/pkg/attribute"
 	"authway/src/server/pkg/user"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -12,16 +14,18 @@ import (
 )
 
 type UserHandler struct {
-	services  *service.Services
-	logger    *zap.Logger
-	validator *validator.Validate
+	services         *service.Services
+	attributeService attribute.Service
+	logger           *zap.Logger
+	validator        *validator.Validate
 }
 
-func NewUserHandler(services *service.Services, logger *zap.Logger) *UserHandler {
+func NewUserHandler(services *service.Services, attributeService attribute.Service, logger *zap.Logger) *UserHandler {
 	return &UserHandler{
-		services:  services,
-		logger:    logger,
-		validator: validator.New(),
+		services:         services,
+		attributeService: attributeService,
+		logger:           logger,
+		validator:        validator.New(),
 	}
 }
 
@@ -80,7 +84,7 @@ func (h *UserHandler) Get(c *fiber.Ctx) error {
 	})
 }
 
-// Update handles updating user information
+// Update handles updating user information, including any of the tenant's custom attributes
 func (h *UserHandler) Update(c *fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := uuid.Parse(idStr)
@@ -97,6 +101,22 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
 	}
 
+	if req.Attributes != nil {
+		foundUser, err := h.services.UserService.GetByID(id)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "User not found")
+		}
+		attributes, err := h.attributeService.Validate(foundUser.TenantID, foundUser, req.Attributes, false)
+		if err != nil {
+			if fields, ok := attributeFieldErrors(err); ok {
+				return validationFailed(c, fields...)
+			}
+			h.logger.Error("Failed to validate attributes", zap.Error(err), zap.String("id", idStr))
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to update user")
+		}
+		req.Attributes = attributes
+	}
+
 	updatedUser, err := h.services.UserService.Update(id, &req)
 	if err != nil {
 		h.logger.Error("Failed to update user", zap.Error(err), zap.String("id", idStr))
@@ -111,6 +131,37 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 	})
 }
 
+// Import handles creating a user migrated from another system with its existing password hash
+func (h *UserHandler) Import(c *fiber.Ctx) error {
+	var req struct {
+		TenantID string `json:"tenant_id" validate:"required,uuid"`
+		user.ImportUserRequest
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	imported, err := h.services.UserService.Import(uuid.MustParse(req.TenantID), &req.ImportUserRequest)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrUnsupportedPasswordHash):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, user.ErrEmailTaken):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		h.logger.Error("Failed to import user", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to import user")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"user": imported.ToPublic(),
+	})
+}
+
 // Delete handles deleting a user
 func (h *UserHandler) Delete(c *fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -130,3 +181,54 @@ func (h *UserHandler) Delete(c *fiber.Ctx) error {
 		"message": "User deleted successfully",
 	})
 }
+
+// ListDeleted handles listing soft-deleted users that can still be restored
+func (h *UserHandler) ListDeleted(c *fiber.Ctx) error {
+	limit, offset := pagination(c)
+
+	users, total, err := h.services.UserService.ListDeleted(limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list deleted users", zap.Error(err))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to retrieve deleted users")
+	}
+
+	publicUsers := make([]user.PublicUser, len(users))
+	for i, u := range users {
+		publicUsers[i] = u.ToPublic()
+	}
+
+	return c.JSON(fiber.Map{
+		"users":  publicUsers,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// Restore handles undeleting a soft-deleted user
+func (h *UserHandler) Restore(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	restored, err := h.services.UserService.Restore(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrDeletedNotFound):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case errors.Is(err, user.ErrEmailTaken), errors.Is(err, user.ErrTenantDeleted):
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		h.logger.Error("Failed to restore user", zap.Error(err), zap.String("id", idStr))
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to restore user")
+	}
+
+	h.logger.Info("User restored successfully", zap.String("id", idStr))
+
+	return c.JSON(fiber.Map{
+		"message": "User restored successfully",
+		"user":    restored.ToPublic(),
+	})
+}
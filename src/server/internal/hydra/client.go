@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -33,6 +36,34 @@ type OAuth2Client struct {
 	ResponseTypes           []string `json:"response_types"`
 	Scope                   string   `json:"scope"`
 	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	JwksURI                 string   `json:"jwks_uri,omitempty"`
+	Audience                []string `json:"audience,omitempty"`
+	AllowedCorsOrigins      []string `json:"allowed_cors_origins,omitempty"`
+	SkipConsent             bool     `json:"skip_consent,omitempty"`
+	PostLogoutRedirectUris  []string `json:"post_logout_redirect_uris,omitempty"`
+	FrontchannelLogoutURI   string   `json:"frontchannel_logout_uri,omitempty"`
+	BackchannelLogoutURI    string   `json:"backchannel_logout_uri,omitempty"`
+	LogoURI                 string   `json:"logo_uri,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+
+	// Per-client token lifespans (Hydra uses its global defaults when empty)
+	OAuth2ClientLifespans
+
+	// Metadata is free-form data stored by Hydra (e.g. Authway policy flags such as require_pkce)
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// OAuth2ClientLifespans holds per-grant token lifespans as duration strings
+type OAuth2ClientLifespans struct {
+	AuthorizationCodeGrantAccessTokenLifespan  string `json:"authorization_code_grant_access_token_lifespan,omitempty"`
+	AuthorizationCodeGrantIDTokenLifespan      string `json:"authorization_code_grant_id_token_lifespan,omitempty"`
+	AuthorizationCodeGrantRefreshTokenLifespan string `json:"authorization_code_grant_refresh_token_lifespan,omitempty"`
+	ClientCredentialsGrantAccessTokenLifespan  string `json:"client_credentials_grant_access_token_lifespan,omitempty"`
+	ImplicitGrantAccessTokenLifespan           string `json:"implicit_grant_access_token_lifespan,omitempty"`
+	ImplicitGrantIDTokenLifespan               string `json:"implicit_grant_id_token_lifespan,omitempty"`
+	RefreshTokenGrantAccessTokenLifespan       string `json:"refresh_token_grant_access_token_lifespan,omitempty"`
+	RefreshTokenGrantIDTokenLifespan           string `json:"refresh_token_grant_id_token_lifespan,omitempty"`
+	RefreshTokenGrantRefreshTokenLifespan      string `json:"refresh_token_grant_refresh_token_lifespan,omitempty"`
 }
 
 func (c *Client) CreateOAuth2Client(client *OAuth2Client) (*OAuth2Client, error) {
@@ -166,6 +197,7 @@ type AcceptLoginRequest struct {
 	Remember    bool                   `json:"remember"`
 	RememberFor int                    `json:"remember_for"`
 	ACR         string                 `json:"acr,omitempty"`
+	AMR         []string               `json:"amr,omitempty"`
 	Context     map[string]interface{} `json:"context,omitempty"`
 }
 
@@ -295,6 +327,7 @@ type ConsentRequest struct {
 	RequestedScope    []string               `json:"requested_scope"`
 	RequestedAudience []string               `json:"requested_audience"`
 	Subject           string                 `json:"subject"`
+	Skip              bool                   `json:"skip"`
 	Client            *OAuth2Client          `json:"client"`
 	LoginChallenge    string                 `json:"login_challenge"`
 	LoginSessionID    string                 `json:"login_session_id"`
@@ -442,7 +475,7 @@ func (c *Client) RevokeUserSessions(subject string) error {
 	// Revoke login sessions
 	req, err := http.NewRequest(
 		http.MethodDelete,
-		fmt.Sprintf("%s/admin/oauth2/auth/sessions/login?subject=%s", c.AdminURL, subject),
+		fmt.Sprintf("%s/admin/oauth2/auth/sessions/login?subject=%s", c.AdminURL, url.QueryEscape(subject)),
 		nil,
 	)
 	if err != nil {
@@ -463,7 +496,7 @@ func (c *Client) RevokeUserSessions(subject string) error {
 	// Revoke consent sessions
 	req, err = http.NewRequest(
 		http.MethodDelete,
-		fmt.Sprintf("%s/admin/oauth2/auth/sessions/consent?subject=%s", c.AdminURL, subject),
+		fmt.Sprintf("%s/admin/oauth2/auth/sessions/consent?subject=%s&all=true", c.AdminURL, url.QueryEscape(subject)),
 		nil,
 	)
 	if err != nil {
@@ -483,3 +516,180 @@ func (c *Client) RevokeUserSessions(subject string) error {
 
 	return nil
 }
+
+// PreviousConsentSession is a consent the subject granted earlier (remembered by Hydra)
+type PreviousConsentSession struct {
+	ConsentRequest           *ConsentRequest `json:"consent_request"`
+	GrantScope               []string        `json:"grant_scope"`
+	GrantAccessTokenAudience []string        `json:"grant_access_token_audience"`
+	Remember                 bool            `json:"remember"`
+	RememberFor              int             `json:"remember_for"`
+	HandledAt                time.Time       `json:"handled_at"`
+}
+
+// ListConsentSessions lists the consent sessions a subject has granted
+// Hydra paginates the list; the pages are followed through the Link header until the last one
+func (c *Client) ListConsentSessions(subject string) ([]PreviousConsentSession, error) {
+	var sessions []PreviousConsentSession
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("subject", subject)
+		query.Set("page_size", strconv.Itoa(consentSessionPageSize))
+		if pageToken != "" {
+			query.Set("page_token", pageToken)
+		}
+
+		resp, err := c.client.Get(fmt.Sprintf("%s/admin/oauth2/auth/sessions/consent?%s", c.AdminURL, query.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list consent sessions: %w", err)
+		}
+
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("hydra list consent sessions failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		}
+
+		var page []PreviousConsentSession
+		if err := json.Unmarshal(bodyBytes, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode consent sessions: %w", err)
+		}
+		sessions = append(sessions, page...)
+
+		next := nextPageToken(resp.Header.Get("Link"))
+		if next == "" || next == pageToken || len(page) == 0 {
+			return sessions, nil
+		}
+		pageToken = next
+	}
+}
+
+// consentSessionPageSize is the number of consent sessions requested per page (Hydra's maximum is 500)
+const consentSessionPageSize = 250
+
+// nextPageToken returns the page_token of the rel="next" link in a Link header, or "" on the last page
+func nextPageToken(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		isNext := false
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				isNext = true
+			}
+		}
+		if !isNext {
+			continue
+		}
+		target, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return ""
+		}
+		return target.Query().Get("page_token")
+	}
+	return ""
+}
+
+// RevokeClientConsentSessions revokes the subject's consent for a single client
+// Hydra also invalidates the access and refresh tokens issued to that client for the subject
+func (c *Client) RevokeClientConsentSessions(subject, clientID string) error {
+	req, err := http.NewRequest(
+		http.MethodDelete,
+		fmt.Sprintf("%s/admin/oauth2/auth/sessions/consent?subject=%s&client=%s", c.AdminURL, url.QueryEscape(subject), url.QueryEscape(clientID)),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create revoke consent request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke consent sessions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke consent sessions: status=%d body=%s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// Token Introspection
+type IntrospectedToken struct {
+	Active    bool                   `json:"active"`
+	Subject   string                 `json:"sub"`
+	ClientID  string                 `json:"client_id"`
+	Scope     string                 `json:"scope"`
+	TokenType string                 `json:"token_type"`
+	TokenUse  string                 `json:"token_use"`
+	ExpiresAt int64                  `json:"exp"`
+	IssuedAt  int64                  `json:"iat"`
+	Audience  []string               `json:"aud"`
+	Extra     map[string]interface{} `json:"ext"`
+}
+
+// IntrospectToken asks Hydra whether an access token is active
+func (c *Client) IntrospectToken(token string) (*IntrospectedToken, error) {
+	form := url.Values{}
+	form.Set("token", token)
+
+	resp, err := c.client.Post(
+		fmt.Sprintf("%s/admin/oauth2/introspect", c.AdminURL),
+		"application/x-www-form-urlencoded",
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hydra introspect failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var introspected IntrospectedToken
+	if err := json.Unmarshal(bodyBytes, &introspected); err != nil {
+		return nil, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+
+	return &introspected, nil
+}
+
+// RevokeLoginSession revokes a single login session by its session ID (sid)
+func (c *Client) RevokeLoginSession(sessionID string) error {
+	req, err := http.NewRequest(
+		http.MethodDelete,
+		fmt.Sprintf("%s/admin/oauth2/auth/sessions/login?sid=%s", c.AdminURL, url.QueryEscape(sessionID)),
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create revoke login session request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke login session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke login session: status=%d body=%s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
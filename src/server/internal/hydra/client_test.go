@@ -195,3 +195,103 @@ func TestClient_RevokeUserSessions_SpecialCharacters(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_ListConsentSessions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/admin/oauth2/auth/sessions/consent", r.URL.Path)
+		assert.Equal(t, "user@example.com", r.URL.Query().Get("subject"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"consent_request":{"client":{"client_id":"app","client_name":"App"}},"grant_scope":["openid","email"],"handled_at":"2024-01-02T03:04:05Z"}]`))
+	}))
+	defer server.Close()
+
+	sessions, err := NewClient(server.URL).ListConsentSessions("user@example.com")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "app", sessions[0].ConsentRequest.Client.ClientID)
+	assert.Equal(t, []string{"openid", "email"}, sessions[0].GrantScope)
+	assert.Equal(t, 2024, sessions[0].HandledAt.Year())
+}
+
+func TestClient_ListConsentSessionsFollowsPages(t *testing.T) {
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("page_token")
+		tokens = append(tokens, token)
+		w.Header().Set("Content-Type", "application/json")
+		if token == "" {
+			w.Header().Set("Link", `</admin/oauth2/auth/sessions/consent?page_size=250&page_token=first>; rel="first",</admin/oauth2/auth/sessions/consent?page_size=250&page_token=page-2>; rel="next"`)
+			_, _ = w.Write([]byte(`[{"consent_request":{"client":{"client_id":"app-1"}}}]`))
+			return
+		}
+		w.Header().Set("Link", `</admin/oauth2/auth/sessions/consent?page_size=250&page_token=first>; rel="first"`)
+		_, _ = w.Write([]byte(`[{"consent_request":{"client":{"client_id":"app-2"}}}]`))
+	}))
+	defer server.Close()
+
+	sessions, err := NewClient(server.URL).ListConsentSessions("user@example.com")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "app-1", sessions[0].ConsentRequest.Client.ClientID)
+	assert.Equal(t, "app-2", sessions[1].ConsentRequest.Client.ClientID)
+	assert.Equal(t, []string{"", "page-2"}, tokens)
+}
+
+func TestClient_RevokeClientConsentSessions(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectError bool
+	}{
+		{name: "revoked", status: http.StatusNoContent},
+		{name: "no sessions", status: http.StatusNotFound},
+		{name: "hydra error", status: http.StatusInternalServerError, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodDelete, r.Method)
+				assert.Equal(t, "user-123", r.URL.Query().Get("subject"))
+				assert.Equal(t, "my app", r.URL.Query().Get("client"))
+				assert.Empty(t, r.URL.Query().Get("all"))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewClient(server.URL).RevokeClientConsentSessions("user-123", "my app")
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClient_IntrospectToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/admin/oauth2/introspect", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("token") == "valid" {
+			_, _ = w.Write([]byte(`{"active":true,"sub":"user-123","client_id":"app","scope":"openid email","token_use":"access_token"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"active":false}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	token, err := client.IntrospectToken("valid")
+	require.NoError(t, err)
+	assert.True(t, token.Active)
+	assert.Equal(t, "user-123", token.Subject)
+	assert.Equal(t, "openid email", token.Scope)
+
+	token, err = client.IntrospectToken("expired")
+	require.NoError(t, err)
+	assert.False(t, token.Active)
+}
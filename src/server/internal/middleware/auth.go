@@ -1,40 +1,57 @@
 package middleware
 
 import (
-	// "strings"  // Currently unused
+	"errors"
+	"strings"
 
-	// "authway/src/server/pkg/token"  // Package not yet implemented
 	"github.com/gofiber/fiber/v2"
 )
 
-// RequireAuth middleware validates JWT tokens - Package not yet implemented
-// func RequireAuth(tokenService token.Service) fiber.Handler {
-//	return func(c *fiber.Ctx) error {
-//		authHeader := c.Get("Authorization")
-//		if authHeader == "" {
-//			return fiber.NewError(fiber.StatusUnauthorized, "Authorization header required")
-//		}
-//
-//		// Extract token from "Bearer <token>" format
-//		parts := strings.Split(authHeader, " ")
-//		if len(parts) != 2 || parts[0] != "Bearer" {
-//			return fiber.NewError(fiber.StatusUnauthorized, "Invalid authorization header format")
-//		}
-//
-//		tokenString := parts[1]
-//		claims, err := tokenService.ValidateAccessToken(tokenString)
-//		if err != nil {
-//			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired token")
-//		}
-//
-//		// Store user information in context
-//		c.Locals("userID", claims.UserID)
-//		c.Locals("clientID", claims.ClientID)
-//		c.Locals("scopes", claims.Scopes)
-//
-//		return c.Next()
-//	}
-// }
+// TenantResolver looks up the tenant of a token subject when the token carries no tenant claim
+type TenantResolver func(subject string) (string, error)
+
+// RequireAuth middleware validates bearer access tokens and stores the token
+// subject, tenant, client and scopes in the request context
+func RequireAuth(validator TokenValidator, resolveTenant TenantResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "Authorization header required")
+		}
+
+		// Extract token from "Bearer <token>" format
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid authorization header format")
+		}
+
+		token, err := validator.ValidateToken(parts[1])
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired token")
+			}
+			return fiber.NewError(fiber.StatusServiceUnavailable, "Failed to validate token")
+		}
+
+		tenantID := token.TenantID
+		if tenantID == "" && resolveTenant != nil {
+			tenantID, err = resolveTenant(token.Subject)
+			if err != nil {
+				return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired token")
+			}
+		}
+
+		// Store token information in context
+		c.Locals("userID", token.Subject)
+		c.Locals("tenantID", tenantID)
+		c.Locals("clientID", token.ClientID)
+		c.Locals("scopes", token.Scopes)
+		c.Locals("roles", token.Roles)
+		c.Locals("permissions", token.Permissions)
+
+		return c.Next()
+	}
+}
 
 // RequireAdmin middleware checks if user has admin role
 func RequireAdmin() fiber.Handler {
@@ -73,3 +90,49 @@ func RequireScope(requiredScope string) fiber.Handler {
 		return fiber.NewError(fiber.StatusForbidden, "Insufficient scope")
 	}
 }
+
+// RequireRole middleware checks if the token carries one of the given roles
+// Role claims are only present when the client was granted the "roles" scope
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, ok := c.Locals("roles").([]string)
+		if !ok {
+			return fiber.NewError(fiber.StatusForbidden, "Access denied")
+		}
+
+		for _, role := range granted {
+			for _, required := range roles {
+				if role == required {
+					return c.Next()
+				}
+			}
+		}
+
+		return fiber.NewError(fiber.StatusForbidden, "Insufficient role")
+	}
+}
+
+// RequirePermission middleware checks if the token carries every given permission
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, ok := c.Locals("permissions").([]string)
+		if !ok {
+			return fiber.NewError(fiber.StatusForbidden, "Access denied")
+		}
+
+		for _, required := range permissions {
+			found := false
+			for _, permission := range granted {
+				if permission == required {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fiber.NewError(fiber.StatusForbidden, "Insufficient permissions")
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,447 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when an access token is malformed, inactive or expired
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// TokenInfo holds the validated properties of an access token
+type TokenInfo struct {
+	Subject     string
+	TenantID    string
+	ClientID    string
+	Scopes      []string
+	Roles       []string
+	Permissions []string
+	ExpiresAt   time.Time
+	Extra       map[string]interface{}
+}
+
+// TokenValidator validates bearer access tokens
+type TokenValidator interface {
+	ValidateToken(token string) (*TokenInfo, error)
+}
+
+// IntrospectionValidator validates tokens with Hydra token introspection
+// Active tokens are cached for a short time so each request does not hit Hydra
+type IntrospectionValidator struct {
+	hydraClient *hydra.Client
+	audience    string
+	cacheTTL    time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedToken
+}
+
+type cachedToken struct {
+	info     *TokenInfo
+	cachedAt time.Time
+}
+
+// NewIntrospectionValidator creates a validator; a non-empty audience must be in the token's "aud"
+func NewIntrospectionValidator(hydraClient *hydra.Client, audience string, cacheTTL time.Duration) *IntrospectionValidator {
+	return &IntrospectionValidator{
+		hydraClient: hydraClient,
+		audience:    audience,
+		cacheTTL:    cacheTTL,
+		now:         time.Now,
+		cache:       make(map[string]cachedToken),
+	}
+}
+
+// ValidateToken introspects the token, using the cache when possible
+func (v *IntrospectionValidator) ValidateToken(token string) (*TokenInfo, error) {
+	key := tokenCacheKey(token)
+	now := v.now()
+
+	if info, ok := v.cached(key, now); ok {
+		return info, nil
+	}
+
+	introspected, err := v.hydraClient.IntrospectToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !introspected.Active || introspected.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if introspected.TokenUse != "" && introspected.TokenUse != "access_token" {
+		return nil, ErrInvalidToken
+	}
+	if v.audience != "" && !containsString(introspected.Audience, v.audience) {
+		return nil, ErrInvalidToken
+	}
+
+	info := &TokenInfo{
+		Subject:     introspected.Subject,
+		ClientID:    introspected.ClientID,
+		Scopes:      strings.Fields(introspected.Scope),
+		Roles:       stringsClaim(introspected.Extra, "roles"),
+		Permissions: stringsClaim(introspected.Extra, "permissions"),
+		Extra:       introspected.Extra,
+		TenantID:    stringClaim(introspected.Extra, "tenant_id"),
+	}
+	if introspected.ExpiresAt > 0 {
+		info.ExpiresAt = time.Unix(introspected.ExpiresAt, 0)
+	}
+
+	if v.cacheTTL > 0 {
+		v.mu.Lock()
+		v.evictExpired(now)
+		v.cache[key] = cachedToken{info: info, cachedAt: now}
+		v.mu.Unlock()
+	}
+
+	return info, nil
+}
+
+func (v *IntrospectionValidator) cached(key string, now time.Time) (*TokenInfo, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	entry, ok := v.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if now.Sub(entry.cachedAt) >= v.cacheTTL || (!entry.info.ExpiresAt.IsZero() && !now.Before(entry.info.ExpiresAt)) {
+		delete(v.cache, key)
+		return nil, false
+	}
+	return entry.info, true
+}
+
+// evictExpired drops stale entries; callers must hold v.mu
+func (v *IntrospectionValidator) evictExpired(now time.Time) {
+	for key, entry := range v.cache {
+		if now.Sub(entry.cachedAt) >= v.cacheTTL {
+			delete(v.cache, key)
+		}
+	}
+}
+
+// tokenCacheKey hashes the token so raw tokens are never kept in memory as map keys
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// JWKSValidator verifies JWT access tokens locally against Hydra's JSON Web Key Set
+// Locally verified tokens are not checked for revocation; keep their lifespan short
+type JWKSValidator struct {
+	jwksURL         string
+	issuer          string
+	audience        string
+	refreshInterval time.Duration
+	httpClient      *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewJWKSValidator creates a validator; a non-empty issuer and audience must match the token's "iss" and "aud"
+func NewJWKSValidator(jwksURL, issuer, audience string, refreshInterval time.Duration) *JWKSValidator {
+	return &JWKSValidator{
+		jwksURL:         jwksURL,
+		issuer:          issuer,
+		audience:        audience,
+		refreshInterval: refreshInterval,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		keys:            make(map[string]interface{}),
+	}
+}
+
+// ValidateToken verifies the JWT signature, expiry, issuer and audience
+// ID tokens are signed with the same keys, so tokens without scopes or with a non access token type are rejected
+func (v *JWKSValidator) ValidateToken(token string) (*TokenInfo, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, v.KeyFunc, options...)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !isAccessTokenType(parsed.Header["typ"]) || !hasScopeClaim(claims) {
+		return nil, ErrInvalidToken
+	}
+
+	info := &TokenInfo{
+		Subject:  stringClaim(claims, "sub"),
+		ClientID: stringClaim(claims, "client_id"),
+		Scopes:   scopesClaim(claims),
+	}
+	if info.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		info.ExpiresAt = exp.Time
+	}
+
+	// Hydra places consent session claims under "ext"
+	if ext, ok := claims["ext"].(map[string]interface{}); ok {
+		info.Extra = ext
+	}
+	info.TenantID = stringClaim(info.Extra, "tenant_id")
+	if info.TenantID == "" {
+		info.TenantID = stringClaim(claims, "tenant_id")
+	}
+	info.Roles = stringsClaim(info.Extra, "roles")
+	info.Permissions = stringsClaim(info.Extra, "permissions")
+
+	return info, nil
+}
+
+// KeyFunc resolves the verification key for a token by its kid, refreshing the key set once on a miss
+func (v *JWKSValidator) KeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	if key, ok := v.key(kid); ok {
+		return key, nil
+	}
+
+	// Unknown key ID: Hydra may have rotated its keys
+	if err := v.refresh(); err != nil {
+		return nil, err
+	}
+	if key, ok := v.key(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *JWKSValidator) key(kid string) (interface{}, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.refreshInterval > 0 && time.Since(v.fetchedAt) > v.refreshInterval {
+		return nil, false
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *JWKSValidator) refresh() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Another request refreshed the keys while we were waiting
+	if time.Since(v.fetchedAt) < time.Second {
+		return nil
+	}
+
+	resp, err := v.httpClient.Get(v.jwksURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return err
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS converts the signing keys of a JWK Set into crypto public keys keyed by kid
+func parseJWKS(body []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				continue
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch jwk.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil {
+				continue
+			}
+			y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if err != nil {
+				continue
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
+
+// HybridValidator verifies JWT access tokens locally and introspects opaque ones
+type HybridValidator struct {
+	jwks          *JWKSValidator
+	introspection *IntrospectionValidator
+}
+
+// NewHybridValidator creates a validator; jwks may be nil to always use introspection
+func NewHybridValidator(jwks *JWKSValidator, introspection *IntrospectionValidator) *HybridValidator {
+	return &HybridValidator{
+		jwks:          jwks,
+		introspection: introspection,
+	}
+}
+
+func (v *HybridValidator) ValidateToken(token string) (*TokenInfo, error) {
+	if v.jwks != nil && looksLikeJWT(token) {
+		return v.jwks.ValidateToken(token)
+	}
+	return v.introspection.ValidateToken(token)
+}
+
+// looksLikeJWT reports whether the token is a compact JWS (Hydra opaque tokens have a single dot)
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	if claims == nil {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
+
+// stringsClaim reads a JSON string array claim
+func stringsClaim(claims map[string]interface{}, name string) []string {
+	values, ok := claims[name].([]interface{})
+	if !ok {
+		return []string{}
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// isAccessTokenType reports whether a JWT "typ" header is absent or names an access token (RFC 9068 or plain JWT)
+func isAccessTokenType(typ interface{}) bool {
+	if typ == nil {
+		return true
+	}
+	value, _ := typ.(string)
+	switch strings.ToLower(value) {
+	case "jwt", "at+jwt", "application/at+jwt":
+		return true
+	}
+	return false
+}
+
+// hasScopeClaim reports whether the token carries Hydra's "scp" or a "scope" claim; ID tokens carry neither
+func hasScopeClaim(claims jwt.MapClaims) bool {
+	_, scp := claims["scp"]
+	_, scope := claims["scope"]
+	return scp || scope
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopesClaim reads scopes from Hydra's "scp" array or a space-delimited "scope" claim
+func scopesClaim(claims jwt.MapClaims) []string {
+	if scp, ok := claims["scp"].([]interface{}); ok {
+		scopes := make([]string, 0, len(scp))
+		for _, scope := range scp {
+			if s, ok := scope.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+		return scopes
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return []string{}
+}
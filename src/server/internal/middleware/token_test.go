@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIntrospectionServer(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("token") == "valid-token" {
+			_, _ = w.Write([]byte(`{"active":true,"sub":"user-123","client_id":"app","scope":"openid profile","token_use":"access_token","exp":` +
+				strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + `,"ext":{"tenant_id":"tenant-1","roles":["editor"],"permissions":["documents:read","documents:write"]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"active":false}`))
+	}))
+}
+
+func TestIntrospectionValidator_Cache(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(t, &calls)
+	defer server.Close()
+
+	validator := NewIntrospectionValidator(hydra.NewClient(server.URL), "", time.Minute)
+	now := time.Now()
+	validator.now = func() time.Time { return now }
+
+	info, err := validator.ValidateToken("valid-token")
+	require.NoError(t, err)
+	assert.Equal(t, "user-123", info.Subject)
+	assert.Equal(t, "tenant-1", info.TenantID)
+	assert.Equal(t, []string{"openid", "profile"}, info.Scopes)
+
+	// Served from cache
+	_, err = validator.ValidateToken("valid-token")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Cache entry expires after the TTL
+	now = now.Add(2 * time.Minute)
+	_, err = validator.ValidateToken("valid-token")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Inactive tokens are rejected and never cached
+	_, err = validator.ValidateToken("revoked-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = validator.ValidateToken("revoked-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestJWKSValidator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	validator := NewJWKSValidator(server.URL, "https://auth.example.com", "", time.Hour)
+
+	t.Run("valid token", func(t *testing.T) {
+		info, err := validator.ValidateToken(sign("key-1", jwt.MapClaims{
+			"iss":       "https://auth.example.com",
+			"sub":       "user-123",
+			"client_id": "app",
+			"scp":       []string{"openid", "email"},
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"ext":       map[string]interface{}{"tenant_id": "tenant-1", "roles": []string{"viewer"}},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "user-123", info.Subject)
+		assert.Equal(t, "app", info.ClientID)
+		assert.Equal(t, "tenant-1", info.TenantID)
+		assert.Equal(t, []string{"openid", "email"}, info.Scopes)
+		assert.Equal(t, []string{"viewer"}, info.Roles)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		_, err := validator.ValidateToken(sign("key-1", jwt.MapClaims{
+			"iss": "https://auth.example.com",
+			"sub": "user-123",
+			"exp": time.Now().Add(-time.Minute).Unix(),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		_, err := validator.ValidateToken(sign("key-1", jwt.MapClaims{
+			"iss": "https://evil.example.com",
+			"sub": "user-123",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := validator.ValidateToken(sign("key-2", jwt.MapClaims{
+			"iss": "https://auth.example.com",
+			"sub": "user-123",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("ID token", func(t *testing.T) {
+		_, err := validator.ValidateToken(sign("key-1", jwt.MapClaims{
+			"iss":   "https://auth.example.com",
+			"sub":   "user-123",
+			"aud":   []string{"app"},
+			"nonce": "abc",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("not an access token type", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": "https://auth.example.com",
+			"sub": "user-123",
+			"scp": []string{"openid"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = "key-1"
+		token.Header["typ"] = "logout+jwt"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		_, err = validator.ValidateToken(signed)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expected audience", func(t *testing.T) {
+		audienceValidator := NewJWKSValidator(server.URL, "https://auth.example.com", "https://api.example.com", time.Hour)
+		claims := func(aud []string) jwt.MapClaims {
+			return jwt.MapClaims{
+				"iss": "https://auth.example.com",
+				"sub": "user-123",
+				"aud": aud,
+				"scp": []string{"openid"},
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}
+		}
+
+		_, err := audienceValidator.ValidateToken(sign("key-1", claims([]string{"https://api.example.com"})))
+		assert.NoError(t, err)
+		_, err = audienceValidator.ValidateToken(sign("key-1", claims([]string{"https://other.example.com"})))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestIntrospectionValidator_Audience(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		_, _ = w.Write([]byte(`{"active":true,"sub":"user-123","scope":"openid","aud":["` + r.PostForm.Get("token") + `"]}`))
+	}))
+	defer server.Close()
+
+	validator := NewIntrospectionValidator(hydra.NewClient(server.URL), "https://api.example.com", time.Minute)
+
+	_, err := validator.ValidateToken("https://api.example.com")
+	assert.NoError(t, err)
+	_, err = validator.ValidateToken("https://other.example.com")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestRequireAuth(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(t, &calls)
+	defer server.Close()
+
+	validator := NewHybridValidator(nil, NewIntrospectionValidator(hydra.NewClient(server.URL), "", time.Minute))
+
+	app := fiber.New()
+	app.Get("/protected", RequireAuth(validator, nil), RequireScope("profile"), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"user_id":   c.Locals("userID"),
+			"tenant_id": c.Locals("tenantID"),
+			"client_id": c.Locals("clientID"),
+		})
+	})
+	app.Get("/admin", RequireAuth(validator, nil), RequireScope("admin"), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "missing header", path: "/protected", expectedStatus: fiber.StatusUnauthorized},
+		{name: "wrong scheme", path: "/protected", authorization: "Basic abc", expectedStatus: fiber.StatusUnauthorized},
+		{name: "inactive token", path: "/protected", authorization: "Bearer revoked-token", expectedStatus: fiber.StatusUnauthorized},
+		{name: "valid token", path: "/protected", authorization: "Bearer valid-token", expectedStatus: fiber.StatusOK},
+		{name: "missing scope", path: "/admin", authorization: "Bearer valid-token", expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			if tt.expectedStatus == fiber.StatusOK {
+				var body map[string]string
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, "user-123", body["user_id"])
+				assert.Equal(t, "tenant-1", body["tenant_id"])
+				assert.Equal(t, "app", body["client_id"])
+			}
+		})
+	}
+}
+
+func TestRequireRoleAndPermission(t *testing.T) {
+	var calls int32
+	server := newIntrospectionServer(t, &calls)
+	defer server.Close()
+
+	validator := NewHybridValidator(nil, NewIntrospectionValidator(hydra.NewClient(server.URL), "", time.Minute))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	app.Get("/editor", RequireAuth(validator, nil), RequireRole("admin", "editor"), ok)
+	app.Get("/admin", RequireAuth(validator, nil), RequireRole("admin"), ok)
+	app.Get("/write", RequireAuth(validator, nil), RequirePermission("documents:read", "documents:write"), ok)
+	app.Get("/delete", RequireAuth(validator, nil), RequirePermission("documents:read", "documents:delete"), ok)
+	app.Get("/no-auth", RequireRole("editor"), ok)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{path: "/editor", expectedStatus: fiber.StatusOK},
+		{path: "/admin", expectedStatus: fiber.StatusForbidden},
+		{path: "/write", expectedStatus: fiber.StatusOK},
+		{path: "/delete", expectedStatus: fiber.StatusForbidden},
+		{path: "/no-auth", expectedStatus: fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
@@ -26,8 +26,8 @@ func NewUserService(db *gorm.DB, logger *zap.Logger) user.Service {
 }
 
 // NewClientService creates a new client service
-func NewClientService(db *gorm.DB, logger *zap.Logger, hydraClient *hydra.Client) client.Service {
-	return client.NewService(db, logger, hydraClient)
+func NewClientService(db *gorm.DB, logger *zap.Logger, hydraClient *hydra.Client, policy client.Policy) client.Service {
+	return client.NewService(db, logger, hydraClient, policy)
 }
 
 // NewTokenService creates a new token service - Package not yet implemented
@@ -0,0 +1,295 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// NameIDFormatTransient is a one-time NameID, for service providers that must not correlate logins
+const NameIDFormatTransient = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+
+// DefaultAssertionLifetime is how long service providers may accept an issued assertion
+const DefaultAssertionLifetime = 5 * time.Minute
+
+// ErrInvalidAuthnRequest is returned when a service provider's AuthnRequest cannot be read
+var ErrInvalidAuthnRequest = errors.New("invalid SAML AuthnRequest")
+
+// IdentityProvider issues signed SAML assertions to registered service providers (IdP mode)
+// One key signs for every tenant; each tenant has its own entity ID
+type IdentityProvider struct {
+	key      crypto.Signer
+	cert     *x509.Certificate
+	logger   *zap.Logger
+	lifetime time.Duration
+	now      func() time.Time
+}
+
+func NewIdentityProvider(key crypto.Signer, cert *x509.Certificate, logger *zap.Logger) *IdentityProvider {
+	return &IdentityProvider{
+		key:      key,
+		cert:     cert,
+		logger:   logger,
+		lifetime: DefaultAssertionLifetime,
+		now:      time.Now,
+	}
+}
+
+// LoadIdentityProviderKey reads the PEM private key (PKCS#8, PKCS#1 or SEC 1) and certificate that sign assertions
+func LoadIdentityProviderKey(keyPath, certPath string) (crypto.Signer, *x509.Certificate, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SAML signing key: %w", err)
+	}
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SAML signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, errors.New("SAML signing key is not PEM encoded")
+	}
+	var key interface{}
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, nil, errors.New("unsupported SAML signing key")
+			}
+		}
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("unsupported SAML signing key")
+	}
+
+	cert, err := parsePEMCertificate(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse SAML signing certificate: %w", err)
+	}
+	return signer, cert, nil
+}
+
+func parsePEMCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("certificate is not PEM encoded")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// GenerateIdentityProviderKey creates an ephemeral RSA key with a self-signed certificate
+// Service providers must re-import the metadata after every restart, so this is for development only
+func GenerateIdentityProviderKey(commonName string) (crypto.Signer, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// Certificate returns the certificate service providers verify assertions with
+func (p *IdentityProvider) Certificate() *x509.Certificate {
+	return p.cert
+}
+
+type idpMetadata struct {
+	XMLName          xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID         string   `xml:"entityID,attr"`
+	IDPSSODescriptor struct {
+		WantAuthnRequestsSigned    bool   `xml:"WantAuthnRequestsSigned,attr"`
+		ProtocolSupportEnumeration string `xml:"protocolSupportEnumeration,attr"`
+		KeyDescriptor              struct {
+			Use     string `xml:"use,attr"`
+			KeyInfo struct {
+				XMLName         xml.Name `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+				X509Certificate string   `xml:"X509Data>X509Certificate"`
+			}
+		} `xml:"KeyDescriptor"`
+		NameIDFormats        []string `xml:"NameIDFormat"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// Metadata returns the IdP metadata document service providers import to trust a tenant
+func (p *IdentityProvider) Metadata(entityID, ssoURL string) ([]byte, error) {
+	var md idpMetadata
+	md.EntityID = entityID
+	md.IDPSSODescriptor.ProtocolSupportEnumeration = nsSAMLP
+	md.IDPSSODescriptor.KeyDescriptor.Use = "signing"
+	md.IDPSSODescriptor.KeyDescriptor.KeyInfo.X509Certificate = base64.StdEncoding.EncodeToString(p.cert.Raw)
+	md.IDPSSODescriptor.NameIDFormats = []string{NameIDFormatEmail, NameIDFormatPersistent, NameIDFormatTransient, NameIDFormatUnspecified}
+	for _, binding := range []string{BindingRedirectURI, BindingPOSTURI} {
+		md.IDPSSODescriptor.SingleSignOnServices = append(md.IDPSSODescriptor.SingleSignOnServices, struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		}{Binding: binding, Location: ssoURL})
+	}
+
+	body, err := xml.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode IdP metadata: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// AuthnRequestInfo is what Authway reads from a service provider's AuthnRequest
+// The request is not signed: the ACS URL is trusted only if registered for the issuer
+type AuthnRequestInfo struct {
+	ID           string
+	Issuer       string
+	ACSURL       string
+	NameIDFormat string
+	ForceAuthn   bool
+}
+
+// ParseAuthnRequest decodes a SAMLRequest parameter
+// deflated is true for the HTTP-Redirect binding and false for HTTP-POST
+func ParseAuthnRequest(samlRequest string, deflated bool) (*AuthnRequestInfo, error) {
+	data, err := base64.StdEncoding.DecodeString(stripSpace(samlRequest))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed base64", ErrInvalidAuthnRequest)
+	}
+	if deflated {
+		if data, err = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), 1<<20)); err != nil {
+			return nil, fmt.Errorf("%w: malformed DEFLATE encoding", ErrInvalidAuthnRequest)
+		}
+	}
+
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAuthnRequest, err)
+	}
+	if !root.is(nsSAMLP, "AuthnRequest") {
+		return nil, fmt.Errorf("%w: not an AuthnRequest", ErrInvalidAuthnRequest)
+	}
+
+	info := &AuthnRequestInfo{
+		ID:           root.attr("ID"),
+		Issuer:       strings.TrimSpace(root.child(nsSAML, "Issuer").text()),
+		ACSURL:       root.attr("AssertionConsumerServiceURL"),
+		NameIDFormat: root.child(nsSAMLP, "NameIDPolicy").attr("Format"),
+		ForceAuthn:   root.attr("ForceAuthn") == "true" || root.attr("ForceAuthn") == "1",
+	}
+	if info.ID == "" || info.Issuer == "" {
+		return nil, fmt.Errorf("%w: missing ID or Issuer", ErrInvalidAuthnRequest)
+	}
+	if binding := root.attr("ProtocolBinding"); binding != "" && binding != BindingPOSTURI {
+		return nil, fmt.Errorf("%w: only the HTTP-POST binding is supported for responses", ErrInvalidAuthnRequest)
+	}
+	return info, nil
+}
+
+// ResponseParams describes the assertion to issue to a service provider
+type ResponseParams struct {
+	Issuer       string // the tenant's IdP entity ID
+	Audience     string // the service provider's entity ID
+	ACSURL       string
+	InResponseTo string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	Attributes   map[string][]string
+}
+
+// Response builds a successful SAML response carrying one signed assertion
+func (p *IdentityProvider) Response(params *ResponseParams) ([]byte, error) {
+	responseID, err := newSAMLID()
+	if err != nil {
+		return nil, err
+	}
+	assertionID, err := newSAMLID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := p.now()
+	issued := samlTime(now)
+	notOnOrAfter := samlTime(now.Add(p.lifetime))
+	inResponseTo := ""
+	if params.InResponseTo != "" {
+		inResponseTo = ` InResponseTo="` + escapeAttr(params.InResponseTo) + `"`
+	}
+
+	var b strings.Builder
+	b.WriteString(`<samlp:Response xmlns:samlp="` + nsSAMLP + `" xmlns:saml="` + nsSAML + `"`)
+	b.WriteString(` ID="` + responseID + `" Version="2.0" IssueInstant="` + issued + `" Destination="` + escapeAttr(params.ACSURL) + `"` + inResponseTo + `>`)
+	b.WriteString(`<saml:Issuer>` + escapeText(params.Issuer) + `</saml:Issuer>`)
+	b.WriteString(`<samlp:Status><samlp:StatusCode Value="` + statusSuccess + `"></samlp:StatusCode></samlp:Status>`)
+
+	b.WriteString(`<saml:Assertion ID="` + assertionID + `" Version="2.0" IssueInstant="` + issued + `">`)
+	b.WriteString(`<saml:Issuer>` + escapeText(params.Issuer) + `</saml:Issuer>`)
+	b.WriteString(`<saml:Subject><saml:NameID Format="` + escapeAttr(params.NameIDFormat) + `">` + escapeText(params.NameID) + `</saml:NameID>`)
+	b.WriteString(`<saml:SubjectConfirmation Method="` + confirmationBearer + `">`)
+	b.WriteString(`<saml:SubjectConfirmationData` + inResponseTo + ` NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + escapeAttr(params.ACSURL) + `"></saml:SubjectConfirmationData>`)
+	b.WriteString(`</saml:SubjectConfirmation></saml:Subject>`)
+	b.WriteString(`<saml:Conditions NotBefore="` + issued + `" NotOnOrAfter="` + notOnOrAfter + `">`)
+	b.WriteString(`<saml:AudienceRestriction><saml:Audience>` + escapeText(params.Audience) + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>`)
+	b.WriteString(`<saml:AuthnStatement AuthnInstant="` + issued + `" SessionIndex="` + escapeAttr(params.SessionIndex) + `">`)
+	b.WriteString(`<saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:unspecified</saml:AuthnContextClassRef></saml:AuthnContext>`)
+	b.WriteString(`</saml:AuthnStatement>`)
+
+	if len(params.Attributes) > 0 {
+		names := make([]string, 0, len(params.Attributes))
+		for name := range params.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.WriteString(`<saml:AttributeStatement>`)
+		for _, name := range names {
+			b.WriteString(`<saml:Attribute Name="` + escapeAttr(name) + `" NameFormat="urn:oasis:names:tc:SAML:2.0:attrname-format:basic">`)
+			for _, value := range params.Attributes[name] {
+				b.WriteString(`<saml:AttributeValue>` + escapeText(value) + `</saml:AttributeValue>`)
+			}
+			b.WriteString(`</saml:Attribute>`)
+		}
+		b.WriteString(`</saml:AttributeStatement>`)
+	}
+	b.WriteString(`</saml:Assertion></samlp:Response>`)
+
+	signed, err := signEnveloped([]byte(b.String()), assertionID, p.key, p.cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign assertion: %w", err)
+	}
+	return append([]byte(xml.Header), signed...), nil
+}
@@ -0,0 +1,101 @@
+package sso
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+
+	"authway/src/server/pkg/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupIdentityProvider(t *testing.T) *IdentityProvider {
+	key, cert, err := GenerateIdentityProviderKey("Authway test")
+	require.NoError(t, err)
+	return NewIdentityProvider(key, cert, zap.NewNop())
+}
+
+func TestIdentityProvider_ResponseVerifies(t *testing.T) {
+	idp := setupIdentityProvider(t)
+	sp := ServiceProvider{EntityID: "https://legacy.acme.com", ACSURL: "https://legacy.acme.com/saml/acs"}
+
+	// Authway's own SP mode is the relying party: the response must pass its validation
+	conn := &connection.Connection{
+		Type:           connection.TypeSAML,
+		IdPEntityID:    "http://localhost:8080/saml/idp/tenant-1",
+		IdPCertificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.Certificate().Raw})),
+	}
+
+	response, err := idp.Response(&ResponseParams{
+		Issuer:       conn.IdPEntityID,
+		Audience:     sp.EntityID,
+		ACSURL:       sp.ACSURL,
+		InResponseTo: "_req1",
+		NameID:       "alice@acme.com",
+		NameIDFormat: NameIDFormatEmail,
+		SessionIndex: "_s1",
+		Attributes: map[string][]string{
+			"email":  {"alice@acme.com"},
+			"groups": {"admins", "R&D <core>"},
+		},
+	})
+	require.NoError(t, err)
+
+	assertion, err := NewSAMLService(0, newReplayCache(t), zap.NewNop()).ParseResponse(conn, sp, base64.StdEncoding.EncodeToString(response), "_req1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@acme.com", assertion.NameID)
+	assert.Equal(t, NameIDFormatEmail, assertion.NameIDFormat)
+	assert.Equal(t, []string{"admins", "R&D <core>"}, assertion.Attributes["groups"])
+
+	tampered := strings.Replace(string(response), "admins", "owners", 1)
+	_, err = NewSAMLService(0, newReplayCache(t), zap.NewNop()).ParseResponse(conn, sp, base64.StdEncoding.EncodeToString([]byte(tampered)), "_req1")
+	assert.ErrorIs(t, err, ErrInvalidAssertion)
+}
+
+func TestParseAuthnRequest(t *testing.T) {
+	service, _, conn, sp := setupSAML(t)
+
+	id, request, err := service.AuthnRequest(conn, sp)
+	require.NoError(t, err)
+
+	// HTTP-POST binding: plain base64
+	info, err := ParseAuthnRequest(base64.StdEncoding.EncodeToString(request), false)
+	require.NoError(t, err)
+	assert.Equal(t, id, info.ID)
+	assert.Equal(t, sp.EntityID, info.Issuer)
+	assert.Equal(t, sp.ACSURL, info.ACSURL)
+	assert.False(t, info.ForceAuthn)
+
+	// HTTP-Redirect binding: DEFLATE then base64
+	redirect, err := RedirectURL("https://idp.example.com/sso", request, "")
+	require.NoError(t, err)
+	parsed, err := url.Parse(redirect)
+	require.NoError(t, err)
+	info, err = ParseAuthnRequest(parsed.Query().Get("SAMLRequest"), true)
+	require.NoError(t, err)
+	assert.Equal(t, id, info.ID)
+
+	_, err = ParseAuthnRequest(base64.StdEncoding.EncodeToString([]byte(`<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_x"/>`)), false)
+	assert.ErrorIs(t, err, ErrInvalidAuthnRequest)
+}
+
+func TestIdentityProvider_Metadata(t *testing.T) {
+	idp := setupIdentityProvider(t)
+
+	body, err := idp.Metadata("http://localhost:8080/saml/idp/tenant-1", "http://localhost:8080/saml/idp/tenant-1/sso")
+	require.NoError(t, err)
+
+	// Authway's SP mode can import its own IdP metadata
+	metadata, err := ParseIdPMetadata(body)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/saml/idp/tenant-1", metadata.EntityID)
+	assert.Equal(t, "http://localhost:8080/saml/idp/tenant-1/sso", metadata.SSOURL)
+	assert.Equal(t, connection.BindingRedirect, metadata.SSOBinding)
+	cert, err := connection.ParseCertificate(metadata.Certificate)
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(idp.Certificate()))
+}
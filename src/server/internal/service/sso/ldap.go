@@ -0,0 +1,237 @@
+package sso
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/connection"
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+// DefaultLDAPTimeout bounds a whole directory login: connect, bind, search and user bind
+const DefaultLDAPTimeout = 10 * time.Second
+
+var (
+	// ErrLDAPInvalidCredentials is returned when the directory has no such user or rejects the password
+	ErrLDAPInvalidCredentials = errors.New("invalid directory credentials")
+
+	// ErrLDAPUnavailable is returned when the directory cannot be reached or answers with an error
+	ErrLDAPUnavailable = errors.New("directory unavailable")
+)
+
+// LDAPEntry is the directory entry of an authenticated user
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Subject returns the stable identifier the entry links to its Authway user by
+// The server-assigned entryUUID (OpenLDAP) or objectGUID (Active Directory) survives renames and moves;
+// the DN is the fallback for directories that expose neither
+func (e *LDAPEntry) Subject() string {
+	if v := e.first("entryUUID"); v != "" {
+		return v
+	}
+	if v := e.first("objectGUID"); v != "" {
+		return hex.EncodeToString([]byte(v))
+	}
+	return strings.ToLower(e.DN)
+}
+
+// Groups returns the values of the group membership attribute
+func (e *LDAPEntry) Groups(attribute string) []string {
+	return e.values(attribute)
+}
+
+// Attribute names are case-insensitive
+func (e *LDAPEntry) values(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e *LDAPEntry) first(name string) string {
+	if values := e.values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// LDAPService verifies passwords against the LDAP directories of tenants (OpenLDAP, Active Directory)
+type LDAPService struct {
+	timeout time.Duration
+	logger  *zap.Logger
+}
+
+func NewLDAPService(timeout time.Duration, logger *zap.Logger) *LDAPService {
+	if timeout <= 0 {
+		timeout = DefaultLDAPTimeout
+	}
+	return &LDAPService{timeout: timeout, logger: logger}
+}
+
+// Authenticate finds the user's entry with the connection's service account and binds as it with password
+// Search-then-bind works for any directory layout, whatever attribute users sign in with
+func (s *LDAPService) Authenticate(ctx context.Context, conn *connection.Connection, username, password string) (*LDAPEntry, error) {
+	// An empty password is an unauthenticated bind (RFC 4513 section 5.1.2), which servers accept
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	filter := conn.UserFilter(username)
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLDAPUnavailable, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	client, err := s.dial(ctx, conn)
+	if err != nil {
+		s.logger.Warn("Failed to connect to LDAP directory", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return nil, fmt.Errorf("%w: %v", ErrLDAPUnavailable, err)
+	}
+	defer client.Close()
+
+	if conn.LDAPBindDN != "" {
+		if err := bind(client, conn.LDAPBindDN, conn.LDAPBindPassword); err != nil {
+			s.logger.Error("LDAP service account bind failed", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+			return nil, fmt.Errorf("%w: service account bind: %v", ErrLDAPUnavailable, err)
+		}
+	}
+
+	attributes := []string{
+		"entryUUID", "objectGUID", conn.GroupAttribute(),
+		mapped(conn.AttributeMapping.Email, connection.LDAPAttributeMapping.Email),
+		mapped(conn.AttributeMapping.Name, connection.LDAPAttributeMapping.Name),
+		mapped(conn.AttributeMapping.GivenName, connection.LDAPAttributeMapping.GivenName),
+		mapped(conn.AttributeMapping.FamilyName, connection.LDAPAttributeMapping.FamilyName),
+	}
+	if conn.AttributeMapping.Picture != "" {
+		attributes = append(attributes, conn.AttributeMapping.Picture)
+	}
+
+	// Size limit 2 tells a unique match from an ambiguous filter; referrals to other servers are not followed
+	result, err := client.Search(ldap.NewSearchRequest(conn.LDAPSearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, filter, attributes, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		s.logger.Error("LDAP user search failed", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return nil, fmt.Errorf("%w: search: %v", ErrLDAPUnavailable, err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrLDAPInvalidCredentials
+	case 1:
+	default:
+		s.logger.Warn("LDAP user filter matches several entries", zap.String("connection_id", conn.ID.String()))
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	found := result.Entries[0]
+	if err := bind(client, found.DN, password); err != nil {
+		return nil, err
+	}
+	entry := &LDAPEntry{DN: found.DN, Attributes: make(map[string][]string, len(found.Attributes))}
+	for _, attr := range found.Attributes {
+		entry.Attributes[attr.Name] = attr.Values
+	}
+	return entry, nil
+}
+
+// LDAPProfile maps a directory entry to the user profile, defaulting to the standard LDAP attribute names
+func LDAPProfile(conn *connection.Connection, entry *LDAPEntry) *connection.Profile {
+	mapping := connection.AttributeMapping{
+		Email:      mapped(conn.AttributeMapping.Email, connection.LDAPAttributeMapping.Email),
+		Name:       mapped(conn.AttributeMapping.Name, connection.LDAPAttributeMapping.Name),
+		GivenName:  mapped(conn.AttributeMapping.GivenName, connection.LDAPAttributeMapping.GivenName),
+		FamilyName: mapped(conn.AttributeMapping.FamilyName, connection.LDAPAttributeMapping.FamilyName),
+		Picture:    conn.AttributeMapping.Picture,
+	}
+
+	// Directories differ in attribute name case (mail, displayname); the mapping matches any
+	claims := make(map[string]interface{}, len(entry.Attributes))
+	for _, name := range []string{mapping.Email, mapping.Name, mapping.GivenName, mapping.FamilyName, mapping.Picture} {
+		if values := entry.values(name); name != "" && len(values) > 0 {
+			claims[name] = values
+		}
+	}
+	return mapping.Profile(entry.Subject(), claims)
+}
+
+func mapped(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+func (s *LDAPService) dial(ctx context.Context, conn *connection.Connection) (*ldap.Conn, error) {
+	u, err := url.Parse(conn.LDAPURL)
+	if err != nil {
+		return nil, err
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "389"
+		if u.Scheme == "ldaps" {
+			port = "636"
+		}
+	}
+
+	tlsConfig, err := conn.LDAPTLSConfig(host)
+	if err != nil {
+		return nil, fmt.Errorf("ldap_ca_certificate: %w", err)
+	}
+
+	dialer := &net.Dialer{}
+	raw, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = raw.SetDeadline(deadline)
+	}
+
+	if u.Scheme == "ldaps" {
+		tlsConn := tls.Client(raw, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		raw = tlsConn
+	}
+
+	client := ldap.NewConn(raw, u.Scheme == "ldaps")
+	client.Start()
+	client.SetTimeout(s.timeout)
+	if conn.LDAPStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("StartTLS: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// bind authenticates the connection with a simple bind, telling rejected credentials from directory errors
+func bind(client *ldap.Conn, dn, password string) error {
+	err := client.Bind(dn, password)
+	switch {
+	case err == nil:
+		return nil
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials):
+		return ErrLDAPInvalidCredentials
+	default:
+		return fmt.Errorf("%w: %v", ErrLDAPUnavailable, err)
+	}
+}
@@ -0,0 +1,402 @@
+package sso
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/connection"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testServiceDN       = "cn=authway,ou=services,dc=acme,dc=com"
+	testServicePassword = "service-secret"
+)
+
+type testLDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// testDirectory is a minimal in-process LDAPv3 server: simple bind, subtree search and StartTLS
+type testDirectory struct {
+	entries  []testLDAPEntry
+	tls      *tls.Config
+	ldaps    bool
+	listener net.Listener
+}
+
+func newTestDirectory(t *testing.T) *testDirectory {
+	return &testDirectory{entries: []testLDAPEntry{
+		{DN: testServiceDN, Password: testServicePassword},
+		{
+			DN:       "uid=alice,ou=people,dc=acme,dc=com",
+			Password: "alice-secret",
+			Attributes: map[string][]string{
+				"uid":         {"alice"},
+				"mail":        {"alice@acme.com"},
+				"displayName": {"Alice Liddell"},
+				"entryUUID":   {"8d2f1c5e-0c3a-4b1e-9a41-3f1b7f3c9b10"},
+				"memberOf":    {"cn=Engineering,ou=groups,dc=acme,dc=com", "cn=VPN Users,ou=groups,dc=acme,dc=com"},
+			},
+		},
+		{
+			DN:       "uid=bob,ou=people,dc=acme,dc=com",
+			Password: "bob-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"bob"},
+				"mail":        {"bob@acme.com"},
+				"givenName":   {"Bob"},
+				"sn":          {"Builder"},
+			},
+		},
+	}}
+}
+
+// start serves the directory on a loopback port and returns its URL
+func (d *testDirectory) start(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	d.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+
+	scheme := "ldap"
+	if d.ldaps {
+		scheme = "ldaps"
+	}
+	return scheme + "://" + listener.Addr().String()
+}
+
+func (d *testDirectory) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	if d.ldaps {
+		conn = tls.Server(conn, d.tls)
+	}
+	bound := ""
+
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id := message.Children[0].Value.(int64)
+		op := message.Children[1]
+		reply := func(op *ber.Packet) {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+			envelope.AppendChild(op)
+			_, _ = conn.Write(envelope.Bytes())
+		}
+		result := func(tag ber.Tag, code int) {
+			op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+			op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+			op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+			reply(op)
+		}
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			code := ldap.LDAPResultInvalidCredentials
+			if dn == "" && password == "" {
+				code = ldap.LDAPResultSuccess
+			}
+			for _, entry := range d.entries {
+				if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			if code == ldap.LDAPResultSuccess {
+				bound = dn
+			}
+			result(ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			if !strings.EqualFold(bound, testServiceDN) {
+				result(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)
+				continue
+			}
+			base := strings.ToLower(op.Children[0].Data.String())
+			sizeLimit := int(op.Children[3].Value.(int64))
+			matched := 0
+			code := ldap.LDAPResultSuccess
+			for _, entry := range d.entries {
+				if !strings.HasSuffix(strings.ToLower(entry.DN), base) || !matchFilter(op.Children[6], entry) {
+					continue
+				}
+				if sizeLimit > 0 && matched == sizeLimit {
+					code = ldap.LDAPResultSizeLimitExceeded
+					break
+				}
+				matched++
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for name, values := range entry.Attributes {
+					attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+					vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, v := range values {
+						vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+					}
+					attr.AppendChild(vals)
+					attrs.AppendChild(attr)
+				}
+				found := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				found.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+				found.AppendChild(attrs)
+				reply(found)
+			}
+			result(ldap.ApplicationSearchResultDone, code)
+		case ldap.ApplicationExtendedRequest:
+			if d.tls == nil || op.Children[0].Data.String() != "1.3.6.1.4.1.1466.20037" {
+				result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError)
+				continue
+			}
+			result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess)
+			conn = tls.Server(conn, d.tls)
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+// matchFilter evaluates the filter subset the tests use, comparing values case-insensitively
+func matchFilter(filter *ber.Packet, entry testLDAPEntry) bool {
+	values := func(attr string) []string {
+		for name, v := range entry.Attributes {
+			if strings.EqualFold(name, attr) {
+				return v
+			}
+		}
+		return nil
+	}
+	children := filter.Children
+
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchFilter(children[0], entry)
+	case ldap.FilterEqualityMatch:
+		for _, v := range values(children[0].Data.String()) {
+			if strings.EqualFold(v, children[1].Data.String()) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(values(filter.Data.String())) > 0
+	case ldap.FilterSubstrings:
+		for _, v := range values(children[0].Data.String()) {
+			v = strings.ToLower(v)
+			ok := true
+			for _, sub := range children[1].Children {
+				s := strings.ToLower(sub.Data.String())
+				switch sub.Tag {
+				case ldap.FilterSubstringsInitial:
+					ok = ok && strings.HasPrefix(v, s)
+				case ldap.FilterSubstringsFinal:
+					ok = ok && strings.HasSuffix(v, s)
+				default:
+					ok = ok && strings.Contains(v, s)
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// testTLS returns a server config for 127.0.0.1 and the PEM of its self-signed certificate
+func testTLS(t *testing.T) (*tls.Config, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap.acme.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return config, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func ldapConnection(url string) *connection.Connection {
+	return &connection.Connection{
+		Type:             connection.TypeLDAP,
+		LDAPURL:          url,
+		LDAPBindDN:       testServiceDN,
+		LDAPBindPassword: testServicePassword,
+		LDAPSearchBase:   "ou=people,dc=acme,dc=com",
+	}
+}
+
+func TestLDAPService_Authenticate(t *testing.T) {
+	directory := newTestDirectory(t)
+	conn := ldapConnection(directory.start(t))
+	service := NewLDAPService(5*time.Second, zap.NewNop())
+	ctx := context.Background()
+
+	entry, err := service.Authenticate(ctx, conn, "Alice@acme.com", "alice-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=alice,ou=people,dc=acme,dc=com", entry.DN)
+	assert.Equal(t, "8d2f1c5e-0c3a-4b1e-9a41-3f1b7f3c9b10", entry.Subject())
+	assert.Len(t, entry.Groups("memberof"), 2)
+
+	profile := LDAPProfile(conn, entry)
+	assert.Equal(t, "alice@acme.com", profile.Email)
+	assert.Equal(t, "Alice Liddell", profile.Name)
+
+	// Sign in by uid instead of email, with names from givenName and sn
+	conn.LDAPUserFilter = "(&(objectClass=*)(|(uid={username})(mail={username})))"
+	entry, err = service.Authenticate(ctx, conn, "bob", "bob-secret")
+	require.NoError(t, err)
+	profile = LDAPProfile(conn, entry)
+	assert.Equal(t, "bob@acme.com", profile.Email)
+	assert.Equal(t, "Bob Builder", profile.Name)
+	assert.Equal(t, "uid=bob,ou=people,dc=acme,dc=com", profile.Subject)
+}
+
+func TestLDAPService_RejectsInvalidCredentials(t *testing.T) {
+	directory := newTestDirectory(t)
+	conn := ldapConnection(directory.start(t))
+	service := NewLDAPService(5*time.Second, zap.NewNop())
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice@acme.com", "wrong"},
+		{"unknown user", "carol@acme.com", "alice-secret"},
+		{"empty password", "alice@acme.com", ""},
+		{"filter injection", "*", "alice-secret"},
+		{"filter injection closing", "alice@acme.com)(uid=*", "alice-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Authenticate(ctx, conn, tt.username, tt.password)
+			assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+		})
+	}
+
+	// A filter matching several entries identifies nobody
+	conn.LDAPUserFilter = "(|(mail={username})(uid=*))"
+	_, err := service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+	assert.ErrorIs(t, err, ErrLDAPInvalidCredentials)
+}
+
+func TestLDAPService_Unavailable(t *testing.T) {
+	directory := newTestDirectory(t)
+	url := directory.start(t)
+	service := NewLDAPService(5*time.Second, zap.NewNop())
+	ctx := context.Background()
+
+	conn := ldapConnection(url)
+	conn.LDAPBindPassword = "wrong"
+	_, err := service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+	assert.ErrorIs(t, err, ErrLDAPUnavailable)
+
+	// Anonymous search is refused by the directory
+	conn = ldapConnection(url)
+	conn.LDAPBindDN = ""
+	_, err = service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+	assert.ErrorIs(t, err, ErrLDAPUnavailable)
+
+	// A malformed user filter is a configuration error, not a failed login
+	conn = ldapConnection(url)
+	conn.LDAPUserFilter = "(mail={username}"
+	_, err = service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+	assert.ErrorIs(t, err, ErrLDAPUnavailable)
+
+	directory.listener.Close()
+	_, err = service.Authenticate(ctx, ldapConnection(url), "alice@acme.com", "alice-secret")
+	assert.ErrorIs(t, err, ErrLDAPUnavailable)
+}
+
+func TestLDAPService_TLS(t *testing.T) {
+	serverTLS, caPEM := testTLS(t)
+	_, otherPEM := testTLS(t)
+	service := NewLDAPService(5*time.Second, zap.NewNop())
+	ctx := context.Background()
+
+	t.Run("StartTLS", func(t *testing.T) {
+		directory := newTestDirectory(t)
+		directory.tls = serverTLS
+		conn := ldapConnection(directory.start(t))
+		conn.LDAPStartTLS = true
+		conn.LDAPCACertificate = caPEM
+
+		_, err := service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+		require.NoError(t, err)
+
+		conn.LDAPCACertificate = otherPEM
+		_, err = service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+		assert.ErrorIs(t, err, ErrLDAPUnavailable)
+	})
+
+	t.Run("LDAPS", func(t *testing.T) {
+		directory := newTestDirectory(t)
+		directory.tls = serverTLS
+		directory.ldaps = true
+		conn := ldapConnection(directory.start(t))
+		conn.LDAPCACertificate = caPEM
+
+		_, err := service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+		require.NoError(t, err)
+
+		// Without the private CA the system roots do not trust the directory
+		conn.LDAPCACertificate = ""
+		_, err = service.Authenticate(ctx, conn, "alice@acme.com", "alice-secret")
+		assert.ErrorIs(t, err, ErrLDAPUnavailable)
+	})
+}
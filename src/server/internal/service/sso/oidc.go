@@ -0,0 +1,234 @@
+package sso
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/internal/middleware"
+	"authway/src/server/pkg/connection"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidIDToken is returned when the upstream ID token fails verification
+var ErrInvalidIDToken = errors.New("invalid upstream ID token")
+
+// providerCacheTTL is how long discovered provider metadata is reused
+const providerCacheTTL = time.Hour
+
+// ProviderMetadata is the subset of the OpenID Provider discovery document Authway uses
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	metadata  ProviderMetadata
+	keys      *middleware.JWKSValidator
+	fetchedAt time.Time
+}
+
+// OIDCService signs users in at upstream OpenID Connect providers of enterprise connections
+type OIDCService struct {
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider
+}
+
+func NewOIDCService(logger *zap.Logger) *OIDCService {
+	return &OIDCService{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		providers:  make(map[string]*oidcProvider),
+	}
+}
+
+// CodeChallenge derives the S256 PKCE code challenge for a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthURL returns the upstream authorization URL for an authorization code flow with PKCE
+func (o *OIDCService) AuthURL(ctx context.Context, conn *connection.Connection, redirectURI, state, nonce, codeVerifier string) (string, error) {
+	provider, err := o.provider(ctx, conn.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("client_id", conn.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(conn.RequestedScopes(), " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token claims,
+// completed with the userinfo claims when the provider has a userinfo endpoint
+func (o *OIDCService) Exchange(ctx context.Context, conn *connection.Connection, redirectURI, code, nonce, codeVerifier string) (map[string]interface{}, error) {
+	provider, err := o.provider(ctx, conn.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+	data.Set("client_id", conn.ClientID)
+	data.Set("code_verifier", codeVerifier)
+	if conn.ClientSecret != "" {
+		data.Set("client_secret", conn.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.metadata.TokenEndpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := o.doJSON(req, &tokens); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, provider.keys.KeyFunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.metadata.Issuer),
+		jwt.WithAudience(conn.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidIDToken)
+	}
+
+	if provider.metadata.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		userinfo, err := o.userinfo(ctx, provider.metadata.UserinfoEndpoint, tokens.AccessToken)
+		if err != nil {
+			o.logger.Warn("Failed to fetch upstream userinfo", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		} else if userinfo["sub"] == subject {
+			for name, value := range userinfo {
+				if _, ok := claims[name]; !ok {
+					claims[name] = value
+				}
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+func (o *OIDCService) userinfo(ctx context.Context, endpoint, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create userinfo request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	userinfo := map[string]interface{}{}
+	if err := o.doJSON(req, &userinfo); err != nil {
+		return nil, err
+	}
+	return userinfo, nil
+}
+
+// provider returns the discovered metadata and signing keys of an issuer
+func (o *OIDCService) provider(ctx context.Context, issuer string) (*oidcProvider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	o.mu.Lock()
+	cached, ok := o.providers[issuer]
+	o.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < providerCacheTTL {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	var metadata ProviderMetadata
+	if err := o.doJSON(req, &metadata); err != nil {
+		return nil, fmt.Errorf("provider discovery failed: %w", err)
+	}
+
+	// The discovery document must be about the configured issuer (OpenID Connect Discovery 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("provider discovery failed: issuer %q does not match %q", metadata.Issuer, issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("provider discovery failed: incomplete metadata for %q", issuer)
+	}
+
+	provider := &oidcProvider{
+		metadata:  metadata,
+		keys:      middleware.NewJWKSValidator(metadata.JWKSURI, metadata.Issuer, "", providerCacheTTL),
+		fetchedAt: time.Now(),
+	}
+	o.mu.Lock()
+	o.providers[issuer] = provider
+	o.mu.Unlock()
+	return provider, nil
+}
+
+func (o *OIDCService) doJSON(req *http.Request, out interface{}) error {
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/connection"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testProvider is a minimal upstream OpenID provider signing ID tokens with a local RSA key
+type testProvider struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	nonce    string
+	verifier string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	discovery := func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ProviderMetadata{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			UserinfoEndpoint:      p.URL + "/userinfo",
+			JWKSURI:               p.URL + "/jwks",
+		})
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", discovery)
+	// Served under another path too, to test issuer validation
+	mux.HandleFunc("/tenant/.well-known/openid-configuration", discovery)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good-code" || CodeChallenge(r.Form.Get("code_verifier")) != CodeChallenge(p.verifier) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":   p.URL,
+			"aud":   "authway",
+			"sub":   "upstream-alice",
+			"email": "alice@acme.com",
+			"nonce": p.nonce,
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "test"
+		signed, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": signed})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"sub": "upstream-alice", "name": "Alice Smith"})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestOIDCService_AuthURLAndExchange(t *testing.T) {
+	provider := newTestProvider(t)
+	provider.nonce = "nonce-1"
+	provider.verifier = "verifier-1"
+
+	service := NewOIDCService(zap.NewNop())
+	conn := &connection.Connection{Issuer: provider.URL + "/", ClientID: "authway", ClientSecret: "secret"}
+	redirectURI := "http://localhost:8080/auth/sso/callback"
+
+	authURL, err := service.AuthURL(context.Background(), conn, redirectURI, "state-1", "nonce-1", "verifier-1")
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, provider.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+	assert.Equal(t, CodeChallenge("verifier-1"), parsed.Query().Get("code_challenge"))
+
+	claims, err := service.Exchange(context.Background(), conn, redirectURI, "good-code", "nonce-1", "verifier-1")
+	require.NoError(t, err)
+	assert.Equal(t, "upstream-alice", claims["sub"])
+	assert.Equal(t, "alice@acme.com", claims["email"])
+	assert.Equal(t, "Alice Smith", claims["name"])
+
+	// A replayed token from another login flow carries a different nonce
+	_, err = service.Exchange(context.Background(), conn, redirectURI, "good-code", "nonce-2", "verifier-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Tokens issued to another client are rejected
+	other := &connection.Connection{Issuer: provider.URL, ClientID: "someone-else"}
+	_, err = service.Exchange(context.Background(), other, redirectURI, "good-code", "nonce-1", "verifier-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestOIDCService_RejectsIssuerMismatch(t *testing.T) {
+	provider := newTestProvider(t)
+
+	service := NewOIDCService(zap.NewNop())
+	conn := &connection.Connection{Issuer: provider.URL + "/tenant", ClientID: "authway"}
+	_, err := service.AuthURL(context.Background(), conn, "http://localhost/cb", "s", "n", "v")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+}
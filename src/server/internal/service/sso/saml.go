@@ -0,0 +1,477 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/connection"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// SAML 2.0 namespaces and identifiers
+const (
+	nsSAMLP = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsSAML  = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMD    = "urn:oasis:names:tc:SAML:2.0:metadata"
+
+	BindingRedirectURI = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	BindingPOSTURI     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	NameIDFormatEmail       = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	NameIDFormatPersistent  = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	NameIDFormatUnspecified = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	confirmationBearer = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// DefaultClockSkew is the tolerated clock difference with identity providers
+const DefaultClockSkew = 3 * time.Minute
+
+// ErrInvalidAssertion is returned when a SAML response fails validation
+var ErrInvalidAssertion = errors.New("invalid SAML response")
+
+// ServiceProvider identifies Authway as the SAML service provider of one connection
+type ServiceProvider struct {
+	EntityID string
+	ACSURL   string
+}
+
+// Assertion is a validated SAML assertion
+type Assertion struct {
+	ID           string
+	NameID       string
+	NameIDFormat string
+	SessionIndex string
+	Attributes   map[string][]string
+}
+
+// ReplayCache records accepted assertions until they expire, shared by all instances
+type ReplayCache interface {
+	// MarkAssertionUsed returns connection.ErrAssertionReplayed if the assertion was already used
+	MarkAssertionUsed(connectionID uuid.UUID, assertionID string, expiresAt time.Time) error
+}
+
+// SAMLService implements the SAML 2.0 web browser SSO profile for enterprise connections
+type SAMLService struct {
+	httpClient *http.Client
+	logger     *zap.Logger
+	clockSkew  time.Duration
+	now        func() time.Time
+	replays    ReplayCache
+}
+
+func NewSAMLService(clockSkew time.Duration, replays ReplayCache, logger *zap.Logger) *SAMLService {
+	if clockSkew <= 0 {
+		clockSkew = DefaultClockSkew
+	}
+	return &SAMLService{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		clockSkew:  clockSkew,
+		now:        time.Now,
+		replays:    replays,
+	}
+}
+
+// newSAMLID returns a random identifier valid as an xs:ID (which must not start with a digit)
+func newSAMLID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
+
+func samlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+type spMetadata struct {
+	XMLName         xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityID        string   `xml:"entityID,attr"`
+	SPSSODescriptor struct {
+		AuthnRequestsSigned        bool     `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool     `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string   `xml:"protocolSupportEnumeration,attr"`
+		NameIDFormats              []string `xml:"NameIDFormat"`
+		AssertionConsumerService   struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+			Index    int    `xml:"index,attr"`
+		} `xml:"AssertionConsumerService"`
+	} `xml:"SPSSODescriptor"`
+}
+
+// Metadata returns the SP metadata document to register Authway at the identity provider
+func (s *SAMLService) Metadata(sp ServiceProvider) ([]byte, error) {
+	var md spMetadata
+	md.EntityID = sp.EntityID
+	md.SPSSODescriptor.WantAssertionsSigned = true
+	md.SPSSODescriptor.ProtocolSupportEnumeration = nsSAMLP
+	md.SPSSODescriptor.NameIDFormats = []string{NameIDFormatEmail, NameIDFormatPersistent, NameIDFormatUnspecified}
+	md.SPSSODescriptor.AssertionConsumerService.Binding = BindingPOSTURI
+	md.SPSSODescriptor.AssertionConsumerService.Location = sp.ACSURL
+
+	body, err := xml.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SP metadata: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// ParseIdPMetadata reads the SSO endpoint and signing certificate of an IdP metadata document
+// The HTTP-Redirect binding is preferred over HTTP-POST
+func ParseIdPMetadata(data []byte) (*connection.IdPMetadata, error) {
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := root
+	if root.is(nsMD, "EntitiesDescriptor") {
+		entity = nil
+		for _, candidate := range root.children(nsMD, "EntityDescriptor") {
+			if candidate.child(nsMD, "IDPSSODescriptor") != nil {
+				entity = candidate
+				break
+			}
+		}
+	}
+	if !entity.is(nsMD, "EntityDescriptor") {
+		return nil, errors.New("metadata has no identity provider EntityDescriptor")
+	}
+	idp := entity.child(nsMD, "IDPSSODescriptor")
+	if idp == nil {
+		return nil, errors.New("metadata has no IDPSSODescriptor")
+	}
+
+	metadata := &connection.IdPMetadata{EntityID: entity.attr("entityID")}
+	for _, binding := range []string{BindingRedirectURI, BindingPOSTURI} {
+		for _, service := range idp.children(nsMD, "SingleSignOnService") {
+			if metadata.SSOURL == "" && service.attr("Binding") == binding {
+				metadata.SSOURL = service.attr("Location")
+				metadata.SSOBinding = connection.BindingRedirect
+				if binding == BindingPOSTURI {
+					metadata.SSOBinding = connection.BindingPOST
+				}
+			}
+		}
+	}
+
+	for _, key := range idp.children(nsMD, "KeyDescriptor") {
+		if use := key.attr("use"); use != "" && use != "signing" {
+			continue
+		}
+		cert := key.child(nsDSig, "KeyInfo").child(nsDSig, "X509Data").child(nsDSig, "X509Certificate")
+		if value := stripSpace(cert.text()); value != "" {
+			metadata.Certificate = value
+			break
+		}
+	}
+
+	if metadata.EntityID == "" || metadata.SSOURL == "" || metadata.Certificate == "" {
+		return nil, errors.New("metadata lacks the entity ID, a supported SingleSignOnService or a signing certificate")
+	}
+	return metadata, nil
+}
+
+// FetchIdPMetadata downloads and parses IdP metadata
+func (s *SAMLService) FetchIdPMetadata(ctx context.Context, metadataURL string) (*connection.IdPMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch metadata: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return ParseIdPMetadata(body)
+}
+
+type authnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	IssueInstant                string   `xml:"IssueInstant,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	Issuer                      struct {
+		XMLName xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+		Value   string   `xml:",chardata"`
+	}
+	NameIDPolicy struct {
+		XMLName     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+		AllowCreate bool     `xml:"AllowCreate,attr"`
+	}
+}
+
+// AuthnRequest builds an SP-initiated authentication request and returns its ID and XML
+func (s *SAMLService) AuthnRequest(conn *connection.Connection, sp ServiceProvider) (string, []byte, error) {
+	id, err := newSAMLID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	req := authnRequest{
+		ID:                          id,
+		Version:                     "2.0",
+		IssueInstant:                samlTime(s.now()),
+		Destination:                 conn.IdPSSOURL,
+		AssertionConsumerServiceURL: sp.ACSURL,
+		ProtocolBinding:             BindingPOSTURI,
+	}
+	req.Issuer.Value = sp.EntityID
+	req.NameIDPolicy.AllowCreate = true
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode AuthnRequest: %w", err)
+	}
+	return id, body, nil
+}
+
+// RedirectURL encodes a request for the HTTP-Redirect binding (DEFLATE, base64, query string)
+func RedirectURL(ssoURL string, request []byte, relayState string) (string, error) {
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(request); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	params.Set("RelayState", relayState)
+
+	separator := "?"
+	if strings.Contains(ssoURL, "?") {
+		separator = "&"
+	}
+	return ssoURL + separator + params.Encode(), nil
+}
+
+var postFormTemplate = template.Must(template.New("saml-post").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Redirecting...</title>
+    <meta charset="utf-8">
+</head>
+<body onload="document.forms[0].submit()">
+    <form method="post" action="{{.URL}}">
+        <input type="hidden" name="{{.Field}}" value="{{.Message}}">
+        <input type="hidden" name="RelayState" value="{{.RelayState}}">
+        <noscript><button type="submit">Continue</button></noscript>
+    </form>
+</body>
+</html>`))
+
+// PostForm renders the auto-submitting HTML form of the HTTP-POST binding
+// field is SAMLRequest or SAMLResponse
+func PostForm(w io.Writer, destination, field string, message []byte, relayState string) error {
+	return postFormTemplate.Execute(w, map[string]string{
+		"URL":        destination,
+		"Field":      field,
+		"Message":    base64.StdEncoding.EncodeToString(message),
+		"RelayState": relayState,
+	})
+}
+
+// ParseResponse validates a base64 SAMLResponse posted to the ACS URL in reply to requestID
+// Either the response or its single assertion must be signed with the connection's certificate
+func (s *SAMLService) ParseResponse(conn *connection.Connection, sp ServiceProvider, samlResponse, requestID string) (*Assertion, error) {
+	data, err := base64.StdEncoding.DecodeString(stripSpace(samlResponse))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed base64", ErrInvalidAssertion)
+	}
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+	if !root.is(nsSAMLP, "Response") {
+		return nil, fmt.Errorf("%w: not a SAML Response", ErrInvalidAssertion)
+	}
+
+	if status := root.child(nsSAMLP, "Status").child(nsSAMLP, "StatusCode").attr("Value"); status != statusSuccess {
+		return nil, fmt.Errorf("%w: identity provider returned status %q", ErrInvalidAssertion, status)
+	}
+	if len(root.children(nsSAML, "EncryptedAssertion")) > 0 {
+		return nil, fmt.Errorf("%w: encrypted assertions are not supported", ErrInvalidAssertion)
+	}
+
+	cert, err := conn.SigningCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+	// Values are only read from the signed content, so wrapped copies and comments are ignored
+	signed, err := verifySignature(root, cert)
+	if err == nil {
+		root = signed
+	} else if !errors.Is(err, errNotSigned) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+	assertions := root.children(nsSAML, "Assertion")
+	if len(assertions) != 1 {
+		return nil, fmt.Errorf("%w: expected exactly one assertion", ErrInvalidAssertion)
+	}
+	assertion := assertions[0]
+	if signed == nil {
+		if assertion, err = verifySignature(assertion, cert); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+		}
+	}
+
+	if destination := root.attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, fmt.Errorf("%w: unexpected destination", ErrInvalidAssertion)
+	}
+	if root.attr("InResponseTo") != requestID {
+		return nil, fmt.Errorf("%w: response is not for this login", ErrInvalidAssertion)
+	}
+	if issuer := root.child(nsSAML, "Issuer").text(); issuer != "" && issuer != conn.IdPEntityID {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidAssertion)
+	}
+
+	now := s.now()
+	if assertion.child(nsSAML, "Issuer").text() != conn.IdPEntityID {
+		return nil, fmt.Errorf("%w: unexpected assertion issuer", ErrInvalidAssertion)
+	}
+
+	subject := assertion.child(nsSAML, "Subject")
+	nameID := subject.child(nsSAML, "NameID")
+	if nameID.text() == "" {
+		return nil, fmt.Errorf("%w: missing NameID", ErrInvalidAssertion)
+	}
+	if err := s.checkBearer(subject, sp, requestID, now); err != nil {
+		return nil, err
+	}
+
+	conditions := assertion.child(nsSAML, "Conditions")
+	expiresAt, err := s.checkConditions(conditions, sp, now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Assertion{
+		ID:           assertion.attr("ID"),
+		NameID:       nameID.text(),
+		NameIDFormat: nameID.attr("Format"),
+		SessionIndex: assertion.child(nsSAML, "AuthnStatement").attr("SessionIndex"),
+		Attributes:   map[string][]string{},
+	}
+	for _, statement := range assertion.children(nsSAML, "AttributeStatement") {
+		for _, attribute := range statement.children(nsSAML, "Attribute") {
+			name := attribute.attr("Name")
+			for _, value := range attribute.children(nsSAML, "AttributeValue") {
+				result.Attributes[name] = append(result.Attributes[name], value.text())
+			}
+		}
+	}
+
+	if err := s.markSeen(conn.ID, result.ID, expiresAt); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkBearer requires a bearer subject confirmation for this ACS and login that has not expired
+func (s *SAMLService) checkBearer(subject *xmlElement, sp ServiceProvider, requestID string, now time.Time) error {
+	for _, confirmation := range subject.children(nsSAML, "SubjectConfirmation") {
+		if confirmation.attr("Method") != confirmationBearer {
+			continue
+		}
+		data := confirmation.child(nsSAML, "SubjectConfirmationData")
+		if data.attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if inResponseTo := data.attr("InResponseTo"); inResponseTo != "" && inResponseTo != requestID {
+			continue
+		}
+		notOnOrAfter, err := time.Parse(time.RFC3339, data.attr("NotOnOrAfter"))
+		if err != nil || !now.Add(-s.clockSkew).Before(notOnOrAfter) {
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no valid bearer subject confirmation", ErrInvalidAssertion)
+}
+
+// checkConditions validates the assertion's validity window and audience and returns its expiry
+func (s *SAMLService) checkConditions(conditions *xmlElement, sp ServiceProvider, now time.Time) (time.Time, error) {
+	if conditions == nil {
+		return time.Time{}, fmt.Errorf("%w: missing conditions", ErrInvalidAssertion)
+	}
+
+	if value := conditions.attr("NotBefore"); value != "" {
+		notBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil || now.Add(s.clockSkew).Before(notBefore) {
+			return time.Time{}, fmt.Errorf("%w: assertion is not yet valid", ErrInvalidAssertion)
+		}
+	}
+	notOnOrAfter, err := time.Parse(time.RFC3339, conditions.attr("NotOnOrAfter"))
+	if err != nil || !now.Add(-s.clockSkew).Before(notOnOrAfter) {
+		return time.Time{}, fmt.Errorf("%w: assertion has expired", ErrInvalidAssertion)
+	}
+
+	restrictions := conditions.children(nsSAML, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return time.Time{}, fmt.Errorf("%w: missing audience restriction", ErrInvalidAssertion)
+	}
+	// Every audience restriction must include Authway
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range restriction.children(nsSAML, "Audience") {
+			if audience.text() == sp.EntityID {
+				found = true
+			}
+		}
+		if !found {
+			return time.Time{}, fmt.Errorf("%w: assertion is intended for another audience", ErrInvalidAssertion)
+		}
+	}
+
+	return notOnOrAfter.Add(s.clockSkew), nil
+}
+
+// markSeen records an accepted assertion ID and rejects IDs already used
+func (s *SAMLService) markSeen(connectionID uuid.UUID, id string, expiresAt time.Time) error {
+	if id == "" {
+		return fmt.Errorf("%w: missing assertion ID", ErrInvalidAssertion)
+	}
+
+	if err := s.replays.MarkAssertionUsed(connectionID, id, expiresAt); err != nil {
+		if errors.Is(err, connection.ErrAssertionReplayed) {
+			return fmt.Errorf("%w: assertion was already used", ErrInvalidAssertion)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/connection"
+	"github.com/beevik/etree"
+	"github.com/google/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newReplayCache returns a database-backed replay cache like the one the server uses
+func newReplayCache(t testing.TB) ReplayCache {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&connection.UsedAssertion{}))
+	return connection.NewStateStore(db)
+}
+
+// testIdP signs SAML responses with a locally generated key pair
+type testIdP struct {
+	key     *rsa.PrivateKey
+	certDER []byte
+	certPEM string
+	certB64 string
+}
+
+func newTestIdP(t testing.TB) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test IdP"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return &testIdP{
+		key:     key,
+		certDER: der,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		certB64: base64.StdEncoding.EncodeToString(der),
+	}
+}
+
+// sign inserts an enveloped signature of the element with the given ID at the {{signature}} placeholder
+func (idp *testIdP) sign(t testing.TB, doc, id string) string {
+	parsed := etree.NewDocument()
+	require.NoError(t, parsed.ReadFromString(strings.Replace(doc, "{{signature}}", "", 1)))
+	el := parsed.FindElement("//[@ID='" + id + "']")
+	require.NotNil(t, el)
+
+	ctx, err := dsig.NewSigningContext(idp.key, [][]byte{idp.certDER})
+	require.NoError(t, err)
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	nsCtx, err := etreeutils.NSBuildParentContext(el)
+	require.NoError(t, err)
+	detached, err := etreeutils.NSDetatch(nsCtx, el)
+	require.NoError(t, err)
+	sig, err := ctx.ConstructSignature(detached, true)
+	require.NoError(t, err)
+
+	rendered := etree.NewDocument()
+	rendered.SetRoot(sig)
+	sigXML, err := rendered.WriteToString()
+	require.NoError(t, err)
+	return strings.Replace(doc, "{{signature}}", sigXML, 1)
+}
+
+type responseParams struct {
+	InResponseTo string
+	AssertionID  string
+	Audience     string
+	Email        string
+	IssuedAt     time.Time
+}
+
+func samlResponseXML(sp ServiceProvider, p responseParams) string {
+	notOnOrAfter := samlTime(p.IssuedAt.Add(5 * time.Minute))
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"` +
+		` ID="_resp1" Version="2.0" IssueInstant="` + samlTime(p.IssuedAt) + `" Destination="` + sp.ACSURL + `" InResponseTo="` + p.InResponseTo + `">` +
+		`<saml:Issuer>https://idp.acme.com</saml:Issuer>` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		`<saml:Assertion ID="` + p.AssertionID + `" Version="2.0" IssueInstant="` + samlTime(p.IssuedAt) + `">` +
+		`<saml:Issuer>https://idp.acme.com</saml:Issuer>{{signature}}` +
+		`<saml:Subject><saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">alice@acme.com</saml:NameID>` +
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">` +
+		`<saml:SubjectConfirmationData InResponseTo="` + p.InResponseTo + `" NotOnOrAfter="` + notOnOrAfter + `" Recipient="` + sp.ACSURL + `"/>` +
+		`</saml:SubjectConfirmation></saml:Subject>` +
+		`<saml:Conditions NotBefore="` + samlTime(p.IssuedAt) + `" NotOnOrAfter="` + notOnOrAfter + `">` +
+		`<saml:AudienceRestriction><saml:Audience>` + p.Audience + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>` +
+		`<saml:AuthnStatement AuthnInstant="` + samlTime(p.IssuedAt) + `" SessionIndex="_session1"/>` +
+		`<saml:AttributeStatement>` +
+		`<saml:Attribute Name="mail"><saml:AttributeValue>` + p.Email + `</saml:AttributeValue></saml:Attribute>` +
+		`<saml:Attribute Name="displayName"><saml:AttributeValue>Alice &amp; Co</saml:AttributeValue></saml:Attribute>` +
+		`</saml:AttributeStatement></saml:Assertion></samlp:Response>`
+}
+
+func setupSAML(t *testing.T) (*SAMLService, *testIdP, *connection.Connection, ServiceProvider) {
+	idp := newTestIdP(t)
+	conn := &connection.Connection{
+		Type:           connection.TypeSAML,
+		IdPEntityID:    "https://idp.acme.com",
+		IdPSSOURL:      "https://idp.acme.com/sso",
+		IdPSSOBinding:  connection.BindingRedirect,
+		IdPCertificate: idp.certPEM,
+	}
+	sp := ServiceProvider{
+		EntityID: "http://localhost:8080/auth/sso/saml/1/metadata",
+		ACSURL:   "http://localhost:8080/auth/sso/saml/1/acs",
+	}
+	return NewSAMLService(0, newReplayCache(t), zap.NewNop()), idp, conn, sp
+}
+
+func encodeResponse(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestSAMLService_ParseResponse(t *testing.T) {
+	service, idp, conn, sp := setupSAML(t)
+	now := time.Now()
+	params := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: now}
+	signed := idp.sign(t, samlResponseXML(sp, params), "_a1")
+
+	assertion, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@acme.com", assertion.NameID)
+	assert.Equal(t, NameIDFormatEmail, assertion.NameIDFormat)
+	assert.Equal(t, "_session1", assertion.SessionIndex)
+	assert.Equal(t, []string{"alice@acme.com"}, assertion.Attributes["mail"])
+	assert.Equal(t, []string{"Alice & Co"}, assertion.Attributes["displayName"])
+
+	// The same assertion cannot be used twice
+	_, err = service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	assert.ErrorIs(t, err, ErrInvalidAssertion)
+	assert.Contains(t, err.Error(), "already used")
+}
+
+func TestSAMLService_CommentInNameID(t *testing.T) {
+	service, idp, conn, sp := setupSAML(t)
+	params := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: time.Now()}
+	doc := strings.Replace(samlResponseXML(sp, params), ">alice@acme.com</saml:NameID>", ">alice@acme.com<!---->.evil.com</saml:NameID>", 1)
+	signed := idp.sign(t, doc, "_a1")
+
+	// The comment must not truncate the NameID the IdP signed
+	assertion, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@acme.com.evil.com", assertion.NameID)
+}
+
+// noReplays accepts every assertion, keeping fuzzing off the database
+type noReplays struct{}
+
+func (noReplays) MarkAssertionUsed(uuid.UUID, string, time.Time) error { return nil }
+
+func FuzzSAMLService_ParseResponse(f *testing.F) {
+	idp := newTestIdP(f)
+	sp := ServiceProvider{EntityID: "http://localhost:8080/auth/sso/saml/1/metadata", ACSURL: "http://localhost:8080/auth/sso/saml/1/acs"}
+	params := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: time.Now()}
+	f.Add([]byte(idp.sign(f, samlResponseXML(sp, params), "_a1")))
+	f.Add([]byte(strings.Replace(samlResponseXML(sp, params), "{{signature}}", "", 1)))
+	f.Add([]byte(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"><!DOCTYPE x></samlp:Response>`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		service := NewSAMLService(0, noReplays{}, zap.NewNop())
+		conn := &connection.Connection{Type: connection.TypeSAML, IdPEntityID: "https://idp.acme.com", IdPCertificate: idp.certPEM}
+
+		// Malformed and forged responses must fail cleanly; a response that passes must carry a subject
+		assertion, err := service.ParseResponse(conn, sp, encodeResponse(string(data)), "_req1")
+		if err == nil {
+			assert.NotEmpty(t, assertion.NameID)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidAssertion)
+		}
+	})
+}
+
+func TestSAMLService_ReplayAcrossInstances(t *testing.T) {
+	first, idp, conn, sp := setupSAML(t)
+	second := NewSAMLService(0, first.replays, zap.NewNop())
+	params := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: time.Now()}
+	signed := idp.sign(t, samlResponseXML(sp, params), "_a1")
+
+	_, err := first.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	require.NoError(t, err)
+
+	// Another instance sharing the replay cache rejects the assertion
+	_, err = second.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	assert.ErrorIs(t, err, ErrInvalidAssertion)
+	assert.Contains(t, err.Error(), "already used")
+}
+
+func TestSAMLService_RejectsInvalidResponses(t *testing.T) {
+	service, idp, conn, sp := setupSAML(t)
+	now := time.Now()
+	valid := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: now}
+
+	t.Run("tampered attribute", func(t *testing.T) {
+		signed := idp.sign(t, samlResponseXML(sp, valid), "_a1")
+		tampered := strings.Replace(signed, "<saml:AttributeValue>alice@acme.com", "<saml:AttributeValue>mallory@acme.com", 1)
+		_, err := service.ParseResponse(conn, sp, encodeResponse(tampered), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+		assert.Contains(t, err.Error(), "signature")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		unsigned := strings.Replace(samlResponseXML(sp, valid), "{{signature}}", "", 1)
+		_, err := service.ParseResponse(conn, sp, encodeResponse(unsigned), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+	})
+
+	t.Run("signed by another key", func(t *testing.T) {
+		other := newTestIdP(t)
+		signed := other.sign(t, samlResponseXML(sp, valid), "_a1")
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+	})
+
+	t.Run("wrapped second assertion", func(t *testing.T) {
+		signed := idp.sign(t, samlResponseXML(sp, valid), "_a1")
+		evil := `<saml:Assertion ID="_evil"><saml:Issuer>https://idp.acme.com</saml:Issuer></saml:Assertion></samlp:Response>`
+		wrapped := strings.Replace(signed, "</samlp:Response>", evil, 1)
+		_, err := service.ParseResponse(conn, sp, encodeResponse(wrapped), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+	})
+
+	t.Run("signature wrapped in a forged assertion", func(t *testing.T) {
+		signed := idp.sign(t, samlResponseXML(sp, valid), "_a1")
+		start, end := strings.Index(signed, "<saml:Assertion "), strings.Index(signed, "</samlp:Response>")
+		original := signed[start:end]
+		forged := strings.Replace(original, "alice@acme.com", "mallory@acme.com", -1)
+		forged = strings.Replace(forged, "</saml:Assertion>", "<saml:Advice>"+original+"</saml:Advice></saml:Assertion>", 1)
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed[:start]+forged+signed[end:]), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+		assert.Contains(t, err.Error(), "signature")
+	})
+
+	t.Run("duplicate IDs", func(t *testing.T) {
+		signed := idp.sign(t, samlResponseXML(sp, valid), "_a1")
+		start, end := strings.Index(signed, "<saml:Assertion "), strings.Index(signed, "</samlp:Response>")
+		original := signed[start:end]
+		forged := strings.Replace(original, "alice@acme.com", "mallory@acme.com", -1)
+		hidden := "<samlp:Extensions>" + original + "</samlp:Extensions>"
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed[:start]+hidden+forged+signed[end:]), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+		assert.Contains(t, err.Error(), "signature")
+	})
+
+	t.Run("other login", func(t *testing.T) {
+		signed := idp.sign(t, samlResponseXML(sp, valid), "_a1")
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req2")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+	})
+
+	t.Run("other audience", func(t *testing.T) {
+		params := valid
+		params.AssertionID = "_a2"
+		params.Audience = "https://other-sp.example.com"
+		signed := idp.sign(t, samlResponseXML(sp, params), "_a2")
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+		assert.Contains(t, err.Error(), "audience")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		params := valid
+		params.AssertionID = "_a3"
+		params.IssuedAt = now.Add(-10 * time.Minute)
+		signed := idp.sign(t, samlResponseXML(sp, params), "_a3")
+		_, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+		assert.ErrorIs(t, err, ErrInvalidAssertion)
+	})
+}
+
+func TestSAMLService_ClockSkew(t *testing.T) {
+	service, idp, conn, sp := setupSAML(t)
+
+	// Issued by an IdP whose clock is two minutes ahead: within the default skew
+	params := responseParams{InResponseTo: "_req1", AssertionID: "_a1", Audience: sp.EntityID, Email: "alice@acme.com", IssuedAt: time.Now().Add(2 * time.Minute)}
+	signed := idp.sign(t, samlResponseXML(sp, params), "_a1")
+	_, err := service.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	require.NoError(t, err)
+
+	strict := NewSAMLService(time.Second, newReplayCache(t), zap.NewNop())
+	params.AssertionID = "_a2"
+	signed = idp.sign(t, samlResponseXML(sp, params), "_a2")
+	_, err = strict.ParseResponse(conn, sp, encodeResponse(signed), "_req1")
+	assert.ErrorIs(t, err, ErrInvalidAssertion)
+	assert.Contains(t, err.Error(), "not yet valid")
+}
+
+func TestSAMLService_AuthnRequestRedirect(t *testing.T) {
+	service, _, conn, sp := setupSAML(t)
+
+	id, request, err := service.AuthnRequest(conn, sp)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id, "_"))
+
+	redirect, err := RedirectURL(conn.IdPSSOURL, request, "relay-1")
+	require.NoError(t, err)
+	parsed, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, "relay-1", parsed.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(parsed.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(t, err)
+
+	root, err := parseXML(inflated)
+	require.NoError(t, err)
+	assert.True(t, root.is(nsSAMLP, "AuthnRequest"))
+	assert.Equal(t, id, root.attr("ID"))
+	assert.Equal(t, sp.ACSURL, root.attr("AssertionConsumerServiceURL"))
+	assert.Equal(t, sp.EntityID, root.child(nsSAML, "Issuer").text())
+
+	var form bytes.Buffer
+	require.NoError(t, PostForm(&form, conn.IdPSSOURL, "SAMLRequest", request, "relay-1"))
+	assert.Contains(t, form.String(), `action="https://idp.acme.com/sso"`)
+}
+
+func TestParseIdPMetadata(t *testing.T) {
+	idp := newTestIdP(t)
+	metadata := `<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.acme.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>ignored</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+      ` + idp.certB64 + `
+    </ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.acme.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.acme.com/sso/redirect"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+	parsed, err := ParseIdPMetadata([]byte(metadata))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.acme.com", parsed.EntityID)
+	assert.Equal(t, "https://idp.acme.com/sso/redirect", parsed.SSOURL)
+	assert.Equal(t, connection.BindingRedirect, parsed.SSOBinding)
+	_, err = connection.ParseCertificate(parsed.Certificate)
+	assert.NoError(t, err)
+
+	_, err = ParseIdPMetadata([]byte(`<!DOCTYPE x [<!ENTITY a "b">]><x/>`))
+	assert.Error(t, err)
+}
+
+func TestSAMLService_Metadata(t *testing.T) {
+	service, _, _, sp := setupSAML(t)
+
+	body, err := service.Metadata(sp)
+	require.NoError(t, err)
+
+	root, err := parseXML(body)
+	require.NoError(t, err)
+	assert.Equal(t, sp.EntityID, root.attr("entityID"))
+	acs := root.child(nsMD, "SPSSODescriptor").child(nsMD, "AssertionConsumerService")
+	assert.Equal(t, sp.ACSURL, acs.attr("Location"))
+	assert.Equal(t, BindingPOSTURI, acs.attr("Binding"))
+}
@@ -0,0 +1,254 @@
+package sso
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// nsDSig is the XML Signature namespace
+const nsDSig = dsig.Namespace
+
+var (
+	// errNotSigned is returned when an element carries no enveloped signature
+	errNotSigned = errors.New("element is not signed")
+
+	// ErrInvalidSignature is returned when an XML signature does not verify
+	ErrInvalidSignature = errors.New("invalid XML signature")
+)
+
+// xmlElement adds nil-safe, namespace-aware lookups to an etree element for reading SAML messages
+type xmlElement struct {
+	*etree.Element
+}
+
+// parseXML reads a document and returns its root element; DTDs are rejected
+func parseXML(data []byte) (*xmlElement, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("malformed XML: %w", err)
+	}
+
+	var root *etree.Element
+	for _, token := range doc.Child {
+		switch t := token.(type) {
+		case *etree.Directive:
+			return nil, errors.New("XML with DTDs is not accepted")
+		case *etree.Element:
+			if root != nil {
+				return nil, errors.New("malformed XML: multiple root elements")
+			}
+			root = t
+		}
+	}
+	if root == nil {
+		return nil, errors.New("malformed XML: incomplete document")
+	}
+	if hasDirective(root) {
+		return nil, errors.New("XML with DTDs is not accepted")
+	}
+	return &xmlElement{root}, nil
+}
+
+func hasDirective(el *etree.Element) bool {
+	for _, token := range el.Child {
+		switch t := token.(type) {
+		case *etree.Directive:
+			return true
+		case *etree.Element:
+			if hasDirective(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attr returns the value of an unqualified attribute
+func (e *xmlElement) attr(name string) string {
+	if e == nil {
+		return ""
+	}
+	for _, a := range e.Attr {
+		if a.Space == "" && a.Key == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (e *xmlElement) is(space, local string) bool {
+	return e != nil && e.Tag == local && e.NamespaceURI() == space
+}
+
+// children returns the direct child elements with the given namespace and name
+func (e *xmlElement) children(space, local string) []*xmlElement {
+	if e == nil {
+		return nil
+	}
+	var found []*xmlElement
+	for _, c := range e.ChildElements() {
+		if el := (&xmlElement{c}); el.is(space, local) {
+			found = append(found, el)
+		}
+	}
+	return found
+}
+
+// child returns the first direct child element with the given namespace and name
+func (e *xmlElement) child(space, local string) *xmlElement {
+	if found := e.children(space, local); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// text returns the concatenated character data of the element, trimmed
+// Comments split character data; every part is kept so a comment cannot truncate a signed value
+func (e *xmlElement) text() string {
+	if e == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range e.Child {
+		if data, ok := c.(*etree.CharData); ok {
+			b.WriteString(data.Data)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+func escapeAttr(s string) string { return attrEscaper.Replace(s) }
+
+// verifySignature checks the enveloped signature of el against the signer's certificate and
+// returns the signed content, re-read from its canonical form
+// Values must only be read from the returned element: it leaves out anything the signature does not cover
+func verifySignature(el *xmlElement, cert *x509.Certificate) (*xmlElement, error) {
+	detached, err := detach(el.Element)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	ctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: []*x509.Certificate{cert}})
+	signed, err := ctx.Validate(detached)
+	if errors.Is(err, dsig.ErrMissingSignature) {
+		return nil, errNotSigned
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return &xmlElement{signed}, nil
+}
+
+// detach copies el with the namespace declarations it inherits, so it can be canonicalized on its own
+func detach(el *etree.Element) (*etree.Element, error) {
+	ctx, err := etreeutils.NSBuildParentContext(el)
+	if err != nil {
+		return nil, err
+	}
+	return etreeutils.NSDetatch(ctx, el)
+}
+
+func stripSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// signEnveloped adds an enveloped exc-c14n signature to the element with the given ID,
+// right after its Issuer as SAML requires
+func signEnveloped(doc []byte, id string, key crypto.Signer, cert *x509.Certificate) ([]byte, error) {
+	root, err := parseXML(doc)
+	if err != nil {
+		return nil, err
+	}
+	el := findByID(root, id)
+	if el == nil {
+		return nil, fmt.Errorf("no element with ID %q", id)
+	}
+
+	signatureMethod := dsig.RSASHA256SignatureMethod
+	if _, ok := key.Public().(*ecdsa.PublicKey); ok {
+		key = ecdsaXMLSigner{key}
+		signatureMethod = dsig.ECDSASHA256SignatureMethod
+	}
+	ctx, err := dsig.NewSigningContext(key, [][]byte{cert.Raw})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.SetSignatureMethod(signatureMethod); err != nil {
+		return nil, err
+	}
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	// Canonicalization rewrites the element it is given
+	detached, err := detach(el.Element)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ctx.ConstructSignature(detached, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	position := 0
+	if issuer := el.child(nsSAML, "Issuer"); issuer != nil {
+		position = issuer.Index() + 1
+	}
+	el.InsertChildAt(position, sig)
+
+	signed := etree.NewDocument()
+	signed.SetRoot(root.Element)
+	return signed.WriteToBytes()
+}
+
+// ecdsaXMLSigner encodes ECDSA signatures as r || s, as XML Signature requires, instead of ASN.1
+type ecdsaXMLSigner struct {
+	crypto.Signer
+}
+
+func (s ecdsaXMLSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	der, err := s.Signer.Sign(rand, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ecdsaRawSignature(der, s.Public().(*ecdsa.PublicKey))
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to the r || s encoding of XML Signature
+func ecdsaRawSignature(der []byte, key *ecdsa.PublicKey) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+// findByID returns the element of the tree with the given ID attribute
+func findByID(el *xmlElement, id string) *xmlElement {
+	if el.attr("ID") == id {
+		return el
+	}
+	for _, c := range el.ChildElements() {
+		if found := findByID(&xmlElement{c}, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
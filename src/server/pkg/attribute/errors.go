@@ -0,0 +1,39 @@
+package attribute
+
+import (
+	"errors"
+	"strings"
+)
+
+// Attribute-specific errors
+var (
+	// ErrNotFound is returned when an attribute definition is not found
+	ErrNotFound = errors.New("attribute definition not found")
+
+	// ErrDuplicateKey is returned when the tenant already defines an attribute with the same key
+	ErrDuplicateKey = errors.New("attribute with this key already exists")
+
+	// ErrReservedClaim is returned when an attribute would be issued as a claim Authway already sets
+	ErrReservedClaim = errors.New("claim name is reserved")
+
+	// ErrInvalidDefinition is returned when a definition's options do not fit its type
+	ErrInvalidDefinition = errors.New("invalid attribute definition")
+)
+
+// ValueError describes why a single attribute value was rejected
+type ValueError struct {
+	Key     string `json:"key"`
+	Rule    string `json:"rule"` // unknown, readonly, required, type, unique
+	Message string `json:"message"`
+}
+
+// ValueErrors lists the rejected attribute values of a create or update
+type ValueErrors []ValueError
+
+func (e ValueErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, ve := range e {
+		messages[i] = ve.Key + " " + ve.Message
+	}
+	return "invalid attributes: " + strings.Join(messages, "; ")
+}
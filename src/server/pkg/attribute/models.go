@@ -0,0 +1,136 @@
+package attribute
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attribute value types
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date" // YYYY-MM-DD
+	TypeEnum    = "enum" // One of the definition's options
+)
+
+// DateLayout is the format of date attribute values
+const DateLayout = "2006-01-02"
+
+// MaxStringLength bounds string attribute values
+const MaxStringLength = 1024
+
+// DefaultScope is the scope that releases an attribute's claim when the definition sets none
+const DefaultScope = "profile"
+
+// keyPattern restricts keys to names that are safe as JSON paths and claim names
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// Definition describes a custom user attribute of a tenant
+// Values are stored in users.attributes keyed by Key
+type Definition struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_user_attribute_definitions_key"`
+	Key          string    `json:"key" gorm:"not null;uniqueIndex:idx_user_attribute_definitions_key"`
+	DisplayName  string    `json:"display_name" gorm:"not null"`
+	Type         string    `json:"type" gorm:"not null"`
+	Options      Options   `json:"options,omitempty" gorm:"type:jsonb"` // Allowed values of enum attributes
+	Required     bool      `json:"required" gorm:"not null;default:false"`
+	Unique       bool      `json:"unique" gorm:"not null;default:false"`        // No two users of the tenant share a value
+	UserEditable bool      `json:"user_editable" gorm:"not null;default:false"` // Users can set it through self-service
+	InToken      bool      `json:"in_token" gorm:"not null;default:false"`      // Issued as a claim when Scope is granted
+	ClaimName    string    `json:"claim_name" gorm:"not null;default:''"`       // Defaults to Key
+	Scope        string    `json:"scope" gorm:"not null;default:''"`            // Defaults to DefaultScope
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Definition model
+func (Definition) TableName() string {
+	return "user_attribute_definitions"
+}
+
+// BeforeCreate sets UUID if not provided
+func (d *Definition) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// Claim returns the name of the token claim carrying the attribute
+func (d *Definition) Claim() string {
+	if d.ClaimName != "" {
+		return d.ClaimName
+	}
+	return d.Key
+}
+
+// ReleasedBy returns the scope that releases the attribute's claim
+func (d *Definition) ReleasedBy() string {
+	if d.Scope != "" {
+		return d.Scope
+	}
+	return DefaultScope
+}
+
+// Options lists the allowed values of an enum attribute
+type Options []string
+
+// Scan implements sql.Scanner for Options (JSONB support)
+func (o *Options) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for Options (JSONB support)
+func (o Options) Value() (driver.Value, error) {
+	if o == nil {
+		return "[]", nil
+	}
+	return json.Marshal(o)
+}
+
+// CreateDefinitionRequest represents the request to define a custom attribute
+type CreateDefinitionRequest struct {
+	TenantID     string   `json:"tenant_id" validate:"required,uuid"`
+	Key          string   `json:"key" validate:"required,max=63"`
+	DisplayName  string   `json:"display_name" validate:"max=255"`
+	Type         string   `json:"type" validate:"required,oneof=string number boolean date enum"`
+	Options      []string `json:"options" validate:"max=100,dive,min=1,max=255"`
+	Required     bool     `json:"required"`
+	Unique       bool     `json:"unique"`
+	UserEditable bool     `json:"user_editable"`
+	InToken      bool     `json:"in_token"`
+	ClaimName    string   `json:"claim_name" validate:"max=63"`
+	Scope        string   `json:"scope" validate:"max=255"`
+}
+
+// UpdateDefinitionRequest represents the request to update an attribute definition
+// The key and type cannot change because stored values depend on them
+type UpdateDefinitionRequest struct {
+	DisplayName  *string  `json:"display_name" validate:"omitempty,max=255"`
+	Options      []string `json:"options" validate:"omitempty,max=100,dive,min=1,max=255"`
+	Required     *bool    `json:"required"`
+	Unique       *bool    `json:"unique"`
+	UserEditable *bool    `json:"user_editable"`
+	InToken      *bool    `json:"in_token"`
+	ClaimName    *string  `json:"claim_name" validate:"omitempty,max=63"`
+	Scope        *string  `json:"scope" validate:"omitempty,max=255"`
+}
@@ -0,0 +1,453 @@
+package attribute
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/consent"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Service manages tenants' custom attribute definitions and validates users' attribute values
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateDefinitionRequest) (*Definition, error)
+	Get(id uuid.UUID) (*Definition, error)
+	List(tenantID uuid.UUID) ([]*Definition, error)
+	Update(id uuid.UUID, req *UpdateDefinitionRequest) (*Definition, error)
+	Delete(id uuid.UUID) error
+
+	// Validate applies changes (null removes a value) to the attributes of target, or of a new user when
+	// target is nil, and returns the resulting values; rejected values are reported as ValueErrors
+	// Self-service callers can only write user-editable attributes
+	Validate(tenantID uuid.UUID, target *user.User, changes map[string]interface{}, selfService bool) (user.Attributes, error)
+
+	// Claims returns the claims of the user's attributes that are issued in tokens for the granted scopes
+	Claims(u *user.User, grantedScopes []string) (map[string]interface{}, error)
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// reservedClaims are set by Hydra or Authway and cannot carry an attribute
+var reservedClaims = []string{
+	"sub", "iss", "aud", "exp", "nbf", "iat", "jti", "auth_time", "nonce", "acr", "amr", "azp",
+	"at_hash", "c_hash", "sid", "scp", "client_id", "ext",
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateDefinitionRequest) (*Definition, error) {
+	def := &Definition{
+		TenantID:     tenantID,
+		Key:          req.Key,
+		DisplayName:  req.DisplayName,
+		Type:         req.Type,
+		Options:      req.Options,
+		Required:     req.Required,
+		Unique:       req.Unique,
+		UserEditable: req.UserEditable,
+		InToken:      req.InToken,
+		ClaimName:    req.ClaimName,
+		Scope:        req.Scope,
+	}
+	if def.DisplayName == "" {
+		def.DisplayName = def.Key
+	}
+	if err := s.check(def); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(def).Error; err != nil {
+		s.logger.Error("Failed to create attribute definition", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create attribute definition: %w", err)
+	}
+
+	s.logger.Info("Attribute definition created", zap.String("id", def.ID.String()), zap.String("key", def.Key), zap.String("tenant_id", tenantID.String()))
+	return def, nil
+}
+
+func (s *service) Get(id uuid.UUID) (*Definition, error) {
+	var def Definition
+	if err := s.db.Where("id = ?", id).First(&def).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get attribute definition: %w", err)
+	}
+	return &def, nil
+}
+
+func (s *service) List(tenantID uuid.UUID) ([]*Definition, error) {
+	var defs []*Definition
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("key ASC").Find(&defs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list attribute definitions: %w", err)
+	}
+	return defs, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateDefinitionRequest) (*Definition, error) {
+	def, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	wasUnique := def.Unique
+
+	if req.DisplayName != nil && *req.DisplayName != "" {
+		def.DisplayName = *req.DisplayName
+	}
+	if req.Options != nil {
+		def.Options = req.Options
+	}
+	if req.Required != nil {
+		def.Required = *req.Required
+	}
+	if req.Unique != nil {
+		def.Unique = *req.Unique
+	}
+	if req.UserEditable != nil {
+		def.UserEditable = *req.UserEditable
+	}
+	if req.InToken != nil {
+		def.InToken = *req.InToken
+	}
+	if req.ClaimName != nil {
+		def.ClaimName = *req.ClaimName
+	}
+	if req.Scope != nil {
+		def.Scope = *req.Scope
+	}
+	if err := s.check(def); err != nil {
+		return nil, err
+	}
+
+	// Values stored while the attribute was not unique may already collide
+	if def.Unique && !wasUnique {
+		duplicated, err := s.hasDuplicates(def)
+		if err != nil {
+			return nil, err
+		}
+		if duplicated {
+			return nil, fmt.Errorf("%w: users already share values of %s", ErrInvalidDefinition, def.Key)
+		}
+	}
+
+	if err := s.db.Save(def).Error; err != nil {
+		s.logger.Error("Failed to update attribute definition", zap.Error(err), zap.String("id", id.String()))
+		return nil, fmt.Errorf("failed to update attribute definition: %w", err)
+	}
+
+	s.logger.Info("Attribute definition updated", zap.String("id", def.ID.String()), zap.String("key", def.Key))
+	return def, nil
+}
+
+// Delete removes the definition and the users' values of the attribute
+func (s *service) Delete(id uuid.UUID) error {
+	def, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(def).Error; err != nil {
+			return err
+		}
+		return tx.Model(&user.User{}).
+			Where("tenant_id = ? AND "+s.valueColumn(def.Key)+" IS NOT NULL", def.TenantID).
+			Update("attributes", gorm.Expr(s.removeValue(def.Key))).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to delete attribute definition", zap.Error(err), zap.String("id", id.String()))
+		return fmt.Errorf("failed to delete attribute definition: %w", err)
+	}
+
+	s.logger.Info("Attribute definition deleted", zap.String("id", id.String()), zap.String("key", def.Key))
+	return nil
+}
+
+func (s *service) Validate(tenantID uuid.UUID, target *user.User, changes map[string]interface{}, selfService bool) (user.Attributes, error) {
+	defs, err := s.List(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*Definition, len(defs))
+	for _, def := range defs {
+		byKey[def.Key] = def
+	}
+
+	values := user.Attributes{}
+	userID := uuid.Nil
+	if target != nil {
+		userID = target.ID
+		for key, value := range target.Attributes {
+			values[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ValueErrors
+	var written []*Definition
+	for _, key := range keys {
+		def, ok := byKey[key]
+		if !ok {
+			errs = append(errs, ValueError{Key: key, Rule: "unknown", Message: "is not a defined attribute"})
+			continue
+		}
+		if selfService && !def.UserEditable {
+			errs = append(errs, ValueError{Key: key, Rule: "readonly", Message: "cannot be changed"})
+			continue
+		}
+
+		raw := changes[key]
+		if text, ok := raw.(string); ok && text == "" {
+			raw = nil
+		}
+		if raw == nil {
+			if def.Required {
+				errs = append(errs, ValueError{Key: key, Rule: "required", Message: "is required"})
+			}
+			delete(values, key)
+			continue
+		}
+
+		value, problem := def.normalize(raw)
+		if problem != "" {
+			errs = append(errs, ValueError{Key: key, Rule: "type", Message: problem})
+			continue
+		}
+		values[key] = value
+		written = append(written, def)
+	}
+
+	// Existing users may lack values of attributes made required later; only new users must have them all
+	if target == nil {
+		for _, def := range defs {
+			if !def.Required || (selfService && !def.UserEditable) {
+				continue
+			}
+			// Values sent in changes were checked above
+			if _, sent := changes[def.Key]; !sent {
+				errs = append(errs, ValueError{Key: def.Key, Rule: "required", Message: "is required"})
+			}
+		}
+	}
+
+	for _, def := range written {
+		if !def.Unique {
+			continue
+		}
+		taken, err := s.valueTaken(tenantID, userID, def.Key, values[def.Key])
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			errs = append(errs, ValueError{Key: def.Key, Rule: "unique", Message: "is already used by another user"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return values, nil
+}
+
+func (s *service) Claims(u *user.User, grantedScopes []string) (map[string]interface{}, error) {
+	claims := map[string]interface{}{}
+	if len(u.Attributes) == 0 {
+		return claims, nil
+	}
+
+	defs, err := s.List(u.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range defs {
+		if !def.InToken || !containsString(grantedScopes, def.ReleasedBy()) {
+			continue
+		}
+		if value, ok := u.Attributes[def.Key]; ok {
+			claims[def.Claim()] = value
+		}
+	}
+	return claims, nil
+}
+
+// check validates a new or updated definition against the tenant's other definitions
+func (s *service) check(def *Definition) error {
+	if !keyPattern.MatchString(def.Key) {
+		return fmt.Errorf("%w: key must start with a lowercase letter and contain only lowercase letters, digits and underscores", ErrInvalidDefinition)
+	}
+	if def.ClaimName != "" && !keyPattern.MatchString(def.ClaimName) {
+		return fmt.Errorf("%w: claim_name must start with a lowercase letter and contain only lowercase letters, digits and underscores", ErrInvalidDefinition)
+	}
+	if strings.ContainsAny(def.Scope, " \t\n") {
+		return fmt.Errorf("%w: scope must be a single scope", ErrInvalidDefinition)
+	}
+	if def.Type == TypeEnum && len(def.Options) == 0 {
+		return fmt.Errorf("%w: enum attributes need options", ErrInvalidDefinition)
+	}
+	if def.Type != TypeEnum && len(def.Options) > 0 {
+		return fmt.Errorf("%w: only enum attributes have options", ErrInvalidDefinition)
+	}
+	if def.Type == TypeBoolean && def.Unique {
+		return fmt.Errorf("%w: boolean attributes cannot be unique", ErrInvalidDefinition)
+	}
+	if def.InToken && reservedClaim(def.Claim()) {
+		return fmt.Errorf("%w: %s", ErrReservedClaim, def.Claim())
+	}
+
+	var others []*Definition
+	if err := s.db.Where("tenant_id = ? AND id <> ?", def.TenantID, def.ID).Find(&others).Error; err != nil {
+		return fmt.Errorf("failed to check attribute definitions: %w", err)
+	}
+	for _, other := range others {
+		if other.Key == def.Key {
+			return ErrDuplicateKey
+		}
+		if def.InToken && other.InToken && other.Claim() == def.Claim() {
+			return fmt.Errorf("%w: claim %s is already issued for %s", ErrInvalidDefinition, def.Claim(), other.Key)
+		}
+	}
+	return nil
+}
+
+// normalize converts a decoded JSON value to the definition's type, or describes why it does not fit
+func (d *Definition) normalize(raw interface{}) (interface{}, string) {
+	switch d.Type {
+	case TypeString:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, "must be a string"
+		}
+		if len(text) > MaxStringLength {
+			return nil, fmt.Sprintf("must be at most %d characters", MaxStringLength)
+		}
+		return text, ""
+	case TypeNumber:
+		number, ok := raw.(float64)
+		if !ok {
+			return nil, "must be a number"
+		}
+		return number, ""
+	case TypeBoolean:
+		flag, ok := raw.(bool)
+		if !ok {
+			return nil, "must be true or false"
+		}
+		return flag, ""
+	case TypeDate:
+		text, ok := raw.(string)
+		if !ok {
+			return nil, "must be a date (YYYY-MM-DD)"
+		}
+		if _, err := time.Parse(DateLayout, text); err != nil {
+			return nil, "must be a date (YYYY-MM-DD)"
+		}
+		return text, ""
+	case TypeEnum:
+		text, ok := raw.(string)
+		if !ok || !containsString(d.Options, text) {
+			return nil, "must be one of: " + strings.Join(d.Options, ", ")
+		}
+		return text, ""
+	default:
+		return nil, "has an unsupported type"
+	}
+}
+
+// valueTaken reports whether another user of the tenant has the value
+func (s *service) valueTaken(tenantID, userID uuid.UUID, key string, value interface{}) (bool, error) {
+	var count int64
+	err := s.db.Model(&user.User{}).
+		Where("tenant_id = ? AND id <> ? AND "+s.valueColumn(key)+" = ?", tenantID, userID, valueText(value)).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check attribute uniqueness: %w", err)
+	}
+	return count > 0, nil
+}
+
+// hasDuplicates reports whether users of the definition's tenant share a value of the attribute
+func (s *service) hasDuplicates(def *Definition) (bool, error) {
+	column := s.valueColumn(def.Key)
+	var duplicates []string
+	err := s.db.Model(&user.User{}).
+		Select(column).
+		Where("tenant_id = ? AND "+column+" IS NOT NULL", def.TenantID).
+		Group(column).
+		Having("COUNT(*) > 1").
+		Limit(1).
+		Pluck(column, &duplicates).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check attribute uniqueness: %w", err)
+	}
+	return len(duplicates) > 0, nil
+}
+
+// valueColumn is the SQL expression of a user's attribute value as text
+// Keys match keyPattern, so they are safe to inline
+func (s *service) valueColumn(key string) string {
+	if s.db.Dialector.Name() == "sqlite" {
+		return "CAST(json_extract(attributes, '$." + key + "') AS TEXT)"
+	}
+	return "attributes->>'" + key + "'"
+}
+
+// removeValue is the SQL expression of the users' attributes without the key
+func (s *service) removeValue(key string) string {
+	if s.db.Dialector.Name() == "sqlite" {
+		return "json_remove(attributes, '$." + key + "')"
+	}
+	return "attributes - '" + key + "'"
+}
+
+// valueText formats a value the way the JSON text operators return it
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func reservedClaim(claim string) bool {
+	if containsString(reservedClaims, claim) {
+		return true
+	}
+	for _, claims := range consent.DefaultScopeClaims {
+		if containsString(claims, claim) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
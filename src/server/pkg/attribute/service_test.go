@@ -0,0 +1,196 @@
+package attribute
+
+import (
+	"testing"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&user.User{}, &Definition{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, tenantID uuid.UUID, email string, attributes user.Attributes) *user.User {
+	u := &user.User{TenantID: tenantID, Email: email, PasswordHash: "x", Attributes: attributes}
+	require.NoError(t, db.Create(u).Error)
+	return u
+}
+
+func valueErrorRules(t *testing.T, err error) map[string]string {
+	var errs ValueErrors
+	require.ErrorAs(t, err, &errs)
+	rules := map[string]string{}
+	for _, e := range errs {
+		rules[e.Key] = e.Rule
+	}
+	return rules
+}
+
+func TestService_Definitions(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	created, err := service.Create(tenantID, &CreateDefinitionRequest{Key: "department", Type: TypeEnum, Options: []string{"sales", "engineering"}, InToken: true})
+	require.NoError(t, err)
+	assert.Equal(t, "department", created.DisplayName)
+	assert.Equal(t, "department", created.Claim())
+	assert.Equal(t, DefaultScope, created.ReleasedBy())
+
+	_, err = service.Create(tenantID, &CreateDefinitionRequest{Key: "department", Type: TypeString})
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	// Other tenants have their own keys
+	_, err = service.Create(uuid.New(), &CreateDefinitionRequest{Key: "department", Type: TypeString})
+	assert.NoError(t, err)
+
+	invalid := []*CreateDefinitionRequest{
+		{Key: "Department", Type: TypeString},
+		{Key: "team", Type: TypeEnum},
+		{Key: "team", Type: TypeString, Options: []string{"a"}},
+		{Key: "contractor", Type: TypeBoolean, Unique: true},
+		{Key: "cost_center", Type: TypeString, InToken: true, ClaimName: "department"},
+	}
+	for _, req := range invalid {
+		_, err = service.Create(tenantID, req)
+		assert.ErrorIs(t, err, ErrInvalidDefinition, req.Key)
+	}
+
+	_, err = service.Create(tenantID, &CreateDefinitionRequest{Key: "email", Type: TypeString, InToken: true})
+	assert.ErrorIs(t, err, ErrReservedClaim)
+	_, err = service.Create(tenantID, &CreateDefinitionRequest{Key: "work_email", Type: TypeString, InToken: true, ClaimName: "sub"})
+	assert.ErrorIs(t, err, ErrReservedClaim)
+
+	required := true
+	updated, err := service.Update(created.ID, &UpdateDefinitionRequest{Required: &required, Options: []string{"sales", "engineering", "support"}})
+	require.NoError(t, err)
+	assert.True(t, updated.Required)
+	assert.Len(t, updated.Options, 3)
+
+	defs, err := service.List(tenantID)
+	require.NoError(t, err)
+	assert.Len(t, defs, 1)
+}
+
+func TestService_Validate(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+
+	for _, req := range []*CreateDefinitionRequest{
+		{Key: "employee_number", Type: TypeString, Required: true, Unique: true},
+		{Key: "department", Type: TypeEnum, Options: []string{"sales", "engineering"}},
+		{Key: "locale", Type: TypeString, UserEditable: true},
+		{Key: "start_date", Type: TypeDate},
+		{Key: "level", Type: TypeNumber, Unique: true},
+		{Key: "remote", Type: TypeBoolean, UserEditable: true, Required: true},
+	} {
+		_, err := service.Create(tenantID, req)
+		require.NoError(t, err)
+	}
+
+	values, err := service.Validate(tenantID, nil, map[string]interface{}{
+		"employee_number": "E-1",
+		"department":      "sales",
+		"start_date":      "2024-02-29",
+		"level":           float64(3),
+		"remote":          false,
+	}, false)
+	require.NoError(t, err)
+	alice := createUser(t, db, tenantID, "alice@example.com", values)
+
+	// Types, required values and unknown keys are checked together
+	_, err = service.Validate(tenantID, nil, map[string]interface{}{
+		"department": "marketing",
+		"start_date": "2024-02-30",
+		"level":      "3",
+		"nickname":   "al",
+	}, false)
+	assert.Equal(t, map[string]string{
+		"department":      "type",
+		"start_date":      "type",
+		"level":           "type",
+		"nickname":        "unknown",
+		"employee_number": "required",
+		"remote":          "required",
+	}, valueErrorRules(t, err))
+
+	// Unique values cannot be shared, but a user keeps their own
+	_, err = service.Validate(tenantID, nil, map[string]interface{}{"employee_number": "E-1", "level": float64(3), "remote": true}, false)
+	assert.Equal(t, map[string]string{"employee_number": "unique", "level": "unique"}, valueErrorRules(t, err))
+	_, err = service.Validate(tenantID, alice, map[string]interface{}{"employee_number": "E-1", "level": float64(3)}, false)
+	assert.NoError(t, err)
+
+	// Updates merge into the current values; null removes optional values only
+	values, err = service.Validate(tenantID, alice, map[string]interface{}{"department": nil, "locale": "de-DE"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, user.Attributes{"employee_number": "E-1", "start_date": "2024-02-29", "level": float64(3), "remote": false, "locale": "de-DE"}, values)
+	_, err = service.Validate(tenantID, alice, map[string]interface{}{"employee_number": nil}, false)
+	assert.Equal(t, map[string]string{"employee_number": "required"}, valueErrorRules(t, err))
+
+	// Self-service can only write user-editable attributes, and new users only need those
+	_, err = service.Validate(tenantID, alice, map[string]interface{}{"locale": "en-GB", "department": "engineering"}, true)
+	assert.Equal(t, map[string]string{"department": "readonly"}, valueErrorRules(t, err))
+	values, err = service.Validate(tenantID, nil, map[string]interface{}{"remote": true}, true)
+	require.NoError(t, err)
+	assert.Equal(t, user.Attributes{"remote": true}, values)
+}
+
+func TestService_UniqueAndDelete(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+
+	def, err := service.Create(tenantID, &CreateDefinitionRequest{Key: "team", Type: TypeString})
+	require.NoError(t, err)
+	alice := createUser(t, db, tenantID, "alice@example.com", user.Attributes{"team": "core", "other": "kept"})
+	createUser(t, db, tenantID, "bob@example.com", user.Attributes{"team": "core"})
+
+	// Existing values must be unique before the attribute can be
+	unique := true
+	_, err = service.Update(def.ID, &UpdateDefinitionRequest{Unique: &unique})
+	assert.ErrorIs(t, err, ErrInvalidDefinition)
+
+	require.NoError(t, service.Delete(def.ID))
+	_, err = service.Get(def.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var reloaded user.User
+	require.NoError(t, db.First(&reloaded, "id = ?", alice.ID).Error)
+	assert.Equal(t, user.Attributes{"other": "kept"}, reloaded.Attributes)
+}
+
+func TestService_Claims(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+
+	for _, req := range []*CreateDefinitionRequest{
+		{Key: "department", Type: TypeString, InToken: true},
+		{Key: "employee_number", Type: TypeString, InToken: true, ClaimName: "employee_id", Scope: "hr"},
+		{Key: "salary_band", Type: TypeString},
+	} {
+		_, err := service.Create(tenantID, req)
+		require.NoError(t, err)
+	}
+	u := createUser(t, db, tenantID, "alice@example.com", user.Attributes{"department": "sales", "employee_number": "E-1", "salary_band": "B"})
+
+	claims, err := service.Claims(u, []string{"openid", "profile"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"department": "sales"}, claims)
+
+	claims, err = service.Claims(u, []string{"openid", "profile", "hr"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"department": "sales", "employee_id": "E-1"}, claims)
+}
@@ -51,10 +51,10 @@ func TestNewService(t *testing.T) {
 	var redisClient *redis.Client
 	cfg := &config.Config{}
 
-	service := NewService(db, redisClient, cfg, logger)
+	svc := NewService(db, redisClient, cfg, logger)
 
-	assert.NotNil(t, service)
-	assert.IsType(t, &service{}, service)
+	assert.NotNil(t, svc)
+	assert.IsType(t, &service{}, svc)
 }
 
 func TestService_Authenticate(t *testing.T) {
@@ -66,9 +66,8 @@ func TestService_Authenticate(t *testing.T) {
 
 	testUser := &user.User{
 		Email:        "test@example.com",
-		PasswordHash: &hashedPassword,
-		FirstName:    stringPtr("John"),
-		LastName:     stringPtr("Doe"),
+		PasswordHash: string(hashedPassword),
+		Name:         stringPtr("John Doe"),
 		Active:       true,
 	}
 
@@ -142,14 +141,15 @@ func TestService_Authenticate_WithInactiveUser(t *testing.T) {
 
 	// Create inactive user
 	testUser := &user.User{
-		Email:     "inactive@example.com",
-		FirstName: stringPtr("Inactive"),
-		LastName:  stringPtr("User"),
-		Active:    false, // User is inactive
+		Email:  "inactive@example.com",
+		Name:   stringPtr("Inactive User"),
+		Active: false, // User is inactive
 	}
 
 	err := db.Create(testUser).Error
 	require.NoError(t, err)
+	// Active has a database default of true, so a false zero value is not written on create
+	require.NoError(t, db.Model(testUser).Update("active", false).Error)
 
 	// The current implementation doesn't check Active status
 	// This test documents the current behavior
@@ -166,8 +166,7 @@ func TestService_Authenticate_WithUnverifiedEmail(t *testing.T) {
 	// Create user with unverified email
 	testUser := &user.User{
 		Email:         "unverified@example.com",
-		FirstName:     stringPtr("Unverified"),
-		LastName:      stringPtr("User"),
+		Name:          stringPtr("Unverified User"),
 		Active:        true,
 		EmailVerified: false, // Email not verified
 	}
@@ -189,10 +188,9 @@ func TestService_Authenticate_CaseInsensitiveEmail(t *testing.T) {
 
 	// Create user with lowercase email
 	testUser := &user.User{
-		Email:     "test@example.com",
-		FirstName: stringPtr("Test"),
-		LastName:  stringPtr("User"),
-		Active:    true,
+		Email:  "test@example.com",
+		Name:   stringPtr("Test User"),
+		Active: true,
 	}
 
 	err := db.Create(testUser).Error
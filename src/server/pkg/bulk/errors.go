@@ -0,0 +1,21 @@
+package bulk
+
+import "errors"
+
+// Bulk-specific errors
+var (
+	// ErrNotFound is returned when an import job does not exist
+	ErrNotFound = errors.New("import job not found")
+
+	// ErrUnsupportedFormat is returned for a file format other than CSV and JSON Lines
+	ErrUnsupportedFormat = errors.New("unsupported format, use csv or jsonl")
+
+	// ErrInvalidFile is returned when a file cannot be read as a whole, such as a CSV without an email column
+	ErrInvalidFile = errors.New("invalid import file")
+
+	// ErrTooManyRows is returned when a file exceeds MaxRows
+	ErrTooManyRows = errors.New("import file has too many rows")
+
+	// ErrUnknownField is returned when an export selects a field that does not exist
+	ErrUnknownField = errors.New("unknown export field")
+)
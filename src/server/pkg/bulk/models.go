@@ -0,0 +1,147 @@
+package bulk
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// File formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Import job statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+const (
+	// MaxRows bounds the rows of a single import file
+	MaxRows = 100000
+
+	// MaxRowErrors bounds the row errors kept on a job; FailedRows still counts them all
+	MaxRowErrors = 1000
+)
+
+// Job is an asynchronous import of a tenant's users from a CSV or JSON Lines file
+// A dry run validates every row and reports what would be created or updated without writing
+type Job struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID      uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Status        string     `json:"status" gorm:"not null"`
+	Format        string     `json:"format" gorm:"not null"`
+	DryRun        bool       `json:"dry_run" gorm:"not null"`
+	Upsert        bool       `json:"upsert" gorm:"not null"`
+	TotalRows     int        `json:"total_rows" gorm:"not null;default:0"`
+	ProcessedRows int        `json:"processed_rows" gorm:"not null;default:0"`
+	CreatedRows   int        `json:"created_rows" gorm:"not null;default:0"`
+	UpdatedRows   int        `json:"updated_rows" gorm:"not null;default:0"`
+	FailedRows    int        `json:"failed_rows" gorm:"not null;default:0"`
+	Errors        RowErrors  `json:"errors" gorm:"type:jsonb"`
+	Error         string     `json:"error,omitempty"` // Why the job failed as a whole
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Job model
+func (Job) TableName() string {
+	return "user_import_jobs"
+}
+
+// BeforeCreate sets UUID if not provided
+func (j *Job) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
+
+// RowError reports why a row of an import file was not imported
+// Line is the row's line in the file, counting a CSV header as line 1
+type RowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// RowErrors is the per-row error report of an import job
+type RowErrors []RowError
+
+// Scan implements sql.Scanner for RowErrors (JSONB support)
+func (e *RowErrors) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for RowErrors (JSONB support)
+func (e RowErrors) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	return json.Marshal(e)
+}
+
+// Row is a user read from an import file
+// Nil fields were absent from the row; on upsert they keep the existing user's value
+type Row struct {
+	Line          int           `json:"-"`
+	Email         string        `json:"email"`
+	Name          *string       `json:"name"`
+	EmailVerified *bool         `json:"email_verified"`
+	PasswordHash  string        `json:"password_hash"`
+	Metadata      user.Metadata `json:"metadata"`
+}
+
+// ImportOptions controls how an import job applies its rows
+type ImportOptions struct {
+	Format string
+	DryRun bool
+	Upsert bool // Update users whose email already exists instead of reporting the row
+}
+
+// Export fields, in the order they are written unless the export selects others
+const (
+	FieldID            = "id"
+	FieldEmail         = "email"
+	FieldName          = "name"
+	FieldEmailVerified = "email_verified"
+	FieldActive        = "active"
+	FieldProvider      = "provider"
+	FieldPasswordHash  = "password_hash"
+	FieldMetadata      = "metadata"
+	FieldLastLoginAt   = "last_login_at"
+	FieldCreatedAt     = "created_at"
+	FieldUpdatedAt     = "updated_at"
+)
+
+// DefaultExportFields are exported when no fields are selected
+// Password hashes are only exported when selected explicitly
+var DefaultExportFields = []string{
+	FieldID, FieldEmail, FieldName, FieldEmailVerified, FieldActive, FieldProvider,
+	FieldMetadata, FieldLastLoginAt, FieldCreatedAt, FieldUpdatedAt,
+}
+
+// ExportFields lists every field an export can select
+var ExportFields = append(append([]string{}, DefaultExportFields...), FieldPasswordHash)
@@ -0,0 +1,198 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+)
+
+// maxLineSize bounds a single JSON Lines row
+const maxLineSize = 1 << 20
+
+// parseRows reads an import file into rows
+// Rows that cannot be read are reported as row errors so the rest of the file still imports;
+// an error is only returned when the file as a whole is unusable
+func parseRows(format string, r io.Reader) ([]Row, RowErrors, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatJSONL:
+		return parseJSONL(r)
+	default:
+		return nil, nil, ErrUnsupportedFormat
+	}
+}
+
+// parseCSV reads a CSV file with a header row naming its columns
+// Recognized columns are email (required), name, email_verified, password_hash and metadata (a JSON object);
+// other columns are ignored, so an export can be imported back
+func parseCSV(r io.Reader) ([]Row, RowErrors, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: missing header row", ErrInvalidFile)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // Byte order mark of spreadsheet exports
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns[FieldEmail]; !ok {
+		return nil, nil, fmt.Errorf("%w: missing email column", ErrInvalidFile)
+	}
+
+	var rows []Row
+	var rowErrs RowErrors
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			rowErrs = append(rowErrs, RowError{Line: parseErr.Line, Error: "wrong number of fields"})
+			continue
+		}
+		if len(rows)+len(rowErrs) >= MaxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := Row{
+			Line:         line,
+			Email:        field(FieldEmail),
+			PasswordHash: field(FieldPasswordHash),
+		}
+		if name := field(FieldName); name != "" {
+			row.Name = &name
+		}
+		if verified := field(FieldEmailVerified); verified != "" {
+			v, err := strconv.ParseBool(verified)
+			if err != nil {
+				rowErrs = append(rowErrs, RowError{Line: line, Email: row.Email, Error: "email_verified must be true or false"})
+				continue
+			}
+			row.EmailVerified = &v
+		}
+		if metadata := field(FieldMetadata); metadata != "" {
+			if err := json.Unmarshal([]byte(metadata), &row.Metadata); err != nil {
+				rowErrs = append(rowErrs, RowError{Line: line, Email: row.Email, Error: "metadata must be a JSON object"})
+				continue
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+// parseJSONL reads one JSON object per line with the fields of Row; blank lines are skipped
+func parseJSONL(r io.Reader) ([]Row, RowErrors, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+
+	var rows []Row
+	var rowErrs RowErrors
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows)+len(rowErrs) >= MaxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		var row Row
+		if err := json.Unmarshal(data, &row); err != nil {
+			rowErrs = append(rowErrs, RowError{Line: line, Error: "invalid JSON object"})
+			continue
+		}
+		row.Line = line
+		row.Email = strings.TrimSpace(row.Email)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return rows, rowErrs, nil
+}
+
+// exportValue returns a user's field as exported
+func exportValue(u *user.User, field string) interface{} {
+	switch field {
+	case FieldID:
+		return u.ID
+	case FieldEmail:
+		return u.Email
+	case FieldName:
+		if u.Name == nil {
+			return ""
+		}
+		return *u.Name
+	case FieldEmailVerified:
+		return u.EmailVerified
+	case FieldActive:
+		return u.Active
+	case FieldProvider:
+		return u.Provider
+	case FieldPasswordHash:
+		return u.PasswordHash
+	case FieldMetadata:
+		if u.Metadata == nil {
+			return user.Metadata{}
+		}
+		return u.Metadata
+	case FieldLastLoginAt:
+		return u.LastLoginAt
+	case FieldCreatedAt:
+		return u.CreatedAt
+	case FieldUpdatedAt:
+		return u.UpdatedAt
+	}
+	return nil
+}
+
+// csvValue formats an exported value as a CSV cell; metadata is written as a JSON object
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case uuid.UUID:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case user.Metadata:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return ""
+}
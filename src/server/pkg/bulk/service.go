@@ -0,0 +1,366 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// progressInterval is how many rows a running job processes between progress updates
+	progressInterval = 100
+
+	// exportBatchSize is how many users an export reads at a time
+	exportBatchSize = 500
+)
+
+// Service imports and exports a tenant's users in bulk
+type Service interface {
+	StartImport(tenantID uuid.UUID, opts ImportOptions, file io.Reader) (*Job, error)
+	GetJob(id uuid.UUID) (*Job, error)
+	ListJobs(tenantID uuid.UUID, limit, offset int) ([]*Job, int64, error)
+	Export(tenantID uuid.UUID, format string, fields []string, w io.Writer) error
+}
+
+type service struct {
+	db          *gorm.DB
+	logger      *zap.Logger
+	userService user.Service
+	validator   *validator.Validate
+
+	// Running import jobs
+	jobs sync.WaitGroup
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger, userService user.Service) Service {
+	return &service{
+		db:          db,
+		logger:      logger,
+		userService: userService,
+		validator:   validator.New(),
+	}
+}
+
+// StartImport reads the file and imports its rows in the background
+// The returned job is polled with GetJob for progress and the per-row error report
+func (s *service) StartImport(tenantID uuid.UUID, opts ImportOptions, file io.Reader) (*Job, error) {
+	rows, rowErrs, err := parseRows(opts.Format, file)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{
+		TenantID:      tenantID,
+		Status:        StatusPending,
+		Format:        opts.Format,
+		DryRun:        opts.DryRun,
+		Upsert:        opts.Upsert,
+		TotalRows:     len(rows) + len(rowErrs),
+		ProcessedRows: len(rowErrs),
+		FailedRows:    len(rowErrs),
+		Errors:        capErrors(rowErrs),
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		s.logger.Error("Failed to create import job", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	s.logger.Info("User import started",
+		zap.String("job_id", job.ID.String()),
+		zap.String("tenant_id", tenantID.String()),
+		zap.Int("rows", job.TotalRows),
+		zap.Bool("dry_run", job.DryRun))
+
+	s.jobs.Add(1)
+	go s.run(*job, rows)
+	return job, nil
+}
+
+func (s *service) GetJob(id uuid.UUID) (*Job, error) {
+	var job Job
+	if err := s.db.Where("id = ?", id).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	return &job, nil
+}
+
+// ListJobs returns a tenant's import jobs, newest first
+func (s *service) ListJobs(tenantID uuid.UUID, limit, offset int) ([]*Job, int64, error) {
+	var jobs []*Job
+	var total int64
+
+	query := s.db.Model(&Job{}).Where("tenant_id = ?", tenantID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count import jobs: %w", err)
+	}
+	// The per-row reports can be large; they are only returned by GetJob
+	if err := query.Omit("errors").Order("created_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list import jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// run applies the rows of a job, saving its progress as it goes
+func (s *service) run(job Job, rows []Row) {
+	defer s.jobs.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("Import job panicked", zap.Any("panic", r), zap.String("job_id", job.ID.String()))
+			s.finish(&job, StatusFailed, "internal error")
+		}
+	}()
+
+	started := time.Now()
+	job.Status = StatusRunning
+	job.StartedAt = &started
+	s.save(&job)
+
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		key := strings.ToLower(row.Email)
+		if first, ok := seen[key]; ok && key != "" {
+			job.fail(row, fmt.Sprintf("duplicate of line %d", first))
+		} else {
+			seen[key] = row.Line
+			created, err := s.apply(job.TenantID, row, job.DryRun, job.Upsert)
+			switch {
+			case err != nil:
+				job.fail(row, err.Error())
+			case created:
+				job.CreatedRows++
+			default:
+				job.UpdatedRows++
+			}
+		}
+		job.ProcessedRows++
+
+		if (i+1)%progressInterval == 0 {
+			s.save(&job)
+		}
+	}
+
+	s.finish(&job, StatusCompleted, "")
+	s.logger.Info("User import finished",
+		zap.String("job_id", job.ID.String()),
+		zap.Int("created", job.CreatedRows),
+		zap.Int("updated", job.UpdatedRows),
+		zap.Int("failed", job.FailedRows),
+		zap.Bool("dry_run", job.DryRun))
+}
+
+// apply creates or, on upsert, updates the row's user; a dry run only decides which it would be
+// Returned errors are row errors, reported to the administrator as they are
+func (s *service) apply(tenantID uuid.UUID, row Row, dryRun, upsert bool) (created bool, err error) {
+	if s.validator.Var(row.Email, "required,email") != nil {
+		return false, errors.New("invalid email")
+	}
+	if row.Name != nil && len(*row.Name) > 255 {
+		return false, errors.New("name is longer than 255 characters")
+	}
+	if row.PasswordHash != "" {
+		if err := user.ValidatePasswordHash(row.PasswordHash); err != nil {
+			return false, err
+		}
+	}
+
+	var existing user.User
+	err = s.db.Where("tenant_id = ? AND LOWER(email) = ?", tenantID, strings.ToLower(row.Email)).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if dryRun {
+			return true, nil
+		}
+		req := &user.ImportUserRequest{
+			Email:        row.Email,
+			PasswordHash: row.PasswordHash,
+			Metadata:     row.Metadata,
+		}
+		if row.Name != nil {
+			req.Name = *row.Name
+		}
+		if row.EmailVerified != nil {
+			req.EmailVerified = *row.EmailVerified
+		}
+		if _, err := s.userService.Import(tenantID, req); err != nil {
+			if errors.Is(err, user.ErrEmailTaken) || errors.Is(err, user.ErrUnsupportedPasswordHash) {
+				return false, err
+			}
+			return false, errors.New("failed to create user")
+		}
+		return true, nil
+	case err != nil:
+		s.logger.Error("Failed to look up imported user", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return false, errors.New("failed to look up user")
+	case !upsert:
+		return false, user.ErrEmailTaken
+	}
+
+	// Upsert: fields the row leaves out keep their value
+	updates := map[string]interface{}{}
+	if row.Name != nil {
+		updates["name"] = *row.Name
+	}
+	if row.EmailVerified != nil {
+		updates["email_verified"] = *row.EmailVerified
+	}
+	if row.PasswordHash != "" {
+		updates["password_hash"] = row.PasswordHash
+	}
+	if row.Metadata != nil {
+		updates["metadata"] = row.Metadata
+	}
+	if dryRun || len(updates) == 0 {
+		return false, nil
+	}
+	if err := s.db.Model(&existing).Updates(updates).Error; err != nil {
+		s.logger.Error("Failed to update imported user", zap.Error(err), zap.String("user_id", existing.ID.String()))
+		return false, errors.New("failed to update user")
+	}
+	return false, nil
+}
+
+// fail records a row error, keeping at most MaxRowErrors of them
+func (j *Job) fail(row Row, reason string) {
+	j.FailedRows++
+	if len(j.Errors) < MaxRowErrors {
+		j.Errors = append(j.Errors, RowError{Line: row.Line, Email: row.Email, Error: reason})
+	}
+}
+
+func (s *service) finish(job *Job, status, reason string) {
+	finished := time.Now()
+	job.Status = status
+	job.Error = reason
+	job.FinishedAt = &finished
+	s.save(job)
+}
+
+// save writes a job's progress; a failed write only delays what pollers see
+func (s *service) save(job *Job) {
+	if err := s.db.Model(&Job{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+		"status":         job.Status,
+		"processed_rows": job.ProcessedRows,
+		"created_rows":   job.CreatedRows,
+		"updated_rows":   job.UpdatedRows,
+		"failed_rows":    job.FailedRows,
+		"errors":         job.Errors,
+		"error":          job.Error,
+		"started_at":     job.StartedAt,
+		"finished_at":    job.FinishedAt,
+	}).Error; err != nil {
+		s.logger.Warn("Failed to save import job progress", zap.Error(err), zap.String("job_id", job.ID.String()))
+	}
+}
+
+// Export streams a tenant's users to w as CSV (with a header row) or JSON Lines
+// fields selects the exported fields in order; see SelectFields
+func (s *service) Export(tenantID uuid.UUID, format string, fields []string, w io.Writer) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return ErrUnsupportedFormat
+	}
+	fields, err := SelectFields(fields)
+	if err != nil {
+		return err
+	}
+
+	var writeUser func(u *user.User) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(fields); err != nil {
+			return err
+		}
+		record := make([]string, len(fields))
+		writeUser = func(u *user.User) error {
+			for i, field := range fields {
+				record[i] = csvValue(exportValue(u, field))
+			}
+			return writer.Write(record)
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	default:
+		encoder := json.NewEncoder(w)
+		writeUser = func(u *user.User) error {
+			object := make(map[string]interface{}, len(fields))
+			for _, field := range fields {
+				object[field] = exportValue(u, field)
+			}
+			return encoder.Encode(object)
+		}
+		flush = func() error { return nil }
+	}
+
+	// Page by ID so users created during the export neither shift nor repeat rows
+	var lastID uuid.UUID
+	for {
+		var users []*user.User
+		query := s.db.Where("tenant_id = ?", tenantID).Order("id").Limit(exportBatchSize)
+		if lastID != uuid.Nil {
+			query = query.Where("id > ?", lastID)
+		}
+		if err := query.Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to read users: %w", err)
+		}
+		for _, u := range users {
+			if err := writeUser(u); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if len(users) < exportBatchSize {
+			return nil
+		}
+		lastID = users[len(users)-1].ID
+	}
+}
+
+// SelectFields validates the fields selected for an export, defaulting to DefaultExportFields
+func SelectFields(selected []string) ([]string, error) {
+	var fields []string
+	for _, field := range selected {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		known := false
+		for _, f := range ExportFields {
+			known = known || f == field
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, field)
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return DefaultExportFields, nil
+	}
+	return fields, nil
+}
+
+// capErrors keeps the first MaxRowErrors row errors
+func capErrors(rowErrs RowErrors) RowErrors {
+	if len(rowErrs) > MaxRowErrors {
+		return rowErrs[:MaxRowErrors]
+	}
+	return rowErrs
+}
@@ -0,0 +1,190 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const ssha256Hash = "{SSHA256}wz+SqWxwzp+ZNwhllQXyHOH6dtfSlGxUaWjTNMVZIPpwZXBwZXIh" // "correct horse"
+
+func setupTestService(t *testing.T) (*service, *gorm.DB, user.Service) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	// Import jobs run in the background; every connection must see the same in-memory database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&user.User{}, &Job{}))
+
+	userService := user.NewService(db, zap.NewNop())
+	return NewService(db, zap.NewNop(), userService).(*service), db, userService
+}
+
+// importFile runs an import job to completion and returns it as pollers see it
+func importFile(t *testing.T, svc *service, tenantID uuid.UUID, opts ImportOptions, file string) *Job {
+	job, err := svc.StartImport(tenantID, opts, strings.NewReader(file))
+	require.NoError(t, err)
+	svc.jobs.Wait()
+
+	job, err = svc.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, job.Status)
+	assert.Equal(t, job.TotalRows, job.ProcessedRows)
+	assert.NotNil(t, job.FinishedAt)
+	return job
+}
+
+func TestService_ImportCSV(t *testing.T) {
+	svc, db, userService := setupTestService(t)
+	tenantID := uuid.New()
+
+	file := "Email,Name,Email_Verified,Password_Hash,Metadata,Ignored\n" +
+		"alice@example.com,Alice,true," + ssha256Hash + ",\"{\"\"crm_id\"\":\"\"42\"\"}\",x\n" +
+		"bob@example.com,Bob,,,,\n" +
+		"not-an-email,Nobody,,,,\n" +
+		"carol@example.com,Carol,maybe,,,\n" +
+		"ALICE@example.com,Alice Again,,,,\n" +
+		"dave@example.com,Dave,,md5:5f4dcc3b,,\n" +
+		"short,row\n"
+
+	// A dry run reports the outcome without writing
+	dryRun := importFile(t, svc, tenantID, ImportOptions{Format: FormatCSV, DryRun: true}, file)
+	assert.Equal(t, 7, dryRun.TotalRows)
+	assert.Equal(t, 2, dryRun.CreatedRows)
+	assert.Equal(t, 5, dryRun.FailedRows)
+	var count int64
+	require.NoError(t, db.Model(&user.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	job := importFile(t, svc, tenantID, ImportOptions{Format: FormatCSV}, file)
+	assert.Equal(t, 2, job.CreatedRows)
+	assert.Equal(t, 0, job.UpdatedRows)
+	assert.Equal(t, 5, job.FailedRows)
+
+	lines := map[int]string{}
+	for _, rowErr := range job.Errors {
+		lines[rowErr.Line] = rowErr.Error
+	}
+	assert.Equal(t, map[int]string{
+		4: "invalid email",
+		5: "email_verified must be true or false",
+		6: "duplicate of line 2",
+		7: user.ErrUnsupportedPasswordHash.Error(),
+		8: "wrong number of fields",
+	}, lines)
+
+	alice, err := userService.GetByEmailAndTenant(tenantID, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", *alice.Name)
+	assert.True(t, alice.EmailVerified)
+	assert.Equal(t, user.Metadata{"crm_id": "42"}, alice.Metadata)
+	assert.True(t, userService.VerifyPassword(alice, "correct horse"))
+
+	bob, err := userService.GetByEmailAndTenant(tenantID, "bob@example.com")
+	require.NoError(t, err)
+	assert.False(t, bob.EmailVerified)
+	assert.False(t, bob.HasPassword())
+}
+
+func TestService_ImportUpsert(t *testing.T) {
+	svc, _, userService := setupTestService(t)
+	tenantID := uuid.New()
+
+	importFile(t, svc, tenantID, ImportOptions{Format: FormatJSONL},
+		`{"email":"alice@example.com","name":"Alice","metadata":{"plan":"free"}}`+"\n")
+
+	update := `{"email":"alice@example.com","email_verified":true,"metadata":{"plan":"pro"}}` + "\n" +
+		"\n" +
+		`{"email":"bob@example.com","name":"Bob"}` + "\n" +
+		`{"email":` + "\n"
+
+	// Without upsert, existing emails are row errors
+	job := importFile(t, svc, tenantID, ImportOptions{Format: FormatJSONL}, update)
+	assert.Equal(t, 3, job.TotalRows)
+	assert.Equal(t, 1, job.CreatedRows)
+	require.Len(t, job.Errors, 2)
+	assert.Equal(t, RowError{Line: 4, Error: "invalid JSON object"}, job.Errors[0])
+	assert.Equal(t, RowError{Line: 1, Email: "alice@example.com", Error: user.ErrEmailTaken.Error()}, job.Errors[1])
+
+	job = importFile(t, svc, tenantID, ImportOptions{Format: FormatJSONL, Upsert: true}, update)
+	assert.Equal(t, 0, job.CreatedRows)
+	assert.Equal(t, 2, job.UpdatedRows)
+	assert.Equal(t, 1, job.FailedRows)
+
+	// Fields the row leaves out keep their value
+	alice, err := userService.GetByEmailAndTenant(tenantID, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", *alice.Name)
+	assert.True(t, alice.EmailVerified)
+	assert.Equal(t, user.Metadata{"plan": "pro"}, alice.Metadata)
+
+	jobs, total, err := svc.ListJobs(tenantID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, jobs, 3)
+}
+
+func TestService_ImportRejectsFile(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	_, err := svc.StartImport(uuid.New(), ImportOptions{Format: FormatCSV}, strings.NewReader("name\nAlice\n"))
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	_, err = svc.StartImport(uuid.New(), ImportOptions{Format: "xlsx"}, strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestService_Export(t *testing.T) {
+	svc, _, userService := setupTestService(t)
+	tenantID := uuid.New()
+	otherTenantID := uuid.New()
+
+	var file strings.Builder
+	file.WriteString("email,name,password_hash,metadata\n")
+	for i := 0; i < exportBatchSize+3; i++ {
+		file.WriteString("user" + uuid.NewString()[:8] + "@example.com,User,,\n")
+	}
+	file.WriteString("alice@example.com,\"Alice, Jr.\"," + ssha256Hash + ",\"{\"\"crm_id\"\":\"\"42\"\"}\"\n")
+	importFile(t, svc, tenantID, ImportOptions{Format: FormatCSV}, file.String())
+	_, err := userService.Import(otherTenantID, &user.ImportUserRequest{Email: "other@example.com"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, svc.Export(tenantID, FormatCSV, nil, &out))
+	records, err := csv.NewReader(bytes.NewReader(out.Bytes())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultExportFields, records[0])
+	assert.Len(t, records, exportBatchSize+5)
+	assert.NotContains(t, out.String(), ssha256Hash)
+	assert.NotContains(t, out.String(), "other@example.com")
+
+	// A selected export with password hashes imports into another tenant as is
+	out.Reset()
+	require.NoError(t, svc.Export(tenantID, FormatCSV, []string{"email", "name", "password_hash", "metadata"}, &out))
+	restoredTenantID := uuid.New()
+	job := importFile(t, svc, restoredTenantID, ImportOptions{Format: FormatCSV}, out.String())
+	assert.Equal(t, exportBatchSize+4, job.CreatedRows)
+	alice, err := userService.GetByEmailAndTenant(restoredTenantID, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice, Jr.", *alice.Name)
+	assert.Equal(t, user.Metadata{"crm_id": "42"}, alice.Metadata)
+	assert.True(t, userService.VerifyPassword(alice, "correct horse"))
+
+	out.Reset()
+	require.NoError(t, svc.Export(otherTenantID, FormatJSONL, []string{"email", "active"}, &out))
+	var exported map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &exported))
+	assert.Equal(t, map[string]interface{}{"email": "other@example.com", "active": true}, exported)
+
+	assert.ErrorIs(t, svc.Export(tenantID, FormatCSV, []string{"email", "secret"}, &out), ErrUnknownField)
+}
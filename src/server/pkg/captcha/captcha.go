@@ -0,0 +1,112 @@
+package captcha
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Verifier providers
+const (
+	ProviderLocal      = "local"
+	ProviderSiteVerify = "siteverify"
+)
+
+// ErrVerificationFailed is returned when a CAPTCHA response is missing or rejected
+var ErrVerificationFailed = errors.New("captcha verification failed")
+
+// Verifier checks the CAPTCHA response submitted with a form
+type Verifier interface {
+	Verify(token, remoteIP string) error
+}
+
+// New creates the verifier for a provider; an empty provider disables CAPTCHA and returns nil
+func New(provider, verifyURL, secret, testToken string) (Verifier, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case ProviderLocal:
+		if testToken == "" {
+			return nil, fmt.Errorf("captcha provider %q requires a test token", provider)
+		}
+		return NewLocalVerifier(testToken), nil
+	case ProviderSiteVerify:
+		if verifyURL == "" || secret == "" {
+			return nil, fmt.Errorf("captcha provider %q requires a verify URL and secret", provider)
+		}
+		return NewSiteVerifyVerifier(verifyURL, secret), nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", provider)
+	}
+}
+
+// LocalVerifier accepts a single fixed token, for development and automated tests
+type LocalVerifier struct {
+	token string
+}
+
+func NewLocalVerifier(token string) *LocalVerifier {
+	return &LocalVerifier{token: token}
+}
+
+func (v *LocalVerifier) Verify(token, remoteIP string) error {
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(v.token)) != 1 {
+		return ErrVerificationFailed
+	}
+	return nil
+}
+
+// SiteVerifyVerifier checks responses against a siteverify endpoint
+// (reCAPTCHA, hCaptcha and Cloudflare Turnstile share this protocol)
+type SiteVerifyVerifier struct {
+	verifyURL  string
+	secret     string
+	httpClient *http.Client
+}
+
+func NewSiteVerifyVerifier(verifyURL, secret string) *SiteVerifyVerifier {
+	return &SiteVerifyVerifier{
+		verifyURL:  verifyURL,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *SiteVerifyVerifier) Verify(token, remoteIP string) error {
+	if token == "" {
+		return ErrVerificationFailed
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	resp, err := v.httpClient.PostForm(v.verifyURL, form)
+	if err != nil {
+		return fmt.Errorf("failed to call captcha verify endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verify endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode captcha verify response: %w", err)
+	}
+	if !result.Success {
+		return ErrVerificationFailed
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package captcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	verifier, err := New("", "", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, verifier)
+
+	_, err = New(ProviderLocal, "", "", "")
+	assert.Error(t, err)
+
+	_, err = New(ProviderSiteVerify, "https://example.com/siteverify", "", "")
+	assert.Error(t, err)
+
+	_, err = New("unknown", "", "", "")
+	assert.Error(t, err)
+}
+
+func TestLocalVerifier(t *testing.T) {
+	verifier := NewLocalVerifier("test-pass")
+
+	assert.NoError(t, verifier.Verify("test-pass", ""))
+	assert.ErrorIs(t, verifier.Verify("wrong", ""), ErrVerificationFailed)
+	assert.ErrorIs(t, verifier.Verify("", ""), ErrVerificationFailed)
+}
+
+func TestSiteVerifyVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "203.0.113.1", r.PostForm.Get("remoteip"))
+		if r.PostForm.Get("response") == "human" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer server.Close()
+
+	verifier := NewSiteVerifyVerifier(server.URL, "secret")
+
+	assert.NoError(t, verifier.Verify("human", "203.0.113.1"))
+	assert.ErrorIs(t, verifier.Verify("bot", "203.0.113.1"), ErrVerificationFailed)
+	assert.ErrorIs(t, verifier.Verify("", "203.0.113.1"), ErrVerificationFailed)
+}
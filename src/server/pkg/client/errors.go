@@ -0,0 +1,15 @@
+package client
+
+import "errors"
+
+// Client-specific errors
+var (
+	// ErrClientIDTaken is returned when a client_id belongs to another client, including a deleted one
+	ErrClientIDTaken = errors.New("client_id is already in use")
+
+	// ErrTenantUnavailable is returned when the client's tenant does not exist, is inactive or is deleted
+	ErrTenantUnavailable = errors.New("tenant not found or inactive")
+
+	// ErrDeletedNotFound is returned when restoring a client that does not exist or is not deleted
+	ErrDeletedNotFound = errors.New("deleted client not found")
+)
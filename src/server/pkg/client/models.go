@@ -1,6 +1,11 @@
 package client
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,6 +30,22 @@ type Client struct {
 	Public       bool           `json:"public" gorm:"default:false"`
 	Active       bool           `json:"active" gorm:"default:true"`
 
+	// Token endpoint and authorization settings (enforced by Policy and synced to Hydra)
+	TokenEndpointAuthMethod string         `json:"token_endpoint_auth_method" gorm:"column:token_endpoint_auth_method;default:client_secret_post"`
+	ResponseTypes           pq.StringArray `json:"response_types" gorm:"type:text[]"`
+	RequirePKCE             bool           `json:"require_pkce" gorm:"column:require_pkce;default:false"`
+	JwksURI                 string         `json:"jwks_uri" gorm:"column:jwks_uri"`
+
+	// Token lifetimes, audience and browser settings (synced to Hydra)
+	TokenLifespans         TokenLifespans `json:"token_lifespans" gorm:"type:jsonb"`
+	Audience               pq.StringArray `json:"audience" gorm:"type:text[]"`
+	AllowedCORSOrigins     pq.StringArray `json:"allowed_cors_origins" gorm:"column:allowed_cors_origins;type:text[]"`
+	SkipConsent            bool           `json:"skip_consent" gorm:"default:false"`
+	FirstParty             bool           `json:"first_party" gorm:"column:first_party;default:false"` // Trusted client: consent is granted automatically
+	PostLogoutRedirectURIs pq.StringArray `json:"post_logout_redirect_uris" gorm:"type:text[]"`
+	FrontchannelLogoutURI  string         `json:"frontchannel_logout_uri"`
+	BackchannelLogoutURI   string         `json:"backchannel_logout_uri"`
+
 	// Client-specific Google OAuth (optional - if enabled, uses client settings; otherwise uses Authway common OAuth)
 	GoogleOAuthEnabled bool    `json:"google_oauth_enabled" gorm:"column:google_oauth_enabled;default:false"`
 	GoogleClientID     *string `json:"-" gorm:"column:google_client_id;null"`
@@ -41,6 +62,70 @@ type Client struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
+// TokenLifespans overrides Hydra's global token lifetimes per grant type
+// Values are Go duration strings (e.g. "1h", "720h"); empty values use Hydra defaults
+type TokenLifespans struct {
+	AuthorizationCodeGrantAccessTokenLifespan  string `json:"authorization_code_grant_access_token_lifespan,omitempty"`
+	AuthorizationCodeGrantIDTokenLifespan      string `json:"authorization_code_grant_id_token_lifespan,omitempty"`
+	AuthorizationCodeGrantRefreshTokenLifespan string `json:"authorization_code_grant_refresh_token_lifespan,omitempty"`
+	ClientCredentialsGrantAccessTokenLifespan  string `json:"client_credentials_grant_access_token_lifespan,omitempty"`
+	ImplicitGrantAccessTokenLifespan           string `json:"implicit_grant_access_token_lifespan,omitempty"`
+	ImplicitGrantIDTokenLifespan               string `json:"implicit_grant_id_token_lifespan,omitempty"`
+	RefreshTokenGrantAccessTokenLifespan       string `json:"refresh_token_grant_access_token_lifespan,omitempty"`
+	RefreshTokenGrantIDTokenLifespan           string `json:"refresh_token_grant_id_token_lifespan,omitempty"`
+	RefreshTokenGrantRefreshTokenLifespan      string `json:"refresh_token_grant_refresh_token_lifespan,omitempty"`
+}
+
+// Scan implements sql.Scanner for TokenLifespans (JSONB support)
+func (l *TokenLifespans) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements driver.Valuer for TokenLifespans (JSONB support)
+func (l TokenLifespans) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// Validate checks that every configured lifespan is a positive duration
+func (l TokenLifespans) Validate() []string {
+	var violations []string
+	fields := map[string]string{
+		"authorization_code_grant_access_token_lifespan":  l.AuthorizationCodeGrantAccessTokenLifespan,
+		"authorization_code_grant_id_token_lifespan":      l.AuthorizationCodeGrantIDTokenLifespan,
+		"authorization_code_grant_refresh_token_lifespan": l.AuthorizationCodeGrantRefreshTokenLifespan,
+		"client_credentials_grant_access_token_lifespan":  l.ClientCredentialsGrantAccessTokenLifespan,
+		"implicit_grant_access_token_lifespan":            l.ImplicitGrantAccessTokenLifespan,
+		"implicit_grant_id_token_lifespan":                l.ImplicitGrantIDTokenLifespan,
+		"refresh_token_grant_access_token_lifespan":       l.RefreshTokenGrantAccessTokenLifespan,
+		"refresh_token_grant_id_token_lifespan":           l.RefreshTokenGrantIDTokenLifespan,
+		"refresh_token_grant_refresh_token_lifespan":      l.RefreshTokenGrantRefreshTokenLifespan,
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			violations = append(violations, fmt.Sprintf("%s %q is not a valid duration", name, value))
+		}
+	}
+	sort.Strings(violations)
+	return violations
+}
+
 // BeforeCreate sets UUID if not provided
 func (c *Client) BeforeCreate(tx *gorm.DB) error {
 	if c.ID == uuid.Nil {
@@ -64,18 +149,33 @@ type PublicClient struct {
 	Public       bool      `json:"public"`
 	Active       bool      `json:"active"`
 
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	ResponseTypes           []string `json:"response_types"`
+	RequirePKCE             bool     `json:"require_pkce"`
+	JwksURI                 string   `json:"jwks_uri"`
+
+	TokenLifespans         TokenLifespans `json:"token_lifespans"`
+	Audience               []string       `json:"audience"`
+	AllowedCORSOrigins     []string       `json:"allowed_cors_origins"`
+	SkipConsent            bool           `json:"skip_consent"`
+	FirstParty             bool           `json:"first_party"`
+	PostLogoutRedirectURIs []string       `json:"post_logout_redirect_uris"`
+	FrontchannelLogoutURI  string         `json:"frontchannel_logout_uri"`
+	BackchannelLogoutURI   string         `json:"backchannel_logout_uri"`
+
 	// OAuth Settings (public fields only)
 	GoogleOAuthEnabled bool    `json:"google_oauth_enabled"`
 	GoogleRedirectURI  *string `json:"google_redirect_uri"`
 	GithubOAuthEnabled bool    `json:"github_oauth_enabled"`
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // ToPublic converts Client to PublicClient
 func (c *Client) ToPublic() PublicClient {
-	return PublicClient{
+	public := PublicClient{
 		ID:           c.ID,
 		TenantID:     c.TenantID,
 		ClientID:     c.ClientID,
@@ -89,6 +189,20 @@ func (c *Client) ToPublic() PublicClient {
 		Public:       c.Public,
 		Active:       c.Active,
 
+		TokenEndpointAuthMethod: c.TokenEndpointAuthMethod,
+		ResponseTypes:           c.ResponseTypes,
+		RequirePKCE:             c.RequirePKCE,
+		JwksURI:                 c.JwksURI,
+
+		TokenLifespans:         c.TokenLifespans,
+		Audience:               c.Audience,
+		AllowedCORSOrigins:     c.AllowedCORSOrigins,
+		SkipConsent:            c.SkipConsent,
+		FirstParty:             c.FirstParty,
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+		FrontchannelLogoutURI:  c.FrontchannelLogoutURI,
+		BackchannelLogoutURI:   c.BackchannelLogoutURI,
+
 		// OAuth public fields
 		GoogleOAuthEnabled: c.GoogleOAuthEnabled,
 		GoogleRedirectURI:  c.GoogleRedirectURI,
@@ -97,6 +211,10 @@ func (c *Client) ToPublic() PublicClient {
 		CreatedAt: c.CreatedAt,
 		UpdatedAt: c.UpdatedAt,
 	}
+	if c.DeletedAt.Valid {
+		public.DeletedAt = &c.DeletedAt.Time
+	}
+	return public
 }
 
 // CreateClientRequest represents the request to create a new OAuth client
@@ -108,11 +226,25 @@ type CreateClientRequest struct {
 	Description  string   `json:"description"`
 	Website      string   `json:"website" validate:"omitempty,url"`
 	Logo         string   `json:"logo" validate:"omitempty,url"`
-	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+	RedirectURIs []string `json:"redirect_uris" validate:"omitempty,dive,url"` // Required for authorization_code (enforced by Policy)
 	GrantTypes   []string `json:"grant_types" validate:"required,min=1"`
 	Scopes       []string `json:"scopes" validate:"required,min=1"`
 	Public       bool     `json:"public"`
 
+	// Token endpoint authentication (defaults: "none" for public, "client_secret_post" for confidential)
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method" validate:"omitempty,oneof=client_secret_basic client_secret_post private_key_jwt none"`
+	JwksURI                 string `json:"jwks_uri" validate:"omitempty,url"` // Required for private_key_jwt
+
+	// Token lifetimes, audience and browser settings (optional)
+	TokenLifespans         TokenLifespans `json:"token_lifespans"`
+	Audience               []string       `json:"audience" validate:"omitempty,dive,required"`
+	AllowedCORSOrigins     []string       `json:"allowed_cors_origins" validate:"omitempty,dive,url"`
+	SkipConsent            bool           `json:"skip_consent"`
+	FirstParty             bool           `json:"first_party"`
+	PostLogoutRedirectURIs []string       `json:"post_logout_redirect_uris" validate:"omitempty,dive,url"`
+	FrontchannelLogoutURI  string         `json:"frontchannel_logout_uri" validate:"omitempty,url"`
+	BackchannelLogoutURI   string         `json:"backchannel_logout_uri" validate:"omitempty,url"`
+
 	// Google OAuth Settings (optional)
 	GoogleOAuthEnabled bool   `json:"google_oauth_enabled"`
 	GoogleClientID     string `json:"google_client_id" validate:"required_with=GoogleOAuthEnabled"`
@@ -136,6 +268,19 @@ type UpdateClientRequest struct {
 	Scopes       []string `json:"scopes" validate:"omitempty,min=1"`
 	Active       *bool    `json:"active"` // Pointer to allow explicit false
 
+	TokenEndpointAuthMethod string  `json:"token_endpoint_auth_method" validate:"omitempty,oneof=client_secret_basic client_secret_post private_key_jwt none"`
+	JwksURI                 *string `json:"jwks_uri" validate:"omitempty,url"`
+
+	// Token lifetimes, audience and browser settings (nil leaves the current value unchanged)
+	TokenLifespans         *TokenLifespans `json:"token_lifespans"`
+	Audience               []string        `json:"audience" validate:"omitempty,dive,required"`
+	AllowedCORSOrigins     []string        `json:"allowed_cors_origins" validate:"omitempty,dive,url"`
+	SkipConsent            *bool           `json:"skip_consent"`
+	FirstParty             *bool           `json:"first_party"`
+	PostLogoutRedirectURIs []string        `json:"post_logout_redirect_uris" validate:"omitempty,dive,url"`
+	FrontchannelLogoutURI  *string         `json:"frontchannel_logout_uri" validate:"omitempty,url"`
+	BackchannelLogoutURI   *string         `json:"backchannel_logout_uri" validate:"omitempty,url"`
+
 	// Google OAuth Settings (optional)
 	GoogleOAuthEnabled *bool   `json:"google_oauth_enabled"` // Pointer to allow explicit false
 	GoogleClientID     *string `json:"google_client_id"`
@@ -0,0 +1,210 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"authway/src/server/pkg/tenant"
+)
+
+// Supported OAuth 2.0 grant types
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeImplicit          = "implicit"
+)
+
+// Supported token endpoint authentication methods
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+	AuthMethodNone              = "none"
+)
+
+// Policy describes which client registrations are acceptable
+// The global policy comes from config.OAuthConfig and can be tightened per tenant
+type Policy struct {
+	AllowedGrantTypes []string
+	AllowedScopes     []string
+	RequirePKCE       bool
+}
+
+// PolicyError is returned when a client registration violates the policy
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "client policy violation: " + strings.Join(e.Violations, "; ")
+}
+
+// ForTenant returns the effective policy after applying tenant overrides
+// Tenant overrides can only narrow the allowed lists and can only turn PKCE on
+func (p Policy) ForTenant(settings *tenant.TenantSettings) Policy {
+	effective := Policy{
+		AllowedGrantTypes: p.AllowedGrantTypes,
+		AllowedScopes:     p.AllowedScopes,
+		RequirePKCE:       p.RequirePKCE,
+	}
+	if settings == nil || settings.OAuthPolicy == nil {
+		return effective
+	}
+
+	override := settings.OAuthPolicy
+	if len(override.AllowedGrantTypes) > 0 {
+		effective.AllowedGrantTypes = intersect(effective.AllowedGrantTypes, override.AllowedGrantTypes)
+	}
+	if len(override.AllowedScopes) > 0 {
+		effective.AllowedScopes = intersect(effective.AllowedScopes, override.AllowedScopes)
+	}
+	if override.RequirePKCE != nil && *override.RequirePKCE {
+		effective.RequirePKCE = true
+	}
+	return effective
+}
+
+// Apply validates the client against the policy and fills in derived settings
+// (token endpoint auth method, response types and PKCE requirement)
+func (p Policy) Apply(c *Client) error {
+	var violations []string
+
+	// Grant types
+	for _, grantType := range c.GrantTypes {
+		if !contains(p.AllowedGrantTypes, grantType) {
+			violations = append(violations, fmt.Sprintf("grant type %q is not allowed", grantType))
+		}
+	}
+	hasAuthCode := contains(c.GrantTypes, GrantTypeAuthorizationCode)
+	hasImplicit := contains(c.GrantTypes, GrantTypeImplicit)
+	if contains(c.GrantTypes, GrantTypeRefreshToken) && !hasAuthCode {
+		violations = append(violations, "refresh_token grant requires authorization_code")
+	}
+	if (hasAuthCode || hasImplicit) && len(c.RedirectURIs) == 0 {
+		violations = append(violations, "redirect_uris are required for browser-based grant types")
+	}
+
+	// Scopes
+	for _, scope := range c.Scopes {
+		if !contains(p.AllowedScopes, scope) {
+			violations = append(violations, fmt.Sprintf("scope %q is not allowed", scope))
+		}
+	}
+
+	// Token endpoint authentication
+	if c.Public {
+		if c.TokenEndpointAuthMethod == "" {
+			c.TokenEndpointAuthMethod = AuthMethodNone
+		}
+		if c.TokenEndpointAuthMethod != AuthMethodNone {
+			violations = append(violations, "public clients must use token_endpoint_auth_method \"none\"")
+		}
+		if contains(c.GrantTypes, GrantTypeClientCredentials) {
+			violations = append(violations, "public clients cannot use the client_credentials grant")
+		}
+	} else {
+		if c.TokenEndpointAuthMethod == "" {
+			c.TokenEndpointAuthMethod = AuthMethodClientSecretPost
+		}
+		switch c.TokenEndpointAuthMethod {
+		case AuthMethodClientSecretBasic, AuthMethodClientSecretPost:
+		case AuthMethodPrivateKeyJWT:
+			if c.JwksURI == "" {
+				violations = append(violations, "private_key_jwt requires jwks_uri")
+			}
+		case AuthMethodNone:
+			violations = append(violations, "confidential clients cannot use token_endpoint_auth_method \"none\"")
+		default:
+			violations = append(violations, fmt.Sprintf("token_endpoint_auth_method %q is not supported", c.TokenEndpointAuthMethod))
+		}
+	}
+
+	// Token lifespans must be valid durations
+	violations = append(violations, c.TokenLifespans.Validate()...)
+
+	// Response types are derived from the grant types
+	c.ResponseTypes = responseTypesFor(c.GrantTypes)
+
+	// PKCE is mandatory for public clients and whenever the policy requires it
+	if hasAuthCode && (p.RequirePKCE || c.Public) {
+		c.RequirePKCE = true
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// ApplyUpdate validates an updated client like Apply, but enforces the policy only on settings that changed:
+// grant types and scopes the client was already registered with stay allowed, and a PKCE requirement
+// added to the policy later applies once the client's grant types change
+func (p Policy) ApplyUpdate(c, previous *Client) error {
+	effective := Policy{
+		AllowedGrantTypes: union(p.AllowedGrantTypes, previous.GrantTypes),
+		AllowedScopes:     union(p.AllowedScopes, previous.Scopes),
+		RequirePKCE:       p.RequirePKCE && !sameElements(c.GrantTypes, previous.GrantTypes),
+	}
+	return effective.Apply(c)
+}
+
+// responseTypesFor maps grant types to the response types Hydra should accept
+func responseTypesFor(grantTypes []string) []string {
+	var responseTypes []string
+	if contains(grantTypes, GrantTypeAuthorizationCode) {
+		responseTypes = append(responseTypes, "code")
+	}
+	if contains(grantTypes, GrantTypeImplicit) {
+		responseTypes = append(responseTypes, "token", "id_token")
+	}
+	if len(responseTypes) == 0 {
+		// Machine-to-machine clients never hit the authorization endpoint
+		responseTypes = []string{"token"}
+	}
+	return responseTypes
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func intersect(a, b []string) []string {
+	result := []string{}
+	for _, item := range a {
+		if contains(b, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func union(a, b []string) []string {
+	result := append([]string{}, a...)
+	for _, item := range b {
+		if !contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// sameElements reports whether a and b contain the same values, ignoring order
+func sameElements(a, b []string) bool {
+	for _, item := range a {
+		if !contains(b, item) {
+			return false
+		}
+	}
+	for _, item := range b {
+		if !contains(a, item) {
+			return false
+		}
+	}
+	return true
+}
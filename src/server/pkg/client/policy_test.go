@@ -0,0 +1,260 @@
+package client
+
+import (
+	"testing"
+
+	"authway/src/server/pkg/tenant"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		AllowedGrantTypes: []string{"authorization_code", "refresh_token", "client_credentials"},
+		AllowedScopes:     []string{"openid", "profile", "email", "offline_access"},
+		RequirePKCE:       false,
+	}
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	tests := []struct {
+		name           string
+		client         *Client
+		expectError    bool
+		errorContains  string
+		expectMethod   string
+		expectResponse []string
+		expectPKCE     bool
+	}{
+		{
+			name: "confidential authorization code client",
+			client: &Client{
+				RedirectURIs: pq.StringArray{"https://example.com/callback"},
+				GrantTypes:   pq.StringArray{"authorization_code", "refresh_token"},
+				Scopes:       pq.StringArray{"openid", "email"},
+			},
+			expectMethod:   AuthMethodClientSecretPost,
+			expectResponse: []string{"code"},
+		},
+		{
+			name: "public client defaults to none and PKCE",
+			client: &Client{
+				RedirectURIs: pq.StringArray{"https://example.com/callback"},
+				GrantTypes:   pq.StringArray{"authorization_code"},
+				Scopes:       pq.StringArray{"openid"},
+				Public:       true,
+			},
+			expectMethod:   AuthMethodNone,
+			expectResponse: []string{"code"},
+			expectPKCE:     true,
+		},
+		{
+			name: "machine to machine client",
+			client: &Client{
+				GrantTypes: pq.StringArray{"client_credentials"},
+				Scopes:     pq.StringArray{"profile"},
+			},
+			expectMethod:   AuthMethodClientSecretPost,
+			expectResponse: []string{"token"},
+		},
+		{
+			name: "public client cannot use client_credentials",
+			client: &Client{
+				GrantTypes: pq.StringArray{"client_credentials"},
+				Scopes:     pq.StringArray{"openid"},
+				Public:     true,
+			},
+			expectError:   true,
+			errorContains: "public clients cannot use the client_credentials grant",
+		},
+		{
+			name: "public client with secret auth method",
+			client: &Client{
+				RedirectURIs:            pq.StringArray{"https://example.com/callback"},
+				GrantTypes:              pq.StringArray{"authorization_code"},
+				Scopes:                  pq.StringArray{"openid"},
+				Public:                  true,
+				TokenEndpointAuthMethod: AuthMethodClientSecretBasic,
+			},
+			expectError:   true,
+			errorContains: "public clients must use token_endpoint_auth_method",
+		},
+		{
+			name: "private_key_jwt requires jwks_uri",
+			client: &Client{
+				GrantTypes:              pq.StringArray{"client_credentials"},
+				Scopes:                  pq.StringArray{"openid"},
+				TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT,
+			},
+			expectError:   true,
+			errorContains: "private_key_jwt requires jwks_uri",
+		},
+		{
+			name: "grant type not allowed",
+			client: &Client{
+				RedirectURIs: pq.StringArray{"https://example.com/callback"},
+				GrantTypes:   pq.StringArray{"implicit"},
+				Scopes:       pq.StringArray{"openid"},
+			},
+			expectError:   true,
+			errorContains: `grant type "implicit" is not allowed`,
+		},
+		{
+			name: "scope not allowed",
+			client: &Client{
+				RedirectURIs: pq.StringArray{"https://example.com/callback"},
+				GrantTypes:   pq.StringArray{"authorization_code"},
+				Scopes:       pq.StringArray{"openid", "admin"},
+			},
+			expectError:   true,
+			errorContains: `scope "admin" is not allowed`,
+		},
+		{
+			name: "authorization code requires redirect uris",
+			client: &Client{
+				GrantTypes: pq.StringArray{"authorization_code"},
+				Scopes:     pq.StringArray{"openid"},
+			},
+			expectError:   true,
+			errorContains: "redirect_uris are required",
+		},
+		{
+			name: "refresh token alone",
+			client: &Client{
+				GrantTypes: pq.StringArray{"refresh_token"},
+				Scopes:     pq.StringArray{"openid"},
+			},
+			expectError:   true,
+			errorContains: "refresh_token grant requires authorization_code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testPolicy().Apply(tt.client)
+
+			if tt.expectError {
+				require.Error(t, err)
+				var policyErr *PolicyError
+				assert.ErrorAs(t, err, &policyErr)
+				assert.Contains(t, err.Error(), tt.errorContains)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectMethod, tt.client.TokenEndpointAuthMethod)
+			assert.Equal(t, pq.StringArray(tt.expectResponse), tt.client.ResponseTypes)
+			assert.Equal(t, tt.expectPKCE, tt.client.RequirePKCE)
+		})
+	}
+}
+
+func TestPolicy_ForTenant(t *testing.T) {
+	requirePKCE := true
+	disablePKCE := false
+
+	t.Run("no overrides", func(t *testing.T) {
+		effective := testPolicy().ForTenant(&tenant.TenantSettings{})
+		assert.Equal(t, testPolicy(), effective)
+	})
+
+	t.Run("overrides narrow the global policy", func(t *testing.T) {
+		effective := testPolicy().ForTenant(&tenant.TenantSettings{
+			OAuthPolicy: &tenant.OAuthPolicySettings{
+				AllowedGrantTypes: []string{"authorization_code", "implicit"},
+				AllowedScopes:     []string{"openid"},
+				RequirePKCE:       &requirePKCE,
+			},
+		})
+
+		assert.Equal(t, []string{"authorization_code"}, effective.AllowedGrantTypes)
+		assert.Equal(t, []string{"openid"}, effective.AllowedScopes)
+		assert.True(t, effective.RequirePKCE)
+	})
+
+	t.Run("overrides cannot disable global PKCE", func(t *testing.T) {
+		global := testPolicy()
+		global.RequirePKCE = true
+
+		effective := global.ForTenant(&tenant.TenantSettings{
+			OAuthPolicy: &tenant.OAuthPolicySettings{RequirePKCE: &disablePKCE},
+		})
+		assert.True(t, effective.RequirePKCE)
+	})
+
+	t.Run("tenant PKCE applies to confidential clients", func(t *testing.T) {
+		effective := testPolicy().ForTenant(&tenant.TenantSettings{
+			OAuthPolicy: &tenant.OAuthPolicySettings{RequirePKCE: &requirePKCE},
+		})
+
+		c := &Client{
+			RedirectURIs: pq.StringArray{"https://example.com/callback"},
+			GrantTypes:   pq.StringArray{"authorization_code"},
+			Scopes:       pq.StringArray{"openid"},
+		}
+		require.NoError(t, effective.Apply(c))
+		assert.True(t, c.RequirePKCE)
+	})
+}
+
+func TestPolicy_Apply_TokenLifespans(t *testing.T) {
+	c := &Client{
+		GrantTypes: pq.StringArray{"client_credentials"},
+		Scopes:     pq.StringArray{"openid"},
+		TokenLifespans: TokenLifespans{
+			ClientCredentialsGrantAccessTokenLifespan: "15m",
+			RefreshTokenGrantRefreshTokenLifespan:     "forever",
+		},
+	}
+
+	err := testPolicy().Apply(c)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `refresh_token_grant_refresh_token_lifespan "forever" is not a valid duration`)
+	assert.NotContains(t, err.Error(), "client_credentials_grant_access_token_lifespan")
+}
+
+func TestPolicy_ApplyUpdate(t *testing.T) {
+	registered := func() *Client {
+		return &Client{
+			RedirectURIs: pq.StringArray{"https://example.com/callback"},
+			GrantTypes:   pq.StringArray{"authorization_code", "implicit"},
+			Scopes:       pq.StringArray{"openid", "legacy"},
+		}
+	}
+
+	t.Run("unchanged settings stay allowed", func(t *testing.T) {
+		previous := registered()
+		updated := *previous
+		updated.Name = "Renamed"
+
+		require.NoError(t, testPolicy().ApplyUpdate(&updated, previous))
+	})
+
+	t.Run("changed settings are enforced", func(t *testing.T) {
+		previous := registered()
+		updated := *previous
+		updated.Scopes = pq.StringArray{"openid", "legacy", "admin"}
+
+		err := testPolicy().ApplyUpdate(&updated, previous)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `scope "admin" is not allowed`)
+		assert.NotContains(t, err.Error(), "legacy")
+	})
+
+	t.Run("PKCE required once grant types change", func(t *testing.T) {
+		policy := testPolicy()
+		policy.AllowedGrantTypes = append(policy.AllowedGrantTypes, "implicit")
+		policy.RequirePKCE = true
+
+		previous := registered()
+		unchanged := *previous
+		require.NoError(t, policy.ApplyUpdate(&unchanged, previous))
+		assert.False(t, unchanged.RequirePKCE)
+
+		changed := *previous
+		changed.GrantTypes = pq.StringArray{"authorization_code"}
+		require.NoError(t, policy.ApplyUpdate(&changed, previous))
+		assert.True(t, changed.RequirePKCE)
+	})
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"strings"
+	"time"
 
 	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/tenant"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -19,6 +21,10 @@ type Service interface {
 	GetByTenant(tenantID uuid.UUID, limit, offset int) ([]*Client, int64, error)
 	Update(id uuid.UUID, req *UpdateClientRequest) (*Client, error)
 	Delete(id uuid.UUID) error
+	Restore(id uuid.UUID) (*Client, error)
+	ListDeleted(limit, offset int) ([]*Client, int64, error)
+	Purge(before time.Time) (int64, error)
+	PurgeTenant(tenantID uuid.UUID) (int64, error)
 	List(limit, offset int) ([]*Client, int64, error)
 	ValidateClient(clientID, clientSecret string) (*Client, error)
 	RegenerateSecret(id uuid.UUID) (*ClientCredentials, error)
@@ -28,13 +34,15 @@ type service struct {
 	db          *gorm.DB
 	logger      *zap.Logger
 	hydraClient *hydra.Client
+	policy      Policy
 }
 
-func NewService(db *gorm.DB, logger *zap.Logger, hydraClient *hydra.Client) Service {
+func NewService(db *gorm.DB, logger *zap.Logger, hydraClient *hydra.Client, policy Policy) Service {
 	return &service{
 		db:          db,
 		logger:      logger,
 		hydraClient: hydraClient,
+		policy:      policy,
 	}
 }
 
@@ -46,12 +54,8 @@ func (s *service) Create(req *CreateClientRequest) (*Client, *ClientCredentials,
 	}
 
 	// Verify tenant exists
-	var tenantExists bool
-	if err := s.db.Raw("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = ? AND active = true)", tenantID).Scan(&tenantExists).Error; err != nil {
-		return nil, nil, fmt.Errorf("failed to verify tenant: %w", err)
-	}
-	if !tenantExists {
-		return nil, nil, fmt.Errorf("tenant not found or inactive")
+	if err := s.checkTenant(tenantID); err != nil {
+		return nil, nil, err
 	}
 
 	// Use provided credentials or generate new ones
@@ -60,6 +64,15 @@ func (s *service) Create(req *CreateClientRequest) (*Client, *ClientCredentials,
 		// Use fixed credentials provided in request
 		clientID = req.ClientID
 		clientSecret = req.ClientSecret
+
+		// Client IDs are never reused, not even those of deleted clients, since relying parties and Hydra refer to them
+		var taken int64
+		if err := s.db.Unscoped().Model(&Client{}).Where("client_id = ?", clientID).Count(&taken).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to check client_id: %w", err)
+		}
+		if taken > 0 {
+			return nil, nil, ErrClientIDTaken
+		}
 		s.logger.Info("Using provided client credentials",
 			zap.String("client_id", clientID),
 			zap.String("tenant_id", tenantID.String()))
@@ -86,6 +99,23 @@ func (s *service) Create(req *CreateClientRequest) (*Client, *ClientCredentials,
 		Scopes:       req.Scopes,
 		Public:       req.Public,
 		Active:       true,
+
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		JwksURI:                 req.JwksURI,
+
+		TokenLifespans:         req.TokenLifespans,
+		Audience:               req.Audience,
+		AllowedCORSOrigins:     req.AllowedCORSOrigins,
+		SkipConsent:            req.SkipConsent,
+		FirstParty:             req.FirstParty,
+		PostLogoutRedirectURIs: req.PostLogoutRedirectURIs,
+		FrontchannelLogoutURI:  req.FrontchannelLogoutURI,
+		BackchannelLogoutURI:   req.BackchannelLogoutURI,
+	}
+
+	// Enforce global and tenant OAuth policy
+	if err := s.applyPolicy(client); err != nil {
+		return nil, nil, err
 	}
 
 	// Set Google OAuth if provided
@@ -119,16 +149,7 @@ func (s *service) Create(req *CreateClientRequest) (*Client, *ClientCredentials,
 	}
 
 	// Register client in Hydra
-	hydraClient := &hydra.OAuth2Client{
-		ClientID:                clientID,
-		ClientSecret:            clientSecret,
-		ClientName:              client.Name,
-		RedirectUris:            client.RedirectURIs,
-		GrantTypes:              client.GrantTypes,
-		ResponseTypes:           []string{"code"}, // Default to authorization code flow
-		Scope:                   strings.Join(client.Scopes, " "),
-		TokenEndpointAuthMethod: "client_secret_post",
-	}
+	hydraClient := toHydraClient(client, clientSecret)
 
 	// DEBUG: Log Hydra Client AdminURL before making request
 	s.logger.Info("🔍 DEBUG: About to call Hydra CreateOAuth2Client",
@@ -150,6 +171,10 @@ func (s *service) Create(req *CreateClientRequest) (*Client, *ClientCredentials,
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 	}
+	if client.Public {
+		// Public clients authenticate with PKCE only
+		credentials.ClientSecret = ""
+	}
 
 	s.logger.Info("Client created successfully in database and Hydra",
 		zap.String("id", client.ID.String()),
@@ -190,6 +215,7 @@ func (s *service) Update(id uuid.UUID, req *UpdateClientRequest) (*Client, error
 		}
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
+	previous := client
 
 	// Update fields
 	if req.Name != "" {
@@ -216,6 +242,36 @@ func (s *service) Update(id uuid.UUID, req *UpdateClientRequest) (*Client, error
 	if req.Active != nil {
 		client.Active = *req.Active
 	}
+	if req.TokenEndpointAuthMethod != "" {
+		client.TokenEndpointAuthMethod = req.TokenEndpointAuthMethod
+	}
+	if req.JwksURI != nil {
+		client.JwksURI = *req.JwksURI
+	}
+	if req.TokenLifespans != nil {
+		client.TokenLifespans = *req.TokenLifespans
+	}
+	if req.Audience != nil {
+		client.Audience = req.Audience
+	}
+	if req.AllowedCORSOrigins != nil {
+		client.AllowedCORSOrigins = req.AllowedCORSOrigins
+	}
+	if req.SkipConsent != nil {
+		client.SkipConsent = *req.SkipConsent
+	}
+	if req.FirstParty != nil {
+		client.FirstParty = *req.FirstParty
+	}
+	if req.PostLogoutRedirectURIs != nil {
+		client.PostLogoutRedirectURIs = req.PostLogoutRedirectURIs
+	}
+	if req.FrontchannelLogoutURI != nil {
+		client.FrontchannelLogoutURI = *req.FrontchannelLogoutURI
+	}
+	if req.BackchannelLogoutURI != nil {
+		client.BackchannelLogoutURI = *req.BackchannelLogoutURI
+	}
 
 	// Google OAuth settings
 	if req.GoogleOAuthEnabled != nil {
@@ -231,22 +287,22 @@ func (s *service) Update(id uuid.UUID, req *UpdateClientRequest) (*Client, error
 		client.GoogleRedirectURI = req.GoogleRedirectURI
 	}
 
+	// Re-validate the changed settings against the OAuth policy
+	policy, err := s.tenantPolicy(client.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.ApplyUpdate(&client, &previous); err != nil {
+		return nil, err
+	}
+
 	if err := s.db.Save(&client).Error; err != nil {
 		s.logger.Error("Failed to update client", zap.Error(err), zap.String("id", id.String()))
 		return nil, fmt.Errorf("failed to update client: %w", err)
 	}
 
 	// Update client in Hydra
-	hydraUpdate := &hydra.OAuth2Client{
-		ClientID:                client.ClientID,
-		ClientSecret:            client.ClientSecret,
-		ClientName:              client.Name,
-		RedirectUris:            client.RedirectURIs,
-		GrantTypes:              client.GrantTypes,
-		ResponseTypes:           []string{"code"},
-		Scope:                   strings.Join(client.Scopes, " "),
-		TokenEndpointAuthMethod: "client_secret_post",
-	}
+	hydraUpdate := toHydraClient(&client, client.ClientSecret)
 
 	_, errHydra := s.hydraClient.UpdateOAuth2Client(client.ClientID, hydraUpdate)
 	if errHydra != nil {
@@ -291,6 +347,102 @@ func (s *service) Delete(id uuid.UUID) error {
 	return nil
 }
 
+// Restore undeletes a soft-deleted client and registers it in Hydra again with its stored secret
+// Its settings are not changed, so the policy is applied as on an update that changes nothing:
+// grant types and scopes the client was registered with stay allowed
+func (s *service) Restore(id uuid.UUID) (*Client, error) {
+	var client Client
+	if err := s.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&client).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDeletedNotFound
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	if err := s.checkTenant(client.TenantID); err != nil {
+		return nil, err
+	}
+	policy, err := s.tenantPolicy(client.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	previous := client
+	if err := policy.ApplyUpdate(&client, &previous); err != nil {
+		return nil, err
+	}
+
+	client.DeletedAt = gorm.DeletedAt{}
+	if err := s.db.Unscoped().Save(&client).Error; err != nil {
+		s.logger.Error("Failed to restore client", zap.Error(err), zap.String("id", id.String()))
+		return nil, fmt.Errorf("failed to restore client: %w", err)
+	}
+
+	if _, err := s.hydraClient.CreateOAuth2Client(toHydraClient(&client, client.ClientSecret)); err != nil {
+		// Keep the client deleted if Hydra registration fails
+		s.db.Delete(&client)
+		s.logger.Error("Failed to register restored client in Hydra, deleted it again",
+			zap.Error(err),
+			zap.String("client_id", client.ClientID))
+		return nil, fmt.Errorf("failed to register client in Hydra: %w", err)
+	}
+
+	s.logger.Info("Client restored successfully", zap.String("id", id.String()), zap.String("client_id", client.ClientID))
+	return &client, nil
+}
+
+// ListDeleted lists soft-deleted clients that have not been purged yet, most recently deleted first
+func (s *service) ListDeleted(limit, offset int) ([]*Client, int64, error) {
+	var clients []*Client
+	var total int64
+
+	if err := s.db.Unscoped().Model(&Client{}).Where("deleted_at IS NOT NULL").Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count deleted clients: %w", err)
+	}
+
+	if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Limit(limit).Offset(offset).Find(&clients).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list deleted clients: %w", err)
+	}
+
+	return clients, total, nil
+}
+
+// Purge permanently removes the clients soft-deleted before the given time and returns how many were removed
+// Their client_id rows go with them, so the IDs become reusable only after the purge
+func (s *service) Purge(before time.Time) (int64, error) {
+	var clients []Client
+	if err := s.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Find(&clients).Error; err != nil {
+		return 0, fmt.Errorf("failed to find clients to purge: %w", err)
+	}
+	return s.purge(clients)
+}
+
+// PurgeTenant permanently removes all clients of a tenant that is being purged and returns how many were removed
+func (s *service) PurgeTenant(tenantID uuid.UUID) (int64, error) {
+	var clients []Client
+	if err := s.db.Unscoped().Where("tenant_id = ?", tenantID).Find(&clients).Error; err != nil {
+		return 0, fmt.Errorf("failed to find clients to purge: %w", err)
+	}
+	return s.purge(clients)
+}
+
+// purge deletes clients from Hydra and the database
+func (s *service) purge(clients []Client) (int64, error) {
+	var purged int64
+	for i := range clients {
+		// Deleting from Hydra is best-effort on Delete, so make sure nothing is left behind
+		if err := s.hydraClient.DeleteOAuth2Client(clients[i].ClientID); err != nil {
+			s.logger.Debug("Client not deleted from Hydra during purge", zap.Error(err), zap.String("client_id", clients[i].ClientID))
+		}
+		result := s.db.Unscoped().Delete(&clients[i])
+		if result.Error != nil {
+			return purged, fmt.Errorf("failed to purge client: %w", result.Error)
+		}
+		purged += result.RowsAffected
+	}
+
+	return purged, nil
+}
+
 func (s *service) List(limit, offset int) ([]*Client, int64, error) {
 	var clients []*Client
 	var total int64
@@ -337,6 +489,10 @@ func (s *service) RegenerateSecret(id uuid.UUID) (*ClientCredentials, error) {
 		return nil, err
 	}
 
+	if client.Public {
+		return nil, fmt.Errorf("public clients do not have a client secret")
+	}
+
 	// Generate new secret
 	newSecret := s.generateClientSecret()
 
@@ -346,16 +502,7 @@ func (s *service) RegenerateSecret(id uuid.UUID) (*ClientCredentials, error) {
 	}
 
 	// Update secret in Hydra
-	hydraUpdate := &hydra.OAuth2Client{
-		ClientID:                client.ClientID,
-		ClientSecret:            newSecret,
-		ClientName:              client.Name,
-		RedirectUris:            client.RedirectURIs,
-		GrantTypes:              client.GrantTypes,
-		ResponseTypes:           []string{"code"},
-		Scope:                   strings.Join(client.Scopes, " "),
-		TokenEndpointAuthMethod: "client_secret_post",
-	}
+	hydraUpdate := toHydraClient(client, newSecret)
 
 	_, errHydra := s.hydraClient.UpdateOAuth2Client(client.ClientID, hydraUpdate)
 	if errHydra != nil {
@@ -374,6 +521,81 @@ func (s *service) RegenerateSecret(id uuid.UUID) (*ClientCredentials, error) {
 	return credentials, nil
 }
 
+// applyPolicy validates the client against the effective policy of its tenant
+func (s *service) applyPolicy(client *Client) error {
+	policy, err := s.tenantPolicy(client.TenantID)
+	if err != nil {
+		return err
+	}
+	return policy.Apply(client)
+}
+
+// tenantPolicy returns the global OAuth policy narrowed by the tenant's overrides
+func (s *service) tenantPolicy(tenantID uuid.UUID) (Policy, error) {
+	var settings tenant.TenantSettings
+	if err := s.db.Table("tenants").Select("settings").Where("id = ?", tenantID).Row().Scan(&settings); err != nil {
+		return Policy{}, fmt.Errorf("failed to load tenant settings: %w", err)
+	}
+	return s.policy.ForTenant(&settings), nil
+}
+
+// checkTenant returns ErrTenantUnavailable unless the tenant exists, is active and is not deleted
+func (s *service) checkTenant(tenantID uuid.UUID) error {
+	var tenantExists bool
+	if err := s.db.Raw("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = ? AND active = true AND deleted_at IS NULL)", tenantID).Scan(&tenantExists).Error; err != nil {
+		return fmt.Errorf("failed to verify tenant: %w", err)
+	}
+	if !tenantExists {
+		return ErrTenantUnavailable
+	}
+	return nil
+}
+
+// toHydraClient builds the Hydra representation of a client
+func toHydraClient(client *Client, secret string) *hydra.OAuth2Client {
+	hydraClient := &hydra.OAuth2Client{
+		ClientID:                client.ClientID,
+		ClientName:              client.Name,
+		RedirectUris:            client.RedirectURIs,
+		GrantTypes:              client.GrantTypes,
+		ResponseTypes:           client.ResponseTypes,
+		Scope:                   strings.Join(client.Scopes, " "),
+		TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+		JwksURI:                 client.JwksURI,
+		Audience:                client.Audience,
+		AllowedCorsOrigins:      client.AllowedCORSOrigins,
+		SkipConsent:             client.SkipConsent,
+		PostLogoutRedirectUris:  client.PostLogoutRedirectURIs,
+		FrontchannelLogoutURI:   client.FrontchannelLogoutURI,
+		BackchannelLogoutURI:    client.BackchannelLogoutURI,
+		LogoURI:                 client.Logo,
+		ClientURI:               client.Website,
+		OAuth2ClientLifespans: hydra.OAuth2ClientLifespans{
+			AuthorizationCodeGrantAccessTokenLifespan:  client.TokenLifespans.AuthorizationCodeGrantAccessTokenLifespan,
+			AuthorizationCodeGrantIDTokenLifespan:      client.TokenLifespans.AuthorizationCodeGrantIDTokenLifespan,
+			AuthorizationCodeGrantRefreshTokenLifespan: client.TokenLifespans.AuthorizationCodeGrantRefreshTokenLifespan,
+			ClientCredentialsGrantAccessTokenLifespan:  client.TokenLifespans.ClientCredentialsGrantAccessTokenLifespan,
+			ImplicitGrantAccessTokenLifespan:           client.TokenLifespans.ImplicitGrantAccessTokenLifespan,
+			ImplicitGrantIDTokenLifespan:               client.TokenLifespans.ImplicitGrantIDTokenLifespan,
+			RefreshTokenGrantAccessTokenLifespan:       client.TokenLifespans.RefreshTokenGrantAccessTokenLifespan,
+			RefreshTokenGrantIDTokenLifespan:           client.TokenLifespans.RefreshTokenGrantIDTokenLifespan,
+			RefreshTokenGrantRefreshTokenLifespan:      client.TokenLifespans.RefreshTokenGrantRefreshTokenLifespan,
+		},
+		Metadata: map[string]interface{}{
+			"tenant_id":    client.TenantID.String(),
+			"require_pkce": client.RequirePKCE,
+			"first_party":  client.FirstParty,
+		},
+	}
+
+	// Only confidential clients using a shared secret send it to Hydra
+	if client.TokenEndpointAuthMethod == AuthMethodClientSecretBasic || client.TokenEndpointAuthMethod == AuthMethodClientSecretPost {
+		hydraClient.ClientSecret = secret
+	}
+
+	return hydraClient
+}
+
 func (s *service) generateClientID() string {
 	// Generate a random client ID
 	bytes := make([]byte, 16)
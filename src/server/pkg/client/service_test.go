@@ -1,9 +1,16 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/tenant"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
@@ -13,31 +20,87 @@ import (
 	"gorm.io/gorm"
 )
 
+// testTenantID is the tenant the clients of these tests belong to
+var testTenantID = uuid.New()
+
 func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 
 	// Auto migrate the schema
-	err = db.AutoMigrate(&Client{})
+	err = db.AutoMigrate(&tenant.Tenant{}, &Client{})
 	require.NoError(t, err)
 
+	require.NoError(t, db.Create(&tenant.Tenant{ID: testTenantID, Name: "Test Tenant", Slug: "test-tenant", Active: true}).Error)
+
 	return db
 }
 
+// fakeHydra is an in-memory stand-in for Hydra's admin client API
+type fakeHydra struct {
+	mu         sync.Mutex
+	clients    map[string]bool
+	failCreate bool
+}
+
+func (f *fakeHydra) registered(clientID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.clients[clientID]
+}
+
+func (f *fakeHydra) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	clientID := strings.TrimPrefix(r.URL.Path, "/admin/clients/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/admin/clients":
+		if f.failCreate {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		var body hydra.OAuth2Client
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.clients[body.ClientID] = true
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body)
+	case r.Method == http.MethodPut && f.clients[clientID]:
+		var body hydra.OAuth2Client
+		json.NewDecoder(r.Body).Decode(&body)
+		json.NewEncoder(w).Encode(body)
+	case r.Method == http.MethodDelete && f.clients[clientID]:
+		delete(f.clients, clientID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestService returns a client service that registers clients in a fake Hydra
+func newTestService(t *testing.T, db *gorm.DB) (Service, *fakeHydra) {
+	fake := &fakeHydra{clients: map[string]bool{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewService(db, zaptest.NewLogger(t), hydra.NewClient(server.URL), testPolicy()), fake
+}
+
 func TestNewService(t *testing.T) {
 	db := setupTestDB(t)
 	logger := zaptest.NewLogger(t)
 
-	service := NewService(db, logger)
+	svc := NewService(db, logger, hydra.NewClient("http://hydra.invalid"), testPolicy())
 
-	assert.NotNil(t, service)
-	assert.IsType(t, &service{}, service)
+	assert.NotNil(t, svc)
+	assert.IsType(t, &service{}, svc)
 }
 
 func TestService_Create(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	tests := []struct {
 		name        string
@@ -48,6 +111,7 @@ func TestService_Create(t *testing.T) {
 		{
 			name: "successful client creation",
 			request: &CreateClientRequest{
+				TenantID:     testTenantID.String(),
 				Name:         "Test App",
 				Description:  "Test application",
 				Website:      "https://example.com",
@@ -62,6 +126,7 @@ func TestService_Create(t *testing.T) {
 		{
 			name: "successful public client creation",
 			request: &CreateClientRequest{
+				TenantID:     testTenantID.String(),
 				Name:         "Public App",
 				Description:  "Public application",
 				RedirectURIs: []string{"https://example.com/callback"},
@@ -74,6 +139,7 @@ func TestService_Create(t *testing.T) {
 		{
 			name: "client with Google OAuth settings",
 			request: &CreateClientRequest{
+				TenantID:           testTenantID.String(),
 				Name:               "Google OAuth App",
 				Description:        "App with Google OAuth",
 				RedirectURIs:       []string{"https://example.com/callback"},
@@ -116,9 +182,13 @@ func TestService_Create(t *testing.T) {
 				assert.Equal(t, tt.request.Public, client.Public)
 				assert.True(t, client.Active)
 
-				// Verify credentials
+				// Verify credentials; public clients authenticate with PKCE and get no secret
 				assert.Equal(t, client.ClientID, credentials.ClientID)
-				assert.Equal(t, client.ClientSecret, credentials.ClientSecret)
+				if tt.request.Public {
+					assert.Empty(t, credentials.ClientSecret)
+				} else {
+					assert.Equal(t, client.ClientSecret, credentials.ClientSecret)
+				}
 
 				// Verify Google OAuth settings
 				assert.Equal(t, tt.request.GoogleOAuthEnabled, client.GoogleOAuthEnabled)
@@ -133,7 +203,7 @@ func TestService_Create(t *testing.T) {
 				assert.True(t, len(credentials.ClientID) > 10)
 
 				// Verify client secret generation
-				assert.True(t, len(credentials.ClientSecret) > 40)
+				assert.True(t, len(client.ClientSecret) > 40)
 			}
 		})
 	}
@@ -141,11 +211,11 @@ func TestService_Create(t *testing.T) {
 
 func TestService_GetByID(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create test client
 	testClient, _, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Test App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -191,11 +261,11 @@ func TestService_GetByID(t *testing.T) {
 
 func TestService_GetByClientID(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create test client
 	testClient, _, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Test App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -242,11 +312,11 @@ func TestService_GetByClientID(t *testing.T) {
 
 func TestService_Update(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create test client
 	testClient, _, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Original App",
 		Description:  "Original description",
 		RedirectURIs: []string{"https://example.com/callback"},
@@ -365,11 +435,11 @@ func TestService_Update(t *testing.T) {
 
 func TestService_Delete(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create test client
 	testClient, _, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Test App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -415,15 +485,42 @@ func TestService_Delete(t *testing.T) {
 	}
 }
 
+func TestService_Restore(t *testing.T) {
+	db := setupTestDB(t)
+	service, fake := newTestService(t, db)
+
+	testClient, _, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
+		Name:         "Legacy App",
+		RedirectURIs: []string{"https://example.com/callback"},
+		GrantTypes:   []string{"authorization_code"},
+		Scopes:       []string{"openid"},
+	})
+	require.NoError(t, err)
+
+	// Settings the policy no longer allows, registered before it was tightened
+	require.NoError(t, db.Model(&Client{}).Where("id = ?", testClient.ID).
+		Update("scopes", pq.StringArray{"openid", "legacy"}).Error)
+	require.NoError(t, service.Delete(testClient.ID))
+
+	restored, err := service.Restore(testClient.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"openid", "legacy"}, restored.Scopes)
+	assert.True(t, fake.registered(restored.ClientID))
+
+	_, err = service.Restore(testClient.ID)
+	assert.ErrorIs(t, err, ErrDeletedNotFound)
+}
+
 func TestService_List(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create multiple test clients
 	clients := make([]*Client, 5)
 	for i := 0; i < 5; i++ {
 		client, _, err := service.Create(&CreateClientRequest{
+			TenantID:     testTenantID.String(),
 			Name:         fmt.Sprintf("Client %d", i),
 			RedirectURIs: []string{fmt.Sprintf("https://example%d.com/callback", i)},
 			GrantTypes:   []string{"authorization_code"},
@@ -498,11 +595,11 @@ func TestService_List(t *testing.T) {
 
 func TestService_ValidateClient(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create confidential client
-	confidentialClient, credentials, err := service.Create(&CreateClientRequest{
+	_, credentials, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Confidential App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -512,7 +609,8 @@ func TestService_ValidateClient(t *testing.T) {
 	require.NoError(t, err)
 
 	// Create public client
-	publicClient, publicCredentials, err := service.Create(&CreateClientRequest{
+	_, publicCredentials, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Public App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -523,6 +621,7 @@ func TestService_ValidateClient(t *testing.T) {
 
 	// Create inactive client
 	inactiveClient, inactiveCredentials, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Inactive App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
@@ -599,11 +698,11 @@ func TestService_ValidateClient(t *testing.T) {
 
 func TestService_RegenerateSecret(t *testing.T) {
 	db := setupTestDB(t)
-	logger := zaptest.NewLogger(t)
-	service := NewService(db, logger)
+	service, _ := newTestService(t, db)
 
 	// Create test client
 	testClient, originalCredentials, err := service.Create(&CreateClientRequest{
+		TenantID:     testTenantID.String(),
 		Name:         "Test App",
 		RedirectURIs: []string{"https://example.com/callback"},
 		GrantTypes:   []string{"authorization_code"},
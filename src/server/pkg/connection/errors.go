@@ -0,0 +1,36 @@
+package connection
+
+import "errors"
+
+// Connection-specific errors
+var (
+	// ErrNotFound is returned when a connection is not found
+	ErrNotFound = errors.New("connection not found")
+
+	// ErrDuplicateName is returned when a connection with the same name already exists in the tenant
+	ErrDuplicateName = errors.New("connection with this name already exists")
+
+	// ErrDomainTaken is returned when a domain is already routed to another connection of the tenant
+	ErrDomainTaken = errors.New("domain is already used by another connection")
+
+	// ErrInvalidSAMLSettings is returned when a SAML connection lacks a usable IdP entity ID, SSO URL or certificate
+	ErrInvalidSAMLSettings = errors.New("invalid SAML identity provider settings")
+
+	// ErrInvalidLDAPSettings is returned when an LDAP connection lacks a usable directory URL, search base or filter
+	ErrInvalidLDAPSettings = errors.New("invalid LDAP directory settings")
+
+	// ErrMissingEmail is returned when the upstream provider did not assert an email address
+	ErrMissingEmail = errors.New("upstream identity has no email address")
+
+	// ErrEmailDomainMismatch is returned when the asserted email is outside the connection's domains
+	ErrEmailDomainMismatch = errors.New("email domain is not routed to this connection")
+
+	// ErrUserMismatch is returned when a linked identity points at a user of another tenant
+	ErrUserMismatch = errors.New("linked user does not belong to the connection's tenant")
+
+	// ErrLoginStateNotFound is returned when an enterprise login state is unknown, expired or already used
+	ErrLoginStateNotFound = errors.New("login state not found or expired")
+
+	// ErrAssertionReplayed is returned when a SAML assertion was already used to sign in
+	ErrAssertionReplayed = errors.New("assertion was already used")
+)
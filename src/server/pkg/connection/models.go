@@ -0,0 +1,445 @@
+package connection
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Connection types
+const (
+	TypeOIDC = "oidc"
+	TypeSAML = "saml"
+	TypeLDAP = "ldap"
+)
+
+// SAML bindings for sending AuthnRequests to the identity provider
+const (
+	BindingRedirect = "redirect"
+	BindingPOST     = "post"
+)
+
+// DefaultLDAPUserFilter finds the directory entry of the username a user signs in with
+// {username} is replaced by the RFC 4515 escaped username
+const DefaultLDAPUserFilter = "(mail={username})"
+
+// DefaultLDAPGroupAttribute lists the groups of a directory entry (Active Directory, OpenLDAP memberof overlay)
+const DefaultLDAPGroupAttribute = "memberOf"
+
+// LDAPAttributeMapping fills the user profile from standard directory attributes
+var LDAPAttributeMapping = AttributeMapping{
+	Email:      "mail",
+	Name:       "displayName",
+	GivenName:  "givenName",
+	FamilyName: "sn",
+}
+
+// DefaultScopes are requested from upstream OIDC providers when a connection sets none
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// Connection is a tenant's enterprise identity provider
+// Users whose email domain matches one of its domains are sent to it at login (home-realm discovery)
+// LDAP connections verify passwords entered on the Authway login page instead; without domains they
+// serve every user of the tenant
+type Connection struct {
+	ID                 uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID           uuid.UUID        `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_connections_tenant_name"`
+	Name               string           `json:"name" gorm:"not null;uniqueIndex:idx_connections_tenant_name"`
+	Type               string           `json:"type" gorm:"not null"`
+	Enabled            bool             `json:"enabled" gorm:"not null"`
+	Issuer             string           `json:"issuer,omitempty"`
+	ClientID           string           `json:"client_id,omitempty"`
+	ClientSecret       string           `json:"-"`
+	Scopes             pq.StringArray   `json:"scopes" gorm:"type:text[]"`
+	IdPEntityID        string           `json:"idp_entity_id,omitempty" gorm:"column:idp_entity_id"`
+	IdPSSOURL          string           `json:"idp_sso_url,omitempty" gorm:"column:idp_sso_url"`
+	IdPSSOBinding      string           `json:"idp_sso_binding,omitempty" gorm:"column:idp_sso_binding"`
+	IdPCertificate     string           `json:"idp_certificate,omitempty" gorm:"column:idp_certificate"`
+	IdPMetadataURL     string           `json:"idp_metadata_url,omitempty" gorm:"column:idp_metadata_url"`
+	LDAPURL            string           `json:"ldap_url,omitempty" gorm:"column:ldap_url"`
+	LDAPStartTLS       bool             `json:"ldap_start_tls,omitempty" gorm:"column:ldap_start_tls;not null;default:false"`
+	LDAPCACertificate  string           `json:"ldap_ca_certificate,omitempty" gorm:"column:ldap_ca_certificate"`
+	LDAPBindDN         string           `json:"ldap_bind_dn,omitempty" gorm:"column:ldap_bind_dn"`
+	LDAPBindPassword   string           `json:"-" gorm:"column:ldap_bind_password"`
+	LDAPSearchBase     string           `json:"ldap_search_base,omitempty" gorm:"column:ldap_search_base"`
+	LDAPUserFilter     string           `json:"ldap_user_filter,omitempty" gorm:"column:ldap_user_filter"`
+	LDAPGroupAttribute string           `json:"ldap_group_attribute,omitempty" gorm:"column:ldap_group_attribute"`
+	GroupRoles         GroupRoleMapping `json:"group_roles,omitempty" gorm:"type:jsonb"`
+	AttributeMapping   AttributeMapping `json:"attribute_mapping" gorm:"type:jsonb"`
+	Domains            []string         `json:"domains" gorm:"-"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for Connection model
+func (Connection) TableName() string {
+	return "connections"
+}
+
+// BeforeCreate sets UUID if not provided
+func (c *Connection) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// RequestedScopes returns the scopes to request from an upstream OIDC provider
+func (c *Connection) RequestedScopes() []string {
+	if len(c.Scopes) == 0 {
+		return DefaultScopes
+	}
+	return c.Scopes
+}
+
+// SigningCertificate parses the SAML IdP signing certificate, stored as PEM or base64 DER
+func (c *Connection) SigningCertificate() (*x509.Certificate, error) {
+	return ParseCertificate(c.IdPCertificate)
+}
+
+// ParseCertificate parses an X.509 certificate given as PEM or as the base64 DER of SAML metadata
+func ParseCertificate(value string) (*x509.Certificate, error) {
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		return x509.ParseCertificate(block.Bytes)
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, errors.New("certificate is neither PEM nor base64 DER")
+	}
+	return x509.ParseCertificate(der)
+}
+
+// LDAPTLSConfig returns the TLS settings for LDAPS and StartTLS
+// A configured CA certificate replaces the system roots, for directories with a private CA
+func (c *Connection) LDAPTLSConfig(serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if strings.TrimSpace(c.LDAPCACertificate) != "" {
+		cert, err := ParseCertificate(c.LDAPCACertificate)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		config.RootCAs.AddCert(cert)
+	}
+	return config, nil
+}
+
+// UserFilter returns the LDAP search filter for the username a user signs in with
+func (c *Connection) UserFilter(username string) string {
+	filter := c.LDAPUserFilter
+	if filter == "" {
+		filter = DefaultLDAPUserFilter
+	}
+	return strings.ReplaceAll(filter, "{username}", EscapeFilterValue(username))
+}
+
+// GroupAttribute returns the directory attribute listing the groups of a user
+func (c *Connection) GroupAttribute() string {
+	if c.LDAPGroupAttribute == "" {
+		return DefaultLDAPGroupAttribute
+	}
+	return c.LDAPGroupAttribute
+}
+
+// EscapeFilterValue escapes a value for an LDAP search filter (RFC 4515)
+func EscapeFilterValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; ch {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// HasDomain reports whether email belongs to one of the connection's domains
+func (c *Connection) HasDomain(email string) bool {
+	domain := emailDomain(email)
+	for _, d := range c.Domains {
+		if domain != "" && d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// Domain routes users with an email at the domain to a connection
+// A domain belongs to at most one connection per tenant
+type Domain struct {
+	ConnectionID uuid.UUID `json:"connection_id" gorm:"type:uuid;not null;index"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	Domain       string    `json:"domain" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Domain model
+func (Domain) TableName() string {
+	return "connection_domains"
+}
+
+// Identity links an account at the upstream provider to the user it signs in as
+type Identity struct {
+	ConnectionID uuid.UUID `json:"connection_id" gorm:"type:uuid;primaryKey"`
+	Subject      string    `json:"subject" gorm:"primaryKey"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	TenantID     uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Identity model
+func (Identity) TableName() string {
+	return "sso_identities"
+}
+
+// AttributeMapping names the upstream claims (OIDC) or attributes (SAML) that fill the user profile
+// Empty fields fall back to the standard OIDC claim names
+type AttributeMapping struct {
+	Email      string `json:"email,omitempty"`
+	Name       string `json:"name,omitempty"`
+	GivenName  string `json:"given_name,omitempty"`
+	FamilyName string `json:"family_name,omitempty"`
+	Picture    string `json:"picture,omitempty"`
+}
+
+// Scan implements sql.Scanner for AttributeMapping (JSONB support)
+func (m *AttributeMapping) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for AttributeMapping (JSONB support)
+func (m AttributeMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// Profile maps upstream attributes to the user profile
+// Multi-valued attributes contribute their first value
+func (m AttributeMapping) Profile(subject string, attributes map[string]interface{}) *Profile {
+	profile := &Profile{
+		Subject: subject,
+		Email:   strings.TrimSpace(attribute(attributes, m.Email, "email")),
+		Name:    strings.TrimSpace(attribute(attributes, m.Name, "name")),
+		Picture: attribute(attributes, m.Picture, "picture"),
+	}
+	if profile.Name == "" {
+		given := attribute(attributes, m.GivenName, "given_name")
+		family := attribute(attributes, m.FamilyName, "family_name")
+		profile.Name = strings.TrimSpace(given + " " + family)
+	}
+	return profile
+}
+
+func attribute(attributes map[string]interface{}, name, fallback string) string {
+	if name == "" {
+		name = fallback
+	}
+	switch v := attributes[name].(type) {
+	case string:
+		return v
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			if s, ok := v[0].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// GroupRoleMapping maps upstream groups to the tenant roles their members hold
+// Keys match a group's distinguished name or its common name, case-insensitively
+type GroupRoleMapping map[string][]string
+
+// Scan implements sql.Scanner for GroupRoleMapping (JSONB support)
+func (m *GroupRoleMapping) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for GroupRoleMapping (JSONB support)
+func (m GroupRoleMapping) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+// Roles returns the role names mapped to the given groups, and every role name the mapping manages
+// Roles that are managed but not granted are revoked when the user signs in
+func (m GroupRoleMapping) Roles(groups []string) (granted, managed []string) {
+	member := make(map[string]bool, len(groups)*2)
+	for _, group := range groups {
+		member[strings.ToLower(group)] = true
+		if cn := commonName(group); cn != "" {
+			member[strings.ToLower(cn)] = true
+		}
+	}
+
+	grantedSet := map[string]bool{}
+	managedSet := map[string]bool{}
+	for group, roles := range m {
+		for _, role := range roles {
+			if !managedSet[role] {
+				managedSet[role] = true
+				managed = append(managed, role)
+			}
+			if member[strings.ToLower(group)] && !grantedSet[role] {
+				grantedSet[role] = true
+				granted = append(granted, role)
+			}
+		}
+	}
+	sort.Strings(granted)
+	sort.Strings(managed)
+	return granted, managed
+}
+
+// commonName returns the value of a distinguished name's leading CN, "" when it has none
+func commonName(dn string) string {
+	rdn := dn
+	for i := 0; i < len(dn); i++ {
+		if dn[i] == '\\' {
+			i++
+			continue
+		}
+		if dn[i] == ',' {
+			rdn = dn[:i]
+			break
+		}
+	}
+	name, value, ok := strings.Cut(rdn, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(name), "cn") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+// Profile is an authenticated upstream user after attribute mapping
+type Profile struct {
+	Subject string
+	Email   string
+	Name    string
+	Picture string
+}
+
+// emailDomain returns the lower-cased domain part of an email address
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return normalizeDomain(email[at+1:])
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
+
+// CreateConnectionRequest represents the request to add an enterprise connection to a tenant
+type CreateConnectionRequest struct {
+	TenantID           string           `json:"tenant_id" validate:"required,uuid"`
+	Name               string           `json:"name" validate:"required,min=2,max=255"`
+	Type               string           `json:"type" validate:"required,oneof=oidc saml ldap"`
+	Enabled            *bool            `json:"enabled"`
+	Domains            []string         `json:"domains" validate:"max=50,dive,fqdn"`
+	Issuer             string           `json:"issuer" validate:"required_if=Type oidc,omitempty,url"`
+	ClientID           string           `json:"client_id" validate:"required_if=Type oidc,max=255"`
+	ClientSecret       string           `json:"client_secret" validate:"max=1024"`
+	Scopes             []string         `json:"scopes" validate:"omitempty,dive,required"`
+	IdPEntityID        string           `json:"idp_entity_id" validate:"max=1024"`
+	IdPSSOURL          string           `json:"idp_sso_url" validate:"omitempty,url"`
+	IdPSSOBinding      string           `json:"idp_sso_binding" validate:"omitempty,oneof=redirect post"`
+	IdPCertificate     string           `json:"idp_certificate"`
+	IdPMetadataURL     string           `json:"idp_metadata_url" validate:"omitempty,url"`
+	IdPMetadataXML     string           `json:"idp_metadata_xml"`
+	LDAPURL            string           `json:"ldap_url" validate:"required_if=Type ldap,omitempty,url"`
+	LDAPStartTLS       bool             `json:"ldap_start_tls"`
+	LDAPCACertificate  string           `json:"ldap_ca_certificate"`
+	LDAPBindDN         string           `json:"ldap_bind_dn" validate:"max=1024"`
+	LDAPBindPassword   string           `json:"ldap_bind_password" validate:"max=1024"`
+	LDAPSearchBase     string           `json:"ldap_search_base" validate:"required_if=Type ldap,max=1024"`
+	LDAPUserFilter     string           `json:"ldap_user_filter" validate:"max=1024"`
+	LDAPGroupAttribute string           `json:"ldap_group_attribute" validate:"max=255"`
+	GroupRoles         GroupRoleMapping `json:"group_roles"`
+	AttributeMapping   AttributeMapping `json:"attribute_mapping"`
+}
+
+// UpdateConnectionRequest represents the request to update an enterprise connection
+// Omitted fields keep their value; domains, when present, replace the current list
+type UpdateConnectionRequest struct {
+	Name               string            `json:"name" validate:"omitempty,min=2,max=255"`
+	Enabled            *bool             `json:"enabled"`
+	Domains            *[]string         `json:"domains" validate:"omitempty,max=50,dive,fqdn"`
+	Issuer             *string           `json:"issuer" validate:"omitempty,url"`
+	ClientID           *string           `json:"client_id" validate:"omitempty,max=255"`
+	ClientSecret       *string           `json:"client_secret" validate:"omitempty,max=1024"`
+	Scopes             []string          `json:"scopes" validate:"omitempty,dive,required"`
+	IdPEntityID        *string           `json:"idp_entity_id" validate:"omitempty,max=1024"`
+	IdPSSOURL          *string           `json:"idp_sso_url" validate:"omitempty,url"`
+	IdPSSOBinding      *string           `json:"idp_sso_binding" validate:"omitempty,oneof=redirect post"`
+	IdPCertificate     *string           `json:"idp_certificate"`
+	LDAPURL            *string           `json:"ldap_url" validate:"omitempty,url"`
+	LDAPStartTLS       *bool             `json:"ldap_start_tls"`
+	LDAPCACertificate  *string           `json:"ldap_ca_certificate"`
+	LDAPBindDN         *string           `json:"ldap_bind_dn" validate:"omitempty,max=1024"`
+	LDAPBindPassword   *string           `json:"ldap_bind_password" validate:"omitempty,max=1024"`
+	LDAPSearchBase     *string           `json:"ldap_search_base" validate:"omitempty,max=1024"`
+	LDAPUserFilter     *string           `json:"ldap_user_filter" validate:"omitempty,max=1024"`
+	LDAPGroupAttribute *string           `json:"ldap_group_attribute" validate:"omitempty,max=255"`
+	GroupRoles         *GroupRoleMapping `json:"group_roles"`
+	AttributeMapping   *AttributeMapping `json:"attribute_mapping"`
+}
+
+// ImportMetadataRequest represents the request to configure a SAML connection from IdP metadata
+// Exactly one of the XML document or the URL to fetch it from is required
+type ImportMetadataRequest struct {
+	MetadataXML string `json:"metadata_xml" validate:"required_without=MetadataURL"`
+	MetadataURL string `json:"metadata_url" validate:"required_without=MetadataXML,omitempty,url"`
+}
+
+// IdPMetadata is the SAML identity provider configuration read from its metadata
+type IdPMetadata struct {
+	EntityID    string
+	SSOURL      string
+	SSOBinding  string
+	Certificate string
+}
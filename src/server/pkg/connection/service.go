@@ -0,0 +1,544 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateConnectionRequest) (*Connection, error)
+	Get(id uuid.UUID) (*Connection, error)
+	List(tenantID uuid.UUID) ([]*Connection, error)
+	Update(id uuid.UUID, req *UpdateConnectionRequest) (*Connection, error)
+	Delete(id uuid.UUID) error
+	ApplyMetadata(id uuid.UUID, metadata *IdPMetadata, metadataURL string) (*Connection, error)
+
+	Discover(tenantID uuid.UUID, email string) (*Connection, error)
+	Directory(tenantID uuid.UUID, email string) (*Connection, error)
+	Provision(conn *Connection, profile *Profile) (*user.User, error)
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateConnectionRequest) (*Connection, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkNameAvailable(tenantID, uuid.Nil, name); err != nil {
+		return nil, err
+	}
+
+	conn := &Connection{
+		TenantID:           tenantID,
+		Name:               name,
+		Type:               req.Type,
+		Enabled:            req.Enabled == nil || *req.Enabled,
+		Issuer:             strings.TrimSpace(req.Issuer),
+		ClientID:           strings.TrimSpace(req.ClientID),
+		ClientSecret:       req.ClientSecret,
+		Scopes:             req.Scopes,
+		IdPEntityID:        strings.TrimSpace(req.IdPEntityID),
+		IdPSSOURL:          strings.TrimSpace(req.IdPSSOURL),
+		IdPSSOBinding:      req.IdPSSOBinding,
+		IdPCertificate:     strings.TrimSpace(req.IdPCertificate),
+		IdPMetadataURL:     req.IdPMetadataURL,
+		LDAPURL:            strings.TrimSpace(req.LDAPURL),
+		LDAPStartTLS:       req.LDAPStartTLS,
+		LDAPCACertificate:  strings.TrimSpace(req.LDAPCACertificate),
+		LDAPBindDN:         strings.TrimSpace(req.LDAPBindDN),
+		LDAPBindPassword:   req.LDAPBindPassword,
+		LDAPSearchBase:     strings.TrimSpace(req.LDAPSearchBase),
+		LDAPUserFilter:     strings.TrimSpace(req.LDAPUserFilter),
+		LDAPGroupAttribute: strings.TrimSpace(req.LDAPGroupAttribute),
+		GroupRoles:         req.GroupRoles,
+		AttributeMapping:   req.AttributeMapping,
+	}
+	if err := validateSAML(conn); err != nil {
+		return nil, err
+	}
+	if err := validateLDAP(conn); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conn).Error; err != nil {
+			return fmt.Errorf("failed to create connection: %w", err)
+		}
+		return s.replaceDomains(tx, conn, req.Domains)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrDomainTaken) {
+			s.logger.Error("Failed to create connection", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Connection created",
+		zap.String("connection_id", conn.ID.String()),
+		zap.String("type", conn.Type),
+		zap.String("tenant_id", tenantID.String()))
+	return s.Get(conn.ID)
+}
+
+func (s *service) Get(id uuid.UUID) (*Connection, error) {
+	var conn Connection
+	if err := s.db.Where("id = ?", id).First(&conn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	if err := s.loadDomains(&conn); err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+func (s *service) List(tenantID uuid.UUID) ([]*Connection, error) {
+	var conns []*Connection
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("name").Find(&conns).Error; err != nil {
+		return nil, fmt.Errorf("failed to list connections: %w", err)
+	}
+	for _, conn := range conns {
+		if err := s.loadDomains(conn); err != nil {
+			return nil, err
+		}
+	}
+	return conns, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateConnectionRequest) (*Connection, error) {
+	conn, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" && name != conn.Name {
+		if err := s.checkNameAvailable(conn.TenantID, conn.ID, name); err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Issuer != nil {
+		updates["issuer"] = strings.TrimSpace(*req.Issuer)
+	}
+	if req.ClientID != nil {
+		updates["client_id"] = strings.TrimSpace(*req.ClientID)
+	}
+	if req.ClientSecret != nil {
+		updates["client_secret"] = *req.ClientSecret
+	}
+	if req.Scopes != nil {
+		updates["scopes"] = pq.StringArray(req.Scopes)
+	}
+	if req.AttributeMapping != nil {
+		updates["attribute_mapping"] = *req.AttributeMapping
+	}
+	if req.IdPEntityID != nil {
+		conn.IdPEntityID = strings.TrimSpace(*req.IdPEntityID)
+		updates["idp_entity_id"] = conn.IdPEntityID
+	}
+	if req.IdPSSOURL != nil {
+		conn.IdPSSOURL = strings.TrimSpace(*req.IdPSSOURL)
+		updates["idp_sso_url"] = conn.IdPSSOURL
+	}
+	if req.IdPSSOBinding != nil {
+		conn.IdPSSOBinding = *req.IdPSSOBinding
+		updates["idp_sso_binding"] = conn.IdPSSOBinding
+	}
+	if req.IdPCertificate != nil {
+		conn.IdPCertificate = strings.TrimSpace(*req.IdPCertificate)
+		updates["idp_certificate"] = conn.IdPCertificate
+	}
+	if req.LDAPURL != nil {
+		conn.LDAPURL = strings.TrimSpace(*req.LDAPURL)
+		updates["ldap_url"] = conn.LDAPURL
+	}
+	if req.LDAPStartTLS != nil {
+		conn.LDAPStartTLS = *req.LDAPStartTLS
+		updates["ldap_start_tls"] = conn.LDAPStartTLS
+	}
+	if req.LDAPCACertificate != nil {
+		conn.LDAPCACertificate = strings.TrimSpace(*req.LDAPCACertificate)
+		updates["ldap_ca_certificate"] = conn.LDAPCACertificate
+	}
+	if req.LDAPBindDN != nil {
+		conn.LDAPBindDN = strings.TrimSpace(*req.LDAPBindDN)
+		updates["ldap_bind_dn"] = conn.LDAPBindDN
+	}
+	if req.LDAPBindPassword != nil {
+		updates["ldap_bind_password"] = *req.LDAPBindPassword
+	}
+	if req.LDAPSearchBase != nil {
+		conn.LDAPSearchBase = strings.TrimSpace(*req.LDAPSearchBase)
+		updates["ldap_search_base"] = conn.LDAPSearchBase
+	}
+	if req.LDAPUserFilter != nil {
+		conn.LDAPUserFilter = strings.TrimSpace(*req.LDAPUserFilter)
+		updates["ldap_user_filter"] = conn.LDAPUserFilter
+	}
+	if req.LDAPGroupAttribute != nil {
+		conn.LDAPGroupAttribute = strings.TrimSpace(*req.LDAPGroupAttribute)
+		updates["ldap_group_attribute"] = conn.LDAPGroupAttribute
+	}
+	if req.GroupRoles != nil {
+		updates["group_roles"] = *req.GroupRoles
+	}
+	if err := validateSAML(conn); err != nil {
+		return nil, err
+	}
+	if err := validateLDAP(conn); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(conn).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update connection: %w", err)
+			}
+		}
+		if req.Domains != nil {
+			return s.replaceDomains(tx, conn, *req.Domains)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrDomainTaken) {
+			s.logger.Error("Failed to update connection", zap.Error(err), zap.String("connection_id", id.String()))
+		}
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Delete removes a connection with its domains and identity links; provisioned users remain in the tenant
+func (s *service) Delete(id uuid.UUID) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("connection_id = ?", id).Delete(&Identity{}).Error; err != nil {
+			return fmt.Errorf("failed to delete connection identities: %w", err)
+		}
+		if err := tx.Where("connection_id = ?", id).Delete(&Domain{}).Error; err != nil {
+			return fmt.Errorf("failed to delete connection domains: %w", err)
+		}
+		if err := tx.Where("id = ?", id).Delete(&Connection{}).Error; err != nil {
+			return fmt.Errorf("failed to delete connection: %w", err)
+		}
+		return nil
+	})
+}
+
+// ApplyMetadata configures a SAML connection from its identity provider's metadata
+// metadataURL records where the metadata was fetched from, "" for an uploaded document
+func (s *service) ApplyMetadata(id uuid.UUID, metadata *IdPMetadata, metadataURL string) (*Connection, error) {
+	conn, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if conn.Type != TypeSAML {
+		return nil, fmt.Errorf("%w: not a SAML connection", ErrInvalidSAMLSettings)
+	}
+
+	conn.IdPEntityID = metadata.EntityID
+	conn.IdPSSOURL = metadata.SSOURL
+	conn.IdPSSOBinding = metadata.SSOBinding
+	conn.IdPCertificate = metadata.Certificate
+	if err := validateSAML(conn); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(conn).Updates(map[string]interface{}{
+		"idp_entity_id":    conn.IdPEntityID,
+		"idp_sso_url":      conn.IdPSSOURL,
+		"idp_sso_binding":  conn.IdPSSOBinding,
+		"idp_certificate":  conn.IdPCertificate,
+		"idp_metadata_url": metadataURL,
+	}).Error; err != nil {
+		s.logger.Error("Failed to apply IdP metadata", zap.Error(err), zap.String("connection_id", id.String()))
+		return nil, fmt.Errorf("failed to update connection: %w", err)
+	}
+
+	return s.Get(id)
+}
+
+// Discover returns the enabled redirect connection (OIDC or SAML) the tenant routes the email's domain to
+// (home-realm discovery); ErrNotFound means the user signs in on the Authway login page
+func (s *service) Discover(tenantID uuid.UUID, email string) (*Connection, error) {
+	return s.byDomain(tenantID, email, []string{TypeOIDC, TypeSAML})
+}
+
+// Directory returns the enabled LDAP connection that verifies the password of a user signing in with email
+// A connection routed the email's domain wins over one without domains, which serves the whole tenant
+// ErrNotFound means the password is checked against the Authway user
+func (s *service) Directory(tenantID uuid.UUID, email string) (*Connection, error) {
+	conn, err := s.byDomain(tenantID, email, []string{TypeLDAP})
+	if !errors.Is(err, ErrNotFound) {
+		return conn, err
+	}
+
+	var directory Connection
+	err = s.db.Where("tenant_id = ? AND type = ? AND enabled = ?", tenantID, TypeLDAP, true).
+		Where("NOT EXISTS (SELECT 1 FROM connection_domains WHERE connection_domains.connection_id = connections.id)").
+		Order("name").
+		First(&directory).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find directory connection: %w", err)
+	}
+	directory.Domains = []string{}
+	return &directory, nil
+}
+
+func (s *service) byDomain(tenantID uuid.UUID, email string, types []string) (*Connection, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, ErrNotFound
+	}
+
+	var conn Connection
+	err := s.db.Table("connections").
+		Joins("JOIN connection_domains ON connection_domains.connection_id = connections.id").
+		Where("connection_domains.tenant_id = ? AND connection_domains.domain = ? AND connections.enabled = ?", tenantID, domain, true).
+		Where("connections.type IN ?", types).
+		Select("connections.*").
+		First(&conn).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to discover connection: %w", err)
+	}
+	if err := s.loadDomains(&conn); err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// Provision returns the user an upstream identity signs in as, creating it just in time
+// A new identity links to an existing tenant user with the same email, so the email must be
+// at one of the connection's domains; the provider is trusted to have verified it
+// An LDAP connection without domains is the tenant's user directory and may assert any email
+func (s *service) Provision(conn *Connection, profile *Profile) (*user.User, error) {
+	var identity Identity
+	err := s.db.Where("connection_id = ? AND subject = ?", conn.ID, profile.Subject).First(&identity).Error
+	if err == nil {
+		var linked user.User
+		if err := s.db.Where("id = ?", identity.UserID).First(&linked).Error; err != nil {
+			return nil, fmt.Errorf("failed to get linked user: %w", err)
+		}
+		if linked.TenantID != conn.TenantID {
+			return nil, ErrUserMismatch
+		}
+		s.syncProfile(&linked, profile)
+		return &linked, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	if profile.Email == "" {
+		return nil, ErrMissingEmail
+	}
+	if !conn.HasDomain(profile.Email) && !(conn.Type == TypeLDAP && len(conn.Domains) == 0) {
+		return nil, ErrEmailDomainMismatch
+	}
+
+	var provisioned user.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("tenant_id = ? AND LOWER(email) = ?", conn.TenantID, strings.ToLower(profile.Email)).First(&provisioned).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			provisioned = user.User{
+				TenantID:      conn.TenantID,
+				Email:         profile.Email,
+				EmailVerified: true,
+				Active:        true,
+				Provider:      "sso",
+			}
+			if profile.Name != "" {
+				provisioned.Name = &profile.Name
+			}
+			if profile.Picture != "" {
+				provisioned.Picture = &profile.Picture
+			}
+			if err := tx.Create(&provisioned).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to get user: %w", err)
+		case !provisioned.EmailVerified:
+			if err := tx.Model(&provisioned).Update("email_verified", true).Error; err != nil {
+				return fmt.Errorf("failed to verify user email: %w", err)
+			}
+		}
+
+		return tx.Create(&Identity{
+			ConnectionID: conn.ID,
+			Subject:      profile.Subject,
+			UserID:       provisioned.ID,
+			TenantID:     conn.TenantID,
+		}).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to provision user", zap.Error(err), zap.String("connection_id", conn.ID.String()))
+		return nil, err
+	}
+
+	s.logger.Info("Linked upstream identity",
+		zap.String("connection_id", conn.ID.String()),
+		zap.String("user_id", provisioned.ID.String()),
+		zap.String("tenant_id", conn.TenantID.String()))
+	return &provisioned, nil
+}
+
+// syncProfile refreshes the profile attributes managed by the upstream provider
+func (s *service) syncProfile(u *user.User, profile *Profile) {
+	updates := map[string]interface{}{}
+	if profile.Name != "" && (u.Name == nil || *u.Name != profile.Name) {
+		updates["name"] = profile.Name
+		u.Name = &profile.Name
+	}
+	if profile.Picture != "" && (u.Picture == nil || *u.Picture != profile.Picture) {
+		updates["picture"] = profile.Picture
+		u.Picture = &profile.Picture
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if err := s.db.Model(&user.User{}).Where("id = ?", u.ID).Updates(updates).Error; err != nil {
+		s.logger.Warn("Failed to sync upstream profile", zap.Error(err), zap.String("user_id", u.ID.String()))
+	}
+}
+
+// replaceDomains routes exactly the given domains to conn
+func (s *service) replaceDomains(tx *gorm.DB, conn *Connection, domains []string) error {
+	if err := tx.Where("connection_id = ?", conn.ID).Delete(&Domain{}).Error; err != nil {
+		return fmt.Errorf("failed to replace connection domains: %w", err)
+	}
+
+	seen := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if domain == "" || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+
+		var taken int64
+		if err := tx.Model(&Domain{}).
+			Where("tenant_id = ? AND domain = ?", conn.TenantID, domain).
+			Count(&taken).Error; err != nil {
+			return fmt.Errorf("failed to check domain: %w", err)
+		}
+		if taken > 0 {
+			return fmt.Errorf("%w: %s", ErrDomainTaken, domain)
+		}
+
+		if err := tx.Create(&Domain{ConnectionID: conn.ID, TenantID: conn.TenantID, Domain: domain}).Error; err != nil {
+			return fmt.Errorf("failed to add connection domain: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *service) loadDomains(conn *Connection) error {
+	var domains []string
+	if err := s.db.Model(&Domain{}).
+		Where("connection_id = ?", conn.ID).
+		Order("domain").
+		Pluck("domain", &domains).Error; err != nil {
+		return fmt.Errorf("failed to load connection domains: %w", err)
+	}
+	if domains == nil {
+		domains = []string{}
+	}
+	conn.Domains = domains
+	return nil
+}
+
+// validateSAML checks that a SAML connection can send AuthnRequests and verify assertions
+func validateSAML(conn *Connection) error {
+	if conn.Type != TypeSAML {
+		return nil
+	}
+	if conn.IdPSSOBinding == "" {
+		conn.IdPSSOBinding = BindingRedirect
+	}
+	if conn.IdPEntityID == "" || conn.IdPSSOURL == "" {
+		return fmt.Errorf("%w: idp_entity_id and idp_sso_url are required", ErrInvalidSAMLSettings)
+	}
+	if _, err := conn.SigningCertificate(); err != nil {
+		return fmt.Errorf("%w: idp_certificate: %v", ErrInvalidSAMLSettings, err)
+	}
+	return nil
+}
+
+// validateLDAP checks that an LDAP connection can reach the directory and find users in it
+func validateLDAP(conn *Connection) error {
+	if conn.Type != TypeLDAP {
+		return nil
+	}
+	if conn.LDAPURL == "" || conn.LDAPSearchBase == "" {
+		return fmt.Errorf("%w: ldap_url and ldap_search_base are required", ErrInvalidLDAPSettings)
+	}
+	u, err := url.Parse(conn.LDAPURL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Hostname() == "" {
+		return fmt.Errorf("%w: ldap_url must be an ldap:// or ldaps:// URL", ErrInvalidLDAPSettings)
+	}
+	if u.Scheme == "ldaps" && conn.LDAPStartTLS {
+		return fmt.Errorf("%w: ldap_start_tls applies to ldap:// URLs only", ErrInvalidLDAPSettings)
+	}
+	if conn.LDAPCACertificate != "" {
+		if _, err := ParseCertificate(conn.LDAPCACertificate); err != nil {
+			return fmt.Errorf("%w: ldap_ca_certificate: %v", ErrInvalidLDAPSettings, err)
+		}
+	}
+	if conn.LDAPUserFilter != "" {
+		if !strings.Contains(conn.LDAPUserFilter, "{username}") {
+			return fmt.Errorf("%w: ldap_user_filter must contain {username}", ErrInvalidLDAPSettings)
+		}
+		if !strings.HasPrefix(conn.LDAPUserFilter, "(") || !strings.HasSuffix(conn.LDAPUserFilter, ")") {
+			return fmt.Errorf("%w: ldap_user_filter must be enclosed in parentheses", ErrInvalidLDAPSettings)
+		}
+	}
+	return nil
+}
+
+func (s *service) checkNameAvailable(tenantID, excludeID uuid.UUID, name string) error {
+	var count int64
+	if err := s.db.Model(&Connection{}).
+		Where("tenant_id = ? AND name = ? AND id <> ?", tenantID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check connection name: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateName
+	}
+	return nil
+}
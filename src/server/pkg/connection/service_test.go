@@ -0,0 +1,262 @@
+package connection
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestService(t *testing.T) (*service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&user.User{}, &Connection{}, &Domain{}, &Identity{})
+	require.NoError(t, err)
+
+	return NewService(db, zap.NewNop()).(*service), db
+}
+
+func oidcRequest(name string, domains ...string) *CreateConnectionRequest {
+	return &CreateConnectionRequest{
+		Name:     name,
+		Type:     TypeOIDC,
+		Domains:  domains,
+		Issuer:   "https://idp.example.com",
+		ClientID: "authway",
+	}
+}
+
+func TestService_CreateAndDiscover(t *testing.T) {
+	service, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	conn, err := service.Create(tenantID, oidcRequest("Acme AD", "Acme.com.", "acme.io", "acme.com"))
+	require.NoError(t, err)
+	assert.True(t, conn.Enabled)
+	assert.Equal(t, []string{"acme.com", "acme.io"}, conn.Domains)
+	assert.Equal(t, DefaultScopes, conn.RequestedScopes())
+
+	_, err = service.Create(tenantID, oidcRequest("Acme AD"))
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	_, err = service.Create(tenantID, oidcRequest("Other", "acme.io"))
+	assert.ErrorIs(t, err, ErrDomainTaken)
+
+	// Another tenant may route the same domain to its own connection
+	_, err = service.Create(uuid.New(), oidcRequest("Acme AD", "acme.com"))
+	require.NoError(t, err)
+
+	found, err := service.Discover(tenantID, "Alice@ACME.com")
+	require.NoError(t, err)
+	assert.Equal(t, conn.ID, found.ID)
+
+	_, err = service.Discover(tenantID, "alice@example.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = service.Discover(uuid.New(), "alice@acme.io")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Disabled connections are skipped; updated domains replace the old ones
+	disabled := false
+	domains := []string{"acme.io"}
+	updated, err := service.Update(conn.ID, &UpdateConnectionRequest{Enabled: &disabled, Domains: &domains})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme.io"}, updated.Domains)
+	_, err = service.Discover(tenantID, "alice@acme.io")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_Provision(t *testing.T) {
+	service, db := setupTestService(t)
+	tenantID := uuid.New()
+
+	conn, err := service.Create(tenantID, oidcRequest("Acme AD", "acme.com"))
+	require.NoError(t, err)
+
+	existing := &user.User{TenantID: tenantID, Email: "bob@acme.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(existing).Error)
+
+	// A new email is provisioned just in time with a verified address
+	alice, err := service.Provision(conn, &Profile{Subject: "idp-alice", Email: "alice@acme.com", Name: "Alice"})
+	require.NoError(t, err)
+	assert.Equal(t, tenantID, alice.TenantID)
+	assert.True(t, alice.EmailVerified)
+	assert.Equal(t, "sso", alice.Provider)
+
+	// The identity stays linked and keeps the profile in sync
+	again, err := service.Provision(conn, &Profile{Subject: "idp-alice", Email: "alice@acme.com", Name: "Alice Smith"})
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, again.ID)
+	assert.Equal(t, "Alice Smith", *again.Name)
+
+	// An existing tenant user is linked by email
+	bob, err := service.Provision(conn, &Profile{Subject: "idp-bob", Email: "Bob@acme.com"})
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, bob.ID)
+	assert.True(t, bob.EmailVerified)
+
+	// Emails outside the connection's domains are rejected
+	_, err = service.Provision(conn, &Profile{Subject: "idp-eve", Email: "eve@example.com"})
+	assert.ErrorIs(t, err, ErrEmailDomainMismatch)
+	_, err = service.Provision(conn, &Profile{Subject: "idp-nobody"})
+	assert.ErrorIs(t, err, ErrMissingEmail)
+}
+
+func TestAttributeMapping_Profile(t *testing.T) {
+	attributes := map[string]interface{}{
+		"sub":       "123",
+		"mail":      []interface{}{"alice@acme.com", "a@acme.com"},
+		"givenName": "Alice",
+		"sn":        []string{"Smith"},
+	}
+
+	mapping := AttributeMapping{Email: "mail", GivenName: "givenName", FamilyName: "sn"}
+	profile := mapping.Profile("123", attributes)
+	assert.Equal(t, "alice@acme.com", profile.Email)
+	assert.Equal(t, "Alice Smith", profile.Name)
+
+	profile = AttributeMapping{}.Profile("123", map[string]interface{}{"email": "bob@acme.com", "name": "Bob"})
+	assert.Equal(t, "bob@acme.com", profile.Email)
+	assert.Equal(t, "Bob", profile.Name)
+}
+
+func testCertificatePEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestService_SAMLSettings(t *testing.T) {
+	service, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	_, err := service.Create(tenantID, &CreateConnectionRequest{
+		Name:        "Acme SAML",
+		Type:        TypeSAML,
+		IdPEntityID: "https://idp.acme.com",
+		IdPSSOURL:   "https://idp.acme.com/sso",
+	})
+	assert.ErrorIs(t, err, ErrInvalidSAMLSettings)
+
+	conn, err := service.Create(tenantID, &CreateConnectionRequest{
+		Name:           "Acme SAML",
+		Type:           TypeSAML,
+		IdPEntityID:    "https://idp.acme.com",
+		IdPSSOURL:      "https://idp.acme.com/sso",
+		IdPCertificate: testCertificatePEM(t),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, BindingRedirect, conn.IdPSSOBinding)
+
+	updated, err := service.ApplyMetadata(conn.ID, &IdPMetadata{
+		EntityID:    "https://idp.acme.com/v2",
+		SSOURL:      "https://idp.acme.com/sso/post",
+		SSOBinding:  BindingPOST,
+		Certificate: testCertificatePEM(t),
+	}, "https://idp.acme.com/metadata")
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.acme.com/v2", updated.IdPEntityID)
+	assert.Equal(t, BindingPOST, updated.IdPSSOBinding)
+	assert.Equal(t, "https://idp.acme.com/metadata", updated.IdPMetadataURL)
+
+	oidc, err := service.Create(tenantID, oidcRequest("Acme OIDC"))
+	require.NoError(t, err)
+	_, err = service.ApplyMetadata(oidc.ID, &IdPMetadata{}, "")
+	assert.ErrorIs(t, err, ErrInvalidSAMLSettings)
+}
+
+func ldapRequest(name string, domains ...string) *CreateConnectionRequest {
+	return &CreateConnectionRequest{
+		Name:           name,
+		Type:           TypeLDAP,
+		Domains:        domains,
+		LDAPURL:        "ldap://dc1.acme.com",
+		LDAPStartTLS:   true,
+		LDAPSearchBase: "dc=acme,dc=com",
+	}
+}
+
+func TestService_LDAPDirectory(t *testing.T) {
+	service, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	invalid := ldapRequest("Bad")
+	invalid.LDAPURL = "https://dc1.acme.com"
+	_, err := service.Create(tenantID, invalid)
+	assert.ErrorIs(t, err, ErrInvalidLDAPSettings)
+	invalid = ldapRequest("Bad")
+	invalid.LDAPUserFilter = "(uid=alice)"
+	_, err = service.Create(tenantID, invalid)
+	assert.ErrorIs(t, err, ErrInvalidLDAPSettings)
+
+	// Without domains the directory serves every user of the tenant
+	corp, err := service.Create(tenantID, ldapRequest("Corp AD"))
+	require.NoError(t, err)
+	found, err := service.Directory(tenantID, "alice@anywhere.com")
+	require.NoError(t, err)
+	assert.Equal(t, corp.ID, found.ID)
+
+	// A directory routed the email's domain takes precedence
+	subsidiary, err := service.Create(tenantID, ldapRequest("Subsidiary AD", "sub.acme.com"))
+	require.NoError(t, err)
+	found, err = service.Directory(tenantID, "bob@sub.acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, subsidiary.ID, found.ID)
+
+	// LDAP connections take part in password login, not in home-realm discovery
+	_, err = service.Discover(tenantID, "bob@sub.acme.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = service.Directory(uuid.New(), "alice@anywhere.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// The tenant-wide directory provisions any email; a routed one only its domains
+	alice, err := service.Provision(corp, &Profile{Subject: "uuid-alice", Email: "alice@anywhere.com"})
+	require.NoError(t, err)
+	assert.Equal(t, tenantID, alice.TenantID)
+	_, err = service.Provision(subsidiary, &Profile{Subject: "uuid-eve", Email: "eve@anywhere.com"})
+	assert.ErrorIs(t, err, ErrEmailDomainMismatch)
+
+	disabled := false
+	_, err = service.Update(corp.ID, &UpdateConnectionRequest{Enabled: &disabled})
+	require.NoError(t, err)
+	_, err = service.Directory(tenantID, "alice@anywhere.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestConnection_UserFilter(t *testing.T) {
+	conn := &Connection{Type: TypeLDAP}
+	assert.Equal(t, `(mail=alice@acme.com)`, conn.UserFilter("alice@acme.com"))
+	assert.Equal(t, `(mail=\2a\29\28uid=\2a\5c\00)`, conn.UserFilter("*)(uid=*\\\x00"))
+
+	conn.LDAPUserFilter = "(|(sAMAccountName={username})(userPrincipalName={username}))"
+	assert.Equal(t, "(|(sAMAccountName=alice)(userPrincipalName=alice))", conn.UserFilter("alice"))
+}
+
+func TestGroupRoleMapping_Roles(t *testing.T) {
+	mapping := GroupRoleMapping{
+		"CN=Engineering,OU=Groups,DC=acme,DC=com": {"developer"},
+		"vpn users": {"vpn", "remote"},
+		"Admins":    {"admin", "developer"},
+	}
+
+	granted, managed := mapping.Roles([]string{"cn=engineering,ou=groups,dc=acme,dc=com", "CN=VPN Users,OU=Groups,DC=acme,DC=com"})
+	assert.Equal(t, []string{"developer", "remote", "vpn"}, granted)
+	assert.Equal(t, []string{"admin", "developer", "remote", "vpn"}, managed)
+
+	granted, _ = mapping.Roles(nil)
+	assert.Empty(t, granted)
+}
@@ -0,0 +1,135 @@
+package connection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginStateTTL is how long a user has to sign in at the upstream identity provider
+const LoginStateTTL = 15 * time.Minute
+
+// LoginState is an enterprise login in progress, keyed by the upstream state parameter (OIDC) or RelayState (SAML)
+// Only a hash of the key is stored
+type LoginState struct {
+	KeyHash        string    `json:"-" gorm:"primaryKey"`
+	ConnectionID   uuid.UUID `json:"connection_id" gorm:"type:uuid;not null"`
+	LoginChallenge string    `json:"-" gorm:"not null"`
+	Organization   string    `json:"organization"`
+	Nonce          string    `json:"-"`
+	CodeVerifier   string    `json:"-"`
+	// SAML logins keep the AuthnRequest ID to match InResponseTo and, for the POST binding, the request itself
+	RequestID    string    `json:"-"`
+	AuthnRequest []byte    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for LoginState model
+func (LoginState) TableName() string {
+	return "sso_login_states"
+}
+
+// UsedAssertion records a SAML assertion that was accepted, until it expires
+type UsedAssertion struct {
+	ConnectionID uuid.UUID `json:"connection_id" gorm:"type:uuid;primaryKey"`
+	AssertionID  string    `json:"assertion_id" gorm:"primaryKey"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// TableName specifies the table name for UsedAssertion model
+func (UsedAssertion) TableName() string {
+	return "saml_used_assertions"
+}
+
+// StateStore keeps enterprise logins in progress and used SAML assertions in the database,
+// so the upstream callback can reach any instance and replays are caught across restarts
+type StateStore struct {
+	db *gorm.DB
+}
+
+// NewStateStore creates a new enterprise login state store
+func NewStateStore(db *gorm.DB) *StateStore {
+	return &StateStore{db: db}
+}
+
+// hashStateKey returns the hex-encoded SHA-256 of a state key
+func hashStateKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveLoginState stores the state of a login that continues at the upstream provider for LoginStateTTL
+func (s *StateStore) SaveLoginState(key string, state *LoginState) error {
+	state.KeyHash = hashStateKey(key)
+	state.ExpiresAt = time.Now().Add(LoginStateTTL)
+	if err := s.db.Create(state).Error; err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+	return nil
+}
+
+// GetLoginState retrieves an unexpired login state without using it up
+func (s *StateStore) GetLoginState(key string) (*LoginState, error) {
+	var state LoginState
+	if err := s.db.Where("key_hash = ? AND expires_at > ?", hashStateKey(key), time.Now()).First(&state).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoginStateNotFound
+		}
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+	return &state, nil
+}
+
+// ConsumeLoginState retrieves and deletes an unexpired login state
+// Only one of several concurrent callbacks with the same state gets it
+func (s *StateStore) ConsumeLoginState(key string) (*LoginState, error) {
+	state, err := s.GetLoginState(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.Where("key_hash = ?", state.KeyHash).Delete(&LoginState{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to delete login state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLoginStateNotFound
+	}
+	return state, nil
+}
+
+// MarkAssertionUsed records an accepted SAML assertion of a connection until it expires
+// It returns ErrAssertionReplayed if the assertion was already used
+func (s *StateStore) MarkAssertionUsed(connectionID uuid.UUID, assertionID string, expiresAt time.Time) error {
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&UsedAssertion{
+		ConnectionID: connectionID,
+		AssertionID:  assertionID,
+		ExpiresAt:    expiresAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to record used assertion: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAssertionReplayed
+	}
+	return nil
+}
+
+// CleanupExpired removes expired login states and used assertions (can be run periodically)
+func (s *StateStore) CleanupExpired() error {
+	now := time.Now()
+	if err := s.db.Where("expires_at < ?", now).Delete(&LoginState{}).Error; err != nil {
+		return fmt.Errorf("failed to clean up login states: %w", err)
+	}
+	if err := s.db.Where("expires_at < ?", now).Delete(&UsedAssertion{}).Error; err != nil {
+		return fmt.Errorf("failed to clean up used assertions: %w", err)
+	}
+	return nil
+}
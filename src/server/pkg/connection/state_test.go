@@ -0,0 +1,85 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestStateStore(t *testing.T) (*StateStore, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&LoginState{}, &UsedAssertion{})
+	require.NoError(t, err)
+
+	return NewStateStore(db), db
+}
+
+func TestStateStore_LoginState(t *testing.T) {
+	store, db := setupTestStateStore(t)
+	connectionID := uuid.New()
+
+	require.NoError(t, store.SaveLoginState("state-1", &LoginState{ConnectionID: connectionID, LoginChallenge: "challenge", RequestID: "_req"}))
+
+	var stored LoginState
+	require.NoError(t, db.First(&stored).Error)
+	assert.NotEqual(t, "state-1", stored.KeyHash, "only a hash of the key is stored")
+
+	state, err := store.GetLoginState("state-1")
+	require.NoError(t, err)
+	assert.Equal(t, "challenge", state.LoginChallenge)
+
+	state, err = store.ConsumeLoginState("state-1")
+	require.NoError(t, err)
+	assert.Equal(t, connectionID, state.ConnectionID)
+	assert.Equal(t, "_req", state.RequestID)
+
+	_, err = store.ConsumeLoginState("state-1")
+	assert.ErrorIs(t, err, ErrLoginStateNotFound)
+	_, err = store.GetLoginState("unknown")
+	assert.ErrorIs(t, err, ErrLoginStateNotFound)
+}
+
+func TestStateStore_CleanupExpired(t *testing.T) {
+	store, db := setupTestStateStore(t)
+
+	require.NoError(t, store.SaveLoginState("expired", &LoginState{ConnectionID: uuid.New(), LoginChallenge: "old"}))
+	require.NoError(t, store.SaveLoginState("live", &LoginState{ConnectionID: uuid.New(), LoginChallenge: "new"}))
+	require.NoError(t, db.Model(&LoginState{}).Where("key_hash = ?", hashStateKey("expired")).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	_, err := store.GetLoginState("expired")
+	assert.ErrorIs(t, err, ErrLoginStateNotFound)
+
+	require.NoError(t, store.CleanupExpired())
+
+	var count int64
+	require.NoError(t, db.Model(&LoginState{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	_, err = store.GetLoginState("live")
+	assert.NoError(t, err)
+}
+
+func TestStateStore_MarkAssertionUsed(t *testing.T) {
+	store, db := setupTestStateStore(t)
+	connectionID := uuid.New()
+
+	require.NoError(t, store.MarkAssertionUsed(connectionID, "_a1", time.Now().Add(time.Minute)))
+	assert.ErrorIs(t, store.MarkAssertionUsed(connectionID, "_a1", time.Now().Add(time.Minute)), ErrAssertionReplayed)
+
+	// Assertion IDs are scoped to the connection that issued them
+	assert.NoError(t, store.MarkAssertionUsed(uuid.New(), "_a1", time.Now().Add(time.Minute)))
+
+	require.NoError(t, store.MarkAssertionUsed(connectionID, "_expired", time.Now().Add(-time.Minute)))
+	require.NoError(t, store.CleanupExpired())
+
+	var count int64
+	require.NoError(t, db.Model(&UsedAssertion{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
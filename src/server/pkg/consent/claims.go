@@ -0,0 +1,111 @@
+package consent
+
+import (
+	"authway/src/server/pkg/user"
+)
+
+// Standard scopes understood by the claim mapper
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeTenant        = "tenant"
+	ScopeRoles         = "roles"
+	ScopeGroups        = "groups"
+	ScopeOfflineAccess = "offline_access"
+	ScopeAccount       = "account" // Self-service account API (/api/v1/me); register it on first-party clients only
+)
+
+// DefaultScopeClaims maps the standard scopes to the claims they authorize
+// openid and offline_access add no claims of their own (sub is always set by Hydra)
+var DefaultScopeClaims = map[string][]string{
+	ScopeProfile: {"name", "picture", "updated_at"},
+	ScopeEmail:   {"email", "email_verified"},
+	ScopeTenant:  {"tenant_id", "org_id", "org_role"},
+	ScopeRoles:   {"roles", "permissions"},
+	ScopeGroups:  {"groups"},
+}
+
+// DefaultClaims are issued whatever scopes are granted
+// Clients registered before the tenant scope existed rely on tenant_id being present
+var DefaultClaims = []string{"tenant_id"}
+
+// ClaimMapper decides which user claims go into the tokens for a set of granted scopes
+type ClaimMapper struct {
+	scopeClaims map[string][]string
+}
+
+// NewClaimMapper creates a mapper with the standard scopes plus custom scopes
+// Custom scopes can list any claim produced by UserClaims; they cannot redefine standard scopes
+func NewClaimMapper(customScopeClaims map[string][]string) *ClaimMapper {
+	scopeClaims := make(map[string][]string, len(DefaultScopeClaims)+len(customScopeClaims))
+	for scope, claims := range customScopeClaims {
+		scopeClaims[scope] = claims
+	}
+	for scope, claims := range DefaultScopeClaims {
+		scopeClaims[scope] = claims
+	}
+	return &ClaimMapper{scopeClaims: scopeClaims}
+}
+
+// Claims returns the default claims plus the subset of available claims authorized by the granted scopes
+func (m *ClaimMapper) Claims(grantedScopes []string, available map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{}
+	for _, claim := range DefaultClaims {
+		if value, ok := available[claim]; ok {
+			claims[claim] = value
+		}
+	}
+	for _, scope := range grantedScopes {
+		for _, claim := range m.scopeClaims[scope] {
+			if value, ok := available[claim]; ok {
+				claims[claim] = value
+			}
+		}
+	}
+	return claims
+}
+
+// UserClaims returns every claim Authway can issue for a user
+// Claims that need other services (roles, permissions, groups) are added by the caller
+func UserClaims(u *user.User) map[string]interface{} {
+	claims := map[string]interface{}{
+		"email":          u.Email,
+		"email_verified": u.EmailVerified,
+		"tenant_id":      u.TenantID.String(),
+		"updated_at":     u.UpdatedAt.Unix(),
+	}
+	if u.Name != nil {
+		claims["name"] = *u.Name
+	}
+	if u.Picture != nil && *u.Picture != "" {
+		claims["picture"] = *u.Picture
+	} else if u.AvatarURL != nil && *u.AvatarURL != "" {
+		claims["picture"] = *u.AvatarURL
+	}
+	return claims
+}
+
+// ValidateGrant returns the scopes in granted that were not requested or are not allowed for the client
+// An empty allowed list means the client's scopes are unknown and only the requested scopes are checked
+func ValidateGrant(granted, requested, allowed []string) []string {
+	invalid := MissingScopes(granted, requested)
+	if len(allowed) > 0 {
+		for _, scope := range MissingScopes(granted, allowed) {
+			if !ContainsScope(invalid, scope) {
+				invalid = append(invalid, scope)
+			}
+		}
+	}
+	return invalid
+}
+
+// ContainsScope reports whether scopes includes scope
+func ContainsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
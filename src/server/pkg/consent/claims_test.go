@@ -0,0 +1,102 @@
+package consent
+
+import (
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimMapper_Claims(t *testing.T) {
+	name := "Test User"
+	u := &user.User{
+		ID:            uuid.New(),
+		TenantID:      uuid.New(),
+		Email:         "test@example.com",
+		Name:          &name,
+		EmailVerified: true,
+		UpdatedAt:     time.Unix(1700000000, 0),
+	}
+	mapper := NewClaimMapper(map[string][]string{
+		"directory": {"name", "email"},
+		"email":     {"tenant_id"}, // cannot redefine a standard scope
+	})
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		expected map[string]interface{}
+	}{
+		{
+			name:   "openid only",
+			scopes: []string{"openid"},
+			expected: map[string]interface{}{
+				"tenant_id": u.TenantID.String(),
+			},
+		},
+		{
+			name:   "email",
+			scopes: []string{"openid", "email"},
+			expected: map[string]interface{}{
+				"email":          "test@example.com",
+				"email_verified": true,
+				"tenant_id":      u.TenantID.String(),
+			},
+		},
+		{
+			name:   "profile and tenant",
+			scopes: []string{"openid", "profile", "tenant"},
+			expected: map[string]interface{}{
+				"name":       "Test User",
+				"updated_at": int64(1700000000),
+				"tenant_id":  u.TenantID.String(),
+			},
+		},
+		{
+			name:   "custom scope",
+			scopes: []string{"directory"},
+			expected: map[string]interface{}{
+				"name":      "Test User",
+				"email":     "test@example.com",
+				"tenant_id": u.TenantID.String(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mapper.Claims(tt.scopes, UserClaims(u)))
+		})
+	}
+}
+
+func TestClaimMapper_OrganizationClaims(t *testing.T) {
+	mapper := NewClaimMapper(nil)
+	orgID := uuid.New().String()
+	available := map[string]interface{}{
+		"tenant_id": uuid.New().String(),
+		"org_id":    orgID,
+		"org_role":  "admin",
+	}
+
+	// The organization selected at login is released with the tenant scope
+	claims := mapper.Claims([]string{"openid", "tenant"}, available)
+	assert.Equal(t, orgID, claims["org_id"])
+	assert.Equal(t, "admin", claims["org_role"])
+
+	// tenant_id is issued by default, the organization only with the tenant scope
+	claims = mapper.Claims([]string{"openid", "email"}, available)
+	assert.Equal(t, map[string]interface{}{"tenant_id": available["tenant_id"]}, claims)
+}
+
+func TestValidateGrant(t *testing.T) {
+	requested := []string{"openid", "email", "profile"}
+	allowed := []string{"openid", "email"}
+
+	assert.Empty(t, ValidateGrant([]string{"openid", "email"}, requested, allowed))
+	assert.Equal(t, []string{"admin"}, ValidateGrant([]string{"openid", "admin"}, requested, allowed))
+	assert.Equal(t, []string{"profile"}, ValidateGrant([]string{"profile"}, requested, allowed))
+	assert.Empty(t, ValidateGrant([]string{"profile"}, requested, nil))
+}
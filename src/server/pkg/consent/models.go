@@ -0,0 +1,81 @@
+package consent
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Grant records the scopes a user asked to remember for an OAuth client
+// Until it expires, later consent requests only prompt for scopes that are not in the grant yet
+type Grant struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID  uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	UserID    uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_consent_grants_user_client"`
+	ClientID  string         `json:"client_id" gorm:"not null;uniqueIndex:idx_consent_grants_user_client"`
+	Scopes    pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	ExpiresAt time.Time      `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for Grant model
+func (Grant) TableName() string {
+	return "consent_grants"
+}
+
+// BeforeCreate sets UUID if not provided
+func (g *Grant) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+// ConnectedApp is an application the user has granted access to
+type ConnectedApp struct {
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Logo      string    `json:"logo"`
+	Website   string    `json:"website"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Covers reports whether every requested scope has already been granted
+func (g *Grant) Covers(requested []string) bool {
+	return len(MissingScopes(requested, g.Scopes)) == 0
+}
+
+// MissingScopes returns the requested scopes that are not in granted
+func MissingScopes(requested, granted []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		grantedSet[scope] = true
+	}
+
+	missing := []string{}
+	for _, scope := range requested {
+		if !grantedSet[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// MergeScopes returns the union of two scope lists, preserving order
+func MergeScopes(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := []string{}
+	for _, list := range [][]string{a, b} {
+		for _, scope := range list {
+			if !seen[scope] {
+				seen[scope] = true
+				merged = append(merged, scope)
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,120 @@
+package consent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	GetGrant(userID uuid.UUID, clientID string) (*Grant, error)
+	ListGrants(userID uuid.UUID) ([]*Grant, error)
+	SaveGrant(tenantID, userID uuid.UUID, clientID string, scopes []string, expiresAt time.Time) (*Grant, error)
+	WithdrawScopes(userID uuid.UUID, clientID string, scopes []string) error
+	DeleteGrant(userID uuid.UUID, clientID string) error
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetGrant returns the unexpired grant for a user and client, or nil if none exists
+func (s *service) GetGrant(userID uuid.UUID, clientID string) (*Grant, error) {
+	grant, err := s.findGrant(userID, clientID)
+	if err != nil || grant == nil || !grant.ExpiresAt.After(time.Now()) {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// findGrant returns the stored grant for a user and client, expired or not
+func (s *service) findGrant(userID uuid.UUID, clientID string) (*Grant, error) {
+	var grant Grant
+	if err := s.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&grant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get consent grant: %w", err)
+	}
+	return &grant, nil
+}
+
+// ListGrants returns every unexpired grant the user has given, oldest first
+func (s *service) ListGrants(userID uuid.UUID) ([]*Grant, error) {
+	var grants []*Grant
+	if err := s.db.Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("created_at ASC").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list consent grants: %w", err)
+	}
+	return grants, nil
+}
+
+// SaveGrant adds scopes to the user's grant for a client, creating it if needed
+// The grant is remembered until expiresAt; scopes of an expired grant are not carried over
+func (s *service) SaveGrant(tenantID, userID uuid.UUID, clientID string, scopes []string, expiresAt time.Time) (*Grant, error) {
+	grant, err := s.findGrant(userID, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if grant == nil {
+		grant = &Grant{
+			TenantID:  tenantID,
+			UserID:    userID,
+			ClientID:  clientID,
+			Scopes:    MergeScopes(nil, scopes),
+			ExpiresAt: expiresAt,
+		}
+		if err := s.db.Create(grant).Error; err != nil {
+			s.logger.Error("Failed to create consent grant", zap.Error(err), zap.String("user_id", userID.String()), zap.String("client_id", clientID))
+			return nil, fmt.Errorf("failed to create consent grant: %w", err)
+		}
+		return grant, nil
+	}
+
+	if grant.ExpiresAt.After(time.Now()) {
+		grant.Scopes = MergeScopes(grant.Scopes, scopes)
+	} else {
+		grant.Scopes = MergeScopes(nil, scopes)
+	}
+	grant.ExpiresAt = expiresAt
+	if err := s.db.Model(grant).Updates(map[string]interface{}{"scopes": grant.Scopes, "expires_at": grant.ExpiresAt}).Error; err != nil {
+		s.logger.Error("Failed to update consent grant", zap.Error(err), zap.String("user_id", userID.String()), zap.String("client_id", clientID))
+		return nil, fmt.Errorf("failed to update consent grant: %w", err)
+	}
+	return grant, nil
+}
+
+// WithdrawScopes removes scopes from the user's grant for a client so they are prompted for again
+func (s *service) WithdrawScopes(userID uuid.UUID, clientID string, scopes []string) error {
+	grant, err := s.GetGrant(userID, clientID)
+	if err != nil || grant == nil {
+		return err
+	}
+
+	grant.Scopes = MissingScopes(grant.Scopes, scopes)
+	if err := s.db.Model(grant).Update("scopes", grant.Scopes).Error; err != nil {
+		s.logger.Error("Failed to update consent grant", zap.Error(err), zap.String("user_id", userID.String()), zap.String("client_id", clientID))
+		return fmt.Errorf("failed to update consent grant: %w", err)
+	}
+	return nil
+}
+
+// DeleteGrant removes the stored grant so the next authorization prompts again
+func (s *service) DeleteGrant(userID uuid.UUID, clientID string) error {
+	if err := s.db.Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&Grant{}).Error; err != nil {
+		return fmt.Errorf("failed to delete consent grant: %w", err)
+	}
+	return nil
+}
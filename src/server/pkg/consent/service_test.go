@@ -0,0 +1,101 @@
+package consent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&Grant{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestService_SaveGrant(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+	userID := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+
+	grant, err := service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	assert.Nil(t, grant)
+
+	_, err = service.SaveGrant(tenantID, userID, "app", []string{"openid", "email"}, expiresAt)
+	require.NoError(t, err)
+
+	// Granting again merges new scopes into the existing grant
+	_, err = service.SaveGrant(tenantID, userID, "app", []string{"email", "profile"}, expiresAt)
+	require.NoError(t, err)
+
+	grant, err = service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	require.NotNil(t, grant)
+	assert.Equal(t, []string{"openid", "email", "profile"}, []string(grant.Scopes))
+	assert.True(t, grant.Covers([]string{"openid", "profile"}))
+	assert.False(t, grant.Covers([]string{"openid", "offline_access"}))
+
+	// Grants are per client
+	other, err := service.GetGrant(userID, "other-app")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	_, err = service.SaveGrant(tenantID, userID, "other-app", []string{"openid"}, expiresAt)
+	require.NoError(t, err)
+	grants, err := service.ListGrants(userID)
+	require.NoError(t, err)
+	assert.Len(t, grants, 2)
+
+	// Withdrawn scopes are prompted for again
+	require.NoError(t, service.WithdrawScopes(userID, "app", []string{"email"}))
+	grant, err = service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"openid", "profile"}, []string(grant.Scopes))
+
+	require.NoError(t, service.DeleteGrant(userID, "app"))
+	grant, err = service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	assert.Nil(t, grant)
+}
+
+func TestMissingScopes(t *testing.T) {
+	assert.Equal(t, []string{"offline_access"}, MissingScopes([]string{"openid", "offline_access"}, []string{"openid", "email"}))
+	assert.Equal(t, []string{}, MissingScopes([]string{"openid"}, []string{"openid"}))
+	assert.Equal(t, []string{"openid"}, MissingScopes([]string{"openid"}, nil))
+}
+
+func TestService_SaveGrant_Expiry(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+	userID := uuid.New()
+
+	_, err := service.SaveGrant(tenantID, userID, "app", []string{"openid", "email"}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	// An expired grant is not remembered any more
+	grant, err := service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	assert.Nil(t, grant)
+	grants, err := service.ListGrants(userID)
+	require.NoError(t, err)
+	assert.Empty(t, grants)
+
+	// Remembering again starts over instead of reviving the expired scopes
+	_, err = service.SaveGrant(tenantID, userID, "app", []string{"openid"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	grant, err = service.GetGrant(userID, "app")
+	require.NoError(t, err)
+	require.NotNil(t, grant)
+	assert.Equal(t, []string{"openid"}, []string(grant.Scopes))
+}
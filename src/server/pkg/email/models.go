@@ -1,6 +1,9 @@
 package email
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"time"
 
 	"github.com/google/uuid"
@@ -84,6 +87,123 @@ func (p *PasswordReset) MarkAsUsed() {
 	p.UsedAt = &now
 }
 
+// EmailChange represents a pending email address change
+// The new address confirms the change with Token; the old address can cancel it with CancelToken
+type EmailChange struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid;index;not null"`
+	OldEmail    string         `json:"old_email" gorm:"not null"`
+	NewEmail    string         `json:"new_email" gorm:"not null"`
+	Token       string         `json:"-" gorm:"uniqueIndex;not null"`
+	CancelToken string         `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt   time.Time      `json:"expires_at" gorm:"not null"`
+	ConfirmedAt *time.Time     `json:"confirmed_at"`
+	CancelledAt *time.Time     `json:"cancelled_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// BeforeCreate sets UUID and tokens if not provided
+func (e *EmailChange) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.Token == "" {
+		e.Token = uuid.New().String()
+	}
+	if e.CancelToken == "" {
+		e.CancelToken = uuid.New().String()
+	}
+	// Default expiration: 24 hours
+	if e.ExpiresAt.IsZero() {
+		e.ExpiresAt = time.Now().Add(24 * time.Hour)
+	}
+	return nil
+}
+
+// IsExpired checks if the email change has expired
+func (e *EmailChange) IsExpired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+// IsPending checks if the change can still be confirmed or cancelled
+func (e *EmailChange) IsPending() bool {
+	return e.ConfirmedAt == nil && e.CancelledAt == nil && !e.IsExpired()
+}
+
+// Passwordless login methods
+// Reauth codes confirm sensitive account changes of users without a password and never sign in
+const (
+	PasswordlessMethodLink   = "link"
+	PasswordlessMethodCode   = "code"
+	PasswordlessMethodReauth = "reauth"
+)
+
+// MaxPasswordlessAttempts is the number of wrong codes allowed before a passwordless login is locked
+const MaxPasswordlessAttempts = 5
+
+// PasswordlessLogin represents a magic link or one-time code issued for a single Hydra login challenge
+// The challenge and code are stored as SHA-256 hashes
+type PasswordlessLogin struct {
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID        uuid.UUID      `json:"user_id" gorm:"type:uuid;index;not null"`
+	ChallengeHash string         `json:"-" gorm:"index;not null"`
+	Method        string         `json:"method" gorm:"not null"`
+	Token         string         `json:"-" gorm:"uniqueIndex;not null"`
+	CodeHash      string         `json:"-"`
+	Attempts      int            `json:"attempts" gorm:"default:0"`
+	ExpiresAt     time.Time      `json:"expires_at" gorm:"not null"`
+	UsedAt        *time.Time     `json:"used_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// BeforeCreate sets UUID and token if not provided
+func (p *PasswordlessLogin) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	if p.Token == "" {
+		p.Token = uuid.New().String()
+	}
+	// Default expiration: 10 minutes
+	if p.ExpiresAt.IsZero() {
+		p.ExpiresAt = time.Now().Add(10 * time.Minute)
+	}
+	return nil
+}
+
+// IsExpired checks if the passwordless login has expired
+func (p *PasswordlessLogin) IsExpired() bool {
+	return time.Now().After(p.ExpiresAt)
+}
+
+// IsValid checks if the passwordless login can still be used
+func (p *PasswordlessLogin) IsValid() bool {
+	return p.UsedAt == nil && !p.IsExpired() && p.Attempts < MaxPasswordlessAttempts
+}
+
+// MatchesChallenge reports whether the passwordless login was issued for the given login challenge
+func (p *PasswordlessLogin) MatchesChallenge(challenge string) bool {
+	return subtle.ConstantTimeCompare([]byte(p.ChallengeHash), []byte(HashSecret(challenge))) == 1
+}
+
+// MatchesCode reports whether the one-time code is correct
+func (p *PasswordlessLogin) MatchesCode(code string) bool {
+	if p.CodeHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(p.CodeHash), []byte(HashSecret(code))) == 1
+}
+
+// HashSecret returns the hex-encoded SHA-256 hash of a challenge or code
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
 // SendVerificationRequest represents a request to send verification email
 type SendVerificationRequest struct {
 	Email string `json:"email" validate:"required,email"`
@@ -109,3 +229,20 @@ type ResetPasswordRequest struct {
 type VerifyResetTokenRequest struct {
 	Token string `json:"token" validate:"required"`
 }
+
+// StartPasswordlessRequest represents a request to email a magic link or one-time code
+type StartPasswordlessRequest struct {
+	LoginChallenge string `json:"login_challenge" validate:"required"`
+	Email          string `json:"email" validate:"required,email"`
+	Method         string `json:"method" validate:"required,oneof=link code"`
+}
+
+// VerifyPasswordlessRequest represents a request to complete a passwordless login
+// Either Token (from the magic link) or Code must be set
+type VerifyPasswordlessRequest struct {
+	LoginChallenge string `json:"login_challenge" validate:"required"`
+	Token          string `json:"token" validate:"required_without=Code"`
+	Code           string `json:"code" validate:"omitempty,len=6,numeric"`
+	Remember       bool   `json:"remember"`
+	Organization   string `json:"organization"` // Organization ID or slug (optional)
+}
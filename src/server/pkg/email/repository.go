@@ -1,14 +1,23 @@
 package email
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"math/big"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// Repository handles email verification and password reset database operations
+// ErrEmailChangeNotPending is returned when an email change was already confirmed or cancelled, or has expired
+var ErrEmailChangeNotPending = errors.New("email change is no longer pending")
+
+// ErrPasswordlessLocked is returned when too many wrong codes were entered for a login challenge
+var ErrPasswordlessLocked = errors.New("too many wrong codes")
+
+// Repository handles email verification, password reset, email change and passwordless login database operations
 type Repository struct {
 	db *gorm.DB
 }
@@ -136,6 +145,221 @@ func (r *Repository) DeletePasswordResetsByUserID(userID uuid.UUID) error {
 	return nil
 }
 
+// === Email Change Methods ===
+
+// CreateEmailChange creates a pending email change, replacing any earlier pending change
+func (r *Repository) CreateEmailChange(userID uuid.UUID, oldEmail, newEmail string) (*EmailChange, error) {
+	if err := r.DeleteEmailChangesByUserID(userID); err != nil {
+		return nil, err
+	}
+
+	change := &EmailChange{
+		UserID:   userID,
+		OldEmail: oldEmail,
+		NewEmail: newEmail,
+	}
+
+	if err := r.db.Create(change).Error; err != nil {
+		return nil, fmt.Errorf("failed to create email change: %w", err)
+	}
+
+	return change, nil
+}
+
+// GetEmailChangeByToken retrieves an email change by its confirmation token
+func (r *Repository) GetEmailChangeByToken(token string) (*EmailChange, error) {
+	var change EmailChange
+	if err := r.db.Where("token = ?", token).First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("email change token not found")
+		}
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+
+	return &change, nil
+}
+
+// GetEmailChangeByCancelToken retrieves an email change by its cancel token
+func (r *Repository) GetEmailChangeByCancelToken(token string) (*EmailChange, error) {
+	var change EmailChange
+	if err := r.db.Where("cancel_token = ?", token).First(&change).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("email change token not found")
+		}
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+
+	return &change, nil
+}
+
+// ConfirmEmailChange claims a pending email change within tx
+// The update only matches a pending change, so concurrent confirmations or a cancellation cannot both succeed
+func (r *Repository) ConfirmEmailChange(tx *gorm.DB, id uuid.UUID) error {
+	return r.claimEmailChange(tx, id, "confirmed_at")
+}
+
+// CancelEmailChange cancels a pending email change
+func (r *Repository) CancelEmailChange(id uuid.UUID) error {
+	return r.claimEmailChange(r.db, id, "cancelled_at")
+}
+
+// claimEmailChange sets column on a change that is neither confirmed, cancelled nor expired
+func (r *Repository) claimEmailChange(db *gorm.DB, id uuid.UUID, column string) error {
+	now := time.Now()
+	result := db.Model(&EmailChange{}).
+		Where("id = ? AND confirmed_at IS NULL AND cancelled_at IS NULL AND expires_at > ?", id, now).
+		Update(column, now)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update email change: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrEmailChangeNotPending
+	}
+	return nil
+}
+
+// DeleteEmailChangesByUserID deletes all email changes for a user
+func (r *Repository) DeleteEmailChangesByUserID(userID uuid.UUID) error {
+	if err := r.db.Where("user_id = ?", userID).Delete(&EmailChange{}).Error; err != nil {
+		return fmt.Errorf("failed to delete email changes: %w", err)
+	}
+	return nil
+}
+
+// === Passwordless Login Methods ===
+
+// CreatePasswordlessLogin issues a magic link token and one-time code for a login challenge,
+// replacing any earlier unused ones issued to the user for the same challenge.
+// Wrong codes entered for the replaced ones still count, so resending does not reset the limit;
+// once it is reached ErrPasswordlessLocked is returned until the last code expires.
+// The plain code is returned once and only its hash is stored.
+func (r *Repository) CreatePasswordlessLogin(userID uuid.UUID, challenge, method string) (*PasswordlessLogin, string, error) {
+	challengeHash := HashSecret(challenge)
+
+	var attempts int
+	if err := r.db.Model(&PasswordlessLogin{}).
+		Where("user_id = ? AND challenge_hash = ? AND used_at IS NULL AND expires_at > ?", userID, challengeHash, time.Now()).
+		Select("COALESCE(MAX(attempts), 0)").
+		Scan(&attempts).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to count passwordless attempts: %w", err)
+	}
+	if attempts >= MaxPasswordlessAttempts {
+		return nil, "", ErrPasswordlessLocked
+	}
+
+	if err := r.db.Where("user_id = ? AND challenge_hash = ? AND used_at IS NULL", userID, challengeHash).
+		Delete(&PasswordlessLogin{}).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to invalidate old passwordless logins: %w", err)
+	}
+
+	login := &PasswordlessLogin{
+		UserID:        userID,
+		ChallengeHash: challengeHash,
+		Method:        method,
+		Attempts:      attempts,
+	}
+
+	var code string
+	if method == PasswordlessMethodCode || method == PasswordlessMethodReauth {
+		generated, err := generateOneTimeCode()
+		if err != nil {
+			return nil, "", err
+		}
+		code = generated
+		login.CodeHash = HashSecret(code)
+	}
+
+	if err := r.db.Create(login).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create passwordless login: %w", err)
+	}
+
+	return login, code, nil
+}
+
+// GetPasswordlessLoginByToken retrieves an unused passwordless login by its magic link token
+func (r *Repository) GetPasswordlessLoginByToken(token string) (*PasswordlessLogin, error) {
+	var login PasswordlessLogin
+	if err := r.db.Where("token = ? AND used_at IS NULL", token).First(&login).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("passwordless login not found or already used")
+		}
+		return nil, fmt.Errorf("failed to get passwordless login: %w", err)
+	}
+
+	return &login, nil
+}
+
+// GetPendingPasswordlessLoginByChallenge retrieves the latest unused passwordless login for a login challenge
+func (r *Repository) GetPendingPasswordlessLoginByChallenge(challenge string) (*PasswordlessLogin, error) {
+	var login PasswordlessLogin
+	if err := r.db.Where("challenge_hash = ? AND used_at IS NULL", HashSecret(challenge)).
+		Order("created_at DESC").
+		First(&login).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("passwordless login not found or already used")
+		}
+		return nil, fmt.Errorf("failed to get passwordless login: %w", err)
+	}
+
+	return &login, nil
+}
+
+// CreateReauthCode issues a one-time code that confirms a sensitive change to the user's account
+// It replaces any earlier unused code; the plain code is returned once
+func (r *Repository) CreateReauthCode(userID uuid.UUID) (*PasswordlessLogin, string, error) {
+	return r.CreatePasswordlessLogin(userID, reauthChallenge(userID), PasswordlessMethodReauth)
+}
+
+// GetPendingReauthCode retrieves the user's latest unused reauthentication code
+func (r *Repository) GetPendingReauthCode(userID uuid.UUID) (*PasswordlessLogin, error) {
+	login, err := r.GetPendingPasswordlessLoginByChallenge(reauthChallenge(userID))
+	if err != nil {
+		return nil, err
+	}
+	if login.UserID != userID || login.Method != PasswordlessMethodReauth {
+		return nil, fmt.Errorf("reauthentication code not found")
+	}
+	return login, nil
+}
+
+// reauthChallenge is the challenge reauthentication codes are bound to; Hydra never issues it
+func reauthChallenge(userID uuid.UUID) string {
+	return "reauth:" + userID.String()
+}
+
+// IncrementPasswordlessAttempts records a wrong one-time code
+func (r *Repository) IncrementPasswordlessAttempts(id uuid.UUID) error {
+	if err := r.db.Model(&PasswordlessLogin{}).Where("id = ?", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+		return fmt.Errorf("failed to record passwordless attempt: %w", err)
+	}
+	return nil
+}
+
+// ConsumePasswordlessLogin marks a passwordless login as used
+// It fails if the login was already used, so concurrent verifications cannot both succeed
+func (r *Repository) ConsumePasswordlessLogin(id uuid.UUID) error {
+	result := r.db.Model(&PasswordlessLogin{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark passwordless login as used: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("passwordless login already used")
+	}
+	return nil
+}
+
+// generateOneTimeCode returns a random 6-digit code
+func generateOneTimeCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate one-time code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
 // CleanupExpiredTokens removes expired tokens (can be run periodically)
 func (r *Repository) CleanupExpiredTokens() error {
 	// Delete expired email verifications
@@ -148,5 +372,15 @@ func (r *Repository) CleanupExpiredTokens() error {
 		return fmt.Errorf("failed to cleanup expired resets: %w", err)
 	}
 
+	// Delete expired email changes
+	if err := r.db.Unscoped().Where("expires_at < NOW() AND deleted_at IS NULL").Delete(&EmailChange{}).Error; err != nil {
+		return fmt.Errorf("failed to cleanup expired email changes: %w", err)
+	}
+
+	// Delete expired passwordless logins
+	if err := r.db.Unscoped().Where("expires_at < NOW() AND deleted_at IS NULL").Delete(&PasswordlessLogin{}).Error; err != nil {
+		return fmt.Errorf("failed to cleanup expired passwordless logins: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,143 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&PasswordlessLogin{}, &EmailChange{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestRepository_PasswordlessCode(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	userID := uuid.New()
+
+	login, code, err := repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodCode)
+	require.NoError(t, err)
+	assert.Len(t, code, 6)
+	assert.NotEqual(t, code, login.CodeHash)
+
+	pending, err := repo.GetPendingPasswordlessLoginByChallenge("challenge-1")
+	require.NoError(t, err)
+	assert.Equal(t, login.ID, pending.ID)
+	assert.True(t, pending.MatchesChallenge("challenge-1"))
+	assert.False(t, pending.MatchesChallenge("challenge-2"))
+	assert.True(t, pending.MatchesCode(code))
+
+	// Codes are bound to the challenge they were issued for
+	_, err = repo.GetPendingPasswordlessLoginByChallenge("challenge-2")
+	assert.Error(t, err)
+
+	// Codes are single-use
+	require.NoError(t, repo.ConsumePasswordlessLogin(login.ID))
+	assert.Error(t, repo.ConsumePasswordlessLogin(login.ID))
+	_, err = repo.GetPendingPasswordlessLoginByChallenge("challenge-1")
+	assert.Error(t, err)
+}
+
+func TestRepository_PasswordlessAttempts(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	userID := uuid.New()
+
+	login, _, err := repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodCode)
+	require.NoError(t, err)
+	require.NoError(t, repo.IncrementPasswordlessAttempts(login.ID))
+
+	// Resending a code keeps the wrong attempts made so far
+	login, _, err = repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodCode)
+	require.NoError(t, err)
+	assert.Equal(t, 1, login.Attempts)
+
+	for i := 1; i < MaxPasswordlessAttempts; i++ {
+		require.NoError(t, repo.IncrementPasswordlessAttempts(login.ID))
+	}
+
+	locked, err := repo.GetPendingPasswordlessLoginByChallenge("challenge-1")
+	require.NoError(t, err)
+	assert.False(t, locked.IsValid())
+
+	// A locked challenge gets no new codes
+	_, _, err = repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodCode)
+	assert.ErrorIs(t, err, ErrPasswordlessLocked)
+	_, _, err = repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodLink)
+	assert.ErrorIs(t, err, ErrPasswordlessLocked)
+
+	// Other challenges are not affected
+	_, _, err = repo.CreatePasswordlessLogin(userID, "challenge-2", PasswordlessMethodCode)
+	assert.NoError(t, err)
+}
+
+func TestRepository_PasswordlessLinkReplacesEarlierLink(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	userID := uuid.New()
+
+	first, code, err := repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodLink)
+	require.NoError(t, err)
+	assert.Empty(t, code)
+	assert.False(t, first.MatchesCode(""))
+
+	second, _, err := repo.CreatePasswordlessLogin(userID, "challenge-1", PasswordlessMethodLink)
+	require.NoError(t, err)
+
+	_, err = repo.GetPasswordlessLoginByToken(first.Token)
+	assert.Error(t, err)
+
+	found, err := repo.GetPasswordlessLoginByToken(second.Token)
+	require.NoError(t, err)
+	assert.True(t, found.IsValid())
+}
+
+func TestRepository_ReauthCode(t *testing.T) {
+	repo := NewRepository(setupTestDB(t))
+	userID := uuid.New()
+
+	login, code, err := repo.CreateReauthCode(userID)
+	require.NoError(t, err)
+	assert.Len(t, code, 6)
+	assert.Equal(t, PasswordlessMethodReauth, login.Method)
+
+	pending, err := repo.GetPendingReauthCode(userID)
+	require.NoError(t, err)
+	assert.Equal(t, login.ID, pending.ID)
+	assert.True(t, pending.MatchesCode(code))
+
+	// Codes belong to the user they were sent to
+	_, err = repo.GetPendingReauthCode(uuid.New())
+	assert.Error(t, err)
+
+	require.NoError(t, repo.ConsumePasswordlessLogin(login.ID))
+	_, err = repo.GetPendingReauthCode(userID)
+	assert.Error(t, err)
+}
+
+func TestRepository_EmailChangeClaimedOnce(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	userID := uuid.New()
+
+	change, err := repo.CreateEmailChange(userID, "old@example.com", "new@example.com")
+	require.NoError(t, err)
+
+	// A change is confirmed at most once and cannot be cancelled afterwards
+	require.NoError(t, repo.ConfirmEmailChange(db, change.ID))
+	assert.ErrorIs(t, repo.ConfirmEmailChange(db, change.ID), ErrEmailChangeNotPending)
+	assert.ErrorIs(t, repo.CancelEmailChange(change.ID), ErrEmailChangeNotPending)
+
+	// A cancelled change cannot be confirmed
+	change, err = repo.CreateEmailChange(userID, "old@example.com", "other@example.com")
+	require.NoError(t, err)
+	require.NoError(t, repo.CancelEmailChange(change.ID))
+	assert.ErrorIs(t, repo.ConfirmEmailChange(db, change.ID), ErrEmailChangeNotPending)
+}
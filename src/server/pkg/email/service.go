@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/smtp"
+	"net/url"
 	"strings"
 
 	"go.uber.org/zap"
@@ -67,6 +68,63 @@ func (s *Service) SendPasswordResetEmail(toEmail, token string) error {
 	return s.sendEmail(toEmail, subject, body)
 }
 
+// SendEmailChangeConfirmation sends the confirmation link for an email change to the new address
+func (s *Service) SendEmailChangeConfirmation(toEmail, token string) error {
+	confirmLink := fmt.Sprintf("%s/confirm-email-change?token=%s", s.frontendURL, token)
+
+	subject := "Authway - 이메일 주소 변경 확인"
+	body := s.renderEmailChangeConfirmationTemplate(confirmLink, toEmail)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendEmailChangeNotice notifies the old address of an email change and offers a cancel link
+func (s *Service) SendEmailChangeNotice(toEmail, newEmail, cancelToken string) error {
+	cancelLink := fmt.Sprintf("%s/cancel-email-change?token=%s", s.frontendURL, cancelToken)
+
+	subject := "Authway - 이메일 주소 변경 요청 알림"
+	body := s.renderEmailChangeNoticeTemplate(cancelLink, newEmail)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendMagicLinkEmail sends a sign-in link bound to a login challenge
+func (s *Service) SendMagicLinkEmail(toEmail, loginChallenge, token string) error {
+	magicLink := fmt.Sprintf("%s/login/passwordless?login_challenge=%s&token=%s",
+		s.frontendURL, url.QueryEscape(loginChallenge), url.QueryEscape(token))
+
+	subject := "Authway - 로그인 링크"
+	body := s.renderMagicLinkTemplate(magicLink)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendLoginCodeEmail sends a one-time sign-in code
+func (s *Service) SendLoginCodeEmail(toEmail, code string) error {
+	subject := fmt.Sprintf("Authway - 로그인 코드 %s", code)
+	body := s.renderCodeTemplate("🔑 Authway 로그인 코드", "로그인 화면에 아래 코드를 입력하세요.", code)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendReauthCodeEmail sends a one-time code that confirms a sensitive account change
+func (s *Service) SendReauthCodeEmail(toEmail, code string) error {
+	subject := fmt.Sprintf("Authway - 본인 확인 코드 %s", code)
+	body := s.renderCodeTemplate("🛡️ Authway 본인 확인 코드", "계정 변경을 계속하려면 아래 코드를 입력하세요. 본인이 요청하지 않았다면 연결된 앱과 로그인 세션을 확인하세요.", code)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
+// SendInvitationEmail sends an invitation to join a tenant
+func (s *Service) SendInvitationEmail(toEmail, tenantName, token string) error {
+	acceptLink := fmt.Sprintf("%s/accept-invitation?token=%s", s.frontendURL, token)
+
+	subject := fmt.Sprintf("Authway - %s 초대", tenantName)
+	body := s.renderInvitationTemplate(acceptLink, tenantName)
+
+	return s.sendEmail(toEmail, subject, body)
+}
+
 // sendEmail sends an email via SMTP
 func (s *Service) sendEmail(to, subject, body string) error {
 	// Build email message
@@ -256,6 +314,369 @@ func (s *Service) renderPasswordResetTemplate(resetLink string) string {
 	return buf.String()
 }
 
+// renderEmailChangeConfirmationTemplate renders email change confirmation HTML template
+func (s *Service) renderEmailChangeConfirmationTemplate(confirmLink, newEmail string) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            padding: 40px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1 {
+            color: #4F46E5;
+            margin-bottom: 20px;
+        }
+        .button {
+            display: inline-block;
+            padding: 12px 30px;
+            background-color: #4F46E5;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            margin: 20px 0;
+        }
+        .warning {
+            background: #FEF3C7;
+            border-left: 4px solid #F59E0B;
+            padding: 12px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #eee;
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>📧 이메일 주소 변경 확인</h1>
+        <p>안녕하세요!</p>
+        <p>Authway 계정의 이메일 주소를 <strong>{{.Email}}</strong>(으)로 변경하도록 요청하셨습니다.</p>
+        <p>아래 버튼을 클릭하여 변경을 완료하세요.</p>
+        <a href="{{.Link}}" class="button">이메일 주소 변경 확인하기</a>
+        <p>또는 아래 링크를 복사하여 브라우저에 붙여넣으세요:</p>
+        <p style="background: #f5f5f5; padding: 10px; border-radius: 4px; word-break: break-all;">
+            {{.Link}}
+        </p>
+        <div class="footer">
+            <p>이 링크는 24시간 동안 유효합니다.</p>
+            <p>변경이 완료되면 모든 기기에서 재로그인이 필요합니다.</p>
+            <p>본인이 요청하지 않은 경우, 이 이메일을 무시하셔도 됩니다.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	t := template.Must(template.New("email-change-confirmation").Parse(tmpl))
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{"Link": confirmLink, "Email": newEmail})
+	return buf.String()
+}
+
+// renderEmailChangeNoticeTemplate renders the email change notice HTML template for the old address
+func (s *Service) renderEmailChangeNoticeTemplate(cancelLink, newEmail string) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            padding: 40px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1 {
+            color: #DC2626;
+            margin-bottom: 20px;
+        }
+        .button {
+            display: inline-block;
+            padding: 12px 30px;
+            background-color: #DC2626;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            margin: 20px 0;
+        }
+        .warning {
+            background: #FEF3C7;
+            border-left: 4px solid #F59E0B;
+            padding: 12px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #eee;
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>⚠️ 이메일 주소 변경 요청</h1>
+        <p>안녕하세요!</p>
+        <p>Authway 계정의 이메일 주소를 <strong>{{.Email}}</strong>(으)로 변경하는 요청이 접수되었습니다.</p>
+        <p>새 이메일 주소에서 확인을 완료하면 변경이 적용됩니다.</p>
+        <div class="warning">
+            <strong>⚠️ 보안 안내</strong><br>
+            본인이 요청하지 않은 경우, 아래 버튼을 클릭하여 변경을 취소하고 즉시 비밀번호를 변경하시기 바랍니다.
+        </div>
+        <a href="{{.Link}}" class="button">이메일 변경 취소하기</a>
+        <p>또는 아래 링크를 복사하여 브라우저에 붙여넣으세요:</p>
+        <p style="background: #f5f5f5; padding: 10px; border-radius: 4px; word-break: break-all;">
+            {{.Link}}
+        </p>
+        <div class="footer">
+            <p>이 링크는 24시간 동안 유효합니다.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	t := template.Must(template.New("email-change-notice").Parse(tmpl))
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{"Link": cancelLink, "Email": newEmail})
+	return buf.String()
+}
+
+// renderMagicLinkTemplate renders the passwordless sign-in link HTML template
+func (s *Service) renderMagicLinkTemplate(magicLink string) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            padding: 40px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1 {
+            color: #4F46E5;
+            margin-bottom: 20px;
+        }
+        .button {
+            display: inline-block;
+            padding: 12px 30px;
+            background-color: #4F46E5;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #eee;
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>🔑 Authway 로그인</h1>
+        <p>안녕하세요!</p>
+        <p>아래 버튼을 클릭하면 비밀번호 없이 로그인됩니다.</p>
+        <a href="{{.Link}}" class="button">로그인하기</a>
+        <p>또는 아래 링크를 복사하여 브라우저에 붙여넣으세요:</p>
+        <p style="background: #f5f5f5; padding: 10px; border-radius: 4px; word-break: break-all;">
+            {{.Link}}
+        </p>
+        <div class="footer">
+            <p>이 링크는 10분 동안 유효하며 한 번만 사용할 수 있습니다.</p>
+            <p>로그인을 요청한 브라우저에서 링크를 열어주세요.</p>
+            <p>본인이 요청하지 않은 경우, 이 이메일을 무시하셔도 됩니다.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	t := template.Must(template.New("magic-link").Parse(tmpl))
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{"Link": magicLink})
+	return buf.String()
+}
+
+// renderCodeTemplate renders the one-time code HTML template
+func (s *Service) renderCodeTemplate(heading, instruction, code string) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            padding: 40px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1 {
+            color: #4F46E5;
+            margin-bottom: 20px;
+        }
+        .code {
+            display: inline-block;
+            padding: 12px 30px;
+            background: #f5f5f5;
+            border-radius: 6px;
+            font-size: 32px;
+            font-weight: bold;
+            letter-spacing: 8px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #eee;
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>{{.Heading}}</h1>
+        <p>안녕하세요!</p>
+        <p>{{.Instruction}}</p>
+        <div class="code">{{.Code}}</div>
+        <div class="footer">
+            <p>이 코드는 10분 동안 유효하며 한 번만 사용할 수 있습니다.</p>
+            <p>코드를 다른 사람과 공유하지 마세요.</p>
+            <p>본인이 요청하지 않은 경우, 이 이메일을 무시하셔도 됩니다.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	t := template.Must(template.New("one-time-code").Parse(tmpl))
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{"Heading": heading, "Instruction": instruction, "Code": code})
+	return buf.String()
+}
+
+// renderInvitationTemplate renders the tenant invitation HTML template
+func (s *Service) renderInvitationTemplate(acceptLink, tenantName string) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .container {
+            background: #ffffff;
+            border-radius: 8px;
+            padding: 40px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        h1 {
+            color: #4F46E5;
+            margin-bottom: 20px;
+        }
+        .button {
+            display: inline-block;
+            padding: 12px 30px;
+            background-color: #4F46E5;
+            color: white;
+            text-decoration: none;
+            border-radius: 6px;
+            margin: 20px 0;
+        }
+        .footer {
+            margin-top: 30px;
+            padding-top: 20px;
+            border-top: 1px solid #eee;
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>✉️ {{.Tenant}} 초대</h1>
+        <p>안녕하세요!</p>
+        <p><strong>{{.Tenant}}</strong>에 초대되었습니다.</p>
+        <p>아래 버튼을 클릭하여 계정을 만들고 초대를 수락하세요.</p>
+        <a href="{{.Link}}" class="button">초대 수락하기</a>
+        <p>또는 아래 링크를 복사하여 브라우저에 붙여넣으세요:</p>
+        <p style="background: #f5f5f5; padding: 10px; border-radius: 4px; word-break: break-all;">
+            {{.Link}}
+        </p>
+        <div class="footer">
+            <p>초대 링크는 만료되거나 관리자가 취소하면 사용할 수 없습니다.</p>
+            <p>초대를 예상하지 못한 경우, 이 이메일을 무시하셔도 됩니다.</p>
+        </div>
+    </div>
+</body>
+</html>
+`
+
+	t := template.Must(template.New("invitation").Parse(tmpl))
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{"Link": acceptLink, "Tenant": tenantName})
+	return buf.String()
+}
+
 // ValidateEmail performs basic email validation
 func ValidateEmail(email string) bool {
 	email = strings.TrimSpace(email)
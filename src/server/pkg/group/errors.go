@@ -0,0 +1,21 @@
+package group
+
+import "errors"
+
+// Group-specific errors
+var (
+	// ErrNotFound is returned when a group is not found
+	ErrNotFound = errors.New("group not found")
+
+	// ErrDuplicateName is returned when a group with the same name already exists in the tenant
+	ErrDuplicateName = errors.New("group with this name already exists")
+
+	// ErrInvalidParent is returned when the parent group is in another tenant or would create a cycle
+	ErrInvalidParent = errors.New("invalid parent group")
+
+	// ErrHasChildren is returned when deleting a group that still has subgroups
+	ErrHasChildren = errors.New("group has subgroups")
+
+	// ErrUnknownUsers is returned when adding users that do not exist in the group's tenant
+	ErrUnknownUsers = errors.New("users not found in tenant")
+)
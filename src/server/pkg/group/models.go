@@ -0,0 +1,76 @@
+package group
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Group is a set of users within a tenant, such as a department or team
+// Groups can be nested: members of a subgroup are also members of its ancestors
+type Group struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_groups_tenant_name"`
+	ParentID    *uuid.UUID `json:"parent_id" gorm:"type:uuid;index"`
+	Name        string     `json:"name" gorm:"not null;uniqueIndex:idx_groups_tenant_name"`
+	Description string     `json:"description"`
+	ExternalID  *string    `json:"external_id,omitempty" gorm:"index"` // ID at the provisioning IdP (SCIM externalId)
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Group model
+func (Group) TableName() string {
+	return "groups"
+}
+
+// BeforeCreate sets UUID if not provided
+func (g *Group) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+// Membership places a user directly in a group
+type Membership struct {
+	GroupID   uuid.UUID `json:"group_id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	TenantID  uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Membership model
+func (Membership) TableName() string {
+	return "group_members"
+}
+
+// Member is a direct member of a group as returned by the member list
+type Member struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Email   string    `json:"email"`
+	Name    *string   `json:"name"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// CreateGroupRequest represents the request to create a group
+type CreateGroupRequest struct {
+	TenantID    string `json:"tenant_id" validate:"required,uuid"`
+	ParentID    string `json:"parent_id" validate:"omitempty,uuid"`
+	Name        string `json:"name" validate:"required,min=1,max=255"`
+	Description string `json:"description" validate:"max=1000"`
+}
+
+// UpdateGroupRequest represents the request to update a group
+// An empty ParentID moves the group to the top level
+type UpdateGroupRequest struct {
+	Name        string  `json:"name" validate:"omitempty,min=1,max=255"`
+	Description *string `json:"description" validate:"omitempty,max=1000"`
+	ParentID    *string `json:"parent_id"`
+}
+
+// MembersRequest represents a bulk add or remove of group members
+type MembersRequest struct {
+	UserIDs []string `json:"user_ids" validate:"required,min=1,max=1000,dive,uuid"`
+}
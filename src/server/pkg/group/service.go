@@ -0,0 +1,395 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateGroupRequest) (*Group, error)
+	Get(id uuid.UUID) (*Group, error)
+	List(tenantID uuid.UUID) ([]*Group, error)
+	Update(id uuid.UUID, req *UpdateGroupRequest) (*Group, error)
+	Delete(id uuid.UUID) error
+
+	AddMembers(groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error)
+	RemoveMembers(groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error)
+	ListMembers(groupID uuid.UUID, limit, offset int) ([]*Member, int64, error)
+
+	UserGroups(userID uuid.UUID) ([]*Group, error)
+	EffectiveGroups(userID uuid.UUID) ([]*Group, error)
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateGroupRequest) (*Group, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkNameAvailable(tenantID, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	group := &Group{
+		TenantID:    tenantID,
+		Name:        name,
+		Description: req.Description,
+	}
+
+	if req.ParentID != "" {
+		parentID, err := s.validParent(tenantID, uuid.Nil, req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		group.ParentID = &parentID
+	}
+
+	if err := s.db.Create(group).Error; err != nil {
+		s.logger.Error("Failed to create group", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	s.logger.Info("Group created",
+		zap.String("group_id", group.ID.String()),
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("name", group.Name))
+
+	return group, nil
+}
+
+func (s *service) Get(id uuid.UUID) (*Group, error) {
+	var group Group
+	if err := s.db.Where("id = ?", id).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return &group, nil
+}
+
+func (s *service) List(tenantID uuid.UUID) ([]*Group, error) {
+	var groups []*Group
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("name ASC").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateGroupRequest) (*Group, error) {
+	group, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" && name != group.Name {
+		if err := s.checkNameAvailable(group.TenantID, name, group.ID); err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.ParentID != nil {
+		if *req.ParentID == "" {
+			updates["parent_id"] = nil
+		} else {
+			parentID, err := s.validParent(group.TenantID, group.ID, *req.ParentID)
+			if err != nil {
+				return nil, err
+			}
+			updates["parent_id"] = parentID
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(group).Updates(updates).Error; err != nil {
+			s.logger.Error("Failed to update group", zap.Error(err), zap.String("group_id", id.String()))
+			return nil, fmt.Errorf("failed to update group: %w", err)
+		}
+	}
+
+	return s.Get(id)
+}
+
+// Delete removes a group and its memberships; groups with subgroups must be emptied first
+func (s *service) Delete(id uuid.UUID) error {
+	var children int64
+	if err := s.db.Model(&Group{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return fmt.Errorf("failed to check subgroups: %w", err)
+	}
+	if children > 0 {
+		return ErrHasChildren
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&Membership{}).Error; err != nil {
+			return fmt.Errorf("failed to delete group members: %w", err)
+		}
+		result := tx.Where("id = ?", id).Delete(&Group{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete group: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// AddMembers adds users of the group's tenant to the group and returns the users that were not already members
+func (s *service) AddMembers(groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	group, err := s.Get(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs = uniqueIDs(userIDs)
+	var known []uuid.UUID
+	if err := s.db.Table("users").
+		Where("tenant_id = ? AND id IN ? AND deleted_at IS NULL", group.TenantID, userIDs).
+		Pluck("id", &known).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up users: %w", err)
+	}
+	if missing := subtractIDs(userIDs, known); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownUsers, joinIDs(missing))
+	}
+
+	existing, err := s.memberIDs(groupID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	added := subtractIDs(userIDs, existing)
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	memberships := make([]*Membership, len(added))
+	for i, userID := range added {
+		memberships[i] = &Membership{GroupID: groupID, UserID: userID, TenantID: group.TenantID}
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&memberships).Error; err != nil {
+		s.logger.Error("Failed to add group members", zap.Error(err), zap.String("group_id", groupID.String()))
+		return nil, fmt.Errorf("failed to add group members: %w", err)
+	}
+
+	return added, nil
+}
+
+// RemoveMembers removes users from the group and returns the users that were members
+func (s *service) RemoveMembers(groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if _, err := s.Get(groupID); err != nil {
+		return nil, err
+	}
+
+	removed, err := s.memberIDs(groupID, uniqueIDs(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	if err := s.db.Where("group_id = ? AND user_id IN ?", groupID, removed).Delete(&Membership{}).Error; err != nil {
+		s.logger.Error("Failed to remove group members", zap.Error(err), zap.String("group_id", groupID.String()))
+		return nil, fmt.Errorf("failed to remove group members: %w", err)
+	}
+
+	return removed, nil
+}
+
+// ListMembers returns a page of the group's direct members ordered by email
+func (s *service) ListMembers(groupID uuid.UUID, limit, offset int) ([]*Member, int64, error) {
+	if _, err := s.Get(groupID); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Table("group_members").
+		Joins("JOIN users ON users.id = group_members.user_id AND users.deleted_at IS NULL").
+		Where("group_members.group_id = ?", groupID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count group members: %w", err)
+	}
+
+	members := []*Member{}
+	if err := query.
+		Select("users.id AS user_id, users.email, users.name, group_members.created_at AS added_at").
+		Order("users.email ASC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&members).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list group members: %w", err)
+	}
+
+	return members, total, nil
+}
+
+// UserGroups returns the groups a user is a direct member of
+func (s *service) UserGroups(userID uuid.UUID) ([]*Group, error) {
+	var groups []*Group
+	if err := s.db.
+		Where("id IN (?)", s.db.Model(&Membership{}).Select("group_id").Where("user_id = ?", userID)).
+		Order("name ASC").
+		Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to list user groups: %w", err)
+	}
+	return groups, nil
+}
+
+// EffectiveGroups returns the user's direct groups and all of their ancestors
+func (s *service) EffectiveGroups(userID uuid.UUID) ([]*Group, error) {
+	direct, err := s.UserGroups(userID)
+	if err != nil || len(direct) == 0 {
+		return direct, err
+	}
+
+	// Load the tenant's groups once and walk the parent links in memory
+	all, err := s.List(direct[0].TenantID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*Group, len(all))
+	for _, group := range all {
+		byID[group.ID] = group
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var groups []*Group
+	for _, group := range direct {
+		for current := byID[group.ID]; current != nil && !seen[current.ID]; {
+			seen[current.ID] = true
+			groups = append(groups, current)
+			if current.ParentID == nil {
+				break
+			}
+			current = byID[*current.ParentID]
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// Names returns the sorted names of a set of groups, as released in the groups claim
+func Names(groups []*Group) []string {
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IDs returns the IDs of a set of groups
+func IDs(groups []*Group) []uuid.UUID {
+	ids := make([]uuid.UUID, len(groups))
+	for i, group := range groups {
+		ids[i] = group.ID
+	}
+	return ids
+}
+
+func (s *service) checkNameAvailable(tenantID uuid.UUID, name string, excludeID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&Group{}).
+		Where("tenant_id = ? AND name = ? AND id <> ?", tenantID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check group name: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateName
+	}
+	return nil
+}
+
+// validParent checks that the parent exists in the tenant and is not groupID or one of its descendants
+func (s *service) validParent(tenantID, groupID uuid.UUID, rawParentID string) (uuid.UUID, error) {
+	parentID, err := uuid.Parse(rawParentID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidParent
+	}
+
+	for current := parentID; ; {
+		if current == groupID {
+			return uuid.Nil, ErrInvalidParent
+		}
+		ancestor, err := s.Get(current)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return uuid.Nil, ErrInvalidParent
+			}
+			return uuid.Nil, err
+		}
+		if ancestor.TenantID != tenantID {
+			return uuid.Nil, ErrInvalidParent
+		}
+		if ancestor.ParentID == nil {
+			return parentID, nil
+		}
+		current = *ancestor.ParentID
+	}
+}
+
+func (s *service) memberIDs(groupID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := s.db.Model(&Membership{}).
+		Where("group_id = ? AND user_id IN ?", groupID, userIDs).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up group members: %w", err)
+	}
+	return ids, nil
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	result := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// subtractIDs returns the IDs in ids that are not in exclude, keeping their order
+func subtractIDs(ids, exclude []uuid.UUID) []uuid.UUID {
+	excluded := make(map[uuid.UUID]bool, len(exclude))
+	for _, id := range exclude {
+		excluded[id] = true
+	}
+	result := []uuid.UUID{}
+	for _, id := range ids {
+		if !excluded[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func joinIDs(ids []uuid.UUID) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = id.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,130 @@
+package group
+
+import (
+	"testing"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&user.User{}, &Group{}, &Membership{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, tenantID uuid.UUID, email string) uuid.UUID {
+	u := &user.User{TenantID: tenantID, Email: email, PasswordHash: "x"}
+	require.NoError(t, db.Create(u).Error)
+	return u.ID
+}
+
+func TestService_CreateAndNesting(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	engineering, err := service.Create(tenantID, &CreateGroupRequest{Name: "Engineering"})
+	require.NoError(t, err)
+	backend, err := service.Create(tenantID, &CreateGroupRequest{Name: "Backend", ParentID: engineering.ID.String()})
+	require.NoError(t, err)
+	assert.Equal(t, engineering.ID, *backend.ParentID)
+
+	_, err = service.Create(tenantID, &CreateGroupRequest{Name: "Engineering"})
+	assert.ErrorIs(t, err, ErrDuplicateName)
+
+	// Parents must be in the same tenant
+	_, err = service.Create(uuid.New(), &CreateGroupRequest{Name: "Other", ParentID: engineering.ID.String()})
+	assert.ErrorIs(t, err, ErrInvalidParent)
+
+	// A group cannot be moved under itself or its descendants
+	parentID := backend.ID.String()
+	_, err = service.Update(engineering.ID, &UpdateGroupRequest{ParentID: &parentID})
+	assert.ErrorIs(t, err, ErrInvalidParent)
+
+	// Moving to the top level clears the parent
+	topLevel := ""
+	moved, err := service.Update(backend.ID, &UpdateGroupRequest{ParentID: &topLevel})
+	require.NoError(t, err)
+	assert.Nil(t, moved.ParentID)
+}
+
+func TestService_Members(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+
+	alice := createUser(t, db, tenantID, "alice@example.com")
+	bob := createUser(t, db, tenantID, "bob@example.com")
+	outsider := createUser(t, db, uuid.New(), "eve@example.com")
+
+	group, err := service.Create(tenantID, &CreateGroupRequest{Name: "Team"})
+	require.NoError(t, err)
+
+	added, err := service.AddMembers(group.ID, []uuid.UUID{alice, bob, alice})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{alice, bob}, added)
+
+	// Adding again is a no-op and users of other tenants are rejected
+	added, err = service.AddMembers(group.ID, []uuid.UUID{alice})
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	_, err = service.AddMembers(group.ID, []uuid.UUID{outsider})
+	assert.ErrorIs(t, err, ErrUnknownUsers)
+
+	members, total, err := service.ListMembers(group.ID, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, members, 1)
+	assert.Equal(t, "alice@example.com", members[0].Email)
+
+	removed, err := service.RemoveMembers(group.ID, []uuid.UUID{alice, outsider})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{alice}, removed)
+
+	_, total, err = service.ListMembers(group.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestService_EffectiveGroups(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+	alice := createUser(t, db, tenantID, "alice@example.com")
+
+	company, err := service.Create(tenantID, &CreateGroupRequest{Name: "Company"})
+	require.NoError(t, err)
+	engineering, err := service.Create(tenantID, &CreateGroupRequest{Name: "Engineering", ParentID: company.ID.String()})
+	require.NoError(t, err)
+	backend, err := service.Create(tenantID, &CreateGroupRequest{Name: "Backend", ParentID: engineering.ID.String()})
+	require.NoError(t, err)
+	_, err = service.Create(tenantID, &CreateGroupRequest{Name: "Sales", ParentID: company.ID.String()})
+	require.NoError(t, err)
+
+	_, err = service.AddMembers(backend.ID, []uuid.UUID{alice})
+	require.NoError(t, err)
+
+	direct, err := service.UserGroups(alice)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Backend"}, Names(direct))
+
+	effective, err := service.EffectiveGroups(alice)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Backend", "Company", "Engineering"}, Names(effective))
+
+	// Groups with subgroups cannot be deleted
+	assert.ErrorIs(t, service.Delete(engineering.ID), ErrHasChildren)
+	require.NoError(t, service.Delete(backend.ID))
+	effective, err = service.EffectiveGroups(alice)
+	require.NoError(t, err)
+	assert.Empty(t, effective)
+}
@@ -0,0 +1,18 @@
+package invitation
+
+import "errors"
+
+// Invitation-specific errors
+var (
+	// ErrNotFound is returned when an invitation is not found
+	ErrNotFound = errors.New("invitation not found")
+
+	// ErrAlreadyInvited is returned when the email already has a pending invitation to the tenant
+	ErrAlreadyInvited = errors.New("email already has a pending invitation")
+
+	// ErrNotPending is returned when an invitation was already accepted or revoked
+	ErrNotPending = errors.New("invitation is no longer pending")
+
+	// ErrExpired is returned when accepting an expired invitation
+	ErrExpired = errors.New("invitation has expired")
+)
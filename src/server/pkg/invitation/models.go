@@ -0,0 +1,131 @@
+package invitation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// DefaultExpiry is how long an invitation stays valid when no expiry is requested
+const DefaultExpiry = 7 * 24 * time.Hour
+
+// Invitation statuses
+const (
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusRevoked  = "revoked"
+	StatusExpired  = "expired"
+)
+
+// Invitation invites an email address to join a tenant, optionally into one of its organizations
+// Accepting it creates the user with a pre-verified email and the pre-assigned roles
+type Invitation struct {
+	ID               uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID         uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Email            string         `json:"email" gorm:"not null;index"`
+	Roles            pq.StringArray `json:"roles" gorm:"type:text[]"`
+	OrganizationID   *uuid.UUID     `json:"organization_id" gorm:"type:uuid;index"`
+	OrganizationRole string         `json:"organization_role"`
+	Token            string         `json:"-" gorm:"uniqueIndex;not null"`
+	InvitedBy        string         `json:"invited_by"`
+	ExpiresAt        time.Time      `json:"expires_at" gorm:"not null"`
+	AcceptedAt       *time.Time     `json:"accepted_at"`
+	AcceptedUserID   *uuid.UUID     `json:"accepted_user_id" gorm:"type:uuid"`
+	RevokedAt        *time.Time     `json:"revoked_at"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for Invitation model
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// BeforeCreate sets UUID, token and expiry if not provided
+func (i *Invitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	if i.Token == "" {
+		i.Token = uuid.New().String()
+	}
+	if i.ExpiresAt.IsZero() {
+		i.ExpiresAt = time.Now().Add(DefaultExpiry)
+	}
+	return nil
+}
+
+// Status reports whether the invitation is pending, accepted, revoked or expired
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt != nil:
+		return StatusAccepted
+	case i.RevokedAt != nil:
+		return StatusRevoked
+	case time.Now().After(i.ExpiresAt):
+		return StatusExpired
+	default:
+		return StatusPending
+	}
+}
+
+// IsPending checks if the invitation can still be accepted
+func (i *Invitation) IsPending() bool {
+	return i.Status() == StatusPending
+}
+
+// PublicInvitation is the invitation representation returned by the API
+type PublicInvitation struct {
+	ID               uuid.UUID  `json:"id"`
+	TenantID         uuid.UUID  `json:"tenant_id"`
+	Email            string     `json:"email"`
+	Roles            []string   `json:"roles"`
+	OrganizationID   *uuid.UUID `json:"organization_id,omitempty"`
+	OrganizationRole string     `json:"organization_role,omitempty"`
+	InvitedBy        string     `json:"invited_by"`
+	Status           string     `json:"status"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	AcceptedAt       *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// ToPublic converts Invitation to PublicInvitation
+func (i *Invitation) ToPublic() PublicInvitation {
+	roles := []string(i.Roles)
+	if roles == nil {
+		roles = []string{}
+	}
+	return PublicInvitation{
+		ID:               i.ID,
+		TenantID:         i.TenantID,
+		Email:            i.Email,
+		Roles:            roles,
+		OrganizationID:   i.OrganizationID,
+		OrganizationRole: i.OrganizationRole,
+		InvitedBy:        i.InvitedBy,
+		Status:           i.Status(),
+		ExpiresAt:        i.ExpiresAt,
+		AcceptedAt:       i.AcceptedAt,
+		RevokedAt:        i.RevokedAt,
+		CreatedAt:        i.CreatedAt,
+	}
+}
+
+// CreateInvitationRequest represents the request to invite a user
+type CreateInvitationRequest struct {
+	TenantID       string   `json:"tenant_id" validate:"required,uuid"`
+	Email          string   `json:"email" validate:"required,email"`
+	Roles          []string `json:"roles" validate:"omitempty,dive,required"`
+	InvitedBy      string   `json:"invited_by" validate:"max=255"`
+	ExpiresInHours int      `json:"expires_in_hours" validate:"omitempty,min=1,max=720"`
+}
+
+// AcceptInvitationRequest represents the request to accept an invitation
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
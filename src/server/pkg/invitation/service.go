@@ -0,0 +1,208 @@
+package invitation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	Create(tenantID uuid.UUID, email string, roles []string, invitedBy string, expiresIn time.Duration) (*Invitation, error)
+	CreateForOrganization(tenantID, organizationID uuid.UUID, organizationRole, email, invitedBy string, expiresIn time.Duration) (*Invitation, error)
+	GetByID(id uuid.UUID) (*Invitation, error)
+	GetByToken(token string) (*Invitation, error)
+	List(tenantID uuid.UUID, status string, limit, offset int) ([]*Invitation, int64, error)
+	Resend(id uuid.UUID, expiresIn time.Duration) (*Invitation, error)
+	Revoke(id uuid.UUID) error
+	MarkAccepted(id, userID uuid.UUID) error
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// pendingScope limits a query to invitations that can still be accepted
+func pendingScope(db *gorm.DB) *gorm.DB {
+	return db.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+}
+
+// Create invites an email address to a tenant; expiresIn of zero uses DefaultExpiry
+func (s *service) Create(tenantID uuid.UUID, email string, roles []string, invitedBy string, expiresIn time.Duration) (*Invitation, error) {
+	return s.create(&Invitation{
+		TenantID:  tenantID,
+		Email:     email,
+		Roles:     pq.StringArray(roles),
+		InvitedBy: invitedBy,
+	}, expiresIn)
+}
+
+// CreateForOrganization invites an email address to a tenant and one of its organizations
+func (s *service) CreateForOrganization(tenantID, organizationID uuid.UUID, organizationRole, email, invitedBy string, expiresIn time.Duration) (*Invitation, error) {
+	return s.create(&Invitation{
+		TenantID:         tenantID,
+		Email:            email,
+		OrganizationID:   &organizationID,
+		OrganizationRole: organizationRole,
+		InvitedBy:        invitedBy,
+	}, expiresIn)
+}
+
+func (s *service) create(invitation *Invitation, expiresIn time.Duration) (*Invitation, error) {
+	invitation.Email = strings.ToLower(strings.TrimSpace(invitation.Email))
+
+	var pending int64
+	if err := s.db.Model(&Invitation{}).Scopes(pendingScope).
+		Where("tenant_id = ? AND email = ?", invitation.TenantID, invitation.Email).
+		Count(&pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to check pending invitations: %w", err)
+	}
+	if pending > 0 {
+		return nil, ErrAlreadyInvited
+	}
+
+	if expiresIn <= 0 {
+		expiresIn = DefaultExpiry
+	}
+	invitation.ExpiresAt = time.Now().Add(expiresIn)
+
+	if err := s.db.Create(invitation).Error; err != nil {
+		s.logger.Error("Failed to create invitation", zap.Error(err), zap.String("tenant_id", invitation.TenantID.String()))
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	s.logger.Info("Invitation created",
+		zap.String("invitation_id", invitation.ID.String()),
+		zap.String("tenant_id", invitation.TenantID.String()))
+
+	return invitation, nil
+}
+
+func (s *service) GetByID(id uuid.UUID) (*Invitation, error) {
+	var invitation Invitation
+	if err := s.db.Where("id = ?", id).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+func (s *service) GetByToken(token string) (*Invitation, error) {
+	var invitation Invitation
+	if err := s.db.Where("token = ?", token).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+// List returns a tenant's invitations, newest first, optionally filtered by status
+func (s *service) List(tenantID uuid.UUID, status string, limit, offset int) ([]*Invitation, int64, error) {
+	query := s.db.Model(&Invitation{}).Where("tenant_id = ?", tenantID)
+
+	now := time.Now()
+	switch status {
+	case "":
+	case StatusPending:
+		query = query.Scopes(pendingScope)
+	case StatusAccepted:
+		query = query.Where("accepted_at IS NOT NULL")
+	case StatusRevoked:
+		query = query.Where("revoked_at IS NOT NULL")
+	case StatusExpired:
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= ?", now)
+	default:
+		return nil, 0, fmt.Errorf("unknown invitation status %q", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count invitations: %w", err)
+	}
+
+	var invitations []*Invitation
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&invitations).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	return invitations, total, nil
+}
+
+// Resend issues a new token and expiry so earlier links stop working
+// Expired invitations can be resent; accepted and revoked ones cannot
+func (s *service) Resend(id uuid.UUID, expiresIn time.Duration) (*Invitation, error) {
+	invitation, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return nil, ErrNotPending
+	}
+
+	if expiresIn <= 0 {
+		expiresIn = DefaultExpiry
+	}
+
+	invitation.Token = uuid.New().String()
+	invitation.ExpiresAt = time.Now().Add(expiresIn)
+	if err := s.db.Model(invitation).Updates(map[string]interface{}{
+		"token":      invitation.Token,
+		"expires_at": invitation.ExpiresAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to renew invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+func (s *service) Revoke(id uuid.UUID) error {
+	result := s.db.Model(&Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := s.GetByID(id); err != nil {
+			return err
+		}
+		return ErrNotPending
+	}
+
+	s.logger.Info("Invitation revoked", zap.String("invitation_id", id.String()))
+	return nil
+}
+
+// MarkAccepted records the user created from the invitation
+func (s *service) MarkAccepted(id, userID uuid.UUID) error {
+	result := s.db.Model(&Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"accepted_at":      time.Now(),
+			"accepted_user_id": userID,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to accept invitation: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package invitation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&Invitation{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestService_Create(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	inv, err := service.Create(tenantID, " Alice@Example.com ", []string{"member"}, "admin@example.com", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", inv.Email)
+	assert.Equal(t, StatusPending, inv.Status())
+	assert.WithinDuration(t, time.Now().Add(DefaultExpiry), inv.ExpiresAt, time.Minute)
+
+	// Only one pending invitation per email and tenant
+	_, err = service.Create(tenantID, "alice@example.com", nil, "", 0)
+	assert.ErrorIs(t, err, ErrAlreadyInvited)
+
+	// The same email can be invited to another tenant
+	_, err = service.Create(uuid.New(), "alice@example.com", nil, "", 0)
+	assert.NoError(t, err)
+
+	found, err := service.GetByToken(inv.Token)
+	require.NoError(t, err)
+	assert.Equal(t, inv.ID, found.ID)
+	assert.Equal(t, []string{"member"}, []string(found.Roles))
+}
+
+func TestService_CreateForOrganization(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+	orgID := uuid.New()
+
+	inv, err := service.CreateForOrganization(tenantID, orgID, "admin", "carol@example.com", "owner@example.com", time.Hour)
+	require.NoError(t, err)
+
+	found, err := service.GetByToken(inv.Token)
+	require.NoError(t, err)
+	require.NotNil(t, found.OrganizationID)
+	assert.Equal(t, orgID, *found.OrganizationID)
+	assert.Equal(t, "admin", found.OrganizationRole)
+	assert.Equal(t, &orgID, found.ToPublic().OrganizationID)
+
+	// Organization invitations share the one-pending-invitation rule of the tenant
+	_, err = service.Create(tenantID, "carol@example.com", nil, "", 0)
+	assert.ErrorIs(t, err, ErrAlreadyInvited)
+}
+
+func TestService_ResendAndRevoke(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	inv, err := service.Create(tenantID, "bob@example.com", nil, "", time.Hour)
+	require.NoError(t, err)
+
+	// Resending rotates the token so the earlier link stops working
+	resent, err := service.Resend(inv.ID, 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, inv.Token, resent.Token)
+	_, err = service.GetByToken(inv.Token)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, service.Revoke(inv.ID))
+	assert.ErrorIs(t, service.Revoke(inv.ID), ErrNotPending)
+	assert.ErrorIs(t, service.Revoke(uuid.New()), ErrNotFound)
+
+	_, err = service.Resend(inv.ID, 0)
+	assert.ErrorIs(t, err, ErrNotPending)
+
+	assert.ErrorIs(t, service.MarkAccepted(inv.ID, uuid.New()), ErrNotPending)
+}
+
+func TestService_List(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop())
+	tenantID := uuid.New()
+
+	pending, err := service.Create(tenantID, "pending@example.com", nil, "", 0)
+	require.NoError(t, err)
+	accepted, err := service.Create(tenantID, "accepted@example.com", nil, "", 0)
+	require.NoError(t, err)
+	require.NoError(t, service.MarkAccepted(accepted.ID, uuid.New()))
+	expired, err := service.Create(tenantID, "expired@example.com", nil, "", 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(expired).Update("expires_at", time.Now().Add(-time.Hour)).Error)
+
+	all, total, err := service.List(tenantID, "", 20, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+	assert.Equal(t, int64(3), total)
+
+	tests := []struct {
+		status string
+		id     uuid.UUID
+	}{
+		{StatusPending, pending.ID},
+		{StatusAccepted, accepted.ID},
+		{StatusExpired, expired.ID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			invitations, total, err := service.List(tenantID, tt.status, 20, 0)
+			require.NoError(t, err)
+			require.Len(t, invitations, 1)
+			assert.Equal(t, int64(1), total)
+			assert.Equal(t, tt.id, invitations[0].ID)
+			assert.Equal(t, tt.status, invitations[0].Status())
+		})
+	}
+
+	_, _, err = service.List(tenantID, "unknown", 20, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package legacyauth
+
+import (
+	"sync"
+	"time"
+)
+
+// Circuit breaker states reported for a connector
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// After breakerThreshold consecutive failures calls stop for breakerCooldown, so a down legacy
+// system does not hold every login for the full timeout; then a single probe call decides
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a call may go out now
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if now.Sub(b.openedAt) < breakerCooldown || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// success closes the circuit
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// failure counts a failed call, (re)opening the circuit at the threshold
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= breakerThreshold {
+		b.openedAt = now
+	}
+}
+
+func (b *breaker) state(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.failures < breakerThreshold:
+		return CircuitClosed
+	case b.probing || now.Sub(b.openedAt) >= breakerCooldown:
+		return CircuitHalfOpen
+	default:
+		return CircuitOpen
+	}
+}
@@ -0,0 +1,24 @@
+package legacyauth
+
+import "errors"
+
+// Legacy authentication-specific errors
+var (
+	// ErrNotFound is returned when a connector is not found, or the tenant has no enabled connector
+	ErrNotFound = errors.New("legacy auth connector not found")
+
+	// ErrAlreadyConfigured is returned when creating a second connector for a tenant
+	ErrAlreadyConfigured = errors.New("tenant already has a legacy auth connector")
+
+	// ErrInvalidURL is returned when the connector endpoint is not an absolute HTTPS URL
+	ErrInvalidURL = errors.New("legacy auth endpoint must be an https URL")
+
+	// ErrRejected is returned when the legacy system does not accept the credentials
+	ErrRejected = errors.New("legacy system rejected the credentials")
+
+	// ErrUserExists is returned when the email already belongs to a user of the tenant
+	ErrUserExists = errors.New("user already exists in the tenant")
+
+	// ErrUnavailable is returned when the legacy system fails, times out or the circuit breaker is open
+	ErrUnavailable = errors.New("legacy system unavailable")
+)
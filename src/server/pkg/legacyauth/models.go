@@ -0,0 +1,112 @@
+package legacyauth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Request headers of calls to the legacy endpoint
+const (
+	HeaderSignature = "X-Authway-Signature"
+	HeaderEvent     = "X-Authway-Event"
+
+	// EventLogin is the event header value of a credential check
+	EventLogin = "legacy_auth.login"
+)
+
+// DefaultTimeout bounds a call to the legacy endpoint when the connector sets none
+const DefaultTimeout = 5 * time.Second
+
+// Connector lazily migrates a tenant's users from a legacy system
+// When a login email is unknown, Authway asks the legacy endpoint to check the password and, if it
+// accepts, creates the user with that password so later logins are local
+type Connector struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID       uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Enabled        bool       `json:"enabled" gorm:"not null"`
+	URL            string     `json:"url" gorm:"not null"`
+	Secret         string     `json:"-" gorm:"not null"`
+	TimeoutSeconds int        `json:"timeout_seconds" gorm:"not null;default:5"`
+	MigratedCount  int64      `json:"migrated_count" gorm:"not null;default:0"`
+	RejectedCount  int64      `json:"rejected_count" gorm:"not null;default:0"`
+	FailureCount   int64      `json:"failure_count" gorm:"not null;default:0"`
+	LastMigratedAt *time.Time `json:"last_migrated_at"`
+	LastFailureAt  *time.Time `json:"last_failure_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Connector model
+func (Connector) TableName() string {
+	return "legacy_auth_connectors"
+}
+
+// BeforeCreate sets UUID if not provided
+func (c *Connector) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// Timeout returns how long a call to the legacy endpoint may take
+func (c *Connector) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// LoginRequest is the signed JSON body posted to the legacy endpoint
+// Timestamp (Unix seconds) lets the endpoint reject replayed requests
+type LoginRequest struct {
+	TenantID  uuid.UUID `json:"tenant_id"`
+	Email     string    `json:"email"`
+	Password  string    `json:"password"`
+	Timestamp int64     `json:"timestamp"`
+}
+
+// LoginResponse is the body the legacy endpoint answers 200 with when it accepts the credentials
+// 401, 403 and 404 reject them; any other status counts as a failure of the legacy system
+type LoginResponse struct {
+	User struct {
+		Email         string `json:"email"`
+		Name          string `json:"name"`
+		EmailVerified bool   `json:"email_verified"`
+		Picture       string `json:"picture"`
+	} `json:"user"`
+}
+
+// Report shows how far a tenant's lazy migration has progressed
+type Report struct {
+	ConnectorID    uuid.UUID  `json:"connector_id"`
+	TenantID       uuid.UUID  `json:"tenant_id"`
+	Enabled        bool       `json:"enabled"`
+	MigratedUsers  int64      `json:"migrated_users"`
+	TenantUsers    int64      `json:"tenant_users"`
+	RejectedLogins int64      `json:"rejected_logins"`
+	FailedCalls    int64      `json:"failed_calls"`
+	LastMigratedAt *time.Time `json:"last_migrated_at"`
+	LastFailureAt  *time.Time `json:"last_failure_at"`
+	Circuit        string     `json:"circuit"`
+}
+
+// CreateConnectorRequest represents the request to configure a tenant's legacy auth connector
+type CreateConnectorRequest struct {
+	TenantID       string `json:"tenant_id" validate:"required,uuid"`
+	URL            string `json:"url" validate:"required,url"`
+	Secret         string `json:"secret" validate:"required,min=32,max=1024"`
+	TimeoutSeconds int    `json:"timeout_seconds" validate:"omitempty,min=1,max=30"`
+	Enabled        *bool  `json:"enabled"`
+}
+
+// UpdateConnectorRequest represents the request to update a legacy auth connector
+// Omitted fields keep their value
+type UpdateConnectorRequest struct {
+	URL            *string `json:"url" validate:"omitempty,url"`
+	Secret         *string `json:"secret" validate:"omitempty,min=32,max=1024"`
+	TimeoutSeconds *int    `json:"timeout_seconds" validate:"omitempty,min=1,max=30"`
+	Enabled        *bool   `json:"enabled"`
+}
@@ -0,0 +1,338 @@
+package legacyauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"authway/src/server/pkg/webhook"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxResponseSize bounds the legacy endpoint's answer
+const maxResponseSize = 64 << 10
+
+// Service manages tenants' legacy auth connectors and migrates users through them at login
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateConnectorRequest) (*Connector, error)
+	Get(id uuid.UUID) (*Connector, error)
+	GetByTenant(tenantID uuid.UUID) (*Connector, error)
+	Update(id uuid.UUID, req *UpdateConnectorRequest) (*Connector, error)
+	Delete(id uuid.UUID) error
+	Report(id uuid.UUID) (*Report, error)
+
+	Migrate(ctx context.Context, tenantID uuid.UUID, email, password string) (*user.User, error)
+}
+
+type service struct {
+	db         *gorm.DB
+	logger     *zap.Logger
+	httpClient *http.Client
+	now        func() time.Time
+
+	// Circuit breakers by connector ID
+	breakers sync.Map
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:         db,
+		logger:     logger,
+		httpClient: &http.Client{},
+		now:        time.Now,
+	}
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateConnectorRequest) (*Connector, error) {
+	if _, err := s.GetByTenant(tenantID); err == nil {
+		return nil, ErrAlreadyConfigured
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	conn := &Connector{
+		TenantID:       tenantID,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+		URL:            strings.TrimSpace(req.URL),
+		Secret:         req.Secret,
+		TimeoutSeconds: req.TimeoutSeconds,
+	}
+	if err := validateURL(conn.URL); err != nil {
+		return nil, err
+	}
+	if conn.TimeoutSeconds == 0 {
+		conn.TimeoutSeconds = int(DefaultTimeout / time.Second)
+	}
+
+	if err := s.db.Create(conn).Error; err != nil {
+		s.logger.Error("Failed to create legacy auth connector", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create legacy auth connector: %w", err)
+	}
+
+	s.logger.Info("Legacy auth connector created",
+		zap.String("connector_id", conn.ID.String()),
+		zap.String("tenant_id", tenantID.String()))
+	return conn, nil
+}
+
+func (s *service) Get(id uuid.UUID) (*Connector, error) {
+	var conn Connector
+	if err := s.db.Where("id = ?", id).First(&conn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get legacy auth connector: %w", err)
+	}
+	return &conn, nil
+}
+
+func (s *service) GetByTenant(tenantID uuid.UUID) (*Connector, error) {
+	var conn Connector
+	if err := s.db.Where("tenant_id = ?", tenantID).First(&conn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get legacy auth connector: %w", err)
+	}
+	return &conn, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateConnectorRequest) (*Connector, error) {
+	conn, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		u := strings.TrimSpace(*req.URL)
+		if err := validateURL(u); err != nil {
+			return nil, err
+		}
+		updates["url"] = u
+	}
+	if req.Secret != nil {
+		updates["secret"] = *req.Secret
+	}
+	if req.TimeoutSeconds != nil {
+		updates["timeout_seconds"] = *req.TimeoutSeconds
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(conn).Updates(updates).Error; err != nil {
+			s.logger.Error("Failed to update legacy auth connector", zap.Error(err), zap.String("connector_id", id.String()))
+			return nil, fmt.Errorf("failed to update legacy auth connector: %w", err)
+		}
+	}
+
+	// A reconfigured endpoint gets a fresh circuit
+	if req.URL != nil || req.Enabled != nil {
+		s.breakers.Delete(id)
+	}
+	return s.Get(id)
+}
+
+// Delete ends the lazy migration; users migrated so far stay in the tenant
+func (s *service) Delete(id uuid.UUID) error {
+	result := s.db.Where("id = ?", id).Delete(&Connector{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete legacy auth connector: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	s.breakers.Delete(id)
+	return nil
+}
+
+// Report returns the migration progress of a connector's tenant
+func (s *service) Report(id uuid.UUID) (*Report, error) {
+	conn, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenantUsers int64
+	if err := s.db.Model(&user.User{}).Where("tenant_id = ?", conn.TenantID).Count(&tenantUsers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count tenant users: %w", err)
+	}
+
+	return &Report{
+		ConnectorID:    conn.ID,
+		TenantID:       conn.TenantID,
+		Enabled:        conn.Enabled,
+		MigratedUsers:  conn.MigratedCount,
+		TenantUsers:    tenantUsers,
+		RejectedLogins: conn.RejectedCount,
+		FailedCalls:    conn.FailureCount,
+		LastMigratedAt: conn.LastMigratedAt,
+		LastFailureAt:  conn.LastFailureAt,
+		Circuit:        s.breaker(conn.ID).state(s.now()),
+	}, nil
+}
+
+// Migrate checks credentials of an email unknown to the tenant with its legacy system
+// When the legacy system accepts them, the user is created with the password hashed locally,
+// so the next login no longer involves the legacy system
+func (s *service) Migrate(ctx context.Context, tenantID uuid.UUID, email, password string) (*user.User, error) {
+	conn, err := s.GetByTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !conn.Enabled {
+		return nil, ErrNotFound
+	}
+	if email == "" || password == "" {
+		return nil, ErrRejected
+	}
+
+	var existing int64
+	if err := s.db.Model(&user.User{}).Where("tenant_id = ? AND LOWER(email) = ?", tenantID, strings.ToLower(email)).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if existing > 0 {
+		return nil, ErrUserExists
+	}
+
+	cb := s.breaker(conn.ID)
+	if !cb.allow(s.now()) {
+		return nil, fmt.Errorf("%w: circuit open", ErrUnavailable)
+	}
+
+	profile, err := s.call(ctx, conn, email, password)
+	switch {
+	case errors.Is(err, ErrRejected):
+		cb.success()
+		s.count(conn, "rejected_count", "")
+		return nil, err
+	case err != nil:
+		cb.failure(s.now())
+		s.count(conn, "failure_count", "last_failure_at")
+		s.logger.Warn("Legacy auth call failed", zap.Error(err), zap.String("connector_id", conn.ID.String()))
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	cb.success()
+
+	hashedPassword, err := user.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	migrated := &user.User{
+		TenantID:      tenantID,
+		Email:         email,
+		PasswordHash:  hashedPassword,
+		EmailVerified: profile.User.EmailVerified,
+		Active:        true,
+		Provider:      user.ProviderLocal,
+	}
+	if name := strings.TrimSpace(profile.User.Name); name != "" {
+		migrated.Name = &name
+	}
+	if profile.User.Picture != "" {
+		migrated.Picture = &profile.User.Picture
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(migrated).Error; err != nil {
+			return fmt.Errorf("failed to create migrated user: %w", err)
+		}
+		return tx.Model(&Connector{}).Where("id = ?", conn.ID).Updates(map[string]interface{}{
+			"migrated_count":   gorm.Expr("migrated_count + 1"),
+			"last_migrated_at": s.now(),
+		}).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to migrate legacy user", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, err
+	}
+
+	s.logger.Info("User migrated from legacy system",
+		zap.String("user_id", migrated.ID.String()),
+		zap.String("tenant_id", tenantID.String()))
+	return migrated, nil
+}
+
+// call posts the signed credentials to the legacy endpoint
+func (s *service) call(ctx context.Context, conn *Connector, email, password string) (*LoginResponse, error) {
+	body, err := json.Marshal(&LoginRequest{
+		TenantID:  conn.TenantID,
+		Email:     email,
+		Password:  password,
+		Timestamp: s.now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, conn.Timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conn.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, EventLogin)
+	req.Header.Set(HeaderSignature, webhook.Sign(conn.Secret, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, ErrRejected
+	default:
+		return nil, fmt.Errorf("legacy endpoint returned status %d", resp.StatusCode)
+	}
+
+	var profile LoginResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("invalid legacy endpoint response: %w", err)
+	}
+	// The legacy system answers for the email that was asked about, never another account
+	if !strings.EqualFold(profile.User.Email, email) {
+		return nil, errors.New("legacy endpoint answered for another email")
+	}
+	return &profile, nil
+}
+
+// count increments a progress counter, stamping timeColumn when set; the login outcome does not depend on it
+func (s *service) count(conn *Connector, column, timeColumn string) {
+	updates := map[string]interface{}{column: gorm.Expr(column + " + 1")}
+	if timeColumn != "" {
+		updates[timeColumn] = s.now()
+	}
+	if err := s.db.Model(&Connector{}).Where("id = ?", conn.ID).Updates(updates).Error; err != nil {
+		s.logger.Warn("Failed to record legacy auth outcome", zap.Error(err), zap.String("connector_id", conn.ID.String()))
+	}
+}
+
+func (s *service) breaker(id uuid.UUID) *breaker {
+	cb, _ := s.breakers.LoadOrStore(id, &breaker{})
+	return cb.(*breaker)
+}
+
+// validateURL requires HTTPS: the endpoint receives users' plaintext passwords
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package legacyauth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/user"
+	"authway/src/server/pkg/webhook"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// legacySystem answers credential checks like a legacy user database would
+type legacySystem struct {
+	server *httptest.Server
+	calls  atomic.Int32
+	status atomic.Int32 // forced status, 0 to check credentials
+	email  string       // email answered with, "" to echo the request
+}
+
+func newLegacySystem(t *testing.T) *legacySystem {
+	legacy := &legacySystem{}
+	legacy.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		legacy.calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != webhook.Sign(testSecret, body) || r.Header.Get(HeaderEvent) != EventLogin {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status := legacy.status.Load(); status != 0 {
+			if status == http.StatusGatewayTimeout {
+				<-r.Context().Done()
+			}
+			w.WriteHeader(int(status))
+			return
+		}
+
+		var req LoginRequest
+		if json.Unmarshal(body, &req) != nil || req.Password != "legacy-password" || req.Timestamp == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		email := req.Email
+		if legacy.email != "" {
+			email = legacy.email
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"user": map[string]interface{}{"email": email, "name": "Legacy User", "email_verified": true},
+		})
+	}))
+	t.Cleanup(legacy.server.Close)
+	return legacy
+}
+
+func setupTestService(t *testing.T, legacy *legacySystem) (*service, *gorm.DB, *Connector) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&user.User{}, &Connector{}))
+
+	svc := NewService(db, zap.NewNop()).(*service)
+	svc.httpClient = legacy.server.Client()
+
+	conn, err := svc.Create(uuid.New(), &CreateConnectorRequest{URL: legacy.server.URL, Secret: testSecret})
+	require.NoError(t, err)
+	return svc, db, conn
+}
+
+func TestService_Create(t *testing.T) {
+	legacy := newLegacySystem(t)
+	svc, _, conn := setupTestService(t, legacy)
+	assert.True(t, conn.Enabled)
+	assert.Equal(t, DefaultTimeout, conn.Timeout())
+
+	_, err := svc.Create(conn.TenantID, &CreateConnectorRequest{URL: legacy.server.URL, Secret: testSecret})
+	assert.ErrorIs(t, err, ErrAlreadyConfigured)
+
+	// Passwords never travel over plain HTTP
+	_, err = svc.Create(uuid.New(), &CreateConnectorRequest{URL: "http://legacy.example.com/auth", Secret: testSecret})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	plain := "http://legacy.example.com/auth"
+	_, err = svc.Update(conn.ID, &UpdateConnectorRequest{URL: &plain})
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+func TestService_Migrate(t *testing.T) {
+	legacy := newLegacySystem(t)
+	svc, _, conn := setupTestService(t, legacy)
+	ctx := context.Background()
+
+	_, err := svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "wrong")
+	assert.ErrorIs(t, err, ErrRejected)
+
+	migrated, err := svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "legacy-password")
+	require.NoError(t, err)
+	assert.Equal(t, conn.TenantID, migrated.TenantID)
+	assert.Equal(t, "Legacy User", *migrated.Name)
+	assert.True(t, migrated.EmailVerified)
+	assert.False(t, user.PasswordNeedsRehash(migrated.PasswordHash))
+	ok, err := user.CheckPassword(migrated.PasswordHash, "legacy-password")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Once migrated, the user signs in locally
+	calls := legacy.calls.Load()
+	_, err = svc.Migrate(ctx, conn.TenantID, "Alice@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.Equal(t, calls, legacy.calls.Load())
+
+	// Tenants without a connector, or with a disabled one, have nothing to migrate from
+	_, err = svc.Migrate(ctx, uuid.New(), "bob@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrNotFound)
+	disabled := false
+	_, err = svc.Update(conn.ID, &UpdateConnectorRequest{Enabled: &disabled})
+	require.NoError(t, err)
+	_, err = svc.Migrate(ctx, conn.TenantID, "bob@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	report, err := svc.Report(conn.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.MigratedUsers)
+	assert.Equal(t, int64(1), report.TenantUsers)
+	assert.Equal(t, int64(1), report.RejectedLogins)
+	assert.NotNil(t, report.LastMigratedAt)
+	assert.Equal(t, CircuitClosed, report.Circuit)
+}
+
+func TestService_MigrateRejectsAnswerForAnotherEmail(t *testing.T) {
+	legacy := newLegacySystem(t)
+	legacy.email = "mallory@legacy.com"
+	svc, db, conn := setupTestService(t, legacy)
+
+	_, err := svc.Migrate(context.Background(), conn.TenantID, "alice@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	var count int64
+	require.NoError(t, db.Model(&user.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestService_CircuitBreaker(t *testing.T) {
+	legacy := newLegacySystem(t)
+	svc, _, conn := setupTestService(t, legacy)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	legacy.status.Store(http.StatusInternalServerError)
+	for i := 0; i < breakerThreshold; i++ {
+		_, err := svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "legacy-password")
+		assert.ErrorIs(t, err, ErrUnavailable)
+	}
+
+	// Open: logins fail fast without calling the legacy system
+	calls := legacy.calls.Load()
+	_, err := svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, calls, legacy.calls.Load())
+	report, err := svc.Report(conn.ID)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitOpen, report.Circuit)
+	assert.Equal(t, int64(breakerThreshold), report.FailedCalls)
+
+	// After the cooldown one probe goes out; its success closes the circuit
+	now = now.Add(breakerCooldown)
+	legacy.status.Store(0)
+	_, err = svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "legacy-password")
+	require.NoError(t, err)
+	assert.Equal(t, calls+1, legacy.calls.Load())
+	report, err = svc.Report(conn.ID)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, report.Circuit)
+}
+
+func TestService_MigrateTimeout(t *testing.T) {
+	legacy := newLegacySystem(t)
+	legacy.status.Store(http.StatusGatewayTimeout)
+	svc, _, conn := setupTestService(t, legacy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := svc.Migrate(ctx, conn.TenantID, "alice@legacy.com", "legacy-password")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Less(t, time.Since(start), DefaultTimeout)
+}
@@ -0,0 +1,30 @@
+package organization
+
+import "errors"
+
+// Organization-specific errors
+var (
+	// ErrNotFound is returned when an organization is not found
+	ErrNotFound = errors.New("organization not found")
+
+	// ErrDuplicateSlug is returned when an organization with the same slug already exists in the tenant
+	ErrDuplicateSlug = errors.New("organization with this slug already exists")
+
+	// ErrMemberNotFound is returned when the user is not a member of the organization
+	ErrMemberNotFound = errors.New("organization member not found")
+
+	// ErrUserNotInTenant is returned when adding a user from another tenant
+	ErrUserNotInTenant = errors.New("user not found in tenant")
+
+	// ErrLastOwner is returned when removing or demoting the only owner of an organization
+	ErrLastOwner = errors.New("organization must keep at least one owner")
+
+	// ErrDomainNotFound is returned when a domain is not found
+	ErrDomainNotFound = errors.New("organization domain not found")
+
+	// ErrDomainTaken is returned when another organization of the tenant already claims the domain
+	ErrDomainTaken = errors.New("domain is already claimed by an organization")
+
+	// ErrDomainNotVerified is returned when the DNS verification record is missing or wrong
+	ErrDomainNotVerified = errors.New("domain verification record not found")
+)
@@ -0,0 +1,196 @@
+package organization
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Member roles
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// VerificationRecordPrefix is the DNS name prefix of the domain verification TXT record
+const VerificationRecordPrefix = "_authway-verification."
+
+// Organization is a customer account within a tenant (B2B)
+// Users stay tenant users; membership links them to one or more organizations
+type Organization struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_organizations_tenant_slug"`
+	Name     string    `json:"name" gorm:"not null"`
+	Slug     string    `json:"slug" gorm:"not null;uniqueIndex:idx_organizations_tenant_slug"`
+
+	// Branding overrides; empty values inherit the tenant's branding
+	Logo         string `json:"logo"`
+	PrimaryColor string `json:"primary_color"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Organization model
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// BeforeCreate sets UUID if not provided
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// PublicOrganization is the organization shown on the login page, with effective branding
+type PublicOrganization struct {
+	ID           uuid.UUID `json:"id"`
+	Name         string    `json:"name"`
+	Slug         string    `json:"slug"`
+	Logo         string    `json:"logo"`
+	PrimaryColor string    `json:"primary_color"`
+}
+
+// ToPublic converts Organization to PublicOrganization, falling back to the tenant's branding
+func (o *Organization) ToPublic(t *tenant.Tenant) PublicOrganization {
+	public := PublicOrganization{
+		ID:           o.ID,
+		Name:         o.Name,
+		Slug:         o.Slug,
+		Logo:         o.Logo,
+		PrimaryColor: o.PrimaryColor,
+	}
+	if t != nil {
+		if public.Logo == "" {
+			public.Logo = t.Logo
+		}
+		if public.PrimaryColor == "" {
+			public.PrimaryColor = t.PrimaryColor
+		}
+	}
+	return public
+}
+
+// Member links a tenant user to an organization with an organization role
+type Member struct {
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;primaryKey"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	TenantID       uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Role           string    `json:"role" gorm:"not null;default:member"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Member model
+func (Member) TableName() string {
+	return "organization_members"
+}
+
+// MemberInfo is an organization member as returned by the member list
+type MemberInfo struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     *string   `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Domain is an email domain claimed by an organization
+// Once verified, tenant users with an email at the domain join the organization automatically
+type Domain struct {
+	ID                uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	OrganizationID    uuid.UUID  `json:"organization_id" gorm:"type:uuid;not null;index"`
+	TenantID          uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_organization_domains_tenant_domain"`
+	Domain            string     `json:"domain" gorm:"not null;uniqueIndex:idx_organization_domains_tenant_domain"`
+	VerificationToken string     `json:"verification_token" gorm:"not null"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for Domain model
+func (Domain) TableName() string {
+	return "organization_domains"
+}
+
+// BeforeCreate sets UUID and verification token if not provided
+func (d *Domain) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.VerificationToken == "" {
+		d.VerificationToken = uuid.New().String()
+	}
+	return nil
+}
+
+// IsVerified reports whether the domain ownership has been proven
+func (d *Domain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// RecordName is the DNS name where the verification TXT record must be published
+func (d *Domain) RecordName() string {
+	return VerificationRecordPrefix + d.Domain
+}
+
+// RecordValue is the expected content of the verification TXT record
+func (d *Domain) RecordValue() string {
+	return fmt.Sprintf("authway-verification=%s", d.VerificationToken)
+}
+
+// emailDomain returns the lower-cased domain part of an email address
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// CreateOrganizationRequest represents the request to create an organization
+type CreateOrganizationRequest struct {
+	TenantID     string `json:"tenant_id" validate:"required,uuid"`
+	Name         string `json:"name" validate:"required,min=2,max=255"`
+	Slug         string `json:"slug" validate:"required,min=2,max=100"`
+	Logo         string `json:"logo" validate:"omitempty,url"`
+	PrimaryColor string `json:"primary_color" validate:"omitempty,hexcolor"`
+}
+
+// UpdateOrganizationRequest represents the request to update an organization
+// Branding fields set to "" reset the override to the tenant's branding
+type UpdateOrganizationRequest struct {
+	Name         string  `json:"name" validate:"omitempty,min=2,max=255"`
+	Logo         *string `json:"logo"`
+	PrimaryColor *string `json:"primary_color"`
+}
+
+// AddMemberRequest represents the request to add a tenant user to an organization
+type AddMemberRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+	Role   string `json:"role" validate:"omitempty,oneof=owner admin member"`
+}
+
+// UpdateMemberRequest represents the request to change a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// AddDomainRequest represents the request to claim an email domain
+type AddDomainRequest struct {
+	Domain string `json:"domain" validate:"required,fqdn"`
+}
+
+// InviteRequest represents the request to invite a new user into an organization
+type InviteRequest struct {
+	Email          string `json:"email" validate:"required,email"`
+	Role           string `json:"role" validate:"omitempty,oneof=owner admin member"`
+	InvitedBy      string `json:"invited_by" validate:"max=255"`
+	ExpiresInHours int    `json:"expires_in_hours" validate:"omitempty,min=1,max=720"`
+}
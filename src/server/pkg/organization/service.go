@@ -0,0 +1,446 @@
+package organization
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateOrganizationRequest) (*Organization, error)
+	Get(id uuid.UUID) (*Organization, error)
+	Find(tenantID uuid.UUID, idOrSlug string) (*Organization, error)
+	List(tenantID uuid.UUID, limit, offset int) ([]*Organization, int64, error)
+	Update(id uuid.UUID, req *UpdateOrganizationRequest) (*Organization, error)
+	Delete(id uuid.UUID) error
+
+	AddMember(orgID, userID uuid.UUID, role string) (*Member, error)
+	UpdateMemberRole(orgID, userID uuid.UUID, role string) (*Member, error)
+	RemoveMember(orgID, userID uuid.UUID) error
+	GetMember(orgID, userID uuid.UUID) (*Member, error)
+	ListMembers(orgID uuid.UUID, limit, offset int) ([]*MemberInfo, int64, error)
+	UserOrganizations(userID uuid.UUID) ([]*Organization, error)
+
+	AddDomain(orgID uuid.UUID, domain string) (*Domain, error)
+	ListDomains(orgID uuid.UUID) ([]*Domain, error)
+	VerifyDomain(orgID, domainID uuid.UUID) (*Domain, error)
+	RemoveDomain(orgID, domainID uuid.UUID) error
+	JoinByEmailDomain(tenantID, userID uuid.UUID, email string) ([]*Organization, error)
+}
+
+type service struct {
+	db        *gorm.DB
+	logger    *zap.Logger
+	lookupTXT func(name string) ([]string, error)
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:        db,
+		logger:    logger,
+		lookupTXT: net.LookupTXT,
+	}
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateOrganizationRequest) (*Organization, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if err := s.checkSlugAvailable(tenantID, slug); err != nil {
+		return nil, err
+	}
+
+	org := &Organization{
+		TenantID:     tenantID,
+		Name:         strings.TrimSpace(req.Name),
+		Slug:         slug,
+		Logo:         req.Logo,
+		PrimaryColor: req.PrimaryColor,
+	}
+
+	if err := s.db.Create(org).Error; err != nil {
+		s.logger.Error("Failed to create organization", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	s.logger.Info("Organization created",
+		zap.String("organization_id", org.ID.String()),
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("slug", org.Slug))
+
+	return org, nil
+}
+
+func (s *service) Get(id uuid.UUID) (*Organization, error) {
+	var org Organization
+	if err := s.db.Where("id = ?", id).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &org, nil
+}
+
+// Find looks up an organization of the tenant by ID or slug, as given in login requests
+func (s *service) Find(tenantID uuid.UUID, idOrSlug string) (*Organization, error) {
+	query := s.db.Where("tenant_id = ?", tenantID)
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("slug = ?", strings.ToLower(strings.TrimSpace(idOrSlug)))
+	}
+
+	var org Organization
+	if err := query.First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find organization: %w", err)
+	}
+	return &org, nil
+}
+
+func (s *service) List(tenantID uuid.UUID, limit, offset int) ([]*Organization, int64, error) {
+	query := s.db.Model(&Organization{}).Where("tenant_id = ?", tenantID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count organizations: %w", err)
+	}
+
+	var orgs []*Organization
+	if err := query.Order("name ASC").Limit(limit).Offset(offset).Find(&orgs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return orgs, total, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateOrganizationRequest) (*Organization, error) {
+	org, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Logo != nil {
+		updates["logo"] = *req.Logo
+	}
+	if req.PrimaryColor != nil {
+		updates["primary_color"] = *req.PrimaryColor
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(org).Updates(updates).Error; err != nil {
+			s.logger.Error("Failed to update organization", zap.Error(err), zap.String("organization_id", id.String()))
+			return nil, fmt.Errorf("failed to update organization: %w", err)
+		}
+	}
+
+	return s.Get(id)
+}
+
+// Delete removes an organization with its memberships and domains; the users themselves remain in the tenant
+func (s *service) Delete(id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ?", id).Delete(&Member{}).Error; err != nil {
+			return fmt.Errorf("failed to delete organization members: %w", err)
+		}
+		if err := tx.Where("organization_id = ?", id).Delete(&Domain{}).Error; err != nil {
+			return fmt.Errorf("failed to delete organization domains: %w", err)
+		}
+		result := tx.Where("id = ?", id).Delete(&Organization{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete organization: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// AddMember adds a user of the organization's tenant; adding an existing member updates the role
+func (s *service) AddMember(orgID, userID uuid.UUID, role string) (*Member, error) {
+	org, err := s.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = RoleMember
+	}
+
+	var users int64
+	if err := s.db.Table("users").
+		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", userID, org.TenantID).
+		Count(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+	if users == 0 {
+		return nil, ErrUserNotInTenant
+	}
+
+	if existing, err := s.GetMember(orgID, userID); err == nil {
+		if existing.Role == role {
+			return existing, nil
+		}
+		return s.UpdateMemberRole(orgID, userID, role)
+	}
+
+	member := &Member{OrganizationID: orgID, UserID: userID, TenantID: org.TenantID, Role: role}
+	if err := s.db.Create(member).Error; err != nil {
+		s.logger.Error("Failed to add organization member", zap.Error(err), zap.String("organization_id", orgID.String()))
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	return member, nil
+}
+
+func (s *service) UpdateMemberRole(orgID, userID uuid.UUID, role string) (*Member, error) {
+	member, err := s.GetMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Role == RoleOwner && role != RoleOwner {
+		if err := s.checkOtherOwner(orgID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Model(member).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).
+		Update("role", role).Error; err != nil {
+		return nil, fmt.Errorf("failed to update organization member: %w", err)
+	}
+	member.Role = role
+	return member, nil
+}
+
+func (s *service) RemoveMember(orgID, userID uuid.UUID) error {
+	member, err := s.GetMember(orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if member.Role == RoleOwner {
+		if err := s.checkOtherOwner(orgID, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&Member{}).Error; err != nil {
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	return nil
+}
+
+func (s *service) GetMember(orgID, userID uuid.UUID) (*Member, error) {
+	var member Member
+	if err := s.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemberNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return &member, nil
+}
+
+// ListMembers returns a page of the organization's members ordered by email
+func (s *service) ListMembers(orgID uuid.UUID, limit, offset int) ([]*MemberInfo, int64, error) {
+	if _, err := s.Get(orgID); err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.Table("organization_members").
+		Joins("JOIN users ON users.id = organization_members.user_id AND users.deleted_at IS NULL").
+		Where("organization_members.organization_id = ?", orgID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count organization members: %w", err)
+	}
+
+	members := []*MemberInfo{}
+	if err := query.
+		Select("users.id AS user_id, users.email, users.name, organization_members.role, organization_members.created_at AS joined_at").
+		Order("users.email ASC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&members).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	return members, total, nil
+}
+
+// UserOrganizations returns the organizations a user belongs to
+func (s *service) UserOrganizations(userID uuid.UUID) ([]*Organization, error) {
+	var orgs []*Organization
+	if err := s.db.
+		Where("id IN (?)", s.db.Model(&Member{}).Select("organization_id").Where("user_id = ?", userID)).
+		Order("name ASC").
+		Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list user organizations: %w", err)
+	}
+	return orgs, nil
+}
+
+// AddDomain claims an email domain for the organization; it must be verified before users join automatically
+func (s *service) AddDomain(orgID uuid.UUID, domain string) (*Domain, error) {
+	org, err := s.Get(orgID)
+	if err != nil {
+		return nil, err
+	}
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+
+	var claimed int64
+	if err := s.db.Model(&Domain{}).
+		Where("tenant_id = ? AND domain = ?", org.TenantID, domain).
+		Count(&claimed).Error; err != nil {
+		return nil, fmt.Errorf("failed to check domain: %w", err)
+	}
+	if claimed > 0 {
+		return nil, ErrDomainTaken
+	}
+
+	d := &Domain{OrganizationID: orgID, TenantID: org.TenantID, Domain: domain}
+	if err := s.db.Create(d).Error; err != nil {
+		return nil, fmt.Errorf("failed to add domain: %w", err)
+	}
+	return d, nil
+}
+
+func (s *service) ListDomains(orgID uuid.UUID) ([]*Domain, error) {
+	var domains []*Domain
+	if err := s.db.Where("organization_id = ?", orgID).Order("domain ASC").Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+	return domains, nil
+}
+
+// VerifyDomain checks the domain's DNS TXT record and marks it verified
+func (s *service) VerifyDomain(orgID, domainID uuid.UUID) (*Domain, error) {
+	d, err := s.getDomain(orgID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if d.IsVerified() {
+		return d, nil
+	}
+
+	records, err := s.lookupTXT(d.RecordName())
+	if err != nil {
+		s.logger.Info("Domain verification lookup failed", zap.Error(err), zap.String("domain", d.Domain))
+		return nil, ErrDomainNotVerified
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == d.RecordValue() {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrDomainNotVerified
+	}
+
+	now := time.Now()
+	if err := s.db.Model(d).Update("verified_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to verify domain: %w", err)
+	}
+	d.VerifiedAt = &now
+
+	s.logger.Info("Organization domain verified",
+		zap.String("organization_id", d.OrganizationID.String()),
+		zap.String("domain", d.Domain))
+
+	return d, nil
+}
+
+func (s *service) RemoveDomain(orgID, domainID uuid.UUID) error {
+	result := s.db.Where("id = ? AND organization_id = ?", domainID, orgID).Delete(&Domain{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove domain: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// JoinByEmailDomain adds the user to the organizations that verified the domain of their email
+// and returns the organizations joined by this call
+func (s *service) JoinByEmailDomain(tenantID, userID uuid.UUID, email string) ([]*Organization, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, nil
+	}
+
+	var domains []*Domain
+	if err := s.db.Where("tenant_id = ? AND domain = ? AND verified_at IS NOT NULL", tenantID, domain).
+		Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up verified domains: %w", err)
+	}
+
+	var joined []*Organization
+	for _, d := range domains {
+		if _, err := s.GetMember(d.OrganizationID, userID); err == nil {
+			continue
+		}
+		if _, err := s.AddMember(d.OrganizationID, userID, RoleMember); err != nil {
+			return joined, err
+		}
+		org, err := s.Get(d.OrganizationID)
+		if err != nil {
+			return joined, err
+		}
+		joined = append(joined, org)
+	}
+	return joined, nil
+}
+
+func (s *service) getDomain(orgID, id uuid.UUID) (*Domain, error) {
+	var d Domain
+	if err := s.db.Where("id = ? AND organization_id = ?", id, orgID).First(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("failed to get domain: %w", err)
+	}
+	return &d, nil
+}
+
+func (s *service) checkSlugAvailable(tenantID uuid.UUID, slug string) error {
+	var count int64
+	if err := s.db.Model(&Organization{}).
+		Where("tenant_id = ? AND slug = ?", tenantID, slug).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check organization slug: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateSlug
+	}
+	return nil
+}
+
+// checkOtherOwner ensures the organization has an owner besides userID
+func (s *service) checkOtherOwner(orgID, userID uuid.UUID) error {
+	var owners int64
+	if err := s.db.Model(&Member{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, RoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return fmt.Errorf("failed to count owners: %w", err)
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package organization
+
+import (
+	"errors"
+	"testing"
+
+	"authway/src/server/pkg/tenant"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestService(t *testing.T) (*service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&user.User{}, &Organization{}, &Member{}, &Domain{})
+	require.NoError(t, err)
+
+	return NewService(db, zap.NewNop()).(*service), db
+}
+
+func createUser(t *testing.T, db *gorm.DB, tenantID uuid.UUID, email string) uuid.UUID {
+	u := &user.User{TenantID: tenantID, Email: email, PasswordHash: "x"}
+	require.NoError(t, db.Create(u).Error)
+	return u.ID
+}
+
+func TestService_CreateAndFind(t *testing.T) {
+	service, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	org, err := service.Create(tenantID, &CreateOrganizationRequest{Name: "Acme Corp", Slug: "Acme"})
+	require.NoError(t, err)
+	assert.Equal(t, "acme", org.Slug)
+
+	_, err = service.Create(tenantID, &CreateOrganizationRequest{Name: "Acme 2", Slug: "acme"})
+	assert.ErrorIs(t, err, ErrDuplicateSlug)
+	_, err = service.Create(uuid.New(), &CreateOrganizationRequest{Name: "Acme", Slug: "acme"})
+	assert.NoError(t, err)
+
+	found, err := service.Find(tenantID, "ACME")
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, found.ID)
+	found, err = service.Find(tenantID, org.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, found.ID)
+
+	// Organizations are only found within their tenant
+	_, err = service.Find(uuid.New(), org.ID.String())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestOrganization_ToPublicInheritsTenantBranding(t *testing.T) {
+	parent := &tenant.Tenant{Logo: "https://tenant.example.com/logo.png", PrimaryColor: "#4F46E5"}
+
+	org := &Organization{Name: "Acme", Slug: "acme", PrimaryColor: "#FF0000"}
+	public := org.ToPublic(parent)
+	assert.Equal(t, "https://tenant.example.com/logo.png", public.Logo)
+	assert.Equal(t, "#FF0000", public.PrimaryColor)
+}
+
+func TestService_Members(t *testing.T) {
+	service, db := setupTestService(t)
+	tenantID := uuid.New()
+	alice := createUser(t, db, tenantID, "alice@acme.com")
+	bob := createUser(t, db, tenantID, "bob@acme.com")
+	outsider := createUser(t, db, uuid.New(), "eve@acme.com")
+
+	org, err := service.Create(tenantID, &CreateOrganizationRequest{Name: "Acme", Slug: "acme"})
+	require.NoError(t, err)
+
+	_, err = service.AddMember(org.ID, alice, RoleOwner)
+	require.NoError(t, err)
+	member, err := service.AddMember(org.ID, bob, "")
+	require.NoError(t, err)
+	assert.Equal(t, RoleMember, member.Role)
+
+	_, err = service.AddMember(org.ID, outsider, RoleMember)
+	assert.ErrorIs(t, err, ErrUserNotInTenant)
+
+	// The only owner cannot be removed or demoted
+	assert.ErrorIs(t, service.RemoveMember(org.ID, alice), ErrLastOwner)
+	_, err = service.UpdateMemberRole(org.ID, alice, RoleAdmin)
+	assert.ErrorIs(t, err, ErrLastOwner)
+
+	_, err = service.UpdateMemberRole(org.ID, bob, RoleOwner)
+	require.NoError(t, err)
+	require.NoError(t, service.RemoveMember(org.ID, alice))
+
+	members, total, err := service.ListMembers(org.ID, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, members, 1)
+	assert.Equal(t, "bob@acme.com", members[0].Email)
+	assert.Equal(t, RoleOwner, members[0].Role)
+
+	orgs, err := service.UserOrganizations(bob)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	assert.Equal(t, org.ID, orgs[0].ID)
+}
+
+func TestService_DomainVerificationAndAutoJoin(t *testing.T) {
+	service, db := setupTestService(t)
+	tenantID := uuid.New()
+	alice := createUser(t, db, tenantID, "alice@Acme.com")
+
+	org, err := service.Create(tenantID, &CreateOrganizationRequest{Name: "Acme", Slug: "acme"})
+	require.NoError(t, err)
+
+	domain, err := service.AddDomain(org.ID, "ACME.com.")
+	require.NoError(t, err)
+	assert.Equal(t, "acme.com", domain.Domain)
+	assert.Equal(t, "_authway-verification.acme.com", domain.RecordName())
+
+	other, err := service.Create(tenantID, &CreateOrganizationRequest{Name: "Other", Slug: "other"})
+	require.NoError(t, err)
+	_, err = service.AddDomain(other.ID, "acme.com")
+	assert.ErrorIs(t, err, ErrDomainTaken)
+
+	// Unverified domains do not grant membership
+	joined, err := service.JoinByEmailDomain(tenantID, alice, "alice@Acme.com")
+	require.NoError(t, err)
+	assert.Empty(t, joined)
+
+	records := map[string][]string{}
+	service.lookupTXT = func(name string) ([]string, error) {
+		if values, ok := records[name]; ok {
+			return values, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	_, err = service.VerifyDomain(org.ID, domain.ID)
+	assert.ErrorIs(t, err, ErrDomainNotVerified)
+	records[domain.RecordName()] = []string{"v=spf1 -all", "authway-verification=wrong"}
+	_, err = service.VerifyDomain(org.ID, domain.ID)
+	assert.ErrorIs(t, err, ErrDomainNotVerified)
+
+	records[domain.RecordName()] = append(records[domain.RecordName()], domain.RecordValue())
+	verified, err := service.VerifyDomain(org.ID, domain.ID)
+	require.NoError(t, err)
+	assert.True(t, verified.IsVerified())
+
+	// Domains are managed through their own organization only
+	_, err = service.VerifyDomain(other.ID, domain.ID)
+	assert.ErrorIs(t, err, ErrDomainNotFound)
+
+	joined, err = service.JoinByEmailDomain(tenantID, alice, "alice@Acme.com")
+	require.NoError(t, err)
+	require.Len(t, joined, 1)
+	assert.Equal(t, org.ID, joined[0].ID)
+
+	member, err := service.GetMember(org.ID, alice)
+	require.NoError(t, err)
+	assert.Equal(t, RoleMember, member.Role)
+
+	// Joining again is a no-op
+	joined, err = service.JoinByEmailDomain(tenantID, alice, "alice@acme.com")
+	require.NoError(t, err)
+	assert.Empty(t, joined)
+}
@@ -0,0 +1,15 @@
+package privacy
+
+import "errors"
+
+// Privacy-specific errors
+var (
+	// ErrNotFound is returned when the user has no pending erasure request
+	ErrNotFound = errors.New("erasure request not found")
+
+	// ErrErasurePending is returned when requesting erasure of a user who already has one scheduled
+	ErrErasurePending = errors.New("erasure is already scheduled for this user")
+
+	// ErrInvalidMode is returned for an erasure mode other than delete or pseudonymize
+	ErrInvalidMode = errors.New("invalid erasure mode")
+)
@@ -0,0 +1,193 @@
+package privacy
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Request types
+const (
+	TypeExport  = "export"
+	TypeErasure = "erasure"
+)
+
+// Request statuses; exports are completed when they are downloaded
+const (
+	StatusScheduled = "scheduled"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// Erasure modes
+const (
+	// ModeDelete removes the user row
+	ModeDelete = "delete"
+
+	// ModePseudonymize keeps a deactivated, soft-deleted row without personal data,
+	// so references from other systems to the user ID stay resolvable
+	ModePseudonymize = "pseudonymize"
+)
+
+// Who made a request
+const (
+	RequestedByUser  = "user"
+	RequestedByAdmin = "admin"
+)
+
+// Request is a data subject request and its outcome
+// It holds no personal data, so it stays as the anonymized audit trail after the user is erased
+type Request struct {
+	ID           uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	Type         string     `json:"type" gorm:"not null"`
+	Status       string     `json:"status" gorm:"not null"`
+	Mode         string     `json:"mode,omitempty"` // Erasure only
+	RequestedBy  string     `json:"requested_by" gorm:"not null"`
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"` // When a scheduled erasure runs
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	Error        string     `json:"error,omitempty"` // Last failed erasure attempt; retried on the next run
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for Request model
+func (Request) TableName() string {
+	return "privacy_requests"
+}
+
+// BeforeCreate sets UUID if not provided
+func (r *Request) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// ErasureRequest represents an administrator's request to erase a user
+type ErasureRequest struct {
+	Mode string `json:"mode" validate:"omitempty,oneof=delete pseudonymize"` // Defaults to the configured mode
+}
+
+// SelfErasureRequest represents a user's request to erase their own account
+type SelfErasureRequest struct {
+	Password string `json:"password"` // Required when the account has a password
+	Code     string `json:"code"`     // Emailed reauthentication code, required when it has none
+}
+
+// Archive file names
+const (
+	FileManifest     = "manifest.json"
+	FileProfile      = "profile.json"
+	FileIdentities   = "identities.json"
+	FileConsents     = "consents.json"
+	FileSessions     = "sessions.json"
+	FileAuditEvents  = "audit_events.json"
+	FileEmailHistory = "email_history.json"
+)
+
+// Manifest describes an export archive
+type Manifest struct {
+	UserID      uuid.UUID `json:"user_id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+// Identities lists how the user signs in
+type Identities struct {
+	Provider string            `json:"provider"`
+	Social   []SocialIdentity  `json:"social"`
+	SSO      []FederatedSignIn `json:"sso"`
+}
+
+// SocialIdentity is a linked social login
+type SocialIdentity struct {
+	Provider string `json:"provider"`
+	Linked   bool   `json:"linked"`
+}
+
+// FederatedSignIn is an account at an enterprise connection (OIDC, SAML or LDAP) linked to the user
+type FederatedSignIn struct {
+	ConnectionID uuid.UUID `json:"connection_id"`
+	Subject      string    `json:"subject"`
+	LinkedAt     time.Time `json:"linked_at"`
+}
+
+// Consents lists the scopes the user granted, as recorded by Authway and as remembered by Hydra
+type Consents struct {
+	Grants   []ConsentGrant   `json:"grants"`
+	Sessions []ConsentSession `json:"sessions"`
+}
+
+// ConsentGrant is the set of scopes granted to a client
+type ConsentGrant struct {
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsentSession is a consent remembered by Hydra
+type ConsentSession struct {
+	ClientID       string    `json:"client_id"`
+	ClientName     string    `json:"client_name,omitempty"`
+	Scopes         []string  `json:"scopes"`
+	Audience       []string  `json:"audience,omitempty"`
+	Remember       bool      `json:"remember"`
+	LoginSessionID string    `json:"login_session_id,omitempty"`
+	HandledAt      time.Time `json:"handled_at"`
+}
+
+// Sessions lists the user's sign-ins
+type Sessions struct {
+	LastLoginAt *time.Time     `json:"last_login_at"`
+	Active      []LoginSession `json:"active"`
+}
+
+// LoginSession is a Hydra login session and the clients that used it
+type LoginSession struct {
+	ID         string    `json:"id"`
+	Clients    []string  `json:"clients"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// EmailHistory lists the emails Authway sent to the user; tokens are never exported
+type EmailHistory struct {
+	Verifications      []EmailVerification `json:"verifications"`
+	PasswordResets     []PasswordReset     `json:"password_resets"`
+	EmailChanges       []EmailChange       `json:"email_changes"`
+	PasswordlessLogins []PasswordlessLogin `json:"passwordless_logins"`
+}
+
+// EmailVerification is a sent verification email
+type EmailVerification struct {
+	SentAt    time.Time `json:"sent_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Verified  bool      `json:"verified"`
+}
+
+// PasswordReset is a sent password reset email
+type PasswordReset struct {
+	SentAt time.Time  `json:"sent_at"`
+	UsedAt *time.Time `json:"used_at"`
+}
+
+// EmailChange is a requested change of the user's email address
+type EmailChange struct {
+	OldEmail    string     `json:"old_email"`
+	NewEmail    string     `json:"new_email"`
+	RequestedAt time.Time  `json:"requested_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+}
+
+// PasswordlessLogin is a sent magic link or one-time code
+type PasswordlessLogin struct {
+	Method string     `json:"method"`
+	SentAt time.Time  `json:"sent_at"`
+	UsedAt *time.Time `json:"used_at"`
+}
@@ -0,0 +1,458 @@
+package privacy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/consent"
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/invitation"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SessionStore is the part of the Hydra admin API holding the user's sessions and consents
+type SessionStore interface {
+	ListConsentSessions(subject string) ([]hydra.PreviousConsentSession, error)
+	RevokeUserSessions(subject string) error
+}
+
+// Service answers data subject requests: exporting a user's data and erasing it
+type Service interface {
+	// Export writes a zip archive of everything Authway stores about the user and records the request
+	Export(w io.Writer, u *user.User, requestedBy string) error
+
+	// RequestErasure schedules the user's erasure after the grace period; an empty mode uses the default
+	// With no grace period the user is erased immediately
+	RequestErasure(u *user.User, mode, requestedBy string) (*Request, error)
+	CancelErasure(userID uuid.UUID) (*Request, error)
+	GetErasure(userID uuid.UUID) (*Request, error)
+	ListRequests(userID uuid.UUID) ([]*Request, error)
+
+	// ProcessDueErasures erases the users whose grace period is over and returns how many were erased
+	// Failed erasures stay scheduled and are retried on the next run
+	ProcessDueErasures() (int, error)
+}
+
+type service struct {
+	db          *gorm.DB
+	logger      *zap.Logger
+	sessions    SessionStore
+	gracePeriod time.Duration
+	defaultMode string
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger, sessions SessionStore, gracePeriod time.Duration, defaultMode string) Service {
+	if defaultMode == "" {
+		defaultMode = ModeDelete
+	}
+	return &service{
+		db:          db,
+		logger:      logger,
+		sessions:    sessions,
+		gracePeriod: gracePeriod,
+		defaultMode: defaultMode,
+	}
+}
+
+func (s *service) Export(w io.Writer, u *user.User, requestedBy string) error {
+	subject := u.ID.String()
+
+	consentSessions, err := s.sessions.ListConsentSessions(subject)
+	if err != nil {
+		return fmt.Errorf("failed to list consent sessions: %w", err)
+	}
+
+	identities, err := s.identities(u)
+	if err != nil {
+		return err
+	}
+	consents, err := s.consents(u.ID, consentSessions)
+	if err != nil {
+		return err
+	}
+	emailHistory, err := s.emailHistory(u.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	req := &Request{
+		TenantID:    u.TenantID,
+		UserID:      u.ID,
+		Type:        TypeExport,
+		Status:      StatusCompleted,
+		RequestedBy: requestedBy,
+		CompletedAt: &now,
+	}
+	if err := s.db.Create(req).Error; err != nil {
+		s.logger.Error("Failed to record data export", zap.Error(err), zap.String("user_id", subject))
+		return fmt.Errorf("failed to record data export: %w", err)
+	}
+
+	// The export itself is part of the audit events it contains
+	auditEvents, err := s.ListRequests(u.ID)
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{FileProfile, u},
+		{FileIdentities, identities},
+		{FileConsents, consents},
+		{FileSessions, Sessions{LastLoginAt: u.LastLoginAt, Active: loginSessions(consentSessions)}},
+		{FileAuditEvents, auditEvents},
+		{FileEmailHistory, emailHistory},
+	}
+	manifest := Manifest{UserID: u.ID, TenantID: u.TenantID, GeneratedAt: now}
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, file.name)
+	}
+
+	archive := zip.NewWriter(w)
+	if err := writeJSON(archive, FileManifest, manifest, now); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := writeJSON(archive, file.name, file.content, now); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write export archive: %w", err)
+	}
+
+	s.logger.Info("User data exported", zap.String("user_id", subject), zap.String("requested_by", requestedBy))
+	return nil
+}
+
+func (s *service) RequestErasure(u *user.User, mode, requestedBy string) (*Request, error) {
+	if mode == "" {
+		mode = s.defaultMode
+	}
+	if mode != ModeDelete && mode != ModePseudonymize {
+		return nil, ErrInvalidMode
+	}
+
+	if _, err := s.GetErasure(u.ID); err == nil {
+		return nil, ErrErasurePending
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	scheduledFor := time.Now().Add(s.gracePeriod)
+	req := &Request{
+		TenantID:     u.TenantID,
+		UserID:       u.ID,
+		Type:         TypeErasure,
+		Status:       StatusScheduled,
+		Mode:         mode,
+		RequestedBy:  requestedBy,
+		ScheduledFor: &scheduledFor,
+	}
+	if err := s.db.Create(req).Error; err != nil {
+		s.logger.Error("Failed to schedule erasure", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return nil, fmt.Errorf("failed to schedule erasure: %w", err)
+	}
+
+	s.logger.Info("User erasure scheduled",
+		zap.String("user_id", u.ID.String()),
+		zap.String("mode", mode),
+		zap.String("requested_by", requestedBy),
+		zap.Time("scheduled_for", scheduledFor))
+
+	if s.gracePeriod <= 0 {
+		if err := s.erase(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (s *service) CancelErasure(userID uuid.UUID) (*Request, error) {
+	req, err := s.GetErasure(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	req.Status = StatusCancelled
+	req.CancelledAt = &now
+	if err := s.db.Save(req).Error; err != nil {
+		s.logger.Error("Failed to cancel erasure", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, fmt.Errorf("failed to cancel erasure: %w", err)
+	}
+
+	s.logger.Info("User erasure cancelled", zap.String("user_id", userID.String()))
+	return req, nil
+}
+
+// GetErasure returns the user's scheduled erasure
+func (s *service) GetErasure(userID uuid.UUID) (*Request, error) {
+	var req Request
+	err := s.db.Where("user_id = ? AND type = ? AND status = ?", userID, TypeErasure, StatusScheduled).First(&req).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get erasure request: %w", err)
+	}
+	return &req, nil
+}
+
+// ListRequests returns the user's data subject requests, oldest first
+func (s *service) ListRequests(userID uuid.UUID) ([]*Request, error) {
+	var requests []*Request
+	if err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to list privacy requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (s *service) ProcessDueErasures() (int, error) {
+	var due []*Request
+	err := s.db.Where("type = ? AND status = ? AND scheduled_for <= ?", TypeErasure, StatusScheduled, time.Now()).
+		Order("scheduled_for ASC").
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due erasures: %w", err)
+	}
+
+	erased := 0
+	for _, req := range due {
+		if err := s.erase(req); err != nil {
+			continue
+		}
+		erased++
+	}
+	return erased, nil
+}
+
+// erase signs the user out everywhere, then removes their personal data and completes the request
+// Hydra is called first so a failure leaves the user intact and the request scheduled for a retry
+func (s *service) erase(req *Request) error {
+	subject := req.UserID.String()
+
+	if err := s.sessions.RevokeUserSessions(subject); err != nil {
+		s.logger.Error("Failed to revoke sessions for erasure", zap.Error(err), zap.String("user_id", subject))
+		s.db.Model(req).Update("error", "failed to revoke sessions: "+err.Error())
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&email.EmailVerification{},
+			&email.PasswordReset{},
+			&email.EmailChange{},
+			&email.PasswordlessLogin{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", req.UserID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{
+			&consent.Grant{},
+			&connection.Identity{},
+			&group.Membership{},
+			&organization.Member{},
+		} {
+			if err := tx.Where("user_id = ?", req.UserID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("subject_type = ? AND subject_id = ?", rbac.SubjectUser, req.UserID).Delete(&rbac.Assignment{}).Error; err != nil {
+			return err
+		}
+		// Accepted invitations still hold the email address
+		if err := tx.Where("accepted_user_id = ?", req.UserID).Delete(&invitation.Invitation{}).Error; err != nil {
+			return err
+		}
+
+		if req.Mode == ModePseudonymize {
+			if err := tx.Unscoped().Model(&user.User{}).Where("id = ?", req.UserID).Updates(pseudonym(req.UserID)).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Unscoped().Where("id = ?", req.UserID).Delete(&user.User{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(req).Updates(map[string]interface{}{
+			"status":       StatusCompleted,
+			"completed_at": now,
+			"error":        "",
+		}).Error
+	})
+	if err != nil {
+		s.logger.Error("Failed to erase user", zap.Error(err), zap.String("user_id", subject))
+		s.db.Model(req).Update("error", "failed to erase user data: "+err.Error())
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	s.logger.Info("User erased", zap.String("user_id", subject), zap.String("mode", req.Mode), zap.String("request_id", req.ID.String()))
+	return nil
+}
+
+// pseudonym returns the column values replacing a pseudonymized user's personal data
+func pseudonym(userID uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"email":          "erased-" + userID.String() + user.ErasedEmailSuffix,
+		"password_hash":  "",
+		"name":           nil,
+		"avatar_url":     nil,
+		"picture":        nil,
+		"google_id":      nil,
+		"github_id":      nil,
+		"external_id":    nil,
+		"provider":       user.ProviderLocal,
+		"metadata":       user.Metadata{},
+		"attributes":     user.Attributes{},
+		"email_verified": false,
+		"active":         false,
+		"last_login_at":  nil,
+		"deleted_at":     time.Now(),
+	}
+}
+
+func (s *service) identities(u *user.User) (*Identities, error) {
+	var linked []*connection.Identity
+	if err := s.db.Where("user_id = ?", u.ID).Order("created_at ASC").Find(&linked).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SSO identities: %w", err)
+	}
+
+	identities := &Identities{Provider: u.Provider, Social: []SocialIdentity{}, SSO: []FederatedSignIn{}}
+	for _, identity := range u.Identities() {
+		identities.Social = append(identities.Social, SocialIdentity{Provider: identity.Provider, Linked: identity.Linked})
+	}
+	for _, identity := range linked {
+		identities.SSO = append(identities.SSO, FederatedSignIn{
+			ConnectionID: identity.ConnectionID,
+			Subject:      identity.Subject,
+			LinkedAt:     identity.CreatedAt,
+		})
+	}
+	return identities, nil
+}
+
+func (s *service) consents(userID uuid.UUID, consentSessions []hydra.PreviousConsentSession) (*Consents, error) {
+	var grants []*consent.Grant
+	if err := s.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list consent grants: %w", err)
+	}
+
+	consents := &Consents{Grants: []ConsentGrant{}, Sessions: []ConsentSession{}}
+	for _, grant := range grants {
+		consents.Grants = append(consents.Grants, ConsentGrant{
+			ClientID:  grant.ClientID,
+			Scopes:    grant.Scopes,
+			GrantedAt: grant.CreatedAt,
+			UpdatedAt: grant.UpdatedAt,
+		})
+	}
+	for _, cs := range consentSessions {
+		session := ConsentSession{
+			Scopes:    cs.GrantScope,
+			Audience:  cs.GrantAccessTokenAudience,
+			Remember:  cs.Remember,
+			HandledAt: cs.HandledAt,
+		}
+		if cs.ConsentRequest != nil {
+			session.LoginSessionID = cs.ConsentRequest.LoginSessionID
+			if cs.ConsentRequest.Client != nil {
+				session.ClientID = cs.ConsentRequest.Client.ClientID
+				session.ClientName = cs.ConsentRequest.Client.ClientName
+			}
+		}
+		consents.Sessions = append(consents.Sessions, session)
+	}
+	return consents, nil
+}
+
+func (s *service) emailHistory(userID uuid.UUID) (*EmailHistory, error) {
+	var verifications []*email.EmailVerification
+	var resets []*email.PasswordReset
+	var changes []*email.EmailChange
+	var passwordless []*email.PasswordlessLogin
+	for _, dest := range []interface{}{&verifications, &resets, &changes, &passwordless} {
+		if err := s.db.Unscoped().Where("user_id = ?", userID).Order("created_at ASC").Find(dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to list email history: %w", err)
+		}
+	}
+
+	history := &EmailHistory{
+		Verifications:      []EmailVerification{},
+		PasswordResets:     []PasswordReset{},
+		EmailChanges:       []EmailChange{},
+		PasswordlessLogins: []PasswordlessLogin{},
+	}
+	for _, v := range verifications {
+		history.Verifications = append(history.Verifications, EmailVerification{SentAt: v.CreatedAt, ExpiresAt: v.ExpiresAt, Verified: v.Verified})
+	}
+	for _, r := range resets {
+		history.PasswordResets = append(history.PasswordResets, PasswordReset{SentAt: r.CreatedAt, UsedAt: r.UsedAt})
+	}
+	for _, c := range changes {
+		history.EmailChanges = append(history.EmailChanges, EmailChange{
+			OldEmail:    c.OldEmail,
+			NewEmail:    c.NewEmail,
+			RequestedAt: c.CreatedAt,
+			ConfirmedAt: c.ConfirmedAt,
+			CancelledAt: c.CancelledAt,
+		})
+	}
+	for _, p := range passwordless {
+		history.PasswordlessLogins = append(history.PasswordlessLogins, PasswordlessLogin{Method: p.Method, SentAt: p.CreatedAt, UsedAt: p.UsedAt})
+	}
+	return history, nil
+}
+
+// loginSessions groups consent sessions by the login session they were granted in
+func loginSessions(consentSessions []hydra.PreviousConsentSession) []LoginSession {
+	sessions := []LoginSession{}
+	index := map[string]int{}
+	for _, cs := range consentSessions {
+		if cs.ConsentRequest == nil || cs.ConsentRequest.LoginSessionID == "" {
+			continue
+		}
+		i, ok := index[cs.ConsentRequest.LoginSessionID]
+		if !ok {
+			i = len(sessions)
+			index[cs.ConsentRequest.LoginSessionID] = i
+			sessions = append(sessions, LoginSession{ID: cs.ConsentRequest.LoginSessionID, Clients: []string{}})
+		}
+		if cs.ConsentRequest.Client != nil {
+			sessions[i].Clients = append(sessions[i].Clients, cs.ConsentRequest.Client.ClientID)
+		}
+		if cs.HandledAt.After(sessions[i].LastUsedAt) {
+			sessions[i].LastUsedAt = cs.HandledAt
+		}
+	}
+	return sessions
+}
+
+func writeJSON(archive *zip.Writer, name string, content interface{}, modified time.Time) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"authway/src/server/internal/hydra"
+	"authway/src/server/pkg/connection"
+	"authway/src/server/pkg/consent"
+	"authway/src/server/pkg/email"
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/invitation"
+	"authway/src/server/pkg/organization"
+	"authway/src/server/pkg/rbac"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeSessions stands in for the Hydra admin API
+type fakeSessions struct {
+	consentSessions []hydra.PreviousConsentSession
+	revoked         []string
+	revokeErr       error
+}
+
+func (f *fakeSessions) ListConsentSessions(subject string) ([]hydra.PreviousConsentSession, error) {
+	return f.consentSessions, nil
+}
+
+func (f *fakeSessions) RevokeUserSessions(subject string) error {
+	if f.revokeErr != nil {
+		return f.revokeErr
+	}
+	f.revoked = append(f.revoked, subject)
+	return nil
+}
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(
+		&user.User{}, &Request{},
+		&email.EmailVerification{}, &email.PasswordReset{}, &email.EmailChange{}, &email.PasswordlessLogin{},
+		&consent.Grant{}, &connection.Identity{}, &group.Membership{}, &organization.Member{},
+		&rbac.Assignment{}, &invitation.Invitation{},
+	)
+	require.NoError(t, err)
+
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB) *user.User {
+	name := "Alice"
+	u := &user.User{
+		TenantID:     uuid.New(),
+		Email:        "alice@example.com",
+		PasswordHash: "x",
+		Name:         &name,
+		Active:       true,
+		Attributes:   user.Attributes{"department": "sales"},
+	}
+	require.NoError(t, db.Create(u).Error)
+
+	repo := email.NewRepository(db)
+	_, err := repo.CreateVerification(u.ID)
+	require.NoError(t, err)
+	_, err = repo.CreatePasswordReset(u.ID)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&consent.Grant{TenantID: u.TenantID, UserID: u.ID, ClientID: "app", Scopes: []string{"openid", "email"}}).Error)
+	require.NoError(t, db.Create(&connection.Identity{ConnectionID: uuid.New(), Subject: "alice-upstream", UserID: u.ID, TenantID: u.TenantID}).Error)
+	return u
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		r.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestService_Export(t *testing.T) {
+	db := setupTestDB(t)
+	sessions := &fakeSessions{consentSessions: []hydra.PreviousConsentSession{{
+		ConsentRequest: &hydra.ConsentRequest{LoginSessionID: "login-1", Client: &hydra.OAuth2Client{ClientID: "app", ClientName: "App"}},
+		GrantScope:     []string{"openid", "email"},
+		HandledAt:      time.Now(),
+	}}}
+	service := NewService(db, zap.NewNop(), sessions, 0, "")
+	u := createUser(t, db)
+
+	var buf bytes.Buffer
+	require.NoError(t, service.Export(&buf, u, RequestedByUser))
+	files := readArchive(t, buf.Bytes())
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal([]byte(files[FileManifest]), &manifest))
+	assert.Equal(t, u.ID, manifest.UserID)
+	assert.ElementsMatch(t, []string{FileProfile, FileIdentities, FileConsents, FileSessions, FileAuditEvents, FileEmailHistory}, manifest.Files)
+	for _, name := range manifest.Files {
+		assert.Contains(t, files, name)
+	}
+
+	assert.Contains(t, files[FileProfile], "alice@example.com")
+	assert.Contains(t, files[FileProfile], "department")
+	assert.Contains(t, files[FileIdentities], "alice-upstream")
+	assert.Contains(t, files[FileConsents], `"client_name": "App"`)
+	assert.Contains(t, files[FileSessions], "login-1")
+
+	var history EmailHistory
+	require.NoError(t, json.Unmarshal([]byte(files[FileEmailHistory]), &history))
+	assert.Len(t, history.Verifications, 1)
+	assert.Len(t, history.PasswordResets, 1)
+
+	// Secrets never leave Authway
+	var verification email.EmailVerification
+	require.NoError(t, db.Where("user_id = ?", u.ID).First(&verification).Error)
+	for name, content := range files {
+		assert.NotContains(t, content, verification.Token, name)
+		assert.NotContains(t, content, "password_hash", name)
+	}
+
+	var audit []Request
+	require.NoError(t, json.Unmarshal([]byte(files[FileAuditEvents]), &audit))
+	require.Len(t, audit, 1)
+	assert.Equal(t, TypeExport, audit[0].Type)
+	assert.Equal(t, RequestedByUser, audit[0].RequestedBy)
+}
+
+func TestService_ScheduledErasure(t *testing.T) {
+	db := setupTestDB(t)
+	sessions := &fakeSessions{}
+	service := NewService(db, zap.NewNop(), sessions, 30*24*time.Hour, ModeDelete)
+	u := createUser(t, db)
+
+	req, err := service.RequestErasure(u, "", RequestedByUser)
+	require.NoError(t, err)
+	assert.Equal(t, StatusScheduled, req.Status)
+	assert.Equal(t, ModeDelete, req.Mode)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), *req.ScheduledFor, time.Minute)
+
+	_, err = service.RequestErasure(u, "", RequestedByAdmin)
+	assert.ErrorIs(t, err, ErrErasurePending)
+	_, err = service.RequestErasure(u, "shred", RequestedByAdmin)
+	assert.ErrorIs(t, err, ErrInvalidMode)
+
+	// Cancelling during the grace period keeps the user
+	cancelled, err := service.CancelErasure(u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, cancelled.Status)
+	_, err = service.CancelErasure(u.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	req, err = service.RequestErasure(u, "", RequestedByAdmin)
+	require.NoError(t, err)
+	erased, err := service.ProcessDueErasures()
+	require.NoError(t, err)
+	assert.Equal(t, 0, erased)
+
+	require.NoError(t, db.Model(req).Update("scheduled_for", time.Now().Add(-time.Minute)).Error)
+	erased, err = service.ProcessDueErasures()
+	require.NoError(t, err)
+	assert.Equal(t, 1, erased)
+	assert.Equal(t, []string{u.ID.String()}, sessions.revoked)
+
+	var count int64
+	db.Unscoped().Model(&user.User{}).Where("id = ?", u.ID).Count(&count)
+	assert.Zero(t, count)
+	for _, model := range []interface{}{&email.EmailVerification{}, &email.PasswordReset{}, &consent.Grant{}, &connection.Identity{}} {
+		db.Unscoped().Model(model).Where("user_id = ?", u.ID).Count(&count)
+		assert.Zero(t, count)
+	}
+
+	// The audit trail remains and holds no personal data
+	requests, err := service.ListRequests(u.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, StatusCancelled, requests[0].Status)
+	assert.Equal(t, StatusCompleted, requests[1].Status)
+	assert.NotNil(t, requests[1].CompletedAt)
+}
+
+func TestService_ImmediatePseudonymization(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, zap.NewNop(), &fakeSessions{}, 0, ModePseudonymize)
+	u := createUser(t, db)
+
+	req, err := service.RequestErasure(u, "", RequestedByAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, ModePseudonymize, req.Mode)
+
+	var erased user.User
+	require.NoError(t, db.Unscoped().First(&erased, "id = ?", u.ID).Error)
+	assert.Equal(t, "erased-"+u.ID.String()+"@erased.invalid", erased.Email)
+	assert.Nil(t, erased.Name)
+	assert.Empty(t, erased.PasswordHash)
+	assert.Empty(t, erased.Attributes)
+	assert.False(t, erased.Active)
+	assert.True(t, erased.DeletedAt.Valid)
+
+	// Soft-deleted users are hidden from normal lookups
+	_, err = user.NewService(db, zap.NewNop()).GetByID(u.ID)
+	assert.Error(t, err)
+}
+
+func TestService_ErasureRetriedWhenHydraFails(t *testing.T) {
+	db := setupTestDB(t)
+	sessions := &fakeSessions{revokeErr: errors.New("hydra unavailable")}
+	service := NewService(db, zap.NewNop(), sessions, time.Hour, ModeDelete)
+	u := createUser(t, db)
+
+	req, err := service.RequestErasure(u, "", RequestedByAdmin)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(req).Update("scheduled_for", time.Now().Add(-time.Minute)).Error)
+
+	erased, err := service.ProcessDueErasures()
+	require.NoError(t, err)
+	assert.Zero(t, erased)
+
+	pending, err := service.GetErasure(u.ID)
+	require.NoError(t, err)
+	assert.Contains(t, pending.Error, "hydra unavailable")
+	_, err = user.NewService(db, zap.NewNop()).GetByID(u.ID)
+	assert.NoError(t, err)
+
+	sessions.revokeErr = nil
+	erased, err = service.ProcessDueErasures()
+	require.NoError(t, err)
+	assert.Equal(t, 1, erased)
+}
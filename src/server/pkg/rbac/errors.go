@@ -0,0 +1,15 @@
+package rbac
+
+import "errors"
+
+// RBAC-specific errors
+var (
+	// ErrRoleNotFound is returned when a role is not found
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrDuplicateRole is returned when a role with the same name already exists for the tenant and client
+	ErrDuplicateRole = errors.New("role with this name already exists")
+
+	// ErrAssignmentNotFound is returned when removing a role that is not assigned
+	ErrAssignmentNotFound = errors.New("role assignment not found")
+)
@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Assignment subject types
+const (
+	SubjectUser  = "user"
+	SubjectGroup = "group"
+)
+
+// Role is a named set of permissions within a tenant
+// Roles with a ClientID only apply to tokens issued to that client; others apply tenant-wide
+type Role struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    uuid.UUID      `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_roles_tenant_client_name"`
+	ClientID    string         `json:"client_id,omitempty" gorm:"not null;default:'';uniqueIndex:idx_roles_tenant_client_name"`
+	Name        string         `json:"name" gorm:"not null;uniqueIndex:idx_roles_tenant_client_name"`
+	Description string         `json:"description"`
+	Permissions pq.StringArray `json:"permissions" gorm:"type:text[]"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for Role model
+func (Role) TableName() string {
+	return "roles"
+}
+
+// BeforeCreate sets UUID if not provided
+func (r *Role) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Assignment grants a role to a user or a group
+type Assignment struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	RoleID      uuid.UUID `json:"role_id" gorm:"type:uuid;not null;uniqueIndex:idx_role_assignments_subject"`
+	SubjectType string    `json:"subject_type" gorm:"not null;uniqueIndex:idx_role_assignments_subject;index:idx_role_assignments_lookup"`
+	SubjectID   uuid.UUID `json:"subject_id" gorm:"type:uuid;not null;uniqueIndex:idx_role_assignments_subject;index:idx_role_assignments_lookup"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for Assignment model
+func (Assignment) TableName() string {
+	return "role_assignments"
+}
+
+// BeforeCreate sets UUID if not provided
+func (a *Assignment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// CreateRoleRequest represents the request to create a role
+type CreateRoleRequest struct {
+	TenantID    string   `json:"tenant_id" validate:"required,uuid"`
+	ClientID    string   `json:"client_id" validate:"max=255"`
+	Name        string   `json:"name" validate:"required,min=1,max=100"`
+	Description string   `json:"description" validate:"max=1000"`
+	Permissions []string `json:"permissions" validate:"omitempty,dive,required,max=255"`
+}
+
+// UpdateRoleRequest represents the request to update a role
+type UpdateRoleRequest struct {
+	Name        string    `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string   `json:"description" validate:"omitempty,max=1000"`
+	Permissions *[]string `json:"permissions" validate:"omitempty,dive,required,max=255"`
+}
+
+// AssignRoleRequest represents the request to assign a role to a user or group
+type AssignRoleRequest struct {
+	SubjectType string `json:"subject_type" validate:"required,oneof=user group"`
+	SubjectID   string `json:"subject_id" validate:"required,uuid"`
+}
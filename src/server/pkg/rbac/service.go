@@ -0,0 +1,301 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service interface {
+	CreateRole(tenantID uuid.UUID, req *CreateRoleRequest) (*Role, error)
+	GetRole(id uuid.UUID) (*Role, error)
+	ListRoles(tenantID uuid.UUID, clientID string) ([]*Role, error)
+	UpdateRole(id uuid.UUID, req *UpdateRoleRequest) (*Role, error)
+	DeleteRole(id uuid.UUID) error
+	FindTenantRoles(tenantID uuid.UUID, names []string) ([]*Role, error)
+
+	Assign(roleID uuid.UUID, subjectType string, subjectID uuid.UUID) error
+	Unassign(roleID uuid.UUID, subjectType string, subjectID uuid.UUID) error
+	ListAssignments(roleID uuid.UUID) ([]*Assignment, error)
+	SubjectRoles(subjectType string, subjectID uuid.UUID) ([]*Role, error)
+	DeleteSubjectAssignments(subjectType string, subjectID uuid.UUID) error
+
+	EffectiveRoles(userID uuid.UUID, groupIDs []uuid.UUID, clientID string) ([]*Role, error)
+}
+
+type service struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger) Service {
+	return &service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *service) CreateRole(tenantID uuid.UUID, req *CreateRoleRequest) (*Role, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkNameAvailable(tenantID, req.ClientID, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	role := &Role{
+		TenantID:    tenantID,
+		ClientID:    req.ClientID,
+		Name:        name,
+		Description: req.Description,
+		Permissions: pq.StringArray(normalizePermissions(req.Permissions)),
+	}
+
+	if err := s.db.Create(role).Error; err != nil {
+		s.logger.Error("Failed to create role", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	s.logger.Info("Role created",
+		zap.String("role_id", role.ID.String()),
+		zap.String("tenant_id", tenantID.String()),
+		zap.String("name", role.Name))
+
+	return role, nil
+}
+
+func (s *service) GetRole(id uuid.UUID) (*Role, error) {
+	var role Role
+	if err := s.db.Where("id = ?", id).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+// ListRoles returns a tenant's roles; a non-empty clientID limits the list to that client's roles
+func (s *service) ListRoles(tenantID uuid.UUID, clientID string) ([]*Role, error) {
+	query := s.db.Where("tenant_id = ?", tenantID)
+	if clientID != "" {
+		query = query.Where("client_id = ?", clientID)
+	}
+
+	var roles []*Role
+	if err := query.Order("client_id ASC, name ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (s *service) UpdateRole(id uuid.UUID, req *UpdateRoleRequest) (*Role, error) {
+	role, err := s.GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" && name != role.Name {
+		if err := s.checkNameAvailable(role.TenantID, role.ClientID, name, role.ID); err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Permissions != nil {
+		updates["permissions"] = pq.StringArray(normalizePermissions(*req.Permissions))
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(role).Updates(updates).Error; err != nil {
+			s.logger.Error("Failed to update role", zap.Error(err), zap.String("role_id", id.String()))
+			return nil, fmt.Errorf("failed to update role: %w", err)
+		}
+	}
+
+	return s.GetRole(id)
+}
+
+// DeleteRole removes a role and all of its assignments
+func (s *service) DeleteRole(id uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", id).Delete(&Assignment{}).Error; err != nil {
+			return fmt.Errorf("failed to delete role assignments: %w", err)
+		}
+		result := tx.Where("id = ?", id).Delete(&Role{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete role: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return nil
+	})
+}
+
+// FindTenantRoles looks up tenant-wide roles by name; every name must exist
+func (s *service) FindTenantRoles(tenantID uuid.UUID, names []string) ([]*Role, error) {
+	if len(names) == 0 {
+		return []*Role{}, nil
+	}
+
+	var roles []*Role
+	if err := s.db.Where("tenant_id = ? AND client_id = '' AND name IN ?", tenantID, names).Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to find roles: %w", err)
+	}
+
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+	}
+	return roles, nil
+}
+
+// Assign grants a role to a user or group; assigning an existing role is a no-op
+func (s *service) Assign(roleID uuid.UUID, subjectType string, subjectID uuid.UUID) error {
+	role, err := s.GetRole(roleID)
+	if err != nil {
+		return err
+	}
+
+	var existing int64
+	if err := s.db.Model(&Assignment{}).
+		Where("role_id = ? AND subject_type = ? AND subject_id = ?", roleID, subjectType, subjectID).
+		Count(&existing).Error; err != nil {
+		return fmt.Errorf("failed to check role assignment: %w", err)
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	assignment := &Assignment{
+		TenantID:    role.TenantID,
+		RoleID:      roleID,
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+	}
+	if err := s.db.Create(assignment).Error; err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+	return nil
+}
+
+func (s *service) Unassign(roleID uuid.UUID, subjectType string, subjectID uuid.UUID) error {
+	result := s.db.Where("role_id = ? AND subject_type = ? AND subject_id = ?", roleID, subjectType, subjectID).
+		Delete(&Assignment{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove role assignment: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAssignmentNotFound
+	}
+	return nil
+}
+
+func (s *service) ListAssignments(roleID uuid.UUID) ([]*Assignment, error) {
+	var assignments []*Assignment
+	if err := s.db.Where("role_id = ?", roleID).Order("created_at ASC").Find(&assignments).Error; err != nil {
+		return nil, fmt.Errorf("failed to list role assignments: %w", err)
+	}
+	return assignments, nil
+}
+
+// SubjectRoles returns the roles assigned directly to a user or group
+func (s *service) SubjectRoles(subjectType string, subjectID uuid.UUID) ([]*Role, error) {
+	var roles []*Role
+	if err := s.db.
+		Where("id IN (?)", s.db.Model(&Assignment{}).Select("role_id").
+			Where("subject_type = ? AND subject_id = ?", subjectType, subjectID)).
+		Order("client_id ASC, name ASC").
+		Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+// DeleteSubjectAssignments removes every role assignment of a user or group
+func (s *service) DeleteSubjectAssignments(subjectType string, subjectID uuid.UUID) error {
+	if err := s.db.Where("subject_type = ? AND subject_id = ?", subjectType, subjectID).
+		Delete(&Assignment{}).Error; err != nil {
+		return fmt.Errorf("failed to delete role assignments: %w", err)
+	}
+	return nil
+}
+
+// EffectiveRoles returns the roles a user holds directly or through the given groups
+// that apply to clientID: tenant-wide roles plus the client's own roles
+func (s *service) EffectiveRoles(userID uuid.UUID, groupIDs []uuid.UUID, clientID string) ([]*Role, error) {
+	subjects := s.db.Model(&Assignment{}).Select("role_id").
+		Where("subject_type = ? AND subject_id = ?", SubjectUser, userID)
+	if len(groupIDs) > 0 {
+		subjects = subjects.Or("subject_type = ? AND subject_id IN ?", SubjectGroup, groupIDs)
+	}
+
+	var roles []*Role
+	if err := s.db.
+		Where("id IN (?)", subjects).
+		Where("client_id = '' OR client_id = ?", clientID).
+		Order("client_id ASC, name ASC").
+		Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve roles: %w", err)
+	}
+	return roles, nil
+}
+
+// Claims returns the sorted, de-duplicated role names and permissions of a set of roles
+func Claims(roles []*Role) (names []string, permissions []string) {
+	nameSet := map[string]bool{}
+	permissionSet := map[string]bool{}
+	for _, role := range roles {
+		nameSet[role.Name] = true
+		for _, permission := range role.Permissions {
+			permissionSet[permission] = true
+		}
+	}
+	return sortedKeys(nameSet), sortedKeys(permissionSet)
+}
+
+func (s *service) checkNameAvailable(tenantID uuid.UUID, clientID, name string, excludeID uuid.UUID) error {
+	var count int64
+	if err := s.db.Model(&Role{}).
+		Where("tenant_id = ? AND client_id = ? AND name = ? AND id <> ?", tenantID, clientID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check role name: %w", err)
+	}
+	if count > 0 {
+		return ErrDuplicateRole
+	}
+	return nil
+}
+
+// normalizePermissions trims, de-duplicates and sorts permission names
+func normalizePermissions(permissions []string) []string {
+	set := map[string]bool{}
+	for _, permission := range permissions {
+		if permission = strings.TrimSpace(permission); permission != "" {
+			set[permission] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
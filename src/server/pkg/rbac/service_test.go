@@ -0,0 +1,135 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&Role{}, &Assignment{})
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestService_CreateRole(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	role, err := service.CreateRole(tenantID, &CreateRoleRequest{
+		Name:        "editor",
+		Permissions: []string{"documents:write", "documents:read", "documents:read", " "},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"documents:read", "documents:write"}, []string(role.Permissions))
+
+	// Names are unique per tenant and client
+	_, err = service.CreateRole(tenantID, &CreateRoleRequest{Name: "editor"})
+	assert.ErrorIs(t, err, ErrDuplicateRole)
+	_, err = service.CreateRole(tenantID, &CreateRoleRequest{Name: "editor", ClientID: "app"})
+	assert.NoError(t, err)
+	_, err = service.CreateRole(uuid.New(), &CreateRoleRequest{Name: "editor"})
+	assert.NoError(t, err)
+
+	roles, err := service.ListRoles(tenantID, "")
+	require.NoError(t, err)
+	assert.Len(t, roles, 2)
+	roles, err = service.ListRoles(tenantID, "app")
+	require.NoError(t, err)
+	assert.Len(t, roles, 1)
+
+	// Tenant-wide lookup by name ignores client roles
+	found, err := service.FindTenantRoles(tenantID, []string{"editor"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, role.ID, found[0].ID)
+	_, err = service.FindTenantRoles(tenantID, []string{"editor", "missing"})
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+func TestService_UpdateAndDeleteRole(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+
+	role, err := service.CreateRole(tenantID, &CreateRoleRequest{Name: "viewer", Permissions: []string{"documents:read"}})
+	require.NoError(t, err)
+	_, err = service.CreateRole(tenantID, &CreateRoleRequest{Name: "admin"})
+	require.NoError(t, err)
+
+	_, err = service.UpdateRole(role.ID, &UpdateRoleRequest{Name: "admin"})
+	assert.ErrorIs(t, err, ErrDuplicateRole)
+
+	cleared := []string{}
+	updated, err := service.UpdateRole(role.ID, &UpdateRoleRequest{Name: "reader", Permissions: &cleared})
+	require.NoError(t, err)
+	assert.Equal(t, "reader", updated.Name)
+	assert.Empty(t, updated.Permissions)
+
+	userID := uuid.New()
+	require.NoError(t, service.Assign(role.ID, SubjectUser, userID))
+	require.NoError(t, service.DeleteRole(role.ID))
+	assert.ErrorIs(t, service.DeleteRole(role.ID), ErrRoleNotFound)
+
+	roles, err := service.SubjectRoles(SubjectUser, userID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func TestService_EffectiveRoles(t *testing.T) {
+	service := NewService(setupTestDB(t), zap.NewNop())
+	tenantID := uuid.New()
+	userID := uuid.New()
+	groupID := uuid.New()
+
+	viewer, err := service.CreateRole(tenantID, &CreateRoleRequest{Name: "viewer", Permissions: []string{"documents:read"}})
+	require.NoError(t, err)
+	editor, err := service.CreateRole(tenantID, &CreateRoleRequest{Name: "editor", Permissions: []string{"documents:read", "documents:write"}})
+	require.NoError(t, err)
+	billing, err := service.CreateRole(tenantID, &CreateRoleRequest{Name: "billing", ClientID: "billing-app", Permissions: []string{"invoices:read"}})
+	require.NoError(t, err)
+	_, err = service.CreateRole(tenantID, &CreateRoleRequest{Name: "unassigned"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.Assign(viewer.ID, SubjectUser, userID))
+	require.NoError(t, service.Assign(viewer.ID, SubjectUser, userID)) // idempotent
+	require.NoError(t, service.Assign(editor.ID, SubjectGroup, groupID))
+	require.NoError(t, service.Assign(billing.ID, SubjectUser, userID))
+
+	assignments, err := service.ListAssignments(viewer.ID)
+	require.NoError(t, err)
+	assert.Len(t, assignments, 1)
+
+	tests := []struct {
+		name          string
+		groupIDs      []uuid.UUID
+		clientID      string
+		expectedRoles []string
+		expectedPerms []string
+	}{
+		{name: "direct roles only", clientID: "other-app", expectedRoles: []string{"viewer"}, expectedPerms: []string{"documents:read"}},
+		{name: "inherited from group", groupIDs: []uuid.UUID{groupID}, clientID: "other-app", expectedRoles: []string{"editor", "viewer"}, expectedPerms: []string{"documents:read", "documents:write"}},
+		{name: "client roles", clientID: "billing-app", expectedRoles: []string{"billing", "viewer"}, expectedPerms: []string{"documents:read", "invoices:read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles, err := service.EffectiveRoles(userID, tt.groupIDs, tt.clientID)
+			require.NoError(t, err)
+			names, permissions := Claims(roles)
+			assert.Equal(t, tt.expectedRoles, names)
+			assert.Equal(t, tt.expectedPerms, permissions)
+		})
+	}
+
+	require.NoError(t, service.Unassign(viewer.ID, SubjectUser, userID))
+	assert.ErrorIs(t, service.Unassign(viewer.ID, SubjectUser, userID), ErrAssignmentNotFound)
+}
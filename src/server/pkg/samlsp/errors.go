@@ -0,0 +1,15 @@
+package samlsp
+
+import "errors"
+
+// Service provider-specific errors
+var (
+	// ErrNotFound is returned when a SAML service provider is not found
+	ErrNotFound = errors.New("SAML service provider not found")
+
+	// ErrDuplicateEntityID is returned when the tenant already registered a service provider with the entity ID
+	ErrDuplicateEntityID = errors.New("SAML service provider with this entity ID already exists")
+
+	// ErrACSURLNotAllowed is returned when an AuthnRequest asks for an unregistered assertion consumer service
+	ErrACSURLNotAllowed = errors.New("assertion consumer service URL is not registered")
+)
@@ -0,0 +1,150 @@
+package samlsp
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"authway/src/server/pkg/consent"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// NameID formats a service provider can receive
+const (
+	NameIDEmail       = "email"
+	NameIDPersistent  = "persistent"
+	NameIDTransient   = "transient"
+	NameIDUnspecified = "unspecified"
+)
+
+// DefaultAttributes are released when a service provider maps no attributes
+var DefaultAttributes = AttributeMapping{
+	"email": "email",
+	"name":  "name",
+}
+
+// ServiceProvider is a legacy application that signs users in with SAML against a tenant
+// Logins run through a backing OAuth client, so they share the tenant's login page and SSO session
+type ServiceProvider struct {
+	ID           uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID     uuid.UUID        `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_saml_sps_tenant_entity"`
+	Name         string           `json:"name" gorm:"not null"`
+	EntityID     string           `json:"entity_id" gorm:"not null;uniqueIndex:idx_saml_sps_tenant_entity"`
+	ACSURLs      pq.StringArray   `json:"acs_urls" gorm:"column:acs_urls;type:text[]"`
+	NameIDFormat string           `json:"name_id_format" gorm:"not null"`
+	Attributes   AttributeMapping `json:"attributes" gorm:"type:jsonb"`
+	Enabled      bool             `json:"enabled" gorm:"not null"`
+	ClientID     uuid.UUID        `json:"client_id" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for ServiceProvider model
+func (ServiceProvider) TableName() string {
+	return "saml_service_providers"
+}
+
+// BeforeCreate sets UUID if not provided
+func (sp *ServiceProvider) BeforeCreate(tx *gorm.DB) error {
+	if sp.ID == uuid.Nil {
+		sp.ID = uuid.New()
+	}
+	return nil
+}
+
+// ACSURL returns the assertion consumer service to answer an AuthnRequest at
+// An empty requested URL selects the first registered one
+func (sp *ServiceProvider) ACSURL(requested string) (string, error) {
+	if len(sp.ACSURLs) == 0 {
+		return "", ErrACSURLNotAllowed
+	}
+	if requested == "" {
+		return sp.ACSURLs[0], nil
+	}
+	for _, u := range sp.ACSURLs {
+		if u == requested {
+			return u, nil
+		}
+	}
+	return "", ErrACSURLNotAllowed
+}
+
+// ReleasedAttributes returns the attribute mapping in effect
+func (sp *ServiceProvider) ReleasedAttributes() AttributeMapping {
+	if len(sp.Attributes) == 0 {
+		return DefaultAttributes
+	}
+	return sp.Attributes
+}
+
+// Scopes returns the OAuth scopes the backing client needs to obtain the mapped claims
+func (sp *ServiceProvider) Scopes() []string {
+	scopes := []string{consent.ScopeOpenID}
+	for _, claim := range sp.ReleasedAttributes() {
+		for scope, claims := range consent.DefaultScopeClaims {
+			if containsString(claims, claim) && !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	sort.Strings(scopes[1:])
+	return scopes
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AttributeMapping maps SAML attribute names to the Authway claims released in them
+type AttributeMapping map[string]string
+
+// Scan implements sql.Scanner for AttributeMapping (JSONB support)
+func (m *AttributeMapping) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for AttributeMapping (JSONB support)
+func (m AttributeMapping) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// CreateServiceProviderRequest represents the request to register a SAML service provider for a tenant
+type CreateServiceProviderRequest struct {
+	TenantID     string           `json:"tenant_id" validate:"required,uuid"`
+	Name         string           `json:"name" validate:"required,min=2,max=255"`
+	EntityID     string           `json:"entity_id" validate:"required,max=1024"`
+	ACSURLs      []string         `json:"acs_urls" validate:"required,min=1,max=20,dive,url"`
+	NameIDFormat string           `json:"name_id_format" validate:"omitempty,oneof=email persistent transient unspecified"`
+	Attributes   AttributeMapping `json:"attributes" validate:"omitempty,max=50,dive,keys,required,max=255,endkeys,oneof=sub email email_verified name picture tenant_id org_id org_role roles permissions groups"`
+	Enabled      *bool            `json:"enabled"`
+}
+
+// UpdateServiceProviderRequest represents the request to update a SAML service provider
+// Omitted fields keep their value; attributes, when present, replace the mapping
+type UpdateServiceProviderRequest struct {
+	Name         string            `json:"name" validate:"omitempty,min=2,max=255"`
+	ACSURLs      []string          `json:"acs_urls" validate:"omitempty,max=20,dive,url"`
+	NameIDFormat string            `json:"name_id_format" validate:"omitempty,oneof=email persistent transient unspecified"`
+	Attributes   *AttributeMapping `json:"attributes" validate:"omitempty,max=50,dive,keys,required,max=255,endkeys,oneof=sub email email_verified name picture tenant_id org_id org_role roles permissions groups"`
+	Enabled      *bool             `json:"enabled"`
+}
@@ -0,0 +1,170 @@
+package samlsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"authway/src/server/pkg/client"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Service manages tenants' SAML service provider registrations and their backing OAuth clients
+type Service interface {
+	Create(tenantID uuid.UUID, req *CreateServiceProviderRequest) (*ServiceProvider, error)
+	Get(id uuid.UUID) (*ServiceProvider, error)
+	GetByEntityID(tenantID uuid.UUID, entityID string) (*ServiceProvider, error)
+	List(tenantID uuid.UUID) ([]*ServiceProvider, error)
+	Update(id uuid.UUID, req *UpdateServiceProviderRequest) (*ServiceProvider, error)
+	Delete(id uuid.UUID) error
+}
+
+type service struct {
+	db            *gorm.DB
+	logger        *zap.Logger
+	clientService client.Service
+	redirectURI   string
+}
+
+// NewService creates the service provider service
+// redirectURI is the IdP callback registered on every backing OAuth client
+func NewService(db *gorm.DB, logger *zap.Logger, clientService client.Service, redirectURI string) Service {
+	return &service{
+		db:            db,
+		logger:        logger,
+		clientService: clientService,
+		redirectURI:   redirectURI,
+	}
+}
+
+func (s *service) Create(tenantID uuid.UUID, req *CreateServiceProviderRequest) (*ServiceProvider, error) {
+	entityID := strings.TrimSpace(req.EntityID)
+	if _, err := s.GetByEntityID(tenantID, entityID); err == nil {
+		return nil, ErrDuplicateEntityID
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	sp := &ServiceProvider{
+		TenantID:     tenantID,
+		Name:         strings.TrimSpace(req.Name),
+		EntityID:     entityID,
+		ACSURLs:      req.ACSURLs,
+		NameIDFormat: req.NameIDFormat,
+		Attributes:   req.Attributes,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+	}
+	if sp.NameIDFormat == "" {
+		sp.NameIDFormat = NameIDPersistent
+	}
+
+	// First-party client: SAML applications are registered by the tenant admin, so consent is implied
+	backing, _, err := s.clientService.Create(&client.CreateClientRequest{
+		TenantID:     tenantID.String(),
+		Name:         "SAML: " + sp.Name,
+		Description:  "Backing client of SAML service provider " + entityID,
+		RedirectURIs: []string{s.redirectURI},
+		GrantTypes:   []string{client.GrantTypeAuthorizationCode},
+		Scopes:       sp.Scopes(),
+		FirstParty:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sp.ClientID = backing.ID
+
+	if err := s.db.Create(sp).Error; err != nil {
+		if deleteErr := s.clientService.Delete(backing.ID); deleteErr != nil {
+			s.logger.Error("Failed to remove backing client", zap.Error(deleteErr), zap.String("client_id", backing.ClientID))
+		}
+		return nil, fmt.Errorf("failed to create SAML service provider: %w", err)
+	}
+
+	s.logger.Info("SAML service provider registered",
+		zap.String("id", sp.ID.String()),
+		zap.String("entity_id", sp.EntityID),
+		zap.String("tenant_id", tenantID.String()))
+	return sp, nil
+}
+
+func (s *service) Get(id uuid.UUID) (*ServiceProvider, error) {
+	var sp ServiceProvider
+	if err := s.db.Where("id = ?", id).First(&sp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get SAML service provider: %w", err)
+	}
+	return &sp, nil
+}
+
+func (s *service) GetByEntityID(tenantID uuid.UUID, entityID string) (*ServiceProvider, error) {
+	var sp ServiceProvider
+	if err := s.db.Where("tenant_id = ? AND entity_id = ?", tenantID, entityID).First(&sp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get SAML service provider: %w", err)
+	}
+	return &sp, nil
+}
+
+func (s *service) List(tenantID uuid.UUID) ([]*ServiceProvider, error) {
+	var sps []*ServiceProvider
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("name").Find(&sps).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SAML service providers: %w", err)
+	}
+	return sps, nil
+}
+
+func (s *service) Update(id uuid.UUID, req *UpdateServiceProviderRequest) (*ServiceProvider, error) {
+	sp, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		sp.Name = strings.TrimSpace(req.Name)
+	}
+	if len(req.ACSURLs) > 0 {
+		sp.ACSURLs = req.ACSURLs
+	}
+	if req.NameIDFormat != "" {
+		sp.NameIDFormat = req.NameIDFormat
+	}
+	if req.Enabled != nil {
+		sp.Enabled = *req.Enabled
+	}
+
+	// The backing client must be allowed the scopes of newly mapped claims
+	if req.Attributes != nil {
+		sp.Attributes = *req.Attributes
+		if _, err := s.clientService.Update(sp.ClientID, &client.UpdateClientRequest{Scopes: sp.Scopes()}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Save(sp).Error; err != nil {
+		return nil, fmt.Errorf("failed to update SAML service provider: %w", err)
+	}
+	return sp, nil
+}
+
+func (s *service) Delete(id uuid.UUID) error {
+	sp, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(&ServiceProvider{}, "id = ?", id).Error; err != nil {
+		return fmt.Errorf("failed to delete SAML service provider: %w", err)
+	}
+	if err := s.clientService.Delete(sp.ClientID); err != nil {
+		s.logger.Error("Failed to remove backing client", zap.Error(err), zap.String("client_id", sp.ClientID.String()))
+	}
+
+	s.logger.Info("SAML service provider deleted", zap.String("id", id.String()), zap.String("entity_id", sp.EntityID))
+	return nil
+}
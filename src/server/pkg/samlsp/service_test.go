@@ -0,0 +1,109 @@
+package samlsp
+
+import (
+	"testing"
+
+	"authway/src/server/pkg/client"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeClientService records the backing clients the service manages
+type fakeClientService struct {
+	client.Service
+	clients map[uuid.UUID]*client.Client
+}
+
+func (f *fakeClientService) Create(req *client.CreateClientRequest) (*client.Client, *client.ClientCredentials, error) {
+	c := &client.Client{ID: uuid.New(), ClientID: "saml-" + req.Name, RedirectURIs: req.RedirectURIs, Scopes: req.Scopes, FirstParty: req.FirstParty}
+	f.clients[c.ID] = c
+	return c, &client.ClientCredentials{ClientID: c.ClientID}, nil
+}
+
+func (f *fakeClientService) Update(id uuid.UUID, req *client.UpdateClientRequest) (*client.Client, error) {
+	f.clients[id].Scopes = req.Scopes
+	return f.clients[id], nil
+}
+
+func (f *fakeClientService) Delete(id uuid.UUID) error {
+	delete(f.clients, id)
+	return nil
+}
+
+func setupTestService(t *testing.T) (Service, *fakeClientService) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&ServiceProvider{}))
+
+	clients := &fakeClientService{clients: map[uuid.UUID]*client.Client{}}
+	return NewService(db, zap.NewNop(), clients, "http://localhost:8080/saml/idp/callback"), clients
+}
+
+func TestService_CreateWithBackingClient(t *testing.T) {
+	service, clients := setupTestService(t)
+	tenantID := uuid.New()
+
+	sp, err := service.Create(tenantID, &CreateServiceProviderRequest{
+		Name:     "Legacy HR",
+		EntityID: "https://hr.acme.com",
+		ACSURLs:  []string{"https://hr.acme.com/acs", "https://hr.acme.com/acs2"},
+	})
+	require.NoError(t, err)
+	assert.True(t, sp.Enabled)
+	assert.Equal(t, NameIDPersistent, sp.NameIDFormat)
+
+	backing := clients.clients[sp.ClientID]
+	require.NotNil(t, backing)
+	assert.True(t, backing.FirstParty)
+	assert.Equal(t, []string{"http://localhost:8080/saml/idp/callback"}, []string(backing.RedirectURIs))
+	assert.Equal(t, []string{"openid", "email", "profile"}, []string(backing.Scopes))
+
+	_, err = service.Create(tenantID, &CreateServiceProviderRequest{Name: "Again", EntityID: "https://hr.acme.com", ACSURLs: []string{"https://hr.acme.com/acs"}})
+	assert.ErrorIs(t, err, ErrDuplicateEntityID)
+
+	found, err := service.GetByEntityID(tenantID, "https://hr.acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, sp.ID, found.ID)
+	_, err = service.GetByEntityID(uuid.New(), "https://hr.acme.com")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	acs, err := found.ACSURL("")
+	require.NoError(t, err)
+	assert.Equal(t, "https://hr.acme.com/acs", acs)
+	acs, err = found.ACSURL("https://hr.acme.com/acs2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://hr.acme.com/acs2", acs)
+	_, err = found.ACSURL("https://evil.example.com/acs")
+	assert.ErrorIs(t, err, ErrACSURLNotAllowed)
+}
+
+func TestService_UpdateAndDelete(t *testing.T) {
+	service, clients := setupTestService(t)
+
+	sp, err := service.Create(uuid.New(), &CreateServiceProviderRequest{
+		Name:     "Legacy HR",
+		EntityID: "https://hr.acme.com",
+		ACSURLs:  []string{"https://hr.acme.com/acs"},
+	})
+	require.NoError(t, err)
+
+	// Mapping roles and groups widens the scopes of the backing client
+	attributes := AttributeMapping{"mail": "email", "memberOf": "groups", "role": "roles"}
+	updated, err := service.Update(sp.ID, &UpdateServiceProviderRequest{Attributes: &attributes, NameIDFormat: NameIDEmail})
+	require.NoError(t, err)
+	assert.Equal(t, NameIDEmail, updated.NameIDFormat)
+	assert.Equal(t, []string{"openid", "email", "groups", "roles"}, []string(clients.clients[sp.ClientID].Scopes))
+
+	found, err := service.Get(sp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, attributes, found.Attributes)
+
+	require.NoError(t, service.Delete(sp.ID))
+	assert.Empty(t, clients.clients)
+	_, err = service.Get(sp.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,121 @@
+package scim
+
+// Discovery documents of RFC 7644 section 4, describing what this service provider supports
+
+type object = map[string]interface{}
+
+// ServiceProviderConfig returns the service provider configuration served at /ServiceProviderConfig
+func ServiceProviderConfig(baseURL string) object {
+	return object{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            object{"supported": true},
+		"bulk":             object{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           object{"supported": true, "maxResults": MaxCount},
+		"changePassword":   object{"supported": false},
+		"sort":             object{"supported": false},
+		"etag":             object{"supported": false},
+		"authenticationSchemes": []object{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Tenant-scoped SCIM token issued by an Authway administrator",
+			"primary":     true,
+		}},
+		"meta": object{"resourceType": "ServiceProviderConfig", "location": baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+// ResourceTypes returns the resource types served at /ResourceTypes
+func ResourceTypes(baseURL string) []object {
+	return []object{
+		resourceType(baseURL, "User", "/Users", SchemaUser),
+		resourceType(baseURL, "Group", "/Groups", SchemaGroup),
+	}
+}
+
+func resourceType(baseURL, name, endpoint, schema string) object {
+	return object{
+		"schemas":     []string{SchemaResourceType},
+		"id":          name,
+		"name":        name,
+		"endpoint":    endpoint,
+		"description": name,
+		"schema":      schema,
+		"meta":        object{"resourceType": "ResourceType", "location": baseURL + "/ResourceTypes/" + name},
+	}
+}
+
+// Schemas returns the resource schemas served at /Schemas, limited to the supported attributes
+func Schemas(baseURL string) []object {
+	return []object{
+		schema(baseURL, SchemaUser, "User", "User Account", []object{
+			attribute("userName", "string", true, false, "readWrite", "server"),
+			attribute("externalId", "string", false, true, "readWrite", "none"),
+			attribute("displayName", "string", false, false, "readWrite", "none"),
+			complexAttribute("name", false, "readWrite",
+				attribute("formatted", "string", false, false, "readWrite", "none"),
+				attribute("givenName", "string", false, false, "readWrite", "none"),
+				attribute("familyName", "string", false, false, "readWrite", "none"),
+			),
+			complexAttribute("emails", true, "readWrite",
+				attribute("value", "string", false, false, "readWrite", "server"),
+				attribute("type", "string", false, false, "readWrite", "none"),
+				attribute("primary", "boolean", false, false, "readWrite", "none"),
+			),
+			attribute("active", "boolean", false, false, "readWrite", "none"),
+			attribute("password", "string", false, false, "writeOnly", "none"),
+			complexAttribute("groups", true, "readOnly",
+				attribute("value", "string", false, false, "readOnly", "none"),
+				attribute("display", "string", false, false, "readOnly", "none"),
+			),
+		}),
+		schema(baseURL, SchemaGroup, "Group", "Group", []object{
+			attribute("displayName", "string", true, false, "readWrite", "server"),
+			attribute("externalId", "string", false, true, "readWrite", "none"),
+			complexAttribute("members", true, "readWrite",
+				attribute("value", "string", false, false, "immutable", "none"),
+				attribute("display", "string", false, false, "readOnly", "none"),
+			),
+		}),
+	}
+}
+
+func schema(baseURL, id, name, description string, attributes []object) object {
+	return object{
+		"schemas":     []string{SchemaSchema},
+		"id":          id,
+		"name":        name,
+		"description": description,
+		"attributes":  attributes,
+		"meta":        object{"resourceType": "Schema", "location": baseURL + "/Schemas/" + id},
+	}
+}
+
+func attribute(name, typ string, required, caseExact bool, mutability, uniqueness string) object {
+	returned := "default"
+	if mutability == "writeOnly" {
+		returned = "never"
+	}
+	return object{
+		"name":        name,
+		"type":        typ,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   caseExact,
+		"mutability":  mutability,
+		"returned":    returned,
+		"uniqueness":  uniqueness,
+	}
+}
+
+func complexAttribute(name string, multiValued bool, mutability string, subAttributes ...object) object {
+	return object{
+		"name":          name,
+		"type":          "complex",
+		"multiValued":   multiValued,
+		"required":      false,
+		"mutability":    mutability,
+		"returned":      "default",
+		"subAttributes": subAttributes,
+	}
+}
@@ -0,0 +1,27 @@
+package scim
+
+import "errors"
+
+// SCIM-specific errors
+var (
+	// ErrNotFound is returned when a user or group does not exist in the token's tenant
+	ErrNotFound = errors.New("resource not found")
+
+	// ErrTokenNotFound is returned when a SCIM token does not exist
+	ErrTokenNotFound = errors.New("scim token not found")
+
+	// ErrInvalidToken is returned for an unknown or expired bearer token
+	ErrInvalidToken = errors.New("invalid scim token")
+
+	// ErrUniqueness is returned when a userName or displayName is already taken in the tenant
+	ErrUniqueness = errors.New("resource already exists")
+
+	// ErrInvalidFilter is returned for a filter that cannot be parsed or uses an unsupported attribute or operator
+	ErrInvalidFilter = errors.New("invalid filter")
+
+	// ErrInvalidPath is returned for a PATCH path that does not name a supported attribute
+	ErrInvalidPath = errors.New("invalid path")
+
+	// ErrInvalidValue is returned for a missing or malformed attribute value
+	ErrInvalidValue = errors.New("invalid value")
+)
@@ -0,0 +1,167 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Filter operators; RFC 7644 section 3.4.2.2 defines more, which are rejected
+const (
+	opEqual      = "eq"
+	opStartsWith = "sw"
+)
+
+// condition is a single "attribute operator value" comparison of a filter
+type condition struct {
+	attr  string // Lowercased attribute path
+	op    string
+	value interface{} // string or bool
+}
+
+// parseFilter parses the supported filter subset: comparisons with eq or sw, joined by "and"
+// Attribute names are case-insensitive; values are JSON strings or booleans
+func parseFilter(filter string) ([]condition, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var conditions []condition
+	for i := 0; ; i += 4 {
+		if i+3 > len(tokens) {
+			return nil, fmt.Errorf("%w: incomplete comparison", ErrInvalidFilter)
+		}
+		op := strings.ToLower(tokens[i+1])
+		if op != opEqual && op != opStartsWith {
+			return nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidFilter, tokens[i+1])
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(tokens[i+2]), &value); err != nil {
+			return nil, fmt.Errorf("%w: invalid value %s", ErrInvalidFilter, tokens[i+2])
+		}
+		switch value.(type) {
+		case string:
+		case bool:
+			if op != opEqual {
+				return nil, fmt.Errorf("%w: %s needs a string", ErrInvalidFilter, op)
+			}
+		default:
+			return nil, fmt.Errorf("%w: invalid value %s", ErrInvalidFilter, tokens[i+2])
+		}
+		conditions = append(conditions, condition{attr: strings.ToLower(tokens[i]), op: op, value: value})
+
+		if i+3 == len(tokens) {
+			return conditions, nil
+		}
+		if !strings.EqualFold(tokens[i+3], "and") {
+			return nil, fmt.Errorf("%w: unsupported logical operator %q", ErrInvalidFilter, tokens[i+3])
+		}
+	}
+}
+
+// tokenize splits a filter on spaces outside of quoted strings
+func tokenize(filter string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inString, escaped := false, false
+	for _, r := range filter {
+		switch {
+		case inString:
+			current.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+			current.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			return nil, fmt.Errorf("%w: grouping and complex attribute filters are not supported", ErrInvalidFilter)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inString {
+		return nil, fmt.Errorf("%w: unterminated string", ErrInvalidFilter)
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// column maps a filterable attribute to a database column
+type column struct {
+	name       string
+	caseExact  bool // Compare as is; otherwise case-insensitively
+	isBool     bool
+	isID       bool
+	equalsOnly bool
+}
+
+// applyFilter narrows the query to the rows matching every condition
+func applyFilter(query *gorm.DB, conditions []condition, columns map[string]column) (*gorm.DB, error) {
+	for _, cond := range conditions {
+		col, ok := columns[cond.attr]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported attribute %q", ErrInvalidFilter, cond.attr)
+		}
+		if col.equalsOnly && cond.op != opEqual {
+			return nil, fmt.Errorf("%w: %s only supports eq", ErrInvalidFilter, cond.attr)
+		}
+
+		if col.isBool {
+			b, ok := cond.value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s needs true or false", ErrInvalidFilter, cond.attr)
+			}
+			query = query.Where(col.name+" = ?", b)
+			continue
+		}
+		value, ok := cond.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s needs a string", ErrInvalidFilter, cond.attr)
+		}
+
+		switch {
+		case col.isID:
+			// Unknown IDs match nothing rather than failing the query on the uuid column
+			id, err := uuid.Parse(value)
+			if err != nil {
+				query = query.Where("1 = 0")
+			} else {
+				query = query.Where(col.name+" = ?", id)
+			}
+		case cond.op == opStartsWith && col.caseExact:
+			query = query.Where(col.name+` LIKE ? ESCAPE '\'`, escapeLike(value)+"%")
+		case cond.op == opStartsWith:
+			query = query.Where("LOWER("+col.name+`) LIKE ? ESCAPE '\'`, escapeLike(strings.ToLower(value))+"%")
+		case col.caseExact:
+			query = query.Where(col.name+" = ?", value)
+		default:
+			query = query.Where("LOWER("+col.name+") = ?", strings.ToLower(value))
+		}
+	}
+	return query, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,400 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// groupColumns are the group attributes a filter can compare; members.value is handled separately
+var groupColumns = map[string]column{
+	"id":          {name: "id", isID: true},
+	"displayname": {name: "name"},
+	"externalid":  {name: "external_id", caseExact: true},
+}
+
+// memberPath matches the value-filtered member path IdPs use to remove a single member
+var memberPath = regexp.MustCompile(`(?i)^members\[value eq "([^"]+)"\]$`)
+
+func (s *service) ListGroups(tenantID uuid.UUID, q *ListQuery) (*ListResponse, error) {
+	conditions, err := parseFilter(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&group.Group{}).Where("tenant_id = ?", tenantID)
+	var columnConditions []condition
+	for _, cond := range conditions {
+		if cond.attr != "members" && cond.attr != "members.value" {
+			columnConditions = append(columnConditions, cond)
+			continue
+		}
+		value, ok := cond.value.(string)
+		if !ok || cond.op != opEqual {
+			return nil, fmt.Errorf("%w: members.value only supports eq with a user ID", ErrInvalidFilter)
+		}
+		memberID, err := uuid.Parse(value)
+		if err != nil {
+			query = query.Where("1 = 0")
+			continue
+		}
+		query = query.Where("id IN (?)", s.db.Model(&group.Membership{}).Select("group_id").Where("user_id = ?", memberID))
+	}
+	if query, err = applyFilter(query, columnConditions, groupColumns); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count groups: %w", err)
+	}
+	offset, limit := page(q)
+	var groups []*group.Group
+	if limit > 0 {
+		if err := query.Order("created_at, id").Offset(offset).Limit(limit).Find(&groups).Error; err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", err)
+		}
+	}
+
+	resources := make([]*Group, len(groups))
+	for i, g := range groups {
+		if resources[i], err = s.groupResource(g, q.ExcludeMembers); err != nil {
+			return nil, err
+		}
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   q.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *service) GetGroup(tenantID, id uuid.UUID, excludeMembers bool) (*Group, error) {
+	g, err := s.findGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(g, excludeMembers)
+}
+
+func (s *service) CreateGroup(tenantID uuid.UUID, req *Group) (*Group, error) {
+	st, err := newGroupState(req)
+	if err != nil {
+		return nil, err
+	}
+	if st.name == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+	}
+
+	created, err := s.groupService.Create(tenantID, &group.CreateGroupRequest{TenantID: tenantID.String(), Name: st.name})
+	if err != nil {
+		return nil, groupError(err)
+	}
+	if err := s.saveGroup(created, st, nil); err != nil {
+		// Leave no half-provisioned group behind for the IdP's retry to collide with
+		if deleteErr := s.groupService.Delete(created.ID); deleteErr != nil {
+			s.logger.Error("Failed to remove partially provisioned group", zap.Error(deleteErr), zap.String("group_id", created.ID.String()))
+		}
+		return nil, err
+	}
+
+	s.logger.Info("Group provisioned over SCIM", zap.String("group_id", created.ID.String()), zap.String("tenant_id", tenantID.String()))
+	return s.GetGroup(tenantID, created.ID, false)
+}
+
+func (s *service) ReplaceGroup(tenantID, id uuid.UUID, req *Group) (*Group, error) {
+	g, err := s.findGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	st, err := newGroupState(req)
+	if err != nil {
+		return nil, err
+	}
+	if st.name == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+	}
+
+	current, err := s.memberIDs(g.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveGroup(g, st, current); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(tenantID, id, false)
+}
+
+// PatchGroup applies PATCH operations to a group
+// The response leaves members out, since IdPs patch large groups a few members at a time
+func (s *service) PatchGroup(tenantID, id uuid.UUID, req *PatchRequest) (*Group, error) {
+	g, err := s.findGroup(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.memberIDs(g.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &groupState{name: g.Name, externalID: g.ExternalID, members: make(map[uuid.UUID]bool, len(current))}
+	for _, memberID := range current {
+		st.members[memberID] = true
+	}
+	for _, op := range req.Operations {
+		if err := st.patch(op); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveGroup(g, st, current); err != nil {
+		return nil, err
+	}
+	return s.GetGroup(tenantID, id, true)
+}
+
+func (s *service) DeleteGroup(tenantID, id uuid.UUID) error {
+	if _, err := s.findGroup(tenantID, id); err != nil {
+		return err
+	}
+	if err := s.groupService.Delete(id); err != nil {
+		return groupError(err)
+	}
+	return nil
+}
+
+func (s *service) findGroup(tenantID, id uuid.UUID) (*group.Group, error) {
+	g, err := s.groupService.Get(id)
+	if err != nil {
+		if errors.Is(err, group.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if g.TenantID != tenantID {
+		return nil, ErrNotFound
+	}
+	return g, nil
+}
+
+// saveGroup writes a group's new state; current lists its direct members before the change
+func (s *service) saveGroup(g *group.Group, st *groupState, current []uuid.UUID) error {
+	if st.name != g.Name {
+		if _, err := s.groupService.Update(g.ID, &group.UpdateGroupRequest{Name: st.name}); err != nil {
+			return groupError(err)
+		}
+	}
+	if err := s.db.Model(&group.Group{}).Where("id = ?", g.ID).Update("external_id", st.externalID).Error; err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+
+	if st.members == nil {
+		return nil
+	}
+	var removed []uuid.UUID
+	for _, memberID := range current {
+		if !st.members[memberID] {
+			removed = append(removed, memberID)
+		}
+		delete(st.members, memberID)
+	}
+	added := make([]uuid.UUID, 0, len(st.members))
+	for memberID, member := range st.members {
+		if member {
+			added = append(added, memberID)
+		}
+	}
+	if len(removed) > 0 {
+		if _, err := s.groupService.RemoveMembers(g.ID, removed); err != nil {
+			return groupError(err)
+		}
+	}
+	if len(added) > 0 {
+		if _, err := s.groupService.AddMembers(g.ID, added); err != nil {
+			return groupError(err)
+		}
+	}
+	return nil
+}
+
+// memberIDs returns the group's direct members
+func (s *service) memberIDs(groupID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := s.db.Model(&group.Membership{}).Where("group_id = ?", groupID).Pluck("user_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *service) groupResource(g *group.Group, excludeMembers bool) (*Group, error) {
+	resource := &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          g.ID.String(),
+		DisplayName: g.Name,
+		Meta:        meta("Group", s.baseURL+"/Groups/"+g.ID.String(), g.CreatedAt, g.UpdatedAt),
+	}
+	if g.ExternalID != nil {
+		resource.ExternalID = *g.ExternalID
+	}
+	if excludeMembers {
+		return resource, nil
+	}
+
+	var members []*user.User
+	if err := s.db.Model(&user.User{}).
+		Joins("JOIN group_members ON group_members.user_id = users.id").
+		Where("group_members.group_id = ?", g.ID).
+		Order("users.email").
+		Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to get group members: %w", err)
+	}
+	for _, member := range members {
+		resource.Members = append(resource.Members, MultiValue{
+			Value:   member.ID.String(),
+			Display: member.Email,
+			Ref:     s.baseURL + "/Users/" + member.ID.String(),
+		})
+	}
+	return resource, nil
+}
+
+// groupError translates group service errors into SCIM errors
+func groupError(err error) error {
+	switch {
+	case errors.Is(err, group.ErrDuplicateName):
+		return fmt.Errorf("%w: %v", ErrUniqueness, err)
+	case errors.Is(err, group.ErrUnknownUsers):
+		return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	case errors.Is(err, group.ErrNotFound):
+		return ErrNotFound
+	}
+	return err
+}
+
+// groupState is a group's attributes as SCIM operations change them
+// members is nil when the operations leave membership alone
+type groupState struct {
+	name       string
+	externalID *string
+	members    map[uuid.UUID]bool
+}
+
+// newGroupState reads a full group resource, as sent by POST and PUT
+func newGroupState(req *Group) (*groupState, error) {
+	st := &groupState{name: strings.TrimSpace(req.DisplayName), members: map[uuid.UUID]bool{}}
+	if externalID := strings.TrimSpace(req.ExternalID); externalID != "" {
+		st.externalID = &externalID
+	}
+	for _, member := range req.Members {
+		memberID, err := uuid.Parse(member.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown member %q", ErrInvalidValue, member.Value)
+		}
+		st.members[memberID] = true
+	}
+	return st, nil
+}
+
+func (st *groupState) patch(op PatchOperation) error {
+	name, err := operation(op)
+	if err != nil {
+		return err
+	}
+
+	path := strings.TrimSpace(op.Path)
+	path = strings.TrimPrefix(path, SchemaGroup+":")
+	if path == "" {
+		if name == "remove" {
+			return fmt.Errorf("%w: remove needs a path", ErrInvalidPath)
+		}
+		values, err := objectValue(op.Value)
+		if err != nil {
+			return err
+		}
+		for attr, value := range values {
+			if err := st.apply(name, attr, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if match := memberPath.FindStringSubmatch(path); match != nil && name == "remove" {
+		memberID, err := uuid.Parse(match[1])
+		if err != nil {
+			return fmt.Errorf("%w: unknown member %q", ErrInvalidValue, match[1])
+		}
+		delete(st.members, memberID)
+		return nil
+	}
+	return st.apply(name, strings.ToLower(path), op.Value)
+}
+
+func (st *groupState) apply(op, attr string, value json.RawMessage) error {
+	switch attr {
+	case "displayname":
+		if op == "remove" {
+			return fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+		}
+		name, err := stringValue(value)
+		if err != nil || name == "" {
+			return fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+		}
+		st.name = name
+	case "externalid":
+		st.externalID = nil
+		if op != "remove" {
+			externalID, err := stringValue(value)
+			if err != nil {
+				return err
+			}
+			if externalID != "" {
+				st.externalID = &externalID
+			}
+		}
+	case "members":
+		var members []MultiValue
+		if len(value) > 0 && string(value) != "null" {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return fmt.Errorf("%w: members must be a list", ErrInvalidValue)
+			}
+		}
+		ids := make([]uuid.UUID, len(members))
+		for i, member := range members {
+			memberID, err := uuid.Parse(member.Value)
+			if err != nil {
+				return fmt.Errorf("%w: unknown member %q", ErrInvalidValue, member.Value)
+			}
+			ids[i] = memberID
+		}
+
+		switch {
+		case op == "replace":
+			st.members = make(map[uuid.UUID]bool, len(ids))
+			fallthrough
+		case op == "add":
+			for _, id := range ids {
+				st.members[id] = true
+			}
+		case len(ids) == 0:
+			// Removing members without a value removes them all
+			st.members = map[uuid.UUID]bool{}
+		default:
+			for _, id := range ids {
+				delete(st.members, id)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidPath, attr)
+	}
+	return nil
+}
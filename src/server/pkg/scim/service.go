@@ -0,0 +1,215 @@
+package scim
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Service provisions a tenant's users and groups for its IdP over SCIM 2.0
+// Every resource operation is scoped to the tenant of the authenticated token
+type Service interface {
+	CreateToken(tenantID uuid.UUID, req *CreateTokenRequest) (*Token, string, error)
+	ListTokens(tenantID uuid.UUID) ([]*Token, error)
+	DeleteToken(id uuid.UUID) error
+	Authenticate(token string) (*Token, error)
+
+	ListUsers(tenantID uuid.UUID, q *ListQuery) (*ListResponse, error)
+	GetUser(tenantID, id uuid.UUID) (*User, error)
+	CreateUser(tenantID uuid.UUID, req *User) (*User, error)
+	ReplaceUser(tenantID, id uuid.UUID, req *User) (*User, bool, error)
+	PatchUser(tenantID, id uuid.UUID, req *PatchRequest) (*User, bool, error)
+	DeleteUser(tenantID, id uuid.UUID) error
+
+	ListGroups(tenantID uuid.UUID, q *ListQuery) (*ListResponse, error)
+	GetGroup(tenantID, id uuid.UUID, excludeMembers bool) (*Group, error)
+	CreateGroup(tenantID uuid.UUID, req *Group) (*Group, error)
+	ReplaceGroup(tenantID, id uuid.UUID, req *Group) (*Group, error)
+	PatchGroup(tenantID, id uuid.UUID, req *PatchRequest) (*Group, error)
+	DeleteGroup(tenantID, id uuid.UUID) error
+}
+
+type service struct {
+	db           *gorm.DB
+	logger       *zap.Logger
+	userService  user.Service
+	groupService group.Service
+	validator    *validator.Validate
+	baseURL      string // SCIM base URL, prefixing resource locations
+}
+
+func NewService(db *gorm.DB, logger *zap.Logger, userService user.Service, groupService group.Service, baseURL string) Service {
+	return &service{
+		db:           db,
+		logger:       logger,
+		userService:  userService,
+		groupService: groupService,
+		validator:    validator.New(),
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// CreateToken issues a bearer token for the tenant's IdP and returns it with its record
+// The token cannot be retrieved again
+func (s *service) CreateToken(tenantID uuid.UUID, req *CreateTokenRequest) (*Token, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate scim token: %w", err)
+	}
+	plain := TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	token := &Token{
+		TenantID:  tenantID,
+		Name:      strings.TrimSpace(req.Name),
+		TokenHash: hashToken(plain),
+		Hint:      plain[len(plain)-4:],
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.db.Create(token).Error; err != nil {
+		s.logger.Error("Failed to create scim token", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, "", fmt.Errorf("failed to create scim token: %w", err)
+	}
+
+	s.logger.Info("SCIM token created",
+		zap.String("token_id", token.ID.String()),
+		zap.String("tenant_id", tenantID.String()))
+	return token, plain, nil
+}
+
+func (s *service) ListTokens(tenantID uuid.UUID) ([]*Token, error) {
+	var tokens []*Token
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("created_at").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to list scim tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *service) DeleteToken(id uuid.UUID) error {
+	result := s.db.Where("id = ?", id).Delete(&Token{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete scim token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the record of a valid bearer token
+func (s *service) Authenticate(plain string) (*Token, error) {
+	if !strings.HasPrefix(plain, TokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	var token Token
+	if err := s.db.Where("token_hash = ?", hashToken(plain)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("failed to get scim token: %w", err)
+	}
+	if token.IsExpired() {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if err := s.db.Model(&token).Update("last_used_at", now).Error; err != nil {
+		s.logger.Warn("Failed to record scim token use", zap.Error(err), zap.String("token_id", token.ID.String()))
+	}
+	token.LastUsedAt = &now
+	return &token, nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash under which a token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// page normalizes a list query's pagination
+func page(q *ListQuery) (offset, limit int) {
+	if q.StartIndex < 1 {
+		q.StartIndex = 1
+	}
+	if q.Count < 0 {
+		q.Count = 0
+	}
+	if q.Count > MaxCount {
+		q.Count = MaxCount
+	}
+	return q.StartIndex - 1, q.Count
+}
+
+// stringValue decodes a PATCH value holding a string
+func stringValue(raw json.RawMessage) (string, error) {
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", fmt.Errorf("%w: expected a string", ErrInvalidValue)
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// boolValue decodes a PATCH value holding a boolean
+// Azure AD sends booleans as the strings "True" and "False"
+func boolValue(raw json.RawMessage) (bool, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err == nil {
+		switch b := v.(type) {
+		case bool:
+			return b, nil
+		case string:
+			switch strings.ToLower(b) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+		}
+	}
+	return false, fmt.Errorf("%w: expected a boolean", ErrInvalidValue)
+}
+
+// objectValue decodes a PATCH value holding an object, keyed by lowercased attribute path
+func objectValue(raw json.RawMessage) (map[string]json.RawMessage, error) {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+		return nil, fmt.Errorf("%w: expected an object", ErrInvalidValue)
+	}
+	lowered := make(map[string]json.RawMessage, len(v))
+	for k, value := range v {
+		lowered[strings.ToLower(k)] = value
+	}
+	return lowered, nil
+}
+
+// operation returns the lowercased op of a PATCH operation, rejecting unknown ones
+func operation(op PatchOperation) (string, error) {
+	switch name := strings.ToLower(op.Op); name {
+	case "add", "replace", "remove":
+		return name, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported op %q", ErrInvalidValue, op.Op)
+	}
+}
+
+func meta(resourceType, location string, created, modified time.Time) *Meta {
+	return &Meta{
+		ResourceType: resourceType,
+		Created:      &created,
+		LastModified: &modified,
+		Location:     location,
+	}
+}
@@ -0,0 +1,281 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/group"
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestService(t *testing.T) (*service, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&user.User{}, &group.Group{}, &group.Membership{}, &Token{}))
+
+	logger := zap.NewNop()
+	svc := NewService(db, logger, user.NewService(db, logger), group.NewService(db, logger), "https://auth.example.com/scim/v2/")
+	return svc.(*service), db
+}
+
+func patch(t *testing.T, ops ...string) *PatchRequest {
+	req := &PatchRequest{Schemas: []string{SchemaPatchOp}}
+	for _, op := range ops {
+		var operation PatchOperation
+		require.NoError(t, json.Unmarshal([]byte(op), &operation))
+		req.Operations = append(req.Operations, operation)
+	}
+	return req
+}
+
+func TestParseFilter(t *testing.T) {
+	conditions, err := parseFilter(`userName Eq "alice@example.com" and active eq true and externalId sw "a \"b\""`)
+	require.NoError(t, err)
+	assert.Equal(t, []condition{
+		{attr: "username", op: opEqual, value: "alice@example.com"},
+		{attr: "active", op: opEqual, value: true},
+		{attr: "externalid", op: opStartsWith, value: `a "b"`},
+	}, conditions)
+
+	conditions, err = parseFilter(`displayName eq "Sales (EMEA) [all]"`)
+	require.NoError(t, err)
+	assert.Equal(t, "Sales (EMEA) [all]", conditions[0].value)
+
+	for _, filter := range []string{
+		`userName co "alice"`,
+		`userName eq "alice" or userName eq "bob"`,
+		`(userName eq "alice")`,
+		`emails[type eq "work"]`,
+		`userName eq`,
+		`userName eq "alice`,
+		`userName eq alice`,
+		`active sw true`,
+	} {
+		_, err := parseFilter(filter)
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
+
+func TestService_Tokens(t *testing.T) {
+	svc, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	token, plain, err := svc.CreateToken(tenantID, &CreateTokenRequest{Name: "Okta"})
+	require.NoError(t, err)
+	assert.Contains(t, plain, TokenPrefix)
+	assert.Equal(t, plain[len(plain)-4:], token.Hint)
+	assert.NotContains(t, token.TokenHash, plain)
+
+	authenticated, err := svc.Authenticate(plain)
+	require.NoError(t, err)
+	assert.Equal(t, tenantID, authenticated.TenantID)
+	assert.NotNil(t, authenticated.LastUsedAt)
+
+	_, err = svc.Authenticate(plain + "x")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	expired := time.Now().Add(-time.Minute)
+	_, expiredPlain, err := svc.CreateToken(tenantID, &CreateTokenRequest{Name: "Old", ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = svc.Authenticate(expiredPlain)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, svc.DeleteToken(token.ID))
+	_, err = svc.Authenticate(plain)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.ErrorIs(t, svc.DeleteToken(token.ID), ErrTokenNotFound)
+}
+
+func TestService_UserLifecycle(t *testing.T) {
+	svc, db := setupTestService(t)
+	tenantID := uuid.New()
+	otherTenantID := uuid.New()
+
+	created, err := svc.CreateUser(tenantID, &User{
+		Schemas:    []string{SchemaUser},
+		UserName:   "alice@example.com",
+		ExternalID: "00u1",
+		Name:       &Name{GivenName: "Alice", FamilyName: "Smith"},
+		Password:   "correct horse battery",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice Smith", created.DisplayName)
+	assert.True(t, *created.Active)
+	assert.Empty(t, created.Password)
+	assert.Equal(t, "https://auth.example.com/scim/v2/Users/"+created.ID, created.Meta.Location)
+
+	id := uuid.MustParse(created.ID)
+	var stored user.User
+	require.NoError(t, db.First(&stored, "id = ?", id).Error)
+	assert.True(t, stored.EmailVerified)
+	ok, err := user.CheckPassword(stored.PasswordHash, "correct horse battery")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = svc.CreateUser(tenantID, &User{UserName: "ALICE@example.com"})
+	assert.ErrorIs(t, err, ErrUniqueness)
+	_, err = svc.CreateUser(tenantID, &User{UserName: "not an email"})
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	_, err = svc.CreateUser(otherTenantID, &User{UserName: "alice@example.com"})
+	require.NoError(t, err)
+
+	// Resources of other tenants are invisible
+	_, err = svc.GetUser(otherTenantID, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Azure AD style: string booleans and a path-less operation keyed by attribute paths
+	patched, deactivated, err := svc.PatchUser(tenantID, id, patch(t,
+		`{"op":"Replace","path":"name.familyName","value":"Jones"}`,
+		`{"op":"Add","value":{"externalId":"00u2","emails[type eq \"work\"].value":"alice.jones@example.com"}}`,
+		`{"op":"Replace","path":"active","value":"False"}`,
+	))
+	require.NoError(t, err)
+	assert.True(t, deactivated)
+	assert.False(t, *patched.Active)
+	assert.Equal(t, "Alice Jones", patched.DisplayName)
+	assert.Equal(t, "alice.jones@example.com", patched.UserName)
+	assert.Equal(t, "00u2", patched.ExternalID)
+
+	// Already inactive: no second deprovisioning
+	_, deactivated, err = svc.PatchUser(tenantID, id, patch(t, `{"op":"replace","value":{"active":false}}`))
+	require.NoError(t, err)
+	assert.False(t, deactivated)
+
+	_, _, err = svc.PatchUser(tenantID, id, patch(t, `{"op":"replace","path":"nickName","value":"Al"}`))
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	_, _, err = svc.PatchUser(tenantID, id, patch(t, `{"op":"move","path":"active","value":true}`))
+	assert.ErrorIs(t, err, ErrInvalidValue)
+
+	active := true
+	replaced, deactivated, err := svc.ReplaceUser(tenantID, id, &User{UserName: "alice.jones@example.com", DisplayName: "Alice J.", Active: &active})
+	require.NoError(t, err)
+	assert.False(t, deactivated)
+	assert.True(t, *replaced.Active)
+	assert.Empty(t, replaced.ExternalID)
+
+	require.NoError(t, svc.DeleteUser(tenantID, id))
+	_, err = svc.GetUser(tenantID, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, svc.DeleteUser(tenantID, id), ErrNotFound)
+}
+
+func TestService_ListUsers(t *testing.T) {
+	svc, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	for _, email := range []string{"alice@example.com", "albert@example.com", "bob@example.com", "al_x@example.com"} {
+		_, err := svc.CreateUser(tenantID, &User{UserName: email, ExternalID: "ext-" + email})
+		require.NoError(t, err)
+	}
+	_, err := svc.CreateUser(uuid.New(), &User{UserName: "alice@example.com"})
+	require.NoError(t, err)
+
+	list, err := svc.ListUsers(tenantID, &ListQuery{Filter: `userName eq "ALICE@example.com"`, Count: DefaultCount})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.TotalResults)
+	assert.Equal(t, "alice@example.com", list.Resources.([]*User)[0].UserName)
+
+	// sw escapes LIKE wildcards
+	list, err = svc.ListUsers(tenantID, &ListQuery{Filter: `userName sw "al_"`, Count: DefaultCount})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), list.TotalResults)
+
+	list, err = svc.ListUsers(tenantID, &ListQuery{Filter: `userName sw "al" and active eq true`, StartIndex: 2, Count: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), list.TotalResults)
+	assert.Equal(t, 2, list.StartIndex)
+	assert.Equal(t, 1, list.ItemsPerPage)
+
+	list, err = svc.ListUsers(tenantID, &ListQuery{Filter: `externalId eq "EXT-bob@example.com"`, Count: DefaultCount})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalResults)
+
+	list, err = svc.ListUsers(tenantID, &ListQuery{Filter: `id eq "not-a-uuid"`, Count: DefaultCount})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalResults)
+
+	list, err = svc.ListUsers(tenantID, &ListQuery{Count: 0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), list.TotalResults)
+	assert.Empty(t, list.Resources)
+
+	_, err = svc.ListUsers(tenantID, &ListQuery{Filter: `title eq "CEO"`, Count: DefaultCount})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestService_Groups(t *testing.T) {
+	svc, _ := setupTestService(t)
+	tenantID := uuid.New()
+
+	var ids []string
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		u, err := svc.CreateUser(tenantID, &User{UserName: email})
+		require.NoError(t, err)
+		ids = append(ids, u.ID)
+	}
+	outsider, err := svc.CreateUser(uuid.New(), &User{UserName: "mallory@example.com"})
+	require.NoError(t, err)
+
+	created, err := svc.CreateGroup(tenantID, &Group{
+		DisplayName: "Engineering",
+		ExternalID:  "grp-1",
+		Members:     []MultiValue{{Value: ids[0]}, {Value: ids[1]}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, created.Members, 2)
+	groupID := uuid.MustParse(created.ID)
+
+	_, err = svc.CreateGroup(tenantID, &Group{DisplayName: "Engineering"})
+	assert.ErrorIs(t, err, ErrUniqueness)
+	// Users of other tenants cannot be added, and nothing is left behind
+	_, err = svc.CreateGroup(tenantID, &Group{DisplayName: "Sales", Members: []MultiValue{{Value: outsider.ID}}})
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	list, err := svc.ListGroups(tenantID, &ListQuery{Filter: `displayName eq "sales"`, Count: DefaultCount})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalResults)
+
+	alice, err := svc.GetUser(tenantID, uuid.MustParse(ids[0]))
+	require.NoError(t, err)
+	require.Len(t, alice.Groups, 1)
+	assert.Equal(t, "Engineering", alice.Groups[0].Display)
+
+	patched, err := svc.PatchGroup(tenantID, groupID, patch(t,
+		`{"op":"add","path":"members","value":[{"value":"`+ids[2]+`"}]}`,
+		`{"op":"remove","path":"members[value eq \"`+ids[0]+`\"]"}`,
+		`{"op":"replace","value":{"displayName":"Platform"}}`,
+	))
+	require.NoError(t, err)
+	assert.Equal(t, "Platform", patched.DisplayName)
+	assert.Empty(t, patched.Members)
+
+	found, err := svc.GetGroup(tenantID, groupID, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{ids[1], ids[2]}, []string{found.Members[0].Value, found.Members[1].Value})
+
+	list, err = svc.ListGroups(tenantID, &ListQuery{Filter: `members.value eq "` + ids[2] + `" and externalId eq "grp-1"`, Count: DefaultCount, ExcludeMembers: true})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), list.TotalResults)
+	assert.Empty(t, list.Resources.([]*Group)[0].Members)
+
+	// Azure AD removes members with a value list
+	_, err = svc.PatchGroup(tenantID, groupID, patch(t, `{"op":"Remove","path":"members","value":[{"value":"`+ids[1]+`"}]}`))
+	require.NoError(t, err)
+	replaced, err := svc.ReplaceGroup(tenantID, groupID, &Group{DisplayName: "Platform", Members: []MultiValue{{Value: ids[0]}}})
+	require.NoError(t, err)
+	require.Len(t, replaced.Members, 1)
+	assert.Equal(t, ids[0], replaced.Members[0].Value)
+	assert.Empty(t, replaced.ExternalID)
+
+	_, err = svc.GetGroup(uuid.New(), groupID, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, svc.DeleteGroup(tenantID, groupID))
+	_, err = svc.GetGroup(tenantID, groupID, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
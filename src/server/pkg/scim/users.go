@@ -0,0 +1,454 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"authway/src/server/pkg/user"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// userColumns are the user attributes a filter can compare
+var userColumns = map[string]column{
+	"id":             {name: "id", isID: true},
+	"username":       {name: "email"},
+	"emails":         {name: "email"},
+	"emails.value":   {name: "email"},
+	"externalid":     {name: "external_id", caseExact: true},
+	"displayname":    {name: "name"},
+	"name.formatted": {name: "name"},
+	"active":         {name: "active", isBool: true},
+}
+
+func (s *service) ListUsers(tenantID uuid.UUID, q *ListQuery) (*ListResponse, error) {
+	conditions, err := parseFilter(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	query, err := applyFilter(s.db.Model(&user.User{}).Where("tenant_id = ?", tenantID), conditions, userColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	offset, limit := page(q)
+	var users []*user.User
+	if limit > 0 {
+		if err := query.Order("created_at, id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+	}
+
+	groups, err := s.userGroups(users)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]*User, len(users))
+	for i, u := range users {
+		resources[i] = s.toUser(u, groups[u.ID])
+	}
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   q.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *service) GetUser(tenantID, id uuid.UUID) (*User, error) {
+	u, err := s.findUser(tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.userResource(u)
+}
+
+// CreateUser provisions a user; its email is verified since the IdP vouches for it
+func (s *service) CreateUser(tenantID uuid.UUID, req *User) (*User, error) {
+	st := newUserState(&user.User{Active: true})
+	if err := st.replace(req); err != nil {
+		return nil, err
+	}
+	if err := s.checkUser(tenantID, uuid.Nil, st); err != nil {
+		return nil, err
+	}
+
+	u := &user.User{
+		TenantID:      tenantID,
+		Email:         st.email,
+		EmailVerified: true,
+		Active:        st.active,
+		Provider:      user.ProviderLocal,
+		ExternalID:    st.externalID,
+	}
+	if name := st.fullName(); name != "" {
+		u.Name = &name
+	}
+	if st.password != "" {
+		hashed, err := user.HashPassword(st.password)
+		if err != nil {
+			return nil, err
+		}
+		u.PasswordHash = hashed
+	}
+	if err := s.db.Create(u).Error; err != nil {
+		s.logger.Error("Failed to provision user", zap.Error(err), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+
+	s.logger.Info("User provisioned over SCIM", zap.String("user_id", u.ID.String()), zap.String("tenant_id", tenantID.String()))
+	return s.userResource(u)
+}
+
+// ReplaceUser replaces a user's attributes; the result reports whether the user was deactivated
+func (s *service) ReplaceUser(tenantID, id uuid.UUID, req *User) (*User, bool, error) {
+	u, err := s.findUser(tenantID, id)
+	if err != nil {
+		return nil, false, err
+	}
+	st := newUserState(u)
+	if err := st.replace(req); err != nil {
+		return nil, false, err
+	}
+	return s.saveUser(u, st)
+}
+
+// PatchUser applies PATCH operations to a user; the result reports whether the user was deactivated
+func (s *service) PatchUser(tenantID, id uuid.UUID, req *PatchRequest) (*User, bool, error) {
+	u, err := s.findUser(tenantID, id)
+	if err != nil {
+		return nil, false, err
+	}
+	st := newUserState(u)
+	for _, op := range req.Operations {
+		if err := st.patch(op); err != nil {
+			return nil, false, err
+		}
+	}
+	return s.saveUser(u, st)
+}
+
+// DeleteUser deprovisions a user
+func (s *service) DeleteUser(tenantID, id uuid.UUID) error {
+	if _, err := s.findUser(tenantID, id); err != nil {
+		return err
+	}
+	if err := s.userService.Delete(id); err != nil {
+		return err
+	}
+	s.logger.Info("User deprovisioned over SCIM", zap.String("user_id", id.String()), zap.String("tenant_id", tenantID.String()))
+	return nil
+}
+
+func (s *service) findUser(tenantID, id uuid.UUID) (*user.User, error) {
+	var u user.User
+	if err := s.db.Where("tenant_id = ? AND id = ?", tenantID, id).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &u, nil
+}
+
+// checkUser validates a user's new state, requiring its email to be free in the tenant
+func (s *service) checkUser(tenantID, id uuid.UUID, st *userState) error {
+	if s.validator.Var(st.email, "required,email") != nil {
+		return fmt.Errorf("%w: userName must be an email address", ErrInvalidValue)
+	}
+	if len(st.fullName()) > 255 {
+		return fmt.Errorf("%w: name is longer than 255 characters", ErrInvalidValue)
+	}
+
+	var count int64
+	if err := s.db.Model(&user.User{}).
+		Where("tenant_id = ? AND LOWER(email) = ? AND id <> ?", tenantID, strings.ToLower(st.email), id).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: userName %s", ErrUniqueness, st.email)
+	}
+	return nil
+}
+
+func (s *service) saveUser(u *user.User, st *userState) (*User, bool, error) {
+	if err := s.checkUser(u.TenantID, u.ID, st); err != nil {
+		return nil, false, err
+	}
+
+	updates := map[string]interface{}{
+		"active":      st.active,
+		"external_id": st.externalID,
+		"name":        nil,
+	}
+	if name := st.fullName(); name != "" {
+		updates["name"] = name
+	}
+	if st.email != u.Email {
+		updates["email"] = st.email
+		updates["email_verified"] = true
+	}
+	if st.password != "" {
+		hashed, err := user.HashPassword(st.password)
+		if err != nil {
+			return nil, false, err
+		}
+		updates["password_hash"] = hashed
+	}
+
+	deactivated := u.Active && !st.active
+	if err := s.db.Model(u).Updates(updates).Error; err != nil {
+		s.logger.Error("Failed to update provisioned user", zap.Error(err), zap.String("user_id", u.ID.String()))
+		return nil, false, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	reloaded, err := s.findUser(u.TenantID, u.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	resource, err := s.userResource(reloaded)
+	return resource, deactivated, err
+}
+
+func (s *service) userResource(u *user.User) (*User, error) {
+	groups, err := s.userGroups([]*user.User{u})
+	if err != nil {
+		return nil, err
+	}
+	return s.toUser(u, groups[u.ID]), nil
+}
+
+// userGroups returns the groups each user is a direct member of
+func (s *service) userGroups(users []*user.User) (map[uuid.UUID][]MultiValue, error) {
+	groups := make(map[uuid.UUID][]MultiValue, len(users))
+	if len(users) == 0 {
+		return groups, nil
+	}
+	ids := make([]uuid.UUID, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+
+	var rows []struct {
+		UserID  uuid.UUID
+		GroupID uuid.UUID
+		Name    string
+	}
+	if err := s.db.Table("group_members").
+		Select("group_members.user_id, groups.id AS group_id, groups.name").
+		Joins("JOIN groups ON groups.id = group_members.group_id").
+		Where("group_members.user_id IN ?", ids).
+		Order("groups.name").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	for _, row := range rows {
+		groups[row.UserID] = append(groups[row.UserID], MultiValue{
+			Value:   row.GroupID.String(),
+			Display: row.Name,
+			Ref:     s.baseURL + "/Groups/" + row.GroupID.String(),
+		})
+	}
+	return groups, nil
+}
+
+func (s *service) toUser(u *user.User, groups []MultiValue) *User {
+	active := u.Active
+	resource := &User{
+		Schemas:  []string{SchemaUser},
+		ID:       u.ID.String(),
+		UserName: u.Email,
+		Emails:   []MultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Active:   &active,
+		Groups:   groups,
+		Meta:     meta("User", s.baseURL+"/Users/"+u.ID.String(), u.CreatedAt, u.UpdatedAt),
+	}
+	if u.ExternalID != nil {
+		resource.ExternalID = *u.ExternalID
+	}
+	if u.Name != nil && *u.Name != "" {
+		resource.DisplayName = *u.Name
+		given, family := splitName(*u.Name)
+		resource.Name = &Name{Formatted: *u.Name, GivenName: given, FamilyName: family}
+	}
+	return resource
+}
+
+// userState is a user's attributes as SCIM operations change them
+// Authway keeps a single name; displayName or name.formatted set it, and otherwise it is
+// composed of name.givenName and name.familyName
+type userState struct {
+	email        string
+	display      string
+	displaySet   bool
+	given        string
+	family       string
+	partsChanged bool
+	externalID   *string
+	active       bool
+	password     string
+}
+
+func newUserState(u *user.User) *userState {
+	st := &userState{email: u.Email, externalID: u.ExternalID, active: u.Active}
+	if u.Name != nil {
+		st.display = *u.Name
+		st.given, st.family = splitName(*u.Name)
+	}
+	return st
+}
+
+func (st *userState) fullName() string {
+	if st.partsChanged && !st.displaySet {
+		return strings.TrimSpace(st.given + " " + st.family)
+	}
+	return strings.TrimSpace(st.display)
+}
+
+// replace sets every attribute of a full user resource, as sent by POST and PUT
+// Omitting active keeps the current value
+func (st *userState) replace(req *User) error {
+	st.email = strings.TrimSpace(req.UserName)
+	if st.email == "" {
+		for _, email := range req.Emails {
+			if email.Primary || st.email == "" {
+				st.email = strings.TrimSpace(email.Value)
+			}
+		}
+	}
+
+	st.display, st.displaySet, st.partsChanged = "", true, false
+	switch {
+	case req.DisplayName != "":
+		st.display = req.DisplayName
+	case req.Name != nil && req.Name.Formatted != "":
+		st.display = req.Name.Formatted
+	case req.Name != nil:
+		st.display = strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName)
+	}
+
+	st.externalID = nil
+	if externalID := strings.TrimSpace(req.ExternalID); externalID != "" {
+		st.externalID = &externalID
+	}
+	if req.Active != nil {
+		st.active = *req.Active
+	}
+	st.password = req.Password
+	return nil
+}
+
+// patch applies one PATCH operation
+// Without a path, the value is an object whose keys are attribute paths
+func (st *userState) patch(op PatchOperation) error {
+	name, err := operation(op)
+	if err != nil {
+		return err
+	}
+
+	if op.Path == "" {
+		if name == "remove" {
+			return fmt.Errorf("%w: remove needs a path", ErrInvalidPath)
+		}
+		values, err := objectValue(op.Value)
+		if err != nil {
+			return err
+		}
+		for path, value := range values {
+			if err := st.set(path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path := strings.ToLower(strings.TrimSpace(op.Path))
+	path = strings.TrimPrefix(path, strings.ToLower(SchemaUser)+":")
+	if name == "remove" {
+		return st.remove(path)
+	}
+	return st.set(path, op.Value)
+}
+
+func (st *userState) set(path string, value json.RawMessage) error {
+	var err error
+	switch path {
+	case "active":
+		st.active, err = boolValue(value)
+	case "username", "emails.value", `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		st.email, err = stringValue(value)
+	case "emails":
+		var emails []MultiValue
+		if json.Unmarshal(value, &emails) != nil || len(emails) == 0 {
+			return fmt.Errorf("%w: emails must be a non-empty list", ErrInvalidValue)
+		}
+		st.email = strings.TrimSpace(emails[0].Value)
+		for _, email := range emails {
+			if email.Primary {
+				st.email = strings.TrimSpace(email.Value)
+			}
+		}
+	case "displayname", "name.formatted":
+		st.display, err = stringValue(value)
+		st.displaySet = true
+	case "name.givenname":
+		st.given, err = stringValue(value)
+		st.partsChanged = true
+	case "name.familyname":
+		st.family, err = stringValue(value)
+		st.partsChanged = true
+	case "name":
+		var n Name
+		if json.Unmarshal(value, &n) != nil {
+			return fmt.Errorf("%w: name must be an object", ErrInvalidValue)
+		}
+		if n.Formatted != "" {
+			st.display, st.displaySet = n.Formatted, true
+		} else {
+			st.given, st.family, st.partsChanged = n.GivenName, n.FamilyName, true
+		}
+	case "externalid":
+		var externalID string
+		externalID, err = stringValue(value)
+		st.externalID = &externalID
+		if externalID == "" {
+			st.externalID = nil
+		}
+	case "password":
+		st.password, err = stringValue(value)
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	return err
+}
+
+func (st *userState) remove(path string) error {
+	switch path {
+	case "externalid":
+		st.externalID = nil
+	case "displayname", "name", "name.formatted":
+		st.display, st.displaySet = "", true
+	case "name.givenname":
+		st.given, st.partsChanged = "", true
+	case "name.familyname":
+		st.family, st.partsChanged = "", true
+	default:
+		return fmt.Errorf("%w: %s cannot be removed", ErrInvalidPath, path)
+	}
+	return nil
+}
+
+// splitName splits a full name into a given name and a family name at the first space
+func splitName(name string) (given, family string) {
+	given, family, _ = strings.Cut(strings.TrimSpace(name), " ")
+	return given, strings.TrimSpace(family)
+}
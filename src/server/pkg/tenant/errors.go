@@ -7,6 +7,9 @@ var (
 	// ErrNotFound is returned when a tenant is not found
 	ErrNotFound = errors.New("tenant not found")
 
+	// ErrDeletedNotFound is returned when restoring a tenant that does not exist or is not deleted
+	ErrDeletedNotFound = errors.New("deleted tenant not found")
+
 	// ErrDuplicateSlug is returned when attempting to create a tenant with an existing slug
 	ErrDuplicateSlug = errors.New("tenant with this slug already exists")
 
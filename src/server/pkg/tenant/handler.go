@@ -30,11 +30,13 @@ func (h *Handler) RegisterRoutes(app *fiber.App, adminMiddleware fiber.Handler)
 	// Apply admin middleware to all routes
 	api.Use(adminMiddleware)
 
-	api.Post("/", h.CreateTenant)      // POST /api/v1/tenants
-	api.Get("/", h.ListTenants)        // GET /api/v1/tenants
-	api.Get("/:id", h.GetTenant)       // GET /api/v1/tenants/:id
-	api.Put("/:id", h.UpdateTenant)    // PUT /api/v1/tenants/:id
-	api.Delete("/:id", h.DeleteTenant) // DELETE /api/v1/tenants/:id
+	api.Post("/", h.CreateTenant)             // POST /api/v1/tenants
+	api.Get("/", h.ListTenants)               // GET /api/v1/tenants
+	api.Get("/deleted", h.ListDeletedTenants) // GET /api/v1/tenants/deleted
+	api.Get("/:id", h.GetTenant)              // GET /api/v1/tenants/:id
+	api.Put("/:id", h.UpdateTenant)           // PUT /api/v1/tenants/:id
+	api.Delete("/:id", h.DeleteTenant)        // DELETE /api/v1/tenants/:id
+	api.Post("/:id/restore", h.RestoreTenant) // POST /api/v1/tenants/:id/restore
 }
 
 // CreateTenant creates a new tenant
@@ -198,3 +200,52 @@ func (h *Handler) DeleteTenant(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// ListDeletedTenants lists soft-deleted tenants that have not been purged yet
+// GET /api/v1/tenants/deleted
+func (h *Handler) ListDeletedTenants(c *fiber.Ctx) error {
+	tenants, err := h.service.ListDeletedTenants()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	publicTenants := make([]PublicTenant, len(tenants))
+	for i, tenant := range tenants {
+		publicTenants[i] = tenant.ToPublic()
+	}
+
+	return c.JSON(publicTenants)
+}
+
+// RestoreTenant undeletes a soft-deleted tenant
+// POST /api/v1/tenants/:id/restore
+func (h *Handler) RestoreTenant(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	tenant, err := h.service.RestoreTenant(id)
+	if err != nil {
+		if errors.Is(err, ErrDeletedNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Deleted tenant not found",
+			})
+		}
+		if errors.Is(err, ErrDuplicateSlug) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "The tenant's slug is now used by another tenant",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to restore tenant",
+		})
+	}
+
+	return c.JSON(tenant.ToPublic())
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,7 +17,7 @@ import (
 type Tenant struct {
 	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
 	Name         string         `json:"name" gorm:"not null"`
-	Slug         string         `json:"slug" gorm:"uniqueIndex;not null"`
+	Slug         string         `json:"slug" gorm:"not null;uniqueIndex:idx_tenants_slug_active,where:deleted_at IS NULL"` // Unique among non-deleted tenants
 	Description  string         `json:"description"`
 	Settings     TenantSettings `json:"settings" gorm:"type:jsonb"`
 	Logo         string         `json:"logo"`
@@ -33,6 +34,55 @@ type TenantSettings struct {
 	PasswordMinLength        int      `json:"password_min_length"`
 	SessionTimeout           int      `json:"session_timeout"` // in minutes
 	AllowedDomains           []string `json:"allowed_domains"`
+
+	// PasswordlessLogin lets users sign in with an emailed magic link or one-time code
+	PasswordlessLogin bool `json:"passwordless_login"`
+
+	// RegistrationMode controls self-service sign-up; empty means open
+	// domain_restricted only accepts emails from AllowedDomains
+	RegistrationMode string `json:"registration_mode,omitempty" validate:"omitempty,oneof=open domain_restricted invite_only disabled"`
+
+	// OAuthPolicy tightens the global OAuth client policy for this tenant (optional)
+	OAuthPolicy *OAuthPolicySettings `json:"oauth_policy,omitempty"`
+}
+
+// Registration modes
+const (
+	RegistrationModeOpen             = "open"
+	RegistrationModeDomainRestricted = "domain_restricted"
+	RegistrationModeInviteOnly       = "invite_only"
+	RegistrationModeDisabled         = "disabled"
+)
+
+// Registration returns the effective registration mode
+func (s TenantSettings) Registration() string {
+	if s.RegistrationMode == "" {
+		return RegistrationModeOpen
+	}
+	return s.RegistrationMode
+}
+
+// AllowsEmailDomain reports whether the email's domain is in AllowedDomains
+func (s TenantSettings) AllowsEmailDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range s.AllowedDomains {
+		if strings.ToLower(strings.TrimPrefix(allowed, "@")) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthPolicySettings contains tenant-level overrides of the OAuth client policy
+// Empty lists inherit the global configuration
+type OAuthPolicySettings struct {
+	AllowedGrantTypes []string `json:"allowed_grant_types,omitempty"`
+	AllowedScopes     []string `json:"allowed_scopes,omitempty"`
+	RequirePKCE       *bool    `json:"require_pkce,omitempty"`
 }
 
 // Scan implements sql.Scanner for TenantSettings (JSONB support)
@@ -78,20 +128,21 @@ func (t *Tenant) BeforeCreate(tx *gorm.DB) error {
 
 // PublicTenant returns tenant data safe for public consumption
 type PublicTenant struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Slug         string    `json:"slug"`
-	Description  string    `json:"description"`
-	Logo         string    `json:"logo"`
-	PrimaryColor string    `json:"primary_color"`
-	Active       bool      `json:"active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	Description  string     `json:"description"`
+	Logo         string     `json:"logo"`
+	PrimaryColor string     `json:"primary_color"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 // ToPublic converts Tenant to PublicTenant
 func (t *Tenant) ToPublic() PublicTenant {
-	return PublicTenant{
+	public := PublicTenant{
 		ID:           t.ID,
 		Name:         t.Name,
 		Slug:         t.Slug,
@@ -102,6 +153,10 @@ func (t *Tenant) ToPublic() PublicTenant {
 		CreatedAt:    t.CreatedAt,
 		UpdatedAt:    t.UpdatedAt,
 	}
+	if t.DeletedAt.Valid {
+		public.DeletedAt = &t.DeletedAt.Time
+	}
+	return public
 }
 
 // CreateTenantRequest represents the request to create a new tenant
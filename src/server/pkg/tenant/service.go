@@ -3,6 +3,7 @@ package tenant
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -19,6 +20,7 @@ func NewService(db *gorm.DB) *Service {
 }
 
 // CreateTenant creates a new tenant
+// The slug of a deleted tenant can be reused
 func (s *Service) CreateTenant(req CreateTenantRequest) (*Tenant, error) {
 	// Check if slug already exists
 	var existing Tenant
@@ -153,6 +155,64 @@ func (s *Service) DeleteTenant(id uuid.UUID) error {
 	return nil
 }
 
+// RestoreTenant undeletes a soft-deleted tenant
+// Its users and clients were deleted before it and are restored separately
+func (s *Service) RestoreTenant(id uuid.UUID) (*Tenant, error) {
+	var tenant Tenant
+	if err := s.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletedNotFound
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	// The slug may have been taken by a new tenant in the meantime
+	if _, err := s.GetTenantBySlug(tenant.Slug); err == nil {
+		return nil, ErrDuplicateSlug
+	} else if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if err := s.db.Unscoped().Model(&tenant).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("failed to restore tenant: %w", err)
+	}
+	tenant.DeletedAt = gorm.DeletedAt{}
+
+	return &tenant, nil
+}
+
+// ListDeletedTenants lists soft-deleted tenants, most recently deleted first
+func (s *Service) ListDeletedTenants() ([]Tenant, error) {
+	var tenants []Tenant
+	if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list deleted tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// PurgeTenants permanently removes the tenants soft-deleted before the given time and returns how many were removed
+// purgeClients runs first for each tenant so its clients are also removed from Hydra; a tenant whose clients
+// could not be purged is kept for the next run. The database's ON DELETE CASCADE foreign keys remove the rest
+func (s *Service) PurgeTenants(before time.Time, purgeClients func(tenantID uuid.UUID) error) (int64, error) {
+	var ids []uuid.UUID
+	if err := s.db.Unscoped().Model(&Tenant{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to find tenants to purge: %w", err)
+	}
+
+	var purged int64
+	for _, id := range ids {
+		if err := purgeClients(id); err != nil {
+			return purged, fmt.Errorf("failed to purge clients of tenant %s: %w", id, err)
+		}
+		result := s.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Delete(&Tenant{})
+		if result.Error != nil {
+			return purged, fmt.Errorf("failed to purge tenants: %w", result.Error)
+		}
+		purged += result.RowsAffected
+	}
+	return purged, nil
+}
+
 // GetDefaultTenant retrieves the default tenant
 func (s *Service) GetDefaultTenant() (*Tenant, error) {
 	return s.GetTenantByID(DefaultTenantID)
@@ -0,0 +1,80 @@
+package tenant
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_RestoreTenant(t *testing.T) {
+	service := NewService(setupTestDB(t))
+
+	original, err := service.CreateTenant(CreateTenantRequest{Name: "Acme", Slug: "acme"})
+	require.NoError(t, err)
+
+	_, err = service.RestoreTenant(original.ID)
+	assert.ErrorIs(t, err, ErrDeletedNotFound, "a live tenant cannot be restored")
+
+	require.NoError(t, service.DeleteTenant(original.ID))
+	deleted, err := service.ListDeletedTenants()
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.NotNil(t, deleted[0].ToPublic().DeletedAt)
+
+	// A deleted tenant's slug is free to use again
+	replacement, err := service.CreateTenant(CreateTenantRequest{Name: "Acme 2", Slug: "acme"})
+	require.NoError(t, err)
+
+	_, err = service.RestoreTenant(original.ID)
+	assert.ErrorIs(t, err, ErrDuplicateSlug)
+
+	require.NoError(t, service.DeleteTenant(replacement.ID))
+	restored, err := service.RestoreTenant(original.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", restored.Name)
+
+	found, err := service.GetTenantBySlug("acme")
+	require.NoError(t, err)
+	assert.Equal(t, original.ID, found.ID)
+}
+
+func TestService_PurgeTenants(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db)
+
+	old, err := service.CreateTenant(CreateTenantRequest{Name: "Old", Slug: "old"})
+	require.NoError(t, err)
+	recent, err := service.CreateTenant(CreateTenantRequest{Name: "Recent", Slug: "recent"})
+	require.NoError(t, err)
+	live, err := service.CreateTenant(CreateTenantRequest{Name: "Live", Slug: "live"})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(&Tenant{}).Where("id = ?", old.ID).Update("deleted_at", time.Now().Add(-60*24*time.Hour)).Error)
+	require.NoError(t, db.Delete(&Tenant{}, "id = ?", recent.ID).Error)
+
+	var count int64
+
+	// A tenant whose clients cannot be purged is kept for the next run
+	_, err = service.PurgeTenants(time.Now().Add(-30*24*time.Hour), func(uuid.UUID) error { return errors.New("hydra unavailable") })
+	require.Error(t, err)
+	require.NoError(t, db.Unscoped().Model(&Tenant{}).Where("id = ?", old.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	var purgedClients []uuid.UUID
+	purged, err := service.PurgeTenants(time.Now().Add(-30*24*time.Hour), func(tenantID uuid.UUID) error {
+		purgedClients = append(purgedClients, tenantID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, []uuid.UUID{old.ID}, purgedClients)
+
+	require.NoError(t, db.Unscoped().Model(&Tenant{}).Where("id IN ?", []interface{}{recent.ID, live.ID}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	require.NoError(t, db.Unscoped().Model(&Tenant{}).Where("id = ?", old.ID).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -20,6 +20,10 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	err = db.AutoMigrate(&Tenant{})
 	require.NoError(t, err)
 
+	// DeleteTenant checks for users and clients; these tests create none
+	require.NoError(t, db.Exec("CREATE TABLE users (id TEXT, tenant_id TEXT, deleted_at DATETIME)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE clients (id TEXT, tenant_id TEXT, deleted_at DATETIME)").Error)
+
 	return db
 }
 
@@ -29,13 +33,13 @@ func TestService_Create(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		request     *CreateTenantRequest
+		request     CreateTenantRequest
 		expectError bool
 		errorMsg    string
 	}{
 		{
 			name: "successful tenant creation",
-			request: &CreateTenantRequest{
+			request: CreateTenantRequest{
 				Name:        "Test Tenant",
 				Slug:        "test-tenant",
 				Description: "Test tenant description",
@@ -44,17 +48,17 @@ func TestService_Create(t *testing.T) {
 		},
 		{
 			name: "duplicate slug error",
-			request: &CreateTenantRequest{
+			request: CreateTenantRequest{
 				Name:        "Another Tenant",
 				Slug:        "test-tenant", // Same as first test
 				Description: "Another description",
 			},
 			expectError: true,
-			errorMsg:    "tenant with slug test-tenant already exists",
+			errorMsg:    "tenant with this slug already exists",
 		},
 		{
 			name: "tenant with custom settings",
-			request: &CreateTenantRequest{
+			request: CreateTenantRequest{
 				Name:         "Custom Tenant",
 				Slug:         "custom-tenant",
 				PrimaryColor: "#FF5733",
@@ -66,7 +70,7 @@ func TestService_Create(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tenant, err := service.Create(tt.request)
+			tenant, err := service.CreateTenant(tt.request)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -81,10 +85,10 @@ func TestService_Create(t *testing.T) {
 				assert.True(t, tenant.Active)
 
 				if tt.request.PrimaryColor != "" {
-					assert.Equal(t, tt.request.PrimaryColor, *tenant.PrimaryColor)
+					assert.Equal(t, tt.request.PrimaryColor, tenant.PrimaryColor)
 				}
 				if tt.request.Logo != "" {
-					assert.Equal(t, tt.request.Logo, *tenant.Logo)
+					assert.Equal(t, tt.request.Logo, tenant.Logo)
 				}
 			}
 		})
@@ -96,7 +100,7 @@ func TestService_GetByID(t *testing.T) {
 	service := NewService(db)
 
 	// Create test tenant
-	testTenant, err := service.Create(&CreateTenantRequest{
+	testTenant, err := service.CreateTenant(CreateTenantRequest{
 		Name: "Test Tenant",
 		Slug: "test-tenant",
 	})
@@ -123,7 +127,7 @@ func TestService_GetByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tenant, err := service.GetByID(tt.tenantID)
+			tenant, err := service.GetTenantByID(tt.tenantID)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -144,7 +148,7 @@ func TestService_GetBySlug(t *testing.T) {
 	service := NewService(db)
 
 	// Create test tenant
-	testTenant, err := service.Create(&CreateTenantRequest{
+	testTenant, err := service.CreateTenant(CreateTenantRequest{
 		Name: "Test Tenant",
 		Slug: "test-tenant",
 	})
@@ -171,7 +175,7 @@ func TestService_GetBySlug(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tenant, err := service.GetBySlug(tt.slug)
+			tenant, err := service.GetTenantBySlug(tt.slug)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -193,60 +197,28 @@ func TestService_List(t *testing.T) {
 
 	// Create multiple test tenants
 	for i := 1; i <= 5; i++ {
-		_, err := service.Create(&CreateTenantRequest{
+		_, err := service.CreateTenant(CreateTenantRequest{
 			Name: string(rune('A'+i-1)) + " Tenant",
 			Slug: string(rune('a'+i-1)) + "-tenant",
 		})
 		require.NoError(t, err)
 	}
 
-	tests := []struct {
-		name          string
-		limit         int
-		offset        int
-		expectedLen   int
-		expectedTotal int64
-	}{
-		{
-			name:          "get all tenants",
-			limit:         10,
-			offset:        0,
-			expectedLen:   5,
-			expectedTotal: 5,
-		},
-		{
-			name:          "paginated results - first page",
-			limit:         2,
-			offset:        0,
-			expectedLen:   2,
-			expectedTotal: 5,
-		},
-		{
-			name:          "paginated results - second page",
-			limit:         2,
-			offset:        2,
-			expectedLen:   2,
-			expectedTotal: 5,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tenants, total, err := service.List(tt.limit, tt.offset)
-
-			assert.NoError(t, err)
-			assert.Len(t, tenants, tt.expectedLen)
-			assert.Equal(t, tt.expectedTotal, total)
+	tenants, err := service.ListTenants()
+	assert.NoError(t, err)
+	assert.Len(t, tenants, 5)
 
-			// Verify tenants are not nil
-			for _, tenant := range tenants {
-				assert.NotNil(t, tenant)
-				assert.NotEmpty(t, tenant.ID)
-				assert.NotEmpty(t, tenant.Name)
-				assert.NotEmpty(t, tenant.Slug)
-			}
-		})
+	for _, tenant := range tenants {
+		assert.NotEmpty(t, tenant.ID)
+		assert.NotEmpty(t, tenant.Name)
+		assert.NotEmpty(t, tenant.Slug)
 	}
+
+	// Deleted tenants are not listed
+	require.NoError(t, service.DeleteTenant(tenants[0].ID))
+	tenants, err = service.ListTenants()
+	assert.NoError(t, err)
+	assert.Len(t, tenants, 4)
 }
 
 func TestService_Update(t *testing.T) {
@@ -254,7 +226,7 @@ func TestService_Update(t *testing.T) {
 	service := NewService(db)
 
 	// Create test tenant
-	testTenant, err := service.Create(&CreateTenantRequest{
+	testTenant, err := service.CreateTenant(CreateTenantRequest{
 		Name: "Test Tenant",
 		Slug: "test-tenant",
 	})
@@ -263,14 +235,14 @@ func TestService_Update(t *testing.T) {
 	tests := []struct {
 		name        string
 		tenantID    uuid.UUID
-		request     *UpdateTenantRequest
+		request     UpdateTenantRequest
 		expectError bool
 		errorMsg    string
 	}{
 		{
 			name:     "successful update",
 			tenantID: testTenant.ID,
-			request: &UpdateTenantRequest{
+			request: UpdateTenantRequest{
 				Name:         "Updated Tenant",
 				Description:  "Updated description",
 				PrimaryColor: "#FF5733",
@@ -280,7 +252,7 @@ func TestService_Update(t *testing.T) {
 		{
 			name:     "partial update",
 			tenantID: testTenant.ID,
-			request: &UpdateTenantRequest{
+			request: UpdateTenantRequest{
 				Name: "Partially Updated",
 			},
 			expectError: false,
@@ -288,7 +260,7 @@ func TestService_Update(t *testing.T) {
 		{
 			name:        "tenant not found",
 			tenantID:    uuid.New(),
-			request:     &UpdateTenantRequest{Name: "Test"},
+			request:     UpdateTenantRequest{Name: "Test"},
 			expectError: true,
 			errorMsg:    "tenant not found",
 		},
@@ -296,7 +268,7 @@ func TestService_Update(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tenant, err := service.Update(tt.tenantID, tt.request)
+			tenant, err := service.UpdateTenant(tt.tenantID, tt.request)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -311,10 +283,10 @@ func TestService_Update(t *testing.T) {
 					assert.Equal(t, tt.request.Name, tenant.Name)
 				}
 				if tt.request.Description != "" {
-					assert.Equal(t, tt.request.Description, *tenant.Description)
+					assert.Equal(t, tt.request.Description, tenant.Description)
 				}
 				if tt.request.PrimaryColor != "" {
-					assert.Equal(t, tt.request.PrimaryColor, *tenant.PrimaryColor)
+					assert.Equal(t, tt.request.PrimaryColor, tenant.PrimaryColor)
 				}
 			}
 		})
@@ -326,7 +298,7 @@ func TestService_Delete(t *testing.T) {
 	service := NewService(db)
 
 	// Create test tenant
-	testTenant, err := service.Create(&CreateTenantRequest{
+	testTenant, err := service.CreateTenant(CreateTenantRequest{
 		Name: "Test Tenant",
 		Slug: "test-tenant",
 	})
@@ -353,7 +325,7 @@ func TestService_Delete(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := service.Delete(tt.tenantID)
+			err := service.DeleteTenant(tt.tenantID)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -362,7 +334,7 @@ func TestService_Delete(t *testing.T) {
 				assert.NoError(t, err)
 
 				// Verify tenant is deleted
-				_, getErr := service.GetByID(tt.tenantID)
+				_, getErr := service.GetTenantByID(tt.tenantID)
 				assert.Error(t, getErr)
 				assert.Contains(t, getErr.Error(), "tenant not found")
 			}
@@ -379,10 +351,11 @@ func TestService_EnsureDefaultTenant(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Verify default tenant exists
-	tenant, err := service.GetBySlug("default")
+	tenant, err := service.GetTenantBySlug("default")
 	assert.NoError(t, err)
 	assert.NotNil(t, tenant)
-	assert.Equal(t, "Default Tenant", tenant.Name)
+	assert.Equal(t, DefaultTenantID, tenant.ID)
+	assert.Equal(t, "Default", tenant.Name)
 	assert.Equal(t, "default", tenant.Slug)
 
 	// Second call should not error (idempotent)
@@ -0,0 +1,30 @@
+package user
+
+import "errors"
+
+// User-specific errors
+var (
+	// ErrEmailTaken is returned when another user in the tenant already uses the email
+	ErrEmailTaken = errors.New("email is already in use in this tenant")
+
+	// ErrEmailChanged is returned when confirming an email change after the user's email has changed since the request
+	ErrEmailChanged = errors.New("email has changed since the change was requested")
+
+	// ErrUnknownProvider is returned for an unsupported social identity provider
+	ErrUnknownProvider = errors.New("unknown identity provider")
+
+	// ErrIdentityNotLinked is returned when unlinking a provider the user is not linked to
+	ErrIdentityNotLinked = errors.New("identity is not linked")
+
+	// ErrUnsupportedPasswordHash is returned for an imported password hash of unknown format or out-of-bounds parameters
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+	// ErrLastCredential is returned when unlinking would leave the user unable to sign in
+	ErrLastCredential = errors.New("cannot remove the last sign-in method")
+
+	// ErrDeletedNotFound is returned when restoring a user that does not exist or is not deleted
+	ErrDeletedNotFound = errors.New("deleted user not found")
+
+	// ErrTenantDeleted is returned when restoring a user whose tenant is itself deleted
+	ErrTenantDeleted = errors.New("the user's tenant is deleted")
+)
@@ -1,6 +1,9 @@
 package user
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,13 +24,65 @@ type User struct {
 	Provider      string         `json:"provider" gorm:"default:local"` // local, google, github
 	GoogleID      *string        `json:"-" gorm:"index"`
 	GithubID      *string        `json:"-" gorm:"index"`
+	ExternalID    *string        `json:"external_id,omitempty" gorm:"index"` // ID at the provisioning IdP (SCIM externalId)
 	Picture       *string        `json:"picture"`
+	Metadata      Metadata       `json:"metadata" gorm:"type:jsonb"`
+	Attributes    Attributes     `json:"attributes" gorm:"type:jsonb"` // Values of the tenant's custom attributes, see pkg/attribute
 	LastLoginAt   *time.Time     `json:"last_login_at"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
+// ErasedEmailSuffix ends the placeholder email of a user pseudonymized by a privacy erasure (see pkg/privacy)
+// Those rows are kept deleted on purpose, so they are never restored or purged
+const ErasedEmailSuffix = "@erased.invalid"
+
+// Metadata holds free-form data an administrator attaches to a user, such as IDs in other systems
+type Metadata map[string]interface{}
+
+// Scan implements sql.Scanner for Metadata (JSONB support)
+func (m *Metadata) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	default:
+		return errors.New("failed to unmarshal JSONB value")
+	}
+}
+
+// Value implements driver.Valuer for Metadata (JSONB support)
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+// Attributes holds a user's values for the custom attributes defined by the tenant, keyed by attribute key
+type Attributes map[string]interface{}
+
+// Scan implements sql.Scanner for Attributes (JSONB support)
+func (a *Attributes) Scan(value interface{}) error {
+	return (*Metadata)(a).Scan(value)
+}
+
+// Value implements driver.Valuer for Attributes (JSONB support)
+// Stored as text so SQLite's JSON functions can query it like PostgreSQL's JSONB operators
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
 // BeforeCreate sets UUID if not provided
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == uuid.Nil {
@@ -44,9 +99,12 @@ type PublicUser struct {
 	AvatarURL     string     `json:"avatar_url"`
 	EmailVerified bool       `json:"email_verified"`
 	Active        bool       `json:"active"`
+	Metadata      Metadata   `json:"metadata,omitempty"`
+	Attributes    Attributes `json:"attributes,omitempty"`
 	LastLoginAt   *time.Time `json:"last_login_at"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"` // Only set on deleted records
 }
 
 // ToPublic converts User to PublicUser
@@ -59,17 +117,23 @@ func (u *User) ToPublic() PublicUser {
 		avatarURL = *u.AvatarURL
 	}
 
-	return PublicUser{
+	public := PublicUser{
 		ID:            u.ID,
 		Email:         u.Email,
 		Name:          name,
 		AvatarURL:     avatarURL,
 		EmailVerified: u.EmailVerified,
 		Active:        u.Active,
+		Metadata:      u.Metadata,
+		Attributes:    u.Attributes,
 		LastLoginAt:   u.LastLoginAt,
 		CreatedAt:     u.CreatedAt,
 		UpdatedAt:     u.UpdatedAt,
 	}
+	if u.DeletedAt.Valid {
+		public.DeletedAt = &u.DeletedAt.Time
+	}
+	return public
 }
 
 // CreateUserRequest represents the request to create a new user
@@ -77,12 +141,29 @@ type CreateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"omitempty,min=8"` // Optional for social login
 	Name     string `json:"name" validate:"required"`
+
+	// Attributes must already be validated against the tenant's definitions (attribute.Service.Validate)
+	Attributes Attributes `json:"-"`
+}
+
+// ImportUserRequest represents a user migrated from another system with its existing password hash
+// See PasswordHashAlgorithm for the accepted hash encodings
+type ImportUserRequest struct {
+	Email         string   `json:"email" validate:"required,email"`
+	Name          string   `json:"name" validate:"max=255"`
+	EmailVerified bool     `json:"email_verified"`
+	PasswordHash  string   `json:"password_hash" validate:"max=1024"`
+	Metadata      Metadata `json:"metadata"`
 }
 
 // UpdateUserRequest represents the request to update a user
 type UpdateUserRequest struct {
 	Name      string `json:"name"`
-	AvatarURL string `json:"avatar_url"`
+	AvatarURL string `json:"avatar_url" validate:"omitempty,url"`
+
+	// Attributes are changes to custom attribute values; null removes a value
+	// Handlers replace them with the validated result of attribute.Service.Validate, which Update stores as is
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 // LoginRequest represents the login request
@@ -96,3 +177,53 @@ type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password" validate:"required"`
 	NewPassword     string `json:"new_password" validate:"required,min=8"`
 }
+
+// ChangeEmailRequest represents a self-service email change
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password"` // Required when the account has a password
+	Code     string `json:"code"`     // Emailed reauthentication code, required when it has none
+}
+
+// UpdateAvatarRequest represents a self-service avatar update
+type UpdateAvatarRequest struct {
+	AvatarURL string `json:"avatar_url" validate:"required,url"`
+}
+
+// DeleteAccountRequest represents a self-service account deletion
+type DeleteAccountRequest struct {
+	Password string `json:"password"` // Required when the account has a password
+	Code     string `json:"code"`     // Emailed reauthentication code, required when it has none
+}
+
+// UnlinkIdentityRequest represents a self-service social identity unlink
+type UnlinkIdentityRequest struct {
+	Password string `json:"password"` // Required when the account has a password
+	Code     string `json:"code"`     // Emailed reauthentication code, required when it has none
+}
+
+// Social identity providers
+const (
+	ProviderLocal  = "local"
+	ProviderGoogle = "google"
+	ProviderGithub = "github"
+)
+
+// LinkedIdentity describes a social identity linked to a user
+type LinkedIdentity struct {
+	Provider string `json:"provider"`
+	Linked   bool   `json:"linked"`
+}
+
+// HasPassword reports whether the user can sign in with a password
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// Identities returns the social identities supported by Authway and whether each is linked
+func (u *User) Identities() []LinkedIdentity {
+	return []LinkedIdentity{
+		{Provider: ProviderGoogle, Linked: u.GoogleID != nil && *u.GoogleID != ""},
+		{Provider: ProviderGithub, Linked: u.GithubID != nil && *u.GithubID != ""},
+	}
+}
@@ -0,0 +1,328 @@
+package user
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordHashCost is the bcrypt cost of new password hashes
+// Hashes of another algorithm or cost are upgraded on the next successful login
+const PasswordHashCost = bcrypt.DefaultCost
+
+// Password hash algorithms accepted for imported users
+const (
+	HashBcrypt         = "bcrypt"
+	HashArgon2id       = "argon2id"
+	HashScrypt         = "scrypt"
+	HashPBKDF2SHA256   = "pbkdf2-sha256"
+	HashSSHA           = "ssha"
+	HashSSHA256        = "ssha256"
+	HashSSHA512        = "ssha512"
+	HashFirebaseScrypt = "firebase-scrypt"
+)
+
+// Upper bounds on imported hash parameters, so that one login cannot exhaust the server
+const (
+	maxArgon2Memory   = 256 * 1024 // KiB
+	maxArgon2Time     = 16
+	maxArgon2Threads  = 16
+	maxScryptLogN     = 20
+	maxScryptR        = 32
+	maxScryptP        = 16
+	maxPBKDF2Rounds   = 10_000_000
+	maxDerivedKeySize = 128
+)
+
+// HashPassword hashes a password with the current algorithm and cost
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), PasswordHashCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+// PasswordHashAlgorithm identifies the algorithm of an encoded hash
+// Supported encodings:
+//
+//	$2a$10$...                                             bcrypt ($2a$, $2b$, $2y$)
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>           PHC string format
+//	$scrypt$ln=15,r=8,p=1$<salt>$<hash>                    PHC string format
+//	$pbkdf2-sha256$i=310000$<salt>$<hash>                  PHC string format
+//	pbkdf2_sha256$260000$<salt>$<hash>                     Django
+//	{SSHA}<base64(digest+salt)>                            RFC 2307, also {SSHA256} and {SSHA512}
+//	$firebase-scrypt$n=14,r=8,k=<signer key>,s=<salt separator>$<salt>$<hash>
+//
+// Salts and hashes are base64, with or without padding; Django salts are plain text
+func PasswordHashAlgorithm(encoded string) (string, error) {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return HashBcrypt, nil
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return HashArgon2id, nil
+	case strings.HasPrefix(encoded, "$scrypt$"):
+		return HashScrypt, nil
+	case strings.HasPrefix(encoded, "$pbkdf2-sha256$"), strings.HasPrefix(encoded, "pbkdf2_sha256$"):
+		return HashPBKDF2SHA256, nil
+	case strings.HasPrefix(encoded, "{SSHA}"):
+		return HashSSHA, nil
+	case strings.HasPrefix(encoded, "{SSHA256}"):
+		return HashSSHA256, nil
+	case strings.HasPrefix(encoded, "{SSHA512}"):
+		return HashSSHA512, nil
+	case strings.HasPrefix(encoded, "$firebase-scrypt$"):
+		return HashFirebaseScrypt, nil
+	}
+	return "", ErrUnsupportedPasswordHash
+}
+
+// ValidatePasswordHash checks that an imported hash is well-formed and within the parameter bounds
+func ValidatePasswordHash(encoded string) error {
+	_, err := checkPassword(encoded, "", false)
+	return err
+}
+
+// CheckPassword reports whether password matches the encoded hash, whatever its algorithm
+func CheckPassword(encoded, password string) (bool, error) {
+	return checkPassword(encoded, password, true)
+}
+
+// PasswordNeedsRehash reports whether a hash is not of the current algorithm and cost
+func PasswordNeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != PasswordHashCost
+}
+
+// checkPassword parses the hash and, when compare is set, verifies password against it
+func checkPassword(encoded, password string, compare bool) (bool, error) {
+	algorithm, err := PasswordHashAlgorithm(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	switch algorithm {
+	case HashBcrypt:
+		if _, err := bcrypt.Cost([]byte(encoded)); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrUnsupportedPasswordHash, err)
+		}
+		return compare && bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) == nil, nil
+	case HashSSHA, HashSSHA256, HashSSHA512:
+		return checkSaltedSHA(algorithm, encoded, password, compare)
+	case HashPBKDF2SHA256:
+		if strings.HasPrefix(encoded, "pbkdf2_sha256$") {
+			return checkDjangoPBKDF2(encoded, password, compare)
+		}
+	}
+
+	phc, err := parsePHC(encoded)
+	if err != nil {
+		return false, err
+	}
+	var derived []byte
+	switch algorithm {
+	case HashArgon2id:
+		derived, err = phc.argon2id(password, compare)
+	case HashScrypt:
+		derived, err = phc.scrypt(password, compare)
+	case HashPBKDF2SHA256:
+		derived, err = phc.pbkdf2(password, compare)
+	case HashFirebaseScrypt:
+		derived, err = phc.firebaseScrypt(password, compare)
+	}
+	if err != nil || !compare {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(derived, phc.hash) == 1, nil
+}
+
+// phcHash is a hash in the PHC string format: $id[$v=version][$param=value,...]$salt$hash
+type phcHash struct {
+	params map[string]string
+	salt   []byte
+	hash   []byte
+}
+
+func parsePHC(encoded string) (*phcHash, error) {
+	fields := strings.Split(encoded, "$")
+	// "", id, [version], params, salt, hash
+	if len(fields) < 5 || len(fields) > 6 {
+		return nil, fmt.Errorf("%w: malformed PHC string", ErrUnsupportedPasswordHash)
+	}
+	phc := &phcHash{params: map[string]string{}}
+	for _, field := range fields[2 : len(fields)-2] {
+		for _, param := range strings.Split(field, ",") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok {
+				return nil, fmt.Errorf("%w: malformed parameter %q", ErrUnsupportedPasswordHash, param)
+			}
+			phc.params[name] = value
+		}
+	}
+
+	var err error
+	if phc.salt, err = decodeHashBase64(fields[len(fields)-2]); err != nil {
+		return nil, fmt.Errorf("%w: salt: %v", ErrUnsupportedPasswordHash, err)
+	}
+	if phc.hash, err = decodeHashBase64(fields[len(fields)-1]); err != nil {
+		return nil, fmt.Errorf("%w: hash: %v", ErrUnsupportedPasswordHash, err)
+	}
+	if len(phc.hash) == 0 || len(phc.hash) > maxDerivedKeySize {
+		return nil, fmt.Errorf("%w: hash length", ErrUnsupportedPasswordHash)
+	}
+	return phc, nil
+}
+
+// int reads a numeric parameter within [1, max]
+func (p *phcHash) int(name string, max int) (int, error) {
+	v, err := strconv.Atoi(p.params[name])
+	if err != nil || v < 1 || v > max {
+		return 0, fmt.Errorf("%w: parameter %s must be between 1 and %d", ErrUnsupportedPasswordHash, name, max)
+	}
+	return v, nil
+}
+
+func (p *phcHash) argon2id(password string, compare bool) ([]byte, error) {
+	if v, ok := p.params["v"]; ok && v != "19" {
+		return nil, fmt.Errorf("%w: argon2 version %s", ErrUnsupportedPasswordHash, v)
+	}
+	memory, err := p.int("m", maxArgon2Memory)
+	if err != nil {
+		return nil, err
+	}
+	time, err := p.int("t", maxArgon2Time)
+	if err != nil {
+		return nil, err
+	}
+	threads, err := p.int("p", maxArgon2Threads)
+	if err != nil || !compare {
+		return nil, err
+	}
+	return argon2.IDKey([]byte(password), p.salt, uint32(time), uint32(memory), uint8(threads), uint32(len(p.hash))), nil
+}
+
+func (p *phcHash) scrypt(password string, compare bool) ([]byte, error) {
+	logN, err := p.int("ln", maxScryptLogN)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.int("r", maxScryptR)
+	if err != nil {
+		return nil, err
+	}
+	parallel, err := p.int("p", maxScryptP)
+	if err != nil || !compare {
+		return nil, err
+	}
+	return scrypt.Key([]byte(password), p.salt, 1<<logN, r, parallel, len(p.hash))
+}
+
+func (p *phcHash) pbkdf2(password string, compare bool) ([]byte, error) {
+	rounds, err := p.int("i", maxPBKDF2Rounds)
+	if err != nil || !compare {
+		return nil, err
+	}
+	return pbkdf2.Key([]byte(password), p.salt, rounds, len(p.hash), sha256.New), nil
+}
+
+// firebaseScrypt implements Firebase Authentication's modified scrypt: the project's signer key is
+// encrypted with AES-256-CTR under scrypt(password, salt+separator)
+func (p *phcHash) firebaseScrypt(password string, compare bool) ([]byte, error) {
+	logN, err := p.int("n", maxScryptLogN)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.int("r", maxScryptR)
+	if err != nil {
+		return nil, err
+	}
+	signerKey, err := decodeHashBase64(p.params["k"])
+	if err != nil || len(signerKey) == 0 {
+		return nil, fmt.Errorf("%w: signer key", ErrUnsupportedPasswordHash)
+	}
+	separator, err := decodeHashBase64(p.params["s"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: salt separator", ErrUnsupportedPasswordHash)
+	}
+	if !compare {
+		return nil, nil
+	}
+
+	salt := append(append([]byte{}, p.salt...), separator...)
+	key, err := scrypt.Key([]byte(password), salt, 1<<logN, r, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	derived := make([]byte, len(signerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(derived, signerKey)
+	return derived, nil
+}
+
+// checkDjangoPBKDF2 verifies Django's pbkdf2_sha256$iterations$salt$base64(hash)
+func checkDjangoPBKDF2(encoded, password string, compare bool) (bool, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 4 {
+		return false, fmt.Errorf("%w: malformed Django hash", ErrUnsupportedPasswordHash)
+	}
+	rounds, err := strconv.Atoi(fields[1])
+	if err != nil || rounds < 1 || rounds > maxPBKDF2Rounds {
+		return false, fmt.Errorf("%w: iterations must be between 1 and %d", ErrUnsupportedPasswordHash, maxPBKDF2Rounds)
+	}
+	expected, err := decodeHashBase64(fields[3])
+	if err != nil || len(expected) == 0 || len(expected) > maxDerivedKeySize {
+		return false, fmt.Errorf("%w: hash", ErrUnsupportedPasswordHash)
+	}
+	if !compare {
+		return false, nil
+	}
+	derived := pbkdf2.Key([]byte(password), []byte(fields[2]), rounds, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(derived, expected) == 1, nil
+}
+
+// checkSaltedSHA verifies an RFC 2307 salted SHA hash: {SSHA}base64(digest(password+salt)+salt)
+func checkSaltedSHA(algorithm, encoded, password string, compare bool) (bool, error) {
+	var newHash func() hash.Hash
+	switch algorithm {
+	case HashSSHA:
+		newHash = sha1.New
+	case HashSSHA256:
+		newHash = sha256.New
+	default:
+		newHash = sha512.New
+	}
+	h := newHash()
+
+	raw, err := decodeHashBase64(encoded[strings.IndexByte(encoded, '}')+1:])
+	if err != nil || len(raw) <= h.Size() {
+		return false, fmt.Errorf("%w: malformed salted SHA hash", ErrUnsupportedPasswordHash)
+	}
+	if !compare {
+		return false, nil
+	}
+	digest, salt := raw[:h.Size()], raw[h.Size():]
+	h.Write([]byte(password))
+	h.Write(salt)
+	return subtle.ConstantTimeCompare(h.Sum(nil), digest) == 1, nil
+}
+
+// decodeHashBase64 decodes standard base64 with or without padding
+func decodeHashBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
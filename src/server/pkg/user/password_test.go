@@ -0,0 +1,115 @@
+package user
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestCheckPassword_LegacyAlgorithms(t *testing.T) {
+	lowCostBcrypt, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		algorithm string
+		password  string
+		hash      string
+	}{
+		{HashBcrypt, "correct horse", string(lowCostBcrypt)},
+		// Reference vector of the Argon2 specification's implementation
+		{HashArgon2id, "password", "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"},
+		{HashScrypt, "correct horse", "$scrypt$ln=10,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$6g3umF+uVrJsObaTZhIbbTlgrvOEFcCItdwSjtPF67M"},
+		{HashPBKDF2SHA256, "correct horse", "$pbkdf2-sha256$i=1000$MDEyMzQ1Njc4OWFiY2RlZg$cBg8D2DungRB9k76szThf5ehfyBz991ay6PT8Srwk4M"},
+		{HashPBKDF2SHA256, "correct horse", "pbkdf2_sha256$1000$saltsalt$qQDPSZa3Ormyy9oK1Pu0ZLLwP2Svzmx3yu8OvDdq/J0="},
+		{HashSSHA, "correct horse", "{SSHA}cMFGhsCowtf7PbCo6UO6aSOrGMlwZXBwZXIh"},
+		{HashSSHA256, "correct horse", "{SSHA256}wz+SqWxwzp+ZNwhllQXyHOH6dtfSlGxUaWjTNMVZIPpwZXBwZXIh"},
+		{HashSSHA512, "correct horse", "{SSHA512}3mhjyqsjOhztQgKUCSOaz/3FFA2UwrQNTJNxSGO/daCluO/ksKBmOCdkSCL/WrVg3M7VHB0K1ex9J89J2Pv0FHBlcHBlciE="},
+		// Example of the Firebase scrypt reference implementation
+		{HashFirebaseScrypt, "user1password", "$firebase-scrypt$n=14,r=8," +
+			"k=jxspr8Ki0RYycVU8zykbdLGjFQ3McFUH0uiiTvC8pVMXAn210wjLNmdZJzxUECKbm0QsEmYUSDzZvpjeJ9WmXA==,s=Bw==" +
+			"$42xEC+ixf3L2lw==$lSrfV15cpx95/sZS2W9c9Kp6i/LVgQNDNC/qzrCnh1SAyZvqmZqAjTdn3aoItz+VHjoZilo78198JAdRuid5lQ=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			algorithm, err := PasswordHashAlgorithm(tt.hash)
+			require.NoError(t, err)
+			assert.Equal(t, tt.algorithm, algorithm)
+			require.NoError(t, ValidatePasswordHash(tt.hash))
+
+			ok, err := CheckPassword(tt.hash, tt.password)
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = CheckPassword(tt.hash, tt.password+"!")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			assert.True(t, PasswordNeedsRehash(tt.hash))
+		})
+	}
+}
+
+func TestValidatePasswordHash_Rejects(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$1$md5crypt$abcdef",
+		"$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=4194304,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$scrypt$ln=30,r=8,p=1$MDEyMzQ1Njc4OWFiY2RlZg$6g3umF+uVrJsObaTZhIbbTlgrvOEFcCItdwSjtPF67M",
+		"$pbkdf2-sha256$i=0$MDEyMzQ1Njc4OWFiY2RlZg$cBg8D2DungRB9k76szThf5ehfyBz991ay6PT8Srwk4M",
+		"$pbkdf2-sha256$i=1000$not base64!$cBg8D2DungRB9k76szThf5ehfyBz991ay6PT8Srwk4M",
+		"pbkdf2_sha256$many$salt$qQDPSZa3Ormyy9oK1Pu0ZLLwP2Svzmx3yu8OvDdq/J0=",
+		"{SSHA}c2hvcnQ=",
+		"$firebase-scrypt$n=14,r=8,s=Bw==$42xEC+ixf3L2lw==$lSrfV15cpx95",
+		"$2a$99$invalid",
+	} {
+		assert.ErrorIs(t, ValidatePasswordHash(hash), ErrUnsupportedPasswordHash, hash)
+	}
+}
+
+func TestService_VerifyPasswordRehashes(t *testing.T) {
+	db, service := setupAccountTestDB(t)
+	tenantID := uuid.New()
+
+	imported, err := service.Import(tenantID, &ImportUserRequest{
+		Email:         "legacy@example.com",
+		Name:          "Legacy",
+		EmailVerified: true,
+		PasswordHash:  "{SSHA256}wz+SqWxwzp+ZNwhllQXyHOH6dtfSlGxUaWjTNMVZIPpwZXBwZXIh",
+	})
+	require.NoError(t, err)
+	assert.True(t, imported.EmailVerified)
+
+	_, err = service.Import(tenantID, &ImportUserRequest{Email: "legacy@example.com"})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+	_, err = service.Import(tenantID, &ImportUserRequest{Email: "md5@example.com", PasswordHash: "5f4dcc3b5aa765d61d8327deb882cf99"})
+	assert.ErrorIs(t, err, ErrUnsupportedPasswordHash)
+
+	// A failed login keeps the legacy hash
+	assert.False(t, service.VerifyPassword(imported, "wrong password"))
+	assert.True(t, strings.HasPrefix(storedHash(t, db, imported.ID), "{SSHA256}"))
+
+	// A successful login upgrades it to the current algorithm and cost
+	assert.True(t, service.VerifyPassword(imported, "correct horse"))
+	upgraded := storedHash(t, db, imported.ID)
+	assert.False(t, PasswordNeedsRehash(upgraded))
+	assert.Equal(t, upgraded, imported.PasswordHash)
+
+	reloaded, err := service.GetByID(imported.ID)
+	require.NoError(t, err)
+	assert.True(t, service.VerifyPassword(reloaded, "correct horse"))
+	assert.Equal(t, upgraded, storedHash(t, db, imported.ID))
+}
+
+func storedHash(t *testing.T, db *gorm.DB, id uuid.UUID) string {
+	var u User
+	require.NoError(t, db.Where("id = ?", id).First(&u).Error)
+	return u.PasswordHash
+}
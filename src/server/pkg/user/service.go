@@ -6,7 +6,6 @@ import (
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -18,12 +17,19 @@ type Service interface {
 	GetByTenant(tenantID uuid.UUID, limit, offset int) ([]*User, int64, error)
 	Update(id uuid.UUID, req *UpdateUserRequest) (*User, error)
 	Delete(id uuid.UUID) error
+	Restore(id uuid.UUID) (*User, error)
+	ListDeleted(limit, offset int) ([]*User, int64, error)
+	Purge(before time.Time, purgeAssignments func(userID uuid.UUID) error) (int64, error)
 	List(limit, offset int) ([]*User, int64, error)
 	VerifyPassword(user *User, password string) bool
+	Import(tenantID uuid.UUID, req *ImportUserRequest) (*User, error)
 	ChangePassword(userID uuid.UUID, req *ChangePasswordRequest) error
 	UpdateLastLogin(userID uuid.UUID) error
 	UpdateEmailVerified(userID uuid.UUID, verified bool) error
 	UpdatePassword(userID uuid.UUID, newPassword string) error
+	CheckEmailAvailable(userID uuid.UUID, email string) error
+	ChangeEmail(userID uuid.UUID, oldEmail, newEmail string, consume func(tx *gorm.DB) error) (*User, error)
+	UnlinkIdentity(userID uuid.UUID, provider string) error
 }
 
 type service struct {
@@ -39,7 +45,7 @@ func NewService(db *gorm.DB, logger *zap.Logger) Service {
 }
 
 func (s *service) Create(tenantID uuid.UUID, req *CreateUserRequest) (*User, error) {
-	// Check if user already exists in this tenant
+	// Check if user already exists in this tenant; deleted users release their email
 	var existingUser User
 	if err := s.db.Where("tenant_id = ? AND email = ?", tenantID, req.Email).First(&existingUser).Error; err == nil {
 		return nil, fmt.Errorf("user with email %s already exists in this tenant", req.Email)
@@ -52,15 +58,16 @@ func (s *service) Create(tenantID uuid.UUID, req *CreateUserRequest) (*User, err
 		Name:          &req.Name,
 		EmailVerified: false,
 		Active:        true,
+		Attributes:    req.Attributes,
 	}
 
 	// Hash password if provided (not required for social login)
 	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		hashedPassword, err := HashPassword(req.Password)
 		if err != nil {
-			return nil, fmt.Errorf("failed to hash password: %w", err)
+			return nil, err
 		}
-		user.PasswordHash = string(hashedPassword)
+		user.PasswordHash = hashedPassword
 	}
 
 	if err := s.db.Create(user).Error; err != nil {
@@ -110,6 +117,9 @@ func (s *service) Update(id uuid.UUID, req *UpdateUserRequest) (*User, error) {
 	if req.AvatarURL != "" {
 		user.AvatarURL = &req.AvatarURL
 	}
+	if req.Attributes != nil {
+		user.Attributes = req.Attributes
+	}
 
 	if err := s.db.Save(&user).Error; err != nil {
 		s.logger.Error("Failed to update user", zap.Error(err), zap.String("id", id.String()))
@@ -135,6 +145,88 @@ func (s *service) Delete(id uuid.UUID) error {
 	return nil
 }
 
+// Restore undeletes a soft-deleted user
+// A deleted user's email is free for a new user of the tenant, so restoring fails with ErrEmailTaken once it is reused
+func (s *service) Restore(id uuid.UUID) (*User, error) {
+	var user User
+	if err := deletedUsers(s.db).Where("id = ?", id).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDeletedNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	var tenantExists bool
+	if err := s.db.Raw("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = ? AND deleted_at IS NULL)", user.TenantID).Scan(&tenantExists).Error; err != nil {
+		return nil, fmt.Errorf("failed to verify tenant: %w", err)
+	}
+	if !tenantExists {
+		return nil, ErrTenantDeleted
+	}
+
+	var count int64
+	if err := s.db.Model(&User{}).Where("tenant_id = ? AND email = ?", user.TenantID, user.Email).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrEmailTaken
+	}
+
+	if err := s.db.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+		s.logger.Error("Failed to restore user", zap.Error(err), zap.String("id", id.String()))
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+
+	s.logger.Info("User restored successfully", zap.String("id", id.String()))
+	return &user, nil
+}
+
+// ListDeleted lists the soft-deleted users that can still be restored, most recently deleted first
+func (s *service) ListDeleted(limit, offset int) ([]*User, int64, error) {
+	var users []*User
+	var total int64
+
+	if err := deletedUsers(s.db).Model(&User{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count deleted users: %w", err)
+	}
+
+	if err := deletedUsers(s.db).Order("deleted_at DESC").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list deleted users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// Purge permanently removes the users soft-deleted before the given time and returns how many were removed
+// purgeAssignments runs first for each user to remove its role assignments, which have no foreign key to users;
+// a user whose assignments could not be removed is kept for the next run. The database's ON DELETE CASCADE
+// foreign keys remove the rest
+func (s *service) Purge(before time.Time, purgeAssignments func(userID uuid.UUID) error) (int64, error) {
+	var ids []uuid.UUID
+	if err := deletedUsers(s.db).Model(&User{}).Where("deleted_at < ?", before).Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to find users to purge: %w", err)
+	}
+
+	var purged int64
+	for _, id := range ids {
+		if err := purgeAssignments(id); err != nil {
+			return purged, fmt.Errorf("failed to purge role assignments of user %s: %w", id, err)
+		}
+		result := deletedUsers(s.db).Where("id = ?", id).Delete(&User{})
+		if result.Error != nil {
+			return purged, fmt.Errorf("failed to purge users: %w", result.Error)
+		}
+		purged += result.RowsAffected
+	}
+	return purged, nil
+}
+
+// deletedUsers scopes a query to soft-deleted users, leaving out pseudonymized ones
+func deletedUsers(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Where("deleted_at IS NOT NULL AND email NOT LIKE ?", "%"+ErasedEmailSuffix)
+}
+
 func (s *service) List(limit, offset int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
@@ -152,12 +244,80 @@ func (s *service) List(limit, offset int) ([]*User, int64, error) {
 	return users, total, nil
 }
 
+// VerifyPassword checks the password against the user's hash, whatever algorithm it was imported with
+// After a successful check a hash of another algorithm or cost is replaced by a current one
 func (s *service) VerifyPassword(user *User, password string) bool {
 	if user.PasswordHash == "" {
 		return false
 	}
-	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
-	return err == nil
+	ok, err := CheckPassword(user.PasswordHash, password)
+	if err != nil {
+		s.logger.Warn("Unusable password hash", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return false
+	}
+	if ok && PasswordNeedsRehash(user.PasswordHash) {
+		s.rehashPassword(user, password)
+	}
+	return ok
+}
+
+// rehashPassword upgrades a verified password to the current algorithm; the login succeeds either way
+func (s *service) rehashPassword(user *User, password string) {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		s.logger.Warn("Failed to rehash password", zap.Error(err), zap.String("user_id", user.ID.String()))
+		return
+	}
+	// Only replace the hash that was verified, in case the password changed concurrently
+	result := s.db.Model(&User{}).
+		Where("id = ? AND password_hash = ?", user.ID, user.PasswordHash).
+		Update("password_hash", hashedPassword)
+	if result.Error != nil {
+		s.logger.Warn("Failed to rehash password", zap.Error(result.Error), zap.String("user_id", user.ID.String()))
+		return
+	}
+	algorithm, _ := PasswordHashAlgorithm(user.PasswordHash)
+	user.PasswordHash = hashedPassword
+	s.logger.Info("Password hash upgraded", zap.String("user_id", user.ID.String()), zap.String("from", algorithm))
+}
+
+// Import creates a user migrated from another system, keeping the password hash it had there
+// The hash is checked for a supported algorithm and upgraded at the user's first login
+func (s *service) Import(tenantID uuid.UUID, req *ImportUserRequest) (*User, error) {
+	if req.PasswordHash != "" {
+		if err := ValidatePasswordHash(req.PasswordHash); err != nil {
+			return nil, err
+		}
+	}
+
+	var count int64
+	if err := s.db.Model(&User{}).Where("tenant_id = ? AND email = ?", tenantID, req.Email).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrEmailTaken
+	}
+
+	user := &User{
+		TenantID:      tenantID,
+		Email:         req.Email,
+		PasswordHash:  req.PasswordHash,
+		EmailVerified: req.EmailVerified,
+		Active:        true,
+		Provider:      ProviderLocal,
+		Metadata:      req.Metadata,
+	}
+	if req.Name != "" {
+		user.Name = &req.Name
+	}
+
+	if err := s.db.Create(user).Error; err != nil {
+		s.logger.Error("Failed to import user", zap.Error(err), zap.String("email", req.Email), zap.String("tenant_id", tenantID.String()))
+		return nil, fmt.Errorf("failed to import user: %w", err)
+	}
+
+	s.logger.Info("User imported", zap.String("id", user.ID.String()), zap.String("tenant_id", tenantID.String()))
+	return user, nil
 }
 
 func (s *service) ChangePassword(userID uuid.UUID, req *ChangePasswordRequest) error {
@@ -173,13 +333,13 @@ func (s *service) ChangePassword(userID uuid.UUID, req *ChangePasswordRequest) e
 	}
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(req.NewPassword)
 	if err != nil {
-		return fmt.Errorf("failed to hash new password: %w", err)
+		return err
 	}
 
 	// Update password
-	if err := s.db.Model(user).Update("password_hash", string(hashedPassword)).Error; err != nil {
+	if err := s.db.Model(user).Update("password_hash", hashedPassword).Error; err != nil {
 		s.logger.Error("Failed to update password", zap.Error(err), zap.String("user_id", userID.String()))
 		return fmt.Errorf("failed to update password: %w", err)
 	}
@@ -210,13 +370,13 @@ func (s *service) UpdateEmailVerified(userID uuid.UUID, verified bool) error {
 // UpdatePassword updates user password (for password reset)
 func (s *service) UpdatePassword(userID uuid.UUID, newPassword string) error {
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(newPassword)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return err
 	}
 
 	// Update password
-	if err := s.db.Model(&User{}).Where("id = ?", userID).Update("password_hash", string(hashedPassword)).Error; err != nil {
+	if err := s.db.Model(&User{}).Where("id = ?", userID).Update("password_hash", hashedPassword).Error; err != nil {
 		s.logger.Error("Failed to update password", zap.Error(err), zap.String("user_id", userID.String()))
 		return fmt.Errorf("failed to update password: %w", err)
 	}
@@ -254,3 +414,98 @@ func (s *service) GetByTenant(tenantID uuid.UUID, limit, offset int) ([]*User, i
 
 	return users, total, nil
 }
+
+// CheckEmailAvailable returns ErrEmailTaken if another user in the user's tenant has the email
+func (s *service) CheckEmailAvailable(userID uuid.UUID, email string) error {
+	user, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.Model(&User{}).Where("tenant_id = ? AND email = ? AND id <> ?", user.TenantID, email, userID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// ChangeEmail applies a confirmed email change if the user's email is still oldEmail
+// consume runs first in the same transaction and must claim the pending change, so a change applies at most once.
+// The new address was confirmed through a link sent to it, so it is marked verified
+func (s *service) ChangeEmail(userID uuid.UUID, oldEmail, newEmail string, consume func(tx *gorm.DB) error) (*User, error) {
+	if err := s.CheckEmailAvailable(userID, newEmail); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := consume(tx); err != nil {
+			return err
+		}
+
+		result := tx.Model(&User{}).Where("id = ? AND email = ?", userID, oldEmail).Updates(map[string]interface{}{
+			"email":          newEmail,
+			"email_verified": true,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to change email: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrEmailChanged
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("Failed to change email", zap.Error(err), zap.String("user_id", userID.String()))
+		return nil, err
+	}
+
+	s.logger.Info("Email changed", zap.String("user_id", userID.String()))
+	return s.GetByID(userID)
+}
+
+// UnlinkIdentity removes a linked social identity
+// The user must keep at least one way to sign in (password or another identity)
+func (s *service) UnlinkIdentity(userID uuid.UUID, provider string) error {
+	user, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	var column string
+	switch provider {
+	case ProviderGoogle:
+		column = "google_id"
+	case ProviderGithub:
+		column = "github_id"
+	default:
+		return ErrUnknownProvider
+	}
+
+	linked := 0
+	unlinking := false
+	for _, identity := range user.Identities() {
+		if identity.Linked {
+			linked++
+			if identity.Provider == provider {
+				unlinking = true
+			}
+		}
+	}
+	if !unlinking {
+		return ErrIdentityNotLinked
+	}
+	if linked == 1 && !user.HasPassword() {
+		return ErrLastCredential
+	}
+
+	if err := s.db.Model(user).Update(column, nil).Error; err != nil {
+		s.logger.Error("Failed to unlink identity", zap.Error(err), zap.String("user_id", userID.String()), zap.String("provider", provider))
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	s.logger.Info("Identity unlinked", zap.String("user_id", userID.String()), zap.String("provider", provider))
+	return nil
+}
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupAccountTestDB(t *testing.T) (*gorm.DB, Service) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	return db, NewService(db, zaptest.NewLogger(t))
+}
+
+func TestService_ChangeEmail(t *testing.T) {
+	db, service := setupAccountTestDB(t)
+	tenantID := uuid.New()
+	otherTenantID := uuid.New()
+
+	u, err := service.Create(tenantID, &CreateUserRequest{Email: "old@example.com", Password: "password123", Name: "User"})
+	require.NoError(t, err)
+	_, err = service.Create(tenantID, &CreateUserRequest{Email: "taken@example.com", Password: "password123", Name: "Other"})
+	require.NoError(t, err)
+	_, err = service.Create(otherTenantID, &CreateUserRequest{Email: "elsewhere@example.com", Password: "password123", Name: "Other"})
+	require.NoError(t, err)
+
+	claim := func(tx *gorm.DB) error { return nil }
+
+	// Email must be unique within the tenant
+	assert.ErrorIs(t, service.CheckEmailAvailable(u.ID, "taken@example.com"), ErrEmailTaken)
+	_, err = service.ChangeEmail(u.ID, "old@example.com", "taken@example.com", claim)
+	assert.ErrorIs(t, err, ErrEmailTaken)
+
+	// A change requested for a different current email is rejected
+	_, err = service.ChangeEmail(u.ID, "stale@example.com", "elsewhere@example.com", claim)
+	assert.ErrorIs(t, err, ErrEmailChanged)
+
+	// The email is left unchanged when the change cannot be claimed
+	claimErr := errors.New("already confirmed")
+	_, err = service.ChangeEmail(u.ID, "old@example.com", "elsewhere@example.com", func(tx *gorm.DB) error { return claimErr })
+	assert.ErrorIs(t, err, claimErr)
+
+	// Emails in other tenants do not conflict
+	require.NoError(t, service.CheckEmailAvailable(u.ID, "elsewhere@example.com"))
+	changed, err := service.ChangeEmail(u.ID, "old@example.com", "elsewhere@example.com", claim)
+	require.NoError(t, err)
+	assert.Equal(t, "elsewhere@example.com", changed.Email)
+
+	// A confirmed change verifies the new address
+	var stored User
+	require.NoError(t, db.First(&stored, "id = ?", u.ID).Error)
+	assert.Equal(t, "elsewhere@example.com", stored.Email)
+	assert.True(t, stored.EmailVerified)
+}
+
+func TestService_UnlinkIdentity(t *testing.T) {
+	db, service := setupAccountTestDB(t)
+	googleID := "google-123"
+	githubID := "github-456"
+
+	tests := []struct {
+		name        string
+		user        *User
+		provider    string
+		expectError error
+	}{
+		{
+			name:     "password user unlinks only identity",
+			user:     &User{TenantID: uuid.New(), Email: "a@example.com", PasswordHash: "hash", GoogleID: &googleID},
+			provider: ProviderGoogle,
+		},
+		{
+			name:     "social user keeps another identity",
+			user:     &User{TenantID: uuid.New(), Email: "b@example.com", GoogleID: &googleID, GithubID: &githubID},
+			provider: ProviderGithub,
+		},
+		{
+			name:        "social user cannot remove last identity",
+			user:        &User{TenantID: uuid.New(), Email: "c@example.com", GoogleID: &googleID},
+			provider:    ProviderGoogle,
+			expectError: ErrLastCredential,
+		},
+		{
+			name:        "identity not linked",
+			user:        &User{TenantID: uuid.New(), Email: "d@example.com", PasswordHash: "hash"},
+			provider:    ProviderGithub,
+			expectError: ErrIdentityNotLinked,
+		},
+		{
+			name:        "unknown provider",
+			user:        &User{TenantID: uuid.New(), Email: "e@example.com", PasswordHash: "hash"},
+			provider:    "myspace",
+			expectError: ErrUnknownProvider,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, db.Create(tt.user).Error)
+
+			err := service.UnlinkIdentity(tt.user.ID, tt.provider)
+			if tt.expectError != nil {
+				assert.ErrorIs(t, err, tt.expectError)
+				return
+			}
+			require.NoError(t, err)
+
+			updated, err := service.GetByID(tt.user.ID)
+			require.NoError(t, err)
+			for _, identity := range updated.Identities() {
+				if identity.Provider == tt.provider {
+					assert.False(t, identity.Linked)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"authway/src/server/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRestoreTestDB(t *testing.T) (*gorm.DB, Service, uuid.UUID) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&tenant.Tenant{}, &User{}))
+
+	tn := &tenant.Tenant{ID: uuid.New(), Name: "Acme", Slug: "acme", Active: true}
+	require.NoError(t, db.Create(tn).Error)
+	return db, NewService(db, zaptest.NewLogger(t)), tn.ID
+}
+
+func TestService_RestoreUser(t *testing.T) {
+	db, service, tenantID := setupRestoreTestDB(t)
+
+	u, err := service.Create(tenantID, &CreateUserRequest{Email: "user@example.com", Password: "password123", Name: "User"})
+	require.NoError(t, err)
+
+	_, err = service.Restore(u.ID)
+	assert.ErrorIs(t, err, ErrDeletedNotFound, "a live user cannot be restored")
+
+	require.NoError(t, service.Delete(u.ID))
+
+	deleted, total, err := service.ListDeleted(20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, deleted, 1)
+	assert.NotNil(t, deleted[0].ToPublic().DeletedAt)
+
+	restored, err := service.Restore(u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, restored.ToPublic().DeletedAt)
+
+	found, err := service.GetByID(u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", found.Email)
+
+	// The tenant must not be deleted itself
+	require.NoError(t, service.Delete(u.ID))
+	require.NoError(t, db.Delete(&tenant.Tenant{}, "id = ?", tenantID).Error)
+	_, err = service.Restore(u.ID)
+	assert.ErrorIs(t, err, ErrTenantDeleted)
+}
+
+func TestService_DeletedUserEmailReuse(t *testing.T) {
+	_, service, tenantID := setupRestoreTestDB(t)
+
+	original, err := service.Create(tenantID, &CreateUserRequest{Email: "user@example.com", Password: "password123", Name: "Original"})
+	require.NoError(t, err)
+	require.NoError(t, service.Delete(original.ID))
+
+	// A deleted user's email is free to register again
+	replacement, err := service.Create(tenantID, &CreateUserRequest{Email: "user@example.com", Password: "password123", Name: "Replacement"})
+	require.NoError(t, err)
+
+	// ...so the original can no longer be restored
+	_, err = service.Restore(original.ID)
+	assert.ErrorIs(t, err, ErrEmailTaken)
+
+	require.NoError(t, service.Delete(replacement.ID))
+	_, err = service.Restore(original.ID)
+	assert.NoError(t, err)
+}
+
+func TestService_PurgeUsers(t *testing.T) {
+	db, service, tenantID := setupRestoreTestDB(t)
+
+	old, err := service.Create(tenantID, &CreateUserRequest{Email: "old@example.com", Password: "password123", Name: "Old"})
+	require.NoError(t, err)
+	recent, err := service.Create(tenantID, &CreateUserRequest{Email: "recent@example.com", Password: "password123", Name: "Recent"})
+	require.NoError(t, err)
+	live, err := service.Create(tenantID, &CreateUserRequest{Email: "live@example.com", Password: "password123", Name: "Live"})
+	require.NoError(t, err)
+	erased, err := service.Create(tenantID, &CreateUserRequest{Email: "erased-x" + ErasedEmailSuffix, Password: "password123", Name: "Erased"})
+	require.NoError(t, err)
+
+	longAgo := time.Now().Add(-60 * 24 * time.Hour)
+	require.NoError(t, db.Model(&User{}).Where("id IN ?", []uuid.UUID{old.ID, erased.ID}).Update("deleted_at", longAgo).Error)
+	require.NoError(t, service.Delete(recent.ID))
+
+	// Pseudonymized users are not restorable
+	_, err = service.Restore(erased.ID)
+	assert.ErrorIs(t, err, ErrDeletedNotFound)
+
+	before := time.Now().Add(-30 * 24 * time.Hour)
+
+	// A user whose role assignments cannot be removed is kept for the next run
+	purged, err := service.Purge(before, func(uuid.UUID) error { return errors.New("rbac unavailable") })
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	var assignmentsPurged []uuid.UUID
+	purged, err = service.Purge(before, func(userID uuid.UUID) error {
+		assignmentsPurged = append(assignmentsPurged, userID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, []uuid.UUID{old.ID}, assignmentsPurged)
+
+	var remaining []uuid.UUID
+	require.NoError(t, db.Unscoped().Model(&User{}).Pluck("id", &remaining).Error)
+	assert.ElementsMatch(t, []uuid.UUID{recent.ID, live.ID, erased.ID}, remaining)
+}
@@ -13,6 +13,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// testTenantID is the tenant the users of these tests belong to
+var testTenantID = uuid.New()
+
 func setupTestDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
@@ -38,29 +41,26 @@ func TestService_Create(t *testing.T) {
 		{
 			name: "successful user creation",
 			request: &CreateUserRequest{
-				Email:     "test@example.com",
-				Password:  "password123",
-				FirstName: "John",
-				LastName:  "Doe",
+				Email:    "test@example.com",
+				Password: "password123",
+				Name:     "John Doe",
 			},
 			expectError: false,
 		},
 		{
 			name: "user creation without password (social login)",
 			request: &CreateUserRequest{
-				Email:     "social@example.com",
-				FirstName: "Jane",
-				LastName:  "Smith",
+				Email: "social@example.com",
+				Name:  "Jane Smith",
 			},
 			expectError: false,
 		},
 		{
 			name: "duplicate email error",
 			request: &CreateUserRequest{
-				Email:     "test@example.com", // Same as first test
-				Password:  "password123",
-				FirstName: "Another",
-				LastName:  "User",
+				Email:    "test@example.com", // Same as first test
+				Password: "password123",
+				Name:     "Another User",
 			},
 			expectError: true,
 			errorMsg:    "user with email test@example.com already exists",
@@ -69,7 +69,7 @@ func TestService_Create(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, err := service.Create(tt.request)
+			user, err := service.Create(testTenantID, tt.request)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -80,17 +80,16 @@ func TestService_Create(t *testing.T) {
 				assert.NotNil(t, user)
 				assert.NotEmpty(t, user.ID)
 				assert.Equal(t, tt.request.Email, user.Email)
-				assert.Equal(t, tt.request.FirstName, *user.FirstName)
-				assert.Equal(t, tt.request.LastName, *user.LastName)
+				assert.Equal(t, tt.request.Name, *user.Name)
 				assert.False(t, user.EmailVerified)
 				assert.True(t, user.Active)
 
 				if tt.request.Password != "" {
-					assert.NotNil(t, user.PasswordHash)
+					assert.NotEmpty(t, user.PasswordHash)
 					// Verify password can be verified
 					assert.True(t, service.VerifyPassword(user, tt.request.Password))
 				} else {
-					assert.Nil(t, user.PasswordHash)
+					assert.Empty(t, user.PasswordHash)
 				}
 			}
 		})
@@ -103,11 +102,10 @@ func TestService_GetByID(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
@@ -154,11 +152,10 @@ func TestService_GetByEmail(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
@@ -205,11 +202,10 @@ func TestService_Update(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
@@ -224,9 +220,8 @@ func TestService_Update(t *testing.T) {
 			name:   "successful update",
 			userID: testUser.ID,
 			request: &UpdateUserRequest{
-				FirstName: "Jane",
-				LastName:  "Smith",
-				Avatar:    "https://example.com/avatar.jpg",
+				Name:      "Jane Smith",
+				AvatarURL: "https://example.com/avatar.jpg",
 			},
 			expectError: false,
 		},
@@ -234,14 +229,14 @@ func TestService_Update(t *testing.T) {
 			name:   "partial update",
 			userID: testUser.ID,
 			request: &UpdateUserRequest{
-				FirstName: "Updated",
+				Name: "Updated",
 			},
 			expectError: false,
 		},
 		{
 			name:        "user not found",
 			userID:      uuid.New(),
-			request:     &UpdateUserRequest{FirstName: "Test"},
+			request:     &UpdateUserRequest{Name: "Test"},
 			expectError: true,
 			errorMsg:    "user not found",
 		},
@@ -260,14 +255,11 @@ func TestService_Update(t *testing.T) {
 				assert.NotNil(t, user)
 				assert.Equal(t, tt.userID, user.ID)
 
-				if tt.request.FirstName != "" {
-					assert.Equal(t, tt.request.FirstName, *user.FirstName)
-				}
-				if tt.request.LastName != "" {
-					assert.Equal(t, tt.request.LastName, *user.LastName)
+				if tt.request.Name != "" {
+					assert.Equal(t, tt.request.Name, *user.Name)
 				}
-				if tt.request.Avatar != "" {
-					assert.Equal(t, tt.request.Avatar, *user.Avatar)
+				if tt.request.AvatarURL != "" {
+					assert.Equal(t, tt.request.AvatarURL, *user.AvatarURL)
 				}
 			}
 		})
@@ -280,11 +272,10 @@ func TestService_Delete(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
@@ -334,11 +325,10 @@ func TestService_List(t *testing.T) {
 	// Create multiple test users
 	users := make([]*User, 5)
 	for i := 0; i < 5; i++ {
-		user, err := service.Create(&CreateUserRequest{
-			Email:     fmt.Sprintf("user%d@example.com", i),
-			Password:  "password123",
-			FirstName: fmt.Sprintf("User%d", i),
-			LastName:  "Test",
+		user, err := service.Create(testTenantID, &CreateUserRequest{
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Password: "password123",
+			Name:     fmt.Sprintf("User%d Test", i),
 		})
 		require.NoError(t, err)
 		users[i] = user
@@ -412,19 +402,17 @@ func TestService_VerifyPassword(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user with password
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
 	// Create test user without password (social login)
-	socialUser, err := service.Create(&CreateUserRequest{
-		Email:     "social@example.com",
-		FirstName: "Jane",
-		LastName:  "Smith",
+	socialUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email: "social@example.com",
+		Name:  "Jane Smith",
 	})
 	require.NoError(t, err)
 
@@ -468,19 +456,17 @@ func TestService_ChangePassword(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "oldpassword",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "oldpassword",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
 	// Create user without password
-	socialUser, err := service.Create(&CreateUserRequest{
-		Email:     "social@example.com",
-		FirstName: "Jane",
-		LastName:  "Smith",
+	socialUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email: "social@example.com",
+		Name:  "Jane Smith",
 	})
 	require.NoError(t, err)
 
@@ -555,11 +541,10 @@ func TestService_UpdateLastLogin(t *testing.T) {
 	service := NewService(db, logger)
 
 	// Create test user
-	testUser, err := service.Create(&CreateUserRequest{
-		Email:     "test@example.com",
-		Password:  "password123",
-		FirstName: "John",
-		LastName:  "Doe",
+	testUser, err := service.Create(testTenantID, &CreateUserRequest{
+		Email:    "test@example.com",
+		Password: "password123",
+		Name:     "John Doe",
 	})
 	require.NoError(t, err)
 
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Event types
+const (
+	EventGroupMembersAdded   = "group.members_added"
+	EventGroupMembersRemoved = "group.members_removed"
+	EventGroupDeleted        = "group.deleted"
+)
+
+// Delivery headers
+const (
+	HeaderEvent     = "X-Authway-Event"
+	HeaderSignature = "X-Authway-Signature"
+)
+
+// maxAttempts is the number of delivery attempts before an event is dropped
+const maxAttempts = 3
+
+// Event is the JSON body posted to the webhook endpoint
+type Event struct {
+	ID         uuid.UUID   `json:"id"`
+	Type       string      `json:"type"`
+	TenantID   uuid.UUID   `json:"tenant_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Publisher delivers events to subscribers
+type Publisher interface {
+	Publish(eventType string, tenantID uuid.UUID, data interface{})
+}
+
+// New creates a publisher posting to url; an empty url disables webhooks
+func New(url, secret string, logger *zap.Logger) Publisher {
+	if url == "" {
+		return nopPublisher{}
+	}
+	return NewDispatcher(url, secret, logger)
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(string, uuid.UUID, interface{}) {}
+
+// Dispatcher posts events to a single endpoint, signing the body with HMAC-SHA256
+type Dispatcher struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+	logger     *zap.Logger
+	retryDelay time.Duration
+}
+
+func NewDispatcher(url, secret string, logger *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		url:        url,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		retryDelay: 2 * time.Second,
+	}
+}
+
+// Publish delivers the event in the background so callers never wait on the subscriber
+func (d *Dispatcher) Publish(eventType string, tenantID uuid.UUID, data interface{}) {
+	event := &Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		TenantID:   tenantID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+
+	go func() {
+		var err error
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			if err = d.Send(event); err == nil {
+				return
+			}
+			time.Sleep(time.Duration(attempt) * d.retryDelay)
+		}
+		d.logger.Error("Failed to deliver webhook event",
+			zap.Error(err),
+			zap.String("event_id", event.ID.String()),
+			zap.String("type", event.Type))
+	}()
+}
+
+// Send posts a single event and returns an error unless the endpoint answers 2xx
+func (d *Dispatcher) Send(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.Type)
+	if d.secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.secret, body))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for a request body
+// Subscribers recompute it with the shared secret to authenticate deliveries
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDispatcher_Send(t *testing.T) {
+	var received Event
+	var signature, eventType string
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(HeaderSignature)
+		eventType = r.Header.Get(HeaderEvent)
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(server.URL, "shared-secret", zap.NewNop())
+	event := &Event{
+		ID:         uuid.New(),
+		Type:       EventGroupMembersAdded,
+		TenantID:   uuid.New(),
+		OccurredAt: time.Now().UTC(),
+		Data:       map[string]string{"group_id": "g1"},
+	}
+
+	require.NoError(t, dispatcher.Send(event))
+	assert.Equal(t, EventGroupMembersAdded, eventType)
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, Sign("shared-secret", body), signature)
+	assert.NotEqual(t, Sign("other-secret", body), signature)
+}
+
+func TestDispatcher_SendRejectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(server.URL, "", zap.NewNop())
+	assert.Error(t, dispatcher.Send(&Event{ID: uuid.New(), Type: EventGroupDeleted}))
+}
+
+func TestDispatcher_PublishRetries(t *testing.T) {
+	var attempts int32
+	delivered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		close(delivered)
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher(server.URL, "", zap.NewNop())
+	dispatcher.retryDelay = time.Millisecond
+	dispatcher.Publish(EventGroupMembersRemoved, uuid.New(), nil)
+
+	select {
+	case <-delivered:
+		assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not redelivered after a failed attempt")
+	}
+}
+
+func TestNew_EmptyURLDisablesWebhooks(t *testing.T) {
+	publisher := New("", "secret", zap.NewNop())
+	_, ok := publisher.(nopPublisher)
+	assert.True(t, ok)
+	publisher.Publish(EventGroupDeleted, uuid.New(), nil)
+}